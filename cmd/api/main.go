@@ -2,84 +2,269 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"goapi/internal/api/config"
+	"goapi/internal/api/health"
+	"goapi/internal/api/logging"
+	"goapi/internal/api/metrics"
 	"goapi/internal/api/repository/DAL/SQLite"
+	"goapi/internal/api/repository/models"
 	"goapi/internal/api/server"
 	"goapi/internal/api/service"
+	"goapi/internal/api/tabular"
+	"goapi/internal/api/tracing"
 	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 )
 
-// NewSimpleLogger creates a new log.Logger that writes to a file.
-// The file is created if it does not exist, and append to it if it does.
-// The file is created with mode 0644.
-// The logger also writes to os.Stdout.
-// The logger prefixes each log entry with the current date and time and the file name and line number of the calling code.
-// The flags argument defines the logging properties.
-// The flags are Ldate, Ltime, and Lshortfile.
-// The Ldate flag causes the logger to write the current date in the local time zone: 2009/01/23.
-// The Ltime flag causes the logger to write the current time in the local time zone: 01:23:23.
-// The Lshortfile flag causes the logger to write the file name and line number: logger.go:24.
-func NewSimpleLogger(logFile string) *log.Logger {
+// NewSimpleLogger creates a JSON slog.Logger that writes to a file and to os.Stdout.
+// The file is created if it does not exist, and appended to if it does. The file is created with mode 0644.
+// level is the minimum level (debug, info, warn or error).
+// Records logged with a request context include the request ID, so a request can be followed from the access log
+// through the handler down to the SQL statements it executed (logged at debug level).
+func NewSimpleLogger(logFile string, level string) *slog.Logger {
 
 	file, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		log.Fatal(err)
 
 	}
-	return log.New(io.MultiWriter(file, os.Stdout), "", log.Ldate|log.Ltime|log.Lshortfile)
+	return logging.New(io.MultiWriter(file, os.Stdout), logging.ParseLevel(level))
 }
 
 func main() {
 
+	// * Subcommands run instead of the server *
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		os.Exit(importCommand(os.Args[2:]))
+	}
+
+	// * Load the configuration: defaults < config file < environment < flags *
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
 	// * Timeout is used to gracefully shutdown the server *
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// * Create a logger and database connection *
-	logger := NewSimpleLogger("production.log")
-	db, err := SQLite.NewSqlite("production.db")
+	logger := NewSimpleLogger(cfg.Log.File, cfg.Log.Level)
+	slog.SetDefault(logger)
+	db, err := SQLite.NewSqlite(cfg.Database.DSN, SQLite.PoolConfig{
+		MaxOpenConns:    cfg.Database.MaxOpenConns,
+		MaxIdleConns:    cfg.Database.MaxIdleConns,
+		ConnMaxLifetime: cfg.Database.ConnMaxLifetime,
+	})
 	if err != nil {
-		logger.Println("Error setting up database:", err)
+		logger.Error("Error setting up database", "error", err)
 		return
 	}
 	defer db.Close()
 
+	// * Fail fast when the database cannot be opened, sql.Open does not connect *
+	pingCtx, pingCancel := context.WithTimeout(ctx, 5*time.Second)
+	err = db.Connection().PingContext(pingCtx)
+	pingCancel()
+	if err != nil {
+		logger.Error("Error connecting to database", "error", err)
+		return
+	}
+
+	// * Export traces, configured with the OTEL_* environment variables *
+	shutdownTracing, err := tracing.Setup(ctx)
+	if err != nil {
+		logger.Error("Error setting up tracing", "error", err)
+		return
+	}
+	defer func() {
+		// The server context is cancelled by now, give the exporter its own time to flush
+		flushCtx, flushCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer flushCancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			logger.Error("Error flushing traces", "error", err)
+		}
+	}()
+
+	// * Collect metrics, the metrics token protects /metrics with a bearer token when set *
+	m := metrics.New(cfg.Metrics.Token)
+	m.RegisterDB(db.Connection(), "sqlite")
+	db.SetQueryObserver(m.ObserveQuery)
+
 	// * Create a service factory and API server *
-	sf := service.NewServiceFactory(db, logger, ctx)
+	sf := service.NewServiceFactory(db, logger, ctx, cfg)
+
+	// * Readiness checks served on /readyz, workers add their own checks *
+	checker := health.New()
+	checker.AddCheck("database", db.Connection().PingContext)
+	checker.AddCheck("schema", func(ctx context.Context) error {
+		return SQLite.CheckSchema(ctx, db)
+	})
 
 	// * Create the API server *
-	server := server.NewServer(ctx, sf, logger)
+	server := server.NewServer(ctx, cfg, sf, logger, m, checker)
 
 	// * Setup graceful shutdown *
-	gracefullShutdown(server, cancel, logger)
+	shutdownDone := gracefullShutdown(server, cancel, logger)
 
 	// * Start the server *
-	logger.Println("Starting server on :8080...")
-	if err := server.ListenAndServe(":8080"); err != nil {
+	logger.Info("Starting server on "+cfg.Server.Addr+"...", "db", cfg.Database.DSN, "auth_mode", cfg.Auth.Mode)
+	if err := server.ListenAndServe(); err != nil {
 		// If the server was shutdown gracefully, don't log a startup error
 		if err != http.ErrServerClosed {
-			logger.Println("Server startup error:", err)
+			logger.Error("Server startup error", "error", err)
+			return
 		}
-		logger.Println("Server gracefully shutdown complete.")
+		// ListenAndServe returns as soon as shutdown starts, wait for in-flight requests to finish
+		<-shutdownDone
+		logger.Info("Server gracefully shutdown complete.")
 		return
 	}
 }
 
-func gracefullShutdown(server *server.Server, cancel context.CancelFunc, logger *log.Logger) {
+// gracefullShutdown shuts the server down on SIGINT or SIGTERM, the returned channel is closed once it is done
+func gracefullShutdown(server *server.Server, cancel context.CancelFunc, logger *slog.Logger) <-chan struct{} {
 
 	signalCh := make(chan os.Signal, 1)
 	signal.Notify(signalCh, syscall.SIGINT, syscall.SIGTERM)
+	done := make(chan struct{})
 
 	// * Listen for signals to shutdown the server gracefully *
 	go func() {
 		<-signalCh
-		cancel()
 		if err := server.Shutdown(); err != nil {
-			logger.Println("Error shutting down API Server:", err)
+			logger.Error("Error shutting down API Server", "error", err)
 		}
+		// * Stop background workers and close the repositories once requests are done *
+		cancel()
+		close(done)
 	}()
+	return done
+}
+
+// formats maps the -format values and file name extensions to the formats an import reads
+var formats = map[string]string{
+	"csv":    tabular.CSV,
+	"ndjson": tabular.NDJSON,
+	"jsonl":  tabular.NDJSON,
+}
+
+// importCommand loads a CSV or NDJSON file into a table straight into the database, like POST /v1/import/{table}
+// but without its body limit and timeout. It takes the configuration flags of the server next to its own:
+// ./api import -table maze_device_status -dry-run maze_device_status.csv
+// The report is printed to stdout. The exit status is 1 when the import failed, rejected rows do not fail it.
+func importCommand(args []string) int {
+	fs := flag.NewFlagSet("api import", flag.ContinueOnError)
+	table := fs.String("table", "", "table to import: "+models.ImportMazeDeviceStatus+", "+models.ImportDeviceConfig+" or "+models.ImportData)
+	dryRun := fs.Bool("dry-run", false, "validate every row and check it for duplicates without storing any")
+	chunkSize := fs.Int("chunk-size", models.DefaultImportChunkSize, "rows stored per transaction")
+	formatName := fs.String("format", "", "csv or ndjson, taken from the file name extension when empty")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: api import -table <table> [flags] <file>, - reads stdin")
+		fs.PrintDefaults()
+	}
+
+	// * Load the configuration like the server, it names the database *
+	cfg, err := config.LoadFlags(fs, args, os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	switch *table {
+	case models.ImportMazeDeviceStatus, models.ImportDeviceConfig, models.ImportData:
+	default:
+		fmt.Fprintf(os.Stderr, "unknown table %q, import %s, %s or %s\n", *table, models.ImportMazeDeviceStatus, models.ImportDeviceConfig, models.ImportData)
+		return 2
+	}
+	path := fs.Arg(0)
+	name := *formatName
+	if name == "" {
+		name = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+	format, ok := formats[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown format %q, use -format csv or -format ndjson\n", name)
+		return 2
+	}
+
+	var file io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer f.Close()
+		file = f
+	}
+
+	// * Chunks committed before an interrupt stay stored *
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// * Log to stderr, stdout holds the report *
+	logger := logging.New(os.Stderr, logging.ParseLevel(cfg.Log.Level))
+	db, err := SQLite.NewSqlite(cfg.Database.DSN, SQLite.PoolConfig{
+		MaxOpenConns:    cfg.Database.MaxOpenConns,
+		MaxIdleConns:    cfg.Database.MaxIdleConns,
+		ConnMaxLifetime: cfg.Database.ConnMaxLifetime,
+	})
+	if err != nil {
+		logger.Error("Error setting up database", "error", err)
+		return 1
+	}
+	defer db.Close()
+
+	sf := service.NewServiceFactory(db, logger, ctx, cfg)
+	is, err := sf.CreateImportService(service.SQLiteDataService)
+	if err != nil {
+		logger.Error("Error setting up import service", "error", err)
+		return 1
+	}
+
+	options := &models.ImportOptions{DryRun: *dryRun, ChunkSize: *chunkSize}
+	var report *models.ImportReport
+	switch *table {
+	case models.ImportMazeDeviceStatus:
+		report, err = is.ImportStatuses(file, format, options, ctx)
+	case models.ImportDeviceConfig:
+		report, err = is.ImportConfigs(file, format, options, ctx)
+	case models.ImportData:
+		report, err = is.ImportData(file, format, options, ctx)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if encodeErr := encoder.Encode(report); encodeErr != nil {
+		logger.Error("Error writing import report", "error", encodeErr)
+	}
+	if err != nil {
+		logger.Error("Import failed", "error", err, "table", *table, "rows", report.Rows, "chunks", report.Chunks, "accepted", report.Accepted)
+		return 1
+	}
+	logger.Info("Import finished", "table", *table, "dry_run", report.DryRun, "rows", report.Rows, "accepted", report.Accepted, "duplicates", report.Duplicates, "rejected", report.Rejected)
+	return 0
 }
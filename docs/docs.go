@@ -0,0 +1,8 @@
+package docs
+
+import _ "embed"
+
+// OpenAPI is the OpenAPI document of the API, the contract every registered route has to be listed in
+//
+//go:embed openapi.yaml
+var OpenAPI []byte
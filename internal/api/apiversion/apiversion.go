@@ -0,0 +1,34 @@
+// Package apiversion names the versions of the API. Routes are served under a /<version> prefix, e.g. /v1/data,
+// and handlers look up the version of the route that matched to pick the request and response shapes.
+package apiversion
+
+import "context"
+
+const (
+	V1 = "v1"
+	// Latest is the version the models in repository/models follow
+	Latest = V1
+	// Legacy is the version the deprecated unversioned routes are answered with, the firmware in the field was
+	// built against it
+	Legacy = V1
+)
+
+// Versions lists every served version, oldest first
+var Versions = []string{V1}
+
+type contextKey int
+
+const versionKey contextKey = iota
+
+// WithVersion returns a copy of ctx carrying the API version
+func WithVersion(ctx context.Context, version string) context.Context {
+	return context.WithValue(ctx, versionKey, version)
+}
+
+// FromContext returns the API version stored in ctx, Latest when there is none
+func FromContext(ctx context.Context) string {
+	if version, ok := ctx.Value(versionKey).(string); ok {
+		return version
+	}
+	return Latest
+}
@@ -0,0 +1,110 @@
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goapi/internal/api/repository/models"
+	"io"
+)
+
+// ErrEmpty is returned for a batch without items
+var ErrEmpty = errors.New("the batch is empty")
+
+// maxLineBytes bounds a single NDJSON line, the whole body is bounded by the body limit middleware
+const maxLineBytes = 1 << 20
+
+// Decode reads a batch upload, either a JSON array of items or newline delimited JSON (NDJSON) with one item per line.
+// Malformed items fail the whole batch, the error names the position of the item.
+func Decode[T any](body io.Reader) ([]*T, error) {
+	reader := bufio.NewReader(body)
+
+	// The first non-space byte tells an array from NDJSON
+	for {
+		b, err := reader.ReadByte()
+		if err == io.EOF {
+			return nil, ErrEmpty
+		}
+		if err != nil {
+			return nil, err
+		}
+		if b == ' ' || b == '\t' || b == '\r' || b == '\n' {
+			continue
+		}
+		reader.UnreadByte()
+		if b == '[' {
+			return decodeArray[T](reader)
+		}
+		return decodeLines[T](reader)
+	}
+}
+
+func decodeArray[T any](reader io.Reader) ([]*T, error) {
+	var items []*T
+	if err := json.NewDecoder(reader).Decode(&items); err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, ErrEmpty
+	}
+	for i, item := range items {
+		if item == nil {
+			return nil, fmt.Errorf("item %d is null", i)
+		}
+	}
+	return items, nil
+}
+
+func decodeLines[T any](reader io.Reader) ([]*T, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineBytes)
+
+	var items []*T
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var item *T
+		if err := json.Unmarshal(text, &item); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if item == nil {
+			return nil, fmt.Errorf("line %d is null", line)
+		}
+		items = append(items, item)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, ErrEmpty
+	}
+	return items, nil
+}
+
+// Response is the body returned for a batch upload, the results follow the order of the items
+type Response struct {
+	Accepted   int                  `json:"accepted"`
+	Duplicates int                  `json:"duplicates"`
+	Rejected   int                  `json:"rejected"`
+	Results    []models.BatchResult `json:"results"`
+}
+
+// NewResponse counts the outcomes of the results
+func NewResponse(results []models.BatchResult) Response {
+	response := Response{Results: results}
+	for _, result := range results {
+		switch result.Status {
+		case models.BatchAccepted:
+			response.Accepted++
+		case models.BatchDuplicate:
+			response.Duplicates++
+		case models.BatchRejected:
+			response.Rejected++
+		}
+	}
+	return response
+}
@@ -0,0 +1,82 @@
+package batch
+
+import (
+	"errors"
+	"goapi/internal/api/repository/models"
+	"strings"
+	"testing"
+)
+
+type item struct {
+	Name string `json:"name"`
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		names []string
+	}{
+		{name: "Array", body: `[{"name":"a"},{"name":"b"}]`, names: []string{"a", "b"}},
+		{name: "Array after whitespace", body: "\n  [{\"name\":\"a\"}]", names: []string{"a"}},
+		{name: "NDJSON", body: "{\"name\":\"a\"}\n{\"name\":\"b\"}\n", names: []string{"a", "b"}},
+		{name: "NDJSON with blank lines and CRLF", body: "{\"name\":\"a\"}\r\n\r\n{\"name\":\"b\"}", names: []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, err := Decode[item](strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(items) != len(tt.names) {
+				t.Fatalf("Expected %d items, got %d", len(tt.names), len(items))
+			}
+			for i, name := range tt.names {
+				if items[i].Name != name {
+					t.Errorf("Expected item %d to be %s, got %s", i, name, items[i].Name)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		contains string
+	}{
+		{name: "Empty body", body: "", contains: ErrEmpty.Error()},
+		{name: "Empty array", body: "[]", contains: ErrEmpty.Error()},
+		{name: "Null in array", body: `[{"name":"a"},null]`, contains: "item 1"},
+		{name: "Malformed array", body: `[{"name":"a"},`, contains: "unexpected EOF"},
+		{name: "Malformed line", body: "{\"name\":\"a\"}\n{\"name\":\n", contains: "line 2"},
+		{name: "Wrong type on line", body: "{\"name\":\"a\"}\n\n{\"name\":1}", contains: "line 3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode[item](strings.NewReader(tt.body))
+			if err == nil || !strings.Contains(err.Error(), tt.contains) {
+				t.Errorf("Expected error containing %q, got %v", tt.contains, err)
+			}
+		})
+	}
+
+	if _, err := Decode[item](strings.NewReader("  \n")); !errors.Is(err, ErrEmpty) {
+		t.Errorf("Expected ErrEmpty for a blank body, got %v", err)
+	}
+}
+
+func TestNewResponse(t *testing.T) {
+	response := NewResponse([]models.BatchResult{
+		{Index: 0, Status: models.BatchAccepted, ID: 1},
+		{Index: 1, Status: models.BatchDuplicate, ID: 1},
+		{Index: 2, Status: models.BatchRejected, Error: "Invalid"},
+		{Index: 3, Status: models.BatchAccepted, ID: 2},
+	})
+	if response.Accepted != 2 || response.Duplicates != 1 || response.Rejected != 1 || len(response.Results) != 4 {
+		t.Errorf("Unexpected response %+v", response)
+	}
+}
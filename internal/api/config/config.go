@@ -0,0 +1,581 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Authentication modes
+const (
+	// AuthBasic accepts the configured admin account and credentials issued to provisioned devices
+	AuthBasic = "basic"
+	// AuthNone disables authentication, every request runs as the configured admin account. Only for local development.
+	AuthNone = "none"
+)
+
+// Config holds the settings of the API server.
+// Values are layered with increasing precedence: defaults, the YAML config file, environment variables and flags.
+type Config struct {
+	Server   ServerConfig   `yaml:"server"`
+	Database DatabaseConfig `yaml:"database"`
+	Log      LogConfig      `yaml:"log"`
+	Auth     AuthConfig     `yaml:"auth"`
+	Limits   LimitsConfig   `yaml:"limits"`
+	Metrics  MetricsConfig  `yaml:"metrics"`
+	Firmware FirmwareConfig `yaml:"firmware"`
+	CORS     CORSConfig     `yaml:"cors"`
+}
+
+type ServerConfig struct {
+	Addr               string        `yaml:"addr"`                 // Listen address, e.g. ":8080"
+	RequestTimeout     time.Duration `yaml:"request_timeout"`      // Deadline of a request's context, bounds its database calls
+	UploadTimeout      time.Duration `yaml:"upload_timeout"`       // Request timeout of firmware uploads
+	ExportTimeout      time.Duration `yaml:"export_timeout"`       // Request timeout of table exports, they stream whole tables
+	ImportTimeout      time.Duration `yaml:"import_timeout"`       // Request timeout of table imports, they load whole files
+	ShutdownDrainDelay time.Duration `yaml:"shutdown_drain_delay"` // How long /readyz fails before the server stops accepting connections
+	ShutdownTimeout    time.Duration `yaml:"shutdown_timeout"`     // How long in-flight requests may take to finish during shutdown
+	IdempotencyTTL     time.Duration `yaml:"idempotency_ttl"`      // How long responses to POST requests with an Idempotency-Key are replayed
+	ContractValidation string        `yaml:"contract_validation"`  // "off", "log" or "enforce"
+	LegacySunset       string        `yaml:"legacy_sunset"`        // Date the unversioned routes are removed, e.g. "2027-06-30", empty when not decided
+	TLS                TLSConfig     `yaml:"tls"`
+}
+
+// Contract validation modes, traffic is checked against the OpenAPI document in docs/openapi.yaml
+const (
+	ContractOff     = "off"     // Traffic is not checked
+	ContractLog     = "log"     // Requests and responses that do not match the document are logged
+	ContractEnforce = "enforce" // Like log, and requests that do not match are rejected with 400
+)
+
+// SunsetLayout is the date format of server.legacy_sunset
+const SunsetLayout = "2006-01-02"
+
+// Client certificate modes
+const (
+	ClientAuthNone     = "none"     // Client certificates are not requested
+	ClientAuthOptional = "optional" // Devices may authenticate with a client certificate instead of Basic credentials
+	ClientAuthRequire  = "require"  // Every connection must present a client certificate signed by the client CA
+)
+
+// TLSConfig enables HTTPS when a certificate and key are set. The files are reloaded when they change.
+type TLSConfig struct {
+	CertFile     string `yaml:"cert_file"`
+	KeyFile      string `yaml:"key_file"`
+	ClientCAFile string `yaml:"client_ca_file"` // CA that signs device client certificates, the certificate CN is the device ID
+	ClientAuth   string `yaml:"client_auth"`    // "none", "optional" or "require"
+}
+
+// Enabled reports whether the server serves HTTPS
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != ""
+}
+
+type DatabaseConfig struct {
+	DSN             string        `yaml:"dsn"` // SQLite data source name, e.g. "production.db"
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+}
+
+type LogConfig struct {
+	File  string `yaml:"file"`  // Log file, logs are also written to stdout
+	Level string `yaml:"level"` // debug, info, warn or error
+}
+
+type AuthConfig struct {
+	Mode     string        `yaml:"mode"` // "basic" or "none"
+	Username string        `yaml:"username"`
+	Password string        `yaml:"password"`
+	Lockout  LockoutConfig `yaml:"lockout"`
+	Tokens   TokenConfig   `yaml:"tokens"`
+}
+
+// TokenConfig configures the tokens issued by POST /auth/login. Access tokens are HS256 signed JWTs,
+// refresh tokens are random and stored hashed.
+type TokenConfig struct {
+	Secret     string        `yaml:"secret"`      // HMAC key of at least 32 bytes, a random key is generated when empty
+	AccessTTL  time.Duration `yaml:"access_ttl"`  // Lifetime of an access token, a revoked session keeps it until then
+	RefreshTTL time.Duration `yaml:"refresh_ttl"` // Lifetime of a refresh token
+}
+
+// LockoutConfig slows down and locks out repeated failed logins. Every failure after the first doubles the delay
+// of the 401 response up to MaxDelay, reaching a limit within Window locks the username or IP address for Duration.
+type LockoutConfig struct {
+	MaxFailures      int           `yaml:"max_failures"`        // Failed logins per username before it is locked, 0 disables
+	MaxFailuresPerIP int           `yaml:"max_failures_per_ip"` // Failed logins per source IP before it is locked, 0 disables
+	Window           time.Duration `yaml:"window"`
+	Duration         time.Duration `yaml:"duration"`
+	MaxDelay         time.Duration `yaml:"max_delay"`
+}
+
+// LimitsConfig bounds how much a single client can send. Rate limits apply per authenticated user or device,
+// and per IP address on public routes.
+type LimitsConfig struct {
+	RateLimit          `yaml:",inline"`
+	Routes             map[string]RateLimit `yaml:"routes"`                // Rate limits of single routes keyed by unversioned pattern, e.g. "POST /device/status", for every API version
+	MaxBodyBytes       int64                `yaml:"max_body_bytes"`        // Maximum request body size
+	UploadMaxBodyBytes int64                `yaml:"upload_max_body_bytes"` // Maximum body size of firmware uploads
+	ImportMaxBodyBytes int64                `yaml:"import_max_body_bytes"` // Maximum body size of table imports
+}
+
+// RateLimit is a token bucket, a client can make Burst requests at once and then RequestsPerSecond
+type RateLimit struct {
+	RequestsPerSecond float64 `yaml:"requests_per_second"` // 0 disables the limit
+	Burst             int     `yaml:"burst"`
+}
+
+type MetricsConfig struct {
+	Token string `yaml:"token"` // Bearer token required on /metrics, open when empty
+}
+
+type FirmwareConfig struct {
+	Dir string `yaml:"dir"` // Directory uploaded firmware binaries are stored in
+}
+
+// CORSConfig decides which browser origins may call the API. A preflight is answered with the methods registered
+// for its path that are also allowed here.
+type CORSConfig struct {
+	AllowedOrigins   []string      `yaml:"allowed_origins"`   // Origins like "https://dashboard.example.com", "*" allows every origin, empty allows none
+	AllowedMethods   []string      `yaml:"allowed_methods"`   // Methods cross-origin requests may use
+	AllowedHeaders   []string      `yaml:"allowed_headers"`   // Request headers cross-origin requests may send, "*" allows any
+	ExposedHeaders   []string      `yaml:"exposed_headers"`   // Response headers scripts may read besides the CORS-safelisted ones
+	AllowCredentials bool          `yaml:"allow_credentials"` // Whether browsers send cookies and Authorization, needs listed origins
+	MaxAge           time.Duration `yaml:"max_age"`           // How long browsers cache a preflight response, 0 leaves it to the browser
+}
+
+// corsMethods are the methods routes are registered with
+var corsMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// Default returns the configuration used when nothing is configured
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:               ":8080",
+			RequestTimeout:     2 * time.Second,
+			UploadTimeout:      10 * time.Second,
+			ExportTimeout:      5 * time.Minute,
+			ImportTimeout:      5 * time.Minute,
+			ShutdownDrainDelay: 5 * time.Second,
+			ShutdownTimeout:    10 * time.Second,
+			IdempotencyTTL:     24 * time.Hour,
+			ContractValidation: ContractOff,
+			TLS: TLSConfig{
+				ClientAuth: ClientAuthNone,
+			},
+		},
+		Database: DatabaseConfig{
+			DSN:             "production.db",
+			MaxOpenConns:    10,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 5 * time.Minute,
+		},
+		Log: LogConfig{
+			File:  "production.log",
+			Level: "info",
+		},
+		Auth: AuthConfig{
+			Mode:     AuthBasic,
+			Username: "admin",
+			Password: "password",
+			Lockout: LockoutConfig{
+				MaxFailures:      5,
+				MaxFailuresPerIP: 20,
+				Window:           15 * time.Minute,
+				Duration:         15 * time.Minute,
+				MaxDelay:         2 * time.Second,
+			},
+			Tokens: TokenConfig{
+				AccessTTL:  15 * time.Minute,
+				RefreshTTL: 30 * 24 * time.Hour,
+			},
+		},
+		Limits: LimitsConfig{
+			RateLimit: RateLimit{RequestsPerSecond: 10, Burst: 20},
+			Routes: map[string]RateLimit{
+				// Claim codes are short, slow down guessing them on the public route
+				"POST /provisioning/claim": {RequestsPerSecond: 0.1, Burst: 5},
+			},
+			MaxBodyBytes:       1 << 20,
+			UploadMaxBodyBytes: 16 << 20,
+			ImportMaxBodyBytes: 64 << 20,
+		},
+		Firmware: FirmwareConfig{
+			Dir: "firmware_releases",
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{"*"},
+			AllowedMethods: slices.Clone(corsMethods),
+			AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", "Idempotency-Key", "If-Match", "If-None-Match", "X-Request-ID"},
+			ExposedHeaders: []string{"Content-Disposition", "Deprecation", "ETag", "Idempotent-Replayed", "Link", "Retry-After", "Sunset", "X-Request-ID"},
+			MaxAge:         10 * time.Minute,
+		},
+	}
+}
+
+// setting binds a config value to its environment variable and flag
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	field func(c *Config) any // Pointer to the string, int, int64, float64, bool, time.Duration or []string field, lists are comma separated
+}
+
+var settings = []setting{
+	{"LISTEN_ADDR", "addr", "listen address", func(c *Config) any { return &c.Server.Addr }},
+	{"REQUEST_TIMEOUT", "request-timeout", "request timeout", func(c *Config) any { return &c.Server.RequestTimeout }},
+	{"UPLOAD_TIMEOUT", "upload-timeout", "firmware upload timeout", func(c *Config) any { return &c.Server.UploadTimeout }},
+	{"EXPORT_TIMEOUT", "export-timeout", "table export timeout", func(c *Config) any { return &c.Server.ExportTimeout }},
+	{"IMPORT_TIMEOUT", "import-timeout", "table import timeout", func(c *Config) any { return &c.Server.ImportTimeout }},
+	{"SHUTDOWN_DRAIN_DELAY", "shutdown-drain-delay", "time readiness fails before shutting down", func(c *Config) any { return &c.Server.ShutdownDrainDelay }},
+	{"SHUTDOWN_TIMEOUT", "shutdown-timeout", "time in-flight requests may take during shutdown", func(c *Config) any { return &c.Server.ShutdownTimeout }},
+	{"IDEMPOTENCY_TTL", "idempotency-ttl", "how long responses to retried POST requests are replayed", func(c *Config) any { return &c.Server.IdempotencyTTL }},
+	{"CONTRACT_VALIDATION", "contract-validation", "check traffic against the OpenAPI document: off, log or enforce", func(c *Config) any { return &c.Server.ContractValidation }},
+	{"LEGACY_SUNSET", "legacy-sunset", "date the unversioned routes are removed, announced in the Sunset header (YYYY-MM-DD)", func(c *Config) any { return &c.Server.LegacySunset }},
+	{"TLS_CERT_FILE", "tls-cert", "TLS certificate file, enables HTTPS", func(c *Config) any { return &c.Server.TLS.CertFile }},
+	{"TLS_KEY_FILE", "tls-key", "TLS private key file", func(c *Config) any { return &c.Server.TLS.KeyFile }},
+	{"TLS_CLIENT_CA_FILE", "tls-client-ca", "CA file to verify device client certificates", func(c *Config) any { return &c.Server.TLS.ClientCAFile }},
+	{"TLS_CLIENT_AUTH", "tls-client-auth", "client certificates: none, optional or require", func(c *Config) any { return &c.Server.TLS.ClientAuth }},
+	{"DB_PATH", "db", "SQLite data source name", func(c *Config) any { return &c.Database.DSN }},
+	{"DB_MAX_OPEN_CONNS", "db-max-open-conns", "maximum open database connections", func(c *Config) any { return &c.Database.MaxOpenConns }},
+	{"DB_MAX_IDLE_CONNS", "db-max-idle-conns", "maximum idle database connections", func(c *Config) any { return &c.Database.MaxIdleConns }},
+	{"DB_CONN_MAX_LIFETIME", "db-conn-max-lifetime", "maximum lifetime of a database connection", func(c *Config) any { return &c.Database.ConnMaxLifetime }},
+	{"LOG_FILE", "log-file", "log file", func(c *Config) any { return &c.Log.File }},
+	{"LOG_LEVEL", "log-level", "log level: debug, info, warn or error", func(c *Config) any { return &c.Log.Level }},
+	{"AUTH_MODE", "auth-mode", "authentication mode: basic or none", func(c *Config) any { return &c.Auth.Mode }},
+	{"BASIC_AUTH_USERNAME", "auth-username", "admin account username", func(c *Config) any { return &c.Auth.Username }},
+	{"BASIC_AUTH_PASSWORD", "auth-password", "admin account password", func(c *Config) any { return &c.Auth.Password }},
+	{"LOCKOUT_MAX_FAILURES", "lockout-max-failures", "failed logins per username before a lockout, 0 disables", func(c *Config) any { return &c.Auth.Lockout.MaxFailures }},
+	{"LOCKOUT_MAX_FAILURES_PER_IP", "lockout-max-failures-per-ip", "failed logins per IP address before a lockout, 0 disables", func(c *Config) any { return &c.Auth.Lockout.MaxFailuresPerIP }},
+	{"LOCKOUT_WINDOW", "lockout-window", "time failed logins are counted", func(c *Config) any { return &c.Auth.Lockout.Window }},
+	{"LOCKOUT_DURATION", "lockout-duration", "how long a lockout lasts", func(c *Config) any { return &c.Auth.Lockout.Duration }},
+	{"LOCKOUT_MAX_DELAY", "lockout-max-delay", "maximum delay added to a failed login", func(c *Config) any { return &c.Auth.Lockout.MaxDelay }},
+	{"JWT_SECRET", "jwt-secret", "HMAC key signing access tokens, random when empty", func(c *Config) any { return &c.Auth.Tokens.Secret }},
+	{"ACCESS_TOKEN_TTL", "access-token-ttl", "access token lifetime", func(c *Config) any { return &c.Auth.Tokens.AccessTTL }},
+	{"REFRESH_TOKEN_TTL", "refresh-token-ttl", "refresh token lifetime", func(c *Config) any { return &c.Auth.Tokens.RefreshTTL }},
+	{"RATE_LIMIT_RPS", "rate-limit", "requests per second per client, 0 disables", func(c *Config) any { return &c.Limits.RequestsPerSecond }},
+	{"RATE_LIMIT_BURST", "rate-limit-burst", "requests a client can make at once", func(c *Config) any { return &c.Limits.Burst }},
+	{"MAX_BODY_BYTES", "max-body-bytes", "maximum request body size", func(c *Config) any { return &c.Limits.MaxBodyBytes }},
+	{"UPLOAD_MAX_BODY_BYTES", "upload-max-body-bytes", "maximum firmware upload size", func(c *Config) any { return &c.Limits.UploadMaxBodyBytes }},
+	{"IMPORT_MAX_BODY_BYTES", "import-max-body-bytes", "maximum table import size", func(c *Config) any { return &c.Limits.ImportMaxBodyBytes }},
+	{"METRICS_TOKEN", "metrics-token", "bearer token required on /metrics", func(c *Config) any { return &c.Metrics.Token }},
+	{"FIRMWARE_DIR", "firmware-dir", "firmware binary directory", func(c *Config) any { return &c.Firmware.Dir }},
+	{"CORS_ALLOWED_ORIGINS", "cors-allowed-origins", "comma separated origins browsers may call the API from, * for any", func(c *Config) any { return &c.CORS.AllowedOrigins }},
+	{"CORS_ALLOWED_METHODS", "cors-allowed-methods", "comma separated methods of cross-origin requests", func(c *Config) any { return &c.CORS.AllowedMethods }},
+	{"CORS_ALLOWED_HEADERS", "cors-allowed-headers", "comma separated request headers of cross-origin requests, * for any", func(c *Config) any { return &c.CORS.AllowedHeaders }},
+	{"CORS_EXPOSED_HEADERS", "cors-exposed-headers", "comma separated response headers readable by cross-origin scripts", func(c *Config) any { return &c.CORS.ExposedHeaders }},
+	{"CORS_ALLOW_CREDENTIALS", "cors-allow-credentials", "allow credentialed cross-origin requests, needs listed origins", func(c *Config) any { return &c.CORS.AllowCredentials }},
+	{"CORS_MAX_AGE", "cors-max-age", "how long browsers cache a preflight response", func(c *Config) any { return &c.CORS.MaxAge }},
+}
+
+// Load builds the configuration from the config file, environment and command line arguments (without the program name).
+// The config file is given with -config or CONFIG_FILE. SERVER_HOST and SERVER_PORT set the parts of the listen address,
+// LISTEN_ADDR sets all of it.
+func Load(args []string, getenv func(string) string) (*Config, error) {
+	return LoadFlags(flag.NewFlagSet("api", flag.ContinueOnError), args, getenv)
+}
+
+// LoadFlags is Load parsing args with fs, so a subcommand can define its own flags next to the configuration flags
+func LoadFlags(fs *flag.FlagSet, args []string, getenv func(string) string) (*Config, error) {
+	defaults := Default()
+
+	configFile := fs.String("config", getenv("CONFIG_FILE"), "YAML config file (env CONFIG_FILE)")
+	flags := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		usage := fmt.Sprintf("%s (env %s, default %v)", s.usage, s.env, deref(s.field(defaults)))
+		flags[s.flag] = fs.String(s.flag, "", usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := defaults
+	if *configFile != "" {
+		if err := loadFile(cfg, *configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if host, port := getenv("SERVER_HOST"), getenv("SERVER_PORT"); host != "" || port != "" {
+		currentHost, currentPort, _ := net.SplitHostPort(cfg.Server.Addr)
+		if host == "" {
+			host = currentHost
+		}
+		if port == "" {
+			port = currentPort
+		}
+		cfg.Server.Addr = net.JoinHostPort(host, port)
+	}
+	for _, s := range settings {
+		if value := getenv(s.env); value != "" {
+			if err := setValue(s.field(cfg), value); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", s.env, err)
+			}
+		}
+	}
+
+	// Only flags given on the command line override, fs.Visit skips the others
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, s := range settings {
+		if set[s.flag] {
+			if err := setValue(s.field(cfg), *flags[s.flag]); err != nil {
+				return nil, fmt.Errorf("invalid -%s: %w", s.flag, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	// An empty file leaves the defaults in place
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports all invalid settings at once, so a misconfigured deployment fails at startup rather than on first use
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Server.Addr == "" {
+		errs = append(errs, errors.New("server.addr must not be empty"))
+	}
+	if c.Server.RequestTimeout <= 0 {
+		errs = append(errs, errors.New("server.request_timeout must be positive"))
+	}
+	if c.Server.UploadTimeout <= 0 {
+		errs = append(errs, errors.New("server.upload_timeout must be positive"))
+	}
+	if c.Server.ExportTimeout <= 0 {
+		errs = append(errs, errors.New("server.export_timeout must be positive"))
+	}
+	if c.Server.ImportTimeout <= 0 {
+		errs = append(errs, errors.New("server.import_timeout must be positive"))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdown_timeout must be positive"))
+	}
+	if c.Server.IdempotencyTTL <= 0 {
+		errs = append(errs, errors.New("server.idempotency_ttl must be positive"))
+	}
+	switch c.Server.ContractValidation {
+	case ContractOff, ContractLog, ContractEnforce:
+	default:
+		errs = append(errs, fmt.Errorf("server.contract_validation %q must be %s, %s or %s", c.Server.ContractValidation, ContractOff, ContractLog, ContractEnforce))
+	}
+	if c.Server.LegacySunset != "" {
+		if _, err := time.Parse(SunsetLayout, c.Server.LegacySunset); err != nil {
+			errs = append(errs, fmt.Errorf("server.legacy_sunset %q must be a date like 2027-06-30", c.Server.LegacySunset))
+		}
+	}
+	if (c.Server.TLS.CertFile == "") != (c.Server.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("server.tls.cert_file and server.tls.key_file must be set together"))
+	}
+	switch c.Server.TLS.ClientAuth {
+	case ClientAuthNone:
+	case ClientAuthOptional, ClientAuthRequire:
+		if c.Server.TLS.ClientCAFile == "" || !c.Server.TLS.Enabled() {
+			errs = append(errs, fmt.Errorf("server.tls.client_auth %q requires server.tls.client_ca_file and a server certificate", c.Server.TLS.ClientAuth))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("server.tls.client_auth %q must be %s, %s or %s", c.Server.TLS.ClientAuth, ClientAuthNone, ClientAuthOptional, ClientAuthRequire))
+	}
+	if c.Server.ShutdownDrainDelay < 0 {
+		errs = append(errs, errors.New("server.shutdown_drain_delay must not be negative"))
+	}
+	if c.Database.DSN == "" {
+		errs = append(errs, errors.New("database.dsn must not be empty"))
+	}
+	if c.Database.MaxOpenConns < 1 {
+		errs = append(errs, errors.New("database.max_open_conns must be at least 1"))
+	}
+	if c.Database.MaxIdleConns < 0 || c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		errs = append(errs, errors.New("database.max_idle_conns must be between 0 and database.max_open_conns"))
+	}
+	if c.Database.ConnMaxLifetime < 0 {
+		errs = append(errs, errors.New("database.conn_max_lifetime must not be negative"))
+	}
+	if c.Log.File == "" {
+		errs = append(errs, errors.New("log.file must not be empty"))
+	}
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log.level %q must be debug, info, warn or error", c.Log.Level))
+	}
+	switch c.Auth.Mode {
+	case AuthBasic, AuthNone:
+	default:
+		errs = append(errs, fmt.Errorf("auth.mode %q must be %s or %s", c.Auth.Mode, AuthBasic, AuthNone))
+	}
+	if c.Auth.Username == "" || strings.Contains(c.Auth.Username, ":") {
+		errs = append(errs, errors.New("auth.username must be set and must not contain ':'"))
+	}
+	if c.Auth.Mode == AuthBasic && c.Auth.Password == "" {
+		errs = append(errs, errors.New("auth.password must be set in basic mode"))
+	}
+	if c.Auth.Lockout.MaxFailures < 0 || c.Auth.Lockout.MaxFailuresPerIP < 0 {
+		errs = append(errs, errors.New("auth.lockout.max_failures and auth.lockout.max_failures_per_ip must not be negative"))
+	}
+	if c.Auth.Lockout.Window <= 0 || c.Auth.Lockout.Duration <= 0 || c.Auth.Lockout.MaxDelay < 0 {
+		errs = append(errs, errors.New("auth.lockout.window and auth.lockout.duration must be positive, auth.lockout.max_delay must not be negative"))
+	}
+	if c.Auth.Tokens.Secret != "" && len(c.Auth.Tokens.Secret) < 32 {
+		errs = append(errs, errors.New("auth.tokens.secret must be at least 32 bytes"))
+	}
+	if c.Auth.Tokens.AccessTTL <= 0 || c.Auth.Tokens.RefreshTTL < c.Auth.Tokens.AccessTTL {
+		errs = append(errs, errors.New("auth.tokens.access_ttl must be positive and auth.tokens.refresh_ttl at least as long"))
+	}
+	if err := c.Limits.RateLimit.validate("limits"); err != nil {
+		errs = append(errs, err)
+	}
+	for route, limit := range c.Limits.Routes {
+		if err := limit.validate(fmt.Sprintf("limits.routes[%q]", route)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.Limits.MaxBodyBytes < 1 || c.Limits.UploadMaxBodyBytes < 1 || c.Limits.ImportMaxBodyBytes < 1 {
+		errs = append(errs, errors.New("limits.max_body_bytes, limits.upload_max_body_bytes and limits.import_max_body_bytes must be positive"))
+	}
+	if c.Firmware.Dir == "" {
+		errs = append(errs, errors.New("firmware.dir must not be empty"))
+	}
+	errs = append(errs, c.CORS.validate()...)
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func (l RateLimit) validate(name string) error {
+	if l.RequestsPerSecond < 0 {
+		return fmt.Errorf("%s.requests_per_second must not be negative", name)
+	}
+	if l.RequestsPerSecond > 0 && l.Burst < 1 {
+		return fmt.Errorf("%s.burst must be at least 1", name)
+	}
+	return nil
+}
+
+func (c CORSConfig) validate() []error {
+	var errs []error
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			if c.AllowCredentials {
+				errs = append(errs, errors.New("cors.allowed_origins must list the origins when cors.allow_credentials is set, not *"))
+			}
+			continue
+		}
+		// An origin is a scheme and host with an optional port, nothing else
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Scheme+"://"+u.Host != origin {
+			errs = append(errs, fmt.Errorf("cors.allowed_origins %q must be * or an origin like https://dashboard.example.com", origin))
+		}
+	}
+	for _, method := range c.AllowedMethods {
+		if !slices.Contains(corsMethods, method) {
+			errs = append(errs, fmt.Errorf("cors.allowed_methods %q must be one of %s", method, strings.Join(corsMethods, ", ")))
+		}
+	}
+	for _, header := range append(slices.Clone(c.AllowedHeaders), c.ExposedHeaders...) {
+		if header == "" || strings.ContainsAny(header, " :,") {
+			errs = append(errs, fmt.Errorf("cors header %q is not a header name", header))
+		}
+	}
+	if c.MaxAge < 0 {
+		errs = append(errs, errors.New("cors.max_age must not be negative"))
+	}
+	return errs
+}
+
+func setValue(field any, value string) error {
+	switch field := field.(type) {
+	case *string:
+		*field = value
+	case *int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*field = n
+	case *int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		*field = n
+	case *float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		*field = f
+	case *bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*field = b
+	case *time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*field = d
+	case *[]string:
+		var values []string
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		*field = values
+	default:
+		return fmt.Errorf("unsupported setting type %T", field)
+	}
+	return nil
+}
+
+func deref(field any) any {
+	switch field := field.(type) {
+	case *string:
+		return *field
+	case *int:
+		return *field
+	case *int64:
+		return *field
+	case *float64:
+		return *field
+	case *bool:
+		return *field
+	case *time.Duration:
+		return *field
+	case *[]string:
+		return strings.Join(*field, ",")
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func env(values map[string]string) func(string) string {
+	return func(key string) string { return values[key] }
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Error writing config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := Load(nil, env(nil))
+	if err != nil {
+		t.Fatalf("Expected defaults to be valid, got %v", err)
+	}
+	if cfg.Server.Addr != ":8080" || cfg.Database.DSN != "production.db" || cfg.Server.RequestTimeout != 2*time.Second {
+		t.Errorf("Unexpected defaults: %+v", cfg)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, `
+server:
+  addr: ":9000"
+  request_timeout: 3s
+database:
+  dsn: file.db
+  max_open_conns: 8
+log:
+  level: debug
+`)
+
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		addr    string
+		dsn     string
+		timeout time.Duration
+	}{
+		{name: "File over defaults", args: []string{"-config", path}, addr: ":9000", dsn: "file.db", timeout: 3 * time.Second},
+		{name: "Env over file", args: []string{"-config", path}, env: map[string]string{"DB_PATH": "env.db", "REQUEST_TIMEOUT": "4s"}, addr: ":9000", dsn: "env.db", timeout: 4 * time.Second},
+		{name: "Config file from env", env: map[string]string{"CONFIG_FILE": path, "SERVER_PORT": "9100"}, addr: ":9100", dsn: "file.db", timeout: 3 * time.Second},
+		{name: "Host and port from env", args: []string{"-config", path}, env: map[string]string{"SERVER_HOST": "0.0.0.0"}, addr: "0.0.0.0:9000", dsn: "file.db", timeout: 3 * time.Second},
+		{name: "Flags over env", args: []string{"-config", path, "-db", "flag.db", "-addr", "127.0.0.1:9200"}, env: map[string]string{"DB_PATH": "env.db", "LISTEN_ADDR": ":9300"}, addr: "127.0.0.1:9200", dsn: "flag.db", timeout: 3 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load(tt.args, env(tt.env))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if cfg.Server.Addr != tt.addr || cfg.Database.DSN != tt.dsn || cfg.Server.RequestTimeout != tt.timeout {
+				t.Errorf("Expected %s %s %s, got %s %s %s", tt.addr, tt.dsn, tt.timeout, cfg.Server.Addr, cfg.Database.DSN, cfg.Server.RequestTimeout)
+			}
+			// Values set only in the file or only by default are kept
+			if cfg.Database.MaxOpenConns != 8 {
+				t.Errorf("Expected max_open_conns from the file, got %d", cfg.Database.MaxOpenConns)
+			}
+			if cfg.Server.UploadTimeout != 10*time.Second {
+				t.Errorf("Expected default upload timeout, got %s", cfg.Server.UploadTimeout)
+			}
+		})
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		file     string
+		contains string
+	}{
+		{name: "Unknown file key", file: "server:\n  adr: \":9000\"\n", contains: "field adr not found"},
+		{name: "Invalid duration", env: map[string]string{"REQUEST_TIMEOUT": "soon"}, contains: "REQUEST_TIMEOUT"},
+		{name: "Invalid flag value", args: []string{"-db-max-open-conns", "many"}, contains: "-db-max-open-conns"},
+		{name: "Unknown contract validation mode", env: map[string]string{"CONTRACT_VALIDATION": "strict"}, contains: "server.contract_validation"},
+		{name: "Malformed sunset date", env: map[string]string{"LEGACY_SUNSET": "30.06.2027"}, contains: "server.legacy_sunset"},
+		{name: "Unknown auth mode", env: map[string]string{"AUTH_MODE": "digest"}, contains: "auth.mode"},
+		{name: "Idle above open", args: []string{"-db-max-open-conns", "2", "-db-max-idle-conns", "3"}, contains: "max_idle_conns"},
+		{name: "Several errors", args: []string{"-request-timeout", "0s", "-log-level", "loud"}, contains: "log.level"},
+		{name: "Certificate without key", args: []string{"-tls-cert", "server.crt"}, contains: "key_file"},
+		{name: "Client auth without CA", args: []string{"-tls-cert", "server.crt", "-tls-key", "server.key", "-tls-client-auth", "require"}, contains: "client_ca_file"},
+		{name: "Negative lockout limit", env: map[string]string{"LOCKOUT_MAX_FAILURES": "-1"}, contains: "auth.lockout.max_failures"},
+		{name: "Short token secret", env: map[string]string{"JWT_SECRET": "secret"}, contains: "auth.tokens.secret"},
+		{name: "Zero export timeout", env: map[string]string{"EXPORT_TIMEOUT": "0s"}, contains: "server.export_timeout"},
+		{name: "Zero import timeout", env: map[string]string{"IMPORT_TIMEOUT": "0s"}, contains: "server.import_timeout"},
+		{name: "Zero import body limit", args: []string{"-import-max-body-bytes", "0"}, contains: "limits.import_max_body_bytes"},
+		{name: "Zero idempotency TTL", env: map[string]string{"IDEMPOTENCY_TTL": "0s"}, contains: "server.idempotency_ttl"},
+		{name: "Any origin with credentials", env: map[string]string{"CORS_ALLOW_CREDENTIALS": "true"}, contains: "cors.allowed_origins"},
+		{name: "Origin with path", env: map[string]string{"CORS_ALLOWED_ORIGINS": "https://dashboard.example.com/"}, contains: "cors.allowed_origins"},
+		{name: "Unknown CORS method", args: []string{"-cors-allowed-methods", "GET,TRACE"}, contains: "cors.allowed_methods"},
+		{name: "Invalid boolean", env: map[string]string{"CORS_ALLOW_CREDENTIALS": "sometimes"}, contains: "CORS_ALLOW_CREDENTIALS"},
+		{name: "Missing config file", args: []string{"-config", "/nonexistent/config.yaml"}, contains: "config file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeFile(t, tt.file)}, args...)
+			}
+			_, err := Load(args, env(tt.env))
+			if err == nil || !strings.Contains(err.Error(), tt.contains) {
+				t.Errorf("Expected error containing %q, got %v", tt.contains, err)
+			}
+		})
+	}
+}
+
+func TestLoadRouteRateLimits(t *testing.T) {
+	path := writeFile(t, `
+limits:
+  requests_per_second: 5
+  burst: 10
+  routes:
+    "POST /device/status": {requests_per_second: 1, burst: 2}
+`)
+
+	cfg, err := Load([]string{"-config", path, "-rate-limit-burst", "15"}, env(nil))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cfg.Limits.RequestsPerSecond != 5 || cfg.Limits.Burst != 15 {
+		t.Errorf("Expected 5 requests per second with a burst of 15, got %+v", cfg.Limits.RateLimit)
+	}
+	if cfg.Limits.Routes["POST /device/status"] != (RateLimit{RequestsPerSecond: 1, Burst: 2}) {
+		t.Errorf("Expected the route limit from the file, got %+v", cfg.Limits.Routes)
+	}
+	if _, ok := cfg.Limits.Routes["POST /provisioning/claim"]; !ok {
+		t.Errorf("Expected the default claim route limit to be kept, got %+v", cfg.Limits.Routes)
+	}
+}
+
+func TestLoadCORS(t *testing.T) {
+	path := writeFile(t, `
+cors:
+  allowed_origins: ["https://dashboard.example.com"]
+  allow_credentials: true
+  max_age: 1h
+`)
+
+	cfg, err := Load([]string{"-config", path, "-cors-allowed-headers", "Authorization, Content-Type,"}, env(map[string]string{"CORS_ALLOWED_ORIGINS": "https://dashboard.example.com,http://localhost:5173"}))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !slices.Equal(cfg.CORS.AllowedOrigins, []string{"https://dashboard.example.com", "http://localhost:5173"}) {
+		t.Errorf("Expected the origins from the environment, got %q", cfg.CORS.AllowedOrigins)
+	}
+	if !slices.Equal(cfg.CORS.AllowedHeaders, []string{"Authorization", "Content-Type"}) {
+		t.Errorf("Expected the trimmed headers from the flag, got %q", cfg.CORS.AllowedHeaders)
+	}
+	if !cfg.CORS.AllowCredentials || cfg.CORS.MaxAge != time.Hour || len(cfg.CORS.AllowedMethods) != 5 {
+		t.Errorf("Expected the file and default values to be kept, got %+v", cfg.CORS)
+	}
+}
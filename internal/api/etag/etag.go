@@ -0,0 +1,73 @@
+package etag
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrPrecondition is returned for If-Match values that can never match a version, e.g. weak or malformed tags
+var ErrPrecondition = errors.New("precondition failed")
+
+// ErrPreconditionRequired is returned by RequireMatch when the If-Match header is absent
+var ErrPreconditionRequired = errors.New("precondition required")
+
+// Format returns the strong entity tag of a resource version, e.g. "3"
+func Format(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// IfMatch returns the version required by an If-Match header.
+// An absent header or * requires no version and returns 0, so the write is unconditional.
+// The header must hold a single strong tag, a list cannot be turned into one expected version.
+func IfMatch(header string) (int, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+	version, ok := parse(header)
+	if !ok || version < 1 {
+		return 0, ErrPrecondition
+	}
+	return version, nil
+}
+
+// RequireMatch is IfMatch for writes that must be conditional, an absent header is an error.
+// * must still be sent explicitly to write whatever version is stored.
+func RequireMatch(header string) (int, error) {
+	if strings.TrimSpace(header) == "" {
+		return 0, ErrPreconditionRequired
+	}
+	return IfMatch(header)
+}
+
+// NoneMatch reports whether an If-None-Match header matches the version, in which case a GET returns 304.
+// Tags are compared weakly as RFC 9110 requires for If-None-Match.
+func NoneMatch(header string, version int) bool {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return false
+	}
+	if header == "*" {
+		return true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if v, ok := parse(tag); ok && v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// parse reads a quoted strong tag holding a version number
+func parse(tag string) (int, bool) {
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false
+	}
+	version, err := strconv.Atoi(tag[1 : len(tag)-1])
+	if err != nil {
+		return 0, false
+	}
+	return version, true
+}
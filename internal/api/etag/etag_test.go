@@ -0,0 +1,81 @@
+package etag
+
+import "testing"
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		version int
+		err     bool
+	}{
+		{name: "Absent", header: "", version: 0},
+		{name: "Any", header: "*", version: 0},
+		{name: "Strong tag", header: `"3"`, version: 3},
+		{name: "Weak tag", header: `W/"3"`, err: true},
+		{name: "List", header: `"3", "4"`, err: true},
+		{name: "Unquoted", header: "3", err: true},
+		{name: "Not a version", header: `"abc"`, err: true},
+		{name: "Zero", header: `"0"`, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, err := IfMatch(tt.header)
+			if (err != nil) != tt.err {
+				t.Fatalf("Expected error %v, got %v", tt.err, err)
+			}
+			if version != tt.version {
+				t.Errorf("Expected version %d, got %d", tt.version, version)
+			}
+		})
+	}
+}
+
+func TestRequireMatch(t *testing.T) {
+	if _, err := RequireMatch(" "); err != ErrPreconditionRequired {
+		t.Errorf("Expected an absent header to be required, got %v", err)
+	}
+	if version, err := RequireMatch("*"); err != nil || version != 0 {
+		t.Errorf("Expected * to require no version, got %d, %v", version, err)
+	}
+	if version, err := RequireMatch(`"3"`); err != nil || version != 3 {
+		t.Errorf("Expected version 3, got %d, %v", version, err)
+	}
+	if _, err := RequireMatch(`W/"3"`); err != ErrPrecondition {
+		t.Errorf("Expected a weak tag to fail, got %v", err)
+	}
+}
+
+func TestNoneMatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		match  bool
+	}{
+		{name: "Absent", header: "", match: false},
+		{name: "Any", header: "*", match: true},
+		{name: "Same version", header: `"2"`, match: true},
+		{name: "Other version", header: `"1"`, match: false},
+		{name: "Weak tag", header: `W/"2"`, match: true},
+		{name: "List", header: `"1", "2"`, match: true},
+		{name: "Malformed", header: `2`, match: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if match := NoneMatch(tt.header, 2); match != tt.match {
+				t.Errorf("Expected %v, got %v", tt.match, match)
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	if tag := Format(7); tag != `"7"` {
+		t.Errorf("Expected \"7\", got %s", tag)
+	}
+	if version, err := IfMatch(Format(7)); err != nil || version != 7 {
+		t.Errorf("Expected a formatted tag to parse back to 7, got %d %v", version, err)
+	}
+}
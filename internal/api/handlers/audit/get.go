@@ -0,0 +1,52 @@
+package audit
+
+import (
+	"encoding/json"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	"goapi/internal/api/service/audit"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// GetHandler handles GET requests to list audit entries, newest first
+// Supports filters and pagination: GET /audit?actor=admin&action=update&resource_type=device_config&resource_id=1&since=2024-01-15T00:00:00Z&until=2024-01-16T00:00:00Z&page=1&rows_per_page=10
+// curl -X GET "http://127.0.0.1:8080/v1/audit?resource_type=device_config&resource_id=1" -u admin:password
+func GetHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, service audit.AuditService) {
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	rowsPerPage, _ := strconv.Atoi(query.Get("rows_per_page"))
+	filter := &models.AuditFilter{
+		Actor:        query.Get("actor"),
+		Action:       query.Get("action"),
+		ResourceType: query.Get("resource_type"),
+		ResourceID:   query.Get("resource_id"),
+		Since:        query.Get("since"),
+		Until:        query.Get("until"),
+	}
+
+	ctx := r.Context()
+
+	entries, err := service.ReadMany(filter, page, rowsPerPage, ctx)
+	if err != nil {
+		switch err.(type) {
+		case audit.AuditError:
+			// Client error: invalid filter
+			problem.Write(w, r, http.StatusBadRequest, err.Error())
+			return
+		default:
+			// Server error
+			logger.ErrorContext(r.Context(), "Error reading audit entries", "error", err)
+			problem.InternalError(w, r)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding audit entries", "error", err)
+		problem.InternalError(w, r)
+		return
+	}
+}
@@ -0,0 +1,93 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"goapi/internal/api/repository/models"
+	"goapi/internal/api/service/audit"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Mock service for GET testing
+type mockAuditService struct {
+	readManyFunc func(*models.AuditFilter, int, int, context.Context) ([]*models.AuditEntry, error)
+}
+
+func (m *mockAuditService) Record(action string, resourceType string, resourceID string, before any, after any, ctx context.Context) error {
+	return nil
+}
+
+func (m *mockAuditService) ReadMany(filter *models.AuditFilter, page int, rowsPerPage int, ctx context.Context) ([]*models.AuditEntry, error) {
+	if m.readManyFunc != nil {
+		return m.readManyFunc(filter, page, rowsPerPage, ctx)
+	}
+	return nil, nil
+}
+
+func TestGetHandlerFilters(t *testing.T) {
+	logger := slog.Default()
+
+	mockService := &mockAuditService{
+		readManyFunc: func(filter *models.AuditFilter, page int, rowsPerPage int, ctx context.Context) ([]*models.AuditEntry, error) {
+			expected := models.AuditFilter{Actor: "admin", Action: "update", ResourceType: "device_config", ResourceID: "1", Since: "2024-01-15T00:00:00Z"}
+			if *filter != expected || page != 2 || rowsPerPage != 5 {
+				t.Errorf("Unexpected filter %+v, page=%d, rowsPerPage=%d", filter, page, rowsPerPage)
+			}
+			return []*models.AuditEntry{{ID: 1, Actor: "admin", Action: "update", ResourceType: "device_config", ResourceID: "1",
+				Before: json.RawMessage(`{"sensitivity_level":5}`), After: json.RawMessage(`{"sensitivity_level":9}`), Changes: json.RawMessage(`{}`)}}, nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/audit?actor=admin&action=update&resource_type=device_config&resource_id=1&since=2024-01-15T00:00:00Z&page=2&rows_per_page=5", nil)
+	w := httptest.NewRecorder()
+
+	GetHandler(w, req, logger, mockService)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+
+	var response []*models.AuditEntry
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response) != 1 || string(response[0].After) != `{"sensitivity_level":9}` {
+		t.Errorf("Unexpected response %+v", response)
+	}
+}
+
+func TestGetHandlerErrors(t *testing.T) {
+	logger := slog.Default()
+
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+	}{
+		{name: "Invalid filter", err: audit.AuditError{Message: "action must be create, update or delete."}, expectedStatus: http.StatusBadRequest},
+		{name: "Internal error", err: errors.New("database error"), expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &mockAuditService{
+				readManyFunc: func(filter *models.AuditFilter, page int, rowsPerPage int, ctx context.Context) ([]*models.AuditEntry, error) {
+					return nil, tt.err
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/audit?action=read", nil)
+			w := httptest.NewRecorder()
+
+			GetHandler(w, req, logger, mockService)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"encoding/json"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/service/auth"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// LoginHandler handles POST requests exchanging credentials for an access token and a refresh token.
+// The route is public: the credentials in the body authenticate the request, failures count towards lockouts.
+// curl -X POST http://127.0.0.1:8080/v1/auth/login -H "Content-Type: application/json" -d '{"username":"admin","password":"password"}'
+func LoginHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, service auth.AuthService) {
+	var request auth.LoginRequest
+
+	// Decode the JSON payload from the request body
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Username == "" {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request data. Please check your input.")
+		return
+	}
+
+	ctx := r.Context()
+
+	pair, err := service.Login(request.Username, request.Password, middleware.ClientIP(r), ctx)
+	if err != nil {
+		writeError(w, r, logger, err, "Error logging in")
+		return
+	}
+	writeTokens(w, r, logger, pair)
+}
+
+// writeError answers rejected credentials or tokens with 401, locked out logins with 429 and anything else with 500
+func writeError(w http.ResponseWriter, r *http.Request, logger *slog.Logger, err error, message string) {
+	switch e := err.(type) {
+	case auth.AuthError:
+		if e.RetryAfter > 0 {
+			seconds := strconv.Itoa(int(math.Ceil(e.RetryAfter.Seconds())))
+			w.Header().Set("Retry-After", seconds)
+			problem.Write(w, r, http.StatusTooManyRequests, "Too many failed logins, retry after "+seconds+" seconds.")
+			return
+		}
+		problem.Write(w, r, http.StatusUnauthorized, "Unauthorized: "+e.Message)
+	default:
+		logger.ErrorContext(r.Context(), message, "error", err)
+		problem.InternalError(w, r)
+	}
+}
+
+// writeTokens returns a new token pair, which must not be cached on the way
+func writeTokens(w http.ResponseWriter, r *http.Request, logger *slog.Logger, pair *auth.TokenPair) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(pair); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding tokens", "error", err)
+		problem.InternalError(w, r)
+		return
+	}
+}
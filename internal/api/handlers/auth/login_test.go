@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"goapi/internal/api/service/auth"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Mock service shared by the auth handler tests
+type mockAuthService struct {
+	loginFunc   func(string, string, string, context.Context) (*auth.TokenPair, error)
+	refreshFunc func(string, context.Context) (*auth.TokenPair, error)
+	logoutFunc  func(string, context.Context) error
+}
+
+func (m *mockAuthService) Login(username string, password string, ip string, ctx context.Context) (*auth.TokenPair, error) {
+	if m.loginFunc != nil {
+		return m.loginFunc(username, password, ip, ctx)
+	}
+	return nil, nil
+}
+
+func (m *mockAuthService) Refresh(refreshToken string, ctx context.Context) (*auth.TokenPair, error) {
+	if m.refreshFunc != nil {
+		return m.refreshFunc(refreshToken, ctx)
+	}
+	return nil, nil
+}
+
+func (m *mockAuthService) Logout(refreshToken string, ctx context.Context) error {
+	if m.logoutFunc != nil {
+		return m.logoutFunc(refreshToken, ctx)
+	}
+	return nil
+}
+
+func (m *mockAuthService) Verify(accessToken string, ctx context.Context) (*auth.Claims, error) {
+	return nil, nil
+}
+
+func (m *mockAuthService) PurgeExpiredTokens(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func TestLoginHandlerSuccess(t *testing.T) {
+	logger := slog.Default()
+	mockService := &mockAuthService{
+		loginFunc: func(username, password, ip string, ctx context.Context) (*auth.TokenPair, error) {
+			if username != "admin" || password != "password" || ip != "192.0.2.1" {
+				t.Errorf("Unexpected login of %s/%s from %s", username, password, ip)
+			}
+			return &auth.TokenPair{AccessToken: "access", TokenType: "Bearer", ExpiresIn: 900, RefreshToken: "refresh"}, nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", bytes.NewBufferString(`{"username":"admin","password":"password"}`))
+	req.RemoteAddr = "192.0.2.1:1234"
+	w := httptest.NewRecorder()
+
+	LoginHandler(w, req, logger, mockService)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+	if w.Header().Get("Cache-Control") != "no-store" {
+		t.Error("Tokens must not be cached")
+	}
+
+	var response auth.TokenPair
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.AccessToken != "access" || response.RefreshToken != "refresh" {
+		t.Errorf("Unexpected tokens %+v", response)
+	}
+}
+
+func TestLoginHandlerErrors(t *testing.T) {
+	logger := slog.Default()
+
+	tests := []struct {
+		name           string
+		body           string
+		err            error
+		expectedStatus int
+	}{
+		{name: "Invalid JSON", body: "not json", expectedStatus: http.StatusBadRequest},
+		{name: "Missing username", body: `{"password":"password"}`, expectedStatus: http.StatusBadRequest},
+		{name: "Invalid credentials", body: `{"username":"admin","password":"wrong"}`, err: auth.AuthError{Message: "Invalid credentials."}, expectedStatus: http.StatusUnauthorized},
+		{name: "Locked out", body: `{"username":"admin","password":"wrong"}`, err: auth.AuthError{Message: "Too many failed logins.", RetryAfter: time.Minute}, expectedStatus: http.StatusTooManyRequests},
+		{name: "Internal error", body: `{"username":"admin","password":"password"}`, err: errors.New("database error"), expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &mockAuthService{
+				loginFunc: func(username, password, ip string, ctx context.Context) (*auth.TokenPair, error) {
+					return nil, tt.err
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/auth/login", bytes.NewBufferString(tt.body))
+			w := httptest.NewRecorder()
+
+			LoginHandler(w, req, logger, mockService)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "60" {
+				t.Errorf("Expected Retry-After 60, got %q", w.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"encoding/json"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/service/auth"
+	"log/slog"
+	"net/http"
+)
+
+// LogoutHandler handles POST requests revoking a refresh token.
+// Access tokens cannot be revoked, they stay valid until they expire.
+// curl -X POST http://127.0.0.1:8080/v1/auth/logout -H "Content-Type: application/json" -d '{"refresh_token":"..."}'
+func LogoutHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, service auth.AuthService) {
+	var request auth.RefreshRequest
+
+	// Decode the JSON payload from the request body
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.RefreshToken == "" {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request data. Please check your input.")
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := service.Logout(request.RefreshToken, ctx); err != nil {
+		writeError(w, r, logger, err, "Error logging out")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"goapi/internal/api/service/auth"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLogoutHandler(t *testing.T) {
+	logger := slog.Default()
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Known token", body: `{"refresh_token":"valid"}`, expectedStatus: http.StatusNoContent},
+		{name: "Unknown token", body: `{"refresh_token":"unknown"}`, expectedStatus: http.StatusUnauthorized},
+		{name: "Invalid JSON", body: "not json", expectedStatus: http.StatusBadRequest},
+	}
+
+	mockService := &mockAuthService{
+		logoutFunc: func(refreshToken string, ctx context.Context) error {
+			if refreshToken != "valid" {
+				return auth.AuthError{Message: "Invalid refresh token."}
+			}
+			return nil
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/auth/logout", bytes.NewBufferString(tt.body))
+			w := httptest.NewRecorder()
+
+			LogoutHandler(w, req, logger, mockService)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"encoding/json"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/service/auth"
+	"log/slog"
+	"net/http"
+)
+
+// RefreshHandler handles POST requests exchanging a refresh token for a new token pair.
+// The refresh token is rotated: the one sent is revoked, reusing it later revokes every token of the account.
+// curl -X POST http://127.0.0.1:8080/v1/auth/refresh -H "Content-Type: application/json" -d '{"refresh_token":"..."}'
+func RefreshHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, service auth.AuthService) {
+	var request auth.RefreshRequest
+
+	// Decode the JSON payload from the request body
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.RefreshToken == "" {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request data. Please check your input.")
+		return
+	}
+
+	ctx := r.Context()
+
+	pair, err := service.Refresh(request.RefreshToken, ctx)
+	if err != nil {
+		writeError(w, r, logger, err, "Error refreshing tokens")
+		return
+	}
+	writeTokens(w, r, logger, pair)
+}
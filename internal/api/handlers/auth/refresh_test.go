@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"goapi/internal/api/service/auth"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRefreshHandler(t *testing.T) {
+	logger := slog.Default()
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Valid token", body: `{"refresh_token":"valid"}`, expectedStatus: http.StatusOK},
+		{name: "Revoked token", body: `{"refresh_token":"revoked"}`, expectedStatus: http.StatusUnauthorized},
+		{name: "Missing token", body: `{}`, expectedStatus: http.StatusBadRequest},
+	}
+
+	mockService := &mockAuthService{
+		refreshFunc: func(refreshToken string, ctx context.Context) (*auth.TokenPair, error) {
+			if refreshToken != "valid" {
+				return nil, auth.AuthError{Message: "Invalid or expired refresh token."}
+			}
+			return &auth.TokenPair{AccessToken: "access", TokenType: "Bearer", ExpiresIn: 900, RefreshToken: "rotated"}, nil
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/auth/refresh", bytes.NewBufferString(tt.body))
+			w := httptest.NewRecorder()
+
+			RefreshHandler(w, req, logger, mockService)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package data
+
+import (
+	"encoding/json"
+	"goapi/internal/api/batch"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log/slog"
+	"net/http"
+)
+
+// * User sends a POST request to /data/batch with many data at once, as a JSON array or NDJSON (one JSON object per line) *
+// * Every item gets a result: accepted, duplicate (same device_id and date_time) or rejected with the reason *
+// * curl -X POST http://127.0.0.1:8080/v1/data/batch -i -u admin:password -H "Content-Type: application/x-ndjson" --data-binary @data.ndjson
+func PostBatchHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, ds service.DataService) {
+	data, err := batch.Decode[models.Data](r.Body)
+	if err != nil {
+		// * This is a User Error: the decoding error names the malformed item
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request data: "+err.Error())
+		return
+	}
+
+	// * Devices may only report their own data, one item of another device rejects the whole batch
+	identity, _ := middleware.IdentityFromContext(r.Context())
+	for _, item := range data {
+		if !identity.CanAccessDevice(item.DeviceID) {
+			problem.Write(w, r, http.StatusForbidden, "Forbidden: Devices may only change their own data.")
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	// * Valid items are stored in a single transaction, invalid ones are reported in the results
+	results, err := ds.CreateBatch(data, ctx)
+	if err != nil {
+		switch err := err.(type) {
+		case service.DataError:
+			problem.Invalid(w, r, err.Message, err.Fields)
+			return
+		default:
+			// * Nothing was stored, the transaction was rolled back
+			logger.ErrorContext(r.Context(), "Error creating data batch", "error", err, "count", len(data))
+			problem.InternalError(w, r)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(batch.NewResponse(results)); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding batch results", "error", err)
+		problem.InternalError(w, r)
+		return
+	}
+}
@@ -0,0 +1,94 @@
+package data_test
+
+import (
+	"encoding/json"
+	"goapi/internal/api/batch"
+	"goapi/internal/api/handlers/data"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
+	service "goapi/internal/api/service/data"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPostBatchSuccessful(t *testing.T) {
+
+	body := `{"device_id": "device1", "value": 1.0, "date_time": "2021-01-01T00:00:00Z"}
+{"device_id": "device1", "value": 2.0, "date_time": "2021-01-01T00:01:00Z"}
+`
+	req, err := http.NewRequest("POST", "/data/batch", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	data.PostBatchHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var response batch.Response
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Accepted != 2 || len(response.Results) != 2 || response.Results[1].ID != 2 {
+		t.Errorf("handler returned unexpected results: got %+v", response)
+	}
+}
+
+func TestPostBatchErrors(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		body     string
+		ds       service.DataService
+		code     int
+		expected string
+	}{
+		{name: "Empty batch", body: `[]`, ds: &service.MockDataServiceSuccessful{}, code: http.StatusBadRequest, expected: "Invalid request data: the batch is empty"},
+		{name: "Malformed line", body: "{\"device_id\": \"device1\"}\n{\"device_id\": \"device1\"", ds: &service.MockDataServiceSuccessful{}, code: http.StatusBadRequest, expected: "Invalid request data: line 2: unexpected end of JSON input"},
+		{name: "Service error", body: `[{"device_id": "device1"}]`, ds: &service.MockDataServiceError{}, code: http.StatusBadRequest, expected: "Error creating data."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/data/batch", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			data.PostBatchHandler(rr, req, slog.Default(), tt.ds)
+
+			if status := rr.Code; status != tt.code {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tt.code)
+			}
+			if problem.Detail(rr.Body.Bytes()) != tt.expected {
+				t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestPostBatchOtherDevice(t *testing.T) {
+	// * One item of another device rejects the whole batch *
+	device := middleware.Identity{Username: "device2", DeviceID: "device2"}
+
+	body := `[{"device_id": "device2", "value": 1.0, "type": "type", "date_time": "2020-01-01T00:00:00Z"}, {"device_id": "device1", "value": 2.0, "type": "type", "date_time": "2020-01-01T00:00:00Z"}]`
+	req, err := http.NewRequest("POST", "/data/batch", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = req.WithContext(middleware.WithIdentity(req.Context(), device))
+
+	rr := httptest.NewRecorder()
+	data.PostBatchHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
+
+	if status := rr.Code; status != http.StatusForbidden {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusForbidden)
+	}
+}
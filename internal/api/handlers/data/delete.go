@@ -1,44 +1,76 @@
 package data
 
 import (
-	"context"
+	"errors"
+	"goapi/internal/api/etag"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
 	"goapi/internal/api/repository/models"
 	service "goapi/internal/api/service/data"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"time"
 )
 
 // * The DELETE method removes a resource identified by a URI *
-// * curl -X DELETE http://127.0.0.1:8080/data/1 -i -u admin:password -H "Content-Type: application/json"
-func DeleteHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, ds service.DataService) {
+// * curl -X DELETE http://127.0.0.1:8080/v1/data/1 -i -u admin:password -H 'If-Match: "1"'
+func DeleteHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, ds service.DataService) {
 	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
 		// * This is a User Error: format of id is invalid, response in JSON and with a 400 status code
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "Missconfigured ID."}`))
+		problem.Write(w, r, http.StatusBadRequest, "Missconfigured ID.")
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
-	defer cancel()
+	// * If-Match is required and * deletes whatever version is stored
+	version, err := etag.RequireMatch(r.Header.Get("If-Match"))
+	if errors.Is(err, etag.ErrPreconditionRequired) {
+		problem.Write(w, r, http.StatusPreconditionRequired, "Precondition required: If-Match must hold the ETag of the resource.")
+		return
+	}
+	if err != nil {
+		problem.Write(w, r, http.StatusPreconditionFailed, "Precondition failed: If-Match must be a single strong ETag.")
+		return
+	}
+
+	ctx := r.Context()
+
+	// * Devices may only delete their own data
+	current, err := ds.ReadOne(id, ctx)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Could not read one", "error", err, "id", id)
+		problem.InternalError(w, r)
+		return
+	}
+	if current == nil {
+		problem.Write(w, r, http.StatusNotFound, "Resource not found.")
+		return
+	}
+	if identity, _ := middleware.IdentityFromContext(r.Context()); !identity.CanAccessDevice(current.DeviceID) {
+		problem.Write(w, r, http.StatusForbidden, "Forbidden: Devices may only change their own data.")
+		return
+	}
 
-	aff, err := ds.Delete(&models.Data{ID: id}, ctx)
+	aff, err := ds.Delete(&models.Data{ID: id, Version: version}, ctx)
 	if err != nil {
-		logger.Println("Could not delete data:", err, id)
-		http.Error(w, "Internal Server error", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Could not delete data", "error", err, "id", id)
+		problem.InternalError(w, r)
 		return
 	}
 
 	// * Check if the data was found and deleted
 	if aff == 0 {
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte(`{"error": "Resource not found."}`))
+		if version != 0 {
+			if current, err := ds.ReadOne(id, ctx); err == nil && current != nil {
+				w.Header().Set("ETag", etag.Format(current.Version))
+				problem.Write(w, r, http.StatusPreconditionFailed, "Precondition failed: resource was modified.")
+				return
+			}
+		}
+		problem.Write(w, r, http.StatusNotFound, "Resource not found.")
 		return
 	}
 
 	// * This is a Success, response in JSON and with a 204 status code when data was successfully deleted
 	w.WriteHeader(http.StatusNoContent)
 }
- 
\ No newline at end of file
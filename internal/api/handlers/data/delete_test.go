@@ -2,11 +2,12 @@ package data_test
 
 import (
 	handlers "goapi/internal/api/handlers/data"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
 	service "goapi/internal/api/service/data"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
-	"strings"
 	"testing"
 )
 
@@ -19,13 +20,14 @@ func TestDeleteInvalidID(t *testing.T) {
 	}
 	req.SetPathValue("id", "invalid") // * Required for routing *
 
+	req.Header.Set("If-Match", `"1"`)
 	rr := httptest.NewRecorder()
 
-	handlers.DeleteHandler(rr, req, log.Default(), mockDataService)
+	handlers.DeleteHandler(rr, req, slog.Default(), mockDataService)
 	if status := rr.Code; status != http.StatusBadRequest {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
 	}
-	if rr.Body.String() != `{"error": "Missconfigured ID."}` {
+	if problem.Detail(rr.Body.Bytes()) != "Missconfigured ID." {
 		t.Errorf("handler returned unexpected body: got %v want empty body", rr.Body.String())
 	}
 }
@@ -39,14 +41,15 @@ func TestDeleteError(t *testing.T) {
 	}
 	req.SetPathValue("id", "1") // * Required for routing *
 
+	req.Header.Set("If-Match", `"1"`)
 	rr := httptest.NewRecorder()
 
-	handlers.DeleteHandler(rr, req, log.Default(), mockDataService)
+	handlers.DeleteHandler(rr, req, slog.Default(), mockDataService)
 	if status := rr.Code; status != http.StatusInternalServerError {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
 	}
-	if strings.TrimSpace(rr.Body.String()) != `Internal Server error` {
-		t.Errorf("handler returned unexpected body: got %v want 'Internal Server error'", rr.Body.String())
+	if problem.Detail(rr.Body.Bytes()) != "Internal server error." {
+		t.Errorf("handler returned unexpected body: got %v want 'Internal server error.'", rr.Body.String())
 	}
 }
 
@@ -58,13 +61,14 @@ func TestDeleteNotFound(t *testing.T) {
 	}
 	req.SetPathValue("id", "1") // * Required for routing *
 
+	req.Header.Set("If-Match", `"1"`)
 	rr := httptest.NewRecorder()
 
-	handlers.DeleteHandler(rr, req, log.Default(), mockDataService)
+	handlers.DeleteHandler(rr, req, slog.Default(), mockDataService)
 	if status := rr.Code; status != http.StatusNotFound {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
 	}
-	if rr.Body.String() != `{"error": "Resource not found."}` {
+	if problem.Detail(rr.Body.Bytes()) != "Resource not found." {
 		t.Errorf("handler returned unexpected body: got %v want empty body", rr.Body.String())
 	}
 }
@@ -77,9 +81,10 @@ func TestDeleteSuccessful(t *testing.T) {
 	}
 	req.SetPathValue("id", "1") // * Required for routing *
 
+	req.Header.Set("If-Match", `"1"`)
 	rr := httptest.NewRecorder()
 
-	handlers.DeleteHandler(rr, req, log.Default(), mockDataService)
+	handlers.DeleteHandler(rr, req, slog.Default(), mockDataService)
 	if status := rr.Code; status != http.StatusNoContent {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNoContent)
 	}
@@ -87,3 +92,58 @@ func TestDeleteSuccessful(t *testing.T) {
 		t.Errorf("handler returned unexpected body: got %v want empty body", rr.Body.String())
 	}
 }
+
+func TestDeletePreconditionFailed(t *testing.T) {
+
+	req, err := http.NewRequest("DELETE", "/data/1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("id", "1") // * Required for routing *
+	req.Header.Set("If-Match", `"2"`)
+
+	rr := httptest.NewRecorder()
+
+	handlers.DeleteHandler(rr, req, slog.Default(), &service.MockDataServiceConflict{})
+	if status := rr.Code; status != http.StatusPreconditionFailed {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusPreconditionFailed)
+	}
+	if etag := rr.Header().Get("ETag"); etag != `"1"` {
+		t.Errorf("handler returned wrong ETag: got %v want %v", etag, `"1"`)
+	}
+}
+
+func TestDeletePreconditionRequired(t *testing.T) {
+
+	req, err := http.NewRequest("DELETE", "/data/1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("id", "1") // * Required for routing *
+
+	rr := httptest.NewRecorder()
+
+	handlers.DeleteHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
+	if status := rr.Code; status != http.StatusPreconditionRequired {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusPreconditionRequired)
+	}
+}
+
+func TestDeleteOtherDevice(t *testing.T) {
+	device := middleware.Identity{Username: "device2", DeviceID: "device2"}
+
+	req, err := http.NewRequest("DELETE", "/data/1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("id", "1") // * Required for routing *
+	req.Header.Set("If-Match", `"1"`)
+	req = req.WithContext(middleware.WithIdentity(req.Context(), device))
+
+	rr := httptest.NewRecorder()
+	handlers.DeleteHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
+
+	if status := rr.Code; status != http.StatusForbidden {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusForbidden)
+	}
+}
@@ -1,18 +1,20 @@
 package data
 
 import (
-	"context"
-	"encoding/json"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
 	service "goapi/internal/api/service/data"
-	"log"
+	"goapi/internal/api/tabular"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"time"
 )
 
 // * The GET method retrieves all resources identified by a URI *
-// * curl -X GET http://127.0.0.1:8080/data -i -u admin:password -H "Content-Type: application/json"
-func GetHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, ds service.DataService) {
+// * Sends CSV or NDJSON instead of JSON for Accept: text/csv or application/x-ndjson *
+// * curl -X GET http://127.0.0.1:8080/v1/data -i -u admin:password -H "Content-Type: application/json"
+func GetHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, ds service.DataService) {
 	page, err := strconv.Atoi(r.URL.Query().Get("page"))
 	if err != nil {
 		if err == err.(*strconv.NumError) {
@@ -20,32 +22,38 @@ func GetHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, ds s
 			page = 0
 		} else {
 			// * Invalid page specified, return a 400 status code *
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte(`{"error": "Invalid page specified."}`))
+			problem.Write(w, r, http.StatusBadRequest, "Invalid page specified.")
 			return
 		}
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
-	defer cancel()
+	// * The list holds every device's data, devices read their own by ID instead
+	if identity, _ := middleware.IdentityFromContext(r.Context()); identity.DeviceID != "" {
+		problem.Write(w, r, http.StatusForbidden, "Forbidden: Devices may only read their own data.")
+		return
+	}
 
-	data, err := ds.ReadMany(page, 10, ctx)
-	if err != nil {
-		logger.Println("Could not get data:", err, data)
-		http.Error(w, "Internal Server error.", http.StatusInternalServerError)
+	ctx := r.Context()
+
+	// * Rows are written as they are read, the response starts with the first one *
+	format := tabular.Negotiate(r.Header.Get("Accept"), tabular.JSON)
+	res := tabular.NewResponse(w, format, models.Data{})
+	if err := tabular.Copy(res, ds.ReadMany(page, 10, ctx), nil); err != nil {
+		if res.Started() {
+			// * The status line is sent, a failure can only cut the body short *
+			logger.ErrorContext(r.Context(), "Error streaming data", "error", err)
+			return
+		}
+		logger.ErrorContext(r.Context(), "Could not get data", "error", err)
+		problem.InternalError(w, r)
 		return
 	}
-	if len(data) == 0 {
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte(`{"error": "Resource not found."}`))
+	if !res.Started() {
+		problem.Write(w, r, http.StatusNotFound, "Resource not found.")
 		return
 	}
 
-	// * Return the data to the user as JSON with a 200 OK status code
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(data); err != nil {
-		logger.Println("Error encoding data:", err, data)
-		http.Error(w, "Internal Server error.", http.StatusInternalServerError)
-		return
+	if err := res.Close(); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding data", "error", err)
 	}
 }
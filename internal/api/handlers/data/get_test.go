@@ -3,8 +3,11 @@ package data_test
 import (
 	"encoding/json"
 	"goapi/internal/api/handlers/data"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
 	service "goapi/internal/api/service/data"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -21,20 +24,49 @@ func TestGetHandlerSuccessful(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// * GetHanler should call the ReadMany method of the DataService *
-	data.GetHandler(rr, req, log.Default(), mockDataService)
+	data.GetHandler(rr, req, slog.Default(), mockDataService)
 	// * Response code should be 200 OK *
 	if status := rr.Code; status != http.StatusOK {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
 	}
 
 	// * We know what the MockDataService will return, so we can compare the response body to the expected value *
-	data, _ := mockDataService.ReadMany(0, 10, nil)
+	data, _ := models.Collect(mockDataService.ReadMany(0, 10, nil))
 	expected, _ := json.Marshal(data)
 	if strings.TrimSpace(rr.Body.String()) != string(expected) {
 		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), string(expected))
 	}
 }
 
+// * The list is sent as newline delimited JSON when the Accept header asks for it *
+func TestGetHandlerNDJSON(t *testing.T) {
+	mockDataService := &service.MockDataServiceSuccessful{}
+	req := httptest.NewRequest("GET", "/data", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	rr := httptest.NewRecorder()
+
+	data.GetHandler(rr, req, slog.Default(), mockDataService)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if contentType := rr.Header().Get("Content-Type"); contentType != "application/x-ndjson" {
+		t.Errorf("handler returned wrong Content-Type: got %v want application/x-ndjson", contentType)
+	}
+
+	// * Every item of the MockDataService is one line *
+	items, _ := models.Collect(mockDataService.ReadMany(0, 10, nil))
+	var expected strings.Builder
+	for _, item := range items {
+		line, _ := json.Marshal(item)
+		expected.Write(line)
+		expected.WriteString("\n")
+	}
+	if rr.Body.String() != expected.String() {
+		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected.String())
+	}
+}
+
 // * This ONLY test that the GetHandler returns the expected response code and body in case of an unsuccesfull (404) multiple resource retrieval without the use of a database and the page parameter *
 func TestGetHandlerNotFound(t *testing.T) {
 	mockDataService := &service.MockDataServiceNotFound{}
@@ -44,14 +76,14 @@ func TestGetHandlerNotFound(t *testing.T) {
 	}
 	rr := httptest.NewRecorder()
 	// * GetHanler should call the ReadMany method of the DataService *
-	data.GetHandler(rr, req, log.Default(), mockDataService)
+	data.GetHandler(rr, req, slog.Default(), mockDataService)
 	// * Response code should be 404 Not Found *
 	if status := rr.Code; status != http.StatusNotFound {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
 	}
 	// * We know what the MockDataService will return, so we can compare the response body to the expected value *
-	if strings.TrimSpace(rr.Body.String()) != `{"error": "Resource not found."}` {
-		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), `{"error": "Resource not found."}`)
+	if problem.Detail(rr.Body.Bytes()) != "Resource not found." {
+		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), "Resource not found.")
 	}
 }
 
@@ -67,13 +99,31 @@ func TestGetHandlerError(t *testing.T) {
 	}
 	rr := httptest.NewRecorder()
 
-	data.GetHandler(rr, req, log.Default(), mockDataService)
+	data.GetHandler(rr, req, slog.Default(), mockDataService)
 	// * Response code should be 500 Internal Server Error *
 	if status := rr.Code; status != http.StatusInternalServerError {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
 	}
 	// * We know what the MockDataService will return, so we can compare the response body to the expected value *
-	if strings.TrimSpace(rr.Body.String()) != `Internal Server error.` {
-		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), `Internal Server error.`)
+	if problem.Detail(rr.Body.Bytes()) != "Internal server error." {
+		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), `Internal server error.`)
+	}
+}
+
+func TestGetFromDevice(t *testing.T) {
+	// * The list holds the data of every device *
+	device := middleware.Identity{Username: "device2", DeviceID: "device2"}
+
+	req, err := http.NewRequest("GET", "/data", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = req.WithContext(middleware.WithIdentity(req.Context(), device))
+
+	rr := httptest.NewRecorder()
+	data.GetHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
+
+	if status := rr.Code; status != http.StatusForbidden {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusForbidden)
 	}
 }
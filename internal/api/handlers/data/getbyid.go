@@ -1,48 +1,59 @@
 package data
 
 import (
-	"context"
 	"encoding/json"
+	"goapi/internal/api/etag"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
 	service "goapi/internal/api/service/data"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"time"
 )
 
 // * The GET method retrieves a resource identified by a URI *
-// * curl -X GET http://127.0.0.1:8080/data/1 -i -u admin:password -H "Content-Type: application/json"
-func GetByIDHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, ds service.DataService) {
+// * curl -X GET http://127.0.0.1:8080/v1/data/1 -i -u admin:password -H "Content-Type: application/json"
+func GetByIDHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, ds service.DataService) {
 
 	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
 		// * This is a User Error: format of id is invalid, response in JSON and with a 400 status code
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "Missconfigured ID."}`))
+		problem.Write(w, r, http.StatusBadRequest, "Missconfigured ID.")
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
-	defer cancel()
+	ctx := r.Context()
 
 	data, err := ds.ReadOne(id, ctx)
 	if err != nil {
-		logger.Println("Could not read one:", err, id)
-		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Could not read one", "error", err, "id", id)
+		problem.InternalError(w, r)
 		return
 	}
 	if data == nil {
 		// * This is a User Error, response in JSON and with a 404 status code
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte(`{"error": "Resource not found."}`))
+		problem.Write(w, r, http.StatusNotFound, "Resource not found.")
+		return
+	}
+
+	// * Devices may only read their own data
+	if identity, _ := middleware.IdentityFromContext(r.Context()); !identity.CanAccessDevice(data.DeviceID) {
+		problem.Write(w, r, http.StatusForbidden, "Forbidden: Devices may only read their own data.")
+		return
+	}
+
+	// * The version is the ETag, a client holding the current version gets 304 Not Modified without a body
+	w.Header().Set("ETag", etag.Format(data.Version))
+	if etag.NoneMatch(r.Header.Get("If-None-Match"), data.Version) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 
 	if err := json.NewEncoder(w).Encode(data); err != nil {
-		logger.Println("Error encoding data:", err, data)
-		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Error encoding data", "error", err, "data", data)
+		problem.InternalError(w, r)
 		return
 	}
 }
@@ -3,8 +3,10 @@ package data_test
 import (
 	"encoding/json"
 	"goapi/internal/api/handlers/data"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
 	service "goapi/internal/api/service/data"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -20,13 +22,13 @@ func TestGetByIDInvalidID(t *testing.T) {
 	req.SetPathValue("id", "invalid") // * Required for routing *
 	rr := httptest.NewRecorder()
 
-	data.GetByIDHandler(rr, req, log.Default(), mockDataService)
+	data.GetByIDHandler(rr, req, slog.Default(), mockDataService)
 	if status := rr.Code; status != http.StatusBadRequest {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
 	}
 
-	if strings.TrimSpace(rr.Body.String()) != `{"error": "Missconfigured ID."}` {
-		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), `{"error": "Missconfigured ID."}`)
+	if problem.Detail(rr.Body.Bytes()) != "Missconfigured ID." {
+		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), "Missconfigured ID.")
 	}
 }
 
@@ -40,12 +42,12 @@ func TestGetByIdInternalError(t *testing.T) {
 
 	rr := httptest.NewRecorder()
 
-	data.GetByIDHandler(rr, req, log.Default(), mockDataService)
+	data.GetByIDHandler(rr, req, slog.Default(), mockDataService)
 	if status := rr.Code; status != http.StatusInternalServerError {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusInternalServerError)
 	}
 
-	if strings.TrimSpace(rr.Body.String()) != `Internal server error.` {
+	if problem.Detail(rr.Body.Bytes()) != "Internal server error." {
 		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), `Internal server error.`)
 	}
 }
@@ -60,13 +62,13 @@ func TestGetByIdNotFound(t *testing.T) {
 
 	rr := httptest.NewRecorder()
 
-	data.GetByIDHandler(rr, req, log.Default(), mockDataService)
+	data.GetByIDHandler(rr, req, slog.Default(), mockDataService)
 	if status := rr.Code; status != http.StatusNotFound {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
 	}
 
-	if strings.TrimSpace(rr.Body.String()) != `{"error": "Resource not found."}` {
-		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), `{"error": "Resource not found."}`)
+	if problem.Detail(rr.Body.Bytes()) != "Resource not found." {
+		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), "Resource not found.")
 	}
 }
 
@@ -80,7 +82,7 @@ func TestGetByIdSuccessful(t *testing.T) {
 
 	rr := httptest.NewRecorder()
 
-	data.GetByIDHandler(rr, req, log.Default(), mockDataService)
+	data.GetByIDHandler(rr, req, slog.Default(), mockDataService)
 	if status := rr.Code; status != http.StatusOK {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
 	}
@@ -92,3 +94,54 @@ func TestGetByIdSuccessful(t *testing.T) {
 		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), string(expected))
 	}
 }
+
+func TestGetByIDIfNoneMatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		code        int
+	}{
+		{name: "Current version", ifNoneMatch: `"1"`, code: http.StatusNotModified},
+		{name: "Any version", ifNoneMatch: "*", code: http.StatusNotModified},
+		{name: "Other version", ifNoneMatch: `"2"`, code: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/data/1", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.SetPathValue("id", "1")
+			req.Header.Set("If-None-Match", tt.ifNoneMatch)
+
+			rr := httptest.NewRecorder()
+			data.GetByIDHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
+
+			if status := rr.Code; status != tt.code {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tt.code)
+			}
+			if etag := rr.Header().Get("ETag"); etag != `"1"` {
+				t.Errorf("handler returned wrong ETag: got %v want %v", etag, `"1"`)
+			}
+		})
+	}
+}
+
+func TestGetByIdOtherDevice(t *testing.T) {
+	device := middleware.Identity{Username: "device2", DeviceID: "device2"}
+
+	req, err := http.NewRequest("GET", "/data/1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("id", "1") // * Required for routing *
+	req = req.WithContext(middleware.WithIdentity(req.Context(), device))
+
+	rr := httptest.NewRecorder()
+	data.GetByIDHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
+
+	if status := rr.Code; status != http.StatusForbidden {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,125 @@
+package data
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"goapi/internal/api/etag"
+	"goapi/internal/api/mergepatch"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// * PATCH applies a JSON merge patch (RFC 7396) to a resource: only the fields sent are changed, null clears a field *
+// * curl -X PATCH http://127.0.0.1:8080/v1/data/1 -i -u admin:password -H "Content-Type: application/merge-patch+json" -d '{"value": 0}'
+func PatchHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, ds service.DataService) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		// * This is a User Error: format of id is invalid, response in JSON and with a 400 status code
+		problem.Write(w, r, http.StatusBadRequest, "Missconfigured ID.")
+		return
+	}
+
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request data. Please check your input.")
+		return
+	}
+
+	version, err := etag.IfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		problem.Write(w, r, http.StatusPreconditionFailed, "Precondition failed: If-Match must be a single strong ETag.")
+		return
+	}
+
+	ctx := r.Context()
+
+	current, err := ds.ReadOne(id, ctx)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Could not read one", "error", err, "id", id)
+		problem.InternalError(w, r)
+		return
+	}
+	if current == nil {
+		problem.Write(w, r, http.StatusNotFound, "Resource not found.")
+		return
+	}
+
+	// * Devices may only update their own data
+	identity, _ := middleware.IdentityFromContext(r.Context())
+	if !identity.CanAccessDevice(current.DeviceID) {
+		problem.Write(w, r, http.StatusForbidden, "Forbidden: Devices may only change their own data.")
+		return
+	}
+	if version != 0 && version != current.Version {
+		w.Header().Set("ETag", etag.Format(current.Version))
+		problem.Write(w, r, http.StatusPreconditionFailed, "Precondition failed: resource was modified.")
+		return
+	}
+
+	// * Merge the patch into the stored representation, unknown fields are a User Error like a typo in PUT would be
+	document, err := json.Marshal(current)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding data", "error", err, "data", current)
+		problem.InternalError(w, r)
+		return
+	}
+	merged, err := mergepatch.Apply(document, patch)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request data. The body must be a JSON merge patch object.")
+		return
+	}
+	var data models.Data
+	decoder := json.NewDecoder(bytes.NewReader(merged))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&data); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request data. Please check your input.")
+		return
+	}
+
+	// * The patch may not move the data to another device
+	if !identity.CanAccessDevice(data.DeviceID) {
+		problem.Write(w, r, http.StatusForbidden, "Forbidden: Devices may only change their own data.")
+		return
+	}
+
+	// * The ID comes from the path, and the update only applies to the version the patch was merged into
+	data.ID = id
+	data.Version = current.Version
+
+	// * Update validates the merged data like a PUT
+	if aff, err := ds.Update(&data, ctx); err != nil {
+		switch err := err.(type) {
+		case service.DataError:
+			problem.Invalid(w, r, err.Message, err.Fields)
+			return
+		default:
+			logger.ErrorContext(r.Context(), "Error patching data", "error", err, "data", data)
+			// * Another row already holds the device and time, the unique index rejects it
+			if errors.As(err, new(*models.DuplicateError)) {
+				problem.Write(w, r, http.StatusConflict, "Data for this device_id and date_time already exists.")
+				return
+			}
+			problem.InternalError(w, r)
+			return
+		}
+	} else if aff == 0 {
+		// * Modified or deleted between reading and writing
+		problem.Write(w, r, http.StatusPreconditionFailed, "Precondition failed: resource was modified.")
+		return
+	}
+
+	w.Header().Set("ETag", etag.Format(data.Version))
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding data", "error", err, "data", data)
+		problem.InternalError(w, r)
+		return
+	}
+}
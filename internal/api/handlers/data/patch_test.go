@@ -0,0 +1,114 @@
+package data_test
+
+import (
+	"encoding/json"
+	"goapi/internal/api/handlers/data"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/data"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPatchSuccessful(t *testing.T) {
+
+	req, err := http.NewRequest("PATCH", "/data/1", strings.NewReader(`{"value": 0, "description": null}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("id", "1") // * Required for routing *
+
+	rr := httptest.NewRecorder()
+	data.PatchHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	// * Patched fields change, zero values included, the others keep their stored value
+	var patched models.Data
+	if err := json.NewDecoder(rr.Body).Decode(&patched); err != nil {
+		t.Fatal(err)
+	}
+	if patched.Value != 0 || patched.Description != "" || patched.DeviceID != "device1" || patched.DeviceName != "device1" {
+		t.Errorf("handler returned unexpected data: got %+v", patched)
+	}
+}
+
+func TestPatchErrors(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		id       string
+		body     string
+		ifMatch  string
+		ds       service.DataService
+		code     int
+		expected string
+	}{
+		{name: "Invalid ID", id: "invalid", body: `{}`, ds: &service.MockDataServiceSuccessful{}, code: http.StatusBadRequest, expected: "Missconfigured ID."},
+		{name: "Not an object", id: "1", body: `[{"value": 1}]`, ds: &service.MockDataServiceSuccessful{}, code: http.StatusBadRequest, expected: "Invalid request data. The body must be a JSON merge patch object."},
+		{name: "Unknown field", id: "1", body: `{"valeu": 1}`, ds: &service.MockDataServiceSuccessful{}, code: http.StatusBadRequest, expected: "Invalid request data. Please check your input."},
+		{name: "Wrong type", id: "1", body: `{"value": "high"}`, ds: &service.MockDataServiceSuccessful{}, code: http.StatusBadRequest, expected: "Invalid request data. Please check your input."},
+		{name: "Not found", id: "1", body: `{"value": 1}`, ds: &service.MockDataServiceNotFound{}, code: http.StatusNotFound, expected: "Resource not found."},
+		{name: "Stale version", id: "1", body: `{"value": 1}`, ifMatch: `"2"`, ds: &service.MockDataServiceSuccessful{}, code: http.StatusPreconditionFailed, expected: "Precondition failed: resource was modified."},
+		{name: "Modified while patching", id: "1", body: `{"value": 1}`, ds: &service.MockDataServiceConflict{}, code: http.StatusPreconditionFailed, expected: "Precondition failed: resource was modified."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("PATCH", "/data/"+tt.id, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.SetPathValue("id", tt.id) // * Required for routing *
+			req.Header.Set("If-Match", tt.ifMatch)
+
+			rr := httptest.NewRecorder()
+			data.PatchHandler(rr, req, slog.Default(), tt.ds)
+
+			if status := rr.Code; status != tt.code {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tt.code)
+			}
+			if problem.Detail(rr.Body.Bytes()) != tt.expected {
+				t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestPatchOtherDevice(t *testing.T) {
+	// * device1 owns the stored data *
+	tests := []struct {
+		name     string
+		identity middleware.Identity
+		body     string
+		code     int
+	}{
+		{name: "Data of another device", identity: middleware.Identity{Username: "device2", DeviceID: "device2"}, body: `{"value": 0}`, code: http.StatusForbidden},
+		{name: "Moving data to another device", identity: middleware.Identity{Username: "device1", DeviceID: "device1"}, body: `{"device_id": "device2"}`, code: http.StatusForbidden},
+		{name: "Own data", identity: middleware.Identity{Username: "device1", DeviceID: "device1"}, body: `{"value": 0}`, code: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("PATCH", "/data/1", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.SetPathValue("id", "1") // * Required for routing *
+			req = req.WithContext(middleware.WithIdentity(req.Context(), tt.identity))
+
+			rr := httptest.NewRecorder()
+			data.PatchHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
+
+			if status := rr.Code; status != tt.code {
+				t.Errorf("handler returned wrong status code: got %v want %v: %s", status, tt.code, rr.Body.String())
+			}
+		})
+	}
+}
@@ -1,44 +1,53 @@
 package data
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
 	"goapi/internal/api/repository/models"
 	service "goapi/internal/api/service/data"
-	"log"
+	"log/slog"
 	"net/http"
-	"time"
 )
 
 // * User sends a POST request to /data with a JSON payload in the request body *
-// * curl -X POST http://127.0.0.1:8080/data -i -u admin:password -H "Content-Type: application/json" -d '{"device_id": "device1", "device_name": "device1", "value": 1.0, "type": "type1", "date_time": "2021-01-01T00:00:00Z", "description": "description1"}'
-func PostHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, ds service.DataService) {
+// * curl -X POST http://127.0.0.1:8080/v1/data -i -u admin:password -H "Content-Type: application/json" -d '{"device_id": "device1", "device_name": "device1", "value": 1.0, "type": "type1", "date_time": "2021-01-01T00:00:00Z", "description": "description1"}'
+func PostHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, ds service.DataService) {
 	var data models.Data
 
 	// * Decode the JSON payload from the request body into the data struct
 	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
 
 		// * This is a User Error: format of body is invalid, response in JSON and with a 400 status code
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "Invalid request data. Please check your input."}`))
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request data. Please check your input.")
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
-	defer cancel()
+	// * Devices may only create their own data, this is a User Error with a 403 status code
+	if identity, _ := middleware.IdentityFromContext(r.Context()); !identity.CanAccessDevice(data.DeviceID) {
+		problem.Write(w, r, http.StatusForbidden, "Forbidden: Devices may only change their own data.")
+		return
+	}
+
+	ctx := r.Context()
 
 	// * Try to create the data in the database
 	if err := ds.Create(&data, ctx); err != nil {
-		switch err.(type) {
+		switch err := err.(type) {
 		case service.DataError:
 			// * If the error is a DataError, handle it as a client error
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte(`{"error": "` + err.Error() + `"}`))
+			problem.Invalid(w, r, err.Message, err.Fields)
 			return
 		default:
 			// * If it is not a DataError, handle it as a server error
-			logger.Println("Error creating data:", err, data)
-			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Error creating data", "error", err, "data", data)
+			// * Another row already holds the device and time, the unique index rejects it
+			if errors.As(err, new(*models.DuplicateError)) {
+				problem.Write(w, r, http.StatusConflict, "Data for this device_id and date_time already exists.")
+				return
+			}
+			problem.InternalError(w, r)
 			return
 		}
 	}
@@ -46,8 +55,8 @@ func PostHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, ds
 	// * Return the data to the user as JSON with a 201 Created status code
 	w.WriteHeader(http.StatusCreated)
 	if err := json.NewEncoder(w).Encode(data); err != nil {
-		logger.Println("Error encoding data:", err, data)
-		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Error encoding data", "error", err, "data", data)
+		problem.InternalError(w, r)
 		return
 	}
 }
@@ -3,10 +3,12 @@ package data_test
 import (
 	"encoding/json"
 	"goapi/internal/api/handlers/data"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
 	"goapi/internal/api/repository/models"
 	service "goapi/internal/api/service/data"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -22,14 +24,14 @@ func TestPostInvalidRequestBody(t *testing.T) {
 
 	req.Body = io.NopCloser(strings.NewReader(`Plain text, not JSON`))
 	rr := httptest.NewRecorder()
-	data.PostHandler(rr, req, log.Default(), &service.MockDataServiceSuccessful{})
+	data.PostHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
 
 	if status := rr.Code; status != http.StatusBadRequest {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
 	}
 
-	expected := `{"error": "Invalid request data. Please check your input."}`
-	if strings.TrimSpace(rr.Body.String()) != expected {
+	expected := "Invalid request data. Please check your input."
+	if problem.Detail(rr.Body.Bytes()) != expected {
 		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
 	}
 }
@@ -54,14 +56,14 @@ func TestPostErrorCreatingData(t *testing.T) {
 	req.Body = io.NopCloser(strings.NewReader(string(dataJSON)))
 	rr := httptest.NewRecorder()
 
-	data.PostHandler(rr, req, log.Default(), &service.MockDataServiceError{})
+	data.PostHandler(rr, req, slog.Default(), &service.MockDataServiceError{})
 
 	if status := rr.Code; status != http.StatusBadRequest {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
 	}
 
-	expected := `{"error": "Error creating data."}` // * This message is passed from the MockDataServiceError
-	if strings.TrimSpace(rr.Body.String()) != expected {
+	expected := "Error creating data." // * This message is passed from the MockDataServiceError
+	if problem.Detail(rr.Body.Bytes()) != expected {
 		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
 	}
 }
@@ -89,7 +91,7 @@ func TestPostSuccessful(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// * Call the handler
-	data.PostHandler(rr, req, log.Default(), &service.MockDataServiceSuccessful{})
+	data.PostHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
 
 	if status := rr.Code; status != http.StatusCreated {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusCreated)
@@ -101,3 +103,20 @@ func TestPostSuccessful(t *testing.T) {
 		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
 	}
 }
+
+func TestPostOtherDevice(t *testing.T) {
+	device := middleware.Identity{Username: "device2", DeviceID: "device2"}
+
+	req, err := http.NewRequest("POST", "/data", strings.NewReader(`{"device_id": "device1", "device_name": "device_name", "value": 1.0, "type": "type", "date_time": "2020-01-01T00:00:00Z", "description": "description"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = req.WithContext(middleware.WithIdentity(req.Context(), device))
+
+	rr := httptest.NewRecorder()
+	data.PostHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
+
+	if status := rr.Code; status != http.StatusForbidden {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusForbidden)
+	}
+}
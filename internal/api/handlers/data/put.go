@@ -1,57 +1,104 @@
 package data
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
+	"goapi/internal/api/etag"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
 	"goapi/internal/api/repository/models"
 	service "goapi/internal/api/service/data"
-	"log"
+	"log/slog"
 	"net/http"
-	"time"
 )
 
 // * When using PUT, the client sends a complete representation of a resource to replace the current version: Whole Resource Replacement. *
-// * curl -X PUT http://127.0.0.1:8080/data -i -u admin:password -H "Content-Type: application/json" -d '{"id": 1, "content": "updated data"}'
-func PutHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, ds service.DataService) {
+// * curl -X PUT http://127.0.0.1:8080/v1/data -i -u admin:password -H "Content-Type: application/json" -H 'If-Match: "1"' -d '{"id": 1, "content": "updated data"}'
+func PutHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, ds service.DataService) {
 	var data models.Data
 
 	// * Decode the JSON payload from the request body into the data struct
 	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
 		// * This is a User Error: format of body is invalid, response in JSON and with a 400 status code
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "Invalid request data. Please check your input."}`))
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request data. Please check your input.")
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
-	defer cancel()
+	// * Devices may only update their own data
+	identity, _ := middleware.IdentityFromContext(r.Context())
+	if !identity.CanAccessDevice(data.DeviceID) {
+		problem.Write(w, r, http.StatusForbidden, "Forbidden: Devices may only change their own data.")
+		return
+	}
+
+	// * If-Match is required, the version in the body is ignored and * writes whatever version is stored
+	version, err := etag.RequireMatch(r.Header.Get("If-Match"))
+	if errors.Is(err, etag.ErrPreconditionRequired) {
+		problem.Write(w, r, http.StatusPreconditionRequired, "Precondition required: If-Match must hold the ETag of the resource.")
+		return
+	}
+	if err != nil {
+		problem.Write(w, r, http.StatusPreconditionFailed, "Precondition failed: If-Match must be a single strong ETag.")
+		return
+	}
+	data.Version = version
+
+	ctx := r.Context()
+
+	// * The stored data must belong to the device too, or it could overwrite another device's data by ID
+	current, err := ds.ReadOne(data.ID, ctx)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Could not read one", "error", err, "id", data.ID)
+		problem.InternalError(w, r)
+		return
+	}
+	if current == nil {
+		problem.Write(w, r, http.StatusNotFound, "Resource not found.")
+		return
+	}
+	if !identity.CanAccessDevice(current.DeviceID) {
+		problem.Write(w, r, http.StatusForbidden, "Forbidden: Devices may only change their own data.")
+		return
+	}
 
 	// * Try to update the data in the database
 	if aff, err := ds.Update(&data, ctx); err != nil {
-		switch err.(type) {
+		switch err := err.(type) {
 		case service.DataError:
 			// * If the error is a DataError, handle it as a client error
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte(`{"error": "` + err.Error() + `"}`))
+			problem.Invalid(w, r, err.Message, err.Fields)
 			return
 		default:
 			// * If it is not a DataError, handle it as a server error
-			logger.Println("Error creating data:", err, data)
-			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Error creating data", "error", err, "data", data)
+			// * Another row already holds the device and time, the unique index rejects it
+			if errors.As(err, new(*models.DuplicateError)) {
+				problem.Write(w, r, http.StatusConflict, "Data for this device_id and date_time already exists.")
+				return
+			}
+			problem.InternalError(w, r)
 			return
 		}
 	} else if aff == 0 {
+		// * A stale version is told apart from a missing resource by reading the current one
+		if data.Version != 0 {
+			if current, err := ds.ReadOne(data.ID, ctx); err == nil && current != nil {
+				w.Header().Set("ETag", etag.Format(current.Version))
+				problem.Write(w, r, http.StatusPreconditionFailed, "Precondition failed: resource was modified.")
+				return
+			}
+		}
 		// * This is a User Error, response in JSON and with a 404 status code
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte(`{"error": "Resource not found."}`))
+		problem.Write(w, r, http.StatusNotFound, "Resource not found.")
 		return
 	}
 
 	// * Return the data to the user as JSON with a 200 OK status code
+	w.Header().Set("ETag", etag.Format(data.Version))
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(data); err != nil {
-		logger.Println("Error encoding data:", err, data)
-		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Error encoding data", "error", err, "data", data)
+		problem.InternalError(w, r)
 		return
 	}
 }
@@ -2,9 +2,11 @@ package data_test
 
 import (
 	"goapi/internal/api/handlers/data"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
 	service "goapi/internal/api/service/data"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -19,15 +21,16 @@ func TestPutInvalidRequestBody(t *testing.T) {
 	}
 
 	req.Body = io.NopCloser(strings.NewReader(`Plain text, not JSON`))
+	req.Header.Set("If-Match", `"1"`)
 	rr := httptest.NewRecorder()
-	data.PutHandler(rr, req, log.Default(), &service.MockDataServiceSuccessful{})
+	data.PutHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
 
 	if status := rr.Code; status != http.StatusBadRequest {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
 	}
 
-	expected := `{"error": "Invalid request data. Please check your input."}`
-	if strings.TrimSpace(rr.Body.String()) != expected {
+	expected := "Invalid request data. Please check your input."
+	if problem.Detail(rr.Body.Bytes()) != expected {
 		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
 	}
 }
@@ -39,15 +42,16 @@ func TestPutHandlerError(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	req.Header.Set("If-Match", `"1"`)
 	rr := httptest.NewRecorder()
-	data.PutHandler(rr, req, log.Default(), &service.MockDataServiceError{})
+	data.PutHandler(rr, req, slog.Default(), &service.MockDataServiceUpdateError{})
 
 	if status := rr.Code; status != http.StatusBadRequest {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
 	}
 
-	expected := `{"error": "Error updating data."}`
-	if strings.TrimSpace(rr.Body.String()) != expected {
+	expected := "Error updating data."
+	if problem.Detail(rr.Body.Bytes()) != expected {
 		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
 	}
 }
@@ -59,15 +63,16 @@ func TestPutDataNotFound(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	req.Header.Set("If-Match", `"1"`)
 	rr := httptest.NewRecorder()
-	data.PutHandler(rr, req, log.Default(), &service.MockDataServiceNotFound{})
+	data.PutHandler(rr, req, slog.Default(), &service.MockDataServiceNotFound{})
 
 	if status := rr.Code; status != http.StatusNotFound {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
 	}
 
-	expected := `{"error": "Resource not found."}`
-	if strings.TrimSpace(rr.Body.String()) != expected {
+	expected := "Resource not found."
+	if problem.Detail(rr.Body.Bytes()) != expected {
 		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
 	}
 }
@@ -79,8 +84,9 @@ func TestPutHandlerSuccess(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	req.Header.Set("If-Match", "*")
 	rr := httptest.NewRecorder()
-	data.PutHandler(rr, req, log.Default(), &service.MockDataServiceSuccessful{})
+	data.PutHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
 
 	if status := rr.Code; status != http.StatusOK {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
@@ -91,3 +97,101 @@ func TestPutHandlerSuccess(t *testing.T) {
 		t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), expected)
 	}
 }
+
+func TestPutPreconditionFailed(t *testing.T) {
+	body := `{"id": 1, "device_id": "device_id", "device_name": "device_name", "value": 1.0, "type": "type", "date_time": "2020-01-01T00:00:00Z", "description": "description"}`
+
+	tests := []struct {
+		name     string
+		ifMatch  string
+		expected string
+	}{
+		{name: "Stale version", ifMatch: `"2"`, expected: "Precondition failed: resource was modified."},
+		{name: "Weak tag", ifMatch: `W/"1"`, expected: "Precondition failed: If-Match must be a single strong ETag."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("PUT", "/data", strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("If-Match", tt.ifMatch)
+
+			rr := httptest.NewRecorder()
+			data.PutHandler(rr, req, slog.Default(), &service.MockDataServiceConflict{})
+
+			if status := rr.Code; status != http.StatusPreconditionFailed {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusPreconditionFailed)
+			}
+			if problem.Detail(rr.Body.Bytes()) != tt.expected {
+				t.Errorf("handler returned unexpected body: got %v want %v", rr.Body.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestPutPreconditionRequired(t *testing.T) {
+	// * The version in the body never makes the update conditional, only If-Match does *
+	body := `{"id": 1, "device_id": "device_id", "device_name": "device_name", "value": 1.0, "type": "type", "date_time": "2020-01-01T00:00:00Z", "description": "description", "version": 2}`
+
+	req, err := http.NewRequest("PUT", "/data", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	data.PutHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
+
+	if status := rr.Code; status != http.StatusPreconditionRequired {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusPreconditionRequired)
+	}
+
+	// * * updates whatever version is stored, the version in the body is ignored *
+	req, err = http.NewRequest("PUT", "/data", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("If-Match", "*")
+
+	rr = httptest.NewRecorder()
+	data.PutHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if strings.Contains(rr.Body.String(), `"version"`) {
+		t.Errorf("handler updated the version from the body: got %v", rr.Body.String())
+	}
+}
+
+func TestPutHandlerOtherDevice(t *testing.T) {
+	device := middleware.Identity{Username: "device2", DeviceID: "device2"}
+
+	// * device1 owns the stored data, neither the body nor the stored row may belong to another device *
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "Data of another device", body: "device1"},
+		{name: "Taking over another device's data", body: "device2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("PUT", "/data", strings.NewReader(`{"id": 1, "device_id": "`+tt.body+`", "device_name": "device_name", "value": 1.0, "type": "type", "date_time": "2020-01-01T00:00:00Z", "description": "description"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("If-Match", `"1"`)
+			req = req.WithContext(middleware.WithIdentity(req.Context(), device))
+
+			rr := httptest.NewRecorder()
+			data.PutHandler(rr, req, slog.Default(), &service.MockDataServiceSuccessful{})
+
+			if status := rr.Code; status != http.StatusForbidden {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusForbidden)
+			}
+		})
+	}
+}
@@ -3,17 +3,19 @@ package device_config
 import (
 	"context"
 	"errors"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
 	"goapi/internal/api/repository/models"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"testing"
 )
 
 // Mock service for delete testing
 type mockDeviceConfigDeleteService struct {
-	deleteFunc func(*models.DeviceConfig, context.Context) (int64, error)
+	deleteFunc  func(*models.DeviceConfig, context.Context) (int64, error)
+	readOneFunc func(int, context.Context) (*models.DeviceConfig, error)
 }
 
 func (m *mockDeviceConfigDeleteService) Create(config *models.DeviceConfig, ctx context.Context) error {
@@ -21,15 +23,18 @@ func (m *mockDeviceConfigDeleteService) Create(config *models.DeviceConfig, ctx
 }
 
 func (m *mockDeviceConfigDeleteService) ReadOne(id int, ctx context.Context) (*models.DeviceConfig, error) {
-	return nil, nil
+	if m.readOneFunc != nil {
+		return m.readOneFunc(id, ctx)
+	}
+	return &models.DeviceConfig{ID: id, DeviceID: "ARD001", Version: 1}, nil
 }
 
 func (m *mockDeviceConfigDeleteService) ReadByDeviceID(deviceID string, ctx context.Context) (*models.DeviceConfig, error) {
 	return nil, nil
 }
 
-func (m *mockDeviceConfigDeleteService) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.DeviceConfig, error) {
-	return nil, nil
+func (m *mockDeviceConfigDeleteService) ReadMany(page int, rowsPerPage int, ctx context.Context) models.Rows[models.DeviceConfig] {
+	return models.RowsOf[models.DeviceConfig](nil, nil)
 }
 
 func (m *mockDeviceConfigDeleteService) Update(config *models.DeviceConfig, ctx context.Context) (int64, error) {
@@ -45,11 +50,12 @@ func (m *mockDeviceConfigDeleteService) ValidateConfig(config *models.DeviceConf
 }
 
 func TestDeleteHandlerInvalidID(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 	mockService := &mockDeviceConfigDeleteService{}
 
 	req := httptest.NewRequest(http.MethodDelete, "/device/config/invalid", nil)
 	req.SetPathValue("id", "invalid")
+	req.Header.Set("If-Match", `"1"`)
 	w := httptest.NewRecorder()
 
 	DeleteHandler(w, req, logger, mockService)
@@ -58,14 +64,14 @@ func TestDeleteHandlerInvalidID(t *testing.T) {
 		t.Errorf("Expected status 400, got %d", w.Code)
 	}
 
-	expected := `{"error": "Invalid ID format."}`
-	if w.Body.String() != expected {
+	expected := "Invalid ID format."
+	if problem.Detail(w.Body.Bytes()) != expected {
 		t.Errorf("Expected body %s, got %s", expected, w.Body.String())
 	}
 }
 
 func TestDeleteHandlerInternalError(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 	mockService := &mockDeviceConfigDeleteService{
 		deleteFunc: func(config *models.DeviceConfig, ctx context.Context) (int64, error) {
 			return 0, errors.New("database error")
@@ -74,6 +80,7 @@ func TestDeleteHandlerInternalError(t *testing.T) {
 
 	req := httptest.NewRequest(http.MethodDelete, "/device/config/1", nil)
 	req.SetPathValue("id", "1")
+	req.Header.Set("If-Match", `"1"`)
 	w := httptest.NewRecorder()
 
 	DeleteHandler(w, req, logger, mockService)
@@ -84,7 +91,7 @@ func TestDeleteHandlerInternalError(t *testing.T) {
 }
 
 func TestDeleteHandlerNotFound(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 	mockService := &mockDeviceConfigDeleteService{
 		deleteFunc: func(config *models.DeviceConfig, ctx context.Context) (int64, error) {
 			return 0, nil // 0 rows affected
@@ -93,6 +100,7 @@ func TestDeleteHandlerNotFound(t *testing.T) {
 
 	req := httptest.NewRequest(http.MethodDelete, "/device/config/999", nil)
 	req.SetPathValue("id", "999")
+	req.Header.Set("If-Match", "*")
 	w := httptest.NewRecorder()
 
 	DeleteHandler(w, req, logger, mockService)
@@ -101,14 +109,14 @@ func TestDeleteHandlerNotFound(t *testing.T) {
 		t.Errorf("Expected status 404, got %d", w.Code)
 	}
 
-	expected := `{"error": "Device config not found."}`
-	if w.Body.String() != expected {
+	expected := "Device config not found."
+	if problem.Detail(w.Body.Bytes()) != expected {
 		t.Errorf("Expected body %s, got %s", expected, w.Body.String())
 	}
 }
 
 func TestDeleteHandlerSuccess(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 	mockService := &mockDeviceConfigDeleteService{
 		deleteFunc: func(config *models.DeviceConfig, ctx context.Context) (int64, error) {
 			return 1, nil // 1 row affected
@@ -117,6 +125,7 @@ func TestDeleteHandlerSuccess(t *testing.T) {
 
 	req := httptest.NewRequest(http.MethodDelete, "/device/config/1", nil)
 	req.SetPathValue("id", "1")
+	req.Header.Set("If-Match", `"1"`)
 	w := httptest.NewRecorder()
 
 	DeleteHandler(w, req, logger, mockService)
@@ -132,7 +141,7 @@ func TestDeleteHandlerSuccess(t *testing.T) {
 }
 
 func TestDeleteHandlerNegativeID(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 	mockService := &mockDeviceConfigDeleteService{
 		deleteFunc: func(config *models.DeviceConfig, ctx context.Context) (int64, error) {
 			return 0, nil
@@ -141,6 +150,7 @@ func TestDeleteHandlerNegativeID(t *testing.T) {
 
 	req := httptest.NewRequest(http.MethodDelete, "/device/config/-1", nil)
 	req.SetPathValue("id", "-1")
+	req.Header.Set("If-Match", "*")
 	w := httptest.NewRecorder()
 
 	DeleteHandler(w, req, logger, mockService)
@@ -149,3 +159,72 @@ func TestDeleteHandlerNegativeID(t *testing.T) {
 		t.Errorf("Expected status 404, got %d", w.Code)
 	}
 }
+
+func TestDeleteHandlerDeviceScope(t *testing.T) {
+	logger := slog.Default()
+	device := middleware.Identity{Username: "ARD001", DeviceID: "ARD001"}
+
+	tests := []struct {
+		name    string
+		stored  string
+		code    int
+		deleted bool
+	}{
+		{name: "Own config", stored: "ARD001", code: http.StatusOK, deleted: true},
+		{name: "Other device's config", stored: "ARD002", code: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deleted := false
+			mockService := &mockDeviceConfigDeleteService{
+				readOneFunc: func(id int, ctx context.Context) (*models.DeviceConfig, error) {
+					return &models.DeviceConfig{ID: id, DeviceID: tt.stored, Version: 1}, nil
+				},
+				deleteFunc: func(config *models.DeviceConfig, ctx context.Context) (int64, error) {
+					deleted = true
+					return 1, nil
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodDelete, "/device/config/1", nil)
+			req.SetPathValue("id", "1")
+			req = req.WithContext(middleware.WithIdentity(req.Context(), device))
+			req.Header.Set("If-Match", `"1"`)
+			w := httptest.NewRecorder()
+
+			DeleteHandler(w, req, logger, mockService)
+
+			if w.Code != tt.code {
+				t.Errorf("Expected status %d, got %d", tt.code, w.Code)
+			}
+			if deleted != tt.deleted {
+				t.Errorf("Expected deleted %v, got %v", tt.deleted, deleted)
+			}
+		})
+	}
+}
+
+func TestDeleteHandlerPreconditionRequired(t *testing.T) {
+	logger := slog.Default()
+	deleted := false
+	mockService := &mockDeviceConfigDeleteService{
+		deleteFunc: func(config *models.DeviceConfig, ctx context.Context) (int64, error) {
+			deleted = true
+			return 1, nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/device/config/1", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	DeleteHandler(w, req, logger, mockService)
+
+	if w.Code != http.StatusPreconditionRequired {
+		t.Errorf("Expected status 428, got %d", w.Code)
+	}
+	if deleted {
+		t.Error("Expected nothing to be deleted without If-Match")
+	}
+}
@@ -1,21 +1,25 @@
 package device_config
 
 import (
-	"context"
 	"encoding/json"
+	"goapi/internal/api/etag"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
 	"goapi/internal/api/service/device_config"
-	"log"
+	"goapi/internal/api/tabular"
+	"io"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"time"
 )
 
 // GetHandler handles GET requests to retrieve device configs
-// Supports pagination: GET /device/config?page=1&rows_per_page=10
+// Supports pagination: GET /v1/device/config?page=1&rows_per_page=10
 // Supports filtering by device_id: GET /device/config?device_id=ARD001
-// curl -X GET "http://127.0.0.1:8080/device/config?page=1&rows_per_page=10" -u admin:password
-// curl -X GET "http://127.0.0.1:8080/device/config?device_id=ARD001" -u admin:password
-func GetHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, service device_config.DeviceConfigService) {
+// curl -X GET "http://127.0.0.1:8080/v1/device/config?page=1&rows_per_page=10" -u admin:password
+// curl -X GET "http://127.0.0.1:8080/v1/device/config?device_id=ARD001" -u admin:password
+func GetHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, service device_config.DeviceConfigService) {
 	// Parse query parameters
 	pageStr := r.URL.Query().Get("page")
 	rowsPerPageStr := r.URL.Query().Get("rows_per_page")
@@ -24,50 +28,72 @@ func GetHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, serv
 	page, _ := strconv.Atoi(pageStr)
 	rowsPerPage, _ := strconv.Atoi(rowsPerPageStr)
 
-	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
-	defer cancel()
+	// Devices may only read their own config, which is what they get without a device_id
+	identity, _ := middleware.IdentityFromContext(r.Context())
+	if deviceID == "" {
+		deviceID = identity.DeviceID
+	}
+	if !identity.CanAccessDevice(deviceID) {
+		problem.Write(w, r, http.StatusForbidden, "Forbidden: Devices may only read their own config.")
+		return
+	}
+
+	ctx := r.Context()
 
 	// If device_id is provided, return that specific config
 	if deviceID != "" {
 		config, err := service.ReadByDeviceID(deviceID, ctx)
 		if err != nil {
-			switch err.(type) {
+			switch err := err.(type) {
 			case device_config.DeviceConfigError:
-				w.WriteHeader(http.StatusBadRequest)
-				w.Write([]byte(`{"error": "` + err.Error() + `"}`))
+				problem.Invalid(w, r, err.Message, err.Fields)
 				return
 			default:
-				logger.Println("Error reading device config by device_id:", err)
-				http.Error(w, "Internal server error.", http.StatusInternalServerError)
+				logger.ErrorContext(r.Context(), "Error reading device config by device_id", "error", err)
+				problem.InternalError(w, r)
 				return
 			}
 		}
 		if config == nil {
-			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte(`{"error": "Device config not found."}`))
+			problem.Write(w, r, http.StatusNotFound, "Device config not found.")
+			return
+		}
+		w.Header().Set("ETag", etag.Format(config.Version))
+		if etag.NoneMatch(r.Header.Get("If-None-Match"), config.Version) {
+			w.WriteHeader(http.StatusNotModified)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
 		if err := json.NewEncoder(w).Encode(config); err != nil {
-			logger.Println("Error encoding device config:", err)
-			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Error encoding device config", "error", err)
+			problem.InternalError(w, r)
 			return
 		}
 		return
 	}
 
-	// Otherwise, return paginated results
-	configs, err := service.ReadMany(page, rowsPerPage, ctx)
-	if err != nil {
-		logger.Println("Error reading device configs:", err)
-		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+	// Otherwise, return paginated results, written as they are read
+	res := tabular.NewResponse(w, tabular.JSON, models.DeviceConfig{})
+	if err := tabular.Copy(res, service.ReadMany(page, rowsPerPage, ctx), nil); err != nil {
+		if res.Started() {
+			// The status line is sent, a failure can only cut the body short
+			logger.ErrorContext(r.Context(), "Error streaming device configs", "error", err)
+			return
+		}
+		logger.ErrorContext(r.Context(), "Error reading device configs", "error", err)
+		problem.InternalError(w, r)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(configs); err != nil {
-		logger.Println("Error encoding device configs:", err)
-		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+	if !res.Started() {
+		// No configs are null, as documented
+		w.WriteHeader(http.StatusOK)
+		if _, err := io.WriteString(w, "null\n"); err != nil {
+			logger.ErrorContext(r.Context(), "Error encoding device configs", "error", err)
+		}
 		return
 	}
+	if err := res.Close(); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding device configs", "error", err)
+	}
 }
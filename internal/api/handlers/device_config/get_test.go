@@ -4,12 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
 	"goapi/internal/api/repository/models"
 	"goapi/internal/api/service/device_config"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"strings"
 	"testing"
 )
@@ -35,11 +36,11 @@ func (m *mockDeviceConfigGetService) ReadByDeviceID(deviceID string, ctx context
 	return nil, nil
 }
 
-func (m *mockDeviceConfigGetService) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.DeviceConfig, error) {
+func (m *mockDeviceConfigGetService) ReadMany(page int, rowsPerPage int, ctx context.Context) models.Rows[models.DeviceConfig] {
 	if m.readManyFunc != nil {
-		return m.readManyFunc(page, rowsPerPage, ctx)
+		return models.RowsOf(m.readManyFunc(page, rowsPerPage, ctx))
 	}
-	return nil, nil
+	return models.RowsOf[models.DeviceConfig](nil, nil)
 }
 
 func (m *mockDeviceConfigGetService) Update(config *models.DeviceConfig, ctx context.Context) (int64, error) {
@@ -55,7 +56,7 @@ func (m *mockDeviceConfigGetService) ValidateConfig(config *models.DeviceConfig)
 }
 
 func TestGetHandlerSuccess(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	expectedConfigs := []*models.DeviceConfig{
 		{
@@ -100,7 +101,7 @@ func TestGetHandlerSuccess(t *testing.T) {
 }
 
 func TestGetHandlerWithPagination(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockDeviceConfigGetService{
 		readManyFunc: func(page int, rowsPerPage int, ctx context.Context) ([]*models.DeviceConfig, error) {
@@ -122,7 +123,7 @@ func TestGetHandlerWithPagination(t *testing.T) {
 }
 
 func TestGetHandlerByDeviceID(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	expectedConfig := &models.DeviceConfig{
 		ID:               1,
@@ -161,7 +162,7 @@ func TestGetHandlerByDeviceID(t *testing.T) {
 }
 
 func TestGetHandlerByDeviceIDNotFound(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockDeviceConfigGetService{
 		readByDeviceIDFunc: func(deviceID string, ctx context.Context) (*models.DeviceConfig, error) {
@@ -178,14 +179,14 @@ func TestGetHandlerByDeviceIDNotFound(t *testing.T) {
 		t.Errorf("Expected status 404, got %d", w.Code)
 	}
 
-	expected := `{"error": "Device config not found."}`
-	if w.Body.String() != expected {
+	expected := "Device config not found."
+	if problem.Detail(w.Body.Bytes()) != expected {
 		t.Errorf("Expected body %s, got %s", expected, w.Body.String())
 	}
 }
 
 func TestGetHandlerByDeviceIDValidationError(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockDeviceConfigGetService{
 		readByDeviceIDFunc: func(deviceID string, ctx context.Context) (*models.DeviceConfig, error) {
@@ -208,7 +209,7 @@ func TestGetHandlerByDeviceIDValidationError(t *testing.T) {
 }
 
 func TestGetHandlerByDeviceIDInternalError(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockDeviceConfigGetService{
 		readByDeviceIDFunc: func(deviceID string, ctx context.Context) (*models.DeviceConfig, error) {
@@ -227,7 +228,7 @@ func TestGetHandlerByDeviceIDInternalError(t *testing.T) {
 }
 
 func TestGetHandlerInternalError(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockDeviceConfigGetService{
 		readManyFunc: func(page int, rowsPerPage int, ctx context.Context) ([]*models.DeviceConfig, error) {
@@ -246,7 +247,7 @@ func TestGetHandlerInternalError(t *testing.T) {
 }
 
 func TestGetHandlerEmptyResult(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockDeviceConfigGetService{
 		readManyFunc: func(page int, rowsPerPage int, ctx context.Context) ([]*models.DeviceConfig, error) {
@@ -274,7 +275,7 @@ func TestGetHandlerEmptyResult(t *testing.T) {
 }
 
 func TestGetHandlerInvalidPaginationParameters(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockDeviceConfigGetService{
 		readManyFunc: func(page int, rowsPerPage int, ctx context.Context) ([]*models.DeviceConfig, error) {
@@ -295,3 +296,45 @@ func TestGetHandlerInvalidPaginationParameters(t *testing.T) {
 		t.Errorf("Expected status 200, got %d", w.Code)
 	}
 }
+
+func TestGetHandlerDeviceScope(t *testing.T) {
+	logger := slog.Default()
+	device := middleware.Identity{Username: "ARD001", DeviceID: "ARD001"}
+
+	tests := []struct {
+		name   string
+		target string
+		code   int
+	}{
+		{name: "Own config", target: "/device/config?device_id=ARD001", code: http.StatusOK},
+		{name: "Without device_id", target: "/device/config", code: http.StatusOK},
+		{name: "Other device's config", target: "/device/config?device_id=ARD002", code: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &mockDeviceConfigGetService{
+				readManyFunc: func(page, rowsPerPage int, ctx context.Context) ([]*models.DeviceConfig, error) {
+					t.Error("Expected a device not to list every config")
+					return nil, nil
+				},
+				readByDeviceIDFunc: func(deviceID string, ctx context.Context) (*models.DeviceConfig, error) {
+					if deviceID != "ARD001" {
+						t.Errorf("Expected device_id ARD001, got %s", deviceID)
+					}
+					return &models.DeviceConfig{ID: 1, DeviceID: deviceID, Version: 1}, nil
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req = req.WithContext(middleware.WithIdentity(req.Context(), device))
+			w := httptest.NewRecorder()
+
+			GetHandler(w, req, logger, mockService)
+
+			if w.Code != tt.code {
+				t.Errorf("Expected status %d, got %d", tt.code, w.Code)
+			}
+		})
+	}
+}
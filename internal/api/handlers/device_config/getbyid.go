@@ -1,48 +1,59 @@
 package device_config
 
 import (
-	"context"
 	"encoding/json"
+	"goapi/internal/api/etag"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
 	"goapi/internal/api/service/device_config"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"time"
 )
 
 // GetByIDHandler handles GET requests to retrieve a specific device config by ID
-// curl -X GET http://127.0.0.1:8080/device/config/1 -u admin:password
-func GetByIDHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, service device_config.DeviceConfigService) {
+// curl -X GET http://127.0.0.1:8080/v1/device/config/1 -u admin:password
+func GetByIDHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, service device_config.DeviceConfigService) {
 	// Extract ID from URL path parameter
 	idStr := r.PathValue("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "Invalid ID format."}`))
+		problem.Write(w, r, http.StatusBadRequest, "Invalid ID format.")
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
-	defer cancel()
+	ctx := r.Context()
 
 	// Retrieve the config from the database
 	config, err := service.ReadOne(id, ctx)
 	if err != nil {
-		logger.Println("Error reading device config:", err)
-		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Error reading device config", "error", err)
+		problem.InternalError(w, r)
 		return
 	}
 
 	if config == nil {
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte(`{"error": "Device config not found."}`))
+		problem.Write(w, r, http.StatusNotFound, "Device config not found.")
+		return
+	}
+
+	// Devices may only read their own config
+	if identity, _ := middleware.IdentityFromContext(r.Context()); !identity.CanAccessDevice(config.DeviceID) {
+		problem.Write(w, r, http.StatusForbidden, "Forbidden: Devices may only read their own config.")
+		return
+	}
+
+	// The version is the ETag, a client holding the current version gets 304 Not Modified without a body
+	w.Header().Set("ETag", etag.Format(config.Version))
+	if etag.NoneMatch(r.Header.Get("If-None-Match"), config.Version) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(config); err != nil {
-		logger.Println("Error encoding device config:", err)
-		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Error encoding device config", "error", err)
+		problem.InternalError(w, r)
 		return
 	}
 }
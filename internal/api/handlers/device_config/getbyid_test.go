@@ -4,11 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
 	"goapi/internal/api/repository/models"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"testing"
 )
 
@@ -32,8 +33,8 @@ func (m *mockDeviceConfigGetByIDService) ReadByDeviceID(deviceID string, ctx con
 	return nil, nil
 }
 
-func (m *mockDeviceConfigGetByIDService) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.DeviceConfig, error) {
-	return nil, nil
+func (m *mockDeviceConfigGetByIDService) ReadMany(page int, rowsPerPage int, ctx context.Context) models.Rows[models.DeviceConfig] {
+	return models.RowsOf[models.DeviceConfig](nil, nil)
 }
 
 func (m *mockDeviceConfigGetByIDService) Update(config *models.DeviceConfig, ctx context.Context) (int64, error) {
@@ -49,7 +50,7 @@ func (m *mockDeviceConfigGetByIDService) ValidateConfig(config *models.DeviceCon
 }
 
 func TestGetByIDHandlerSuccess(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	expectedConfig := &models.DeviceConfig{
 		ID:               1,
@@ -93,7 +94,7 @@ func TestGetByIDHandlerSuccess(t *testing.T) {
 }
 
 func TestGetByIDHandlerInvalidID(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 	mockService := &mockDeviceConfigGetByIDService{}
 
 	req := httptest.NewRequest(http.MethodGet, "/device/config/invalid", nil)
@@ -106,14 +107,14 @@ func TestGetByIDHandlerInvalidID(t *testing.T) {
 		t.Errorf("Expected status 400, got %d", w.Code)
 	}
 
-	expected := `{"error": "Invalid ID format."}`
-	if w.Body.String() != expected {
+	expected := "Invalid ID format."
+	if problem.Detail(w.Body.Bytes()) != expected {
 		t.Errorf("Expected body %s, got %s", expected, w.Body.String())
 	}
 }
 
 func TestGetByIDHandlerNotFound(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockDeviceConfigGetByIDService{
 		readOneFunc: func(id int, ctx context.Context) (*models.DeviceConfig, error) {
@@ -131,14 +132,14 @@ func TestGetByIDHandlerNotFound(t *testing.T) {
 		t.Errorf("Expected status 404, got %d", w.Code)
 	}
 
-	expected := `{"error": "Device config not found."}`
-	if w.Body.String() != expected {
+	expected := "Device config not found."
+	if problem.Detail(w.Body.Bytes()) != expected {
 		t.Errorf("Expected body %s, got %s", expected, w.Body.String())
 	}
 }
 
 func TestGetByIDHandlerInternalError(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockDeviceConfigGetByIDService{
 		readOneFunc: func(id int, ctx context.Context) (*models.DeviceConfig, error) {
@@ -158,7 +159,7 @@ func TestGetByIDHandlerInternalError(t *testing.T) {
 }
 
 func TestGetByIDHandlerNegativeID(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockDeviceConfigGetByIDService{
 		readOneFunc: func(id int, ctx context.Context) (*models.DeviceConfig, error) {
@@ -181,7 +182,7 @@ func TestGetByIDHandlerNegativeID(t *testing.T) {
 }
 
 func TestGetByIDHandlerZeroID(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockDeviceConfigGetByIDService{
 		readOneFunc: func(id int, ctx context.Context) (*models.DeviceConfig, error) {
@@ -204,7 +205,7 @@ func TestGetByIDHandlerZeroID(t *testing.T) {
 }
 
 func TestGetByIDHandlerLargeID(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockDeviceConfigGetByIDService{
 		readOneFunc: func(id int, ctx context.Context) (*models.DeviceConfig, error) {
@@ -225,3 +226,65 @@ func TestGetByIDHandlerLargeID(t *testing.T) {
 		t.Errorf("Expected status 404, got %d", w.Code)
 	}
 }
+
+func TestGetByIDHandlerIfNoneMatch(t *testing.T) {
+	logger := slog.Default()
+	mockService := &mockDeviceConfigGetByIDService{
+		readOneFunc: func(id int, ctx context.Context) (*models.DeviceConfig, error) {
+			return &models.DeviceConfig{ID: id, DeviceID: "ARD001", Version: 2}, nil
+		},
+	}
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		code        int
+	}{
+		{name: "Current version", ifNoneMatch: `"2"`, code: http.StatusNotModified},
+		{name: "Older version", ifNoneMatch: `"1"`, code: http.StatusOK},
+		{name: "No header", code: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/device/config/1", nil)
+			req.SetPathValue("id", "1")
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			w := httptest.NewRecorder()
+
+			GetByIDHandler(w, req, logger, mockService)
+
+			if w.Code != tt.code {
+				t.Errorf("Expected status %d, got %d", tt.code, w.Code)
+			}
+			if etag := w.Header().Get("ETag"); etag != `"2"` {
+				t.Errorf("Expected ETag \"2\", got %q", etag)
+			}
+			if tt.code == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Errorf("Expected no body, got %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetByIDHandlerOtherDevice(t *testing.T) {
+	logger := slog.Default()
+	mockService := &mockDeviceConfigGetByIDService{
+		readOneFunc: func(id int, ctx context.Context) (*models.DeviceConfig, error) {
+			return &models.DeviceConfig{ID: id, DeviceID: "ARD002", Version: 1}, nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/device/config/1", nil)
+	req.SetPathValue("id", "1")
+	req = req.WithContext(middleware.WithIdentity(req.Context(), middleware.Identity{Username: "ARD001", DeviceID: "ARD001"}))
+	w := httptest.NewRecorder()
+
+	GetByIDHandler(w, req, logger, mockService)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", w.Code)
+	}
+}
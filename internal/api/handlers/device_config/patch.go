@@ -0,0 +1,124 @@
+package device_config
+
+import (
+	"bytes"
+	"encoding/json"
+	"goapi/internal/api/etag"
+	"goapi/internal/api/mergepatch"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	"goapi/internal/api/service/device_config"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// PatchHandler handles PATCH requests applying a JSON merge patch (RFC 7396) to a device config
+// curl -X PATCH http://127.0.0.1:8080/v1/device/config/1 -u admin:password -H "Content-Type: application/merge-patch+json" -d '{"sensitivity_level":9}'
+func PatchHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, service device_config.DeviceConfigService) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid ID format.")
+		return
+	}
+
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request data. Please check your input.")
+		return
+	}
+
+	version, err := etag.IfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		problem.Write(w, r, http.StatusPreconditionFailed, "Precondition failed: If-Match must be a single strong ETag.")
+		return
+	}
+
+	ctx := r.Context()
+
+	current, err := service.ReadOne(id, ctx)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Error reading device config", "error", err)
+		problem.InternalError(w, r)
+		return
+	}
+	if current == nil {
+		problem.Write(w, r, http.StatusNotFound, "Device config not found.")
+		return
+	}
+
+	// Devices may only update their own config
+	identity, _ := middleware.IdentityFromContext(r.Context())
+	if !identity.CanAccessDevice(current.DeviceID) {
+		problem.Write(w, r, http.StatusForbidden, "Forbidden: Devices may only change their own config.")
+		return
+	}
+	if version != 0 && version != current.Version {
+		w.Header().Set("ETag", etag.Format(current.Version))
+		problem.Write(w, r, http.StatusPreconditionFailed, "Precondition failed: device config was modified.")
+		return
+	}
+
+	// Merge the patch into the stored config, unknown fields are rejected so typos do not go unnoticed
+	document, err := json.Marshal(current)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding device config", "error", err, "config", current)
+		problem.InternalError(w, r)
+		return
+	}
+	merged, err := mergepatch.Apply(document, patch)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request data. The body must be a JSON merge patch object.")
+		return
+	}
+	var config models.DeviceConfig
+	decoder := json.NewDecoder(bytes.NewReader(merged))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request data. Please check your input.")
+		return
+	}
+
+	// The patch may not move the config to another device
+	if !identity.CanAccessDevice(config.DeviceID) {
+		problem.Write(w, r, http.StatusForbidden, "Forbidden: Devices may only change their own config.")
+		return
+	}
+
+	// The ID comes from the path, and the update only applies to the version the patch was merged into
+	config.ID = id
+	config.Version = current.Version
+
+	// A patch that does not set updated_at is stamped with the time of the update
+	if config.UpdatedAt == current.UpdatedAt {
+		config.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	// Update validates the merged config like a PUT
+	if rowsAffected, err := service.Update(&config, ctx); err != nil {
+		switch err := err.(type) {
+		case device_config.DeviceConfigError:
+			problem.Invalid(w, r, err.Message, err.Fields)
+			return
+		default:
+			logger.ErrorContext(r.Context(), "Error patching device config", "error", err, "config", config)
+			problem.InternalError(w, r)
+			return
+		}
+	} else if rowsAffected == 0 {
+		// Modified or deleted between reading and writing
+		problem.Write(w, r, http.StatusPreconditionFailed, "Precondition failed: device config was modified.")
+		return
+	}
+
+	w.Header().Set("ETag", etag.Format(config.Version))
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(config); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding device config", "error", err, "config", config)
+		problem.InternalError(w, r)
+		return
+	}
+}
@@ -0,0 +1,203 @@
+package device_config
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/repository/models"
+	"goapi/internal/api/service/device_config"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Mock service for PATCH testing, holds a single stored config
+type mockDeviceConfigPatchService struct {
+	stored  *models.DeviceConfig
+	updated *models.DeviceConfig
+}
+
+func (m *mockDeviceConfigPatchService) Create(config *models.DeviceConfig, ctx context.Context) error {
+	return nil
+}
+
+func (m *mockDeviceConfigPatchService) ReadOne(id int, ctx context.Context) (*models.DeviceConfig, error) {
+	if m.stored == nil || m.stored.ID != id {
+		return nil, nil
+	}
+	stored := *m.stored
+	return &stored, nil
+}
+
+func (m *mockDeviceConfigPatchService) ReadByDeviceID(deviceID string, ctx context.Context) (*models.DeviceConfig, error) {
+	return nil, nil
+}
+
+func (m *mockDeviceConfigPatchService) ReadMany(page int, rowsPerPage int, ctx context.Context) models.Rows[models.DeviceConfig] {
+	return models.RowsOf[models.DeviceConfig](nil, nil)
+}
+
+func (m *mockDeviceConfigPatchService) Update(config *models.DeviceConfig, ctx context.Context) (int64, error) {
+	if config.SensitivityLevel > 10 {
+		return 0, device_config.DeviceConfigError{Message: "Invalid device config: sensitivity_level must be between 1 and 10."}
+	}
+	if config.Version != m.stored.Version {
+		return 0, nil
+	}
+	config.Version++
+	m.updated = config
+	return 1, nil
+}
+
+func (m *mockDeviceConfigPatchService) Delete(config *models.DeviceConfig, ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockDeviceConfigPatchService) ValidateConfig(config *models.DeviceConfig) error {
+	return nil
+}
+
+func newStoredConfig() *models.DeviceConfig {
+	return &models.DeviceConfig{
+		ID:               1,
+		DeviceID:         "ARD001",
+		AlarmTimeout:     300,
+		SensitivityLevel: 5,
+		UpdatedAt:        "2024-01-15T10:35:00Z",
+		Version:          3,
+	}
+}
+
+func TestPatchHandlerSuccess(t *testing.T) {
+	logger := slog.Default()
+	mockService := &mockDeviceConfigPatchService{stored: newStoredConfig()}
+
+	req := httptest.NewRequest(http.MethodPatch, "/device/config/1", strings.NewReader(`{"alarm_timeout":0,"updated_at":"2024-01-16T08:00:00Z"}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	PatchHandler(w, req, logger, mockService)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if etag := w.Header().Get("ETag"); etag != `"4"` {
+		t.Errorf("Expected ETag \"4\", got %q", etag)
+	}
+
+	var response models.DeviceConfig
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	// Only the patched fields change, 0 is applied rather than treated as missing
+	expected := newStoredConfig()
+	expected.AlarmTimeout = 0
+	expected.UpdatedAt = "2024-01-16T08:00:00Z"
+	expected.Version = 4
+	if response != *expected {
+		t.Errorf("Expected %+v, got %+v", *expected, response)
+	}
+}
+
+func TestPatchHandlerErrors(t *testing.T) {
+	logger := slog.Default()
+
+	tests := []struct {
+		name    string
+		id      string
+		body    string
+		ifMatch string
+		code    int
+	}{
+		{name: "Invalid ID", id: "abc", body: `{}`, code: http.StatusBadRequest},
+		{name: "Not found", id: "2", body: `{"sensitivity_level":7}`, code: http.StatusNotFound},
+		{name: "Not an object", id: "1", body: `7`, code: http.StatusBadRequest},
+		{name: "Unknown field", id: "1", body: `{"sensitivity":7}`, code: http.StatusBadRequest},
+		{name: "Validation failed", id: "1", body: `{"sensitivity_level":11}`, code: http.StatusBadRequest},
+		{name: "Stale version", id: "1", body: `{"sensitivity_level":7}`, ifMatch: `"2"`, code: http.StatusPreconditionFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &mockDeviceConfigPatchService{stored: newStoredConfig()}
+
+			req := httptest.NewRequest(http.MethodPatch, "/device/config/"+tt.id, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+			req.SetPathValue("id", tt.id)
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+
+			PatchHandler(w, req, logger, mockService)
+
+			if w.Code != tt.code {
+				t.Errorf("Expected status %d, got %d: %s", tt.code, w.Code, w.Body.String())
+			}
+			if mockService.updated != nil {
+				t.Errorf("Expected no update, got %+v", mockService.updated)
+			}
+		})
+	}
+}
+
+func TestPatchHandlerStampsUpdatedAt(t *testing.T) {
+	logger := slog.Default()
+	mockService := &mockDeviceConfigPatchService{stored: newStoredConfig()}
+
+	req := httptest.NewRequest(http.MethodPatch, "/device/config/1", strings.NewReader(`{"sensitivity_level":7}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.Header.Set("If-Match", `"3"`)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	PatchHandler(w, req, logger, mockService)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if mockService.updated.SensitivityLevel != 7 || mockService.updated.UpdatedAt == newStoredConfig().UpdatedAt {
+		t.Errorf("Expected sensitivity 7 and a new updated_at, got %+v", mockService.updated)
+	}
+}
+
+func TestPatchHandlerDeviceScope(t *testing.T) {
+	logger := slog.Default()
+	device := middleware.Identity{Username: "ARD001", DeviceID: "ARD001"}
+
+	tests := []struct {
+		name   string
+		stored string
+		patch  string
+	}{
+		{name: "Other device's config", stored: "ARD002", patch: `{"sensitivity_level":9}`},
+		{name: "Moving the config to another device", stored: "ARD001", patch: `{"device_id":"ARD002"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &mockDeviceConfigPatchService{
+				stored: &models.DeviceConfig{ID: 1, DeviceID: tt.stored, AlarmTimeout: 300, SensitivityLevel: 5, UpdatedAt: "2024-01-15T10:30:00Z", Version: 1},
+			}
+
+			req := httptest.NewRequest(http.MethodPatch, "/device/config/1", strings.NewReader(tt.patch))
+			req.SetPathValue("id", "1")
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+			req = req.WithContext(middleware.WithIdentity(req.Context(), device))
+			w := httptest.NewRecorder()
+
+			PatchHandler(w, req, logger, mockService)
+
+			if w.Code != http.StatusForbidden {
+				t.Errorf("Expected status 403, got %d: %s", w.Code, w.Body.String())
+			}
+			if mockService.updated != nil {
+				t.Errorf("Expected no update, got %+v", mockService.updated)
+			}
+		})
+	}
+}
@@ -1,48 +1,51 @@
 package device_config
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
 	"goapi/internal/api/repository/models"
 	"goapi/internal/api/service/device_config"
-	"log"
+	"log/slog"
 	"net/http"
-	"time"
 )
 
 // PostHandler handles POST requests to create new device config
-// curl -X POST http://127.0.0.1:8080/device/config -u admin:password -H "Content-Type: application/json" -d '{"device_id":"ARD001","alarm_timeout":300,"sensitivity_level":5,"updated_at":"2024-01-15T10:30:00Z"}'
-func PostHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, service device_config.DeviceConfigService) {
+// curl -X POST http://127.0.0.1:8080/v1/device/config -u admin:password -H "Content-Type: application/json" -d '{"device_id":"ARD001","alarm_timeout":300,"sensitivity_level":5,"updated_at":"2024-01-15T10:30:00Z"}'
+func PostHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, service device_config.DeviceConfigService) {
 	var config models.DeviceConfig
 
 	// Decode the JSON payload from the request body
 	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "Invalid request data. Please check your input."}`))
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request data. Please check your input.")
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
-	defer cancel()
+	// Devices may only create their own config
+	if identity, _ := middleware.IdentityFromContext(r.Context()); !identity.CanAccessDevice(config.DeviceID) {
+		problem.Write(w, r, http.StatusForbidden, "Forbidden: Devices may only change their own config.")
+		return
+	}
+
+	ctx := r.Context()
 
 	// Try to create the config in the database
 	if err := service.Create(&config, ctx); err != nil {
-		switch err.(type) {
+		switch err := err.(type) {
 		case device_config.DeviceConfigError:
 			// Client error: validation failed
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte(`{"error": "` + err.Error() + `"}`))
+			problem.Invalid(w, r, err.Message, err.Fields)
 			return
 		default:
 			// Server error (could be duplicate device_id due to UNIQUE constraint)
-			logger.Println("Error creating device config:", err, config)
-			// Check if it's a unique constraint error
-			if err.Error() == "UNIQUE constraint failed: device_config.device_id" {
-				w.WriteHeader(http.StatusConflict)
-				w.Write([]byte(`{"error": "Device config already exists for this device_id."}`))
+			logger.ErrorContext(r.Context(), "Error creating device config", "error", err, "config", config)
+			// Another config already holds the device_id, the unique constraint rejects it
+			if errors.As(err, new(*models.DuplicateError)) {
+				problem.Write(w, r, http.StatusConflict, "Device config already exists for this device_id.")
 				return
 			}
-			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			problem.InternalError(w, r)
 			return
 		}
 	}
@@ -50,8 +53,8 @@ func PostHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, ser
 	// Return the created config with 201 Created
 	w.WriteHeader(http.StatusCreated)
 	if err := json.NewEncoder(w).Encode(config); err != nil {
-		logger.Println("Error encoding device config:", err, config)
-		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Error encoding device config", "error", err, "config", config)
+		problem.InternalError(w, r)
 		return
 	}
 }
@@ -5,12 +5,12 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"goapi/internal/api/middleware"
 	"goapi/internal/api/repository/models"
 	"goapi/internal/api/service/device_config"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"testing"
 )
 
@@ -31,8 +31,8 @@ func (m *mockDeviceConfigService) ReadByDeviceID(deviceID string, ctx context.Co
 	return nil, nil
 }
 
-func (m *mockDeviceConfigService) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.DeviceConfig, error) {
-	return nil, nil
+func (m *mockDeviceConfigService) ReadMany(page int, rowsPerPage int, ctx context.Context) models.Rows[models.DeviceConfig] {
+	return models.RowsOf[models.DeviceConfig](nil, nil)
 }
 
 func (m *mockDeviceConfigService) Update(config *models.DeviceConfig, ctx context.Context) (int64, error) {
@@ -48,7 +48,7 @@ func (m *mockDeviceConfigService) ValidateConfig(config *models.DeviceConfig) er
 }
 
 func TestPostHandlerInvalidJSON(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 	mockService := &mockDeviceConfigService{}
 
 	req := httptest.NewRequest(http.MethodPost, "/device/config", bytes.NewBufferString("{invalid json}"))
@@ -63,7 +63,7 @@ func TestPostHandlerInvalidJSON(t *testing.T) {
 }
 
 func TestPostHandlerValidationError(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 	mockService := &mockDeviceConfigService{
 		createFunc: func(config *models.DeviceConfig, ctx context.Context) error {
 			return device_config.DeviceConfigError{Message: "device_id is required"}
@@ -90,10 +90,10 @@ func TestPostHandlerValidationError(t *testing.T) {
 }
 
 func TestPostHandlerUniqueConstraintError(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 	mockService := &mockDeviceConfigService{
 		createFunc: func(config *models.DeviceConfig, ctx context.Context) error {
-			return errors.New("UNIQUE constraint failed: device_config.device_id")
+			return &models.DuplicateError{Err: errors.New("UNIQUE constraint failed: device_config.device_id")}
 		},
 	}
 
@@ -117,7 +117,7 @@ func TestPostHandlerUniqueConstraintError(t *testing.T) {
 }
 
 func TestPostHandlerSuccess(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 	mockService := &mockDeviceConfigService{
 		createFunc: func(config *models.DeviceConfig, ctx context.Context) error {
 			config.ID = 1
@@ -149,3 +149,25 @@ func TestPostHandlerSuccess(t *testing.T) {
 		t.Errorf("Expected ID 1, got %d", response.ID)
 	}
 }
+
+func TestPostHandlerOtherDevice(t *testing.T) {
+	logger := slog.Default()
+	mockService := &mockDeviceConfigService{
+		createFunc: func(config *models.DeviceConfig, ctx context.Context) error {
+			t.Error("Expected no config to be created")
+			return nil
+		},
+	}
+
+	body := `{"device_id":"ARD002","alarm_timeout":300,"sensitivity_level":5,"updated_at":"2024-01-15T10:30:00Z"}`
+	req := httptest.NewRequest(http.MethodPost, "/device/config", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(middleware.WithIdentity(req.Context(), middleware.Identity{Username: "ARD001", DeviceID: "ARD001"}))
+	w := httptest.NewRecorder()
+
+	PostHandler(w, req, logger, mockService)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", w.Code)
+	}
+}
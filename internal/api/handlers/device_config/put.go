@@ -1,65 +1,106 @@
 package device_config
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
+	"goapi/internal/api/etag"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
 	"goapi/internal/api/repository/models"
 	"goapi/internal/api/service/device_config"
-	"log"
+	"log/slog"
 	"net/http"
-	"time"
 )
 
 // PutHandler handles PUT requests to update device config
-// curl -X PUT http://127.0.0.1:8080/device/config -u admin:password -H "Content-Type: application/json" -d '{"id":1,"device_id":"ARD001","alarm_timeout":600,"sensitivity_level":7,"updated_at":"2024-01-15T10:35:00Z"}'
-func PutHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, service device_config.DeviceConfigService) {
+// curl -X PUT http://127.0.0.1:8080/v1/device/config -u admin:password -H "Content-Type: application/json" -H 'If-Match: "1"' -d '{"id":1,"device_id":"ARD001","alarm_timeout":600,"sensitivity_level":7,"updated_at":"2024-01-15T10:35:00Z"}'
+func PutHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, service device_config.DeviceConfigService) {
 	var config models.DeviceConfig
 
 	// Decode the JSON payload from the request body
 	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "Invalid request data. Please check your input."}`))
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request data. Please check your input.")
+		return
+	}
+
+	// Devices may only update their own config
+	identity, _ := middleware.IdentityFromContext(r.Context())
+	if !identity.CanAccessDevice(config.DeviceID) {
+		problem.Write(w, r, http.StatusForbidden, "Forbidden: Devices may only change their own config.")
 		return
 	}
 
 	// Validate that ID is provided
 	if config.ID == 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "ID is required for update."}`))
+		problem.Write(w, r, http.StatusBadRequest, "ID is required for update.")
+		return
+	}
+
+	// If-Match is required, the version in the body is ignored and * writes whatever version is stored
+	version, err := etag.RequireMatch(r.Header.Get("If-Match"))
+	if errors.Is(err, etag.ErrPreconditionRequired) {
+		problem.Write(w, r, http.StatusPreconditionRequired, "Precondition required: If-Match must hold the ETag of the resource.")
+		return
+	}
+	if err != nil {
+		problem.Write(w, r, http.StatusPreconditionFailed, "Precondition failed: If-Match must be a single strong ETag.")
 		return
 	}
+	config.Version = version
 
-	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
-	defer cancel()
+	ctx := r.Context()
+
+	// The stored config must belong to the device too, or it could overwrite another device's config by ID
+	current, err := service.ReadOne(config.ID, ctx)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Error reading device config", "error", err)
+		problem.InternalError(w, r)
+		return
+	}
+	if current == nil {
+		problem.Write(w, r, http.StatusNotFound, "Device config not found.")
+		return
+	}
+	if !identity.CanAccessDevice(current.DeviceID) {
+		problem.Write(w, r, http.StatusForbidden, "Forbidden: Devices may only change their own config.")
+		return
+	}
 
 	// Try to update the config in the database
 	rowsAffected, err := service.Update(&config, ctx)
 	if err != nil {
-		switch err.(type) {
+		switch err := err.(type) {
 		case device_config.DeviceConfigError:
 			// Client error: validation failed
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte(`{"error": "` + err.Error() + `"}`))
+			problem.Invalid(w, r, err.Message, err.Fields)
 			return
 		default:
 			// Server error
-			logger.Println("Error updating device config:", err, config)
-			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Error updating device config", "error", err, "config", config)
+			problem.InternalError(w, r)
 			return
 		}
 	}
 
 	if rowsAffected == 0 {
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte(`{"error": "Device config not found."}`))
+		// A stale version is told apart from a missing device config by reading the current one
+		if config.Version != 0 {
+			if current, err := service.ReadOne(config.ID, ctx); err == nil && current != nil {
+				w.Header().Set("ETag", etag.Format(current.Version))
+				problem.Write(w, r, http.StatusPreconditionFailed, "Precondition failed: device config was modified.")
+				return
+			}
+		}
+		problem.Write(w, r, http.StatusNotFound, "Device config not found.")
 		return
 	}
 
 	// Return the updated config with 200 OK
+	w.Header().Set("ETag", etag.Format(config.Version))
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(config); err != nil {
-		logger.Println("Error encoding device config:", err, config)
-		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Error encoding device config", "error", err, "config", config)
+		problem.InternalError(w, r)
 		return
 	}
 }
@@ -5,19 +5,21 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
 	"goapi/internal/api/repository/models"
 	"goapi/internal/api/service/device_config"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"strings"
 	"testing"
 )
 
 // Mock service for PUT testing
 type mockDeviceConfigPutService struct {
-	updateFunc func(*models.DeviceConfig, context.Context) (int64, error)
+	updateFunc  func(*models.DeviceConfig, context.Context) (int64, error)
+	readOneFunc func(int, context.Context) (*models.DeviceConfig, error)
 }
 
 func (m *mockDeviceConfigPutService) Create(config *models.DeviceConfig, ctx context.Context) error {
@@ -25,15 +27,18 @@ func (m *mockDeviceConfigPutService) Create(config *models.DeviceConfig, ctx con
 }
 
 func (m *mockDeviceConfigPutService) ReadOne(id int, ctx context.Context) (*models.DeviceConfig, error) {
-	return nil, nil
+	if m.readOneFunc != nil {
+		return m.readOneFunc(id, ctx)
+	}
+	return &models.DeviceConfig{ID: id, DeviceID: "ARD001", Version: 1}, nil
 }
 
 func (m *mockDeviceConfigPutService) ReadByDeviceID(deviceID string, ctx context.Context) (*models.DeviceConfig, error) {
 	return nil, nil
 }
 
-func (m *mockDeviceConfigPutService) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.DeviceConfig, error) {
-	return nil, nil
+func (m *mockDeviceConfigPutService) ReadMany(page int, rowsPerPage int, ctx context.Context) models.Rows[models.DeviceConfig] {
+	return models.RowsOf[models.DeviceConfig](nil, nil)
 }
 
 func (m *mockDeviceConfigPutService) Update(config *models.DeviceConfig, ctx context.Context) (int64, error) {
@@ -52,7 +57,7 @@ func (m *mockDeviceConfigPutService) ValidateConfig(config *models.DeviceConfig)
 }
 
 func TestPutHandlerSuccess(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockDeviceConfigPutService{
 		updateFunc: func(config *models.DeviceConfig, ctx context.Context) (int64, error) {
@@ -71,6 +76,7 @@ func TestPutHandlerSuccess(t *testing.T) {
 	jsonData, _ := json.Marshal(config)
 	req := httptest.NewRequest(http.MethodPut, "/device/config", bytes.NewBuffer(jsonData))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
 	w := httptest.NewRecorder()
 
 	PutHandler(w, req, logger, mockService)
@@ -90,11 +96,12 @@ func TestPutHandlerSuccess(t *testing.T) {
 }
 
 func TestPutHandlerInvalidJSON(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 	mockService := &mockDeviceConfigPutService{}
 
 	req := httptest.NewRequest(http.MethodPut, "/device/config", bytes.NewBufferString("{invalid json}"))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
 	w := httptest.NewRecorder()
 
 	PutHandler(w, req, logger, mockService)
@@ -109,7 +116,7 @@ func TestPutHandlerInvalidJSON(t *testing.T) {
 }
 
 func TestPutHandlerMissingID(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 	mockService := &mockDeviceConfigPutService{}
 
 	config := models.DeviceConfig{
@@ -123,6 +130,7 @@ func TestPutHandlerMissingID(t *testing.T) {
 	jsonData, _ := json.Marshal(config)
 	req := httptest.NewRequest(http.MethodPut, "/device/config", bytes.NewBuffer(jsonData))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
 	w := httptest.NewRecorder()
 
 	PutHandler(w, req, logger, mockService)
@@ -131,14 +139,14 @@ func TestPutHandlerMissingID(t *testing.T) {
 		t.Errorf("Expected status 400, got %d", w.Code)
 	}
 
-	expected := `{"error": "ID is required for update."}`
-	if w.Body.String() != expected {
+	expected := "ID is required for update."
+	if problem.Detail(w.Body.Bytes()) != expected {
 		t.Errorf("Expected body %s, got %s", expected, w.Body.String())
 	}
 }
 
 func TestPutHandlerValidationError(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockDeviceConfigPutService{
 		updateFunc: func(config *models.DeviceConfig, ctx context.Context) (int64, error) {
@@ -157,6 +165,7 @@ func TestPutHandlerValidationError(t *testing.T) {
 	jsonData, _ := json.Marshal(config)
 	req := httptest.NewRequest(http.MethodPut, "/device/config", bytes.NewBuffer(jsonData))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
 	w := httptest.NewRecorder()
 
 	PutHandler(w, req, logger, mockService)
@@ -171,7 +180,7 @@ func TestPutHandlerValidationError(t *testing.T) {
 }
 
 func TestPutHandlerNotFound(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockDeviceConfigPutService{
 		updateFunc: func(config *models.DeviceConfig, ctx context.Context) (int64, error) {
@@ -190,6 +199,7 @@ func TestPutHandlerNotFound(t *testing.T) {
 	jsonData, _ := json.Marshal(config)
 	req := httptest.NewRequest(http.MethodPut, "/device/config", bytes.NewBuffer(jsonData))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", "*")
 	w := httptest.NewRecorder()
 
 	PutHandler(w, req, logger, mockService)
@@ -198,14 +208,14 @@ func TestPutHandlerNotFound(t *testing.T) {
 		t.Errorf("Expected status 404, got %d", w.Code)
 	}
 
-	expected := `{"error": "Device config not found."}`
-	if w.Body.String() != expected {
+	expected := "Device config not found."
+	if problem.Detail(w.Body.Bytes()) != expected {
 		t.Errorf("Expected body %s, got %s", expected, w.Body.String())
 	}
 }
 
 func TestPutHandlerInternalError(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockDeviceConfigPutService{
 		updateFunc: func(config *models.DeviceConfig, ctx context.Context) (int64, error) {
@@ -224,6 +234,7 @@ func TestPutHandlerInternalError(t *testing.T) {
 	jsonData, _ := json.Marshal(config)
 	req := httptest.NewRequest(http.MethodPut, "/device/config", bytes.NewBuffer(jsonData))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
 	w := httptest.NewRecorder()
 
 	PutHandler(w, req, logger, mockService)
@@ -234,11 +245,12 @@ func TestPutHandlerInternalError(t *testing.T) {
 }
 
 func TestPutHandlerEmptyBody(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 	mockService := &mockDeviceConfigPutService{}
 
 	req := httptest.NewRequest(http.MethodPut, "/device/config", bytes.NewBuffer([]byte{}))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
 	w := httptest.NewRecorder()
 
 	PutHandler(w, req, logger, mockService)
@@ -249,7 +261,7 @@ func TestPutHandlerEmptyBody(t *testing.T) {
 }
 
 func TestPutHandlerBoundaryValues(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockDeviceConfigPutService{
 		updateFunc: func(config *models.DeviceConfig, ctx context.Context) (int64, error) {
@@ -273,6 +285,7 @@ func TestPutHandlerBoundaryValues(t *testing.T) {
 	jsonData, _ := json.Marshal(config)
 	req := httptest.NewRequest(http.MethodPut, "/device/config", bytes.NewBuffer(jsonData))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
 	w := httptest.NewRecorder()
 
 	PutHandler(w, req, logger, mockService)
@@ -283,7 +296,7 @@ func TestPutHandlerBoundaryValues(t *testing.T) {
 }
 
 func TestPutHandlerMaxValues(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockDeviceConfigPutService{
 		updateFunc: func(config *models.DeviceConfig, ctx context.Context) (int64, error) {
@@ -302,6 +315,7 @@ func TestPutHandlerMaxValues(t *testing.T) {
 	jsonData, _ := json.Marshal(config)
 	req := httptest.NewRequest(http.MethodPut, "/device/config", bytes.NewBuffer(jsonData))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
 	w := httptest.NewRecorder()
 
 	PutHandler(w, req, logger, mockService)
@@ -310,3 +324,119 @@ func TestPutHandlerMaxValues(t *testing.T) {
 		t.Errorf("Expected status 200, got %d", w.Code)
 	}
 }
+
+func TestPutHandlerIfMatch(t *testing.T) {
+	logger := slog.Default()
+	body := `{"id":1,"device_id":"ARD001","alarm_timeout":600,"sensitivity_level":7,"updated_at":"2024-01-15T10:35:00Z","version":2}`
+
+	// The version in the body is ignored, only If-Match makes the update conditional
+	tests := []struct {
+		name     string
+		ifMatch  string
+		affected int64
+		current  *models.DeviceConfig
+		missing  bool
+		code     int
+		version  int
+		etag     string
+	}{
+		{name: "Missing If-Match", code: http.StatusPreconditionRequired},
+		{name: "Matching version", ifMatch: `"3"`, affected: 1, code: http.StatusOK, version: 3, etag: `"4"`},
+		{name: "Stale version", ifMatch: `"2"`, current: &models.DeviceConfig{ID: 1, Version: 3}, code: http.StatusPreconditionFailed, version: 2, etag: `"3"`},
+		{name: "Missing config", ifMatch: `"2"`, missing: true, code: http.StatusNotFound},
+		{name: "List of tags", ifMatch: `"2", "3"`, code: http.StatusPreconditionFailed},
+		{name: "Any version", ifMatch: "*", affected: 1, code: http.StatusOK, version: 0, etag: `"1"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var version int
+			mockService := &mockDeviceConfigPutService{
+				updateFunc: func(config *models.DeviceConfig, ctx context.Context) (int64, error) {
+					version = config.Version
+					if tt.affected > 0 {
+						config.Version++
+					}
+					return tt.affected, nil
+				},
+				readOneFunc: func(id int, ctx context.Context) (*models.DeviceConfig, error) {
+					if tt.missing {
+						return nil, nil
+					}
+					if tt.current != nil {
+						return tt.current, nil
+					}
+					return &models.DeviceConfig{ID: id, DeviceID: "ARD001", Version: 3}, nil
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodPut, "/device/config", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+
+			PutHandler(w, req, logger, mockService)
+
+			if w.Code != tt.code {
+				t.Errorf("Expected status %d, got %d", tt.code, w.Code)
+			}
+			if version != tt.version {
+				t.Errorf("Expected the update to require version %d, got %d", tt.version, version)
+			}
+			if etag := w.Header().Get("ETag"); etag != tt.etag {
+				t.Errorf("Expected ETag %q, got %q", tt.etag, etag)
+			}
+		})
+	}
+}
+
+func TestPutHandlerDeviceScope(t *testing.T) {
+	logger := slog.Default()
+	device := middleware.Identity{Username: "ARD001", DeviceID: "ARD001"}
+
+	tests := []struct {
+		name    string
+		body    string
+		stored  string
+		code    int
+		updated bool
+	}{
+		{name: "Own config", body: "ARD001", stored: "ARD001", code: http.StatusOK, updated: true},
+		{name: "Config of another device", body: "ARD002", stored: "ARD002", code: http.StatusForbidden},
+		{name: "Taking over another device's config", body: "ARD001", stored: "ARD002", code: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated := false
+			mockService := &mockDeviceConfigPutService{
+				readOneFunc: func(id int, ctx context.Context) (*models.DeviceConfig, error) {
+					return &models.DeviceConfig{ID: id, DeviceID: tt.stored, Version: 1}, nil
+				},
+				updateFunc: func(config *models.DeviceConfig, ctx context.Context) (int64, error) {
+					updated = true
+					config.Version++
+					return 1, nil
+				},
+			}
+
+			body := `{"id":1,"device_id":"` + tt.body + `","alarm_timeout":600,"sensitivity_level":7,"updated_at":"2024-01-15T10:35:00Z"}`
+			req := httptest.NewRequest(http.MethodPut, "/device/config", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("If-Match", `"1"`)
+			req = req.WithContext(middleware.WithIdentity(req.Context(), device))
+			w := httptest.NewRecorder()
+
+			PutHandler(w, req, logger, mockService)
+
+			if w.Code != tt.code {
+				t.Errorf("Expected status %d, got %d: %s", tt.code, w.Code, w.Body.String())
+			}
+			if updated != tt.updated {
+				t.Errorf("Expected updated %v, got %v", tt.updated, updated)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package export
+
+import (
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/export"
+	"goapi/internal/api/tabular"
+	"log/slog"
+	"net/http"
+)
+
+// extensions are the file name extensions of the formats, suggested in the Content-Disposition header
+var extensions = map[string]string{
+	tabular.CSV:    ".csv",
+	tabular.NDJSON: ".ndjson",
+	tabular.JSON:   ".json",
+}
+
+// GetHandler handles GET requests streaming a whole table, rows are written as they are read from the database.
+// Supports filters: GET /v1/export/maze_device_status?device_id=ARD001&since=2024-01-15T00:00:00Z&until=2024-01-16T00:00:00Z
+// Sends CSV unless the Accept header asks for application/x-ndjson or application/json.
+// curl "http://127.0.0.1:8080/v1/export/maze_device_status?device_id=ARD001" -u admin:password -H "Content-Type: application/json" -o maze_device_status.csv
+func GetHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, es service.ExportService) {
+	table := r.PathValue("table")
+	query := r.URL.Query()
+	filter := &models.ExportFilter{
+		DeviceID: query.Get("device_id"),
+		Since:    query.Get("since"),
+		Until:    query.Get("until"),
+	}
+	format := tabular.Negotiate(r.Header.Get("Accept"), tabular.CSV)
+
+	ctx := r.Context()
+
+	var started bool
+	var err error
+	switch table {
+	case models.ExportMazeDeviceStatus:
+		started, err = stream(w, format, table, func(each func(status *models.MazeDeviceStatus) error) error {
+			return es.ExportStatuses(filter, each, ctx)
+		})
+	case models.ExportData:
+		started, err = stream(w, format, table, func(each func(data *models.Data) error) error {
+			return es.ExportData(filter, each, ctx)
+		})
+	default:
+		problem.Write(w, r, http.StatusNotFound, "Unknown table, export "+models.ExportMazeDeviceStatus+" or "+models.ExportData+".")
+		return
+	}
+	if err == nil {
+		return
+	}
+
+	if started {
+		// The status line is sent, the client sees a body cut short
+		logger.ErrorContext(r.Context(), "Error streaming export", "error", err, "table", table)
+		return
+	}
+	switch err.(type) {
+	case service.ExportError:
+		// Client error: invalid filter
+		problem.Write(w, r, http.StatusBadRequest, err.Error())
+	default:
+		// Server error
+		logger.ErrorContext(r.Context(), "Error exporting table", "error", err, "table", table)
+		problem.InternalError(w, r)
+	}
+}
+
+// stream writes the rows export passes to each. The response starts with the first row, so an export failing
+// before it, e.g. on an invalid filter, can still be answered with a problem. started reports whether it did.
+func stream[T any](w http.ResponseWriter, format string, table string, export func(each func(row *T) error) error) (started bool, err error) {
+	var rows *tabular.Writer
+	start := func() {
+		w.Header().Set("Content-Type", tabular.ContentType(format))
+		w.Header().Set("Content-Disposition", `attachment; filename="`+table+extensions[format]+`"`)
+		w.WriteHeader(http.StatusOK)
+		rows = tabular.NewWriter(w, format, new(T))
+	}
+
+	err = export(func(row *T) error {
+		if rows == nil {
+			start()
+		}
+		return rows.Write(row)
+	})
+	if err != nil {
+		return rows != nil, err
+	}
+	if rows == nil {
+		start()
+	}
+	return true, rows.Close()
+}
@@ -0,0 +1,137 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/export"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Mock service streaming fixed rows, err is returned after them
+type mockExportService struct {
+	filter   *models.ExportFilter
+	statuses []*models.MazeDeviceStatus
+	data     []*models.Data
+	err      error
+}
+
+func (m *mockExportService) ExportStatuses(filter *models.ExportFilter, each func(status *models.MazeDeviceStatus) error, ctx context.Context) error {
+	m.filter = filter
+	for _, status := range m.statuses {
+		if err := each(status); err != nil {
+			return err
+		}
+	}
+	return m.err
+}
+
+func (m *mockExportService) ExportData(filter *models.ExportFilter, each func(data *models.Data) error, ctx context.Context) error {
+	m.filter = filter
+	for _, d := range m.data {
+		if err := each(d); err != nil {
+			return err
+		}
+	}
+	return m.err
+}
+
+func TestGetHandlerStreamsCSV(t *testing.T) {
+	mockService := &mockExportService{statuses: []*models.MazeDeviceStatus{
+		{ID: 1, DeviceID: "ARD001", AlarmActive: true, BatteryLevel: 85, Timestamp: "2024-01-15T10:30:00Z", Version: 1},
+	}}
+
+	req := httptest.NewRequest(http.MethodGet, "/export/maze_device_status?device_id=ARD001&since=2024-01-15T00:00:00Z", nil)
+	req.SetPathValue("table", "maze_device_status")
+	w := httptest.NewRecorder()
+
+	GetHandler(w, req, slog.Default(), mockService)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	expectedFilter := models.ExportFilter{DeviceID: "ARD001", Since: "2024-01-15T00:00:00Z"}
+	if *mockService.filter != expectedFilter {
+		t.Errorf("Unexpected filter %+v", mockService.filter)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "text/csv; charset=utf-8" {
+		t.Errorf("Expected CSV, got %s", contentType)
+	}
+	if disposition := w.Header().Get("Content-Disposition"); disposition != `attachment; filename="maze_device_status.csv"` {
+		t.Errorf("Unexpected Content-Disposition %s", disposition)
+	}
+	expected := "id,device_id,alarm_active,maze_completed,hall_sensor_value,battery_level,timestamp,version\n" +
+		"1,ARD001,true,false,false,85,2024-01-15T10:30:00Z,1\n"
+	if w.Body.String() != expected {
+		t.Errorf("Unexpected body:\n%s", w.Body.String())
+	}
+}
+
+func TestGetHandlerStreamsNDJSON(t *testing.T) {
+	mockService := &mockExportService{data: []*models.Data{
+		{ID: 1, DeviceID: "ARD001", Value: 21.5, Type: "temperature", DateTime: "2024-01-15T10:30:00Z", Version: 1},
+		{ID: 2, DeviceID: "ARD001", Value: 22, Type: "temperature", DateTime: "2024-01-15T10:31:00Z", Version: 1},
+	}}
+
+	req := httptest.NewRequest(http.MethodGet, "/export/data", nil)
+	req.SetPathValue("table", "data")
+	req.Header.Set("Accept", "application/x-ndjson")
+	w := httptest.NewRecorder()
+
+	GetHandler(w, req, slog.Default(), mockService)
+
+	if contentType := w.Header().Get("Content-Type"); w.Code != http.StatusOK || contentType != "application/x-ndjson" {
+		t.Fatalf("Expected status 200 with NDJSON, got %d with %s", w.Code, contentType)
+	}
+	expected := `{"id":1,"device_id":"ARD001","device_name":"","value":21.5,"type":"temperature","date_time":"2024-01-15T10:30:00Z","description":"","version":1}` + "\n" +
+		`{"id":2,"device_id":"ARD001","device_name":"","value":22,"type":"temperature","date_time":"2024-01-15T10:31:00Z","description":"","version":1}` + "\n"
+	if w.Body.String() != expected {
+		t.Errorf("Unexpected body:\n%s", w.Body.String())
+	}
+}
+
+func TestGetHandlerErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		table    string
+		err      error
+		expected int
+	}{
+		{name: "Unknown table", table: "users", expected: http.StatusNotFound},
+		{name: "Invalid filter", table: "data", err: service.ExportError{Message: "since and until must be RFC3339 timestamps."}, expected: http.StatusBadRequest},
+		{name: "Database error", table: "data", err: errors.New("database is locked"), expected: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/export/"+tt.table, nil)
+			req.SetPathValue("table", tt.table)
+			w := httptest.NewRecorder()
+
+			GetHandler(w, req, slog.Default(), &mockExportService{err: tt.err})
+
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+			if contentType := w.Header().Get("Content-Type"); contentType != "application/problem+json" {
+				t.Errorf("Expected a problem, got %s", contentType)
+			}
+		})
+	}
+}
+
+func TestGetHandlerEmptyExport(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/export/data", nil)
+	req.SetPathValue("table", "data")
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	GetHandler(w, req, slog.Default(), &mockExportService{})
+
+	if w.Code != http.StatusOK || w.Body.String() != "[]\n" {
+		t.Errorf("Expected an empty array, got %d: %s", w.Code, w.Body.String())
+	}
+}
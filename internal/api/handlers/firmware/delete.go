@@ -0,0 +1,36 @@
+package firmware
+
+import (
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	"goapi/internal/api/service/firmware"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// DeleteHandler handles DELETE requests to remove a firmware release and its binary
+// curl -X DELETE http://127.0.0.1:8080/v1/firmware/1 -u admin:password
+func DeleteHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, service firmware.FirmwareService) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid ID format.")
+		return
+	}
+
+	ctx := r.Context()
+
+	rowsAffected, err := service.Delete(&models.FirmwareRelease{ID: id}, ctx)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Error deleting firmware release", "error", err, "id", id)
+		problem.InternalError(w, r)
+		return
+	}
+
+	if rowsAffected == 0 {
+		problem.Write(w, r, http.StatusNotFound, "Firmware release not found.")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
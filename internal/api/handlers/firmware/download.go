@@ -0,0 +1,72 @@
+package firmware
+
+import (
+	"goapi/internal/api/problem"
+	"goapi/internal/api/service/firmware"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DownloadHandler handles GET requests to download a firmware binary.
+// Range requests are supported, so devices can resume an interrupted download.
+// Devices may only download releases they were offered for their hardware, admins may download any release.
+// curl -X GET http://127.0.0.1:8080/v1/firmware/1/download -u admin:password -H "Range: bytes=0-1023" -o part.bin
+func DownloadHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, service firmware.FirmwareService) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid ID format.")
+		return
+	}
+
+	ctx := r.Context()
+
+	release, err := service.ReadOne(id, ctx)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Error reading firmware release", "error", err, "id", id)
+		problem.InternalError(w, r)
+		return
+	}
+	// * Devices only see releases their rollout targets, so the staged rollout cannot be skipped by guessing IDs *
+	deviceID := requestDeviceID(r, "")
+	if release == nil || (deviceID != "" && !service.Targets(release, deviceID)) {
+		problem.Write(w, r, http.StatusNotFound, "Firmware release not found.")
+		return
+	}
+	// Record that the device started downloading, admins downloading a binary are not part of the rollout
+	if deviceID != "" {
+		offered, err := service.StartDownload(release, deviceID, fromStart(r), ctx)
+		if err != nil {
+			logger.ErrorContext(r.Context(), "Error recording firmware download", "error", err, "device_id", deviceID)
+			problem.InternalError(w, r)
+			return
+		}
+		if !offered {
+			problem.Write(w, r, http.StatusNotFound, "Firmware release not found.")
+			return
+		}
+	}
+
+	file, err := service.Open(release)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Error opening firmware binary", "error", err, "path", release.FilePath)
+		problem.InternalError(w, r)
+		return
+	}
+	defer file.Close()
+
+	modTime, _ := time.Parse(time.RFC3339, release.CreatedAt)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("ETag", `"`+release.Checksum+`"`)
+	w.Header().Set("X-Checksum-SHA256", release.Checksum)
+	http.ServeContent(w, r, release.Hardware+"-"+release.Version+".bin", modTime, file)
+}
+
+// fromStart reports whether r downloads the binary from its first byte, resuming with a later Range does not
+// start a new download
+func fromStart(r *http.Request) bool {
+	rangeHeader := r.Header.Get("Range")
+	return rangeHeader == "" || strings.HasPrefix(rangeHeader, "bytes=0-")
+}
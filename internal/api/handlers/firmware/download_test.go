@@ -0,0 +1,79 @@
+package firmware
+
+import (
+	"context"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/repository/models"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDownloadHandler(t *testing.T) {
+	binary := filepath.Join(t.TempDir(), "esp32-1.1.0.bin")
+	if err := os.WriteFile(binary, []byte("firmware"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		identity       *middleware.Identity
+		rangeHeader    string
+		expectedStatus int
+		expectStart    bool // Whether the download counts as started from the first byte
+	}{
+		{name: "Targeted device", identity: &middleware.Identity{Username: "ESP32_MAZE_001", DeviceID: "ESP32_MAZE_001"}, expectedStatus: http.StatusOK, expectStart: true},
+		{name: "Range from the start", identity: &middleware.Identity{Username: "ESP32_MAZE_001", DeviceID: "ESP32_MAZE_001"}, rangeHeader: "bytes=0-3", expectedStatus: http.StatusPartialContent, expectStart: true},
+		{name: "Resumed download", identity: &middleware.Identity{Username: "ESP32_MAZE_001", DeviceID: "ESP32_MAZE_001"}, rangeHeader: "bytes=4-", expectedStatus: http.StatusPartialContent},
+		{name: "Device never offered the release", identity: &middleware.Identity{Username: "ESP32_MAZE_003", DeviceID: "ESP32_MAZE_003"}, expectedStatus: http.StatusNotFound},
+		{name: "Device outside the rollout", identity: &middleware.Identity{Username: "ESP32_MAZE_002", DeviceID: "ESP32_MAZE_002"}, expectedStatus: http.StatusNotFound},
+		{name: "Admin", identity: &middleware.Identity{Username: "admin"}, expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started := false
+			mockService := &mockFirmwareService{
+				readOneFunc: func(id int, ctx context.Context) (*models.FirmwareRelease, error) {
+					return &models.FirmwareRelease{ID: id, Version: "1.1.0", Hardware: "esp32", Allowlist: []string{"ESP32_MAZE_001", "ESP32_MAZE_003"}, FilePath: binary}, nil
+				},
+				targetsFunc: func(release *models.FirmwareRelease, deviceID string) bool {
+					return deviceID != "ESP32_MAZE_002"
+				},
+				startDownloadFunc: func(release *models.FirmwareRelease, deviceID string, fromStart bool, ctx context.Context) (bool, error) {
+					if deviceID != "ESP32_MAZE_001" {
+						return false, nil
+					}
+					started = fromStart
+					return true, nil
+				},
+				openFunc: func(release *models.FirmwareRelease) (*os.File, error) {
+					return os.Open(release.FilePath)
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/firmware/3/download", nil)
+			req.SetPathValue("id", "3")
+			if tt.rangeHeader != "" {
+				req.Header.Set("Range", tt.rangeHeader)
+			}
+			req = req.WithContext(middleware.WithIdentity(req.Context(), *tt.identity))
+			w := httptest.NewRecorder()
+
+			DownloadHandler(w, req, slog.Default(), mockService)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if tt.expectedStatus == http.StatusOK && w.Body.String() != "firmware" {
+				t.Errorf("Expected the binary, got %q", w.Body.String())
+			}
+			if started != tt.expectStart {
+				t.Errorf("Expected download started %v, got %v", tt.expectStart, started)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package firmware
+
+import (
+	"encoding/json"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/service/firmware"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// GetHandler handles GET requests to list firmware releases, newest first
+// Supports pagination: GET /v1/firmware?page=1&rows_per_page=10
+// curl -X GET "http://127.0.0.1:8080/v1/firmware?page=1&rows_per_page=10" -u admin:password
+func GetHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, service firmware.FirmwareService) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	rowsPerPage, _ := strconv.Atoi(r.URL.Query().Get("rows_per_page"))
+
+	ctx := r.Context()
+
+	releases, err := service.ReadMany(page, rowsPerPage, ctx)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Error reading firmware releases", "error", err)
+		problem.InternalError(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(releases); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding firmware releases", "error", err)
+		problem.InternalError(w, r)
+		return
+	}
+}
@@ -0,0 +1,41 @@
+package firmware
+
+import (
+	"encoding/json"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/service/firmware"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// GetByIDHandler handles GET requests to retrieve a specific firmware release by ID
+// curl -X GET http://127.0.0.1:8080/v1/firmware/1 -u admin:password
+func GetByIDHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, service firmware.FirmwareService) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid ID format.")
+		return
+	}
+
+	ctx := r.Context()
+
+	release, err := service.ReadOne(id, ctx)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Error reading firmware release", "error", err)
+		problem.InternalError(w, r)
+		return
+	}
+
+	if release == nil {
+		problem.Write(w, r, http.StatusNotFound, "Firmware release not found.")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(release); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding firmware release", "error", err)
+		problem.InternalError(w, r)
+		return
+	}
+}
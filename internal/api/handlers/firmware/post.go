@@ -0,0 +1,46 @@
+package firmware
+
+import (
+	"encoding/json"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/service/firmware"
+	"log/slog"
+	"net/http"
+)
+
+// PostHandler handles POST requests to upload a new firmware release, the binary is sent base64 encoded in "data"
+// curl -X POST http://127.0.0.1:8080/v1/firmware -u admin:password -H "Content-Type: application/json" -d "{\"version\":\"1.1.0\",\"hardware\":\"esp32\",\"data\":\"$(base64 -w0 firmware.bin)\"}"
+func PostHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, service firmware.FirmwareService) {
+	var upload firmware.FirmwareUpload
+
+	// Decode the JSON payload from the request body
+	if err := json.NewDecoder(r.Body).Decode(&upload); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request data. Please check your input.")
+		return
+	}
+
+	ctx := r.Context()
+
+	release, err := service.Create(&upload, ctx)
+	if err != nil {
+		switch err.(type) {
+		case firmware.FirmwareError:
+			// Client error: validation failed or release already exists
+			problem.Write(w, r, http.StatusBadRequest, err.Error())
+			return
+		default:
+			// Server error
+			logger.ErrorContext(r.Context(), "Error creating firmware release", "error", err, "hardware", upload.Hardware, "version", upload.Version)
+			problem.InternalError(w, r)
+			return
+		}
+	}
+
+	// Return the created release with 201 Created
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(release); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding firmware release", "error", err)
+		problem.InternalError(w, r)
+		return
+	}
+}
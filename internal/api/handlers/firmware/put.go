@@ -0,0 +1,69 @@
+package firmware
+
+import (
+	"encoding/json"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	"goapi/internal/api/service/firmware"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// RolloutPolicy is the payload used to change which devices are offered a release
+type RolloutPolicy struct {
+	RolloutPercentage int      `json:"rollout_percentage"`
+	Allowlist         []string `json:"allowlist"`
+}
+
+// PutHandler handles PUT requests to replace the rollout policy of a firmware release
+// curl -X PUT http://127.0.0.1:8080/v1/firmware/1/rollout -u admin:password -H "Content-Type: application/json" -d '{"rollout_percentage":25,"allowlist":["ESP32_MAZE_001"]}'
+func PutHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, service firmware.FirmwareService) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid ID format.")
+		return
+	}
+
+	var policy RolloutPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request data. Please check your input.")
+		return
+	}
+
+	ctx := r.Context()
+
+	release := models.FirmwareRelease{ID: id, RolloutPercentage: policy.RolloutPercentage, Allowlist: policy.Allowlist}
+	rowsAffected, err := service.UpdatePolicy(&release, ctx)
+	if err != nil {
+		switch err.(type) {
+		case firmware.FirmwareError:
+			problem.Write(w, r, http.StatusBadRequest, err.Error())
+			return
+		default:
+			logger.ErrorContext(r.Context(), "Error updating firmware rollout policy", "error", err, "id", id)
+			problem.InternalError(w, r)
+			return
+		}
+	}
+
+	if rowsAffected == 0 {
+		problem.Write(w, r, http.StatusNotFound, "Firmware release not found.")
+		return
+	}
+
+	// Return the full release so the caller sees the stored policy
+	updated, err := service.ReadOne(id, ctx)
+	if err != nil || updated == nil {
+		logger.ErrorContext(r.Context(), "Error reading firmware release", "error", err, "id", id)
+		problem.InternalError(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(updated); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding firmware release", "error", err)
+		problem.InternalError(w, r)
+		return
+	}
+}
@@ -0,0 +1,72 @@
+package firmware
+
+import (
+	"encoding/json"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	"goapi/internal/api/service/firmware"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// RolloutHandler handles POST requests from devices reporting the outcome of an update
+// curl -X POST http://127.0.0.1:8080/v1/firmware/rollouts -u ESP32_MAZE_002:password -H "Content-Type: application/json" -d '{"release_id":1,"status":"installed"}'
+func RolloutHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, service firmware.FirmwareService) {
+	var rollout models.FirmwareRollout
+
+	if err := json.NewDecoder(r.Body).Decode(&rollout); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request data. Please check your input.")
+		return
+	}
+
+	// Devices can only report for themselves
+	rollout.DeviceID = requestDeviceID(r, rollout.DeviceID)
+
+	ctx := r.Context()
+
+	if err := service.ReportRollout(&rollout, ctx); err != nil {
+		switch err.(type) {
+		case firmware.FirmwareError:
+			problem.Write(w, r, http.StatusBadRequest, err.Error())
+			return
+		default:
+			logger.ErrorContext(r.Context(), "Error recording firmware rollout", "error", err, "rollout", rollout)
+			problem.InternalError(w, r)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(rollout); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding firmware rollout", "error", err)
+		problem.InternalError(w, r)
+		return
+	}
+}
+
+// GetRolloutsHandler handles GET requests listing the rollout state of a release per device
+// curl -X GET http://127.0.0.1:8080/v1/firmware/1/rollouts -u admin:password
+func GetRolloutsHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, service firmware.FirmwareService) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid ID format.")
+		return
+	}
+
+	ctx := r.Context()
+
+	rollouts, err := service.ReadRollouts(id, ctx)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Error reading firmware rollouts", "error", err, "id", id)
+		problem.InternalError(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(rollouts); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding firmware rollouts", "error", err)
+		problem.InternalError(w, r)
+		return
+	}
+}
@@ -0,0 +1,76 @@
+package firmware
+
+import (
+	"encoding/json"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	"goapi/internal/api/service/firmware"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// UpdateResponse tells a device which release to install and where to download it
+type UpdateResponse struct {
+	*models.FirmwareRelease
+	DownloadURL string `json:"download_url"`
+}
+
+// UpdateHandler handles GET requests from devices asking whether an update exists for their version.
+// Responds 200 with the release to install, or 204 No Content when the device is up to date.
+// Devices are identified by their credentials, admins may pass device_id to preview a device's answer,
+// which is not recorded as offered to the device.
+// curl -X GET "http://127.0.0.1:8080/v1/firmware/update?hardware=esp32&version=1.0.0" -u ESP32_MAZE_002:password
+func UpdateHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, service firmware.FirmwareService) {
+	query := r.URL.Query()
+	deviceID := requestDeviceID(r, "")
+
+	ctx := r.Context()
+
+	var release *models.FirmwareRelease
+	var err error
+	if deviceID != "" {
+		release, err = service.CheckUpdate(deviceID, query.Get("hardware"), query.Get("version"), ctx)
+	} else {
+		deviceID = query.Get("device_id")
+		release, err = service.PreviewUpdate(deviceID, query.Get("hardware"), query.Get("version"), ctx)
+	}
+	if err != nil {
+		switch err.(type) {
+		case firmware.FirmwareError:
+			problem.Write(w, r, http.StatusBadRequest, err.Error())
+			return
+		default:
+			logger.ErrorContext(r.Context(), "Error checking firmware update", "error", err, "device_id", deviceID)
+			problem.InternalError(w, r)
+			return
+		}
+	}
+
+	if release == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// * The download URL keeps the version prefix of the request, devices on the unversioned routes stay on them *
+	w.WriteHeader(http.StatusOK)
+	response := UpdateResponse{
+		FirmwareRelease: release,
+		DownloadURL:     strings.TrimSuffix(r.URL.Path, "/update") + "/" + strconv.Itoa(release.ID) + "/download",
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding firmware update", "error", err)
+		problem.InternalError(w, r)
+		return
+	}
+}
+
+// requestDeviceID returns the device ID of a device caller, for other callers the given fallback
+func requestDeviceID(r *http.Request, fallback string) string {
+	if identity, ok := middleware.IdentityFromContext(r.Context()); ok && identity.DeviceID != "" {
+		return identity.DeviceID
+	}
+	return fallback
+}
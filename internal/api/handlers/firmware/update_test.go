@@ -0,0 +1,218 @@
+package firmware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/repository/models"
+	"goapi/internal/api/service/firmware"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// Mock service for testing
+type mockFirmwareService struct {
+	readOneFunc       func(int, context.Context) (*models.FirmwareRelease, error)
+	checkUpdateFunc   func(string, string, string, context.Context) (*models.FirmwareRelease, error)
+	previewUpdateFunc func(string, string, string, context.Context) (*models.FirmwareRelease, error)
+	targetsFunc       func(*models.FirmwareRelease, string) bool
+	startDownloadFunc func(*models.FirmwareRelease, string, bool, context.Context) (bool, error)
+	openFunc          func(*models.FirmwareRelease) (*os.File, error)
+	reportRolloutFunc func(*models.FirmwareRollout, context.Context) error
+}
+
+func (m *mockFirmwareService) Create(upload *firmware.FirmwareUpload, ctx context.Context) (*models.FirmwareRelease, error) {
+	return nil, nil
+}
+
+func (m *mockFirmwareService) ReadOne(id int, ctx context.Context) (*models.FirmwareRelease, error) {
+	if m.readOneFunc != nil {
+		return m.readOneFunc(id, ctx)
+	}
+	return nil, nil
+}
+
+func (m *mockFirmwareService) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.FirmwareRelease, error) {
+	return nil, nil
+}
+
+func (m *mockFirmwareService) UpdatePolicy(release *models.FirmwareRelease, ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockFirmwareService) Delete(release *models.FirmwareRelease, ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockFirmwareService) CheckUpdate(deviceID string, hardware string, currentVersion string, ctx context.Context) (*models.FirmwareRelease, error) {
+	return m.checkUpdateFunc(deviceID, hardware, currentVersion, ctx)
+}
+
+func (m *mockFirmwareService) PreviewUpdate(deviceID string, hardware string, currentVersion string, ctx context.Context) (*models.FirmwareRelease, error) {
+	return m.previewUpdateFunc(deviceID, hardware, currentVersion, ctx)
+}
+
+func (m *mockFirmwareService) Targets(release *models.FirmwareRelease, deviceID string) bool {
+	return m.targetsFunc(release, deviceID)
+}
+
+func (m *mockFirmwareService) StartDownload(release *models.FirmwareRelease, deviceID string, fromStart bool, ctx context.Context) (bool, error) {
+	return m.startDownloadFunc(release, deviceID, fromStart, ctx)
+}
+
+func (m *mockFirmwareService) Open(release *models.FirmwareRelease) (*os.File, error) {
+	if m.openFunc != nil {
+		return m.openFunc(release)
+	}
+	return nil, os.ErrNotExist
+}
+
+func (m *mockFirmwareService) ReportRollout(rollout *models.FirmwareRollout, ctx context.Context) error {
+	return m.reportRolloutFunc(rollout, ctx)
+}
+
+func (m *mockFirmwareService) ReadRollouts(releaseID int, ctx context.Context) ([]*models.FirmwareRollout, error) {
+	return nil, nil
+}
+
+func TestUpdateHandlerUsesDeviceIdentity(t *testing.T) {
+	logger := slog.Default()
+	mockService := &mockFirmwareService{
+		checkUpdateFunc: func(deviceID, hardware, version string, ctx context.Context) (*models.FirmwareRelease, error) {
+			if deviceID != "ESP32_MAZE_002" || hardware != "esp32" || version != "1.0.0" {
+				t.Errorf("Unexpected update check: %s %s %s", deviceID, hardware, version)
+			}
+			return &models.FirmwareRelease{ID: 3, Version: "1.1.0", Hardware: "esp32"}, nil
+		},
+	}
+
+	// A device cannot ask on behalf of another device
+	req := httptest.NewRequest(http.MethodGet, "/firmware/update?hardware=esp32&version=1.0.0&device_id=OTHER", nil)
+	req = req.WithContext(middleware.WithIdentity(req.Context(), middleware.Identity{Username: "ESP32_MAZE_002", DeviceID: "ESP32_MAZE_002"}))
+	w := httptest.NewRecorder()
+
+	UpdateHandler(w, req, logger, mockService)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response struct {
+		Version     string `json:"version"`
+		DownloadURL string `json:"download_url"`
+	}
+	json.NewDecoder(w.Body).Decode(&response)
+	if response.Version != "1.1.0" || response.DownloadURL != "/firmware/3/download" {
+		t.Errorf("Unexpected update response: %+v", response)
+	}
+}
+
+func TestUpdateHandlerAdminPreview(t *testing.T) {
+	logger := slog.Default()
+	mockService := &mockFirmwareService{
+		checkUpdateFunc: func(deviceID, hardware, version string, ctx context.Context) (*models.FirmwareRelease, error) {
+			t.Error("Expected a preview not to record the release as offered")
+			return nil, nil
+		},
+		previewUpdateFunc: func(deviceID, hardware, version string, ctx context.Context) (*models.FirmwareRelease, error) {
+			if deviceID != "ESP32_MAZE_002" {
+				t.Errorf("Expected a preview for ESP32_MAZE_002, got %s", deviceID)
+			}
+			return &models.FirmwareRelease{ID: 3, Version: "1.1.0", Hardware: "esp32"}, nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/firmware/update?hardware=esp32&version=1.0.0&device_id=ESP32_MAZE_002", nil)
+	req = req.WithContext(middleware.WithIdentity(req.Context(), middleware.Identity{Username: "admin"}))
+	w := httptest.NewRecorder()
+
+	UpdateHandler(w, req, logger, mockService)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+}
+
+func TestUpdateHandlerKeepsVersionPrefix(t *testing.T) {
+	logger := slog.Default()
+	mockService := &mockFirmwareService{
+		previewUpdateFunc: func(deviceID, hardware, version string, ctx context.Context) (*models.FirmwareRelease, error) {
+			return &models.FirmwareRelease{ID: 3, Version: "1.1.0", Hardware: "esp32"}, nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/firmware/update?hardware=esp32&version=1.0.0&device_id=ESP32_MAZE_002", nil)
+	w := httptest.NewRecorder()
+
+	UpdateHandler(w, req, logger, mockService)
+
+	var response struct {
+		DownloadURL string `json:"download_url"`
+	}
+	json.NewDecoder(w.Body).Decode(&response)
+	if response.DownloadURL != "/v1/firmware/3/download" {
+		t.Errorf("Expected download URL /v1/firmware/3/download, got %q", response.DownloadURL)
+	}
+}
+
+func TestUpdateHandlerUpToDate(t *testing.T) {
+	logger := slog.Default()
+	mockService := &mockFirmwareService{
+		previewUpdateFunc: func(deviceID, hardware, version string, ctx context.Context) (*models.FirmwareRelease, error) {
+			return nil, nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/firmware/update?hardware=esp32&version=1.1.0&device_id=ESP32_MAZE_002", nil)
+	w := httptest.NewRecorder()
+
+	UpdateHandler(w, req, logger, mockService)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", w.Code)
+	}
+}
+
+func TestUpdateHandlerValidationError(t *testing.T) {
+	logger := slog.Default()
+	mockService := &mockFirmwareService{
+		previewUpdateFunc: func(deviceID, hardware, version string, ctx context.Context) (*models.FirmwareRelease, error) {
+			return nil, firmware.FirmwareError{Message: "version must be in MAJOR.MINOR.PATCH format."}
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/firmware/update?hardware=esp32&version=latest&device_id=ESP32_MAZE_002", nil)
+	w := httptest.NewRecorder()
+
+	UpdateHandler(w, req, logger, mockService)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestRolloutHandlerReportsForCaller(t *testing.T) {
+	logger := slog.Default()
+	mockService := &mockFirmwareService{
+		reportRolloutFunc: func(rollout *models.FirmwareRollout, ctx context.Context) error {
+			if rollout.DeviceID != "ESP32_MAZE_002" || rollout.Status != models.RolloutInstalled {
+				t.Errorf("Unexpected rollout report: %+v", rollout)
+			}
+			return nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/firmware/rollouts", bytes.NewBufferString(`{"release_id":3,"device_id":"OTHER","status":"installed"}`))
+	req = req.WithContext(middleware.WithIdentity(req.Context(), middleware.Identity{Username: "ESP32_MAZE_002", DeviceID: "ESP32_MAZE_002"}))
+	w := httptest.NewRecorder()
+
+	RolloutHandler(w, req, logger, mockService)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+}
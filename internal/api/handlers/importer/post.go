@@ -0,0 +1,100 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/importer"
+	"goapi/internal/api/tabular"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+// PostHandler handles POST requests loading rows into a table, e.g. months of readings exported from another backend.
+// Rows are sent as CSV with a header naming the columns, or as NDJSON with one JSON object per line.
+// Supports a dry run that validates every row and stores none, and the number of rows stored per transaction:
+// POST /v1/import/maze_device_status?dry_run=true&chunk_size=500
+// curl -X POST "http://127.0.0.1:8080/v1/import/maze_device_status?dry_run=true" -u admin:password -H "Content-Type: text/csv" --data-binary @maze_device_status.csv
+func PostHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, is service.ImportService) {
+	table := r.PathValue("table")
+	query := r.URL.Query()
+
+	var importRows func(body io.Reader, format string, options *models.ImportOptions, ctx context.Context) (*models.ImportReport, error)
+	switch table {
+	case models.ImportMazeDeviceStatus:
+		importRows = is.ImportStatuses
+	case models.ImportDeviceConfig:
+		importRows = is.ImportConfigs
+	case models.ImportData:
+		importRows = is.ImportData
+	default:
+		problem.Write(w, r, http.StatusNotFound, "Unknown table, import "+models.ImportMazeDeviceStatus+", "+models.ImportDeviceConfig+" or "+models.ImportData+".")
+		return
+	}
+
+	format, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if format != tabular.CSV && format != tabular.NDJSON {
+		problem.Write(w, r, http.StatusUnsupportedMediaType, "Content-Type header should be set to: "+tabular.CSV+" or "+tabular.NDJSON+".")
+		return
+	}
+
+	// * Options default to storing the rows in chunks of models.DefaultImportChunkSize
+	options := &models.ImportOptions{}
+	var fields models.FieldErrors
+	if value := query.Get("dry_run"); value != "" {
+		dryRun, err := strconv.ParseBool(value)
+		if err != nil {
+			fields.Add("dry_run", "must be true or false")
+		}
+		options.DryRun = dryRun
+	}
+	if value := query.Get("chunk_size"); value != "" {
+		chunkSize, err := strconv.Atoi(value)
+		if err != nil {
+			fields.Add("chunk_size", "must be an integer")
+		}
+		options.ChunkSize = chunkSize
+	}
+	if len(fields) > 0 {
+		problem.Invalid(w, r, "Invalid import options.", fields)
+		return
+	}
+
+	ctx := r.Context()
+
+	report, err := importRows(r.Body, format, options, ctx)
+	if err != nil {
+		// * Chunks committed before the error stay stored, importing the file again reports them as duplicates
+		var stored string
+		if report.Chunks > 0 {
+			stored = " " + strconv.Itoa(report.Accepted) + " rows were stored before, they are skipped when the file is imported again."
+		}
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			problem.Write(w, r, http.StatusRequestEntityTooLarge, "Request body too large, the limit is "+strconv.FormatInt(tooLarge.Limit, 10)+" bytes."+stored)
+			return
+		}
+		switch err.(type) {
+		case service.ImportError:
+			// Client error: unreadable header, no rows or invalid chunk size
+			problem.Write(w, r, http.StatusBadRequest, err.Error())
+		default:
+			// Server error
+			logger.ErrorContext(r.Context(), "Error importing table", "error", err, "table", table, "rows", report.Rows, "chunks", report.Chunks)
+			problem.Write(w, r, http.StatusInternalServerError, "Internal server error."+stored)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding import report", "error", err)
+		problem.InternalError(w, r)
+		return
+	}
+}
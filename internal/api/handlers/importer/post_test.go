@@ -0,0 +1,128 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	service "goapi/internal/api/service/importer"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Mock service reading the whole body and returning a fixed report
+type mockImportService struct {
+	table   string
+	format  string
+	body    string
+	options *models.ImportOptions
+	report  models.ImportReport
+	err     error
+}
+
+func (m *mockImportService) importRows(table string, body io.Reader, format string, options *models.ImportOptions) (*models.ImportReport, error) {
+	read, err := io.ReadAll(body)
+	if err != nil {
+		return &m.report, err
+	}
+	m.table, m.format, m.body, m.options = table, format, string(read), options
+	return &m.report, m.err
+}
+
+func (m *mockImportService) ImportStatuses(body io.Reader, format string, options *models.ImportOptions, ctx context.Context) (*models.ImportReport, error) {
+	return m.importRows(models.ImportMazeDeviceStatus, body, format, options)
+}
+
+func (m *mockImportService) ImportConfigs(body io.Reader, format string, options *models.ImportOptions, ctx context.Context) (*models.ImportReport, error) {
+	return m.importRows(models.ImportDeviceConfig, body, format, options)
+}
+
+func (m *mockImportService) ImportData(body io.Reader, format string, options *models.ImportOptions, ctx context.Context) (*models.ImportReport, error) {
+	return m.importRows(models.ImportData, body, format, options)
+}
+
+func TestPostHandler(t *testing.T) {
+	mockService := &mockImportService{report: models.ImportReport{DryRun: true, Rows: 2, Accepted: 1, Rejected: 1, Errors: []models.ImportRowError{
+		{Line: 3, Error: "Invalid device config.", Errors: models.FieldErrors{{Field: "sensitivity_level", Message: "must be between 1 and 10"}}},
+	}}}
+	body := "device_id,alarm_timeout,sensitivity_level,updated_at\nARD001,30,5,2024-01-15T10:30:00Z\nARD002,30,11,2024-01-15T10:30:00Z\n"
+
+	req := httptest.NewRequest(http.MethodPost, "/import/device_config?dry_run=true&chunk_size=100", strings.NewReader(body))
+	req.SetPathValue("table", "device_config")
+	req.Header.Set("Content-Type", "text/csv; charset=utf-8")
+	w := httptest.NewRecorder()
+
+	PostHandler(w, req, slog.Default(), mockService)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if mockService.table != models.ImportDeviceConfig || mockService.format != "text/csv" || mockService.body != body {
+		t.Errorf("Unexpected import of %s as %s", mockService.table, mockService.format)
+	}
+	if *mockService.options != (models.ImportOptions{DryRun: true, ChunkSize: 100}) {
+		t.Errorf("Unexpected options %+v", mockService.options)
+	}
+	var report models.ImportReport
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Rejected != 1 || len(report.Errors) != 1 || report.Errors[0].Line != 3 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+}
+
+func TestPostHandlerErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		table       string
+		query       string
+		contentType string
+		report      models.ImportReport
+		err         error
+		expected    int
+		detail      string
+	}{
+		{name: "Unknown table", table: "users", contentType: "text/csv", expected: http.StatusNotFound},
+		{name: "JSON body", table: "data", contentType: "application/json", expected: http.StatusUnsupportedMediaType},
+		{name: "Invalid dry run", table: "data", query: "?dry_run=maybe", contentType: "text/csv", expected: http.StatusBadRequest},
+		{name: "Invalid chunk size", table: "data", query: "?chunk_size=many", contentType: "application/x-ndjson", expected: http.StatusBadRequest},
+		{name: "Invalid header", table: "data", contentType: "text/csv", err: service.ImportError{Message: "Invalid CSV header, line 1: colour is not a known column."}, expected: http.StatusBadRequest},
+		{
+			name: "Too large", table: "data", contentType: "text/csv",
+			report: models.ImportReport{Rows: 1500, Accepted: 1000, Chunks: 2}, err: &http.MaxBytesError{Limit: 1024},
+			expected: http.StatusRequestEntityTooLarge,
+			detail:   "Request body too large, the limit is 1024 bytes. 1000 rows were stored before, they are skipped when the file is imported again.",
+		},
+		{
+			name: "Database error", table: "maze_device_status", contentType: "text/csv",
+			err: errors.New("database is locked"), expected: http.StatusInternalServerError, detail: "Internal server error.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/import/"+tt.table+tt.query, strings.NewReader("device_id\nARD001\n"))
+			req.SetPathValue("table", tt.table)
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+
+			PostHandler(w, req, slog.Default(), &mockImportService{report: tt.report, err: tt.err})
+
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+			if contentType := w.Header().Get("Content-Type"); contentType != "application/problem+json" {
+				t.Errorf("Expected a problem, got %s", contentType)
+			}
+			if detail := problem.Detail(w.Body.Bytes()); tt.detail != "" && detail != tt.detail {
+				t.Errorf("Unexpected detail %q", detail)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package lockout
+
+import (
+	"encoding/json"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/service/lockout"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// GetHandler handles GET requests to list the lockouts caused by failed logins, newest first
+// Supports pagination: GET /v1/auth/lockouts?page=1&rows_per_page=10
+// curl -X GET "http://127.0.0.1:8080/v1/auth/lockouts?page=1&rows_per_page=10" -u admin:password
+func GetHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, service lockout.LockoutService) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	rowsPerPage, _ := strconv.Atoi(r.URL.Query().Get("rows_per_page"))
+
+	ctx := r.Context()
+
+	lockouts, err := service.ReadMany(page, rowsPerPage, ctx)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Error reading lockouts", "error", err)
+		problem.InternalError(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(lockouts); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding lockouts", "error", err)
+		problem.InternalError(w, r)
+		return
+	}
+}
@@ -0,0 +1,88 @@
+package lockout
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"goapi/internal/api/repository/models"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Mock service for GET testing
+type mockLockoutService struct {
+	readManyFunc func(int, int, context.Context) ([]*models.AuthLockout, error)
+}
+
+func (m *mockLockoutService) Locked(username string, ip string) (time.Duration, bool) {
+	return 0, false
+}
+
+func (m *mockLockoutService) Failed(username string, ip string, ctx context.Context) time.Duration {
+	return 0
+}
+
+func (m *mockLockoutService) Succeeded(username string, ip string) {}
+
+func (m *mockLockoutService) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.AuthLockout, error) {
+	if m.readManyFunc != nil {
+		return m.readManyFunc(page, rowsPerPage, ctx)
+	}
+	return nil, nil
+}
+
+func TestGetHandlerSuccess(t *testing.T) {
+	logger := slog.Default()
+
+	mockService := &mockLockoutService{
+		readManyFunc: func(page int, rowsPerPage int, ctx context.Context) ([]*models.AuthLockout, error) {
+			if page != 2 || rowsPerPage != 20 {
+				t.Errorf("Expected page=2, rowsPerPage=20, got page=%d, rowsPerPage=%d", page, rowsPerPage)
+			}
+			return []*models.AuthLockout{
+				{ID: 2, Subject: models.LockoutIP, Value: "192.0.2.1", Failures: 20, RemoteAddr: "192.0.2.1"},
+				{ID: 1, Subject: models.LockoutUsername, Value: "admin", Failures: 5, RemoteAddr: "192.0.2.1"},
+			}, nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/lockouts?page=2&rows_per_page=20", nil)
+	w := httptest.NewRecorder()
+
+	GetHandler(w, req, logger, mockService)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+
+	var response []*models.AuthLockout
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if len(response) != 2 || response[1].Value != "admin" {
+		t.Errorf("Expected 2 lockouts, got %+v", response)
+	}
+}
+
+func TestGetHandlerServiceError(t *testing.T) {
+	logger := slog.Default()
+
+	mockService := &mockLockoutService{
+		readManyFunc: func(page int, rowsPerPage int, ctx context.Context) ([]*models.AuthLockout, error) {
+			return nil, errors.New("database error")
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/lockouts", nil)
+	w := httptest.NewRecorder()
+
+	GetHandler(w, req, logger, mockService)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", w.Code)
+	}
+}
@@ -0,0 +1,55 @@
+package maze_device
+
+import (
+	"encoding/json"
+	"goapi/internal/api/batch"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/service/maze_device"
+	"log/slog"
+	"net/http"
+)
+
+// PostBatchHandler handles POST requests storing many maze device statuses at once, e.g. readings buffered during a WiFi outage.
+// The body is a JSON array or NDJSON, every status gets a result: accepted, duplicate (same device_id and timestamp) or rejected.
+// curl -X POST http://127.0.0.1:8080/v1/device/status/batch -u admin:password -H "Content-Type: application/x-ndjson" --data-binary @statuses.ndjson
+func PostBatchHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, service maze_device.MazeDeviceStatusService) {
+	statuses, err := shapeOf(r).decodeBatch(r.Body)
+	if err != nil {
+		// The decoding error names the malformed item
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request data: "+err.Error())
+		return
+	}
+
+	// Devices may only report their own status
+	identity, _ := middleware.IdentityFromContext(r.Context())
+	for _, status := range statuses {
+		if !identity.CanAccessDevice(status.DeviceID) {
+			problem.Write(w, r, http.StatusForbidden, "Forbidden: Devices may only report their own status.")
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	results, err := service.CreateBatch(statuses, ctx)
+	if err != nil {
+		switch err := err.(type) {
+		case maze_device.MazeDeviceStatusError:
+			problem.Invalid(w, r, err.Message, err.Fields)
+			return
+		default:
+			// Nothing was stored, the transaction was rolled back
+			logger.ErrorContext(r.Context(), "Error creating maze device status batch", "error", err, "count", len(statuses))
+			problem.InternalError(w, r)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(batch.NewResponse(results)); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding batch results", "error", err)
+		problem.InternalError(w, r)
+		return
+	}
+}
@@ -0,0 +1,112 @@
+package maze_device
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"goapi/internal/api/batch"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/repository/models"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Mock service for batch testing, embeds the GET mock for the methods a batch does not use
+type mockMazeDeviceBatchService struct {
+	mockMazeDeviceGetService
+	createBatchFunc func([]*models.MazeDeviceStatus, context.Context) ([]models.BatchResult, error)
+}
+
+func (m *mockMazeDeviceBatchService) CreateBatch(statuses []*models.MazeDeviceStatus, ctx context.Context) ([]models.BatchResult, error) {
+	return m.createBatchFunc(statuses, ctx)
+}
+
+const batchBody = `[
+	{"device_id":"ESP32_001","alarm_active":true,"battery_level":80,"timestamp":"2024-01-15T10:00:00Z"},
+	{"device_id":"ESP32_001","alarm_active":false,"battery_level":80,"timestamp":"2024-01-15T10:00:00Z"},
+	{"device_id":"ESP32_001","alarm_active":false,"battery_level":180,"timestamp":"2024-01-15T10:01:00Z"}
+]`
+
+func TestPostBatchHandlerSuccess(t *testing.T) {
+	logger := slog.Default()
+
+	var received []*models.MazeDeviceStatus
+	mockService := &mockMazeDeviceBatchService{
+		createBatchFunc: func(statuses []*models.MazeDeviceStatus, ctx context.Context) ([]models.BatchResult, error) {
+			received = statuses
+			return []models.BatchResult{
+				{Index: 0, Status: models.BatchAccepted, ID: 7},
+				{Index: 1, Status: models.BatchDuplicate, ID: 7},
+				{Index: 2, Status: models.BatchRejected, Error: "Invalid maze device status: battery_level must be between 0 and 100. "},
+			}, nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/device/status/batch", strings.NewReader(batchBody))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(middleware.WithIdentity(req.Context(), middleware.Identity{Username: "ESP32_001", DeviceID: "ESP32_001"}))
+	w := httptest.NewRecorder()
+
+	PostBatchHandler(w, req, logger, mockService)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(received) != 3 || received[2].BatteryLevel != 180 {
+		t.Errorf("Expected the 3 statuses to reach the service, got %v", received)
+	}
+
+	var response batch.Response
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Accepted != 1 || response.Duplicates != 1 || response.Rejected != 1 {
+		t.Errorf("Expected one result of each kind, got %+v", response)
+	}
+}
+
+func TestPostBatchHandlerErrors(t *testing.T) {
+	logger := slog.Default()
+
+	tests := []struct {
+		name     string
+		body     string
+		identity middleware.Identity
+		err      error
+		code     int
+	}{
+		{name: "Invalid JSON", body: `[{"device_id":`, code: http.StatusBadRequest},
+		{name: "Empty batch", body: "\n", code: http.StatusBadRequest},
+		{name: "Other device", body: batchBody, identity: middleware.Identity{Username: "ESP32_002", DeviceID: "ESP32_002"}, code: http.StatusForbidden},
+		{name: "Internal error", body: batchBody, err: errors.New("database is locked"), code: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			mockService := &mockMazeDeviceBatchService{
+				createBatchFunc: func(statuses []*models.MazeDeviceStatus, ctx context.Context) ([]models.BatchResult, error) {
+					called = true
+					return nil, tt.err
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/device/status/batch", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/x-ndjson")
+			req = req.WithContext(middleware.WithIdentity(req.Context(), tt.identity))
+			w := httptest.NewRecorder()
+
+			PostBatchHandler(w, req, logger, mockService)
+
+			if w.Code != tt.code {
+				t.Errorf("Expected status %d, got %d: %s", tt.code, w.Code, w.Body.String())
+			}
+			if called != (tt.err != nil) {
+				t.Errorf("Expected the service to be called only when the batch is valid and allowed")
+			}
+		})
+	}
+}
@@ -1,42 +1,75 @@
 package maze_device
 
 import (
-	"context"
+	"errors"
+	"goapi/internal/api/etag"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
 	"goapi/internal/api/repository/models"
 	"goapi/internal/api/service/maze_device"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"time"
 )
 
 // DeleteHandler handles DELETE requests to remove a maze device status
-// curl -X DELETE http://127.0.0.1:8080/device/status/1 -u admin:password
-func DeleteHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, service maze_device.MazeDeviceStatusService) {
+// curl -X DELETE http://127.0.0.1:8080/v1/device/status/1 -u admin:password -H 'If-Match: "1"'
+func DeleteHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, service maze_device.MazeDeviceStatusService) {
 	// Extract ID from URL path parameter
 	idStr := r.PathValue("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "Invalid ID format."}`))
+		problem.Write(w, r, http.StatusBadRequest, "Invalid ID format.")
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
-	defer cancel()
+	// If-Match is required and * deletes whatever version is stored
+	version, err := etag.RequireMatch(r.Header.Get("If-Match"))
+	if errors.Is(err, etag.ErrPreconditionRequired) {
+		problem.Write(w, r, http.StatusPreconditionRequired, "Precondition required: If-Match must hold the ETag of the resource.")
+		return
+	}
+	if err != nil {
+		problem.Write(w, r, http.StatusPreconditionFailed, "Precondition failed: If-Match must be a single strong ETag.")
+		return
+	}
+
+	ctx := r.Context()
+
+	// Devices may only delete their own status
+	current, err := service.ReadOne(id, ctx)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Error reading maze device status", "error", err)
+		problem.InternalError(w, r)
+		return
+	}
+	if current == nil {
+		problem.Write(w, r, http.StatusNotFound, "Maze device status not found.")
+		return
+	}
+	if identity, _ := middleware.IdentityFromContext(r.Context()); !identity.CanAccessDevice(current.DeviceID) {
+		problem.Write(w, r, http.StatusForbidden, "Forbidden: Devices may only delete their own status.")
+		return
+	}
 
 	// Delete the status from the database
-	status := &models.MazeDeviceStatus{ID: id}
+	status := &models.MazeDeviceStatus{ID: id, Version: version}
 	rowsAffected, err := service.Delete(status, ctx)
 	if err != nil {
-		logger.Println("Error deleting maze device status:", err)
-		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Error deleting maze device status", "error", err)
+		problem.InternalError(w, r)
 		return
 	}
 
 	if rowsAffected == 0 {
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte(`{"error": "Maze device status not found."}`))
+		if version != 0 {
+			if current, err := service.ReadOne(id, ctx); err == nil && current != nil {
+				w.Header().Set("ETag", etag.Format(current.Version))
+				problem.Write(w, r, http.StatusPreconditionFailed, "Precondition failed: maze device status was modified.")
+				return
+			}
+		}
+		problem.Write(w, r, http.StatusNotFound, "Maze device status not found.")
 		return
 	}
 
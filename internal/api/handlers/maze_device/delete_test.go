@@ -3,32 +3,45 @@ package maze_device
 import (
 	"context"
 	"errors"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
 	"goapi/internal/api/repository/models"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"testing"
 )
 
 // Mock service for delete testing
 type mockMazeDeviceDeleteService struct {
-	deleteFunc func(*models.MazeDeviceStatus, context.Context) (int64, error)
+	deleteFunc  func(*models.MazeDeviceStatus, context.Context) (int64, error)
+	readOneFunc func(int, context.Context) (*models.MazeDeviceStatus, error)
 }
 
 func (m *mockMazeDeviceDeleteService) Create(status *models.MazeDeviceStatus, ctx context.Context) error {
 	return nil
 }
 
-func (m *mockMazeDeviceDeleteService) ReadOne(id int, ctx context.Context) (*models.MazeDeviceStatus, error) {
+func (m *mockMazeDeviceDeleteService) CreateBatch(statuses []*models.MazeDeviceStatus, ctx context.Context) ([]models.BatchResult, error) {
 	return nil, nil
 }
 
-func (m *mockMazeDeviceDeleteService) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.MazeDeviceStatus, error) {
-	return nil, nil
+func (m *mockMazeDeviceDeleteService) ReadOne(id int, ctx context.Context) (*models.MazeDeviceStatus, error) {
+	if m.readOneFunc != nil {
+		return m.readOneFunc(id, ctx)
+	}
+	return &models.MazeDeviceStatus{ID: id, DeviceID: "ESP32_001", Version: 1}, nil
+}
+
+func (m *mockMazeDeviceDeleteService) ReadMany(page int, rowsPerPage int, ctx context.Context) models.Rows[models.MazeDeviceStatus] {
+	return models.RowsOf[models.MazeDeviceStatus](nil, nil)
+}
+
+func (m *mockMazeDeviceDeleteService) ReadByDeviceID(deviceID string, ctx context.Context) models.Rows[models.MazeDeviceStatus] {
+	return models.RowsOf[models.MazeDeviceStatus](nil, nil)
 }
 
-func (m *mockMazeDeviceDeleteService) ReadByDeviceID(deviceID string, ctx context.Context) ([]*models.MazeDeviceStatus, error) {
+func (m *mockMazeDeviceDeleteService) ReadLatest(ctx context.Context) ([]*models.MazeDeviceStatus, error) {
 	return nil, nil
 }
 
@@ -45,11 +58,12 @@ func (m *mockMazeDeviceDeleteService) ValidateStatus(status *models.MazeDeviceSt
 }
 
 func TestDeleteHandlerInvalidID(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 	mockService := &mockMazeDeviceDeleteService{}
 
 	req := httptest.NewRequest(http.MethodDelete, "/device/status/invalid", nil)
 	req.SetPathValue("id", "invalid")
+	req.Header.Set("If-Match", `"1"`)
 	w := httptest.NewRecorder()
 
 	DeleteHandler(w, req, logger, mockService)
@@ -58,14 +72,14 @@ func TestDeleteHandlerInvalidID(t *testing.T) {
 		t.Errorf("Expected status 400, got %d", w.Code)
 	}
 
-	expected := `{"error": "Invalid ID format."}`
-	if w.Body.String() != expected {
+	expected := "Invalid ID format."
+	if problem.Detail(w.Body.Bytes()) != expected {
 		t.Errorf("Expected body %s, got %s", expected, w.Body.String())
 	}
 }
 
 func TestDeleteHandlerInternalError(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 	mockService := &mockMazeDeviceDeleteService{
 		deleteFunc: func(status *models.MazeDeviceStatus, ctx context.Context) (int64, error) {
 			return 0, errors.New("database error")
@@ -74,6 +88,7 @@ func TestDeleteHandlerInternalError(t *testing.T) {
 
 	req := httptest.NewRequest(http.MethodDelete, "/device/status/1", nil)
 	req.SetPathValue("id", "1")
+	req.Header.Set("If-Match", `"1"`)
 	w := httptest.NewRecorder()
 
 	DeleteHandler(w, req, logger, mockService)
@@ -84,7 +99,7 @@ func TestDeleteHandlerInternalError(t *testing.T) {
 }
 
 func TestDeleteHandlerNotFound(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 	mockService := &mockMazeDeviceDeleteService{
 		deleteFunc: func(status *models.MazeDeviceStatus, ctx context.Context) (int64, error) {
 			return 0, nil // 0 rows affected
@@ -93,6 +108,7 @@ func TestDeleteHandlerNotFound(t *testing.T) {
 
 	req := httptest.NewRequest(http.MethodDelete, "/device/status/999", nil)
 	req.SetPathValue("id", "999")
+	req.Header.Set("If-Match", "*")
 	w := httptest.NewRecorder()
 
 	DeleteHandler(w, req, logger, mockService)
@@ -101,14 +117,14 @@ func TestDeleteHandlerNotFound(t *testing.T) {
 		t.Errorf("Expected status 404, got %d", w.Code)
 	}
 
-	expected := `{"error": "Maze device status not found."}`
-	if w.Body.String() != expected {
+	expected := "Maze device status not found."
+	if problem.Detail(w.Body.Bytes()) != expected {
 		t.Errorf("Expected body %s, got %s", expected, w.Body.String())
 	}
 }
 
 func TestDeleteHandlerSuccess(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 	mockService := &mockMazeDeviceDeleteService{
 		deleteFunc: func(status *models.MazeDeviceStatus, ctx context.Context) (int64, error) {
 			return 1, nil // 1 row affected
@@ -117,6 +133,7 @@ func TestDeleteHandlerSuccess(t *testing.T) {
 
 	req := httptest.NewRequest(http.MethodDelete, "/device/status/1", nil)
 	req.SetPathValue("id", "1")
+	req.Header.Set("If-Match", `"1"`)
 	w := httptest.NewRecorder()
 
 	DeleteHandler(w, req, logger, mockService)
@@ -132,7 +149,7 @@ func TestDeleteHandlerSuccess(t *testing.T) {
 }
 
 func TestDeleteHandlerZeroID(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 	mockService := &mockMazeDeviceDeleteService{
 		deleteFunc: func(status *models.MazeDeviceStatus, ctx context.Context) (int64, error) {
 			return 0, nil // Not found
@@ -141,6 +158,7 @@ func TestDeleteHandlerZeroID(t *testing.T) {
 
 	req := httptest.NewRequest(http.MethodDelete, "/device/status/0", nil)
 	req.SetPathValue("id", "0")
+	req.Header.Set("If-Match", "*")
 	w := httptest.NewRecorder()
 
 	DeleteHandler(w, req, logger, mockService)
@@ -151,3 +169,72 @@ func TestDeleteHandlerZeroID(t *testing.T) {
 		t.Errorf("Expected status 404 or 200, got %d", w.Code)
 	}
 }
+
+func TestDeleteHandlerDeviceScope(t *testing.T) {
+	logger := slog.Default()
+	device := middleware.Identity{Username: "ESP32_TEST", DeviceID: "ESP32_TEST"}
+
+	tests := []struct {
+		name    string
+		stored  string
+		code    int
+		deleted bool
+	}{
+		{name: "Own status", stored: "ESP32_TEST", code: http.StatusOK, deleted: true},
+		{name: "Other device's status", stored: "ESP32_OTHER", code: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deleted := false
+			mockService := &mockMazeDeviceDeleteService{
+				readOneFunc: func(id int, ctx context.Context) (*models.MazeDeviceStatus, error) {
+					return &models.MazeDeviceStatus{ID: id, DeviceID: tt.stored, Version: 1}, nil
+				},
+				deleteFunc: func(status *models.MazeDeviceStatus, ctx context.Context) (int64, error) {
+					deleted = true
+					return 1, nil
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodDelete, "/device/status/1", nil)
+			req.SetPathValue("id", "1")
+			req = req.WithContext(middleware.WithIdentity(req.Context(), device))
+			req.Header.Set("If-Match", `"1"`)
+			w := httptest.NewRecorder()
+
+			DeleteHandler(w, req, logger, mockService)
+
+			if w.Code != tt.code {
+				t.Errorf("Expected status %d, got %d", tt.code, w.Code)
+			}
+			if deleted != tt.deleted {
+				t.Errorf("Expected deleted %v, got %v", tt.deleted, deleted)
+			}
+		})
+	}
+}
+
+func TestDeleteHandlerPreconditionRequired(t *testing.T) {
+	logger := slog.Default()
+	deleted := false
+	mockService := &mockMazeDeviceDeleteService{
+		deleteFunc: func(status *models.MazeDeviceStatus, ctx context.Context) (int64, error) {
+			deleted = true
+			return 1, nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/device/status/1", nil)
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	DeleteHandler(w, req, logger, mockService)
+
+	if w.Code != http.StatusPreconditionRequired {
+		t.Errorf("Expected status 428, got %d", w.Code)
+	}
+	if deleted {
+		t.Error("Expected nothing to be deleted without If-Match")
+	}
+}
@@ -1,66 +1,86 @@
 package maze_device
 
 import (
-	"context"
-	"encoding/json"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
 	"goapi/internal/api/service/maze_device"
-	"log"
+	"goapi/internal/api/tabular"
+	"io"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"time"
 )
 
 // GetHandler handles GET requests to retrieve multiple maze device statuses
-// Supports pagination: GET /device/status?page=1&rows_per_page=10
-// curl -X GET "http://127.0.0.1:8080/device/status?page=1&rows_per_page=10" -u admin:password
-func GetHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, service maze_device.MazeDeviceStatusService) {
+// Supports pagination: GET /v1/device/status?page=1&rows_per_page=10
+// Sends CSV or NDJSON instead of JSON for Accept: text/csv or application/x-ndjson
+// curl -X GET "http://127.0.0.1:8080/v1/device/status?page=1&rows_per_page=10" -u admin:password
+func GetHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, service maze_device.MazeDeviceStatusService) {
 	// Parse query parameters for pagination
 	pageStr := r.URL.Query().Get("page")
 	rowsPerPageStr := r.URL.Query().Get("rows_per_page")
 	deviceID := r.URL.Query().Get("device_id")
 
 	page, _ := strconv.Atoi(pageStr)
+	shape := shapeOf(r)
 	rowsPerPage, _ := strconv.Atoi(rowsPerPageStr)
 
-	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
-	defer cancel()
+	// Devices may only read their own statuses, which is what they get without a device_id
+	identity, _ := middleware.IdentityFromContext(r.Context())
+	if deviceID == "" {
+		deviceID = identity.DeviceID
+	}
+	if !identity.CanAccessDevice(deviceID) {
+		problem.Write(w, r, http.StatusForbidden, "Forbidden: Devices may only read their own status.")
+		return
+	}
+
+	ctx := r.Context()
 
-	// If device_id is provided, filter by device_id
+	// If device_id is provided, filter by device_id, otherwise return paginated results
+	var statuses models.Rows[models.MazeDeviceStatus]
 	if deviceID != "" {
-		statuses, err := service.ReadByDeviceID(deviceID, ctx)
-		if err != nil {
-			switch err.(type) {
-			case maze_device.MazeDeviceStatusError:
-				w.WriteHeader(http.StatusBadRequest)
-				w.Write([]byte(`{"error": "` + err.Error() + `"}`))
-				return
-			default:
-				logger.Println("Error reading maze device statuses by device_id:", err)
-				http.Error(w, "Internal server error.", http.StatusInternalServerError)
-				return
-			}
-		}
-		w.WriteHeader(http.StatusOK)
-		if err := json.NewEncoder(w).Encode(statuses); err != nil {
-			logger.Println("Error encoding maze device statuses:", err)
-			http.Error(w, "Internal server error.", http.StatusInternalServerError)
-			return
+		statuses = service.ReadByDeviceID(deviceID, ctx)
+	} else {
+		statuses = service.ReadMany(page, rowsPerPage, ctx)
+	}
+
+	if err := writeStatuses(w, r, logger, shape, statuses); err != nil {
+		switch err := err.(type) {
+		case maze_device.MazeDeviceStatusError:
+			problem.Invalid(w, r, err.Message, err.Fields)
+		default:
+			logger.ErrorContext(r.Context(), "Error reading maze device statuses", "error", err, "device_id", deviceID)
+			problem.InternalError(w, r)
 		}
-		return
 	}
+}
 
-	// Otherwise, return paginated results
-	statuses, err := service.ReadMany(page, rowsPerPage, ctx)
-	if err != nil {
-		logger.Println("Error reading maze device statuses:", err)
-		http.Error(w, "Internal server error.", http.StatusInternalServerError)
-		return
+// writeStatuses responds with a list of statuses in the format the Accept header asks for, writing each status as it
+// is read. An error before the first status is returned so it can be answered with a problem.
+func writeStatuses(w http.ResponseWriter, r *http.Request, logger *slog.Logger, shape statusShape, statuses models.Rows[models.MazeDeviceStatus]) error {
+	format := tabular.Negotiate(r.Header.Get("Accept"), tabular.JSON)
+	res := tabular.NewResponse(w, format, shape.encode(&models.MazeDeviceStatus{}))
+	if err := tabular.Copy(res, statuses, shape.encode); err != nil {
+		if !res.Started() {
+			return err
+		}
+		// The status line is sent, a failure can only cut the body short
+		logger.ErrorContext(r.Context(), "Error streaming maze device statuses", "error", err)
+		return nil
 	}
 
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(statuses); err != nil {
-		logger.Println("Error encoding maze device statuses:", err)
-		http.Error(w, "Internal server error.", http.StatusInternalServerError)
-		return
+	if !res.Started() && format == tabular.JSON {
+		// No statuses are null in JSON, as documented
+		w.WriteHeader(http.StatusOK)
+		if _, err := io.WriteString(w, "null\n"); err != nil {
+			logger.ErrorContext(r.Context(), "Error encoding maze device statuses", "error", err)
+		}
+		return nil
+	}
+	if err := res.Close(); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding maze device statuses", "error", err)
 	}
+	return nil
 }
@@ -4,12 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"goapi/internal/api/middleware"
 	"goapi/internal/api/repository/models"
 	"goapi/internal/api/service/maze_device"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"strings"
 	"testing"
 )
@@ -24,21 +24,29 @@ func (m *mockMazeDeviceGetService) Create(status *models.MazeDeviceStatus, ctx c
 	return nil
 }
 
+func (m *mockMazeDeviceGetService) CreateBatch(statuses []*models.MazeDeviceStatus, ctx context.Context) ([]models.BatchResult, error) {
+	return nil, nil
+}
+
 func (m *mockMazeDeviceGetService) ReadOne(id int, ctx context.Context) (*models.MazeDeviceStatus, error) {
 	return nil, nil
 }
 
-func (m *mockMazeDeviceGetService) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.MazeDeviceStatus, error) {
+func (m *mockMazeDeviceGetService) ReadMany(page int, rowsPerPage int, ctx context.Context) models.Rows[models.MazeDeviceStatus] {
 	if m.readManyFunc != nil {
-		return m.readManyFunc(page, rowsPerPage, ctx)
+		return models.RowsOf(m.readManyFunc(page, rowsPerPage, ctx))
 	}
-	return nil, nil
+	return models.RowsOf[models.MazeDeviceStatus](nil, nil)
 }
 
-func (m *mockMazeDeviceGetService) ReadByDeviceID(deviceID string, ctx context.Context) ([]*models.MazeDeviceStatus, error) {
+func (m *mockMazeDeviceGetService) ReadByDeviceID(deviceID string, ctx context.Context) models.Rows[models.MazeDeviceStatus] {
 	if m.readByDeviceIDFunc != nil {
-		return m.readByDeviceIDFunc(deviceID, ctx)
+		return models.RowsOf(m.readByDeviceIDFunc(deviceID, ctx))
 	}
+	return models.RowsOf[models.MazeDeviceStatus](nil, nil)
+}
+
+func (m *mockMazeDeviceGetService) ReadLatest(ctx context.Context) ([]*models.MazeDeviceStatus, error) {
 	return nil, nil
 }
 
@@ -55,7 +63,7 @@ func (m *mockMazeDeviceGetService) ValidateStatus(status *models.MazeDeviceStatu
 }
 
 func TestGetHandlerSuccess(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	expectedStatuses := []*models.MazeDeviceStatus{
 		{
@@ -104,7 +112,7 @@ func TestGetHandlerSuccess(t *testing.T) {
 }
 
 func TestGetHandlerWithPagination(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockMazeDeviceGetService{
 		readManyFunc: func(page int, rowsPerPage int, ctx context.Context) ([]*models.MazeDeviceStatus, error) {
@@ -127,7 +135,7 @@ func TestGetHandlerWithPagination(t *testing.T) {
 }
 
 func TestGetHandlerByDeviceID(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	expectedStatuses := []*models.MazeDeviceStatus{
 		{
@@ -173,8 +181,40 @@ func TestGetHandlerByDeviceID(t *testing.T) {
 	}
 }
 
+func TestGetHandlerNegotiatesCSV(t *testing.T) {
+	logger := slog.Default()
+
+	mockService := &mockMazeDeviceGetService{
+		readByDeviceIDFunc: func(deviceID string, ctx context.Context) ([]*models.MazeDeviceStatus, error) {
+			return []*models.MazeDeviceStatus{
+				{ID: 2, DeviceID: "ESP32_TEST", HallSensorValue: true, BatteryLevel: 99, Timestamp: "2024-01-15T10:05:00Z", Version: 3},
+				{ID: 1, DeviceID: "ESP32_TEST", AlarmActive: true, BatteryLevel: 100, Timestamp: "2024-01-15T10:00:00Z", Version: 1},
+			}, nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/device/status?device_id=ESP32_TEST", nil)
+	req.Header.Set("Accept", "text/csv")
+	w := httptest.NewRecorder()
+
+	GetHandler(w, req, logger, mockService)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+	if contentType := w.Header().Get("Content-Type"); contentType != "text/csv; charset=utf-8" {
+		t.Errorf("Expected CSV, got %s", contentType)
+	}
+	expected := "id,device_id,alarm_active,maze_completed,hall_sensor_value,battery_level,timestamp,version\n" +
+		"2,ESP32_TEST,false,false,true,99,2024-01-15T10:05:00Z,3\n" +
+		"1,ESP32_TEST,true,false,false,100,2024-01-15T10:00:00Z,1\n"
+	if w.Body.String() != expected {
+		t.Errorf("Unexpected body:\n%s", w.Body.String())
+	}
+}
+
 func TestGetHandlerByDeviceIDError(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockMazeDeviceGetService{
 		readByDeviceIDFunc: func(deviceID string, ctx context.Context) ([]*models.MazeDeviceStatus, error) {
@@ -197,7 +237,7 @@ func TestGetHandlerByDeviceIDError(t *testing.T) {
 }
 
 func TestGetHandlerByDeviceIDInternalError(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockMazeDeviceGetService{
 		readByDeviceIDFunc: func(deviceID string, ctx context.Context) ([]*models.MazeDeviceStatus, error) {
@@ -216,7 +256,7 @@ func TestGetHandlerByDeviceIDInternalError(t *testing.T) {
 }
 
 func TestGetHandlerInternalError(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockMazeDeviceGetService{
 		readManyFunc: func(page int, rowsPerPage int, ctx context.Context) ([]*models.MazeDeviceStatus, error) {
@@ -235,7 +275,7 @@ func TestGetHandlerInternalError(t *testing.T) {
 }
 
 func TestGetHandlerEmptyResult(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockMazeDeviceGetService{
 		readManyFunc: func(page int, rowsPerPage int, ctx context.Context) ([]*models.MazeDeviceStatus, error) {
@@ -263,7 +303,7 @@ func TestGetHandlerEmptyResult(t *testing.T) {
 }
 
 func TestGetHandlerInvalidPaginationParameters(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockMazeDeviceGetService{
 		readManyFunc: func(page int, rowsPerPage int, ctx context.Context) ([]*models.MazeDeviceStatus, error) {
@@ -284,3 +324,84 @@ func TestGetHandlerInvalidPaginationParameters(t *testing.T) {
 		t.Errorf("Expected status 200, got %d", w.Code)
 	}
 }
+
+func TestGetHandlerNoStatusesIsNull(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/device/status", nil)
+	w := httptest.NewRecorder()
+
+	GetHandler(w, req, slog.Default(), &mockMazeDeviceGetService{})
+
+	if w.Code != http.StatusOK || w.Body.String() != "null\n" {
+		t.Errorf("Expected status 200 with null, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// Mock service failing after the first status, like a database error in the middle of a long list
+type mockMazeDeviceStreamService struct {
+	mockMazeDeviceGetService
+}
+
+func (m *mockMazeDeviceStreamService) ReadMany(page int, rowsPerPage int, ctx context.Context) models.Rows[models.MazeDeviceStatus] {
+	return func(yield func(*models.MazeDeviceStatus, error) bool) {
+		if yield(&models.MazeDeviceStatus{ID: 1, DeviceID: "ESP32_001"}, nil) {
+			yield(nil, errors.New("database connection lost"))
+		}
+	}
+}
+
+func TestGetHandlerErrorWhileStreaming(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/device/status", nil)
+	w := httptest.NewRecorder()
+
+	GetHandler(w, req, slog.Default(), &mockMazeDeviceStreamService{})
+
+	// The status line went out with the first status, the body is cut short
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+	if body := w.Body.String(); !strings.HasPrefix(body, `[{"id":1,"device_id":"ESP32_001"`) || strings.HasSuffix(body, "]\n") {
+		t.Errorf("Expected an unterminated array, got %s", body)
+	}
+}
+
+func TestGetHandlerDeviceScope(t *testing.T) {
+	logger := slog.Default()
+	device := middleware.Identity{Username: "ESP32_TEST", DeviceID: "ESP32_TEST"}
+
+	tests := []struct {
+		name   string
+		target string
+		code   int
+	}{
+		{name: "Own statuses", target: "/device/status?device_id=ESP32_TEST", code: http.StatusOK},
+		{name: "Without device_id", target: "/device/status", code: http.StatusOK},
+		{name: "Other device's statuses", target: "/device/status?device_id=ESP32_OTHER", code: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &mockMazeDeviceGetService{
+				readManyFunc: func(page, rowsPerPage int, ctx context.Context) ([]*models.MazeDeviceStatus, error) {
+					t.Error("Expected a device not to list every status")
+					return nil, nil
+				},
+				readByDeviceIDFunc: func(deviceID string, ctx context.Context) ([]*models.MazeDeviceStatus, error) {
+					if deviceID != "ESP32_TEST" {
+						t.Errorf("Expected device_id ESP32_TEST, got %s", deviceID)
+					}
+					return []*models.MazeDeviceStatus{{ID: 1, DeviceID: deviceID}}, nil
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req = req.WithContext(middleware.WithIdentity(req.Context(), device))
+			w := httptest.NewRecorder()
+
+			GetHandler(w, req, logger, mockService)
+
+			if w.Code != tt.code {
+				t.Errorf("Expected status %d, got %d", tt.code, w.Code)
+			}
+		})
+	}
+}
@@ -1,48 +1,59 @@
 package maze_device
 
 import (
-	"context"
 	"encoding/json"
+	"goapi/internal/api/etag"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
 	"goapi/internal/api/service/maze_device"
-	"log"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"time"
 )
 
 // GetByIDHandler handles GET requests to retrieve a specific maze device status by ID
-// curl -X GET http://127.0.0.1:8080/device/status/1 -u admin:password
-func GetByIDHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, service maze_device.MazeDeviceStatusService) {
+// curl -X GET http://127.0.0.1:8080/v1/device/status/1 -u admin:password
+func GetByIDHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, service maze_device.MazeDeviceStatusService) {
 	// Extract ID from URL path parameter
 	idStr := r.PathValue("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "Invalid ID format."}`))
+		problem.Write(w, r, http.StatusBadRequest, "Invalid ID format.")
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
-	defer cancel()
+	ctx := r.Context()
 
 	// Retrieve the status from the database
 	status, err := service.ReadOne(id, ctx)
 	if err != nil {
-		logger.Println("Error reading maze device status:", err)
-		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+		logger.ErrorContext(r.Context(), "Error reading maze device status", "error", err)
+		problem.InternalError(w, r)
 		return
 	}
 
 	if status == nil {
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte(`{"error": "Maze device status not found."}`))
+		problem.Write(w, r, http.StatusNotFound, "Maze device status not found.")
+		return
+	}
+
+	// Devices may only read their own status
+	if identity, _ := middleware.IdentityFromContext(r.Context()); !identity.CanAccessDevice(status.DeviceID) {
+		problem.Write(w, r, http.StatusForbidden, "Forbidden: Devices may only read their own status.")
+		return
+	}
+
+	// The version is the ETag, a client holding the current version gets 304 Not Modified without a body
+	w.Header().Set("ETag", etag.Format(status.Version))
+	if etag.NoneMatch(r.Header.Get("If-None-Match"), status.Version) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(status); err != nil {
-		logger.Println("Error encoding maze device status:", err)
-		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+	if err := json.NewEncoder(w).Encode(shapeOf(r).encode(status)); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding maze device status", "error", err)
+		problem.InternalError(w, r)
 		return
 	}
 }
@@ -4,11 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
 	"goapi/internal/api/repository/models"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"testing"
 )
 
@@ -21,6 +22,10 @@ func (m *mockMazeDeviceGetByIDService) Create(status *models.MazeDeviceStatus, c
 	return nil
 }
 
+func (m *mockMazeDeviceGetByIDService) CreateBatch(statuses []*models.MazeDeviceStatus, ctx context.Context) ([]models.BatchResult, error) {
+	return nil, nil
+}
+
 func (m *mockMazeDeviceGetByIDService) ReadOne(id int, ctx context.Context) (*models.MazeDeviceStatus, error) {
 	if m.readOneFunc != nil {
 		return m.readOneFunc(id, ctx)
@@ -28,11 +33,15 @@ func (m *mockMazeDeviceGetByIDService) ReadOne(id int, ctx context.Context) (*mo
 	return nil, nil
 }
 
-func (m *mockMazeDeviceGetByIDService) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.MazeDeviceStatus, error) {
-	return nil, nil
+func (m *mockMazeDeviceGetByIDService) ReadMany(page int, rowsPerPage int, ctx context.Context) models.Rows[models.MazeDeviceStatus] {
+	return models.RowsOf[models.MazeDeviceStatus](nil, nil)
 }
 
-func (m *mockMazeDeviceGetByIDService) ReadByDeviceID(deviceID string, ctx context.Context) ([]*models.MazeDeviceStatus, error) {
+func (m *mockMazeDeviceGetByIDService) ReadByDeviceID(deviceID string, ctx context.Context) models.Rows[models.MazeDeviceStatus] {
+	return models.RowsOf[models.MazeDeviceStatus](nil, nil)
+}
+
+func (m *mockMazeDeviceGetByIDService) ReadLatest(ctx context.Context) ([]*models.MazeDeviceStatus, error) {
 	return nil, nil
 }
 
@@ -49,7 +58,7 @@ func (m *mockMazeDeviceGetByIDService) ValidateStatus(status *models.MazeDeviceS
 }
 
 func TestGetByIDHandlerSuccess(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	expectedStatus := &models.MazeDeviceStatus{
 		ID:              1,
@@ -95,7 +104,7 @@ func TestGetByIDHandlerSuccess(t *testing.T) {
 }
 
 func TestGetByIDHandlerInvalidID(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 	mockService := &mockMazeDeviceGetByIDService{}
 
 	req := httptest.NewRequest(http.MethodGet, "/device/status/invalid", nil)
@@ -108,14 +117,14 @@ func TestGetByIDHandlerInvalidID(t *testing.T) {
 		t.Errorf("Expected status 400, got %d", w.Code)
 	}
 
-	expected := `{"error": "Invalid ID format."}`
-	if w.Body.String() != expected {
+	expected := "Invalid ID format."
+	if problem.Detail(w.Body.Bytes()) != expected {
 		t.Errorf("Expected body %s, got %s", expected, w.Body.String())
 	}
 }
 
 func TestGetByIDHandlerNotFound(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockMazeDeviceGetByIDService{
 		readOneFunc: func(id int, ctx context.Context) (*models.MazeDeviceStatus, error) {
@@ -133,14 +142,14 @@ func TestGetByIDHandlerNotFound(t *testing.T) {
 		t.Errorf("Expected status 404, got %d", w.Code)
 	}
 
-	expected := `{"error": "Maze device status not found."}`
-	if w.Body.String() != expected {
+	expected := "Maze device status not found."
+	if problem.Detail(w.Body.Bytes()) != expected {
 		t.Errorf("Expected body %s, got %s", expected, w.Body.String())
 	}
 }
 
 func TestGetByIDHandlerInternalError(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockMazeDeviceGetByIDService{
 		readOneFunc: func(id int, ctx context.Context) (*models.MazeDeviceStatus, error) {
@@ -160,7 +169,7 @@ func TestGetByIDHandlerInternalError(t *testing.T) {
 }
 
 func TestGetByIDHandlerNegativeID(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockMazeDeviceGetByIDService{
 		readOneFunc: func(id int, ctx context.Context) (*models.MazeDeviceStatus, error) {
@@ -184,7 +193,7 @@ func TestGetByIDHandlerNegativeID(t *testing.T) {
 }
 
 func TestGetByIDHandlerZeroID(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockMazeDeviceGetByIDService{
 		readOneFunc: func(id int, ctx context.Context) (*models.MazeDeviceStatus, error) {
@@ -207,7 +216,7 @@ func TestGetByIDHandlerZeroID(t *testing.T) {
 }
 
 func TestGetByIDHandlerLargeID(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockMazeDeviceGetByIDService{
 		readOneFunc: func(id int, ctx context.Context) (*models.MazeDeviceStatus, error) {
@@ -228,3 +237,65 @@ func TestGetByIDHandlerLargeID(t *testing.T) {
 		t.Errorf("Expected status 404, got %d", w.Code)
 	}
 }
+
+func TestGetByIDHandlerIfNoneMatch(t *testing.T) {
+	logger := slog.Default()
+	mockService := &mockMazeDeviceGetByIDService{
+		readOneFunc: func(id int, ctx context.Context) (*models.MazeDeviceStatus, error) {
+			return &models.MazeDeviceStatus{ID: id, DeviceID: "ARD001", Version: 2}, nil
+		},
+	}
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		code        int
+	}{
+		{name: "Current version", ifNoneMatch: `"2"`, code: http.StatusNotModified},
+		{name: "Older version", ifNoneMatch: `"1"`, code: http.StatusOK},
+		{name: "No header", code: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/device/status/1", nil)
+			req.SetPathValue("id", "1")
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			w := httptest.NewRecorder()
+
+			GetByIDHandler(w, req, logger, mockService)
+
+			if w.Code != tt.code {
+				t.Errorf("Expected status %d, got %d", tt.code, w.Code)
+			}
+			if etag := w.Header().Get("ETag"); etag != `"2"` {
+				t.Errorf("Expected ETag \"2\", got %q", etag)
+			}
+			if tt.code == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Errorf("Expected no body, got %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetByIDHandlerOtherDevice(t *testing.T) {
+	logger := slog.Default()
+	mockService := &mockMazeDeviceGetByIDService{
+		readOneFunc: func(id int, ctx context.Context) (*models.MazeDeviceStatus, error) {
+			return &models.MazeDeviceStatus{ID: id, DeviceID: "ESP32_OTHER", Version: 1}, nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/device/status/1", nil)
+	req.SetPathValue("id", "1")
+	req = req.WithContext(middleware.WithIdentity(req.Context(), middleware.Identity{Username: "ESP32_TEST", DeviceID: "ESP32_TEST"}))
+	w := httptest.NewRecorder()
+
+	GetByIDHandler(w, req, logger, mockService)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", w.Code)
+	}
+}
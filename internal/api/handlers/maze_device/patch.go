@@ -0,0 +1,125 @@
+package maze_device
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"goapi/internal/api/etag"
+	"goapi/internal/api/mergepatch"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	"goapi/internal/api/service/maze_device"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// PatchHandler handles PATCH requests applying a JSON merge patch (RFC 7396) to a maze device status
+// curl -X PATCH http://127.0.0.1:8080/v1/device/status/1 -u admin:password -H "Content-Type: application/merge-patch+json" -d '{"alarm_active":false}'
+func PatchHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, service maze_device.MazeDeviceStatusService) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid ID format.")
+		return
+	}
+
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request data. Please check your input.")
+		return
+	}
+
+	version, err := etag.IfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		problem.Write(w, r, http.StatusPreconditionFailed, "Precondition failed: If-Match must be a single strong ETag.")
+		return
+	}
+
+	ctx := r.Context()
+
+	current, err := service.ReadOne(id, ctx)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Error reading maze device status", "error", err)
+		problem.InternalError(w, r)
+		return
+	}
+	if current == nil {
+		problem.Write(w, r, http.StatusNotFound, "Maze device status not found.")
+		return
+	}
+
+	// Devices may only update their own status
+	identity, _ := middleware.IdentityFromContext(r.Context())
+	if !identity.CanAccessDevice(current.DeviceID) {
+		problem.Write(w, r, http.StatusForbidden, "Forbidden: Devices may only update their own status.")
+		return
+	}
+	if version != 0 && version != current.Version {
+		w.Header().Set("ETag", etag.Format(current.Version))
+		problem.Write(w, r, http.StatusPreconditionFailed, "Precondition failed: maze device status was modified.")
+		return
+	}
+
+	// Merge the patch into the stored status in the request's shape, unknown fields are rejected so typos do not go unnoticed
+	shape := shapeOf(r)
+	document, err := json.Marshal(shape.encode(current))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding maze device status", "error", err, "status", current)
+		problem.InternalError(w, r)
+		return
+	}
+	merged, err := mergepatch.Apply(document, patch)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request data. The body must be a JSON merge patch object.")
+		return
+	}
+	var status models.MazeDeviceStatus
+	decoder := json.NewDecoder(bytes.NewReader(merged))
+	decoder.DisallowUnknownFields()
+	if err := shape.decode(decoder, &status); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request data. Please check your input.")
+		return
+	}
+
+	// The patch may not move the status to another device
+	if !identity.CanAccessDevice(status.DeviceID) {
+		problem.Write(w, r, http.StatusForbidden, "Forbidden: Devices may only update their own status.")
+		return
+	}
+
+	// The ID comes from the path, and the update only applies to the version the patch was merged into
+	status.ID = id
+	status.Version = current.Version
+
+	// Update validates the merged status like a PUT
+	if rowsAffected, err := service.Update(&status, ctx); err != nil {
+		switch err := err.(type) {
+		case maze_device.MazeDeviceStatusError:
+			problem.Invalid(w, r, err.Message, err.Fields)
+			return
+		default:
+			logger.ErrorContext(r.Context(), "Error patching maze device status", "error", err, "status", status)
+			// Another row already holds the device and time, the unique index rejects it
+			if errors.As(err, new(*models.DuplicateError)) {
+				problem.Write(w, r, http.StatusConflict, "A status for this device_id and timestamp already exists.")
+				return
+			}
+			problem.InternalError(w, r)
+			return
+		}
+	} else if rowsAffected == 0 {
+		// Modified or deleted between reading and writing
+		problem.Write(w, r, http.StatusPreconditionFailed, "Precondition failed: maze device status was modified.")
+		return
+	}
+
+	w.Header().Set("ETag", etag.Format(status.Version))
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(shape.encode(&status)); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding maze device status", "error", err, "status", status)
+		problem.InternalError(w, r)
+		return
+	}
+}
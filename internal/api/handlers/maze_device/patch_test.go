@@ -0,0 +1,161 @@
+package maze_device
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/repository/models"
+	"goapi/internal/api/service/maze_device"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Mock service for PATCH testing, holds a single stored status
+type mockMazeDevicePatchService struct {
+	stored  *models.MazeDeviceStatus
+	updated *models.MazeDeviceStatus
+}
+
+func (m *mockMazeDevicePatchService) Create(status *models.MazeDeviceStatus, ctx context.Context) error {
+	return nil
+}
+
+func (m *mockMazeDevicePatchService) CreateBatch(statuses []*models.MazeDeviceStatus, ctx context.Context) ([]models.BatchResult, error) {
+	return nil, nil
+}
+
+func (m *mockMazeDevicePatchService) ReadOne(id int, ctx context.Context) (*models.MazeDeviceStatus, error) {
+	if m.stored == nil || m.stored.ID != id {
+		return nil, nil
+	}
+	stored := *m.stored
+	return &stored, nil
+}
+
+func (m *mockMazeDevicePatchService) ReadMany(page int, rowsPerPage int, ctx context.Context) models.Rows[models.MazeDeviceStatus] {
+	return models.RowsOf[models.MazeDeviceStatus](nil, nil)
+}
+
+func (m *mockMazeDevicePatchService) ReadByDeviceID(deviceID string, ctx context.Context) models.Rows[models.MazeDeviceStatus] {
+	return models.RowsOf[models.MazeDeviceStatus](nil, nil)
+}
+
+func (m *mockMazeDevicePatchService) ReadLatest(ctx context.Context) ([]*models.MazeDeviceStatus, error) {
+	return nil, nil
+}
+
+func (m *mockMazeDevicePatchService) Update(status *models.MazeDeviceStatus, ctx context.Context) (int64, error) {
+	if status.BatteryLevel > 100 {
+		return 0, maze_device.MazeDeviceStatusError{Message: "Invalid maze device status: battery_level must be between 0 and 100."}
+	}
+	if status.Version != m.stored.Version {
+		return 0, nil
+	}
+	status.Version++
+	m.updated = status
+	return 1, nil
+}
+
+func (m *mockMazeDevicePatchService) Delete(status *models.MazeDeviceStatus, ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockMazeDevicePatchService) ValidateStatus(status *models.MazeDeviceStatus) error {
+	return nil
+}
+
+func newStoredStatus() *models.MazeDeviceStatus {
+	return &models.MazeDeviceStatus{
+		ID:              1,
+		DeviceID:        "ESP32_001",
+		AlarmActive:     true,
+		MazeCompleted:   false,
+		HallSensorValue: true,
+		BatteryLevel:    85,
+		Timestamp:       "2024-01-15T11:00:00Z",
+		Version:         3,
+	}
+}
+
+func TestPatchHandlerSuccess(t *testing.T) {
+	logger := slog.Default()
+	mockService := &mockMazeDevicePatchService{stored: newStoredStatus()}
+
+	req := httptest.NewRequest(http.MethodPatch, "/device/status/1", strings.NewReader(`{"alarm_active":false}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+
+	PatchHandler(w, req, logger, mockService)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if etag := w.Header().Get("ETag"); etag != `"4"` {
+		t.Errorf("Expected ETag \"4\", got %q", etag)
+	}
+
+	var response models.MazeDeviceStatus
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	// Only alarm_active changes, false is applied rather than treated as missing
+	expected := newStoredStatus()
+	expected.AlarmActive = false
+	expected.Version = 4
+	if response != *expected {
+		t.Errorf("Expected %+v, got %+v", *expected, response)
+	}
+}
+
+func TestPatchHandlerErrors(t *testing.T) {
+	logger := slog.Default()
+
+	tests := []struct {
+		name     string
+		id       string
+		body     string
+		ifMatch  string
+		identity *middleware.Identity
+		code     int
+	}{
+		{name: "Invalid ID", id: "abc", body: `{}`, code: http.StatusBadRequest},
+		{name: "Not found", id: "2", body: `{"alarm_active":false}`, code: http.StatusNotFound},
+		{name: "Invalid JSON", id: "1", body: `{"alarm_active":`, code: http.StatusBadRequest},
+		{name: "Unknown field", id: "1", body: `{"alarm":false}`, code: http.StatusBadRequest},
+		{name: "Validation failed", id: "1", body: `{"battery_level":101}`, code: http.StatusBadRequest},
+		{name: "Stale version", id: "1", body: `{"alarm_active":false}`, ifMatch: `"2"`, code: http.StatusPreconditionFailed},
+		{name: "Other device", id: "1", body: `{"alarm_active":false}`, identity: &middleware.Identity{Username: "ESP32_002", DeviceID: "ESP32_002"}, code: http.StatusForbidden},
+		{name: "Moved to other device", id: "1", body: `{"device_id":"ESP32_002"}`, identity: &middleware.Identity{Username: "ESP32_001", DeviceID: "ESP32_001"}, code: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &mockMazeDevicePatchService{stored: newStoredStatus()}
+
+			req := httptest.NewRequest(http.MethodPatch, "/device/status/"+tt.id, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+			req.SetPathValue("id", tt.id)
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			if tt.identity != nil {
+				req = req.WithContext(middleware.WithIdentity(req.Context(), *tt.identity))
+			}
+			w := httptest.NewRecorder()
+
+			PatchHandler(w, req, logger, mockService)
+
+			if w.Code != tt.code {
+				t.Errorf("Expected status %d, got %d: %s", tt.code, w.Code, w.Body.String())
+			}
+			if mockService.updated != nil {
+				t.Errorf("Expected no update, got %+v", mockService.updated)
+			}
+		})
+	}
+}
@@ -1,51 +1,61 @@
 package maze_device
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
 	"goapi/internal/api/repository/models"
 	"goapi/internal/api/service/maze_device"
-	"log"
+	"log/slog"
 	"net/http"
-	"time"
 )
 
 // PostHandler handles POST requests to create new maze device status
-// curl -X POST http://127.0.0.1:8080/device/status -u admin:password -H "Content-Type: application/json" -d '{"device_id":"ARD001","alarm_active":true,"maze_completed":false,"hall_sensor_value":false,"battery_level":85,"timestamp":"2024-01-15T10:30:00Z"}'
-func PostHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, service maze_device.MazeDeviceStatusService) {
+// curl -X POST http://127.0.0.1:8080/v1/device/status -u admin:password -H "Content-Type: application/json" -d '{"device_id":"ARD001","alarm_active":true,"maze_completed":false,"hall_sensor_value":false,"battery_level":85,"timestamp":"2024-01-15T10:30:00Z"}'
+func PostHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, service maze_device.MazeDeviceStatusService) {
 	var status models.MazeDeviceStatus
+	shape := shapeOf(r)
 
 	// Decode the JSON payload from the request body
-	if err := json.NewDecoder(r.Body).Decode(&status); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "Invalid request data. Please check your input."}`))
+	if err := shape.decode(json.NewDecoder(r.Body), &status); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request data. Please check your input.")
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
-	defer cancel()
+	// Devices may only report their own status
+	if identity, _ := middleware.IdentityFromContext(r.Context()); !identity.CanAccessDevice(status.DeviceID) {
+		problem.Write(w, r, http.StatusForbidden, "Forbidden: Devices may only report their own status.")
+		return
+	}
+
+	ctx := r.Context()
 
 	// Try to create the status in the database
 	if err := service.Create(&status, ctx); err != nil {
-		switch err.(type) {
+		switch err := err.(type) {
 		case maze_device.MazeDeviceStatusError:
 			// Client error: validation failed
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte(`{"error": "` + err.Error() + `"}`))
+			problem.Invalid(w, r, err.Message, err.Fields)
 			return
 		default:
 			// Server error
-			logger.Println("Error creating maze device status:", err, status)
-			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Error creating maze device status", "error", err, "status", status)
+			// Another row already holds the device and time, the unique index rejects it
+			if errors.As(err, new(*models.DuplicateError)) {
+				problem.Write(w, r, http.StatusConflict, "A status for this device_id and timestamp already exists.")
+				return
+			}
+			problem.InternalError(w, r)
 			return
 		}
 	}
 
 	// Return the created status with 201 Created
 	w.WriteHeader(http.StatusCreated)
-	if err := json.NewEncoder(w).Encode(status); err != nil {
-		logger.Println("Error encoding maze device status:", err, status)
-		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+	if err := json.NewEncoder(w).Encode(shape.encode(&status)); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding maze device status", "error", err, "status", status)
+		problem.InternalError(w, r)
 		return
 	}
 }
@@ -4,12 +4,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"goapi/internal/api/middleware"
 	"goapi/internal/api/repository/models"
 	"goapi/internal/api/service/maze_device"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"testing"
 )
 
@@ -22,15 +23,23 @@ func (m *mockMazeDeviceStatusService) Create(status *models.MazeDeviceStatus, ct
 	return m.createFunc(status, ctx)
 }
 
-func (m *mockMazeDeviceStatusService) ReadOne(id int, ctx context.Context) (*models.MazeDeviceStatus, error) {
+func (m *mockMazeDeviceStatusService) CreateBatch(statuses []*models.MazeDeviceStatus, ctx context.Context) ([]models.BatchResult, error) {
 	return nil, nil
 }
 
-func (m *mockMazeDeviceStatusService) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.MazeDeviceStatus, error) {
+func (m *mockMazeDeviceStatusService) ReadOne(id int, ctx context.Context) (*models.MazeDeviceStatus, error) {
 	return nil, nil
 }
 
-func (m *mockMazeDeviceStatusService) ReadByDeviceID(deviceID string, ctx context.Context) ([]*models.MazeDeviceStatus, error) {
+func (m *mockMazeDeviceStatusService) ReadMany(page int, rowsPerPage int, ctx context.Context) models.Rows[models.MazeDeviceStatus] {
+	return models.RowsOf[models.MazeDeviceStatus](nil, nil)
+}
+
+func (m *mockMazeDeviceStatusService) ReadByDeviceID(deviceID string, ctx context.Context) models.Rows[models.MazeDeviceStatus] {
+	return models.RowsOf[models.MazeDeviceStatus](nil, nil)
+}
+
+func (m *mockMazeDeviceStatusService) ReadLatest(ctx context.Context) ([]*models.MazeDeviceStatus, error) {
 	return nil, nil
 }
 
@@ -47,7 +56,7 @@ func (m *mockMazeDeviceStatusService) ValidateStatus(status *models.MazeDeviceSt
 }
 
 func TestPostHandlerInvalidJSON(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 	mockService := &mockMazeDeviceStatusService{}
 
 	req := httptest.NewRequest(http.MethodPost, "/device/status", bytes.NewBufferString("{invalid json}"))
@@ -62,7 +71,7 @@ func TestPostHandlerInvalidJSON(t *testing.T) {
 }
 
 func TestPostHandlerValidationError(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 	mockService := &mockMazeDeviceStatusService{
 		createFunc: func(status *models.MazeDeviceStatus, ctx context.Context) error {
 			return maze_device.MazeDeviceStatusError{Message: "device_id is required"}
@@ -91,7 +100,7 @@ func TestPostHandlerValidationError(t *testing.T) {
 }
 
 func TestPostHandlerSuccess(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 	mockService := &mockMazeDeviceStatusService{
 		createFunc: func(status *models.MazeDeviceStatus, ctx context.Context) error {
 			status.ID = 1
@@ -125,3 +134,45 @@ func TestPostHandlerSuccess(t *testing.T) {
 		t.Errorf("Expected ID 1, got %d", response.ID)
 	}
 }
+
+func TestPostHandlerOtherDevice(t *testing.T) {
+	logger := slog.Default()
+	mockService := &mockMazeDeviceStatusService{
+		createFunc: func(status *models.MazeDeviceStatus, ctx context.Context) error {
+			t.Error("Create should not have been called")
+			return nil
+		},
+	}
+
+	jsonData, _ := json.Marshal(models.MazeDeviceStatus{DeviceID: "ESP32_MAZE_001", BatteryLevel: 85, Timestamp: "2024-01-15T10:30:00Z"})
+	req := httptest.NewRequest(http.MethodPost, "/device/status", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(middleware.WithIdentity(req.Context(), middleware.Identity{Username: "ESP32_MAZE_002", DeviceID: "ESP32_MAZE_002"}))
+	w := httptest.NewRecorder()
+
+	PostHandler(w, req, logger, mockService)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403, got %d", w.Code)
+	}
+}
+
+func TestPostHandlerDuplicate(t *testing.T) {
+	logger := slog.Default()
+	mockService := &mockMazeDeviceStatusService{
+		createFunc: func(status *models.MazeDeviceStatus, ctx context.Context) error {
+			return &models.DuplicateError{Err: errors.New("UNIQUE constraint failed: maze_device_status.device_id, maze_device_status.timestamp")}
+		},
+	}
+
+	jsonData, _ := json.Marshal(models.MazeDeviceStatus{DeviceID: "ARD001", BatteryLevel: 85, Timestamp: "2024-01-15T10:30:00Z"})
+	req := httptest.NewRequest(http.MethodPost, "/device/status", bytes.NewBuffer(jsonData))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	PostHandler(w, req, logger, mockService)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409, got %d", w.Code)
+	}
+}
@@ -1,65 +1,112 @@
 package maze_device
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
+	"goapi/internal/api/etag"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
 	"goapi/internal/api/repository/models"
 	"goapi/internal/api/service/maze_device"
-	"log"
+	"log/slog"
 	"net/http"
-	"time"
 )
 
 // PutHandler handles PUT requests to update maze device status
-// curl -X PUT http://127.0.0.1:8080/device/status -u admin:password -H "Content-Type: application/json" -d '{"id":1,"device_id":"ARD001","alarm_active":false,"maze_completed":true,"hall_sensor_value":true,"battery_level":80,"timestamp":"2024-01-15T10:35:00Z"}'
-func PutHandler(w http.ResponseWriter, r *http.Request, logger *log.Logger, service maze_device.MazeDeviceStatusService) {
+// curl -X PUT http://127.0.0.1:8080/v1/device/status -u admin:password -H "Content-Type: application/json" -H 'If-Match: "1"' -d '{"id":1,"device_id":"ARD001","alarm_active":false,"maze_completed":true,"hall_sensor_value":true,"battery_level":80,"timestamp":"2024-01-15T10:35:00Z"}'
+func PutHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, service maze_device.MazeDeviceStatusService) {
 	var status models.MazeDeviceStatus
+	shape := shapeOf(r)
 
 	// Decode the JSON payload from the request body
-	if err := json.NewDecoder(r.Body).Decode(&status); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "Invalid request data. Please check your input."}`))
+	if err := shape.decode(json.NewDecoder(r.Body), &status); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request data. Please check your input.")
+		return
+	}
+
+	// Devices may only update their own status
+	identity, _ := middleware.IdentityFromContext(r.Context())
+	if !identity.CanAccessDevice(status.DeviceID) {
+		problem.Write(w, r, http.StatusForbidden, "Forbidden: Devices may only update their own status.")
 		return
 	}
 
 	// Validate that ID is provided
 	if status.ID == 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(`{"error": "ID is required for update."}`))
+		problem.Write(w, r, http.StatusBadRequest, "ID is required for update.")
+		return
+	}
+
+	// If-Match is required, the version in the body is ignored and * writes whatever version is stored
+	version, err := etag.RequireMatch(r.Header.Get("If-Match"))
+	if errors.Is(err, etag.ErrPreconditionRequired) {
+		problem.Write(w, r, http.StatusPreconditionRequired, "Precondition required: If-Match must hold the ETag of the resource.")
+		return
+	}
+	if err != nil {
+		problem.Write(w, r, http.StatusPreconditionFailed, "Precondition failed: If-Match must be a single strong ETag.")
 		return
 	}
+	status.Version = version
 
-	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
-	defer cancel()
+	ctx := r.Context()
+
+	// The stored status must belong to the device too, or it could overwrite another device's status by ID
+	current, err := service.ReadOne(status.ID, ctx)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Error reading maze device status", "error", err)
+		problem.InternalError(w, r)
+		return
+	}
+	if current == nil {
+		problem.Write(w, r, http.StatusNotFound, "Maze device status not found.")
+		return
+	}
+	if !identity.CanAccessDevice(current.DeviceID) {
+		problem.Write(w, r, http.StatusForbidden, "Forbidden: Devices may only update their own status.")
+		return
+	}
 
 	// Try to update the status in the database
 	rowsAffected, err := service.Update(&status, ctx)
 	if err != nil {
-		switch err.(type) {
+		switch err := err.(type) {
 		case maze_device.MazeDeviceStatusError:
 			// Client error: validation failed
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte(`{"error": "` + err.Error() + `"}`))
+			problem.Invalid(w, r, err.Message, err.Fields)
 			return
 		default:
 			// Server error
-			logger.Println("Error updating maze device status:", err, status)
-			http.Error(w, "Internal server error.", http.StatusInternalServerError)
+			logger.ErrorContext(r.Context(), "Error updating maze device status", "error", err, "status", status)
+			// Another row already holds the device and time, the unique index rejects it
+			if errors.As(err, new(*models.DuplicateError)) {
+				problem.Write(w, r, http.StatusConflict, "A status for this device_id and timestamp already exists.")
+				return
+			}
+			problem.InternalError(w, r)
 			return
 		}
 	}
 
 	if rowsAffected == 0 {
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte(`{"error": "Maze device status not found."}`))
+		// A stale version is told apart from a missing maze device status by reading the current one
+		if status.Version != 0 {
+			if current, err := service.ReadOne(status.ID, ctx); err == nil && current != nil {
+				w.Header().Set("ETag", etag.Format(current.Version))
+				problem.Write(w, r, http.StatusPreconditionFailed, "Precondition failed: maze device status was modified.")
+				return
+			}
+		}
+		problem.Write(w, r, http.StatusNotFound, "Maze device status not found.")
 		return
 	}
 
 	// Return the updated status with 200 OK
+	w.Header().Set("ETag", etag.Format(status.Version))
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(status); err != nil {
-		logger.Println("Error encoding maze device status:", err, status)
-		http.Error(w, "Internal server error.", http.StatusInternalServerError)
+	if err := json.NewEncoder(w).Encode(shape.encode(&status)); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding maze device status", "error", err, "status", status)
+		problem.InternalError(w, r)
 		return
 	}
 }
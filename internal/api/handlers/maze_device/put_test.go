@@ -5,34 +5,47 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
 	"goapi/internal/api/repository/models"
 	"goapi/internal/api/service/maze_device"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"strings"
 	"testing"
 )
 
 // Mock service for PUT testing
 type mockMazeDevicePutService struct {
-	updateFunc func(*models.MazeDeviceStatus, context.Context) (int64, error)
+	updateFunc  func(*models.MazeDeviceStatus, context.Context) (int64, error)
+	readOneFunc func(int, context.Context) (*models.MazeDeviceStatus, error)
 }
 
 func (m *mockMazeDevicePutService) Create(status *models.MazeDeviceStatus, ctx context.Context) error {
 	return nil
 }
 
-func (m *mockMazeDevicePutService) ReadOne(id int, ctx context.Context) (*models.MazeDeviceStatus, error) {
+func (m *mockMazeDevicePutService) CreateBatch(statuses []*models.MazeDeviceStatus, ctx context.Context) ([]models.BatchResult, error) {
 	return nil, nil
 }
 
-func (m *mockMazeDevicePutService) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.MazeDeviceStatus, error) {
-	return nil, nil
+func (m *mockMazeDevicePutService) ReadOne(id int, ctx context.Context) (*models.MazeDeviceStatus, error) {
+	if m.readOneFunc != nil {
+		return m.readOneFunc(id, ctx)
+	}
+	return &models.MazeDeviceStatus{ID: id, DeviceID: "ESP32_001", Version: 1}, nil
 }
 
-func (m *mockMazeDevicePutService) ReadByDeviceID(deviceID string, ctx context.Context) ([]*models.MazeDeviceStatus, error) {
+func (m *mockMazeDevicePutService) ReadMany(page int, rowsPerPage int, ctx context.Context) models.Rows[models.MazeDeviceStatus] {
+	return models.RowsOf[models.MazeDeviceStatus](nil, nil)
+}
+
+func (m *mockMazeDevicePutService) ReadByDeviceID(deviceID string, ctx context.Context) models.Rows[models.MazeDeviceStatus] {
+	return models.RowsOf[models.MazeDeviceStatus](nil, nil)
+}
+
+func (m *mockMazeDevicePutService) ReadLatest(ctx context.Context) ([]*models.MazeDeviceStatus, error) {
 	return nil, nil
 }
 
@@ -52,7 +65,7 @@ func (m *mockMazeDevicePutService) ValidateStatus(status *models.MazeDeviceStatu
 }
 
 func TestPutHandlerSuccess(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockMazeDevicePutService{
 		updateFunc: func(status *models.MazeDeviceStatus, ctx context.Context) (int64, error) {
@@ -73,6 +86,7 @@ func TestPutHandlerSuccess(t *testing.T) {
 	jsonData, _ := json.Marshal(status)
 	req := httptest.NewRequest(http.MethodPut, "/device/status", bytes.NewBuffer(jsonData))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
 	w := httptest.NewRecorder()
 
 	PutHandler(w, req, logger, mockService)
@@ -92,11 +106,12 @@ func TestPutHandlerSuccess(t *testing.T) {
 }
 
 func TestPutHandlerInvalidJSON(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 	mockService := &mockMazeDevicePutService{}
 
 	req := httptest.NewRequest(http.MethodPut, "/device/status", bytes.NewBufferString("{invalid json}"))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
 	w := httptest.NewRecorder()
 
 	PutHandler(w, req, logger, mockService)
@@ -111,7 +126,7 @@ func TestPutHandlerInvalidJSON(t *testing.T) {
 }
 
 func TestPutHandlerMissingID(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 	mockService := &mockMazeDevicePutService{}
 
 	status := models.MazeDeviceStatus{
@@ -127,6 +142,7 @@ func TestPutHandlerMissingID(t *testing.T) {
 	jsonData, _ := json.Marshal(status)
 	req := httptest.NewRequest(http.MethodPut, "/device/status", bytes.NewBuffer(jsonData))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
 	w := httptest.NewRecorder()
 
 	PutHandler(w, req, logger, mockService)
@@ -135,18 +151,20 @@ func TestPutHandlerMissingID(t *testing.T) {
 		t.Errorf("Expected status 400, got %d", w.Code)
 	}
 
-	expected := `{"error": "ID is required for update."}`
-	if w.Body.String() != expected {
+	expected := "ID is required for update."
+	if problem.Detail(w.Body.Bytes()) != expected {
 		t.Errorf("Expected body %s, got %s", expected, w.Body.String())
 	}
 }
 
 func TestPutHandlerValidationError(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockMazeDevicePutService{
 		updateFunc: func(status *models.MazeDeviceStatus, ctx context.Context) (int64, error) {
-			return 0, maze_device.MazeDeviceStatusError{Message: "device_id is required"}
+			var fields models.FieldErrors
+			fields.Add("device_id", "is required and must be less than 50 characters")
+			return 0, maze_device.MazeDeviceStatusError{Message: "Invalid maze device status.", Fields: fields}
 		},
 	}
 
@@ -163,6 +181,7 @@ func TestPutHandlerValidationError(t *testing.T) {
 	jsonData, _ := json.Marshal(status)
 	req := httptest.NewRequest(http.MethodPut, "/device/status", bytes.NewBuffer(jsonData))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
 	w := httptest.NewRecorder()
 
 	PutHandler(w, req, logger, mockService)
@@ -171,13 +190,20 @@ func TestPutHandlerValidationError(t *testing.T) {
 		t.Errorf("Expected status 400, got %d", w.Code)
 	}
 
-	if !strings.Contains(w.Body.String(), "device_id is required") {
-		t.Errorf("Expected error about device_id, got %s", w.Body.String())
+	if w.Header().Get("Content-Type") != problem.ContentType {
+		t.Errorf("Expected Content-Type %s, got %s", problem.ContentType, w.Header().Get("Content-Type"))
+	}
+	var response problem.Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Expected a problem, got %s", w.Body.String())
+	}
+	if response.Type != problem.TypeValidation || len(response.Errors) != 1 || response.Errors[0].Field != "device_id" {
+		t.Errorf("Expected a validation problem about device_id, got %s", w.Body.String())
 	}
 }
 
 func TestPutHandlerNotFound(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockMazeDevicePutService{
 		updateFunc: func(status *models.MazeDeviceStatus, ctx context.Context) (int64, error) {
@@ -198,6 +224,7 @@ func TestPutHandlerNotFound(t *testing.T) {
 	jsonData, _ := json.Marshal(status)
 	req := httptest.NewRequest(http.MethodPut, "/device/status", bytes.NewBuffer(jsonData))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", "*")
 	w := httptest.NewRecorder()
 
 	PutHandler(w, req, logger, mockService)
@@ -206,14 +233,14 @@ func TestPutHandlerNotFound(t *testing.T) {
 		t.Errorf("Expected status 404, got %d", w.Code)
 	}
 
-	expected := `{"error": "Maze device status not found."}`
-	if w.Body.String() != expected {
+	expected := "Maze device status not found."
+	if problem.Detail(w.Body.Bytes()) != expected {
 		t.Errorf("Expected body %s, got %s", expected, w.Body.String())
 	}
 }
 
 func TestPutHandlerInternalError(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockMazeDevicePutService{
 		updateFunc: func(status *models.MazeDeviceStatus, ctx context.Context) (int64, error) {
@@ -234,6 +261,7 @@ func TestPutHandlerInternalError(t *testing.T) {
 	jsonData, _ := json.Marshal(status)
 	req := httptest.NewRequest(http.MethodPut, "/device/status", bytes.NewBuffer(jsonData))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
 	w := httptest.NewRecorder()
 
 	PutHandler(w, req, logger, mockService)
@@ -244,11 +272,12 @@ func TestPutHandlerInternalError(t *testing.T) {
 }
 
 func TestPutHandlerEmptyBody(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 	mockService := &mockMazeDevicePutService{}
 
 	req := httptest.NewRequest(http.MethodPut, "/device/status", bytes.NewBuffer([]byte{}))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
 	w := httptest.NewRecorder()
 
 	PutHandler(w, req, logger, mockService)
@@ -259,7 +288,7 @@ func TestPutHandlerEmptyBody(t *testing.T) {
 }
 
 func TestPutHandlerBoundaryValues(t *testing.T) {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	logger := slog.Default()
 
 	mockService := &mockMazeDevicePutService{
 		updateFunc: func(status *models.MazeDeviceStatus, ctx context.Context) (int64, error) {
@@ -284,6 +313,7 @@ func TestPutHandlerBoundaryValues(t *testing.T) {
 	jsonData, _ := json.Marshal(status)
 	req := httptest.NewRequest(http.MethodPut, "/device/status", bytes.NewBuffer(jsonData))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
 	w := httptest.NewRecorder()
 
 	PutHandler(w, req, logger, mockService)
@@ -292,3 +322,119 @@ func TestPutHandlerBoundaryValues(t *testing.T) {
 		t.Errorf("Expected status 200, got %d", w.Code)
 	}
 }
+
+func TestPutHandlerIfMatch(t *testing.T) {
+	logger := slog.Default()
+	body := `{"id":1,"device_id":"ESP32_001","alarm_active":false,"maze_completed":true,"hall_sensor_value":true,"battery_level":85,"timestamp":"2024-01-15T11:00:00Z","version":2}`
+
+	// The version in the body is ignored, only If-Match makes the update conditional
+	tests := []struct {
+		name     string
+		ifMatch  string
+		affected int64
+		current  *models.MazeDeviceStatus
+		missing  bool
+		code     int
+		version  int
+		etag     string
+	}{
+		{name: "Missing If-Match", code: http.StatusPreconditionRequired},
+		{name: "Matching version", ifMatch: `"3"`, affected: 1, code: http.StatusOK, version: 3, etag: `"4"`},
+		{name: "Stale version", ifMatch: `"2"`, current: &models.MazeDeviceStatus{ID: 1, Version: 3}, code: http.StatusPreconditionFailed, version: 2, etag: `"3"`},
+		{name: "Missing status", ifMatch: `"2"`, missing: true, code: http.StatusNotFound},
+		{name: "Weak tag", ifMatch: `W/"3"`, code: http.StatusPreconditionFailed},
+		{name: "Any version", ifMatch: "*", affected: 1, code: http.StatusOK, version: 0, etag: `"1"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var version int
+			mockService := &mockMazeDevicePutService{
+				updateFunc: func(status *models.MazeDeviceStatus, ctx context.Context) (int64, error) {
+					version = status.Version
+					if tt.affected > 0 {
+						status.Version++
+					}
+					return tt.affected, nil
+				},
+				readOneFunc: func(id int, ctx context.Context) (*models.MazeDeviceStatus, error) {
+					if tt.missing {
+						return nil, nil
+					}
+					if tt.current != nil {
+						return tt.current, nil
+					}
+					return &models.MazeDeviceStatus{ID: id, DeviceID: "ESP32_001", Version: 3}, nil
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodPut, "/device/status", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+
+			PutHandler(w, req, logger, mockService)
+
+			if w.Code != tt.code {
+				t.Errorf("Expected status %d, got %d", tt.code, w.Code)
+			}
+			if version != tt.version {
+				t.Errorf("Expected the update to require version %d, got %d", tt.version, version)
+			}
+			if etag := w.Header().Get("ETag"); etag != tt.etag {
+				t.Errorf("Expected ETag %q, got %q", tt.etag, etag)
+			}
+		})
+	}
+}
+
+func TestPutHandlerDeviceScope(t *testing.T) {
+	logger := slog.Default()
+	device := middleware.Identity{Username: "ESP32_TEST", DeviceID: "ESP32_TEST"}
+
+	tests := []struct {
+		name    string
+		body    string
+		stored  string
+		code    int
+		updated bool
+	}{
+		{name: "Own status", body: "ESP32_TEST", stored: "ESP32_TEST", code: http.StatusOK, updated: true},
+		{name: "Status of another device", body: "ESP32_OTHER", stored: "ESP32_OTHER", code: http.StatusForbidden},
+		{name: "Taking over another device's status", body: "ESP32_TEST", stored: "ESP32_OTHER", code: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			updated := false
+			mockService := &mockMazeDevicePutService{
+				readOneFunc: func(id int, ctx context.Context) (*models.MazeDeviceStatus, error) {
+					return &models.MazeDeviceStatus{ID: id, DeviceID: tt.stored, Version: 1}, nil
+				},
+				updateFunc: func(status *models.MazeDeviceStatus, ctx context.Context) (int64, error) {
+					updated = true
+					status.Version++
+					return 1, nil
+				},
+			}
+
+			body := `{"id":1,"device_id":"` + tt.body + `","battery_level":80,"timestamp":"2024-01-15T10:35:00Z"}`
+			req := httptest.NewRequest(http.MethodPut, "/device/status", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("If-Match", `"1"`)
+			req = req.WithContext(middleware.WithIdentity(req.Context(), device))
+			w := httptest.NewRecorder()
+
+			PutHandler(w, req, logger, mockService)
+
+			if w.Code != tt.code {
+				t.Errorf("Expected status %d, got %d: %s", tt.code, w.Code, w.Body.String())
+			}
+			if updated != tt.updated {
+				t.Errorf("Expected updated %v, got %v", tt.updated, updated)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package maze_device
+
+import (
+	"encoding/json"
+	"goapi/internal/api/apiversion"
+	"goapi/internal/api/batch"
+	"goapi/internal/api/repository/models"
+	"io"
+	"net/http"
+)
+
+// statusShape is the JSON representation of a maze device status in one API version. A version that changes the
+// representation registers its own shape converting to and from models.MazeDeviceStatus, so clients of older
+// versions, like the firmware in the field, keep sending and receiving the shape they were built against.
+type statusShape struct {
+	decode      func(decoder *json.Decoder, status *models.MazeDeviceStatus) error
+	decodeBatch func(body io.Reader) ([]*models.MazeDeviceStatus, error)
+	encode      func(status *models.MazeDeviceStatus) any
+}
+
+// statusShapes is keyed by API version, every version in apiversion.Versions needs a shape
+var statusShapes = map[string]statusShape{
+	// v1 is the model itself
+	apiversion.V1: {
+		decode: func(decoder *json.Decoder, status *models.MazeDeviceStatus) error {
+			return decoder.Decode(status)
+		},
+		decodeBatch: batch.Decode[models.MazeDeviceStatus],
+		encode: func(status *models.MazeDeviceStatus) any {
+			return status
+		},
+	},
+}
+
+// shapeOf returns the status shape of the API version the request was routed to
+func shapeOf(r *http.Request) statusShape {
+	return statusShapes[apiversion.FromContext(r.Context())]
+}
@@ -0,0 +1,37 @@
+package maze_device
+
+import (
+	"encoding/json"
+	"goapi/internal/api/apiversion"
+	"goapi/internal/api/repository/models"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEveryVersionHasStatusShape(t *testing.T) {
+	for _, version := range apiversion.Versions {
+		shape, ok := statusShapes[version]
+		if !ok || shape.decode == nil || shape.decodeBatch == nil || shape.encode == nil {
+			t.Errorf("API version %s has no complete maze device status shape", version)
+		}
+	}
+}
+
+func TestStatusShapeFollowsRequestVersion(t *testing.T) {
+	req := httptest.NewRequest("GET", "/v1/device/status", nil)
+	req = req.WithContext(apiversion.WithVersion(req.Context(), apiversion.V1))
+
+	shape := shapeOf(req)
+	if shape.encode == nil {
+		t.Fatal("Expected the v1 shape")
+	}
+
+	body, err := json.Marshal(shape.encode(&models.MazeDeviceStatus{ID: 1, DeviceID: "ARD001"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"id":1,"device_id":"ARD001"`; !strings.HasPrefix(string(body), expected) {
+		t.Errorf("Expected %s..., got %s", expected, body)
+	}
+}
@@ -0,0 +1,53 @@
+package provisioning
+
+import (
+	"encoding/json"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/service/provisioning"
+	"log/slog"
+	"net/http"
+)
+
+// ClaimHandler handles POST requests from unprovisioned devices exchanging a claim code for credentials.
+// The route is public: the claim code itself authorizes the request and can only be used once.
+// curl -X POST http://127.0.0.1:8080/v1/provisioning/claim -H "Content-Type: application/json" -d '{"hardware_id":"24:6F:28:AA:BB:CC","claim_code":"K7QF-M2XP"}'
+func ClaimHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, service provisioning.ProvisioningService) {
+	var request provisioning.ProvisioningRequest
+
+	// Decode the JSON payload from the request body
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request data. Please check your input.")
+		return
+	}
+
+	ctx := r.Context()
+
+	device, err := service.Provision(&request, ctx)
+	if err != nil {
+		switch err := err.(type) {
+		case provisioning.ProvisioningError:
+			// Client error: the hardware already belongs to another device
+			if err.Conflict {
+				problem.Write(w, r, http.StatusConflict, err.Error())
+				return
+			}
+			// Client error: unknown, expired or used claim code
+			problem.Write(w, r, http.StatusBadRequest, err.Error())
+			return
+		default:
+			// Server error
+			logger.ErrorContext(r.Context(), "Error provisioning device", "error", err, "hardware_id", request.HardwareID)
+			problem.InternalError(w, r)
+			return
+		}
+	}
+
+	// The password is only ever returned here, make sure it is not cached on the way
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(device); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding provisioned device", "error", err)
+		problem.InternalError(w, r)
+		return
+	}
+}
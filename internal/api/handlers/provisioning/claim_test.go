@@ -0,0 +1,108 @@
+package provisioning
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"goapi/internal/api/service/provisioning"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClaimHandlerInvalidJSON(t *testing.T) {
+	logger := slog.Default()
+	mockService := &mockProvisioningService{}
+
+	req := httptest.NewRequest(http.MethodPost, "/provisioning/claim", bytes.NewBufferString("not json"))
+	w := httptest.NewRecorder()
+
+	ClaimHandler(w, req, logger, mockService)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestClaimHandlerRejectedClaim(t *testing.T) {
+	logger := slog.Default()
+	mockService := &mockProvisioningService{
+		provisionFunc: func(request *provisioning.ProvisioningRequest, ctx context.Context) (*provisioning.ProvisionedDevice, error) {
+			return nil, provisioning.ProvisioningError{Message: "Invalid, expired or already used claim code."}
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/provisioning/claim", bytes.NewBufferString(`{"hardware_id":"HW1","claim_code":"USEDCODE"}`))
+	w := httptest.NewRecorder()
+
+	ClaimHandler(w, req, logger, mockService)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestClaimHandlerConflict(t *testing.T) {
+	logger := slog.Default()
+	mockService := &mockProvisioningService{
+		provisionFunc: func(request *provisioning.ProvisioningRequest, ctx context.Context) (*provisioning.ProvisionedDevice, error) {
+			return nil, provisioning.ProvisioningError{Message: "hardware_id is already provisioned as device ESP32_MAZE_001.", Conflict: true}
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/provisioning/claim", bytes.NewBufferString(`{"hardware_id":"HW1","claim_code":"ABCD2345"}`))
+	w := httptest.NewRecorder()
+
+	ClaimHandler(w, req, logger, mockService)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409, got %d", w.Code)
+	}
+}
+
+func TestClaimHandlerInternalError(t *testing.T) {
+	logger := slog.Default()
+	mockService := &mockProvisioningService{
+		provisionFunc: func(request *provisioning.ProvisioningRequest, ctx context.Context) (*provisioning.ProvisionedDevice, error) {
+			return nil, errors.New("database error")
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/provisioning/claim", bytes.NewBufferString(`{"hardware_id":"HW1","claim_code":"K7QFM2XP"}`))
+	w := httptest.NewRecorder()
+
+	ClaimHandler(w, req, logger, mockService)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", w.Code)
+	}
+}
+
+func TestClaimHandlerSuccess(t *testing.T) {
+	logger := slog.Default()
+	mockService := &mockProvisioningService{
+		provisionFunc: func(request *provisioning.ProvisioningRequest, ctx context.Context) (*provisioning.ProvisionedDevice, error) {
+			return &provisioning.ProvisionedDevice{DeviceID: request.HardwareID, Username: request.HardwareID, Password: "secret"}, nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/provisioning/claim", bytes.NewBufferString(`{"hardware_id":"HW1","claim_code":"K7QFM2XP"}`))
+	w := httptest.NewRecorder()
+
+	ClaimHandler(w, req, logger, mockService)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", w.Code)
+	}
+	if w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("Expected Cache-Control: no-store, got %s", w.Header().Get("Cache-Control"))
+	}
+
+	var response provisioning.ProvisionedDevice
+	json.NewDecoder(w.Body).Decode(&response)
+	if response.DeviceID != "HW1" || response.Password != "secret" {
+		t.Errorf("Unexpected provisioned device: %+v", response)
+	}
+}
@@ -0,0 +1,40 @@
+package provisioning
+
+import (
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	"goapi/internal/api/service/provisioning"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// DeleteHandler handles DELETE requests to revoke a claim code issued by the calling user
+// curl -X DELETE http://127.0.0.1:8080/v1/provisioning/claims/1 -u admin:password
+func DeleteHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, service provisioning.ProvisioningService) {
+	// Extract ID from URL path parameter
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid ID format.")
+		return
+	}
+
+	identity, _ := middleware.IdentityFromContext(r.Context())
+
+	ctx := r.Context()
+
+	rowsAffected, err := service.DeleteClaim(&models.ClaimCode{ID: id, IssuedBy: identity.Username}, ctx)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Error deleting claim code", "error", err)
+		problem.InternalError(w, r)
+		return
+	}
+
+	if rowsAffected == 0 {
+		problem.Write(w, r, http.StatusNotFound, "Claim code not found.")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,32 @@
+package provisioning
+
+import (
+	"encoding/json"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/service/provisioning"
+	"log/slog"
+	"net/http"
+)
+
+// GetHandler handles GET requests to list the claim codes issued by the calling user
+// curl -X GET http://127.0.0.1:8080/v1/provisioning/claims -u admin:password
+func GetHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, service provisioning.ProvisioningService) {
+	identity, _ := middleware.IdentityFromContext(r.Context())
+
+	ctx := r.Context()
+
+	claims, err := service.ReadClaims(identity.Username, ctx)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "Error reading claim codes", "error", err)
+		problem.InternalError(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(claims); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding claim codes", "error", err)
+		problem.InternalError(w, r)
+		return
+	}
+}
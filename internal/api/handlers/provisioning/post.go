@@ -0,0 +1,61 @@
+package provisioning
+
+import (
+	"encoding/json"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	"goapi/internal/api/service/provisioning"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// ClaimRequest is the payload an admin sends to generate a claim code
+type ClaimRequest struct {
+	DeviceID   string `json:"device_id"`   // Optional device ID to assign, derived from the hardware ID when empty
+	TTLSeconds int    `json:"ttl_seconds"` // Optional lifetime of the code, defaults to 15 minutes
+}
+
+// PostHandler handles POST requests to generate a claim code bound to the calling user
+// curl -X POST http://127.0.0.1:8080/v1/provisioning/claims -u admin:password -H "Content-Type: application/json" -d '{"device_id":"ESP32_MAZE_002","ttl_seconds":900}'
+func PostHandler(w http.ResponseWriter, r *http.Request, logger *slog.Logger, service provisioning.ProvisioningService) {
+	var request ClaimRequest
+
+	// Decode the JSON payload from the request body
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request data. Please check your input.")
+		return
+	}
+
+	identity, _ := middleware.IdentityFromContext(r.Context())
+	claim := models.ClaimCode{
+		IssuedBy: identity.Username,
+		DeviceID: request.DeviceID,
+	}
+
+	ctx := r.Context()
+
+	// Try to create the claim code in the database
+	if err := service.CreateClaim(&claim, time.Duration(request.TTLSeconds)*time.Second, ctx); err != nil {
+		switch err.(type) {
+		case provisioning.ProvisioningError:
+			// Client error: validation failed
+			problem.Write(w, r, http.StatusBadRequest, err.Error())
+			return
+		default:
+			// Server error
+			logger.ErrorContext(r.Context(), "Error creating claim code", "error", err)
+			problem.InternalError(w, r)
+			return
+		}
+	}
+
+	// Return the created claim code with 201 Created
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(claim); err != nil {
+		logger.ErrorContext(r.Context(), "Error encoding claim code", "error", err)
+		problem.InternalError(w, r)
+		return
+	}
+}
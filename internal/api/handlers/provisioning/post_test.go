@@ -0,0 +1,112 @@
+package provisioning
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/repository/models"
+	"goapi/internal/api/service/provisioning"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Mock service for testing
+type mockProvisioningService struct {
+	createClaimFunc func(*models.ClaimCode, time.Duration, context.Context) error
+	provisionFunc   func(*provisioning.ProvisioningRequest, context.Context) (*provisioning.ProvisionedDevice, error)
+}
+
+func (m *mockProvisioningService) CreateClaim(claim *models.ClaimCode, ttl time.Duration, ctx context.Context) error {
+	return m.createClaimFunc(claim, ttl, ctx)
+}
+
+func (m *mockProvisioningService) ReadClaims(issuedBy string, ctx context.Context) ([]*models.ClaimCode, error) {
+	return nil, nil
+}
+
+func (m *mockProvisioningService) DeleteClaim(claim *models.ClaimCode, ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockProvisioningService) Provision(request *provisioning.ProvisioningRequest, ctx context.Context) (*provisioning.ProvisionedDevice, error) {
+	return m.provisionFunc(request, ctx)
+}
+
+func (m *mockProvisioningService) Authenticate(username string, password string, ctx context.Context) (string, bool) {
+	return "", false
+}
+
+func (m *mockProvisioningService) Credential(username string, ctx context.Context) (string, string, bool) {
+	return "", "", false
+}
+
+func (m *mockProvisioningService) PurgeExpiredClaims(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func TestPostHandlerInvalidJSON(t *testing.T) {
+	logger := slog.Default()
+	mockService := &mockProvisioningService{}
+
+	req := httptest.NewRequest(http.MethodPost, "/provisioning/claims", bytes.NewBufferString("{invalid json}"))
+	w := httptest.NewRecorder()
+
+	PostHandler(w, req, logger, mockService)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestPostHandlerBindsClaimToCaller(t *testing.T) {
+	logger := slog.Default()
+	mockService := &mockProvisioningService{
+		createClaimFunc: func(claim *models.ClaimCode, ttl time.Duration, ctx context.Context) error {
+			if ttl != 10*time.Minute {
+				t.Errorf("Expected TTL of 10 minutes, got %v", ttl)
+			}
+			claim.ID = 1
+			claim.Code = "K7QFM2XP"
+			return nil
+		},
+	}
+
+	body, _ := json.Marshal(ClaimRequest{DeviceID: "ESP32_MAZE_002", TTLSeconds: 600})
+	req := httptest.NewRequest(http.MethodPost, "/provisioning/claims", bytes.NewBuffer(body))
+	req = req.WithContext(middleware.WithIdentity(req.Context(), middleware.Identity{Username: "admin"}))
+	w := httptest.NewRecorder()
+
+	PostHandler(w, req, logger, mockService)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d", w.Code)
+	}
+
+	var response models.ClaimCode
+	json.NewDecoder(w.Body).Decode(&response)
+	if response.IssuedBy != "admin" || response.DeviceID != "ESP32_MAZE_002" || response.Code != "K7QFM2XP" {
+		t.Errorf("Unexpected claim code: %+v", response)
+	}
+}
+
+func TestPostHandlerValidationError(t *testing.T) {
+	logger := slog.Default()
+	mockService := &mockProvisioningService{
+		createClaimFunc: func(claim *models.ClaimCode, ttl time.Duration, ctx context.Context) error {
+			return provisioning.ProvisioningError{Message: "ttl_seconds must be between 60 and 86400."}
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/provisioning/claims", bytes.NewBufferString(`{"ttl_seconds":1}`))
+	w := httptest.NewRecorder()
+
+	PostHandler(w, req, logger, mockService)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
@@ -0,0 +1,46 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// checkTimeout bounds a readiness probe, so a hanging dependency fails the probe instead of the load balancer timing out
+const checkTimeout = 2 * time.Second
+
+// LiveHandler serves GET /healthz, it does not touch any dependency so a busy database never restarts the process
+// curl http://127.0.0.1:8080/healthz
+func (c *Checker) LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"status": "ok"}`))
+	})
+}
+
+// ReadyHandler serves GET /readyz with the result of every check, 503 when any check fails
+// curl http://127.0.0.1:8080/readyz
+func (c *Checker) ReadyHandler(logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+		defer cancel()
+
+		report := c.Ready(ctx)
+		status := http.StatusOK
+		if report.Status != StatusOK {
+			status = http.StatusServiceUnavailable
+			logger.WarnContext(r.Context(), "Readiness check failing", "checks", report.Checks)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			logger.ErrorContext(r.Context(), "Error encoding readiness report", "error", err)
+		}
+	})
+}
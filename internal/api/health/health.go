@@ -0,0 +1,143 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Check reports whether a dependency of the server is usable
+type Check func(ctx context.Context) error
+
+// Result is the outcome of a single check
+type Result struct {
+	Status    string  `json:"status"` // "ok" or "failing"
+	Error     string  `json:"error,omitempty"`
+	LatencyMs float64 `json:"latency_ms"`
+}
+
+// Report is the readiness of the server with the result of every check
+type Report struct {
+	Status string            `json:"status"` // "ok" when every check passed, "failing" otherwise
+	Checks map[string]Result `json:"checks"`
+}
+
+const (
+	StatusOK      = "ok"
+	StatusFailing = "failing"
+)
+
+// Checker runs the readiness checks of the server and tracks its background workers.
+// Liveness only means the process serves HTTP, readiness means it can also serve API requests.
+type Checker struct {
+	mu           sync.RWMutex
+	checks       map[string]Check
+	shuttingDown bool
+}
+
+func New() *Checker {
+	return &Checker{checks: make(map[string]Check)}
+}
+
+// AddCheck registers a readiness check, e.g. a database ping
+func (c *Checker) AddCheck(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+}
+
+// SetShuttingDown makes readiness fail, so load balancers stop routing new requests before the server drains
+func (c *Checker) SetShuttingDown() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.shuttingDown = true
+}
+
+// Ready runs all checks concurrently and reports the result of each
+func (c *Checker) Ready(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := make(map[string]Check, len(c.checks))
+	for name, check := range c.checks {
+		checks[name] = check
+	}
+	shuttingDown := c.shuttingDown
+	c.mu.RUnlock()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks)+1)}
+	if shuttingDown {
+		report.Checks["shutdown"] = Result{Status: StatusFailing, Error: "server is shutting down"}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			start := time.Now()
+			err := check(ctx)
+			result := Result{Status: StatusOK, LatencyMs: float64(time.Since(start).Microseconds()) / 1000}
+			if err != nil {
+				result.Status, result.Error = StatusFailing, err.Error()
+			}
+			mu.Lock()
+			report.Checks[name] = result
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status != StatusOK {
+			report.Status = StatusFailing
+		}
+	}
+	return report
+}
+
+// RunWorker calls run every interval until ctx is done. The worker is registered as a readiness check
+// that fails when its last run failed or when it has not completed a run for two intervals.
+func (c *Checker) RunWorker(ctx context.Context, name string, interval time.Duration, run func(ctx context.Context) error) {
+	w := &worker{interval: interval}
+	c.AddCheck("worker:"+name, w.check)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		w.record(run(ctx))
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// worker tracks the heartbeat of a background worker
+type worker struct {
+	mu       sync.Mutex
+	interval time.Duration
+	lastRun  time.Time
+	lastErr  error
+}
+
+func (w *worker) record(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lastRun, w.lastErr = time.Now(), err
+}
+
+func (w *worker) check(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	switch {
+	case w.lastRun.IsZero():
+		return errors.New("worker has not run yet")
+	case w.lastErr != nil:
+		return w.lastErr
+	case time.Since(w.lastRun) > 2*w.interval:
+		return errors.New("worker missed its last run at " + w.lastRun.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func probe(t *testing.T, c *Checker) (int, Report) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	c.ReadyHandler(slog.Default()).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	var report Report
+	if err := json.NewDecoder(rr.Body).Decode(&report); err != nil {
+		t.Fatalf("Error decoding readiness report: %v", err)
+	}
+	return rr.Code, report
+}
+
+func TestReadyReportsEachCheck(t *testing.T) {
+	c := New()
+	c.AddCheck("database", func(ctx context.Context) error { return nil })
+
+	code, report := probe(t, c)
+	if code != http.StatusOK || report.Status != StatusOK {
+		t.Errorf("Expected ready, got %d %+v", code, report)
+	}
+
+	c.AddCheck("schema", func(ctx context.Context) error { return errors.New("missing tables: data") })
+	code, report = probe(t, c)
+	if code != http.StatusServiceUnavailable || report.Status != StatusFailing {
+		t.Errorf("Expected not ready, got %d %+v", code, report)
+	}
+	if report.Checks["database"].Status != StatusOK || report.Checks["schema"].Error != "missing tables: data" {
+		t.Errorf("Unexpected check results: %+v", report.Checks)
+	}
+}
+
+func TestReadyFailsWhileShuttingDown(t *testing.T) {
+	c := New()
+	c.SetShuttingDown()
+
+	code, report := probe(t, c)
+	if code != http.StatusServiceUnavailable || report.Checks["shutdown"].Status != StatusFailing {
+		t.Errorf("Expected readiness to fail during shutdown, got %d %+v", code, report)
+	}
+
+	rr := httptest.NewRecorder()
+	c.LiveHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected liveness to pass during shutdown, got %d", rr.Code)
+	}
+}
+
+func TestWorkerStatus(t *testing.T) {
+	w := &worker{interval: time.Minute}
+	if err := w.check(context.Background()); err == nil {
+		t.Error("Expected a worker that never ran to fail")
+	}
+
+	w.record(nil)
+	if err := w.check(context.Background()); err != nil {
+		t.Errorf("Expected a worker that just ran to pass, got %v", err)
+	}
+
+	w.record(errors.New("database is locked"))
+	if err := w.check(context.Background()); err == nil || err.Error() != "database is locked" {
+		t.Errorf("Expected the last worker error, got %v", err)
+	}
+
+	w.record(nil)
+	w.lastRun = time.Now().Add(-3 * time.Minute)
+	if err := w.check(context.Background()); err == nil {
+		t.Error("Expected a stale worker to fail")
+	}
+}
+
+func TestRunWorker(t *testing.T) {
+	c := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := make(chan struct{}, 1)
+
+	done := make(chan struct{})
+	go func() {
+		c.RunWorker(ctx, "purge", time.Hour, func(ctx context.Context) error {
+			ran <- struct{}{}
+			return nil
+		})
+		close(done)
+	}()
+
+	<-ran
+	cancel()
+	<-done
+
+	if _, report := probe(t, c); report.Checks["worker:purge"].Status != StatusOK {
+		t.Errorf("Expected the worker check to pass after a run, got %+v", report.Checks)
+	}
+}
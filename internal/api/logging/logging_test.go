@@ -0,0 +1,51 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestContextHandlerAddsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo).With("component", "test")
+
+	logger.InfoContext(WithRequestID(context.Background(), "abc123"), "Handled request")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Expected a JSON record, got %q: %v", buf.String(), err)
+	}
+	if record["request_id"] != "abc123" || record["component"] != "test" || record["msg"] != "Handled request" {
+		t.Errorf("Unexpected record: %v", record)
+	}
+}
+
+func TestContextHandlerWithoutRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo)
+
+	logger.Info("Starting server")
+
+	var record map[string]any
+	json.Unmarshal(buf.Bytes(), &record)
+	if _, ok := record["request_id"]; ok {
+		t.Errorf("Expected no request_id outside a request, got %v", record)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := map[string]slog.Level{
+		"debug": slog.LevelDebug,
+		"WARN":  slog.LevelWarn,
+		"":      slog.LevelInfo,
+		"loud":  slog.LevelInfo,
+	}
+	for name, expected := range tests {
+		if got := ParseLevel(name); got != expected {
+			t.Errorf("ParseLevel(%q) = %v, expected %v", name, got, expected)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package mergepatch
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// ErrInvalidPatch is returned when the patch is not a JSON object
+var ErrInvalidPatch = errors.New("merge patch must be a JSON object")
+
+// Apply applies an RFC 7396 JSON merge patch to a JSON document.
+// Members of the patch replace those of the document, null removes a member and nested objects are merged recursively.
+// Arrays and other values are replaced as a whole.
+func Apply(document []byte, patch []byte) ([]byte, error) {
+	var p any
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, err
+	}
+	// A patch that is not an object would replace the whole resource, which PATCH is not meant for
+	if _, ok := p.(map[string]any); !ok {
+		return nil, ErrInvalidPatch
+	}
+
+	var d any
+	if err := json.Unmarshal(document, &d); err != nil {
+		return nil, err
+	}
+	return json.Marshal(merge(d, p))
+}
+
+// merge follows the MergePatch pseudo code of RFC 7396 section 2
+func merge(target any, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+	for name, value := range p {
+		if value == nil {
+			delete(t, name)
+		} else {
+			t[name] = merge(t[name], value)
+		}
+	}
+	return t
+}
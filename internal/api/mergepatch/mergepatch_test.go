@@ -0,0 +1,65 @@
+package mergepatch
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	// Examples from RFC 7396 appendix A, limited to patches that are objects
+	tests := []struct {
+		name     string
+		document string
+		patch    string
+		expected string
+	}{
+		{name: "Replace member", document: `{"a":"b"}`, patch: `{"a":"c"}`, expected: `{"a":"c"}`},
+		{name: "Add member", document: `{"a":"b"}`, patch: `{"b":"c"}`, expected: `{"a":"b","b":"c"}`},
+		{name: "Remove member", document: `{"a":"b"}`, patch: `{"a":null}`, expected: `{}`},
+		{name: "Remove one of two", document: `{"a":"b","b":"c"}`, patch: `{"a":null}`, expected: `{"b":"c"}`},
+		{name: "Replace array", document: `{"a":["b"]}`, patch: `{"a":"c"}`, expected: `{"a":"c"}`},
+		{name: "Replace with array", document: `{"a":"c"}`, patch: `{"a":["b"]}`, expected: `{"a":["b"]}`},
+		{name: "Nested merge", document: `{"a":{"b":"c"}}`, patch: `{"a":{"b":"d","c":null}}`, expected: `{"a":{"b":"d"}}`},
+		{name: "Arrays are not merged", document: `{"a":[{"b":"c"}]}`, patch: `{"a":[1]}`, expected: `{"a":[1]}`},
+		{name: "Untouched null is kept", document: `{"e":null}`, patch: `{"a":1}`, expected: `{"a":1,"e":null}`},
+		{name: "Non-object document", document: `["c"]`, patch: `{"a":"b"}`, expected: `{"a":"b"}`},
+		{name: "Nested object on missing member", document: `{}`, patch: `{"a":{"bb":{"ccc":null}}}`, expected: `{"a":{"bb":{}}}`},
+		{name: "Zero values are set", document: `{"level":7,"active":true}`, patch: `{"level":0,"active":false}`, expected: `{"level":0,"active":false}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Apply([]byte(tt.document), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			var got, want any
+			json.Unmarshal(result, &got)
+			json.Unmarshal([]byte(tt.expected), &want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestApplyInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+	}{
+		{name: "Not JSON", patch: `not json`},
+		{name: "Array", patch: `["a"]`},
+		{name: "Null", patch: `null`},
+		{name: "String", patch: `"a"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Apply([]byte(`{"a":"b"}`), []byte(tt.patch)); err == nil {
+				t.Errorf("Expected an error for patch %s", tt.patch)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"goapi/internal/api/service/maze_device"
+)
+
+// mazeDeviceStatusService counts the statuses stored through the wrapped service
+type mazeDeviceStatusService struct {
+	maze_device.MazeDeviceStatusService
+	metrics *Metrics
+}
+
+// InstrumentMazeDeviceStatusService wraps service so that every stored status is counted per device
+func (m *Metrics) InstrumentMazeDeviceStatusService(service maze_device.MazeDeviceStatusService) maze_device.MazeDeviceStatusService {
+	return &mazeDeviceStatusService{MazeDeviceStatusService: service, metrics: m}
+}
+
+func (s *mazeDeviceStatusService) Create(status *models.MazeDeviceStatus, ctx context.Context) error {
+	if err := s.MazeDeviceStatusService.Create(status, ctx); err != nil {
+		return err
+	}
+	s.metrics.ObserveStatusIngested(status.DeviceID)
+	return nil
+}
+
+func (s *mazeDeviceStatusService) CreateBatch(statuses []*models.MazeDeviceStatus, ctx context.Context) ([]models.BatchResult, error) {
+	results, err := s.MazeDeviceStatusService.CreateBatch(statuses, ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, result := range results {
+		if result.Status == models.BatchAccepted {
+			s.metrics.ObserveStatusIngested(statuses[result.Index].DeviceID)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,170 @@
+package metrics
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DeviceOnlineWindow is how recently a device must have reported a status to count as online
+const DeviceOnlineWindow = 5 * time.Minute
+
+// Metrics holds the Prometheus registry of the API server and the collectors recorded into it
+type Metrics struct {
+	registry         *prometheus.Registry
+	token            string
+	requests         *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+	queryDuration    *prometheus.HistogramVec
+	statusesIngested *prometheus.CounterVec
+}
+
+// New creates the metrics registry. When token is not empty, scraping requires "Authorization: Bearer <token>".
+func New(token string) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		token:    token,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests handled, by route pattern and status code.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latency, by route pattern.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "db_query_duration_seconds",
+			Help:    "SQLite statement latency, by repository method and result.",
+			Buckets: []float64{.0001, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, 1},
+		}, []string{"repository", "method", "result"}),
+		statusesIngested: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "maze_statuses_ingested_total",
+			Help: "Maze device statuses stored, by device.",
+		}, []string{"device_id"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.queryDuration,
+		m.statusesIngested,
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format
+func (m *Metrics) Handler(logger *slog.Logger) http.Handler {
+	handler := promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{
+		ErrorLog: slog.NewLogLogger(logger.Handler(), slog.LevelError),
+	})
+	if m.token == "" {
+		return handler
+	}
+
+	expected := []byte("Bearer " + m.token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			problem.Write(w, r, http.StatusUnauthorized, "Unauthorized: Invalid metrics token.")
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// ObserveRequest records a handled HTTP request
+func (m *Metrics) ObserveRequest(method string, route string, status int, duration time.Duration) {
+	if route == "" {
+		route = "unmatched"
+	}
+	m.requests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	m.requestDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// ObserveQuery records an executed SQL statement, it is used as the database's DAL.QueryObserver
+func (m *Metrics) ObserveQuery(repository string, method string, duration time.Duration, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	m.queryDuration.WithLabelValues(repository, method, result).Observe(duration.Seconds())
+}
+
+// ObserveStatusIngested counts a status stored for a device
+func (m *Metrics) ObserveStatusIngested(deviceID string) {
+	m.statusesIngested.WithLabelValues(deviceID).Inc()
+}
+
+// RegisterDB exports the connection pool statistics of db
+func (m *Metrics) RegisterDB(db *sql.DB, name string) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterSockets exports the number of open WebSocket connections, connections returns it on every scrape
+func (m *Metrics) RegisterSockets(connections func() int) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "websocket_connections_open",
+		Help: "Open WebSocket connections.",
+	}, func() float64 { return float64(connections()) }))
+}
+
+// LatestStatusReader returns the most recent status of every device
+type LatestStatusReader interface {
+	ReadLatest(ctx context.Context) ([]*models.MazeDeviceStatus, error)
+}
+
+// RegisterDevices exports the number of online devices and active alarms, computed on every scrape
+func (m *Metrics) RegisterDevices(reader LatestStatusReader, logger *slog.Logger) {
+	m.registry.MustRegister(&deviceCollector{reader: reader, logger: logger})
+}
+
+var (
+	devicesOnlineDesc = prometheus.NewDesc("maze_devices_online", "Devices that reported a status within the last 5 minutes.", nil, nil)
+	alarmsActiveDesc  = prometheus.NewDesc("maze_alarms_active", "Devices whose latest status has the alarm active.", nil, nil)
+)
+
+type deviceCollector struct {
+	reader LatestStatusReader
+	logger *slog.Logger
+}
+
+func (c *deviceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- devicesOnlineDesc
+	ch <- alarmsActiveDesc
+}
+
+func (c *deviceCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	statuses, err := c.reader.ReadLatest(ctx)
+	if err != nil {
+		c.logger.Error("Error collecting device metrics", "error", err)
+		ch <- prometheus.NewInvalidMetric(devicesOnlineDesc, err)
+		return
+	}
+
+	var online, alarms int
+	for _, status := range statuses {
+		if reported, err := time.Parse(time.RFC3339, status.Timestamp); err == nil && time.Since(reported) <= DeviceOnlineWindow {
+			online++
+		}
+		if status.AlarmActive {
+			alarms++
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(devicesOnlineDesc, prometheus.GaugeValue, float64(online))
+	ch <- prometheus.MustNewConstMetric(alarmsActiveDesc, prometheus.GaugeValue, float64(alarms))
+}
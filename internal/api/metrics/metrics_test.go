@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"goapi/internal/api/repository/models"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type latestStatuses []*models.MazeDeviceStatus
+
+func (l latestStatuses) ReadLatest(ctx context.Context) ([]*models.MazeDeviceStatus, error) {
+	return l, nil
+}
+
+func scrape(t *testing.T, m *Metrics, authorization string) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rr := httptest.NewRecorder()
+	m.Handler(slog.Default()).ServeHTTP(rr, req)
+	body, _ := io.ReadAll(rr.Body)
+	return rr.Code, string(body)
+}
+
+func TestMetricsExposition(t *testing.T) {
+	m := New("")
+	now := time.Now().UTC()
+	m.RegisterDevices(latestStatuses{
+		{DeviceID: "ESP32_MAZE_001", AlarmActive: true, Timestamp: now.Format(time.RFC3339)},
+		{DeviceID: "ESP32_MAZE_002", Timestamp: now.Add(-time.Hour).Format(time.RFC3339)},
+	}, slog.Default())
+
+	m.ObserveRequest(http.MethodGet, "GET /data/{id}", http.StatusOK, 20*time.Millisecond)
+	m.ObserveQuery("DataRepository", "ReadOne", time.Millisecond, errors.New("database is locked"))
+	m.ObserveStatusIngested("ESP32_MAZE_001")
+
+	code, body := scrape(t, m, "")
+	if code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", code)
+	}
+
+	for _, expected := range []string{
+		`http_requests_total{method="GET",route="GET /data/{id}",status="200"} 1`,
+		`db_query_duration_seconds_count{method="ReadOne",repository="DataRepository",result="error"} 1`,
+		`maze_statuses_ingested_total{device_id="ESP32_MAZE_001"} 1`,
+		"maze_devices_online 1",
+		"maze_alarms_active 1",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected metrics to contain %q", expected)
+		}
+	}
+}
+
+func TestMetricsToken(t *testing.T) {
+	m := New("s3cret")
+
+	if code, _ := scrape(t, m, ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without token, got %d", code)
+	}
+	if code, _ := scrape(t, m, "Basic YWRtaW46cGFzc3dvcmQ="); code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 with Basic credentials, got %d", code)
+	}
+	if code, _ := scrape(t, m, "Bearer s3cret"); code != http.StatusOK {
+		t.Errorf("Expected status 200 with token, got %d", code)
+	}
+}
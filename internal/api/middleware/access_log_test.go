@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"goapi/internal/api/logging"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// * Test: Request ID is generated, echoed back and available to handlers
+func TestRequestIDGenerated(t *testing.T) {
+
+	req := httptest.NewRequest(http.MethodGet, "/data/0", nil)
+	rr := httptest.NewRecorder()
+
+	var seen string
+	handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = logging.RequestID(r.Context())
+	}))
+	handler.ServeHTTP(rr, req)
+
+	if seen == "" || rr.Header().Get(RequestIDHeader) != seen {
+		t.Errorf("Expected request ID %q to be echoed, got %q", seen, rr.Header().Get(RequestIDHeader))
+	}
+}
+
+// * Test: Valid client request IDs are reused, invalid ones replaced
+func TestRequestIDFromClient(t *testing.T) {
+
+	for id, reused := range map[string]bool{"device-42.boot-7": true, "bad id\n": false} {
+		req := httptest.NewRequest(http.MethodGet, "/data/0", nil)
+		req.Header.Set(RequestIDHeader, id)
+		rr := httptest.NewRecorder()
+
+		RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rr, req)
+
+		if got := rr.Header().Get(RequestIDHeader); (got == id) != reused {
+			t.Errorf("Client request ID %q: reused=%v, got %q", id, reused, got)
+		}
+	}
+}
+
+// * Test: Access log line contains route, status and the authenticated caller
+func TestAccessLog(t *testing.T) {
+
+	var buf bytes.Buffer
+	logger := logging.New(&buf, slog.LevelInfo)
+
+	req := httptest.NewRequest(http.MethodGet, "/device/config/7", nil)
+	req.SetBasicAuth("admin", "password")
+	rr := httptest.NewRecorder()
+
+	handler := ChainMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}),
+		BasicAuthenticationMiddleware,
+		NewAccessLogMiddleware(logger, func(r *http.Request) string { return "GET /device/config/{id}" }),
+		RequestIDMiddleware,
+	)
+	handler.ServeHTTP(rr, req)
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Expected a JSON access log line, got %q: %v", buf.String(), err)
+	}
+	if record["route"] != "GET /device/config/{id}" || record["status"] != float64(http.StatusNotFound) || record["user"] != "admin" {
+		t.Errorf("Unexpected access log record: %v", record)
+	}
+	if record["request_id"] != rr.Header().Get(RequestIDHeader) {
+		t.Errorf("Expected request_id %q, got %v", rr.Header().Get(RequestIDHeader), record["request_id"])
+	}
+}
+
+// * Test: Metrics middleware reports the route pattern and the status written by the handler
+func TestMetricsMiddleware(t *testing.T) {
+
+	req := httptest.NewRequest(http.MethodDelete, "/data/9", nil)
+	rr := httptest.NewRecorder()
+
+	var gotRoute string
+	var gotStatus int
+	handler := NewMetricsMiddleware(
+		func(r *http.Request) string { return "DELETE /data/{id}" },
+		func(method string, route string, status int, duration time.Duration) {
+			gotRoute, gotStatus = route, status
+		},
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	handler.ServeHTTP(rr, req)
+
+	if gotRoute != "DELETE /data/{id}" || gotStatus != http.StatusNoContent {
+		t.Errorf("Unexpected observation: %s %d", gotRoute, gotStatus)
+	}
+}
+
+// * Test: Metrics middleware reports non-standard methods as OTHER
+func TestMetricsMiddlewareOtherMethod(t *testing.T) {
+
+	for method, expected := range map[string]string{http.MethodPatch: http.MethodPatch, "PROPFIND": "OTHER", "get": "OTHER"} {
+		req := httptest.NewRequest(method, "/data/9", nil)
+
+		var gotMethod string
+		handler := NewMetricsMiddleware(
+			func(r *http.Request) string { return "" },
+			func(method string, route string, status int, duration time.Duration) {
+				gotMethod = method
+			},
+		)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if gotMethod != expected {
+			t.Errorf("Expected %s to be reported as %s, got %s", method, expected, gotMethod)
+		}
+	}
+}
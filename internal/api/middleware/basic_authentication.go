@@ -1,68 +1,252 @@
 package middleware
 
 import (
+	"context"
+	"crypto/subtle"
 	"encoding/base64"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/service/lockout"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type contextKey int
+
+const (
+	identityKey contextKey = iota
+	accessLogKey
 )
 
+// Identity describes the authenticated caller of a request
+type Identity struct {
+	Username string
+	DeviceID string // Set when the caller authenticated with credentials issued to a provisioned device
+}
+
+// IsAdmin reports whether the caller is a user account rather than a device
+func (i Identity) IsAdmin() bool {
+	return i.Username != "" && i.DeviceID == ""
+}
+
+// CanAccessDevice reports whether the caller may act for deviceID, devices may only act for themselves
+func (i Identity) CanAccessDevice(deviceID string) bool {
+	return i.DeviceID == "" || i.DeviceID == deviceID
+}
+
+// IdentityFromContext returns the identity stored by the authentication middleware
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityKey).(Identity)
+	return identity, ok
+}
+
+// WithIdentity returns a copy of ctx carrying identity
+func WithIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityKey, identity)
+}
+
+// CredentialValidator checks a username and password.
+// On success it returns the ID of the device the credentials were issued to, or "" for a user account.
+type CredentialValidator func(username string, password string, ctx context.Context) (deviceID string, ok bool)
+
+// TokenValidator checks a Bearer access token and returns the identity it was issued to
+type TokenValidator func(token string, ctx context.Context) (Identity, bool)
+
+// Default admin account, used when no other account is configured
+const (
+	DefaultUsername = "admin"
+	DefaultPassword = "password"
+)
+
+// LoginGuard tracks failed logins per username and source IP, to slow down and lock out guessing credentials
+type LoginGuard interface {
+	Locked(username string, ip string) (retryAfter time.Duration, locked bool)
+	Failed(username string, ip string, ctx context.Context) (delay time.Duration)
+	Succeeded(username string, ip string)
+}
+
 func BasicAuthenticationMiddleware(next http.Handler) http.Handler {
+	return NewBasicAuthenticationMiddleware(nil, nil, nil, UserAccount(DefaultUsername, DefaultPassword))(next)
+}
 
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+// UserAccount validates the credentials of a single user account, e.g. the configured admin
+func UserAccount(username string, password string) CredentialValidator {
 
-		// * If type is Option return
-		if r.Method == http.MethodOptions {
-			next.ServeHTTP(w, r)
-			return
-		}
+	return func(u string, p string, ctx context.Context) (string, bool) {
+		// Compare both to not leak which one was wrong through timing
+		userOK := subtle.ConstantTimeCompare([]byte(u), []byte(username)) == 1
+		passwordOK := subtle.ConstantTimeCompare([]byte(p), []byte(password)) == 1
+		return "", userOK && passwordOK
+	}
+}
 
-		authHeader := r.Header.Get("Authorization")
+// NewNoAuthenticationMiddleware runs every request as identity, for local development without credentials
+func NewNoAuthenticationMiddleware(identity Identity) Middleware {
 
-		if authHeader == "" {
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte(`{"error": "Unauthorized: Missing credentials."}`))
-			return
-		}
+	return func(next http.Handler) http.Handler {
 
-		// * Split the Authorization header to get the 'Basic' part and the encoded credentials part
-		headerParts := strings.Split(authHeader, " ")
-		if len(headerParts) != 2 || headerParts[0] != "Basic" {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte(`{"error": "Malformed or invalid Authorization header. [1]"}`))
-			return
-		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			recordIdentity(r.Context(), identity)
+			next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), identity)))
+		})
+	}
+}
 
-		// * Decode the credentials part of the header
-		decoded, err := base64.StdEncoding.DecodeString(headerParts[1])
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte(`{"error": "Malformed or invalid Authorization header. [2]"}`))
-			return
-		}
+// NewBasicAuthenticationMiddleware creates the Basic authentication middleware.
+// Requests for which isPublic returns true are passed through without credentials,
+// requests over mutual TLS without an Authorization header are identified by their client certificate,
+// Bearer access tokens are checked with tokens, Basic credentials by consulting validators in order until one accepts them.
+// When guard is set, failed logins are answered with a growing delay and locked out usernames or IP addresses
+// are rejected with 429 Too Many Requests before their credentials are checked.
+func NewBasicAuthenticationMiddleware(isPublic func(r *http.Request) bool, guard LoginGuard, tokens TokenValidator, validators ...CredentialValidator) Middleware {
 
-		// * Split the decoded credentials to get the username and password
-		credentials := strings.SplitN(string(decoded), ":", 2)
-		if len(credentials) != 2 {
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte(`{"error": "Malformed or invalid Authorization header. [3]"}`))
-			return
-		}
+	return func(next http.Handler) http.Handler {
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			// * If type is Option return
+			if r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// * Public routes, e.g. device provisioning, do not require credentials
+			if isPublic != nil && isPublic(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			authHeader := r.Header.Get("Authorization")
+
+			// * Devices with a client certificate verified against the client CA are identified by its CN
+			if identity, ok := ClientCertificateIdentity(r); ok && authHeader == "" {
+				recordIdentity(r.Context(), identity)
+				trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("device.id", identity.DeviceID))
+				next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), identity)))
+				return
+			}
+
+			if authHeader == "" {
+				problem.Write(w, r, http.StatusUnauthorized, "Unauthorized: Missing credentials.")
+				return
+			}
+
+			// * Split the Authorization header to get the 'Basic' or 'Bearer' part and the credentials part
+			headerParts := strings.Split(authHeader, " ")
+			if len(headerParts) != 2 || !(headerParts[0] == "Basic" || headerParts[0] == "Bearer" && tokens != nil) {
+				problem.Write(w, r, http.StatusBadRequest, "Malformed or invalid Authorization header. [1]")
+				return
+			}
+
+			// * Access tokens issued by POST /auth/login are verified without a database lookup
+			if headerParts[0] == "Bearer" {
+				ctx, span := otel.Tracer(tracerName).Start(r.Context(), "authenticate")
+				identity, ok := tokens(headerParts[1], ctx)
+				span.End()
+				if !ok {
+					w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+					problem.Write(w, r, http.StatusUnauthorized, "Unauthorized: Invalid or expired token.")
+					return
+				}
+				serveAuthenticated(w, r, next, identity)
+				return
+			}
 
-		username, password := credentials[0], credentials[1]
+			// * Decode the credentials part of the header
+			decoded, err := base64.StdEncoding.DecodeString(headerParts[1])
+			if err != nil {
+				problem.Write(w, r, http.StatusBadRequest, "Malformed or invalid Authorization header. [2]")
+				return
+			}
 
-		if !validateUser(username, password) {
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte(`{"error": "Unauthorized: Invalid credentials."}`))
+			// * Split the decoded credentials to get the username and password
+			credentials := strings.SplitN(string(decoded), ":", 2)
+			if len(credentials) != 2 {
+				problem.Write(w, r, http.StatusBadRequest, "Malformed or invalid Authorization header. [3]")
+				return
+			}
+
+			username, password := credentials[0], credentials[1]
+			ip := ClientIP(r)
+
+			// * Locked out callers are rejected without checking their credentials, so guessing gets no answers
+			if guard != nil {
+				if retryAfter, locked := guard.Locked(username, ip); locked {
+					seconds := strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))
+					w.Header().Set("Retry-After", seconds)
+					problem.Write(w, r, http.StatusTooManyRequests, "Too many failed logins, retry after "+seconds+" seconds.")
+					return
+				}
+			}
+
+			// Device credentials are checked against the database, trace them separately from the handler
+			ctx, span := otel.Tracer(tracerName).Start(r.Context(), "authenticate")
+			identity, ok := authenticate(username, password, ctx, validators)
+			span.End()
+			if !ok {
+				if guard != nil {
+					lockout.Wait(r.Context(), guard.Failed(username, ip, r.Context()))
+				}
+				problem.Write(w, r, http.StatusUnauthorized, "Unauthorized: Invalid credentials.")
+				return
+			}
+			if guard != nil {
+				guard.Succeeded(username, ip)
+			}
+
+			serveAuthenticated(w, r, next, identity)
+		})
+	}
+}
+
+// serveAuthenticated calls the next handler in the chain with the caller's identity in the request context
+func serveAuthenticated(w http.ResponseWriter, r *http.Request, next http.Handler, identity Identity) {
+	recordIdentity(r.Context(), identity)
+	trace.SpanFromContext(r.Context()).SetAttributes(
+		attribute.String("enduser.id", identity.Username),
+		attribute.String("device.id", identity.DeviceID),
+	)
+	next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), identity)))
+}
+
+// ClientCertificateIdentity returns the device identity of a request whose client certificate was verified
+// against the client CA, the certificate's common name is the device ID
+func ClientCertificateIdentity(r *http.Request) (Identity, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return Identity{}, false
+	}
+	deviceID := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	if deviceID == "" {
+		return Identity{}, false
+	}
+	return Identity{Username: deviceID, DeviceID: deviceID}, true
+}
+
+// AdminOnly rejects requests from callers that are not user accounts, e.g. provisioned devices
+func AdminOnly(next http.HandlerFunc) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		identity, _ := IdentityFromContext(r.Context())
+		if !identity.IsAdmin() {
+			problem.Write(w, r, http.StatusForbidden, "Forbidden: Admin privileges required.")
 			return
 		}
-
-		// Call the next handler in the chain
-		next.ServeHTTP(w, r)
-	})
+		next(w, r)
+	}
 }
 
-func validateUser(username, password string) bool {
+func authenticate(username, password string, ctx context.Context, validators []CredentialValidator) (Identity, bool) {
 
-	// ! This is a dummy implementation, replace this with real authentication logic
-	return username == "admin" && password == "password"
+	for _, validate := range validators {
+		if deviceID, ok := validate(username, password, ctx); ok {
+			return Identity{Username: username, DeviceID: deviceID}, true
+		}
+	}
+	return Identity{}, false
 }
@@ -1,9 +1,15 @@
 package middleware
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"goapi/internal/api/problem"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 // * Test: No Authorization header
@@ -25,8 +31,8 @@ func TestBasicAuthMissingAuthHeader(t *testing.T) {
 	}
 
 	// * Test if returned error message is correct
-	expected := `{"error": "Unauthorized: Missing credentials."}`
-	if rr.Body.String() != expected {
+	expected := "Unauthorized: Missing credentials."
+	if problem.Detail(rr.Body.Bytes()) != expected {
 		t.Errorf("Expected body %s, got %s", expected, rr.Body.String())
 	}
 }
@@ -51,8 +57,8 @@ func TestBasicAuthMalformedHeader(t *testing.T) {
 	}
 
 	// * Test if returned error message is correct
-	expected := `{"error": "Malformed or invalid Authorization header. [1]"}`
-	if rr.Body.String() != expected {
+	expected := "Malformed or invalid Authorization header. [1]"
+	if problem.Detail(rr.Body.Bytes()) != expected {
 		t.Errorf("Expected body %s, got %s", expected, rr.Body.String())
 	}
 }
@@ -76,8 +82,8 @@ func TestBasicAuthErrorOnBase64Decoding(t *testing.T) {
 	}
 
 	// * Test if returned error message is correct
-	expected := `{"error": "Malformed or invalid Authorization header. [2]"}`
-	if rr.Body.String() != expected {
+	expected := "Malformed or invalid Authorization header. [2]"
+	if problem.Detail(rr.Body.Bytes()) != expected {
 		t.Errorf("Expected body %s, got %s", expected, rr.Body.String())
 	}
 }
@@ -101,8 +107,8 @@ func TestBasicAuthErrorOnSplitDecodedString(t *testing.T) {
 	}
 
 	// * Test if returned error message is correct
-	expected := `{"error": "Malformed or invalid Authorization header. [3]"}`
-	if rr.Body.String() != expected {
+	expected := "Malformed or invalid Authorization header. [3]"
+	if problem.Detail(rr.Body.Bytes()) != expected {
 		t.Errorf("Expected body %s, got %s", expected, rr.Body.String())
 	}
 }
@@ -126,9 +132,233 @@ func TestBasicAuthInvalidCredentials(t *testing.T) {
 	}
 
 	// * Test if returned error message is correct
-	expected := `{"error": "Unauthorized: Invalid credentials."}`
-	if rr.Body.String() != expected {
+	expected := "Unauthorized: Invalid credentials."
+	if problem.Detail(rr.Body.Bytes()) != expected {
 		t.Errorf("Expected body %s, got %s", expected, rr.Body.String())
 	}
 
 }
+
+func TestBasicAuthStoresIdentity(t *testing.T) {
+
+	req := httptest.NewRequest(http.MethodGet, "/data/0", nil)
+	req.SetBasicAuth("admin", "password")
+
+	rr := httptest.NewRecorder()
+	handler := BasicAuthenticationMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := IdentityFromContext(r.Context())
+		if !ok || identity.Username != "admin" || !identity.IsAdmin() {
+			t.Errorf("Expected admin identity in context, got %+v", identity)
+		}
+	}))
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestBasicAuthDeviceCredentials(t *testing.T) {
+
+	req := httptest.NewRequest(http.MethodPost, "/device/status", nil)
+	req.SetBasicAuth("ESP32_MAZE_002", "secret")
+
+	validator := func(username, password string, ctx context.Context) (string, bool) {
+		return username, username == "ESP32_MAZE_002" && password == "secret"
+	}
+
+	rr := httptest.NewRecorder()
+	handler := NewBasicAuthenticationMiddleware(nil, nil, nil, validator)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, _ := IdentityFromContext(r.Context())
+		if identity.DeviceID != "ESP32_MAZE_002" || identity.IsAdmin() {
+			t.Errorf("Expected device identity in context, got %+v", identity)
+		}
+	}))
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestBasicAuthPublicRoute(t *testing.T) {
+
+	req := httptest.NewRequest(http.MethodPost, "/provisioning/claim", nil)
+	called := false
+
+	rr := httptest.NewRecorder()
+	isPublic := func(r *http.Request) bool { return r.URL.Path == "/provisioning/claim" }
+	handler := NewBasicAuthenticationMiddleware(isPublic, nil, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	handler.ServeHTTP(rr, req)
+
+	if !called {
+		t.Error("Handler should have been called for a public route without credentials")
+	}
+}
+
+func TestAdminOnlyRejectsDevices(t *testing.T) {
+
+	req := httptest.NewRequest(http.MethodPost, "/provisioning/claims", nil)
+	req = req.WithContext(WithIdentity(req.Context(), Identity{Username: "ESP32_MAZE_002", DeviceID: "ESP32_MAZE_002"}))
+
+	rr := httptest.NewRecorder()
+	AdminOnly(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Handler should not have been called")
+	})(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d, got %d", http.StatusForbidden, rr.Code)
+	}
+}
+
+func TestBasicAuthConfiguredAccount(t *testing.T) {
+
+	handler := NewBasicAuthenticationMiddleware(nil, nil, nil, UserAccount("operator", "s3cret"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, _ := IdentityFromContext(r.Context())
+		if identity.Username != "operator" || !identity.IsAdmin() {
+			t.Errorf("Expected operator identity in context, got %+v", identity)
+		}
+	}))
+
+	for credentials, expected := range map[[2]string]int{
+		{"operator", "s3cret"}:   http.StatusOK,
+		{"admin", "password"}:    http.StatusUnauthorized,
+		{"operator", "password"}: http.StatusUnauthorized,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/data/0", nil)
+		req.SetBasicAuth(credentials[0], credentials[1])
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != expected {
+			t.Errorf("Expected status code %d for %s, got %d", expected, credentials[0], rr.Code)
+		}
+	}
+}
+
+func TestNoAuthenticationUsesIdentity(t *testing.T) {
+
+	req := httptest.NewRequest(http.MethodGet, "/data/0", nil)
+	rr := httptest.NewRecorder()
+
+	handler := NewNoAuthenticationMiddleware(Identity{Username: "admin"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, _ := IdentityFromContext(r.Context())
+		if !identity.IsAdmin() {
+			t.Errorf("Expected admin identity in context, got %+v", identity)
+		}
+	}))
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestBasicAuthClientCertificate(t *testing.T) {
+
+	req := httptest.NewRequest(http.MethodPost, "/device/status", nil)
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "ESP32_MAZE_002"}}}}}
+
+	rr := httptest.NewRecorder()
+	handler := NewBasicAuthenticationMiddleware(nil, nil, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, _ := IdentityFromContext(r.Context())
+		if identity.DeviceID != "ESP32_MAZE_002" || !identity.CanAccessDevice("ESP32_MAZE_002") || identity.CanAccessDevice("ESP32_MAZE_001") {
+			t.Errorf("Expected device identity from the certificate, got %+v", identity)
+		}
+	}))
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+type mockLoginGuard struct {
+	locked    map[string]bool
+	failed    []string
+	succeeded []string
+}
+
+func (g *mockLoginGuard) Locked(username, ip string) (time.Duration, bool) {
+	return 90 * time.Second, g.locked[username]
+}
+
+func (g *mockLoginGuard) Failed(username, ip string, ctx context.Context) time.Duration {
+	g.failed = append(g.failed, username+"@"+ip)
+	return time.Millisecond
+}
+
+func (g *mockLoginGuard) Succeeded(username, ip string) {
+	g.succeeded = append(g.succeeded, username+"@"+ip)
+}
+
+func TestBasicAuthLoginGuard(t *testing.T) {
+
+	guard := &mockLoginGuard{locked: map[string]bool{"locked": true}}
+	handler := NewBasicAuthenticationMiddleware(nil, guard, nil, UserAccount("operator", "s3cret"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, tt := range []struct {
+		username, password string
+		expected           int
+	}{
+		{"operator", "wrong", http.StatusUnauthorized},
+		{"operator", "s3cret", http.StatusOK},
+		{"locked", "s3cret", http.StatusTooManyRequests},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/data/0", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.SetBasicAuth(tt.username, tt.password)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.expected {
+			t.Errorf("Expected status code %d for %s, got %d", tt.expected, tt.username, rr.Code)
+		}
+		if tt.expected == http.StatusTooManyRequests && rr.Header().Get("Retry-After") != "90" {
+			t.Errorf("Expected Retry-After 90, got %q", rr.Header().Get("Retry-After"))
+		}
+	}
+
+	if len(guard.failed) != 1 || guard.failed[0] != "operator@192.0.2.1" {
+		t.Errorf("Expected one failed login of operator@192.0.2.1, got %v", guard.failed)
+	}
+	if len(guard.succeeded) != 1 || guard.succeeded[0] != "operator@192.0.2.1" {
+		t.Errorf("Expected one successful login of operator@192.0.2.1, got %v", guard.succeeded)
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+
+	tokens := func(token string, ctx context.Context) (Identity, bool) {
+		if token == "device-token" {
+			return Identity{Username: "ESP32_MAZE_002", DeviceID: "ESP32_MAZE_002"}, true
+		}
+		return Identity{}, false
+	}
+	handler := NewBasicAuthenticationMiddleware(nil, nil, tokens, UserAccount("admin", "password"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, _ := IdentityFromContext(r.Context())
+		if identity.DeviceID != "ESP32_MAZE_002" {
+			t.Errorf("Expected device identity from the token, got %+v", identity)
+		}
+	}))
+
+	for header, expected := range map[string]int{
+		"Bearer device-token": http.StatusOK,
+		"Bearer expired":      http.StatusUnauthorized,
+		"Token device-token":  http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/device/status", nil)
+		req.Header.Set("Authorization", header)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != expected {
+			t.Errorf("Expected status code %d for %q, got %d", expected, header, rr.Code)
+		}
+		if expected == http.StatusUnauthorized && rr.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("Expected a WWW-Authenticate challenge for %q", header)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"goapi/internal/api/problem"
+	"net/http"
+	"strconv"
+)
+
+// NewBodyLimitMiddleware caps the size of request bodies, limit returns the maximum in bytes for the request.
+// Bodies announcing a larger Content-Length are rejected with 413, others fail to decode once the limit is reached.
+func NewBodyLimitMiddleware(limit func(r *http.Request) int64) Middleware {
+
+	return func(next http.Handler) http.Handler {
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			maxBytes := limit(r)
+			if r.ContentLength > maxBytes {
+				problem.Write(w, r, http.StatusRequestEntityTooLarge, "Request body too large, the limit is "+strconv.FormatInt(maxBytes, 10)+" bytes.")
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
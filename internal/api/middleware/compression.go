@@ -0,0 +1,233 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// compressMinBytes is the smallest body worth compressing, shorter ones are sent as they are
+const compressMinBytes = 1024
+
+// encoder is a compressing writer that can be reused for another response
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// encodings are the supported content codings, brotli is preferred when a client accepts both equally
+var encodings = []string{"br", "gzip"}
+
+var encoders = map[string]*sync.Pool{
+	// Level 5 keeps most of brotli's gain over gzip at a speed suited to responses compressed on the fly
+	"br": {New: func() any { return brotli.NewWriterLevel(nil, 5) }},
+	"gzip": {New: func() any {
+		w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return w
+	}},
+}
+
+// NewCompressionMiddleware compresses responses with brotli or gzip, whichever the Accept-Encoding header prefers.
+// Only textual media types are compressed, and only once the body grows past compressMinBytes or is flushed, so
+// short responses and streams are not held back. Responses that already have a Content-Encoding are left alone.
+func NewCompressionMiddleware() Middleware {
+
+	return func(next http.Handler) http.Handler {
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// * HEAD responses have no body and protocol upgrades take the connection over *
+			if r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Accept-Encoding")
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			if encoding == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+			next.ServeHTTP(cw, r)
+			cw.close()
+		})
+	}
+}
+
+// negotiateEncoding returns the supported content coding the Accept-Encoding header prefers, "" for none.
+// Codings are weighed by their q-value, * stands for the codings not listed and a q-value of 0 refuses a coding.
+func negotiateEncoding(header string) string {
+	weights := make(map[string]float64)
+	wildcard := 0.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if name == "x-gzip" {
+			name = "gzip"
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				parsed, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					parsed = 0
+				}
+				q = parsed
+			}
+		}
+		if name == "*" {
+			wildcard = q
+		} else {
+			weights[name] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range encodings {
+		q, listed := weights[encoding]
+		if !listed {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// compressible reports whether a media type is text that compresses well
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"), strings.HasSuffix(mediaType, "+json"), strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/x-ndjson", "application/yaml", "application/javascript", "application/xml":
+		return true
+	}
+	return false
+}
+
+// compressWriter holds back the start of a response until it knows whether compressing it pays off. ETags are kept
+// as they are, they name the version of a resource rather than the bytes sent.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	status   int
+	buffer   []byte
+	encoder  encoder
+	started  bool
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.started || status < http.StatusOK {
+		// Informational responses like 103 Early Hints go out right away
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if cw.status == 0 {
+		cw.status = status
+	}
+	if status == http.StatusNoContent || status == http.StatusNotModified {
+		cw.start(false)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.started {
+		if cw.encoder != nil {
+			return cw.encoder.Write(b)
+		}
+		return cw.ResponseWriter.Write(b)
+	}
+
+	cw.buffer = append(cw.buffer, b...)
+	if len(cw.buffer) >= compressMinBytes {
+		if err := cw.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush sends what was written so far, a response flushed before it grew past compressMinBytes is a stream and is
+// still compressed
+func (cw *compressWriter) Flush() {
+	if !cw.started {
+		cw.start(true)
+	}
+	if cw.encoder != nil {
+		cw.encoder.Flush()
+	}
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// start sends the status line, compressing the body when compress is set and the response lends itself to it
+func (cw *compressWriter) start(compress bool) error {
+	cw.started = true
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+
+	header := cw.Header()
+	if compress && cw.status != http.StatusNoContent && cw.status != http.StatusNotModified &&
+		header.Get("Content-Encoding") == "" && compressible(header.Get("Content-Type")) {
+		header.Set("Content-Encoding", cw.encoding)
+		header.Del("Content-Length")
+		cw.encoder = encoders[cw.encoding].Get().(encoder)
+		cw.encoder.Reset(cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	buffered := cw.buffer
+	cw.buffer = nil
+	if len(buffered) == 0 {
+		return nil
+	}
+	if cw.encoder != nil {
+		_, err := cw.encoder.Write(buffered)
+		return err
+	}
+	_, err := cw.ResponseWriter.Write(buffered)
+	return err
+}
+
+// close sends a response too short to compress and ends a compressed one
+func (cw *compressWriter) close() {
+	if !cw.started {
+		if cw.status == 0 && len(cw.buffer) == 0 {
+			// Nothing was written, net/http sends the empty 200
+			return
+		}
+		cw.start(false)
+		return
+	}
+	if cw.encoder == nil {
+		return
+	}
+	cw.encoder.Close()
+	cw.encoder.Reset(io.Discard)
+	encoders[cw.encoding].Put(cw.encoder)
+	cw.encoder = nil
+}
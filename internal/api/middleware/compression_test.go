@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{header: "", want: ""},
+		{header: "gzip", want: "gzip"},
+		{header: "gzip, deflate, br", want: "br"},
+		{header: "br;q=0.5, gzip", want: "gzip"},
+		{header: "br;q=0, *", want: "gzip"},
+		{header: "*;q=0.1", want: "br"},
+		{header: "identity", want: ""},
+		{header: "deflate, x-gzip;q=0.5", want: "gzip"},
+		{header: "GZIP;q=1.0", want: "gzip"},
+		{header: "gzip;q=0", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := negotiateEncoding(tt.header); got != tt.want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestCompressionMiddleware(t *testing.T) {
+	long := `[` + strings.Repeat(`{"device_id":"ARD001","battery_level":85},`, 100) + `{}]`
+
+	tests := []struct {
+		name           string
+		method         string
+		acceptEncoding string
+		contentType    string
+		encoded        string // Content-Encoding set by the handler
+		body           string
+		want           string // Expected Content-Encoding
+	}{
+		{name: "Gzip", acceptEncoding: "gzip", contentType: "application/json", body: long, want: "gzip"},
+		{name: "Brotli", acceptEncoding: "gzip, br", contentType: "application/json", body: long, want: "br"},
+		{name: "CSV", acceptEncoding: "gzip", contentType: "text/csv; charset=utf-8", body: long, want: "gzip"},
+		{name: "Short body", acceptEncoding: "gzip", contentType: "application/json", body: `{"id":1}`},
+		{name: "No Accept-Encoding", contentType: "application/json", body: long},
+		{name: "Binary body", acceptEncoding: "gzip", contentType: "application/octet-stream", body: long},
+		{name: "Already encoded", acceptEncoding: "gzip", contentType: "application/json", encoded: "br", body: long, want: "br"},
+		{name: "HEAD", method: http.MethodHead, acceptEncoding: "gzip", contentType: "application/json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewCompressionMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				if tt.encoded != "" {
+					w.Header().Set("Content-Encoding", tt.encoded)
+				}
+				w.WriteHeader(http.StatusOK)
+				// Written in pieces, like a streamed list
+				for _, piece := range strings.SplitAfter(tt.body, "},") {
+					io.WriteString(w, piece)
+				}
+			}))
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, "/v1/device/status", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d", rr.Code)
+			}
+			if got := rr.Header().Get("Content-Encoding"); got != tt.want {
+				t.Fatalf("Content-Encoding = %q, want %q", got, tt.want)
+			}
+			if tt.method != http.MethodHead && rr.Header().Get("Vary") != "Accept-Encoding" {
+				t.Errorf("Expected Vary: Accept-Encoding, got %q", rr.Header().Get("Vary"))
+			}
+			if tt.encoded != "" {
+				return
+			}
+
+			var body io.Reader = rr.Body
+			switch tt.want {
+			case "gzip":
+				zr, err := gzip.NewReader(rr.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body = zr
+			case "br":
+				body = brotli.NewReader(rr.Body)
+			}
+			decoded, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(decoded) != tt.body {
+				t.Errorf("Body changed, got %d bytes, want %d", len(decoded), len(tt.body))
+			}
+		})
+	}
+}
+
+func TestCompressionMiddlewareFlush(t *testing.T) {
+	handler := NewCompressionMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		io.WriteString(w, `{"id":1}`+"\n")
+		http.NewResponseController(w).Flush()
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/export/data", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	// A flushed response is a stream, compressed however short it is
+	if !rr.Flushed || rr.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected a flushed gzip stream, got flushed %v with %q", rr.Flushed, rr.Header().Get("Content-Encoding"))
+	}
+	zr, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded, _ := io.ReadAll(zr); string(decoded) != `{"id":1}`+"\n" {
+		t.Errorf("Unexpected body %q", decoded)
+	}
+}
+
+func TestCompressionMiddlewareNoContent(t *testing.T) {
+	handler := NewCompressionMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	req := httptest.NewRequest(http.MethodDelete, "/v1/data/1", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNoContent || rr.Header().Get("Content-Encoding") != "" || rr.Body.Len() != 0 {
+		t.Errorf("Expected an uncompressed 204, got %d with %q", rr.Code, rr.Header().Get("Content-Encoding"))
+	}
+}
@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ContractValidator checks traffic against the documented API, route is the pattern that matched the request
+type ContractValidator interface {
+	ValidateRequest(route string, r *http.Request, body []byte) models.FieldErrors
+	ValidateResponse(route string, status int, header http.Header, body []byte) models.FieldErrors
+}
+
+// contractRecorder passes a response through and keeps a copy of JSON bodies, other bodies like firmware binaries
+// are only passed through
+type contractRecorder struct {
+	http.ResponseWriter
+	status int
+	json   bool
+	body   bytes.Buffer
+}
+
+func (rec *contractRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+		mediaType, _, _ := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+		rec.json = mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *contractRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.WriteHeader(http.StatusOK)
+	}
+	if rec.json {
+		rec.body.Write(b)
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (rec *contractRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// Hijack hands the connection over to a protocol upgrade like a WebSocket, which answers with 101 itself
+func (rec *contractRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(rec.ResponseWriter).Hijack()
+	if err == nil && rec.status == 0 {
+		rec.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// NewContractMiddleware checks requests and responses against the OpenAPI document.
+// Violations are logged at warn level. When enforce is set, invalid requests are rejected with a validation problem
+// before they reach the handler, responses are never changed. route returns the pattern that matched the request.
+func NewContractMiddleware(validator ContractValidator, route func(r *http.Request) string, enforce bool, logger *slog.Logger) Middleware {
+
+	return func(next http.Handler) http.Handler {
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pattern := route(r)
+			if pattern == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var body []byte
+			if r.Body != nil && r.Body != http.NoBody {
+				var err error
+				body, err = io.ReadAll(r.Body)
+				if err != nil {
+					var tooLarge *http.MaxBytesError
+					if errors.As(err, &tooLarge) {
+						problem.Write(w, r, http.StatusRequestEntityTooLarge, "Request body too large, the limit is "+strconv.FormatInt(tooLarge.Limit, 10)+" bytes.")
+						return
+					}
+					problem.Write(w, r, http.StatusBadRequest, "Invalid request data. Please check your input.")
+					return
+				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+			}
+
+			if fields := validator.ValidateRequest(pattern, r, body); len(fields) > 0 {
+				logger.WarnContext(r.Context(), "Request does not match the API contract", "route", pattern, "errors", fields.String())
+				if enforce {
+					problem.Invalid(w, r, "Request does not match the API contract.", fields)
+					return
+				}
+			}
+
+			rec := &contractRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if fields := validator.ValidateResponse(pattern, status, w.Header(), rec.body.Bytes()); len(fields) > 0 {
+				logger.WarnContext(r.Context(), "Response does not match the API contract", "route", pattern, "status", status, "errors", fields.String())
+			}
+		})
+	}
+}
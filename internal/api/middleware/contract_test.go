@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/repository/models"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Accepts request bodies that are JSON objects and responses with status 200, records what it was asked to check
+type objectValidator struct {
+	route    string
+	response []byte
+}
+
+func (v *objectValidator) ValidateRequest(route string, r *http.Request, body []byte) models.FieldErrors {
+	v.route = route
+	if !bytes.HasPrefix(body, []byte("{")) {
+		return models.FieldErrors{{Field: "body", Message: "must be an object"}}
+	}
+	return nil
+}
+
+func (v *objectValidator) ValidateResponse(route string, status int, header http.Header, body []byte) models.FieldErrors {
+	v.response = body
+	if status != http.StatusOK {
+		return models.FieldErrors{{Field: "status", Message: "is not documented"}}
+	}
+	return nil
+}
+
+func newContractHandler(validator ContractValidator, enforce bool, logs *bytes.Buffer) (http.Handler, *int) {
+	calls := 0
+	route := func(r *http.Request) string { return "POST /device/status" }
+	logger := slog.New(slog.NewTextHandler(logs, nil))
+	handler := NewContractMiddleware(validator, route, enforce, logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		// The body is still readable after the middleware checked it
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+	}))
+	return handler, &calls
+}
+
+func TestContractPassesValidRequest(t *testing.T) {
+	validator := &objectValidator{}
+	var logs bytes.Buffer
+	handler, calls := newContractHandler(validator, true, &logs)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/device/status", strings.NewReader(`{"device_id":"ARD001"}`)))
+
+	if rr.Code != http.StatusOK || *calls != 1 {
+		t.Fatalf("got status %v after %v calls, want %v after 1 call", rr.Code, *calls, http.StatusOK)
+	}
+	if rr.Body.String() != `{"device_id":"ARD001"}` {
+		t.Errorf("handler received body %q", rr.Body.String())
+	}
+	if validator.route != "POST /device/status" || string(validator.response) != `{"device_id":"ARD001"}` {
+		t.Errorf("validator checked route %q and response %q", validator.route, validator.response)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("unexpected log output: %s", logs.String())
+	}
+}
+
+func TestContractEnforceRejectsInvalidRequest(t *testing.T) {
+	var logs bytes.Buffer
+	handler, calls := newContractHandler(&objectValidator{}, true, &logs)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/device/status", strings.NewReader(`[]`)))
+
+	if rr.Code != http.StatusBadRequest || *calls != 0 {
+		t.Fatalf("got status %v after %v calls, want %v after 0 calls", rr.Code, *calls, http.StatusBadRequest)
+	}
+	var p problem.Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Type != problem.TypeValidation || len(p.Errors) != 1 || p.Errors[0].Field != "body" {
+		t.Errorf("unexpected problem: %+v", p)
+	}
+	if !strings.Contains(logs.String(), "Request does not match the API contract") {
+		t.Errorf("violation was not logged: %s", logs.String())
+	}
+}
+
+func TestContractLogPassesInvalidRequest(t *testing.T) {
+	var logs bytes.Buffer
+	handler, calls := newContractHandler(&objectValidator{}, false, &logs)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/device/status", strings.NewReader(`[]`)))
+
+	if rr.Code != http.StatusOK || *calls != 1 {
+		t.Fatalf("got status %v after %v calls, want %v after 1 call", rr.Code, *calls, http.StatusOK)
+	}
+	if !strings.Contains(logs.String(), "Request does not match the API contract") {
+		t.Errorf("violation was not logged: %s", logs.String())
+	}
+}
+
+func TestContractLogsInvalidResponse(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	route := func(r *http.Request) string { return "GET /data/{id}" }
+	handler := NewContractMiddleware(&objectValidator{}, route, true, logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/data/1", strings.NewReader(`{}`)))
+
+	// Responses are never changed, only logged
+	if rr.Code != http.StatusTeapot {
+		t.Errorf("got status %v, want %v", rr.Code, http.StatusTeapot)
+	}
+	if !strings.Contains(logs.String(), "Response does not match the API contract") {
+		t.Errorf("violation was not logged: %s", logs.String())
+	}
+}
@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"goapi/internal/api/problem"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSPolicy decides which browser origins may call the API and what their requests may contain
+type CORSPolicy struct {
+	AllowedOrigins   []string // Origins like "https://dashboard.example.com", "*" allows every origin
+	AllowedMethods   []string
+	AllowedHeaders   []string // "*" allows whatever request headers a preflight asks for
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration // 0 leaves out Access-Control-Max-Age
+}
+
+// allows reports whether requests from origin are allowed
+func (p CORSPolicy) allows(origin string) bool {
+	return slices.Contains(p.AllowedOrigins, "*") || slices.ContainsFunc(p.AllowedOrigins, func(allowed string) bool {
+		return strings.EqualFold(allowed, origin)
+	})
+}
+
+// allowOrigin sets the headers every response to an allowed origin carries. With credentials the origin is named,
+// browsers reject * on credentialed responses.
+func (p CORSPolicy) allowOrigin(header http.Header, origin string) {
+	if slices.Contains(p.AllowedOrigins, "*") && !p.AllowCredentials {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// NewCORSMiddleware answers OPTIONS requests and adds the CORS headers of policy to every response. methods returns
+// the methods registered for the request's path, a preflight is answered with those the policy allows, so a browser
+// learns per route what it may send. Responses to origins the policy does not allow carry no CORS headers, and
+// preflights from them are rejected with 403. OPTIONS requests are answered here, before authentication, since
+// browsers send preflights without credentials.
+func NewCORSMiddleware(policy CORSPolicy, methods func(r *http.Request) []string) Middleware {
+	exposed := strings.Join(policy.ExposedHeaders, ", ")
+	allowedHeaders := strings.Join(policy.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(int(policy.MaxAge.Seconds()))
+
+	return func(next http.Handler) http.Handler {
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := w.Header()
+			header.Add("Vary", "Origin")
+			origin := r.Header.Get("Origin")
+			allowed := origin != "" && policy.allows(origin)
+
+			if r.Method != http.MethodOptions {
+				if allowed {
+					policy.allowOrigin(header, origin)
+					if exposed != "" {
+						header.Set("Access-Control-Expose-Headers", exposed)
+					}
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			registered := methods(r)
+			if len(registered) == 0 {
+				problem.Write(w, r, http.StatusNotFound, "Resource not found.")
+				return
+			}
+
+			// * Without Access-Control-Request-Method it is a plain OPTIONS request asking what the path supports *
+			requestedMethod := r.Header.Get("Access-Control-Request-Method")
+			if origin == "" || requestedMethod == "" {
+				header.Set("Allow", strings.Join(append(registered, http.MethodOptions), ", "))
+				if allowed {
+					policy.allowOrigin(header, origin)
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			if !allowed {
+				problem.Write(w, r, http.StatusForbidden, "Origin "+origin+" is not allowed.")
+				return
+			}
+
+			// * The browser compares the requested method and headers with these lists itself *
+			var permitted []string
+			for _, method := range registered {
+				if slices.Contains(policy.AllowedMethods, method) {
+					permitted = append(permitted, method)
+				}
+			}
+			policy.allowOrigin(header, origin)
+			if len(permitted) > 0 {
+				header.Set("Access-Control-Allow-Methods", strings.Join(permitted, ", "))
+			}
+			if slices.Contains(policy.AllowedHeaders, "*") {
+				// Authorization is never covered by *, so the requested headers are named instead
+				if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+					header.Set("Access-Control-Allow-Headers", requested)
+				}
+			} else if allowedHeaders != "" {
+				header.Set("Access-Control-Allow-Headers", allowedHeaders)
+			}
+			if policy.MaxAge > 0 {
+				header.Set("Access-Control-Max-Age", maxAge)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
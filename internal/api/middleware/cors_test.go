@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+	policy := CORSPolicy{
+		AllowedOrigins: []string{"https://dashboard.example.com"},
+		AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodDelete},
+		AllowedHeaders: []string{"Authorization", "Content-Type"},
+		ExposedHeaders: []string{"ETag", "X-Request-ID"},
+		MaxAge:         10 * time.Minute,
+	}
+	methods := func(r *http.Request) []string {
+		if r.URL.Path == "/v1/data/1" {
+			return []string{http.MethodGet, http.MethodPatch, http.MethodDelete}
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		origin        string
+		requestMethod string // Access-Control-Request-Method
+		status        int
+		header        map[string]string // Expected response headers, "" for absent ones
+	}{
+		{
+			name: "Preflight", method: http.MethodOptions, path: "/v1/data/1", origin: "https://dashboard.example.com", requestMethod: http.MethodDelete, status: http.StatusNoContent,
+			header: map[string]string{
+				"Access-Control-Allow-Origin":  "https://dashboard.example.com",
+				"Access-Control-Allow-Methods": "GET, DELETE",
+				"Access-Control-Allow-Headers": "Authorization, Content-Type",
+				"Access-Control-Max-Age":       "600",
+			},
+		},
+		{
+			name: "Preflight from other origin", method: http.MethodOptions, path: "/v1/data/1", origin: "https://evil.example.com", requestMethod: http.MethodGet, status: http.StatusForbidden,
+			header: map[string]string{"Access-Control-Allow-Origin": "", "Access-Control-Allow-Methods": ""},
+		},
+		{
+			name: "Preflight of unknown path", method: http.MethodOptions, path: "/v1/nothing", origin: "https://dashboard.example.com", requestMethod: http.MethodGet, status: http.StatusNotFound,
+		},
+		{
+			name: "Plain OPTIONS", method: http.MethodOptions, path: "/v1/data/1", status: http.StatusNoContent,
+			header: map[string]string{"Allow": "GET, PATCH, DELETE, OPTIONS", "Access-Control-Allow-Origin": ""},
+		},
+		{
+			name: "Allowed origin", method: http.MethodGet, path: "/v1/data/1", origin: "https://DASHBOARD.example.com", status: http.StatusOK,
+			header: map[string]string{
+				"Access-Control-Allow-Origin":      "https://DASHBOARD.example.com",
+				"Access-Control-Expose-Headers":    "ETag, X-Request-ID",
+				"Access-Control-Allow-Credentials": "",
+			},
+		},
+		{
+			name: "Other origin", method: http.MethodGet, path: "/v1/data/1", origin: "https://evil.example.com", status: http.StatusOK,
+			header: map[string]string{"Access-Control-Allow-Origin": "", "Access-Control-Expose-Headers": ""},
+		},
+		{
+			name: "Same origin", method: http.MethodGet, path: "/v1/data/1", status: http.StatusOK,
+			header: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewCORSMiddleware(policy, methods)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodOptions {
+					t.Error("OPTIONS request reached the handler")
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.requestMethod != "" {
+				req.Header.Set("Access-Control-Request-Method", tt.requestMethod)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d", tt.status, rr.Code)
+			}
+			if rr.Header().Values("Vary")[0] != "Origin" {
+				t.Errorf("Expected Vary: Origin, got %q", rr.Header().Values("Vary"))
+			}
+			for name, want := range tt.header {
+				if got := rr.Header().Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCORSMiddlewareWildcards(t *testing.T) {
+	tests := []struct {
+		name        string
+		credentials bool
+		origin      string
+	}{
+		{name: "Any origin", origin: "*"},
+		// * Credentialed responses must name the origin, browsers reject * *
+		{name: "Credentials", credentials: true, origin: "https://dashboard.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := CORSPolicy{AllowedOrigins: []string{"*"}, AllowedMethods: []string{http.MethodPost}, AllowedHeaders: []string{"*"}, AllowCredentials: tt.credentials}
+			handler := NewCORSMiddleware(policy, func(r *http.Request) []string { return []string{http.MethodPost} })(http.NotFoundHandler())
+
+			req := httptest.NewRequest(http.MethodOptions, "/v1/data", nil)
+			req.Header.Set("Origin", "https://dashboard.example.com")
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			req.Header.Set("Access-Control-Request-Headers", "authorization, content-type")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if got := rr.Header().Get("Access-Control-Allow-Origin"); got != tt.origin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.origin)
+			}
+			if got := rr.Header().Get("Access-Control-Allow-Headers"); got != "authorization, content-type" {
+				t.Errorf("Expected the requested headers to be allowed, got %q", got)
+			}
+			if rr.Header().Get("Access-Control-Max-Age") != "" {
+				t.Errorf("Expected no Access-Control-Max-Age without a max age, got %q", rr.Header().Get("Access-Control-Max-Age"))
+			}
+		})
+	}
+}
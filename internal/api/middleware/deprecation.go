@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// NewDeprecationMiddleware marks every response of a deprecated route with the Deprecation header (RFC 9745) and
+// links the route replacing it. successor returns the path that replaces the request's path, "" when its route is
+// not deprecated. A zero sunset leaves out the Sunset header (RFC 8594).
+func NewDeprecationMiddleware(successor func(r *http.Request) string, deprecatedAt time.Time, sunset time.Time) Middleware {
+	deprecation := "@" + strconv.FormatInt(deprecatedAt.Unix(), 10)
+
+	return func(next http.Handler) http.Handler {
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if path := successor(r); path != "" {
+				w.Header().Set("Deprecation", deprecation)
+				if !sunset.IsZero() {
+					w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+				}
+				w.Header().Add("Link", "<"+path+">; rel=\"successor-version\"")
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
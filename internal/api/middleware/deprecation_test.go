@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeprecationMiddleware(t *testing.T) {
+
+	successor := func(r *http.Request) string {
+		if strings.HasPrefix(r.URL.Path, "/v1/") {
+			return ""
+		}
+		return "/v1" + r.URL.Path
+	}
+	deprecatedAt := time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, time.June, 30, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		path        string
+		sunset      time.Time
+		deprecation string
+		wantSunset  string
+		link        string
+	}{
+		{name: "Legacy route", path: "/data/1", sunset: sunset, deprecation: "@1792281600", wantSunset: "Wed, 30 Jun 2027 00:00:00 GMT", link: `</v1/data/1>; rel="successor-version"`},
+		{name: "Legacy route without sunset", path: "/data/1", deprecation: "@1792281600", link: `</v1/data/1>; rel="successor-version"`},
+		{name: "Versioned route", path: "/v1/data/1", sunset: sunset},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler := NewDeprecationMiddleware(successor, deprecatedAt, tt.sunset)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusUnauthorized)
+			}))
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if got := rr.Header().Get("Deprecation"); got != tt.deprecation {
+				t.Errorf("Deprecation = %q, want %q", got, tt.deprecation)
+			}
+			if got := rr.Header().Get("Sunset"); got != tt.wantSunset {
+				t.Errorf("Sunset = %q, want %q", got, tt.wantSunset)
+			}
+			if got := rr.Header().Get("Link"); got != tt.link {
+				t.Errorf("Link = %q, want %q", got, tt.link)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"goapi/internal/api/problem"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// maxIdempotencyKeyLength bounds the Idempotency-Key header, UUIDs and similar random keys fit easily
+const maxIdempotencyKeyLength = 255
+
+// IdempotentResponse is the stored response of a request sent with an Idempotency-Key
+type IdempotentResponse struct {
+	RequestHash string // SHA-256 of the method, path and body of the request
+	StatusCode  int    // 0 while the request is still in progress
+	ContentType string
+	Body        []byte
+}
+
+// IdempotencyStore keeps the responses of requests sent with an Idempotency-Key
+type IdempotencyStore interface {
+	// Begin claims key for a request and returns nil, or the response stored for the key when it was used before
+	Begin(scope string, key string, requestHash string, ctx context.Context) (*IdempotentResponse, error)
+	Complete(scope string, key string, response *IdempotentResponse, ctx context.Context) error
+	Release(scope string, key string, ctx context.Context) error
+}
+
+// idempotencyRecorder passes a response through and keeps a copy of it
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *idempotencyRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *idempotencyRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (rec *idempotencyRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// NewIdempotencyMiddleware makes POST requests sent with an Idempotency-Key header safe to retry.
+// The first request with a key is processed and its response stored, retries with the same method, path and body
+// get the stored response with an Idempotent-Replayed header. Reusing a key for a different request is rejected with 422,
+// a retry while the first request is still in progress with 409. Keys are scoped to the caller, so it has to run
+// after the authentication middleware. Server errors and responses marked Cache-Control: no-store are not stored.
+func NewIdempotencyMiddleware(store IdempotencyStore, logger *slog.Logger) Middleware {
+
+	return func(next http.Handler) http.Handler {
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("Idempotency-Key")
+			if r.Method != http.MethodPost || key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !validIdempotencyKey(key) {
+				problem.Write(w, r, http.StatusBadRequest, "Idempotency-Key must be 1 to "+strconv.Itoa(maxIdempotencyKeyLength)+" printable ASCII characters.")
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					problem.Write(w, r, http.StatusRequestEntityTooLarge, "Request body too large, the limit is "+strconv.FormatInt(tooLarge.Limit, 10)+" bytes.")
+					return
+				}
+				problem.Write(w, r, http.StatusBadRequest, "Invalid request data. Please check your input.")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			scope := clientKey(r)
+			hash := requestHash(r, body)
+			// The key is stored even when the client gives up, so the store calls outlive the request
+			ctx := context.WithoutCancel(r.Context())
+
+			stored, err := store.Begin(scope, key, hash, ctx)
+			if err != nil {
+				logger.ErrorContext(r.Context(), "Error reading idempotency key", "error", err, "scope", scope)
+				problem.InternalError(w, r)
+				return
+			}
+			if stored != nil {
+				switch {
+				case stored.RequestHash != hash:
+					problem.Write(w, r, http.StatusUnprocessableEntity, "Idempotency-Key was already used with a different request.")
+				case stored.StatusCode == 0:
+					w.Header().Set("Retry-After", "1")
+					problem.Write(w, r, http.StatusConflict, "A request with this Idempotency-Key is still in progress.")
+				default:
+					if stored.ContentType != "" {
+						w.Header().Set("Content-Type", stored.ContentType)
+					}
+					w.Header().Set("Idempotent-Replayed", "true")
+					w.WriteHeader(stored.StatusCode)
+					w.Write(stored.Body)
+				}
+				return
+			}
+
+			rec := &idempotencyRecorder{ResponseWriter: w}
+			defer func() {
+				status := rec.status
+				if status == 0 {
+					status = http.StatusOK
+				}
+				// Server errors and panics are worth retrying, and no-store responses carry secrets like tokens
+				if p := recover(); p != nil || status >= http.StatusInternalServerError || w.Header().Get("Cache-Control") == "no-store" {
+					if err := store.Release(scope, key, ctx); err != nil {
+						logger.ErrorContext(r.Context(), "Error releasing idempotency key", "error", err, "scope", scope)
+					}
+					if p != nil {
+						panic(p)
+					}
+					return
+				}
+				response := &IdempotentResponse{RequestHash: hash, StatusCode: status, ContentType: w.Header().Get("Content-Type"), Body: rec.body.Bytes()}
+				if err := store.Complete(scope, key, response, ctx); err != nil {
+					logger.ErrorContext(r.Context(), "Error storing idempotent response", "error", err, "scope", scope)
+				}
+			}()
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
+
+// validIdempotencyKey reports whether key is short and printable, so it can be stored and logged safely
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// requestHash identifies a request by its method, path and body
+func requestHash(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
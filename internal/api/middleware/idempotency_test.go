@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// In-memory store, keys never expire
+type memoryIdempotencyStore struct {
+	responses map[string]*IdempotentResponse
+}
+
+func (s *memoryIdempotencyStore) Begin(scope string, key string, requestHash string, ctx context.Context) (*IdempotentResponse, error) {
+	if stored, ok := s.responses[scope+"/"+key]; ok {
+		return stored, nil
+	}
+	s.responses[scope+"/"+key] = &IdempotentResponse{RequestHash: requestHash}
+	return nil, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(scope string, key string, response *IdempotentResponse, ctx context.Context) error {
+	s.responses[scope+"/"+key] = response
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(scope string, key string, ctx context.Context) error {
+	delete(s.responses, scope+"/"+key)
+	return nil
+}
+
+func newIdempotencyHandler(status int) (http.Handler, *int) {
+	calls := 0
+	handler := NewIdempotencyMiddleware(&memoryIdempotencyStore{responses: map[string]*IdempotentResponse{}}, slog.Default())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(`{"id": ` + strconv.Itoa(calls) + `}`))
+	}))
+	return handler, &calls
+}
+
+func idempotentRequest(handler http.Handler, method string, key string, body string, identity Identity) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/device/status", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	req = req.WithContext(WithIdentity(req.Context(), identity))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestIdempotencyReplaysResponse(t *testing.T) {
+	handler, calls := newIdempotencyHandler(http.StatusCreated)
+	device := Identity{Username: "ESP32_MAZE_002", DeviceID: "ESP32_MAZE_002"}
+
+	first := idempotentRequest(handler, http.MethodPost, "retry-1", `{"device_id": "ESP32_MAZE_002"}`, device)
+	retry := idempotentRequest(handler, http.MethodPost, "retry-1", `{"device_id": "ESP32_MAZE_002"}`, device)
+
+	if *calls != 1 {
+		t.Errorf("Expected the handler to run once, ran %d times", *calls)
+	}
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Errorf("Expected the stored response, got %d %s", retry.Code, retry.Body.String())
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" || retry.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected replay headers, got %v", retry.Header())
+	}
+	if first.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("Expected the first response not to be marked as replayed")
+	}
+}
+
+func TestIdempotencyRejectsDifferentRequest(t *testing.T) {
+	handler, calls := newIdempotencyHandler(http.StatusCreated)
+	device := Identity{Username: "ESP32_MAZE_002", DeviceID: "ESP32_MAZE_002"}
+
+	idempotentRequest(handler, http.MethodPost, "retry-1", `{"moves": 1}`, device)
+	rr := idempotentRequest(handler, http.MethodPost, "retry-1", `{"moves": 2}`, device)
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422, got %d", rr.Code)
+	}
+	if *calls != 1 {
+		t.Errorf("Expected the handler to run once, ran %d times", *calls)
+	}
+
+	// The same key of another client is unrelated
+	rr = idempotentRequest(handler, http.MethodPost, "retry-1", `{"moves": 2}`, Identity{Username: "admin"})
+	if rr.Code != http.StatusCreated || *calls != 2 {
+		t.Errorf("Expected another client's key to be processed, got %d after %d calls", rr.Code, *calls)
+	}
+}
+
+func TestIdempotencyInProgress(t *testing.T) {
+	store := &memoryIdempotencyStore{responses: map[string]*IdempotentResponse{}}
+	handler := NewIdempotencyMiddleware(store, slog.Default())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	store.responses["user:admin/retry-1"] = &IdempotentResponse{RequestHash: requestHash(httptest.NewRequest(http.MethodPost, "/device/status", nil), []byte(`{}`))}
+
+	rr := idempotentRequest(handler, http.MethodPost, "retry-1", `{}`, Identity{Username: "admin"})
+	if rr.Code != http.StatusConflict || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected status 409 with Retry-After, got %d %v", rr.Code, rr.Header())
+	}
+}
+
+func TestIdempotencyReleasesServerErrors(t *testing.T) {
+	handler, calls := newIdempotencyHandler(http.StatusInternalServerError)
+	admin := Identity{Username: "admin"}
+
+	idempotentRequest(handler, http.MethodPost, "retry-1", `{}`, admin)
+	idempotentRequest(handler, http.MethodPost, "retry-1", `{}`, admin)
+
+	if *calls != 2 {
+		t.Errorf("Expected a failed request to be processed again, ran %d times", *calls)
+	}
+}
+
+func TestIdempotencyIgnoresOtherRequests(t *testing.T) {
+	handler, calls := newIdempotencyHandler(http.StatusOK)
+	admin := Identity{Username: "admin"}
+
+	idempotentRequest(handler, http.MethodPut, "retry-1", `{}`, admin)
+	idempotentRequest(handler, http.MethodPut, "retry-1", `{}`, admin)
+	idempotentRequest(handler, http.MethodPost, "", `{}`, admin)
+	idempotentRequest(handler, http.MethodPost, "", `{}`, admin)
+
+	if *calls != 4 {
+		t.Errorf("Expected every request without a POST key to be processed, ran %d times", *calls)
+	}
+}
+
+func TestIdempotencyInvalidKey(t *testing.T) {
+	handler, calls := newIdempotencyHandler(http.StatusCreated)
+
+	for _, key := range []string{strings.Repeat("k", 256), "key\x7f"} {
+		rr := idempotentRequest(handler, http.MethodPost, key, `{}`, Identity{Username: "admin"})
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for key %q, got %d", key, rr.Code)
+		}
+	}
+	if *calls != 0 {
+		t.Errorf("Expected no request to be processed, ran %d times", *calls)
+	}
+}
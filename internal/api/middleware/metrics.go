@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+)
+
+// RequestObserver is notified about every handled request, e.g. to record metrics
+type RequestObserver func(method string, route string, status int, duration time.Duration)
+
+// NewMetricsMiddleware reports the method, matched route pattern, status and latency of every request to observe.
+// Methods outside the standard set are reported as OTHER, so clients cannot create a label per made up method.
+func NewMetricsMiddleware(route func(r *http.Request) string, observe RequestObserver) Middleware {
+
+	return func(next http.Handler) http.Handler {
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w}
+
+			next.ServeHTTP(rec, r)
+
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			observe(metricsMethod(r.Method), route(r), status, time.Since(start))
+		})
+	}
+}
+
+// metricsMethod returns the method as reported in metrics, OTHER for non-standard methods
+func metricsMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "OTHER"
+	}
+}
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"goapi/internal/api/problem"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// NewNegotiationMiddleware checks that a request's body and the response it asks for are in media types the route
+// handles. representations returns the media types the route matching a request answers with.
+//
+// Only requests with a body need a Content-Type, so GET and DELETE requests from browsers, curl and probes pass
+// without one. A request whose Accept header allows none of the route's representations is rejected with 406,
+// requests without an Accept header take whatever the route answers with.
+func NewNegotiationMiddleware(representations func(r *http.Request) []string) Middleware {
+
+	return func(next http.Handler) http.Handler {
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			// * OPTIONS requests have no body to validate, the CORS middleware answers them before they get here *
+			if r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// * A request body should be JSON, and the Content-Type header must start with: application/json *
+			// * PATCH bodies are JSON merge patches and may use their own media type: application/merge-patch+json *
+			// * Batch uploads may be sent as newline delimited JSON: application/x-ndjson *
+			// * Table imports may be sent as CSV: text/csv *
+			if hasBody(r) {
+				contentType := r.Header.Get("Content-Type")
+				isMergePatch := r.Method == http.MethodPatch && strings.HasPrefix(contentType, "application/merge-patch+json")
+				isNDJSON := r.Method == http.MethodPost && strings.HasPrefix(contentType, "application/x-ndjson")
+				isCSV := r.Method == http.MethodPost && strings.HasPrefix(contentType, "text/csv")
+				if !strings.HasPrefix(contentType, "application/json") && !isMergePatch && !isNDJSON && !isCSV {
+					problem.Write(w, r, http.StatusUnsupportedMediaType, "Content-Type header should be set to: application/json.")
+					return
+				}
+			}
+
+			// * Routes answering in several formats pick one by the Accept header, caches must keep them apart *
+			produced := representations(r)
+			if len(produced) > 1 {
+				w.Header().Add("Vary", "Accept")
+			}
+			if accept := strings.Join(r.Header.Values("Accept"), ","); accept != "" {
+				if !slices.ContainsFunc(produced, func(mediaType string) bool { return acceptable(accept, mediaType) }) {
+					problem.Write(w, r, http.StatusNotAcceptable, "Accept header should allow: "+strings.Join(produced, ", ")+".")
+					return
+				}
+			}
+
+			// * Set the Content-Type header of the response to application/json for all responses
+			// * Error responses replace it with application/problem+json, other formats with their own media type
+			w.Header().Set("Content-Type", "application/json")
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// hasBody reports whether a request carries a body, one of unknown length counts
+func hasBody(r *http.Request) bool {
+	return r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0
+}
+
+// acceptable reports whether the Accept header allows mediaType. The most specific media range matching it decides,
+// a q-value of 0 refuses it.
+func acceptable(accept string, mediaType string) bool {
+	typ, _, _ := strings.Cut(mediaType, "/")
+	specificity, q := -1, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaRange, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		matched := -1
+		switch mediaRange {
+		case mediaType:
+			matched = 2
+		case typ + "/*":
+			matched = 1
+		case "*/*", "*":
+			matched = 0
+		}
+		if matched <= specificity {
+			continue
+		}
+
+		weight := 1.0
+		if value, ok := params["q"]; ok {
+			if weight, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		specificity, q = matched, weight
+	}
+	return q > 0
+}
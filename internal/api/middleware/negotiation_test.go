@@ -0,0 +1,159 @@
+package middleware
+
+import (
+	"goapi/internal/api/problem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func negotiationHandler(representations ...string) http.Handler {
+	if len(representations) == 0 {
+		representations = []string{"application/json"}
+	}
+	return NewNegotiationMiddleware(func(r *http.Request) []string { return representations })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+}
+
+func TestNegotiationInvalidContentType(t *testing.T) {
+
+	req, err := http.NewRequest("POST", "/data", strings.NewReader(`{"content":"text"}`))
+	if err != nil {
+		t.Fatalf("Error creating request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "text/plain")
+	rr := httptest.NewRecorder()
+
+	negotiationHandler().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("Expected status code 415, got: %d", rr.Code)
+	}
+
+	expected := "Content-Type header should be set to: application/json."
+	if problem.Detail(rr.Body.Bytes()) != expected {
+		t.Fatalf("Expected response body: %s, got: %s", expected, rr.Body.String())
+	}
+}
+
+func TestNegotiationCorrectContentType(t *testing.T) {
+
+	req, err := http.NewRequest("POST", "/data", strings.NewReader(`{"content":"text"}`))
+	if err != nil {
+		t.Fatalf("Error creating request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	negotiationHandler().ServeHTTP(rr, req)
+
+	// * The status code is set in the handler, so we will not check it here; The handlers are tested separately *
+
+	if rr.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("Expected Content-Type: application/json, got: %s", rr.Header().Get("Content-Type"))
+	}
+	// * CORS headers are set by the CORS middleware *
+	if rr.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("Expected no Access-Control-Allow-Origin, got: %s", rr.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestNegotiationBodylessRequests(t *testing.T) {
+
+	// * Browsers, curl and probes send GET and DELETE requests without a Content-Type *
+	for _, method := range []string{http.MethodGet, http.MethodDelete, http.MethodPost} {
+		req, err := http.NewRequest(method, "/data/1", nil)
+		if err != nil {
+			t.Fatalf("Error creating request: %v", err)
+		}
+		req.Header.Set("Content-Type", "text/plain")
+		rr := httptest.NewRecorder()
+
+		negotiationHandler().ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("Expected status code 200 for %s without a body, got: %d", method, rr.Code)
+		}
+	}
+}
+
+func TestNegotiationAlternativeContentTypes(t *testing.T) {
+
+	tests := []struct {
+		method      string
+		contentType string
+		code        int
+	}{
+		{method: http.MethodPatch, contentType: "application/merge-patch+json", code: http.StatusOK},
+		{method: http.MethodPut, contentType: "application/merge-patch+json", code: http.StatusUnsupportedMediaType},
+		{method: http.MethodPost, contentType: "application/x-ndjson", code: http.StatusOK},
+		{method: http.MethodPut, contentType: "application/x-ndjson", code: http.StatusUnsupportedMediaType},
+		{method: http.MethodPost, contentType: "text/csv; charset=utf-8", code: http.StatusOK},
+		{method: http.MethodDelete, contentType: "text/csv", code: http.StatusUnsupportedMediaType},
+		{method: http.MethodPost, contentType: "", code: http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, "/data/1", strings.NewReader("{}"))
+		if err != nil {
+			t.Fatalf("Error creating request: %v", err)
+		}
+
+		req.Header.Set("Content-Type", tt.contentType)
+		rr := httptest.NewRecorder()
+
+		negotiationHandler().ServeHTTP(rr, req)
+
+		if rr.Code != tt.code {
+			t.Fatalf("Expected status code %d for %s %s, got: %d", tt.code, tt.method, tt.contentType, rr.Code)
+		}
+	}
+}
+
+func TestNegotiationAccept(t *testing.T) {
+
+	tables := []string{"application/json", "text/csv", "application/x-ndjson"}
+	tests := []struct {
+		name            string
+		accept          string
+		representations []string
+		code            int
+	}{
+		{name: "No Accept header", code: http.StatusOK},
+		{name: "JSON", accept: "application/json", code: http.StatusOK},
+		{name: "Browser", accept: "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", code: http.StatusOK},
+		{name: "Type wildcard", accept: "application/*", code: http.StatusOK},
+		{name: "Bare wildcard", accept: "*", code: http.StatusOK},
+		{name: "Only HTML", accept: "text/html", code: http.StatusNotAcceptable},
+		{name: "Refused JSON", accept: "application/json;q=0, */*", code: http.StatusNotAcceptable},
+		{name: "Refused wildcard", accept: "*/*;q=0", code: http.StatusNotAcceptable},
+		{name: "CSV from a table", accept: "text/csv", representations: tables, code: http.StatusOK},
+		{name: "CSV from JSON", accept: "text/csv", code: http.StatusNotAcceptable},
+		{name: "Text from a table", accept: "text/*", representations: tables, code: http.StatusOK},
+		{name: "Binary file", accept: "application/octet-stream", representations: []string{"application/octet-stream"}, code: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/data", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rr := httptest.NewRecorder()
+
+			negotiationHandler(tt.representations...).ServeHTTP(rr, req)
+
+			if rr.Code != tt.code {
+				t.Fatalf("Expected status code %d, got: %d", tt.code, rr.Code)
+			}
+			if tt.code == http.StatusNotAcceptable && !strings.HasPrefix(problem.Detail(rr.Body.Bytes()), "Accept header should allow: ") {
+				t.Errorf("Unexpected problem: %s", rr.Body.String())
+			}
+			if vary := rr.Header().Get("Vary"); (vary == "Accept") != (len(tt.representations) > 1) {
+				t.Errorf("Unexpected Vary: %q", vary)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"goapi/internal/api/problem"
+	"goapi/internal/api/ratelimit"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// NewClientIPRateLimitMiddleware rejects requests with 429 Too Many Requests once the IP address they are sent from
+// used up its requests. It runs before the authentication middleware, so requests with rejected credentials count
+// towards the limit too. limiter returns nil for unlimited requests.
+func NewClientIPRateLimitMiddleware(limiter func(r *http.Request) *ratelimit.Limiter) Middleware {
+
+	return newRateLimitMiddleware(limiter, func(r *http.Request) string {
+		return "ip:" + ClientIP(r)
+	})
+}
+
+// NewRateLimitMiddleware rejects requests with 429 Too Many Requests once an authenticated client used up its
+// requests, keyed by username so a device is limited regardless of the address it connects from.
+// It has to run after the authentication middleware, unauthenticated requests are left to the client IP limit.
+func NewRateLimitMiddleware(limiter func(r *http.Request) *ratelimit.Limiter) Middleware {
+
+	return newRateLimitMiddleware(limiter, func(r *http.Request) string {
+		if identity, ok := IdentityFromContext(r.Context()); ok && identity.Username != "" {
+			return "user:" + identity.Username
+		}
+		return ""
+	})
+}
+
+// newRateLimitMiddleware limits requests by the key of their client, requests without a key are not limited
+func newRateLimitMiddleware(limiter func(r *http.Request) *ratelimit.Limiter, clientKey func(r *http.Request) string) Middleware {
+
+	return func(next http.Handler) http.Handler {
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			l := limiter(r)
+			key := clientKey(r)
+			if l == nil || key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if ok, retryAfter := l.Allow(key, time.Now()); !ok {
+				seconds := strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))
+				w.Header().Set("Retry-After", seconds)
+				problem.Write(w, r, http.StatusTooManyRequests, "Too many requests, retry after "+seconds+" seconds.")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientKey identifies the caller of r, by authenticated username or else by IP address
+func clientKey(r *http.Request) string {
+	if identity, ok := IdentityFromContext(r.Context()); ok && identity.Username != "" {
+		return "user:" + identity.Username
+	}
+	return "ip:" + ClientIP(r)
+}
+
+// ClientIP returns the IP address r was sent from, the host of its RemoteAddr
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
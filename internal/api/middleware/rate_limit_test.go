@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/ratelimit"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRateLimitPerClient(t *testing.T) {
+
+	limiter := ratelimit.New(1, 2)
+	handler := NewRateLimitMiddleware(func(r *http.Request) *ratelimit.Limiter { return limiter })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	request := func(identity *Identity, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/device/status", nil)
+		req.RemoteAddr = remoteAddr
+		if identity != nil {
+			req = req.WithContext(WithIdentity(req.Context(), *identity))
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	device := &Identity{Username: "ESP32_MAZE_002", DeviceID: "ESP32_MAZE_002"}
+	for i := 0; i < 2; i++ {
+		if rr := request(device, "10.0.0.1:1000"); rr.Code != http.StatusOK {
+			t.Fatalf("Expected request %d to pass, got %d", i+1, rr.Code)
+		}
+	}
+
+	// The device is limited regardless of the address it connects from
+	rr := request(device, "10.0.0.2:1000")
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "1" {
+		t.Errorf("Expected 429 with Retry-After 1, got %d %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+	if problem.Detail(rr.Body.Bytes()) != "Too many requests, retry after 1 seconds." {
+		t.Errorf("Unexpected body %s", rr.Body.String())
+	}
+
+	// Unauthenticated callers are left to the client IP limit
+	for i := 0; i < 3; i++ {
+		if rr := request(nil, "10.0.0.1:1000"); rr.Code != http.StatusOK {
+			t.Errorf("Expected an anonymous caller not to be limited here, got %d", rr.Code)
+		}
+	}
+}
+
+func TestRateLimitCountsRejectedCredentials(t *testing.T) {
+
+	limiter := ratelimit.New(1, 2)
+	authentication := NewBasicAuthenticationMiddleware(nil, nil, nil, UserAccount("admin", "password"))
+	handler := NewClientIPRateLimitMiddleware(func(r *http.Request) *ratelimit.Limiter { return limiter })(
+		authentication(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	request := func(password string, remoteAddr string) int {
+		req := httptest.NewRequest(http.MethodGet, "/device/status", nil)
+		req.RemoteAddr = remoteAddr
+		req.SetBasicAuth("admin", password)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// Guessing passwords uses up the bucket of the address, even with the right password afterwards
+	for i := 0; i < 2; i++ {
+		if code := request("guess", "10.0.0.1:1000"); code != http.StatusUnauthorized {
+			t.Fatalf("Expected guess %d to be rejected with 401, got %d", i+1, code)
+		}
+	}
+	if code := request("guess", "10.0.0.1:1000"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected the third guess to be rate limited, got %d", code)
+	}
+	if code := request("password", "10.0.0.1:1000"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected the address to stay limited, got %d", code)
+	}
+
+	// Other addresses have their own bucket
+	if code := request("password", "10.0.0.2:1000"); code != http.StatusOK {
+		t.Errorf("Expected another address to pass, got %d", code)
+	}
+}
+
+func TestRateLimitUnlimited(t *testing.T) {
+
+	handler := NewRateLimitMiddleware(func(r *http.Request) *ratelimit.Limiter { return nil })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for i := 0; i < 100; i++ {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/data", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected no limit, got %d", rr.Code)
+		}
+	}
+}
+
+func TestBodyLimit(t *testing.T) {
+
+	handler := NewBodyLimitMiddleware(func(r *http.Request) int64 { return 16 })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+
+	tests := []struct {
+		name     string
+		body     io.Reader
+		expected int
+	}{
+		{name: "Small body", body: strings.NewReader(`{"a":"b"}`), expected: http.StatusOK},
+		{name: "Content-Length above limit", body: bytes.NewReader([]byte(`{"a":"` + strings.Repeat("b", 32) + `"}`)), expected: http.StatusRequestEntityTooLarge},
+		// Without a Content-Length the body is cut off at the limit and fails to decode
+		{name: "Streamed body above limit", body: io.MultiReader(strings.NewReader(`{"a":"` + strings.Repeat("b", 32) + `"}`)), expected: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/data", tt.body)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tt.expected {
+				t.Errorf("Expected status code %d, got %d", tt.expected, rr.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"goapi/internal/api/logging"
+	"net/http"
+	"regexp"
+)
+
+// RequestIDHeader carries the request ID in both directions
+const RequestIDHeader = "X-Request-ID"
+
+// Only IDs that are safe to echo back and to write to logs are accepted from clients
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._\-]{1,64}$`)
+
+// RequestIDMiddleware assigns every request an ID, reusing the caller's X-Request-ID when it is valid.
+// The ID is returned in the response header and stored in the request context for logging.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = logging.NewRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
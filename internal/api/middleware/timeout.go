@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// NewTimeoutMiddleware sets a deadline on the request context, so the database calls of a slow request are cancelled.
+// timeout returns the limit for the request, e.g. a longer one for uploads.
+func NewTimeoutMiddleware(timeout func(r *http.Request) time.Duration) Middleware {
+
+	return func(next http.Handler) http.Handler {
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout(r))
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
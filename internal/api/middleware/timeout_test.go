@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeoutMiddlewareSetsDeadline(t *testing.T) {
+
+	timeout := func(r *http.Request) time.Duration {
+		if r.URL.Path == "/firmware" {
+			return 10 * time.Second
+		}
+		return 2 * time.Second
+	}
+
+	for path, expected := range map[string]time.Duration{"/data": 2 * time.Second, "/firmware": 10 * time.Second} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rr := httptest.NewRecorder()
+
+		handler := NewTimeoutMiddleware(timeout)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			deadline, ok := r.Context().Deadline()
+			if remaining := time.Until(deadline); !ok || remaining > expected || remaining < expected-time.Second {
+				t.Errorf("Expected a %s deadline for %s, got %s", expected, path, remaining)
+			}
+		}))
+		handler.ServeHTTP(rr, req)
+	}
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// * Test: Server span continues the caller's trace and authentication gets its own child span
+func TestTracingMiddleware(t *testing.T) {
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	req := httptest.NewRequest(http.MethodGet, "/data/0", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.SetBasicAuth("admin", "password")
+	rr := httptest.NewRecorder()
+
+	handler := ChainMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}),
+		BasicAuthenticationMiddleware,
+		NewTracingMiddleware(func(r *http.Request) string { return "GET /data/{id}" }),
+	)
+	handler.ServeHTTP(rr, req)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+
+	auth, server := spans[0], spans[1]
+	if auth.Name() != "authenticate" || auth.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Errorf("Expected authenticate span as child of the server span, got %q", auth.Name())
+	}
+	if server.Name() != "GET /data/{id}" || server.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Unexpected server span %q in trace %s", server.Name(), server.SpanContext().TraceID())
+	}
+	if server.Status().Code.String() != "Error" {
+		t.Errorf("Expected 500 responses to mark the span as failed, got %v", server.Status())
+	}
+
+	attributes := map[attribute.Key]attribute.Value{}
+	for _, kv := range server.Attributes() {
+		attributes[kv.Key] = kv.Value
+	}
+	if attributes["http.response.status_code"].AsInt64() != http.StatusInternalServerError || attributes["enduser.id"].AsString() != "admin" {
+		t.Errorf("Unexpected server span attributes: %v", server.Attributes())
+	}
+}
@@ -0,0 +1,37 @@
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// docsPage renders the document from /openapi.json without any external scripts or styles
+//
+//go:embed docs.html
+var docsPage []byte
+
+// YAMLHandler serves GET /openapi.yaml, the document as written
+// curl http://127.0.0.1:8080/openapi.yaml
+func (spec *Spec) YAMLHandler() http.Handler {
+	return serve("application/yaml", spec.document)
+}
+
+// JSONHandler serves GET /openapi.json, the document converted to JSON for tools that do not read YAML
+// curl http://127.0.0.1:8080/openapi.json
+func (spec *Spec) JSONHandler() http.Handler {
+	return serve("application/json", spec.json)
+}
+
+// DocsHandler serves GET /docs, a page listing every operation with its parameters, bodies and responses
+func (spec *Spec) DocsHandler() http.Handler {
+	return serve("text/html; charset=utf-8", docsPage)
+}
+
+func serve(contentType string, body []byte) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+	})
+}
@@ -0,0 +1,200 @@
+package openapi
+
+import (
+	"fmt"
+	"goapi/internal/api/repository/models"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// schema is the subset of the OpenAPI schema object the validator checks.
+// Annotations like format, readOnly and example only document the API and are not checked.
+type schema struct {
+	Ref        string             `yaml:"$ref"`
+	Type       string             `yaml:"type"`
+	Nullable   bool               `yaml:"nullable"`
+	Enum       []any              `yaml:"enum"`
+	Properties map[string]*schema `yaml:"properties"`
+	Required   []string           `yaml:"required"`
+	Items      *schema            `yaml:"items"`
+	AllOf      []*schema          `yaml:"allOf"`
+	OneOf      []*schema          `yaml:"oneOf"`
+	Minimum    *float64           `yaml:"minimum"`
+	Maximum    *float64           `yaml:"maximum"`
+	MinLength  *int               `yaml:"minLength"`
+	MaxLength  *int               `yaml:"maxLength"`
+	MinItems   *int               `yaml:"minItems"`
+	MaxItems   *int               `yaml:"maxItems"`
+	ref        *schema            // Target of Ref, set when the document is loaded
+}
+
+// validate adds an error for every part of value, decoded from JSON, that does not match the schema.
+// path names the value in the errors, e.g. "results[2].status", the empty path is the whole body.
+func (s *schema) validate(path string, value any, fields *models.FieldErrors) {
+	if s == nil || (value == nil && s.Nullable) {
+		return
+	}
+	if s.ref != nil {
+		s.ref.validate(path, value, fields)
+		return
+	}
+	for _, sub := range s.AllOf {
+		sub.validate(path, value, fields)
+	}
+	if len(s.OneOf) > 0 {
+		matches := 0
+		for _, sub := range s.OneOf {
+			var errs models.FieldErrors
+			sub.validate(path, value, &errs)
+			if len(errs) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			fields.Add(fieldName(path), "must match exactly one of the documented schemas")
+		}
+	}
+	if s.Type == "" {
+		return
+	}
+	if value == nil {
+		fields.Add(fieldName(path), "must not be null")
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			fields.Add(fieldName(path), "must be an object")
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := object[name]; !ok {
+				fields.Add(join(path, name), "is required")
+			}
+		}
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if v, ok := object[name]; ok {
+				s.Properties[name].validate(join(path, name), v, fields)
+			}
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			fields.Add(fieldName(path), "must be an array")
+			return
+		}
+		if s.MinItems != nil && len(items) < *s.MinItems {
+			fields.Add(fieldName(path), "must have at least "+strconv.Itoa(*s.MinItems)+" items")
+		}
+		if s.MaxItems != nil && len(items) > *s.MaxItems {
+			fields.Add(fieldName(path), "must have at most "+strconv.Itoa(*s.MaxItems)+" items")
+		}
+		for i, item := range items {
+			s.Items.validate(path+"["+strconv.Itoa(i)+"]", item, fields)
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fields.Add(fieldName(path), "must be a string")
+			return
+		}
+		length := utf8.RuneCountInString(str)
+		if s.MinLength != nil && length < *s.MinLength {
+			fields.Add(fieldName(path), "must be at least "+strconv.Itoa(*s.MinLength)+" characters")
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			fields.Add(fieldName(path), "must be at most "+strconv.Itoa(*s.MaxLength)+" characters")
+		}
+	case "integer", "number":
+		number, ok := value.(float64)
+		if !ok || (s.Type == "integer" && number != math.Trunc(number)) {
+			fields.Add(fieldName(path), "must be "+article(s.Type)+" "+s.Type)
+			return
+		}
+		if s.Minimum != nil && number < *s.Minimum {
+			fields.Add(fieldName(path), "must be at least "+formatNumber(*s.Minimum))
+		}
+		if s.Maximum != nil && number > *s.Maximum {
+			fields.Add(fieldName(path), "must be at most "+formatNumber(*s.Maximum))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fields.Add(fieldName(path), "must be a boolean")
+			return
+		}
+	}
+
+	if len(s.Enum) > 0 {
+		values := make([]string, len(s.Enum))
+		for i, e := range s.Enum {
+			values[i] = fmt.Sprint(e)
+			if values[i] == fmt.Sprint(value) {
+				return
+			}
+		}
+		fields.Add(fieldName(path), "must be one of "+strings.Join(values, ", "))
+	}
+}
+
+// parse converts a path, query or header parameter to the type of the schema, so it can be validated like JSON
+func (s *schema) parse(value string) (any, bool) {
+	if s.ref != nil {
+		return s.ref.parse(value)
+	}
+	switch s.Type {
+	case "integer":
+		n, err := strconv.ParseInt(value, 10, 64)
+		return float64(n), err == nil
+	case "number":
+		n, err := strconv.ParseFloat(value, 64)
+		return n, err == nil
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		return b, err == nil
+	}
+	return value, true
+}
+
+// typ returns the type of a schema, following its reference
+func (s *schema) typ() string {
+	if s.ref != nil {
+		return s.ref.typ()
+	}
+	return s.Type
+}
+
+// fieldName names the whole body "body" in errors
+func fieldName(path string) string {
+	if path == "" {
+		return "body"
+	}
+	return path
+}
+
+func join(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func article(typ string) string {
+	if typ == "integer" {
+		return "an"
+	}
+	return "a"
+}
+
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
@@ -0,0 +1,239 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec is a parsed OpenAPI 3.0 document. Operations are keyed like the routes of an http.ServeMux, e.g. "GET /data/{id}",
+// so the route that matched a request finds the operation that documents it.
+type Spec struct {
+	document   []byte // The document as written
+	json       []byte // The document converted to JSON
+	operations map[string]*operation
+	schemas    map[string]*schema
+	parameters map[string]*parameter
+	responses  map[string]*response
+}
+
+type document struct {
+	Paths      map[string]*pathItem `yaml:"paths"`
+	Components struct {
+		Schemas    map[string]*schema    `yaml:"schemas"`
+		Parameters map[string]*parameter `yaml:"parameters"`
+		Responses  map[string]*response  `yaml:"responses"`
+	} `yaml:"components"`
+}
+
+type pathItem struct {
+	Parameters []*parameter `yaml:"parameters"`
+	Get        *operation   `yaml:"get"`
+	Put        *operation   `yaml:"put"`
+	Post       *operation   `yaml:"post"`
+	Delete     *operation   `yaml:"delete"`
+	Patch      *operation   `yaml:"patch"`
+	Head       *operation   `yaml:"head"`
+	Options    *operation   `yaml:"options"`
+}
+
+type operation struct {
+	Parameters  []*parameter         `yaml:"parameters"`
+	RequestBody *requestBody         `yaml:"requestBody"`
+	Responses   map[string]*response `yaml:"responses"`
+	path        string               // Path template, e.g. /data/{id}
+}
+
+type parameter struct {
+	Ref      string  `yaml:"$ref"`
+	Name     string  `yaml:"name"`
+	In       string  `yaml:"in"` // path, query or header
+	Required bool    `yaml:"required"`
+	Schema   *schema `yaml:"schema"`
+}
+
+type requestBody struct {
+	Required bool                  `yaml:"required"`
+	Content  map[string]*mediaType `yaml:"content"`
+}
+
+type response struct {
+	Ref     string                `yaml:"$ref"`
+	Content map[string]*mediaType `yaml:"content"`
+}
+
+type mediaType struct {
+	Schema *schema `yaml:"schema"`
+}
+
+// Load parses an OpenAPI document and resolves its references, a reference to a missing component is an error
+func Load(data []byte) (*Spec, error) {
+	var doc document
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing OpenAPI document: %w", err)
+	}
+	var raw any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing OpenAPI document: %w", err)
+	}
+	converted, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("converting OpenAPI document to JSON: %w", err)
+	}
+
+	spec := &Spec{
+		document:   data,
+		json:       converted,
+		operations: make(map[string]*operation),
+		schemas:    doc.Components.Schemas,
+		parameters: doc.Components.Parameters,
+		responses:  doc.Components.Responses,
+	}
+	for name, s := range spec.schemas {
+		if err := spec.resolveSchema(s); err != nil {
+			return nil, fmt.Errorf("schema %s: %w", name, err)
+		}
+	}
+	for name, p := range spec.parameters {
+		if err := spec.resolveSchema(p.Schema); err != nil {
+			return nil, fmt.Errorf("parameter %s: %w", name, err)
+		}
+	}
+	for name, r := range spec.responses {
+		if err := spec.resolveContent(r.Content); err != nil {
+			return nil, fmt.Errorf("response %s: %w", name, err)
+		}
+	}
+
+	for path, item := range doc.Paths {
+		for method, op := range item.operations() {
+			pattern := method + " " + path
+			if err := spec.resolveOperation(op, item.Parameters); err != nil {
+				return nil, fmt.Errorf("%s: %w", pattern, err)
+			}
+			op.path = path
+			spec.operations[pattern] = op
+		}
+	}
+	return spec, nil
+}
+
+// operations returns the operations of a path keyed by their HTTP method
+func (item *pathItem) operations() map[string]*operation {
+	operations := make(map[string]*operation)
+	for method, op := range map[string]*operation{
+		http.MethodGet:     item.Get,
+		http.MethodPut:     item.Put,
+		http.MethodPost:    item.Post,
+		http.MethodDelete:  item.Delete,
+		http.MethodPatch:   item.Patch,
+		http.MethodHead:    item.Head,
+		http.MethodOptions: item.Options,
+	} {
+		if op != nil {
+			operations[method] = op
+		}
+	}
+	return operations
+}
+
+// resolveOperation replaces references with the components they point to
+// and adds the parameters of the path that the operation does not override
+func (spec *Spec) resolveOperation(op *operation, shared []*parameter) error {
+	var parameters []*parameter
+	seen := make(map[string]bool)
+	for _, p := range append(op.Parameters, shared...) {
+		resolved, err := spec.resolveParameter(p)
+		if err != nil {
+			return err
+		}
+		if seen[resolved.In+" "+resolved.Name] {
+			continue
+		}
+		seen[resolved.In+" "+resolved.Name] = true
+		parameters = append(parameters, resolved)
+	}
+	op.Parameters = parameters
+
+	if op.RequestBody != nil {
+		if err := spec.resolveContent(op.RequestBody.Content); err != nil {
+			return err
+		}
+	}
+	for status, r := range op.Responses {
+		if r.Ref == "" {
+			if err := spec.resolveContent(r.Content); err != nil {
+				return err
+			}
+			continue
+		}
+		target, ok := spec.responses[strings.TrimPrefix(r.Ref, "#/components/responses/")]
+		if !ok {
+			return fmt.Errorf("unknown response %s", r.Ref)
+		}
+		op.Responses[status] = target
+	}
+	return nil
+}
+
+func (spec *Spec) resolveParameter(p *parameter) (*parameter, error) {
+	if p.Ref == "" {
+		return p, spec.resolveSchema(p.Schema)
+	}
+	target, ok := spec.parameters[strings.TrimPrefix(p.Ref, "#/components/parameters/")]
+	if !ok {
+		return nil, fmt.Errorf("unknown parameter %s", p.Ref)
+	}
+	return target, nil
+}
+
+func (spec *Spec) resolveContent(content map[string]*mediaType) error {
+	for _, media := range content {
+		if err := spec.resolveSchema(media.Schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveSchema links every $ref below s to the schema it names
+func (spec *Spec) resolveSchema(s *schema) error {
+	if s == nil {
+		return nil
+	}
+	if s.Ref != "" {
+		if s.ref != nil {
+			return nil
+		}
+		target, ok := spec.schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+		if !ok {
+			return fmt.Errorf("unknown schema %s", s.Ref)
+		}
+		s.ref = target
+		return nil
+	}
+	children := append(append([]*schema{s.Items}, s.AllOf...), s.OneOf...)
+	for _, property := range s.Properties {
+		children = append(children, property)
+	}
+	for _, child := range children {
+		if err := spec.resolveSchema(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Operations returns the documented operations as route patterns, e.g. "GET /data/{id}", in sorted order
+func (spec *Spec) Operations() []string {
+	patterns := make([]string, 0, len(spec.operations))
+	for pattern := range spec.operations {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	return patterns
+}
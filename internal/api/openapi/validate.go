@@ -0,0 +1,180 @@
+package openapi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"goapi/internal/api/repository/models"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ValidateRequest checks the parameters and body of a request against the operation documented for route,
+// the pattern that matched the request. Requests to undocumented routes are not checked.
+func (spec *Spec) ValidateRequest(route string, r *http.Request, body []byte) models.FieldErrors {
+	op, ok := spec.operations[route]
+	if !ok {
+		return nil
+	}
+	var fields models.FieldErrors
+
+	path := pathValues(op.path, r.URL.Path)
+	query := r.URL.Query()
+	for _, p := range op.Parameters {
+		var value string
+		var present bool
+		switch p.In {
+		case "path":
+			value, present = path[p.Name]
+		case "query":
+			value, present = query.Get(p.Name), query.Has(p.Name)
+		case "header":
+			value = r.Header.Get(p.Name)
+			present = value != ""
+		default:
+			continue
+		}
+		if !present {
+			if p.Required {
+				fields.Add(p.Name, "is required")
+			}
+			continue
+		}
+		if p.Schema == nil {
+			continue
+		}
+		parsed, ok := p.Schema.parse(value)
+		if !ok {
+			fields.Add(p.Name, "must be "+article(p.Schema.typ())+" "+p.Schema.typ())
+			continue
+		}
+		p.Schema.validate(p.Name, parsed, &fields)
+	}
+
+	if op.RequestBody == nil {
+		return fields
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		if op.RequestBody.Required {
+			fields.Add("body", "is required")
+		}
+		return fields
+	}
+	media, ok := contentFor(op.RequestBody.Content, r.Header.Get("Content-Type"))
+	if !ok {
+		fields.Add("Content-Type", "must be one of "+strings.Join(mediaTypes(op.RequestBody.Content), ", "))
+		return fields
+	}
+	validateBody(media, r.Header.Get("Content-Type"), body, &fields)
+	return fields
+}
+
+// ValidateResponse checks the status, content type and body of a response against the operation documented for route.
+// Only JSON bodies are checked against their schema.
+func (spec *Spec) ValidateResponse(route string, status int, header http.Header, body []byte) models.FieldErrors {
+	op, ok := spec.operations[route]
+	if !ok {
+		return nil
+	}
+	var fields models.FieldErrors
+
+	documented := op.Responses[strconv.Itoa(status)]
+	if documented == nil {
+		documented = op.Responses[strconv.Itoa(status/100)+"XX"]
+	}
+	if documented == nil {
+		documented = op.Responses["default"]
+	}
+	if documented == nil {
+		fields.Add("status", strconv.Itoa(status)+" is not documented")
+		return fields
+	}
+	if len(body) == 0 {
+		return fields
+	}
+	if len(documented.Content) == 0 {
+		fields.Add("body", "must be empty")
+		return fields
+	}
+	media, ok := contentFor(documented.Content, header.Get("Content-Type"))
+	if !ok {
+		fields.Add("Content-Type", "must be one of "+strings.Join(mediaTypes(documented.Content), ", "))
+		return fields
+	}
+	validateBody(media, header.Get("Content-Type"), body, &fields)
+	return fields
+}
+
+// validateBody checks a JSON body against the schema of its media type.
+// Newline delimited JSON is checked line by line, the schema describes a single line.
+func validateBody(media *mediaType, contentType string, body []byte, fields *models.FieldErrors) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if media.Schema == nil || !isJSON(mediaType) {
+		return
+	}
+	if strings.HasSuffix(mediaType, "ndjson") {
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		scanner.Buffer(nil, len(body)+1)
+		for i := 0; scanner.Scan(); {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			var value any
+			if err := json.Unmarshal(line, &value); err != nil {
+				fields.Add("["+strconv.Itoa(i)+"]", "must be valid JSON")
+			} else {
+				media.Schema.validate("["+strconv.Itoa(i)+"]", value, fields)
+			}
+			i++
+		}
+		return
+	}
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		fields.Add("body", "must be valid JSON")
+		return
+	}
+	media.Schema.validate("", value, fields)
+}
+
+// contentFor returns the documented media type matching a Content-Type header, parameters like charset are ignored
+func contentFor(content map[string]*mediaType, contentType string) (*mediaType, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	media, ok := content[mediaType]
+	return media, ok
+}
+
+func mediaTypes(content map[string]*mediaType) []string {
+	types := make([]string, 0, len(content))
+	for mediaType := range content {
+		types = append(types, mediaType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "ndjson")
+}
+
+// pathValues matches a path against a template like /v1/data/{id} and returns the values of its parameters.
+// Segments are matched from the end, so unversioned aliases like /data/1 line up with the template too.
+func pathValues(template string, path string) map[string]string {
+	values := make(map[string]string)
+	names := strings.Split(strings.Trim(template, "/"), "/")
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	offset := len(segments) - len(names)
+	for i, name := range names {
+		if j := i + offset; j >= 0 && strings.HasPrefix(name, "{") && strings.HasSuffix(name, "}") {
+			values[strings.Trim(name, "{}")] = segments[j]
+		}
+	}
+	return values
+}
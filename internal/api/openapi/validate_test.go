@@ -0,0 +1,194 @@
+package openapi
+
+import (
+	"goapi/docs"
+	"goapi/internal/api/repository/models"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func loadSpec(t *testing.T) *Spec {
+	t.Helper()
+	spec, err := Load(docs.OpenAPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+func TestLoadUnknownReference(t *testing.T) {
+	document := `
+paths:
+  /data:
+    get:
+      responses:
+        '200':
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Missing'
+`
+	_, err := Load([]byte(document))
+	if err == nil || !strings.Contains(err.Error(), "GET /data") {
+		t.Errorf("Load() error = %v, want an error naming GET /data", err)
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	spec := loadSpec(t)
+
+	tests := []struct {
+		name        string
+		route       string
+		method      string
+		target      string
+		contentType string
+		body        string
+		want        models.FieldErrors
+	}{
+		{
+			name: "Valid status", route: "POST /v1/device/status", method: "POST", target: "/v1/device/status", contentType: "application/json",
+			body: `{"device_id":"ARD001","alarm_active":true,"maze_completed":false,"hall_sensor_value":false,"battery_level":85,"timestamp":"2024-01-15T10:30:00Z"}`,
+		},
+		{
+			name: "Invalid status", route: "POST /v1/device/status", method: "POST", target: "/v1/device/status", contentType: "application/json",
+			body: `{"device_id":"ARD001","alarm_active":"yes","maze_completed":false,"battery_level":101.5,"timestamp":"2024-01-15T10:30:00Z"}`,
+			want: models.FieldErrors{
+				{Field: "hall_sensor_value", Message: "is required"},
+				{Field: "alarm_active", Message: "must be a boolean"},
+				{Field: "battery_level", Message: "must be an integer"},
+			},
+		},
+		{
+			name: "Missing body", route: "POST /v1/device/config", method: "POST", target: "/v1/device/config", contentType: "application/json",
+			want: models.FieldErrors{{Field: "body", Message: "is required"}},
+		},
+		{
+			name: "Undocumented media type", route: "PUT /v1/device/config", method: "PUT", target: "/v1/device/config", contentType: "application/x-ndjson", body: `{}`,
+			want: models.FieldErrors{{Field: "Content-Type", Message: "must be one of application/json"}},
+		},
+		{
+			name: "Invalid path parameter", route: "GET /v1/data/{id}", method: "GET", target: "/v1/data/abc",
+			want: models.FieldErrors{{Field: "id", Message: "must be an integer"}},
+		},
+		{
+			name: "Path parameter of an alias", route: "GET /v1/firmware/{id}/download", method: "GET", target: "/firmware/7/download",
+		},
+		{
+			name: "Invalid path parameter of an alias", route: "GET /v1/data/{id}", method: "GET", target: "/data/abc",
+			want: models.FieldErrors{{Field: "id", Message: "must be an integer"}},
+		},
+		{
+			name: "Invalid query parameter", route: "GET /v1/audit", method: "GET", target: "/v1/audit?action=rename&page=-1",
+			want: models.FieldErrors{{Field: "action", Message: "must be one of create, update, delete"}, {Field: "page", Message: "must be at least 0"}},
+		},
+		{
+			name: "Missing query parameter", route: "GET /v1/firmware/update", method: "GET", target: "/v1/firmware/update?hardware=esp32",
+			want: models.FieldErrors{{Field: "version", Message: "is required"}},
+		},
+		{
+			name: "NDJSON batch", route: "POST /v1/device/status/batch", method: "POST", target: "/v1/device/status/batch", contentType: "application/x-ndjson",
+			body: "{\"device_id\":\"ARD001\",\"alarm_active\":true,\"maze_completed\":false,\"hall_sensor_value\":false,\"battery_level\":85,\"timestamp\":\"2024-01-15T10:30:00Z\"}\n\n{\"device_id\":\"ARD001\",\"alarm_active\":true,\"maze_completed\":false,\"hall_sensor_value\":false,\"battery_level\":-1,\"timestamp\":\"2024-01-15T10:30:00Z\"}\n",
+			want: models.FieldErrors{{Field: "[1].battery_level", Message: "must be at least 0"}},
+		},
+		{
+			name: "Undocumented route", route: "GET /unknown", method: "GET", target: "/unknown",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.target, nil)
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			got := spec.ValidateRequest(tt.route, r, []byte(tt.body))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateRequest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	spec := loadSpec(t)
+
+	tests := []struct {
+		name        string
+		route       string
+		status      int
+		contentType string
+		body        string
+		want        models.FieldErrors
+	}{
+		{name: "Empty list", route: "GET /v1/device/status", status: http.StatusOK, contentType: "application/json", body: "null\n"},
+		{name: "Single config", route: "GET /v1/device/config", status: http.StatusOK, contentType: "application/json", body: `{"id":1,"device_id":"ARD001","alarm_timeout":300,"sensitivity_level":5,"updated_at":"2024-01-15T10:30:00Z"}`},
+		{name: "Config page", route: "GET /v1/device/config", status: http.StatusOK, contentType: "application/json", body: `[{"id":1,"device_id":"ARD001","alarm_timeout":300,"sensitivity_level":5,"updated_at":"2024-01-15T10:30:00Z"}]`},
+		{name: "Problem", route: "GET /v1/data/{id}", status: http.StatusNotFound, contentType: "application/problem+json", body: `{"type":"about:blank","title":"Not Found","status":404,"detail":"Resource not found."}`},
+		{name: "Not modified", route: "GET /v1/data/{id}", status: http.StatusNotModified},
+		{name: "Binary", route: "GET /v1/firmware/{id}/download", status: http.StatusOK, contentType: "application/octet-stream", body: "\x00\x01"},
+		{
+			name: "Undocumented status", route: "DELETE /v1/data/{id}", status: http.StatusTeapot,
+			want: models.FieldErrors{{Field: "status", Message: "418 is not documented"}},
+		},
+		{
+			name: "Wrong media type", route: "GET /v1/data/{id}", status: http.StatusNotFound, contentType: "application/json", body: `{}`,
+			want: models.FieldErrors{{Field: "Content-Type", Message: "must be one of application/problem+json"}},
+		},
+		{
+			name: "Wrong field type", route: "POST /v1/auth/login", status: http.StatusOK, contentType: "application/json", body: `{"access_token":"a","token_type":"Basic","expires_in":"900"}`,
+			want: models.FieldErrors{{Field: "expires_in", Message: "must be an integer"}, {Field: "token_type", Message: "must be one of Bearer"}},
+		},
+		{
+			name: "Body where none is documented", route: "DELETE /v1/data/{id}", status: http.StatusNoContent, contentType: "application/json", body: `{}`,
+			want: models.FieldErrors{{Field: "body", Message: "must be empty"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.contentType != "" {
+				header.Set("Content-Type", tt.contentType)
+			}
+			got := spec.ValidateResponse(tt.route, tt.status, header, []byte(tt.body))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateResponse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	spec := loadSpec(t)
+
+	tests := []struct {
+		name        string
+		handler     http.Handler
+		contentType string
+		contains    string
+	}{
+		{name: "YAML", handler: spec.YAMLHandler(), contentType: "application/yaml", contains: "openapi: 3.0.3"},
+		{name: "JSON", handler: spec.JSONHandler(), contentType: "application/json", contains: `"openapi":"3.0.3"`},
+		{name: "Docs", handler: spec.DocsHandler(), contentType: "text/html; charset=utf-8", contains: `fetch("openapi.json")`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+			if rr.Code != http.StatusOK {
+				t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+			}
+			if got := rr.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("handler returned wrong Content-Type: got %v want %v", got, tt.contentType)
+			}
+			if !strings.Contains(rr.Body.String(), tt.contains) {
+				t.Errorf("handler body does not contain %q", tt.contains)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package problem
+
+import (
+	"encoding/json"
+	"goapi/internal/api/logging"
+	"goapi/internal/api/repository/models"
+	"net/http"
+)
+
+// ContentType is the media type of error responses
+const ContentType = "application/problem+json"
+
+// Problem types, the title of a type never changes while the detail explains the occurrence
+const (
+	// TypeDefault means the status code says it all, the title is its status text
+	TypeDefault = "about:blank"
+	// TypeValidation lists the invalid fields of the request in errors
+	TypeValidation = "urn:goapi:problem:validation"
+)
+
+// Problem is an RFC 7807 problem details object
+type Problem struct {
+	Type      string             `json:"type"`
+	Title     string             `json:"title"`
+	Status    int                `json:"status"`
+	Detail    string             `json:"detail,omitempty"`
+	Instance  string             `json:"instance,omitempty"`   // Path of the request
+	RequestID string             `json:"request_id,omitempty"` // Matches the X-Request-ID header and the logs
+	Errors    models.FieldErrors `json:"errors,omitempty"`     // Invalid fields of a validation problem
+}
+
+// New returns a problem of the default type
+func New(status int, detail string) *Problem {
+	return &Problem{Type: TypeDefault, Title: http.StatusText(status), Status: status, Detail: detail}
+}
+
+// Validation returns a 400 problem naming the invalid fields
+func Validation(detail string, fields models.FieldErrors) *Problem {
+	return &Problem{Type: TypeValidation, Title: "Your request is not valid.", Status: http.StatusBadRequest, Detail: detail, Errors: fields}
+}
+
+// Write sends the problem as the response to r, headers like Retry-After have to be set before
+func (p *Problem) Write(w http.ResponseWriter, r *http.Request) {
+	p.Instance = r.URL.Path
+	p.RequestID = logging.RequestID(r.Context())
+
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	// A problem only holds strings and numbers, encoding cannot fail
+	json.NewEncoder(w).Encode(p)
+}
+
+// Write sends a problem of the default type
+func Write(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	New(status, detail).Write(w, r)
+}
+
+// Invalid sends a validation problem
+func Invalid(w http.ResponseWriter, r *http.Request, detail string, fields models.FieldErrors) {
+	Validation(detail, fields).Write(w, r)
+}
+
+// InternalError sends a 500 problem, the cause is only logged so internals are not leaked to clients
+func InternalError(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, http.StatusInternalServerError, "Internal server error.")
+}
+
+// Detail returns the detail of the problem in body, or "" when body does not hold a problem
+func Detail(body []byte) string {
+	var p Problem
+	if err := json.Unmarshal(body, &p); err != nil {
+		return ""
+	}
+	return p.Detail
+}
@@ -0,0 +1,53 @@
+package problem
+
+import (
+	"encoding/json"
+	"goapi/internal/api/logging"
+	"goapi/internal/api/repository/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/device/status/7", nil)
+	req = req.WithContext(logging.WithRequestID(req.Context(), "145bcf6d"))
+	rr := httptest.NewRecorder()
+
+	Write(rr, req, http.StatusNotFound, `Device "7" not found.`)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", rr.Code)
+	}
+	if rr.Header().Get("Content-Type") != ContentType {
+		t.Errorf("Expected Content-Type %s, got %s", ContentType, rr.Header().Get("Content-Type"))
+	}
+	var p Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &p); err != nil {
+		t.Fatalf("Expected a JSON body, got %s: %v", rr.Body.String(), err)
+	}
+	expected := Problem{Type: TypeDefault, Title: "Not Found", Status: http.StatusNotFound, Detail: `Device "7" not found.`, Instance: "/device/status/7", RequestID: "145bcf6d"}
+	if p.Type != expected.Type || p.Title != expected.Title || p.Status != expected.Status || p.Detail != expected.Detail || p.Instance != expected.Instance || p.RequestID != expected.RequestID {
+		t.Errorf("Expected %+v, got %+v", expected, p)
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/device/status", nil)
+	rr := httptest.NewRecorder()
+
+	var fields models.FieldErrors
+	fields.Add("battery_level", "must be between 0 and 100")
+	Invalid(rr, req, "Invalid maze device status.", fields)
+
+	var p Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Status != http.StatusBadRequest || p.Type != TypeValidation || len(p.Errors) != 1 || p.Errors[0].Field != "battery_level" {
+		t.Errorf("Expected a validation problem naming battery_level, got %+v", p)
+	}
+	if p.RequestID != "" {
+		t.Errorf("Expected no request ID outside the middleware chain, got %s", p.RequestID)
+	}
+}
@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Limiter keeps a token bucket per client, e.g. per authenticated user or IP address.
+// A client may make burst requests at once and then rate requests per second.
+type Limiter struct {
+	limit rate.Limit
+	burst int
+
+	mu      sync.Mutex
+	clients map[string]*client
+}
+
+type client struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func New(requestsPerSecond float64, burst int) *Limiter {
+	return &Limiter{
+		limit:   rate.Limit(requestsPerSecond),
+		burst:   burst,
+		clients: make(map[string]*client),
+	}
+}
+
+// Allow takes a token from the bucket of key. When the bucket is empty it returns false
+// and how long the client has to wait for the next token.
+func (l *Limiter) Allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	c, ok := l.clients[key]
+	if !ok {
+		c = &client{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.clients[key] = c
+	}
+	c.lastSeen = now
+	l.mu.Unlock()
+
+	reservation := c.limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		return false, time.Second
+	}
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		// Do not let the rejected request use up a token
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// Cleanup forgets clients that have not made a request since before, their buckets would be full again anyway
+func (l *Limiter) Cleanup(before time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, c := range l.clients {
+		if c.lastSeen.Before(before) {
+			delete(l.clients, key)
+		}
+	}
+}
+
+// Run cleans up idle clients every interval until ctx is done
+func (l *Limiter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			l.Cleanup(now.Add(-l.idleAfter()))
+		}
+	}
+}
+
+// idleAfter is the time an empty bucket takes to fill up, a client idle for longer can be forgotten
+func (l *Limiter) idleAfter() time.Duration {
+	if l.limit <= 0 {
+		return time.Minute
+	}
+	return max(time.Duration(float64(l.burst)/float64(l.limit)*float64(time.Second)), time.Minute)
+}
+
+// size returns the number of tracked clients
+func (l *Limiter) size() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.clients)
+}
@@ -0,0 +1,42 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllowBurstThenRate(t *testing.T) {
+	l := New(2, 3)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("device", now); !ok {
+			t.Fatalf("Expected request %d of the burst to be allowed", i+1)
+		}
+	}
+
+	ok, retryAfter := l.Allow("device", now)
+	if ok || retryAfter <= 0 || retryAfter > 500*time.Millisecond {
+		t.Errorf("Expected to wait up to 500ms after the burst, got %v %s", ok, retryAfter)
+	}
+	if ok, _ := l.Allow("other", now); !ok {
+		t.Error("Expected other clients to have their own bucket")
+	}
+
+	// Rejected requests do not consume tokens, after 500ms one token is back
+	if ok, _ := l.Allow("device", now.Add(500*time.Millisecond)); !ok {
+		t.Error("Expected a request to be allowed after the refill")
+	}
+}
+
+func TestCleanupForgetsIdleClients(t *testing.T) {
+	l := New(1, 1)
+	now := time.Now()
+	l.Allow("idle", now.Add(-2*time.Minute))
+	l.Allow("active", now)
+
+	l.Cleanup(now.Add(-time.Minute))
+	if l.size() != 1 {
+		t.Errorf("Expected only the active client to be kept, got %d", l.size())
+	}
+}
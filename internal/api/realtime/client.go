@@ -0,0 +1,233 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"goapi/internal/api/middleware"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// subscription is a topic of one device, or of every device when deviceID is empty
+type subscription struct {
+	topic    string
+	deviceID string
+}
+
+// client is a connection served by the hub. readPump handles the client's messages one at a time and writePump
+// writes everything sent to it, so only writePump ever writes to the connection.
+type client struct {
+	hub      *Hub
+	conn     *websocket.Conn
+	identity middleware.Identity
+	send     chan []byte
+
+	closeOnce   sync.Once
+	done        chan struct{} // Closed when the connection is to be closed
+	closeCode   int
+	closeReason string
+
+	mu            sync.Mutex
+	subscriptions map[subscription]bool
+}
+
+// enqueue queues a message without blocking the hub. A client whose queue is full reads slower than events arrive
+// and is disconnected rather than buffered for without bound, it may reconnect and fetch what it missed over REST.
+func (c *client) enqueue(payload []byte) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	select {
+	case c.send <- payload:
+		return true
+	default:
+		c.close(websocket.CloseTryAgainLater, "too many pending messages")
+		return false
+	}
+}
+
+// close asks writePump to close the connection with code, only the first call counts
+func (c *client) close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		c.closeCode, c.closeReason = code, reason
+		close(c.done)
+	})
+}
+
+func (c *client) subscribed(topic string, deviceID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.subscriptions[subscription{topic, deviceID}] || c.subscriptions[subscription{topic, ""}]
+}
+
+func (c *client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case payload := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-c.done:
+			if c.closeCode != websocket.CloseAbnormalClosure {
+				c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(c.closeCode, c.closeReason), time.Now().Add(writeWait))
+			}
+			return
+		}
+	}
+}
+
+func (c *client) readPump() {
+	defer func() {
+		c.hub.unregister(c)
+		c.close(websocket.CloseNormalClosure, "")
+		c.hub.logger.Info("WebSocket client disconnected", "user", c.identity.Username, "device_id", c.identity.DeviceID, "code", c.closeCode, "reason", c.closeReason)
+	}()
+
+	c.conn.SetReadLimit(maxMessageBytes)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, payload, err := c.conn.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) {
+				// The close frame was already answered by the connection
+				c.close(closeErr.Code, closeErr.Text)
+			} else {
+				// Read limit, missed pongs or a broken connection, writePump gives up on it too
+				c.close(websocket.CloseAbnormalClosure, "")
+			}
+			return
+		}
+		// Any message shows the client is alive
+		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+
+		var msg Message
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			c.reply(Message{Type: MessageError, Error: "Message is not valid JSON."})
+			continue
+		}
+		c.handle(msg)
+	}
+}
+
+// reply queues an answer to the client
+func (c *client) reply(msg Message) {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		c.hub.logger.Error("Error encoding WebSocket reply", "type", msg.Type, "error", err)
+		return
+	}
+	c.enqueue(payload)
+}
+
+func (c *client) fail(msg Message, reason string) {
+	c.reply(Message{Type: MessageError, ID: msg.ID, Error: reason})
+}
+
+// handle answers a message, checking it against the identity the connection authenticated as
+func (c *client) handle(msg Message) {
+	switch msg.Type {
+	case MessageSubscribe, MessageUnsubscribe:
+		if !slices.Contains(topics, msg.Topic) {
+			c.fail(msg, "Unknown topic, use status, config or alerts.")
+			return
+		}
+		// An empty device_id subscribes to every device, which only user accounts may do
+		if !c.identity.CanAccessDevice(msg.DeviceID) {
+			c.fail(msg, "Forbidden: Devices may only subscribe to their own topics.")
+			return
+		}
+
+		c.mu.Lock()
+		key := subscription{msg.Topic, msg.DeviceID}
+		full := msg.Type == MessageSubscribe && !c.subscriptions[key] && len(c.subscriptions) >= maxSubscriptions
+		if !full {
+			if msg.Type == MessageSubscribe {
+				c.subscriptions[key] = true
+			} else {
+				delete(c.subscriptions, key)
+			}
+		}
+		c.mu.Unlock()
+		if full {
+			c.fail(msg, "Too many subscriptions, unsubscribe from another topic first.")
+			return
+		}
+		c.reply(Message{Type: MessageAck, ID: msg.ID})
+
+	case MessageCommand:
+		if !c.identity.IsAdmin() {
+			c.fail(msg, "Forbidden: Devices cannot send commands.")
+			return
+		}
+		if msg.DeviceID == "" {
+			c.fail(msg, "device_id is required.")
+			return
+		}
+		if !slices.Contains(commands, msg.Command) {
+			c.fail(msg, "Unknown command, use stop_alarm or request_status.")
+			return
+		}
+
+		if msg.Command == CommandRequestStatus {
+			if !c.sendLatestStatus(msg) {
+				return
+			}
+		}
+		delivered := c.hub.deliver(Message{Type: MessageCommand, ID: msg.ID, Command: msg.Command, DeviceID: msg.DeviceID})
+		if delivered == 0 && msg.Command == CommandStopAlarm {
+			c.fail(msg, "Device "+msg.DeviceID+" is not connected.")
+			return
+		}
+		c.reply(Message{Type: MessageAck, ID: msg.ID, Data: map[string]int{"delivered": delivered}})
+
+	default:
+		c.fail(msg, "Unknown message type, use subscribe, unsubscribe or command.")
+	}
+}
+
+// sendLatestStatus sends the latest stored status of the device in msg as a status event, reporting whether the
+// command can go on
+func (c *client) sendLatestStatus(msg Message) bool {
+	if c.hub.statuses == nil {
+		return true
+	}
+	ctx, cancel := context.WithTimeout(middleware.WithIdentity(context.Background(), c.identity), c.hub.timeout)
+	defer cancel()
+
+	// Statuses of a device are read newest first
+	for status, err := range c.hub.statuses.ReadByDeviceID(msg.DeviceID, ctx) {
+		if err != nil {
+			c.hub.logger.ErrorContext(ctx, "Error reading the latest status for a WebSocket client", "device_id", msg.DeviceID, "error", err)
+			c.fail(msg, "Internal Server Error.")
+			return false
+		}
+		c.reply(Message{Type: MessageEvent, Topic: TopicStatus, DeviceID: msg.DeviceID, Data: status})
+		break
+	}
+	return true
+}
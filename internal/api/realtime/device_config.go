@@ -0,0 +1,34 @@
+package realtime
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"goapi/internal/api/service/device_config"
+)
+
+type deviceConfigService struct {
+	device_config.DeviceConfigService
+	hub *Hub
+}
+
+// NotifyDeviceConfigService wraps service so that every config it creates or updates is published on the config topic
+func (h *Hub) NotifyDeviceConfigService(service device_config.DeviceConfigService) device_config.DeviceConfigService {
+	return &deviceConfigService{DeviceConfigService: service, hub: h}
+}
+
+func (s *deviceConfigService) Create(config *models.DeviceConfig, ctx context.Context) error {
+	if err := s.DeviceConfigService.Create(config, ctx); err != nil {
+		return err
+	}
+	s.hub.Publish(TopicConfig, config.DeviceID, config)
+	return nil
+}
+
+func (s *deviceConfigService) Update(config *models.DeviceConfig, ctx context.Context) (int64, error) {
+	rowsAffected, err := s.DeviceConfigService.Update(config, ctx)
+	if err != nil || rowsAffected == 0 {
+		return rowsAffected, err
+	}
+	s.hub.Publish(TopicConfig, config.DeviceID, config)
+	return rowsAffected, nil
+}
@@ -0,0 +1,224 @@
+// Package realtime pushes device events to WebSocket clients and passes commands from apps on to devices.
+//
+// Clients exchange JSON messages. They subscribe to the status, config and alerts topics of a device, or of every
+// device, and send commands like stop_alarm to devices connected to the same hub. Every message a client sends may
+// carry an id, the ack or error answering it echoes that id:
+//
+//	{"type":"subscribe","id":"1","topic":"status","device_id":"ESP32_MAZE_001"}
+//	{"type":"ack","id":"1"}
+//	{"type":"event","topic":"status","device_id":"ESP32_MAZE_001","data":{"id":42,"alarm_active":false,...}}
+//	{"type":"command","id":"2","command":"stop_alarm","device_id":"ESP32_MAZE_001"}
+//	{"type":"ack","id":"2","data":{"delivered":1}}
+//
+// Devices connect with their own credentials and receive the commands sent to them, they may only subscribe to their
+// own topics. The server pings every client and drops those that stop answering, or that read their messages slower
+// than events arrive.
+package realtime
+
+import (
+	"encoding/json"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/problem"
+	"goapi/internal/api/service/maze_device"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// writeWait is how long writing a message to a client may take
+	writeWait = 10 * time.Second
+	// pongWait is how long a client may stay silent, pongs to the server's pings count
+	pongWait = 60 * time.Second
+	// pingPeriod is how often clients are pinged, shorter than pongWait so a pong can arrive in time
+	pingPeriod = pongWait * 9 / 10
+	// maxMessageBytes is the size limit of a message sent by a client
+	maxMessageBytes = 4096
+	// sendBuffer is how many messages may wait for a client, a client falling further behind is disconnected
+	sendBuffer = 64
+	// maxSubscriptions is how many subscriptions a client may hold
+	maxSubscriptions = 64
+)
+
+// Topics clients can subscribe to
+const (
+	TopicStatus = "status" // Statuses stored for a device
+	TopicConfig = "config" // Configs created or updated for a device
+	TopicAlerts = "alerts" // Statuses stored with the alarm active
+)
+
+var topics = []string{TopicStatus, TopicConfig, TopicAlerts}
+
+// Commands apps can send to devices
+const (
+	CommandStopAlarm     = "stop_alarm"     // Silences the device's alarm
+	CommandRequestStatus = "request_status" // Asks the device to report its status, the latest stored one is sent right away
+)
+
+var commands = []string{CommandStopAlarm, CommandRequestStatus}
+
+// Message types
+const (
+	MessageSubscribe   = "subscribe"   // Client: start receiving events of a topic
+	MessageUnsubscribe = "unsubscribe" // Client: stop receiving events of a topic
+	MessageCommand     = "command"     // Client: send a command to a device. Server: a command sent to this device.
+	MessageEvent       = "event"       // Server: an event of a subscribed topic
+	MessageAck         = "ack"         // Server: the message with ID was handled
+	MessageError       = "error"       // Server: the message with ID was rejected
+)
+
+// Message is a message exchanged with a client
+type Message struct {
+	Type     string `json:"type"`
+	ID       string `json:"id,omitempty"` // Chosen by the client, echoed in the ack or error answering its message
+	Topic    string `json:"topic,omitempty"`
+	DeviceID string `json:"device_id,omitempty"` // Empty in a subscription to every device
+	Command  string `json:"command,omitempty"`
+	Data     any    `json:"data,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Hub keeps track of the connected clients and fans events out to their subscriptions
+type Hub struct {
+	logger   *slog.Logger
+	timeout  time.Duration
+	upgrader websocket.Upgrader
+	statuses maze_device.MazeDeviceStatusService // Answers status requests, set by NotifyMazeDeviceStatusService
+
+	mu      sync.Mutex
+	clients map[*client]bool
+	closed  bool
+}
+
+// NewHub creates a hub. timeout bounds the database calls made for a client's message, checkOrigin decides which
+// browser origins may open a connection.
+func NewHub(logger *slog.Logger, timeout time.Duration, checkOrigin func(r *http.Request) bool) *Hub {
+	return &Hub{
+		logger:  logger,
+		timeout: timeout,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: checkOrigin,
+			Error: func(w http.ResponseWriter, r *http.Request, status int, reason error) {
+				problem.Write(w, r, status, "WebSocket handshake failed: "+reason.Error()+".")
+			},
+		},
+		clients: make(map[*client]bool),
+	}
+}
+
+// AllowOrigins returns an origin check accepting clients without an Origin header, like apps and devices, pages of
+// the API's own host and the listed origins. A * in origins is ignored, browsers send their credentials with the
+// handshake whatever page opens it, so cross-origin pages must be listed one by one.
+func AllowOrigins(origins []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+			return true
+		}
+		return slices.ContainsFunc(origins, func(allowed string) bool {
+			return allowed != "*" && strings.EqualFold(allowed, origin)
+		})
+	}
+}
+
+// Upgrade switches the request's connection to the WebSocket protocol, on failure the client is answered with a problem
+func (h *Hub) Upgrade(w http.ResponseWriter, r *http.Request) (*websocket.Conn, error) {
+	return h.upgrader.Upgrade(w, r, nil)
+}
+
+// Serve takes over conn for a client authenticated as identity. It returns right away, the connection is served until
+// either side closes it.
+func (h *Hub) Serve(conn *websocket.Conn, identity middleware.Identity) {
+	c := &client{
+		hub:           h,
+		conn:          conn,
+		identity:      identity,
+		send:          make(chan []byte, sendBuffer),
+		done:          make(chan struct{}),
+		closeCode:     websocket.CloseNormalClosure,
+		subscriptions: make(map[subscription]bool),
+	}
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		c.close(websocket.CloseGoingAway, "server shutting down")
+		go c.writePump()
+		return
+	}
+	h.clients[c] = true
+	h.mu.Unlock()
+
+	h.logger.Info("WebSocket client connected", "user", identity.Username, "device_id", identity.DeviceID, "remote_addr", conn.RemoteAddr().String())
+	go c.writePump()
+	go c.readPump()
+}
+
+// Publish sends an event to the clients subscribed to topic for deviceID
+func (h *Hub) Publish(topic string, deviceID string, data any) {
+	payload, err := json.Marshal(Message{Type: MessageEvent, Topic: topic, DeviceID: deviceID, Data: data})
+	if err != nil {
+		h.logger.Error("Error encoding WebSocket event", "topic", topic, "device_id", deviceID, "error", err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients {
+		if c.subscribed(topic, deviceID) {
+			c.enqueue(payload)
+		}
+	}
+}
+
+// deliver sends a command to the clients connected as deviceID and returns how many there are
+func (h *Hub) deliver(command Message) int {
+	payload, err := json.Marshal(command)
+	if err != nil {
+		h.logger.Error("Error encoding WebSocket command", "command", command.Command, "error", err)
+		return 0
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delivered := 0
+	for c := range h.clients {
+		if c.identity.DeviceID == command.DeviceID && c.enqueue(payload) {
+			delivered++
+		}
+	}
+	return delivered
+}
+
+// Connections returns the number of connected clients
+func (h *Hub) Connections() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
+// Close disconnects every client with 1001 Going Away and refuses new ones. Hijacked connections are not closed by
+// http.Server.Shutdown, register Close with RegisterOnShutdown.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for c := range h.clients {
+		c.close(websocket.CloseGoingAway, "server shutting down")
+	}
+}
+
+func (h *Hub) unregister(c *client) {
+	h.mu.Lock()
+	delete(h.clients, c)
+	h.mu.Unlock()
+}
@@ -0,0 +1,302 @@
+package realtime
+
+import (
+	"context"
+	"goapi/internal/api/middleware"
+	"goapi/internal/api/repository/models"
+	"goapi/internal/api/service/maze_device"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// statusReader answers ReadByDeviceID with stored statuses, the other methods are not used by the hub
+type statusReader struct {
+	maze_device.MazeDeviceStatusService
+	statuses []*models.MazeDeviceStatus
+}
+
+func (s *statusReader) ReadByDeviceID(deviceID string, ctx context.Context) models.Rows[models.MazeDeviceStatus] {
+	var found []*models.MazeDeviceStatus
+	for _, status := range s.statuses {
+		if status.DeviceID == deviceID {
+			found = append(found, status)
+		}
+	}
+	return models.RowsOf(found, nil)
+}
+
+func (s *statusReader) Create(status *models.MazeDeviceStatus, ctx context.Context) error {
+	s.statuses = append([]*models.MazeDeviceStatus{status}, s.statuses...)
+	return nil
+}
+
+// newTestHub serves hub on a test server, connections authenticate as the device in the device query parameter
+// or as the admin account without one
+func newTestHub(t *testing.T) (*Hub, *httptest.Server) {
+	t.Helper()
+	hub := NewHub(slog.New(slog.NewTextHandler(io.Discard, nil)), time.Second, AllowOrigins([]string{"https://dashboard.example.com"}))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity := middleware.Identity{Username: "admin"}
+		if device := r.URL.Query().Get("device"); device != "" {
+			identity = middleware.Identity{Username: device, DeviceID: device}
+		}
+		conn, err := hub.Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		hub.Serve(conn, identity)
+	}))
+	t.Cleanup(func() {
+		hub.Close()
+		server.Close()
+	})
+	return hub, server
+}
+
+func dial(t *testing.T, server *httptest.Server, device string) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	if device != "" {
+		url += "?device=" + device
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// exchange sends msg and returns the next message received
+func exchange(t *testing.T, conn *websocket.Conn, msg Message) Message {
+	t.Helper()
+	if err := conn.WriteJSON(msg); err != nil {
+		t.Fatal(err)
+	}
+	return receive(t, conn)
+}
+
+func receive(t *testing.T, conn *websocket.Conn) Message {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg Message
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestSubscriptions(t *testing.T) {
+	hub, server := newTestHub(t)
+	statuses := hub.NotifyMazeDeviceStatusService(&statusReader{})
+
+	app := dial(t, server, "")
+	device := dial(t, server, "ESP32_MAZE_001")
+
+	tests := []struct {
+		name  string
+		conn  *websocket.Conn
+		msg   Message
+		reply string
+	}{
+		{name: "Device topic", conn: app, msg: Message{Type: MessageSubscribe, ID: "1", Topic: TopicStatus, DeviceID: "ESP32_MAZE_001"}, reply: MessageAck},
+		{name: "Every device", conn: app, msg: Message{Type: MessageSubscribe, ID: "2", Topic: TopicAlerts}, reply: MessageAck},
+		{name: "Unknown topic", conn: app, msg: Message{Type: MessageSubscribe, ID: "3", Topic: "battery"}, reply: MessageError},
+		{name: "Own topic", conn: device, msg: Message{Type: MessageSubscribe, ID: "4", Topic: TopicConfig, DeviceID: "ESP32_MAZE_001"}, reply: MessageAck},
+		{name: "Other device's topic", conn: device, msg: Message{Type: MessageSubscribe, ID: "5", Topic: TopicStatus, DeviceID: "ESP32_MAZE_002"}, reply: MessageError},
+		{name: "Every device from a device", conn: device, msg: Message{Type: MessageSubscribe, ID: "6", Topic: TopicStatus}, reply: MessageError},
+		{name: "Unknown type", conn: app, msg: Message{Type: "publish", ID: "7"}, reply: MessageError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply := exchange(t, tt.conn, tt.msg)
+			if reply.Type != tt.reply || reply.ID != tt.msg.ID {
+				t.Errorf("Expected %s for message %s, got %+v", tt.reply, tt.msg.ID, reply)
+			}
+		})
+	}
+
+	// * An alarm is published on the status topic of the device and the alerts topic of every device *
+	if err := statuses.Create(&models.MazeDeviceStatus{DeviceID: "ESP32_MAZE_001", AlarmActive: true}, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, topic := range []string{TopicStatus, TopicAlerts} {
+		event := receive(t, app)
+		if event.Type != MessageEvent || event.Topic != topic || event.DeviceID != "ESP32_MAZE_001" {
+			t.Errorf("Expected a %s event, got %+v", topic, event)
+		}
+	}
+
+	// * The device only subscribed to its config, so its next message is the answer to this one *
+	hub.Publish(TopicConfig, "ESP32_MAZE_002", nil)
+	if reply := exchange(t, device, Message{Type: MessageUnsubscribe, ID: "8", Topic: TopicConfig, DeviceID: "ESP32_MAZE_001"}); reply.Type != MessageAck {
+		t.Errorf("Expected the unsubscription to be acknowledged, got %+v", reply)
+	}
+}
+
+func TestImportedStatuses(t *testing.T) {
+	hub, server := newTestHub(t)
+	app := dial(t, server, "")
+	if reply := exchange(t, app, Message{Type: MessageSubscribe, Topic: TopicAlerts}); reply.Type != MessageAck {
+		t.Fatalf("Expected an ack, got %+v", reply)
+	}
+
+	// * Only the status with an active alarm reaches the alerts topic *
+	hub.StatusesImported([]*models.MazeDeviceStatus{
+		{ID: 1, DeviceID: "ESP32_MAZE_001"},
+		{ID: 2, DeviceID: "ESP32_MAZE_002", AlarmActive: true},
+	}, context.Background())
+	if event := receive(t, app); event.Type != MessageEvent || event.DeviceID != "ESP32_MAZE_002" {
+		t.Errorf("Expected the alarm of the imported status, got %+v", event)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	hub, server := newTestHub(t)
+	hub.NotifyMazeDeviceStatusService(&statusReader{statuses: []*models.MazeDeviceStatus{
+		{ID: 2, DeviceID: "ESP32_MAZE_001", BatteryLevel: 80},
+		{ID: 1, DeviceID: "ESP32_MAZE_001", BatteryLevel: 90},
+	}})
+
+	app := dial(t, server, "")
+	device := dial(t, server, "ESP32_MAZE_001")
+
+	if reply := exchange(t, app, Message{Type: MessageCommand, ID: "1", Command: CommandStopAlarm, DeviceID: "ESP32_MAZE_001"}); reply.Type != MessageAck || reply.Data.(map[string]any)["delivered"] != 1.0 {
+		t.Errorf("Expected the command to be delivered once, got %+v", reply)
+	}
+	if command := receive(t, device); command.Type != MessageCommand || command.Command != CommandStopAlarm || command.ID != "1" {
+		t.Errorf("Expected the device to receive the command, got %+v", command)
+	}
+
+	// * The latest stored status answers right away, the device is asked for a fresh one *
+	if event := exchange(t, app, Message{Type: MessageCommand, ID: "2", Command: CommandRequestStatus, DeviceID: "ESP32_MAZE_001"}); event.Type != MessageEvent || event.Data.(map[string]any)["id"] != 2.0 {
+		t.Errorf("Expected the latest status, got %+v", event)
+	}
+	if reply := receive(t, app); reply.Type != MessageAck || reply.ID != "2" {
+		t.Errorf("Expected an ack, got %+v", reply)
+	}
+	if command := receive(t, device); command.Command != CommandRequestStatus {
+		t.Errorf("Expected the device to be asked for its status, got %+v", command)
+	}
+
+	tests := []struct {
+		name string
+		conn *websocket.Conn
+		msg  Message
+	}{
+		{name: "Device not connected", conn: app, msg: Message{Type: MessageCommand, ID: "3", Command: CommandStopAlarm, DeviceID: "ESP32_MAZE_002"}},
+		{name: "Unknown command", conn: app, msg: Message{Type: MessageCommand, ID: "4", Command: "self_destruct", DeviceID: "ESP32_MAZE_001"}},
+		{name: "Missing device", conn: app, msg: Message{Type: MessageCommand, ID: "5", Command: CommandStopAlarm}},
+		{name: "Sent by a device", conn: device, msg: Message{Type: MessageCommand, ID: "6", Command: CommandStopAlarm, DeviceID: "ESP32_MAZE_001"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if reply := exchange(t, tt.conn, tt.msg); reply.Type != MessageError || reply.ID != tt.msg.ID {
+				t.Errorf("Expected an error, got %+v", reply)
+			}
+		})
+	}
+}
+
+func TestInvalidMessages(t *testing.T) {
+	_, server := newTestHub(t)
+	app := dial(t, server, "")
+
+	if err := app.WriteMessage(websocket.TextMessage, []byte("stop_alarm")); err != nil {
+		t.Fatal(err)
+	}
+	if reply := receive(t, app); reply.Type != MessageError {
+		t.Errorf("Expected an error, got %+v", reply)
+	}
+
+	// * Messages over the size limit close the connection *
+	app.WriteMessage(websocket.TextMessage, []byte(`{"type":"subscribe","id":"`+strings.Repeat("x", maxMessageBytes)+`"}`))
+	app.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := app.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+		t.Errorf("Expected the connection to be closed with 1009, got %v", err)
+	}
+}
+
+func TestSlowClient(t *testing.T) {
+	hub, server := newTestHub(t)
+	app := dial(t, server, "")
+	if reply := exchange(t, app, Message{Type: MessageSubscribe, Topic: TopicStatus}); reply.Type != MessageAck {
+		t.Fatalf("Expected an ack, got %+v", reply)
+	}
+
+	// * Events pile up faster than the client reads them, it is dropped instead of buffering without bound *
+	payload := strings.Repeat("x", 64<<10)
+	for i := 0; i < sendBuffer*4; i++ {
+		hub.Publish(TopicStatus, "ESP32_MAZE_001", payload)
+	}
+
+	app.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, _, err := app.ReadMessage(); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseTryAgainLater) {
+				t.Errorf("Expected the connection to be closed with 1013, got %v", err)
+			}
+			break
+		}
+	}
+	waitFor(t, func() bool { return hub.Connections() == 0 })
+}
+
+func TestClose(t *testing.T) {
+	hub, server := newTestHub(t)
+	app := dial(t, server, "")
+	waitFor(t, func() bool { return hub.Connections() == 1 })
+
+	hub.Close()
+	app.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := app.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("Expected the connection to be closed with 1001, got %v", err)
+	}
+
+	// * New connections are turned away once the hub is closed *
+	late := dial(t, server, "")
+	late.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := late.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("Expected the connection to be closed with 1001, got %v", err)
+	}
+}
+
+func TestAllowOrigins(t *testing.T) {
+	check := AllowOrigins([]string{"*", "https://dashboard.example.com"})
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{origin: "", allowed: true},
+		{origin: "http://api.example.com", allowed: true},
+		{origin: "https://Dashboard.example.com", allowed: true},
+		{origin: "https://evil.example.com", allowed: false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "http://api.example.com/v1/ws", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if got := check(req); got != tt.allowed {
+			t.Errorf("Origin %q: got allowed %v, want %v", tt.origin, got, tt.allowed)
+		}
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); !condition(); {
+		if time.Now().After(deadline) {
+			t.Fatal("Condition not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
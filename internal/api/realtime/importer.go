@@ -0,0 +1,23 @@
+package realtime
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+)
+
+// StatusesImported publishes the statuses an import stored like statuses created through the API
+func (h *Hub) StatusesImported(statuses []*models.MazeDeviceStatus, ctx context.Context) {
+	for _, status := range statuses {
+		h.publishStatus(status)
+	}
+}
+
+// ConfigsImported publishes the configs an import stored on the config topic
+func (h *Hub) ConfigsImported(configs []*models.DeviceConfig, ctx context.Context) {
+	for _, config := range configs {
+		h.Publish(TopicConfig, config.DeviceID, config)
+	}
+}
+
+// DataImported publishes nothing, data has no topic
+func (h *Hub) DataImported(data []*models.Data, ctx context.Context) {}
@@ -0,0 +1,62 @@
+package realtime
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"goapi/internal/api/service/maze_device"
+)
+
+type mazeDeviceStatusService struct {
+	maze_device.MazeDeviceStatusService
+	hub *Hub
+}
+
+// NotifyMazeDeviceStatusService wraps service so that every status it stores is published on the status topic, and
+// on the alerts topic when its alarm is active. The hub answers status requests from service.
+func (h *Hub) NotifyMazeDeviceStatusService(service maze_device.MazeDeviceStatusService) maze_device.MazeDeviceStatusService {
+	h.statuses = service
+	return &mazeDeviceStatusService{MazeDeviceStatusService: service, hub: h}
+}
+
+func (s *mazeDeviceStatusService) Create(status *models.MazeDeviceStatus, ctx context.Context) error {
+	if err := s.MazeDeviceStatusService.Create(status, ctx); err != nil {
+		return err
+	}
+	s.publish(status)
+	return nil
+}
+
+// CreateBatch publishes the statuses the batch stored, duplicates and rejected statuses change nothing
+func (s *mazeDeviceStatusService) CreateBatch(statuses []*models.MazeDeviceStatus, ctx context.Context) ([]models.BatchResult, error) {
+	results, err := s.MazeDeviceStatusService.CreateBatch(statuses, ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, result := range results {
+		if result.Status == models.BatchAccepted {
+			s.publish(statuses[result.Index])
+		}
+	}
+	return results, nil
+}
+
+func (s *mazeDeviceStatusService) Update(status *models.MazeDeviceStatus, ctx context.Context) (int64, error) {
+	rowsAffected, err := s.MazeDeviceStatusService.Update(status, ctx)
+	if err != nil || rowsAffected == 0 {
+		return rowsAffected, err
+	}
+	s.publish(status)
+	return rowsAffected, nil
+}
+
+func (s *mazeDeviceStatusService) publish(status *models.MazeDeviceStatus) {
+	s.hub.publishStatus(status)
+}
+
+// publishStatus publishes a stored status on the status topic, and on the alerts topic when its alarm is active
+func (h *Hub) publishStatus(status *models.MazeDeviceStatus) {
+	h.Publish(TopicStatus, status.DeviceID, status)
+	if status.AlarmActive {
+		h.Publish(TopicAlerts, status.DeviceID, status)
+	}
+}
@@ -0,0 +1,136 @@
+package SQLite
+
+import (
+	"context"
+	"database/sql"
+	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/models"
+	"log/slog"
+)
+
+type AuditRepository struct {
+	sqlDB *sql.DB
+	createStmt,
+	readManyStmt *instrumentedStmt
+	ctx context.Context
+}
+
+func NewAuditRepository(sqlDB DAL.SQLDatabase, logger *slog.Logger, ctx context.Context) (models.AuditRepository, error) {
+
+	repo := &AuditRepository{
+		sqlDB: sqlDB.Connection(),
+		ctx:   ctx,
+	}
+
+	// Create the audit_log table if it doesn't exist
+	if _, err := repo.sqlDB.Exec(`CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		actor VARCHAR(50) NOT NULL,
+		action VARCHAR(10) NOT NULL,
+		resource_type VARCHAR(50) NOT NULL,
+		resource_id VARCHAR(50) NOT NULL,
+		before TEXT NOT NULL,
+		after TEXT NOT NULL,
+		changes TEXT NOT NULL,
+		request_id VARCHAR(32) NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL
+	);`); err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+
+	// Create index on the resource for following the history of a single row
+	if _, err := repo.sqlDB.Exec(`CREATE INDEX IF NOT EXISTS idx_audit_log_resource ON audit_log(resource_type, resource_id);`); err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+
+	// The log is append-only, reject changing or deleting entries even outside the API
+	for _, trigger := range []string{
+		`CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+		BEGIN SELECT RAISE(ABORT, 'audit_log is append-only'); END;`,
+		`CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+		BEGIN SELECT RAISE(ABORT, 'audit_log is append-only'); END;`,
+	} {
+		if _, err := repo.sqlDB.Exec(trigger); err != nil {
+			repo.sqlDB.Close()
+			return nil, err
+		}
+	}
+
+	// Prepare SQL statements
+	createStmt, err := prepare(sqlDB, logger, `INSERT INTO audit_log (actor, action, resource_type, resource_id, before, after, changes, request_id, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.createStmt = createStmt
+
+	// Empty filter values match every row
+	readManyStmt, err := prepare(sqlDB, logger, `SELECT id, actor, action, resource_type, resource_id, before, after, changes, request_id, created_at FROM audit_log
+		WHERE (?1 = '' OR actor = ?1)
+		AND (?2 = '' OR action = ?2)
+		AND (?3 = '' OR resource_type = ?3)
+		AND (?4 = '' OR resource_id = ?4)
+		AND (?5 = '' OR created_at >= ?5)
+		AND (?6 = '' OR created_at < ?6)
+		ORDER BY id DESC LIMIT ?7 OFFSET ?8`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readManyStmt = readManyStmt
+
+	go CloseAudit(ctx, repo)
+
+	return repo, nil
+}
+
+func CloseAudit(ctx context.Context, r *AuditRepository) {
+	<-ctx.Done()
+	r.createStmt.Close()
+	r.readManyStmt.Close()
+	r.sqlDB.Close()
+}
+
+func (r *AuditRepository) Create(entry *models.AuditEntry, ctx context.Context) error {
+	res, err := r.createStmt.ExecContext(ctx, entry.Actor, entry.Action, entry.ResourceType, entry.ResourceID,
+		string(entry.Before), string(entry.After), string(entry.Changes), entry.RequestID, entry.CreatedAt)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	entry.ID = int(id)
+	return nil
+}
+
+// ReadMany returns the entries matching filter, newest first
+func (r *AuditRepository) ReadMany(filter *models.AuditFilter, page int, rowsPerPage int, ctx context.Context) ([]*models.AuditEntry, error) {
+	if page < 1 {
+		page, rowsPerPage = 1, -1 // SQLite treats a negative LIMIT as no limit
+	}
+
+	offset := rowsPerPage * (page - 1)
+	rows, err := r.readManyStmt.QueryContext(ctx, filter.Actor, filter.Action, filter.ResourceType, filter.ResourceID,
+		filter.Since, filter.Until, rowsPerPage, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*models.AuditEntry
+	for rows.Next() {
+		var e models.AuditEntry
+		var before, after, changes string
+		err := rows.Scan(&e.ID, &e.Actor, &e.Action, &e.ResourceType, &e.ResourceID, &before, &after, &changes, &e.RequestID, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		e.Before, e.After, e.Changes = []byte(before), []byte(after), []byte(changes)
+		entries = append(entries, &e)
+	}
+	return entries, nil
+}
@@ -0,0 +1,102 @@
+package SQLite
+
+import (
+	"context"
+	"database/sql"
+	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/models"
+	"log/slog"
+)
+
+type AuthLockoutRepository struct {
+	sqlDB *sql.DB
+	createStmt,
+	readManyStmt *instrumentedStmt
+	ctx context.Context
+}
+
+func NewAuthLockoutRepository(sqlDB DAL.SQLDatabase, logger *slog.Logger, ctx context.Context) (models.AuthLockoutRepository, error) {
+
+	repo := &AuthLockoutRepository{
+		sqlDB: sqlDB.Connection(),
+		ctx:   ctx,
+	}
+
+	// Create the auth_lockout table if it doesn't exist
+	if _, err := repo.sqlDB.Exec(`CREATE TABLE IF NOT EXISTS auth_lockout (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		subject VARCHAR(20) NOT NULL,
+		value VARCHAR(100) NOT NULL,
+		failures INTEGER NOT NULL,
+		remote_addr VARCHAR(64) NOT NULL,
+		locked_at TIMESTAMP NOT NULL,
+		locked_until TIMESTAMP NOT NULL
+	);`); err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+
+	// Prepare SQL statements
+	createStmt, err := prepare(sqlDB, logger, "INSERT INTO auth_lockout (subject, value, failures, remote_addr, locked_at, locked_until) VALUES (?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.createStmt = createStmt
+
+	readManyStmt, err := prepare(sqlDB, logger, "SELECT id, subject, value, failures, remote_addr, locked_at, locked_until FROM auth_lockout ORDER BY id DESC LIMIT ? OFFSET ?")
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readManyStmt = readManyStmt
+
+	go CloseAuthLockout(ctx, repo)
+
+	return repo, nil
+}
+
+func CloseAuthLockout(ctx context.Context, r *AuthLockoutRepository) {
+	<-ctx.Done()
+	r.createStmt.Close()
+	r.readManyStmt.Close()
+	r.sqlDB.Close()
+}
+
+func (r *AuthLockoutRepository) Create(lockout *models.AuthLockout, ctx context.Context) error {
+	res, err := r.createStmt.ExecContext(ctx, lockout.Subject, lockout.Value, lockout.Failures, lockout.RemoteAddr, lockout.LockedAt, lockout.LockedUntil)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	lockout.ID = int(id)
+	return nil
+}
+
+// ReadMany returns lockouts newest first
+func (r *AuthLockoutRepository) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.AuthLockout, error) {
+	if page < 1 {
+		page, rowsPerPage = 1, -1 // SQLite treats a negative LIMIT as no limit
+	}
+
+	offset := rowsPerPage * (page - 1)
+	rows, err := r.readManyStmt.QueryContext(ctx, rowsPerPage, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lockouts []*models.AuthLockout
+	for rows.Next() {
+		var l models.AuthLockout
+		err := rows.Scan(&l.ID, &l.Subject, &l.Value, &l.Failures, &l.RemoteAddr, &l.LockedAt, &l.LockedUntil)
+		if err != nil {
+			return nil, err
+		}
+		lockouts = append(lockouts, &l)
+	}
+	return lockouts, nil
+}
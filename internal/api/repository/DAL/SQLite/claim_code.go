@@ -0,0 +1,233 @@
+package SQLite
+
+import (
+	"context"
+	"database/sql"
+	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/models"
+	"log/slog"
+)
+
+type ClaimCodeRepository struct {
+	sqlDB *sql.DB
+	createStmt,
+	readByCodeStmt,
+	readByIssuerStmt,
+	redeemStmt,
+	createCredentialStmt,
+	updateCredentialStmt,
+	deleteStmt,
+	deleteExpiredStmt *instrumentedStmt
+	ctx context.Context
+}
+
+func NewClaimCodeRepository(sqlDB DAL.SQLDatabase, logger *slog.Logger, ctx context.Context) (models.ClaimCodeRepository, error) {
+
+	repo := &ClaimCodeRepository{
+		sqlDB: sqlDB.Connection(),
+		ctx:   ctx,
+	}
+
+	// Create the claim_code table if it doesn't exist
+	if _, err := repo.sqlDB.Exec(`CREATE TABLE IF NOT EXISTS claim_code (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		code VARCHAR(16) NOT NULL UNIQUE,
+		issued_by VARCHAR(50) NOT NULL,
+		device_id VARCHAR(50) NOT NULL DEFAULT '',
+		hardware_id VARCHAR(50) NOT NULL DEFAULT '',
+		expires_at TIMESTAMP NOT NULL,
+		used_at TIMESTAMP NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL
+	);`); err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+
+	// Create index on issued_by for listing an account's claim codes
+	if _, err := repo.sqlDB.Exec(`CREATE INDEX IF NOT EXISTS idx_claim_code_issued_by ON claim_code(issued_by);`); err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+
+	// Prepare SQL statements
+	createStmt, err := prepare(sqlDB, logger, `INSERT INTO claim_code (code, issued_by, device_id, expires_at, created_at) VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.createStmt = createStmt
+
+	readByCodeStmt, err := prepare(sqlDB, logger, "SELECT id, code, issued_by, device_id, hardware_id, expires_at, used_at, created_at FROM claim_code WHERE code = ?")
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readByCodeStmt = readByCodeStmt
+
+	readByIssuerStmt, err := prepare(sqlDB, logger, "SELECT id, code, issued_by, device_id, hardware_id, expires_at, used_at, created_at FROM claim_code WHERE issued_by = ? ORDER BY created_at DESC")
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readByIssuerStmt = readByIssuerStmt
+
+	// The WHERE clause makes redemption atomic: only one caller can flip an unused, unexpired code to used
+	redeemStmt, err := prepare(sqlDB, logger, "UPDATE claim_code SET hardware_id = ?, device_id = ?, used_at = ? WHERE code = ? AND used_at = '' AND expires_at > ?")
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.redeemStmt = redeemStmt
+
+	// Needs the device_credential table, create the DeviceCredentialRepository first
+	createCredentialStmt, err := prepare(sqlDB, logger, createCredentialQuery)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.createCredentialStmt = createCredentialStmt
+
+	updateCredentialStmt, err := prepare(sqlDB, logger, updateCredentialQuery)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.updateCredentialStmt = updateCredentialStmt
+
+	deleteStmt, err := prepare(sqlDB, logger, "DELETE FROM claim_code WHERE id = ? AND issued_by = ?")
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.deleteStmt = deleteStmt
+
+	// Redeemed codes are kept as a record of which account provisioned a device
+	deleteExpiredStmt, err := prepare(sqlDB, logger, "DELETE FROM claim_code WHERE used_at = '' AND expires_at <= ?")
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.deleteExpiredStmt = deleteExpiredStmt
+
+	go CloseClaimCode(ctx, repo)
+
+	return repo, nil
+}
+
+func CloseClaimCode(ctx context.Context, r *ClaimCodeRepository) {
+	<-ctx.Done()
+	r.createStmt.Close()
+	r.readByCodeStmt.Close()
+	r.readByIssuerStmt.Close()
+	r.redeemStmt.Close()
+	r.createCredentialStmt.Close()
+	r.updateCredentialStmt.Close()
+	r.deleteStmt.Close()
+	r.deleteExpiredStmt.Close()
+	r.sqlDB.Close()
+}
+
+func (r *ClaimCodeRepository) Create(claim *models.ClaimCode, ctx context.Context) error {
+	res, err := r.createStmt.ExecContext(ctx, claim.Code, claim.IssuedBy, claim.DeviceID, claim.ExpiresAt, claim.CreatedAt)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	claim.ID = int(id)
+	return nil
+}
+
+func (r *ClaimCodeRepository) ReadByCode(code string, ctx context.Context) (*models.ClaimCode, error) {
+	row := r.readByCodeStmt.QueryRowContext(ctx, code)
+	var claim models.ClaimCode
+	err := row.Scan(&claim.ID, &claim.Code, &claim.IssuedBy, &claim.DeviceID, &claim.HardwareID, &claim.ExpiresAt, &claim.UsedAt, &claim.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &claim, nil
+}
+
+func (r *ClaimCodeRepository) ReadByIssuer(issuedBy string, ctx context.Context) ([]*models.ClaimCode, error) {
+	rows, err := r.readByIssuerStmt.QueryContext(ctx, issuedBy)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var claims []*models.ClaimCode
+	for rows.Next() {
+		var c models.ClaimCode
+		err := rows.Scan(&c.ID, &c.Code, &c.IssuedBy, &c.DeviceID, &c.HardwareID, &c.ExpiresAt, &c.UsedAt, &c.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		claims = append(claims, &c)
+	}
+	return claims, nil
+}
+
+// Redeem marks the claim code as used by claim.HardwareID at claim.UsedAt and stores the credential issued for it in
+// the same transaction, so a failed write leaves the code redeemable. A credential without an ID is created, one with
+// an ID updated. Zero rows affected means the code does not exist, is already used or has expired, nothing is stored.
+func (r *ClaimCodeRepository) Redeem(claim *models.ClaimCode, credential *models.DeviceCredential, ctx context.Context) (int64, error) {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := r.redeemStmt.inTx(ctx, tx).ExecContext(ctx, claim.HardwareID, claim.DeviceID, claim.UsedAt, claim.Code, claim.UsedAt)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil || rowsAffected == 0 {
+		return 0, err
+	}
+
+	id := int64(credential.ID)
+	if credential.ID == 0 {
+		res, err := r.createCredentialStmt.inTx(ctx, tx).ExecContext(ctx, credential.DeviceID, credential.HardwareID, credential.Owner, credential.SecretHash, credential.CreatedAt)
+		if err != nil {
+			return 0, err
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return 0, err
+		}
+	} else if _, err := r.updateCredentialStmt.inTx(ctx, tx).ExecContext(ctx, credential.DeviceID, credential.HardwareID, credential.Owner, credential.SecretHash, credential.CreatedAt, credential.ID); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	credential.ID = int(id)
+	return rowsAffected, nil
+}
+
+func (r *ClaimCodeRepository) Delete(claim *models.ClaimCode, ctx context.Context) (int64, error) {
+	res, err := r.deleteStmt.ExecContext(ctx, claim.ID, claim.IssuedBy)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return rowsAffected, nil
+}
+
+// DeleteExpired removes unused claim codes that expired at or before the given RFC3339 timestamp
+func (r *ClaimCodeRepository) DeleteExpired(before string, ctx context.Context) (int64, error) {
+	res, err := r.deleteExpiredStmt.ExecContext(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
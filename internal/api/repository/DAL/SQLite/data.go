@@ -5,19 +5,23 @@ import (
 	"database/sql"
 	"goapi/internal/api/repository/DAL"
 	"goapi/internal/api/repository/models"
+	"log/slog"
 )
 
 type DataRepository struct {
 	sqlDB *sql.DB
 	createStmt,
+	createNewStmt,
 	readStmt,
 	readManyStmt,
 	updateStmt,
-	deleteStmt *sql.Stmt
+	deleteStmt,
+	findStmt,
+	exportStmt *instrumentedStmt
 	ctx context.Context
 }
 
-func NewDataRepository(sqlDB DAL.SQLDatabase, ctx context.Context) (models.DataRepository, error) {
+func NewDataRepository(sqlDB DAL.SQLDatabase, logger *slog.Logger, ctx context.Context) (models.DataRepository, error) {
 
 	repo := &DataRepository{
 		sqlDB: sqlDB.Connection(),
@@ -32,48 +36,95 @@ func NewDataRepository(sqlDB DAL.SQLDatabase, ctx context.Context) (models.DataR
 		value FLOAT,
 		data_type VARCHAR(20),
 		date_time TIMESTAMP,
-		description TEXT
+		description TEXT,
+		version INTEGER NOT NULL DEFAULT 1
 	);`); err != nil {
 		repo.sqlDB.Close()
 		return nil, err
 	}
 
+	// Tables created before optimistic concurrency lack the version column
+	if err := addColumn(repo.sqlDB, "data", "version", "INTEGER NOT NULL DEFAULT 1"); err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+
+	// Batch uploads skip readings already stored for the device and time, the index makes that safe against
+	// concurrent batches. Duplicates stored before it existed fail the setup rather than being removed.
+	if err := addUniqueIndex(repo.sqlDB, "data", "idx_data_device_id_date_time_unique", "device_id", "date_time"); err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+
+	// The unique index replaces the plain one on the same columns
+	if _, err := repo.sqlDB.Exec(`DROP INDEX IF EXISTS idx_data_device_id_date_time;`); err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+
 	// * Create needed Prepared SQL statements, this is more efficient than running each query individually
-	createStmt, err := repo.sqlDB.Prepare(`INSERT INTO data (device_id, device_name, value, data_type, date_time, description) VALUES (?, ?, ?, ?, ?, ?)`)
+	createStmt, err := prepare(sqlDB, logger, `INSERT INTO data (device_id, device_name, value, data_type, date_time, description) VALUES (?, ?, ?, ?, ?, ?)`)
 	if err != nil {
 		repo.sqlDB.Close() // Close the database connection if statement preparation fails
 		return nil, err
 	}
 	repo.createStmt = createStmt
 
-	readStmt, err := repo.sqlDB.Prepare("SELECT id, device_id, device_name, value, data_type, date_time, description FROM data WHERE id = ?")
+	createNewStmt, err := prepare(sqlDB, logger, `INSERT INTO data (device_id, device_name, value, data_type, date_time, description) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (device_id, date_time) DO NOTHING`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.createNewStmt = createNewStmt
+
+	readStmt, err := prepare(sqlDB, logger, "SELECT id, device_id, device_name, value, data_type, date_time, description, version FROM data WHERE id = ?")
 	if err != nil {
 		repo.sqlDB.Close()
 		return nil, err
 	}
 	repo.readStmt = readStmt
 
-	readManyStmt, err := repo.sqlDB.Prepare("SELECT id, device_id, device_name, value, data_type, date_time, description FROM data LIMIT ? OFFSET ?")
+	readManyStmt, err := prepare(sqlDB, logger, "SELECT id, device_id, device_name, value, data_type, date_time, description, version FROM data LIMIT ? OFFSET ?")
 	if err != nil {
 		repo.sqlDB.Close()
 		return nil, err
 	}
 	repo.readManyStmt = readManyStmt
 
-	updateStmt, err := repo.sqlDB.Prepare("UPDATE data SET device_id = ?, device_name = ?, value = ?, data_type = ?, date_time = ?, description = ? WHERE id = ?")
+	updateStmt, err := prepare(sqlDB, logger, "UPDATE data SET device_id = ?, device_name = ?, value = ?, data_type = ?, date_time = ?, description = ?, version = version + 1 WHERE id = ? AND (? = 0 OR version = ?) RETURNING version")
 	if err != nil {
 		repo.sqlDB.Close()
 		return nil, err
 	}
 	repo.updateStmt = updateStmt
 
-	deleteStmt, err := repo.sqlDB.Prepare("DELETE FROM data WHERE id = ?")
+	deleteStmt, err := prepare(sqlDB, logger, "DELETE FROM data WHERE id = ? AND (? = 0 OR version = ?)")
 	if err != nil {
 		repo.sqlDB.Close()
 		return nil, err
 	}
 	repo.deleteStmt = deleteStmt
 
+	findStmt, err := prepare(sqlDB, logger, "SELECT id, version FROM data WHERE device_id = ? AND date_time = ? LIMIT 1")
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.findStmt = findStmt
+
+	// Empty filter values match every row, datetime() compares timestamps written with different offsets
+	exportStmt, err := prepare(sqlDB, logger, `SELECT id, device_id, device_name, value, data_type, date_time, description, version FROM data
+		WHERE (?1 = '' OR device_id = ?1)
+		AND (?2 = '' OR datetime(date_time) >= datetime(?2))
+		AND (?3 = '' OR datetime(date_time) < datetime(?3))
+		ORDER BY id`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.exportStmt = exportStmt
+
 	go Close(ctx, repo)
 
 	return repo, nil
@@ -83,10 +134,13 @@ func Close(ctx context.Context, r *DataRepository) {
 
 	<-ctx.Done()
 	r.createStmt.Close()
+	r.createNewStmt.Close()
 	r.readStmt.Close()
 	r.updateStmt.Close()
 	r.deleteStmt.Close()
 	r.readManyStmt.Close()
+	r.findStmt.Close()
+	r.exportStmt.Close()
 	r.sqlDB.Close()
 }
 
@@ -101,13 +155,74 @@ func (r *DataRepository) Create(data *models.Data, ctx context.Context) error {
 		return err
 	}
 	data.ID = int(id)
+	data.Version = 1
 	return nil
 }
 
+// CreateMany inserts the data in one transaction, skipping data whose device_id and date_time are already stored.
+// created reports which data were inserted, skipped data get the ID of the stored data.
+func (r *DataRepository) CreateMany(data []*models.Data, ctx context.Context) ([]bool, error) {
+	return r.createMany(data, true, ctx)
+}
+
+// CheckMany reports which data CreateMany would insert without storing any, the transaction is rolled back.
+// Only skipped data get an ID.
+func (r *DataRepository) CheckMany(data []*models.Data, ctx context.Context) ([]bool, error) {
+	return r.createMany(data, false, ctx)
+}
+
+func (r *DataRepository) createMany(data []*models.Data, commit bool, ctx context.Context) ([]bool, error) {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	createStmt := r.createNewStmt.inTx(ctx, tx)
+	findStmt := r.findStmt.inTx(ctx, tx)
+
+	created := make([]bool, len(data))
+	for i, d := range data {
+		// The unique index skips duplicates, also those inserted earlier in the same batch or by a concurrent one
+		res, err := createStmt.ExecContext(ctx, d.DeviceID, d.DeviceName, d.Value, d.Type, d.DateTime, d.Description)
+		if err != nil {
+			return nil, err
+		}
+		inserted, err := res.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if inserted == 0 {
+			if err := findStmt.QueryRowContext(ctx, d.DeviceID, d.DateTime).Scan(&d.ID, &d.Version); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		created[i] = true
+		if !commit {
+			continue
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		d.ID = int(id)
+		d.Version = 1
+	}
+
+	if !commit {
+		return created, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
 func (r *DataRepository) ReadOne(id int, ctx context.Context) (*models.Data, error) {
 	row := r.readStmt.QueryRowContext(ctx, id)
 	var data models.Data
-	err := row.Scan(&data.ID, &data.DeviceID, &data.DeviceName, &data.Value, &data.Type, &data.DateTime, &data.Description)
+	err := row.Scan(&data.ID, &data.DeviceID, &data.DeviceName, &data.Value, &data.Type, &data.DateTime, &data.Description, &data.Version)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -117,64 +232,65 @@ func (r *DataRepository) ReadOne(id int, ctx context.Context) (*models.Data, err
 	return &data, nil
 }
 
-func (r *DataRepository) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.Data, error) {
+// ReadMany returns a page of data, all of it when page is below 1. Rows are read as the sequence is ranged over.
+func (r *DataRepository) ReadMany(page int, rowsPerPage int, ctx context.Context) models.Rows[models.Data] {
 
 	if page < 1 {
-		return r.ReadAll()
+		return r.ReadAll(ctx)
 	}
 
 	offset := rowsPerPage * (page - 1)
-	rows, err := r.readManyStmt.QueryContext(ctx, rowsPerPage, offset)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+	return queryRows(func() (cursor, error) {
+		return r.readManyStmt.QueryContext(ctx, rowsPerPage, offset)
+	}, scanData)
+}
 
-	var data []*models.Data
-	for rows.Next() {
-		var d models.Data
-		err := rows.Scan(&d.ID, &d.DeviceID, &d.DeviceName, &d.Value, &d.Type, &d.DateTime, &d.Description)
-		if err != nil {
-			return nil, err
-		}
-		data = append(data, &d)
-	}
-	return data, nil
+// scanData scans a row holding every column of data
+func scanData(rows scanner, d *models.Data) error {
+	return rows.Scan(&d.ID, &d.DeviceID, &d.DeviceName, &d.Value, &d.Type, &d.DateTime, &d.Description, &d.Version)
 }
 
-func (r *DataRepository) ReadAll() ([]*models.Data, error) {
-	rows, err := r.sqlDB.QueryContext(context.Background(), "SELECT id, device_id, device_name, value, data_type, date_time, description FROM data")
+// Export calls each for every row matching filter in the order they were stored, rows are read one at a time
+// so large tables are never held in memory. An error returned by each stops the export.
+func (r *DataRepository) Export(filter *models.ExportFilter, each func(data *models.Data) error, ctx context.Context) error {
+	rows, err := r.exportStmt.QueryContext(ctx, filter.DeviceID, filter.Since, filter.Until)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	var data []*models.Data
 	for rows.Next() {
 		var d models.Data
-		err := rows.Scan(&d.ID, &d.DeviceID, &d.DeviceName, &d.Value, &d.Type, &d.DateTime, &d.Description)
+		err := rows.Scan(&d.ID, &d.DeviceID, &d.DeviceName, &d.Value, &d.Type, &d.DateTime, &d.Description, &d.Version)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := each(&d); err != nil {
+			return err
 		}
-		data = append(data, &d)
 	}
-	return data, nil
+	return rows.Err()
+}
+
+func (r *DataRepository) ReadAll(ctx context.Context) models.Rows[models.Data] {
+	return queryRows(func() (cursor, error) {
+		return r.sqlDB.QueryContext(ctx, "SELECT id, device_id, device_name, value, data_type, date_time, description, version FROM data")
+	}, scanData)
 }
 
 func (r *DataRepository) Update(data *models.Data, ctx context.Context) (int64, error) {
-	res, err := r.updateStmt.ExecContext(ctx, data.DeviceID, data.DeviceName, data.Value, data.Type, data.DateTime, data.Description, data.ID)
-	if err != nil {
-		return 0, err
-	}
-	rowsAffected, err := res.RowsAffected()
-	if err != nil {
-		return 0, err
+	row := r.updateStmt.QueryRowContext(ctx, data.DeviceID, data.DeviceName, data.Value, data.Type, data.DateTime, data.Description, data.ID, data.Version, data.Version)
+	if err := row.Scan(&data.Version); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, uniqueViolation(err)
 	}
-	return rowsAffected, nil
+	return 1, nil
 }
 
 func (r *DataRepository) Delete(data *models.Data, ctx context.Context) (int64, error) {
-	res, err := r.deleteStmt.ExecContext(ctx, data.ID)
+	res, err := r.deleteStmt.ExecContext(ctx, data.ID, data.Version, data.Version)
 	if err != nil {
 		return 0, err
 	}
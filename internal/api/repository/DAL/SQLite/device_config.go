@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"goapi/internal/api/repository/DAL"
 	"goapi/internal/api/repository/models"
+	"log/slog"
 )
 
 type DeviceConfigRepository struct {
@@ -14,11 +15,11 @@ type DeviceConfigRepository struct {
 	readByDeviceIDStmt,
 	readManyStmt,
 	updateStmt,
-	deleteStmt *sql.Stmt
+	deleteStmt *instrumentedStmt
 	ctx context.Context
 }
 
-func NewDeviceConfigRepository(sqlDB DAL.SQLDatabase, ctx context.Context) (models.DeviceConfigRepository, error) {
+func NewDeviceConfigRepository(sqlDB DAL.SQLDatabase, logger *slog.Logger, ctx context.Context) (models.DeviceConfigRepository, error) {
 
 	repo := &DeviceConfigRepository{
 		sqlDB: sqlDB.Connection(),
@@ -31,12 +32,19 @@ func NewDeviceConfigRepository(sqlDB DAL.SQLDatabase, ctx context.Context) (mode
 		device_id VARCHAR(50) NOT NULL UNIQUE,
 		alarm_timeout INTEGER NOT NULL,
 		sensitivity_level INTEGER NOT NULL CHECK(sensitivity_level >= 1 AND sensitivity_level <= 10),
-		updated_at TIMESTAMP NOT NULL
+		updated_at TIMESTAMP NOT NULL,
+		version INTEGER NOT NULL DEFAULT 1
 	);`); err != nil {
 		repo.sqlDB.Close()
 		return nil, err
 	}
 
+	// Tables created before optimistic concurrency lack the version column
+	if err := addColumn(repo.sqlDB, "device_config", "version", "INTEGER NOT NULL DEFAULT 1"); err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+
 	// Create index on device_id for faster queries
 	if _, err := repo.sqlDB.Exec(`CREATE INDEX IF NOT EXISTS idx_device_config_device_id ON device_config(device_id);`); err != nil {
 		repo.sqlDB.Close()
@@ -44,42 +52,42 @@ func NewDeviceConfigRepository(sqlDB DAL.SQLDatabase, ctx context.Context) (mode
 	}
 
 	// Prepare SQL statements
-	createStmt, err := repo.sqlDB.Prepare(`INSERT INTO device_config (device_id, alarm_timeout, sensitivity_level, updated_at) VALUES (?, ?, ?, ?)`)
+	createStmt, err := prepare(sqlDB, logger, `INSERT INTO device_config (device_id, alarm_timeout, sensitivity_level, updated_at) VALUES (?, ?, ?, ?)`)
 	if err != nil {
 		repo.sqlDB.Close()
 		return nil, err
 	}
 	repo.createStmt = createStmt
 
-	readStmt, err := repo.sqlDB.Prepare("SELECT id, device_id, alarm_timeout, sensitivity_level, updated_at FROM device_config WHERE id = ?")
+	readStmt, err := prepare(sqlDB, logger, "SELECT id, device_id, alarm_timeout, sensitivity_level, updated_at, version FROM device_config WHERE id = ?")
 	if err != nil {
 		repo.sqlDB.Close()
 		return nil, err
 	}
 	repo.readStmt = readStmt
 
-	readByDeviceIDStmt, err := repo.sqlDB.Prepare("SELECT id, device_id, alarm_timeout, sensitivity_level, updated_at FROM device_config WHERE device_id = ?")
+	readByDeviceIDStmt, err := prepare(sqlDB, logger, "SELECT id, device_id, alarm_timeout, sensitivity_level, updated_at, version FROM device_config WHERE device_id = ?")
 	if err != nil {
 		repo.sqlDB.Close()
 		return nil, err
 	}
 	repo.readByDeviceIDStmt = readByDeviceIDStmt
 
-	readManyStmt, err := repo.sqlDB.Prepare("SELECT id, device_id, alarm_timeout, sensitivity_level, updated_at FROM device_config LIMIT ? OFFSET ?")
+	readManyStmt, err := prepare(sqlDB, logger, "SELECT id, device_id, alarm_timeout, sensitivity_level, updated_at, version FROM device_config LIMIT ? OFFSET ?")
 	if err != nil {
 		repo.sqlDB.Close()
 		return nil, err
 	}
 	repo.readManyStmt = readManyStmt
 
-	updateStmt, err := repo.sqlDB.Prepare("UPDATE device_config SET device_id = ?, alarm_timeout = ?, sensitivity_level = ?, updated_at = ? WHERE id = ?")
+	updateStmt, err := prepare(sqlDB, logger, "UPDATE device_config SET device_id = ?, alarm_timeout = ?, sensitivity_level = ?, updated_at = ?, version = version + 1 WHERE id = ? AND (? = 0 OR version = ?) RETURNING version")
 	if err != nil {
 		repo.sqlDB.Close()
 		return nil, err
 	}
 	repo.updateStmt = updateStmt
 
-	deleteStmt, err := repo.sqlDB.Prepare("DELETE FROM device_config WHERE id = ?")
+	deleteStmt, err := prepare(sqlDB, logger, "DELETE FROM device_config WHERE id = ? AND (? = 0 OR version = ?)")
 	if err != nil {
 		repo.sqlDB.Close()
 		return nil, err
@@ -112,13 +120,74 @@ func (r *DeviceConfigRepository) Create(config *models.DeviceConfig, ctx context
 		return err
 	}
 	config.ID = int(id)
+	config.Version = 1
 	return nil
 }
 
+// CreateMany inserts the configs in one transaction, skipping configs of devices that already have one.
+// created reports which configs were inserted, skipped configs get the ID of the stored config.
+func (r *DeviceConfigRepository) CreateMany(configs []*models.DeviceConfig, ctx context.Context) ([]bool, error) {
+	return r.createMany(configs, true, ctx)
+}
+
+// CheckMany reports which configs CreateMany would insert without storing any, the transaction is rolled back.
+// Only skipped configs get an ID.
+func (r *DeviceConfigRepository) CheckMany(configs []*models.DeviceConfig, ctx context.Context) ([]bool, error) {
+	return r.createMany(configs, false, ctx)
+}
+
+func (r *DeviceConfigRepository) createMany(configs []*models.DeviceConfig, commit bool, ctx context.Context) ([]bool, error) {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	createStmt := r.createStmt.inTx(ctx, tx)
+	readByDeviceIDStmt := r.readByDeviceIDStmt.inTx(ctx, tx)
+
+	created := make([]bool, len(configs))
+	for i, config := range configs {
+		// Also finds configs inserted earlier in the same batch
+		var stored models.DeviceConfig
+		err := readByDeviceIDStmt.QueryRowContext(ctx, config.DeviceID).Scan(&stored.ID, &stored.DeviceID, &stored.AlarmTimeout, &stored.SensitivityLevel, &stored.UpdatedAt, &stored.Version)
+		if err == nil {
+			config.ID, config.Version = stored.ID, stored.Version
+			continue
+		}
+		if err != sql.ErrNoRows {
+			return nil, err
+		}
+
+		res, err := createStmt.ExecContext(ctx, config.DeviceID, config.AlarmTimeout, config.SensitivityLevel, config.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		created[i] = true
+		if !commit {
+			continue
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		config.ID = int(id)
+		config.Version = 1
+	}
+
+	if !commit {
+		return created, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
 func (r *DeviceConfigRepository) ReadOne(id int, ctx context.Context) (*models.DeviceConfig, error) {
 	row := r.readStmt.QueryRowContext(ctx, id)
 	var config models.DeviceConfig
-	err := row.Scan(&config.ID, &config.DeviceID, &config.AlarmTimeout, &config.SensitivityLevel, &config.UpdatedAt)
+	err := row.Scan(&config.ID, &config.DeviceID, &config.AlarmTimeout, &config.SensitivityLevel, &config.UpdatedAt, &config.Version)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -131,7 +200,7 @@ func (r *DeviceConfigRepository) ReadOne(id int, ctx context.Context) (*models.D
 func (r *DeviceConfigRepository) ReadByDeviceID(deviceID string, ctx context.Context) (*models.DeviceConfig, error) {
 	row := r.readByDeviceIDStmt.QueryRowContext(ctx, deviceID)
 	var config models.DeviceConfig
-	err := row.Scan(&config.ID, &config.DeviceID, &config.AlarmTimeout, &config.SensitivityLevel, &config.UpdatedAt)
+	err := row.Scan(&config.ID, &config.DeviceID, &config.AlarmTimeout, &config.SensitivityLevel, &config.UpdatedAt, &config.Version)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -141,63 +210,42 @@ func (r *DeviceConfigRepository) ReadByDeviceID(deviceID string, ctx context.Con
 	return &config, nil
 }
 
-func (r *DeviceConfigRepository) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.DeviceConfig, error) {
+// ReadMany returns a page of configs, all of them when page is below 1. They are read as the sequence is ranged over.
+func (r *DeviceConfigRepository) ReadMany(page int, rowsPerPage int, ctx context.Context) models.Rows[models.DeviceConfig] {
 	if page < 1 {
 		return r.ReadAll(ctx)
 	}
 
 	offset := rowsPerPage * (page - 1)
-	rows, err := r.readManyStmt.QueryContext(ctx, rowsPerPage, offset)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var configs []*models.DeviceConfig
-	for rows.Next() {
-		var c models.DeviceConfig
-		err := rows.Scan(&c.ID, &c.DeviceID, &c.AlarmTimeout, &c.SensitivityLevel, &c.UpdatedAt)
-		if err != nil {
-			return nil, err
-		}
-		configs = append(configs, &c)
-	}
-	return configs, nil
+	return queryRows(func() (cursor, error) {
+		return r.readManyStmt.QueryContext(ctx, rowsPerPage, offset)
+	}, scanConfig)
 }
 
-func (r *DeviceConfigRepository) ReadAll(ctx context.Context) ([]*models.DeviceConfig, error) {
-	rows, err := r.sqlDB.QueryContext(ctx, "SELECT id, device_id, alarm_timeout, sensitivity_level, updated_at FROM device_config")
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+func (r *DeviceConfigRepository) ReadAll(ctx context.Context) models.Rows[models.DeviceConfig] {
+	return queryRows(func() (cursor, error) {
+		return r.sqlDB.QueryContext(ctx, "SELECT id, device_id, alarm_timeout, sensitivity_level, updated_at, version FROM device_config")
+	}, scanConfig)
+}
 
-	var configs []*models.DeviceConfig
-	for rows.Next() {
-		var c models.DeviceConfig
-		err := rows.Scan(&c.ID, &c.DeviceID, &c.AlarmTimeout, &c.SensitivityLevel, &c.UpdatedAt)
-		if err != nil {
-			return nil, err
-		}
-		configs = append(configs, &c)
-	}
-	return configs, nil
+// scanConfig scans a row holding every column of device_config
+func scanConfig(rows scanner, c *models.DeviceConfig) error {
+	return rows.Scan(&c.ID, &c.DeviceID, &c.AlarmTimeout, &c.SensitivityLevel, &c.UpdatedAt, &c.Version)
 }
 
 func (r *DeviceConfigRepository) Update(config *models.DeviceConfig, ctx context.Context) (int64, error) {
-	res, err := r.updateStmt.ExecContext(ctx, config.DeviceID, config.AlarmTimeout, config.SensitivityLevel, config.UpdatedAt, config.ID)
-	if err != nil {
-		return 0, err
-	}
-	rowsAffected, err := res.RowsAffected()
-	if err != nil {
-		return 0, err
+	row := r.updateStmt.QueryRowContext(ctx, config.DeviceID, config.AlarmTimeout, config.SensitivityLevel, config.UpdatedAt, config.ID, config.Version, config.Version)
+	if err := row.Scan(&config.Version); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, uniqueViolation(err)
 	}
-	return rowsAffected, nil
+	return 1, nil
 }
 
 func (r *DeviceConfigRepository) Delete(config *models.DeviceConfig, ctx context.Context) (int64, error) {
-	res, err := r.deleteStmt.ExecContext(ctx, config.ID)
+	res, err := r.deleteStmt.ExecContext(ctx, config.ID, config.Version, config.Version)
 	if err != nil {
 		return 0, err
 	}
@@ -0,0 +1,132 @@
+package SQLite
+
+import (
+	"context"
+	"database/sql"
+	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/models"
+	"log/slog"
+)
+
+// Credentials are also written by ClaimCodeRepository.Redeem, in the transaction that redeems the claim code
+const (
+	createCredentialQuery = `INSERT INTO device_credential (device_id, hardware_id, owner, secret_hash, created_at) VALUES (?, ?, ?, ?, ?)`
+	updateCredentialQuery = "UPDATE device_credential SET device_id = ?, hardware_id = ?, owner = ?, secret_hash = ?, created_at = ? WHERE id = ?"
+)
+
+type DeviceCredentialRepository struct {
+	sqlDB *sql.DB
+	createStmt,
+	readByDeviceIDStmt,
+	readByHardwareIDStmt,
+	updateStmt *instrumentedStmt
+	ctx context.Context
+}
+
+func NewDeviceCredentialRepository(sqlDB DAL.SQLDatabase, logger *slog.Logger, ctx context.Context) (models.DeviceCredentialRepository, error) {
+
+	repo := &DeviceCredentialRepository{
+		sqlDB: sqlDB.Connection(),
+		ctx:   ctx,
+	}
+
+	// Create the device_credential table if it doesn't exist
+	if _, err := repo.sqlDB.Exec(`CREATE TABLE IF NOT EXISTS device_credential (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		device_id VARCHAR(50) NOT NULL UNIQUE,
+		hardware_id VARCHAR(50) NOT NULL UNIQUE,
+		owner VARCHAR(50) NOT NULL,
+		secret_hash VARCHAR(64) NOT NULL,
+		created_at TIMESTAMP NOT NULL
+	);`); err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+
+	// Prepare SQL statements
+	createStmt, err := prepare(sqlDB, logger, createCredentialQuery)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.createStmt = createStmt
+
+	readByDeviceIDStmt, err := prepare(sqlDB, logger, "SELECT id, device_id, hardware_id, owner, secret_hash, created_at FROM device_credential WHERE device_id = ?")
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readByDeviceIDStmt = readByDeviceIDStmt
+
+	readByHardwareIDStmt, err := prepare(sqlDB, logger, "SELECT id, device_id, hardware_id, owner, secret_hash, created_at FROM device_credential WHERE hardware_id = ?")
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readByHardwareIDStmt = readByHardwareIDStmt
+
+	updateStmt, err := prepare(sqlDB, logger, updateCredentialQuery)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.updateStmt = updateStmt
+
+	go CloseDeviceCredential(ctx, repo)
+
+	return repo, nil
+}
+
+func CloseDeviceCredential(ctx context.Context, r *DeviceCredentialRepository) {
+	<-ctx.Done()
+	r.createStmt.Close()
+	r.readByDeviceIDStmt.Close()
+	r.readByHardwareIDStmt.Close()
+	r.updateStmt.Close()
+	r.sqlDB.Close()
+}
+
+func (r *DeviceCredentialRepository) Create(credential *models.DeviceCredential, ctx context.Context) error {
+	res, err := r.createStmt.ExecContext(ctx, credential.DeviceID, credential.HardwareID, credential.Owner, credential.SecretHash, credential.CreatedAt)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	credential.ID = int(id)
+	return nil
+}
+
+func (r *DeviceCredentialRepository) ReadByDeviceID(deviceID string, ctx context.Context) (*models.DeviceCredential, error) {
+	return r.scanOne(r.readByDeviceIDStmt.QueryRowContext(ctx, deviceID))
+}
+
+func (r *DeviceCredentialRepository) ReadByHardwareID(hardwareID string, ctx context.Context) (*models.DeviceCredential, error) {
+	return r.scanOne(r.readByHardwareIDStmt.QueryRowContext(ctx, hardwareID))
+}
+
+func (r *DeviceCredentialRepository) scanOne(row *sql.Row) (*models.DeviceCredential, error) {
+	var credential models.DeviceCredential
+	err := row.Scan(&credential.ID, &credential.DeviceID, &credential.HardwareID, &credential.Owner, &credential.SecretHash, &credential.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &credential, nil
+}
+
+func (r *DeviceCredentialRepository) Update(credential *models.DeviceCredential, ctx context.Context) (int64, error) {
+	res, err := r.updateStmt.ExecContext(ctx, credential.DeviceID, credential.HardwareID, credential.Owner, credential.SecretHash, credential.CreatedAt, credential.ID)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return rowsAffected, nil
+}
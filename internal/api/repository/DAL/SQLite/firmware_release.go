@@ -0,0 +1,226 @@
+package SQLite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/models"
+	"log/slog"
+)
+
+type FirmwareReleaseRepository struct {
+	sqlDB *sql.DB
+	createStmt,
+	readStmt,
+	readManyStmt,
+	readByHardwareStmt,
+	updateStmt,
+	deleteStmt *instrumentedStmt
+	ctx context.Context
+}
+
+func NewFirmwareReleaseRepository(sqlDB DAL.SQLDatabase, logger *slog.Logger, ctx context.Context) (models.FirmwareReleaseRepository, error) {
+
+	repo := &FirmwareReleaseRepository{
+		sqlDB: sqlDB.Connection(),
+		ctx:   ctx,
+	}
+
+	// Create the firmware_release table if it doesn't exist
+	if _, err := repo.sqlDB.Exec(`CREATE TABLE IF NOT EXISTS firmware_release (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		version VARCHAR(20) NOT NULL,
+		hardware VARCHAR(50) NOT NULL,
+		checksum VARCHAR(64) NOT NULL,
+		size INTEGER NOT NULL,
+		rollout_percentage INTEGER NOT NULL DEFAULT 0 CHECK(rollout_percentage >= 0 AND rollout_percentage <= 100),
+		allowlist TEXT NOT NULL DEFAULT '[]',
+		file_path TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		UNIQUE(hardware, version)
+	);`); err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+
+	// Prepare SQL statements
+	createStmt, err := prepare(sqlDB, logger, `INSERT INTO firmware_release (version, hardware, checksum, size, rollout_percentage, allowlist, file_path, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.createStmt = createStmt
+
+	readStmt, err := prepare(sqlDB, logger, "SELECT id, version, hardware, checksum, size, rollout_percentage, allowlist, file_path, created_at FROM firmware_release WHERE id = ?")
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readStmt = readStmt
+
+	readManyStmt, err := prepare(sqlDB, logger, "SELECT id, version, hardware, checksum, size, rollout_percentage, allowlist, file_path, created_at FROM firmware_release ORDER BY created_at DESC LIMIT ? OFFSET ?")
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readManyStmt = readManyStmt
+
+	readByHardwareStmt, err := prepare(sqlDB, logger, "SELECT id, version, hardware, checksum, size, rollout_percentage, allowlist, file_path, created_at FROM firmware_release WHERE hardware = ?")
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readByHardwareStmt = readByHardwareStmt
+
+	updateStmt, err := prepare(sqlDB, logger, "UPDATE firmware_release SET rollout_percentage = ?, allowlist = ? WHERE id = ?")
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.updateStmt = updateStmt
+
+	deleteStmt, err := prepare(sqlDB, logger, "DELETE FROM firmware_release WHERE id = ?")
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.deleteStmt = deleteStmt
+
+	go CloseFirmwareRelease(ctx, repo)
+
+	return repo, nil
+}
+
+func CloseFirmwareRelease(ctx context.Context, r *FirmwareReleaseRepository) {
+	<-ctx.Done()
+	r.createStmt.Close()
+	r.readStmt.Close()
+	r.readManyStmt.Close()
+	r.readByHardwareStmt.Close()
+	r.updateStmt.Close()
+	r.deleteStmt.Close()
+	r.sqlDB.Close()
+}
+
+func (r *FirmwareReleaseRepository) Create(release *models.FirmwareRelease, ctx context.Context) error {
+	allowlist, err := marshalAllowlist(release.Allowlist)
+	if err != nil {
+		return err
+	}
+	res, err := r.createStmt.ExecContext(ctx, release.Version, release.Hardware, release.Checksum, release.Size, release.RolloutPercentage, allowlist, release.FilePath, release.CreatedAt)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	release.ID = int(id)
+	return nil
+}
+
+func (r *FirmwareReleaseRepository) ReadOne(id int, ctx context.Context) (*models.FirmwareRelease, error) {
+	release, err := scanFirmwareRelease(r.readStmt.QueryRowContext(ctx, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return release, err
+}
+
+func (r *FirmwareReleaseRepository) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.FirmwareRelease, error) {
+	if page < 1 {
+		page, rowsPerPage = 1, -1 // SQLite treats a negative LIMIT as no limit
+	}
+
+	offset := rowsPerPage * (page - 1)
+	rows, err := r.readManyStmt.QueryContext(ctx, rowsPerPage, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var releases []*models.FirmwareRelease
+	for rows.Next() {
+		release, err := scanFirmwareRelease(rows)
+		if err != nil {
+			return nil, err
+		}
+		releases = append(releases, release)
+	}
+	return releases, nil
+}
+
+func (r *FirmwareReleaseRepository) ReadByHardware(hardware string, ctx context.Context) ([]*models.FirmwareRelease, error) {
+	rows, err := r.readByHardwareStmt.QueryContext(ctx, hardware)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var releases []*models.FirmwareRelease
+	for rows.Next() {
+		release, err := scanFirmwareRelease(rows)
+		if err != nil {
+			return nil, err
+		}
+		releases = append(releases, release)
+	}
+	return releases, nil
+}
+
+// Update changes the rollout policy of a release, the binary itself is immutable
+func (r *FirmwareReleaseRepository) Update(release *models.FirmwareRelease, ctx context.Context) (int64, error) {
+	allowlist, err := marshalAllowlist(release.Allowlist)
+	if err != nil {
+		return 0, err
+	}
+	res, err := r.updateStmt.ExecContext(ctx, release.RolloutPercentage, allowlist, release.ID)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return rowsAffected, nil
+}
+
+func (r *FirmwareReleaseRepository) Delete(release *models.FirmwareRelease, ctx context.Context) (int64, error) {
+	res, err := r.deleteStmt.ExecContext(ctx, release.ID)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return rowsAffected, nil
+}
+
+// scanner is implemented by *sql.Row, *sql.Rows and *instrumentedRows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanFirmwareRelease(row scanner) (*models.FirmwareRelease, error) {
+	var release models.FirmwareRelease
+	var allowlist string
+	err := row.Scan(&release.ID, &release.Version, &release.Hardware, &release.Checksum, &release.Size, &release.RolloutPercentage, &allowlist, &release.FilePath, &release.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(allowlist), &release.Allowlist); err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+// The allowlist is stored as a JSON array in a TEXT column
+func marshalAllowlist(allowlist []string) (string, error) {
+	if allowlist == nil {
+		allowlist = []string{}
+	}
+	encoded, err := json.Marshal(allowlist)
+	return string(encoded), err
+}
@@ -0,0 +1,113 @@
+package SQLite
+
+import (
+	"context"
+	"database/sql"
+	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/models"
+	"log/slog"
+)
+
+type FirmwareRolloutRepository struct {
+	sqlDB *sql.DB
+	upsertStmt,
+	readByReleaseStmt,
+	readOneStmt *instrumentedStmt
+	ctx context.Context
+}
+
+func NewFirmwareRolloutRepository(sqlDB DAL.SQLDatabase, logger *slog.Logger, ctx context.Context) (models.FirmwareRolloutRepository, error) {
+
+	repo := &FirmwareRolloutRepository{
+		sqlDB: sqlDB.Connection(),
+		ctx:   ctx,
+	}
+
+	// Create the firmware_rollout table if it doesn't exist, there is one row per release and device
+	if _, err := repo.sqlDB.Exec(`CREATE TABLE IF NOT EXISTS firmware_rollout (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		release_id INTEGER NOT NULL,
+		device_id VARCHAR(50) NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		detail TEXT NOT NULL DEFAULT '',
+		updated_at TIMESTAMP NOT NULL,
+		UNIQUE(release_id, device_id)
+	);`); err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+
+	// Prepare SQL statements
+	upsertStmt, err := prepare(sqlDB, logger, `INSERT INTO firmware_rollout (release_id, device_id, status, detail, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(release_id, device_id) DO UPDATE SET status = excluded.status, detail = excluded.detail, updated_at = excluded.updated_at`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.upsertStmt = upsertStmt
+
+	readByReleaseStmt, err := prepare(sqlDB, logger, "SELECT id, release_id, device_id, status, detail, updated_at FROM firmware_rollout WHERE release_id = ? ORDER BY updated_at DESC")
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readByReleaseStmt = readByReleaseStmt
+
+	readOneStmt, err := prepare(sqlDB, logger, "SELECT id, release_id, device_id, status, detail, updated_at FROM firmware_rollout WHERE release_id = ? AND device_id = ?")
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readOneStmt = readOneStmt
+
+	go CloseFirmwareRollout(ctx, repo)
+
+	return repo, nil
+}
+
+func CloseFirmwareRollout(ctx context.Context, r *FirmwareRolloutRepository) {
+	<-ctx.Done()
+	r.upsertStmt.Close()
+	r.readByReleaseStmt.Close()
+	r.readOneStmt.Close()
+	r.sqlDB.Close()
+}
+
+// Upsert stores the latest rollout state of a release on a device
+func (r *FirmwareRolloutRepository) Upsert(rollout *models.FirmwareRollout, ctx context.Context) error {
+	_, err := r.upsertStmt.ExecContext(ctx, rollout.ReleaseID, rollout.DeviceID, rollout.Status, rollout.Detail, rollout.UpdatedAt)
+	return err
+}
+
+func (r *FirmwareRolloutRepository) ReadByRelease(releaseID int, ctx context.Context) ([]*models.FirmwareRollout, error) {
+	rows, err := r.readByReleaseStmt.QueryContext(ctx, releaseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rollouts []*models.FirmwareRollout
+	for rows.Next() {
+		var ro models.FirmwareRollout
+		err := rows.Scan(&ro.ID, &ro.ReleaseID, &ro.DeviceID, &ro.Status, &ro.Detail, &ro.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		rollouts = append(rollouts, &ro)
+	}
+	return rollouts, nil
+}
+
+// ReadOne returns the rollout state of a release on a device, or nil when it was never offered to it
+func (r *FirmwareRolloutRepository) ReadOne(releaseID int, deviceID string, ctx context.Context) (*models.FirmwareRollout, error) {
+	row := r.readOneStmt.QueryRowContext(ctx, releaseID, deviceID)
+	var ro models.FirmwareRollout
+	err := row.Scan(&ro.ID, &ro.ReleaseID, &ro.DeviceID, &ro.Status, &ro.Detail, &ro.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &ro, nil
+}
@@ -0,0 +1,157 @@
+package SQLite
+
+import (
+	"context"
+	"database/sql"
+	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/models"
+	"log/slog"
+)
+
+type IdempotencyKeyRepository struct {
+	sqlDB *sql.DB
+	createStmt,
+	readStmt,
+	completeStmt,
+	deleteStmt,
+	deleteExpiredStmt *instrumentedStmt
+	ctx context.Context
+}
+
+func NewIdempotencyKeyRepository(sqlDB DAL.SQLDatabase, logger *slog.Logger, ctx context.Context) (models.IdempotencyKeyRepository, error) {
+
+	repo := &IdempotencyKeyRepository{
+		sqlDB: sqlDB.Connection(),
+		ctx:   ctx,
+	}
+
+	// Create the idempotency_key table if it doesn't exist
+	if _, err := repo.sqlDB.Exec(`CREATE TABLE IF NOT EXISTS idempotency_key (
+		scope VARCHAR(100) NOT NULL,
+		idempotency_key VARCHAR(255) NOT NULL,
+		request_hash VARCHAR(64) NOT NULL,
+		status_code INTEGER NOT NULL DEFAULT 0,
+		content_type VARCHAR(100) NOT NULL DEFAULT '',
+		body BLOB,
+		expires_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (scope, idempotency_key)
+	);`); err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+
+	// Create index on expires_at for purging expired keys
+	if _, err := repo.sqlDB.Exec(`CREATE INDEX IF NOT EXISTS idx_idempotency_key_expires_at ON idempotency_key(expires_at);`); err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+
+	// Prepare SQL statements
+	// An expired key is replaced in the same statement, so only one of two concurrent requests can claim it
+	createStmt, err := prepare(sqlDB, logger, `INSERT INTO idempotency_key (scope, idempotency_key, request_hash, expires_at, created_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (scope, idempotency_key) DO UPDATE SET request_hash = excluded.request_hash, status_code = 0, content_type = '', body = NULL,
+		expires_at = excluded.expires_at, created_at = excluded.created_at WHERE idempotency_key.expires_at <= excluded.created_at`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.createStmt = createStmt
+
+	readStmt, err := prepare(sqlDB, logger, "SELECT scope, idempotency_key, request_hash, status_code, content_type, body, expires_at, created_at FROM idempotency_key WHERE scope = ? AND idempotency_key = ?")
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readStmt = readStmt
+
+	// Only a key still in progress can be completed, a stored response is never overwritten
+	completeStmt, err := prepare(sqlDB, logger, "UPDATE idempotency_key SET status_code = ?, content_type = ?, body = ? WHERE scope = ? AND idempotency_key = ? AND request_hash = ? AND status_code = 0")
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.completeStmt = completeStmt
+
+	deleteStmt, err := prepare(sqlDB, logger, "DELETE FROM idempotency_key WHERE scope = ? AND idempotency_key = ? AND status_code = 0")
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.deleteStmt = deleteStmt
+
+	deleteExpiredStmt, err := prepare(sqlDB, logger, "DELETE FROM idempotency_key WHERE expires_at <= ?")
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.deleteExpiredStmt = deleteExpiredStmt
+
+	go CloseIdempotencyKey(ctx, repo)
+
+	return repo, nil
+}
+
+func CloseIdempotencyKey(ctx context.Context, r *IdempotencyKeyRepository) {
+	<-ctx.Done()
+	r.createStmt.Close()
+	r.readStmt.Close()
+	r.completeStmt.Close()
+	r.deleteStmt.Close()
+	r.deleteExpiredStmt.Close()
+	r.sqlDB.Close()
+}
+
+// Create stores a key in progress. It returns false when the scope already holds the key and it has not expired yet.
+func (r *IdempotencyKeyRepository) Create(key *models.IdempotencyKey, ctx context.Context) (bool, error) {
+	res, err := r.createStmt.ExecContext(ctx, key.Scope, key.Key, key.RequestHash, key.ExpiresAt, key.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+func (r *IdempotencyKeyRepository) Read(scope string, key string, ctx context.Context) (*models.IdempotencyKey, error) {
+	row := r.readStmt.QueryRowContext(ctx, scope, key)
+	var k models.IdempotencyKey
+	err := row.Scan(&k.Scope, &k.Key, &k.RequestHash, &k.StatusCode, &k.ContentType, &k.Body, &k.ExpiresAt, &k.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &k, nil
+}
+
+// Complete stores the response of the key's request.
+// Zero rows affected means the key expired and was claimed by another request in the meantime.
+func (r *IdempotencyKeyRepository) Complete(key *models.IdempotencyKey, ctx context.Context) (int64, error) {
+	res, err := r.completeStmt.ExecContext(ctx, key.StatusCode, key.ContentType, key.Body, key.Scope, key.Key, key.RequestHash)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Delete removes a key still in progress, so the request can be retried with it
+func (r *IdempotencyKeyRepository) Delete(scope string, key string, ctx context.Context) (int64, error) {
+	res, err := r.deleteStmt.ExecContext(ctx, scope, key)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DeleteExpired removes keys that expired at or before the given RFC3339 timestamp
+func (r *IdempotencyKeyRepository) DeleteExpired(before string, ctx context.Context) (int64, error) {
+	res, err := r.deleteExpiredStmt.ExecContext(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
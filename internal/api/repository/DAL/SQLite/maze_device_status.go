@@ -5,20 +5,25 @@ import (
 	"database/sql"
 	"goapi/internal/api/repository/DAL"
 	"goapi/internal/api/repository/models"
+	"log/slog"
 )
 
 type MazeDeviceStatusRepository struct {
 	sqlDB *sql.DB
 	createStmt,
+	createNewStmt,
 	readStmt,
 	readManyStmt,
 	readByDeviceIDStmt,
+	readLatestStmt,
 	updateStmt,
-	deleteStmt *sql.Stmt
+	deleteStmt,
+	findStmt,
+	exportStmt *instrumentedStmt
 	ctx context.Context
 }
 
-func NewMazeDeviceStatusRepository(sqlDB DAL.SQLDatabase, ctx context.Context) (models.MazeDeviceStatusRepository, error) {
+func NewMazeDeviceStatusRepository(sqlDB DAL.SQLDatabase, logger *slog.Logger, ctx context.Context) (models.MazeDeviceStatusRepository, error) {
 
 	repo := &MazeDeviceStatusRepository{
 		sqlDB: sqlDB.Connection(),
@@ -33,61 +38,110 @@ func NewMazeDeviceStatusRepository(sqlDB DAL.SQLDatabase, ctx context.Context) (
 		maze_completed BOOLEAN NOT NULL,
 		hall_sensor_value BOOLEAN NOT NULL,
 		battery_level INTEGER NOT NULL CHECK(battery_level >= 0 AND battery_level <= 100),
-		timestamp TIMESTAMP NOT NULL
+		timestamp TIMESTAMP NOT NULL,
+		version INTEGER NOT NULL DEFAULT 1
 	);`); err != nil {
 		repo.sqlDB.Close()
 		return nil, err
 	}
 
+	// Tables created before optimistic concurrency lack the version column
+	if err := addColumn(repo.sqlDB, "maze_device_status", "version", "INTEGER NOT NULL DEFAULT 1"); err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+
 	// Create index on device_id for faster queries
 	if _, err := repo.sqlDB.Exec(`CREATE INDEX IF NOT EXISTS idx_maze_device_status_device_id ON maze_device_status(device_id);`); err != nil {
 		repo.sqlDB.Close()
 		return nil, err
 	}
 
+	// Batch uploads skip statuses already stored for the device and timestamp, the index makes that safe against
+	// concurrent batches. Duplicates stored before it existed fail the setup rather than being removed.
+	if err := addUniqueIndex(repo.sqlDB, "maze_device_status", "idx_maze_device_status_device_id_timestamp", "device_id", "timestamp"); err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+
 	// Prepare SQL statements
-	createStmt, err := repo.sqlDB.Prepare(`INSERT INTO maze_device_status (device_id, alarm_active, maze_completed, hall_sensor_value, battery_level, timestamp) VALUES (?, ?, ?, ?, ?, ?)`)
+	createStmt, err := prepare(sqlDB, logger, `INSERT INTO maze_device_status (device_id, alarm_active, maze_completed, hall_sensor_value, battery_level, timestamp) VALUES (?, ?, ?, ?, ?, ?)`)
 	if err != nil {
 		repo.sqlDB.Close()
 		return nil, err
 	}
 	repo.createStmt = createStmt
 
-	readStmt, err := repo.sqlDB.Prepare("SELECT id, device_id, alarm_active, maze_completed, hall_sensor_value, battery_level, timestamp FROM maze_device_status WHERE id = ?")
+	createNewStmt, err := prepare(sqlDB, logger, `INSERT INTO maze_device_status (device_id, alarm_active, maze_completed, hall_sensor_value, battery_level, timestamp) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (device_id, timestamp) DO NOTHING`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.createNewStmt = createNewStmt
+
+	readStmt, err := prepare(sqlDB, logger, "SELECT id, device_id, alarm_active, maze_completed, hall_sensor_value, battery_level, timestamp, version FROM maze_device_status WHERE id = ?")
 	if err != nil {
 		repo.sqlDB.Close()
 		return nil, err
 	}
 	repo.readStmt = readStmt
 
-	readManyStmt, err := repo.sqlDB.Prepare("SELECT id, device_id, alarm_active, maze_completed, hall_sensor_value, battery_level, timestamp FROM maze_device_status LIMIT ? OFFSET ?")
+	readManyStmt, err := prepare(sqlDB, logger, "SELECT id, device_id, alarm_active, maze_completed, hall_sensor_value, battery_level, timestamp, version FROM maze_device_status LIMIT ? OFFSET ?")
 	if err != nil {
 		repo.sqlDB.Close()
 		return nil, err
 	}
 	repo.readManyStmt = readManyStmt
 
-	readByDeviceIDStmt, err := repo.sqlDB.Prepare("SELECT id, device_id, alarm_active, maze_completed, hall_sensor_value, battery_level, timestamp FROM maze_device_status WHERE device_id = ? ORDER BY timestamp DESC")
+	readByDeviceIDStmt, err := prepare(sqlDB, logger, "SELECT id, device_id, alarm_active, maze_completed, hall_sensor_value, battery_level, timestamp, version FROM maze_device_status WHERE device_id = ? ORDER BY timestamp DESC")
 	if err != nil {
 		repo.sqlDB.Close()
 		return nil, err
 	}
 	repo.readByDeviceIDStmt = readByDeviceIDStmt
 
-	updateStmt, err := repo.sqlDB.Prepare("UPDATE maze_device_status SET device_id = ?, alarm_active = ?, maze_completed = ?, hall_sensor_value = ?, battery_level = ?, timestamp = ? WHERE id = ?")
+	// SQLite takes the bare columns of an aggregate query from the row holding the MAX()
+	readLatestStmt, err := prepare(sqlDB, logger, "SELECT id, device_id, alarm_active, maze_completed, hall_sensor_value, battery_level, MAX(timestamp), version FROM maze_device_status GROUP BY device_id")
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readLatestStmt = readLatestStmt
+
+	updateStmt, err := prepare(sqlDB, logger, "UPDATE maze_device_status SET device_id = ?, alarm_active = ?, maze_completed = ?, hall_sensor_value = ?, battery_level = ?, timestamp = ?, version = version + 1 WHERE id = ? AND (? = 0 OR version = ?) RETURNING version")
 	if err != nil {
 		repo.sqlDB.Close()
 		return nil, err
 	}
 	repo.updateStmt = updateStmt
 
-	deleteStmt, err := repo.sqlDB.Prepare("DELETE FROM maze_device_status WHERE id = ?")
+	deleteStmt, err := prepare(sqlDB, logger, "DELETE FROM maze_device_status WHERE id = ? AND (? = 0 OR version = ?)")
 	if err != nil {
 		repo.sqlDB.Close()
 		return nil, err
 	}
 	repo.deleteStmt = deleteStmt
 
+	findStmt, err := prepare(sqlDB, logger, "SELECT id, version FROM maze_device_status WHERE device_id = ? AND timestamp = ? LIMIT 1")
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.findStmt = findStmt
+
+	// Empty filter values match every row, datetime() compares timestamps written with different offsets
+	exportStmt, err := prepare(sqlDB, logger, `SELECT id, device_id, alarm_active, maze_completed, hall_sensor_value, battery_level, timestamp, version FROM maze_device_status
+		WHERE (?1 = '' OR device_id = ?1)
+		AND (?2 = '' OR datetime(timestamp) >= datetime(?2))
+		AND (?3 = '' OR datetime(timestamp) < datetime(?3))
+		ORDER BY id`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.exportStmt = exportStmt
+
 	go CloseMazeDeviceStatus(ctx, repo)
 
 	return repo, nil
@@ -96,11 +150,15 @@ func NewMazeDeviceStatusRepository(sqlDB DAL.SQLDatabase, ctx context.Context) (
 func CloseMazeDeviceStatus(ctx context.Context, r *MazeDeviceStatusRepository) {
 	<-ctx.Done()
 	r.createStmt.Close()
+	r.createNewStmt.Close()
 	r.readStmt.Close()
 	r.updateStmt.Close()
 	r.deleteStmt.Close()
 	r.readManyStmt.Close()
 	r.readByDeviceIDStmt.Close()
+	r.readLatestStmt.Close()
+	r.findStmt.Close()
+	r.exportStmt.Close()
 	r.sqlDB.Close()
 }
 
@@ -114,13 +172,74 @@ func (r *MazeDeviceStatusRepository) Create(status *models.MazeDeviceStatus, ctx
 		return err
 	}
 	status.ID = int(id)
+	status.Version = 1
 	return nil
 }
 
+// CreateMany inserts the statuses in one transaction, skipping statuses whose device_id and timestamp are already stored.
+// created reports which statuses were inserted, skipped statuses get the ID of the stored status.
+func (r *MazeDeviceStatusRepository) CreateMany(statuses []*models.MazeDeviceStatus, ctx context.Context) ([]bool, error) {
+	return r.createMany(statuses, true, ctx)
+}
+
+// CheckMany reports which statuses CreateMany would insert without storing any, the transaction is rolled back.
+// Only skipped statuses get an ID.
+func (r *MazeDeviceStatusRepository) CheckMany(statuses []*models.MazeDeviceStatus, ctx context.Context) ([]bool, error) {
+	return r.createMany(statuses, false, ctx)
+}
+
+func (r *MazeDeviceStatusRepository) createMany(statuses []*models.MazeDeviceStatus, commit bool, ctx context.Context) ([]bool, error) {
+	tx, err := r.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	createStmt := r.createNewStmt.inTx(ctx, tx)
+	findStmt := r.findStmt.inTx(ctx, tx)
+
+	created := make([]bool, len(statuses))
+	for i, status := range statuses {
+		// The unique index skips duplicates, also those inserted earlier in the same batch or by a concurrent one
+		res, err := createStmt.ExecContext(ctx, status.DeviceID, status.AlarmActive, status.MazeCompleted, status.HallSensorValue, status.BatteryLevel, status.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+		inserted, err := res.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if inserted == 0 {
+			if err := findStmt.QueryRowContext(ctx, status.DeviceID, status.Timestamp).Scan(&status.ID, &status.Version); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		created[i] = true
+		if !commit {
+			continue
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		status.ID = int(id)
+		status.Version = 1
+	}
+
+	if !commit {
+		return created, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
 func (r *MazeDeviceStatusRepository) ReadOne(id int, ctx context.Context) (*models.MazeDeviceStatus, error) {
 	row := r.readStmt.QueryRowContext(ctx, id)
 	var status models.MazeDeviceStatus
-	err := row.Scan(&status.ID, &status.DeviceID, &status.AlarmActive, &status.MazeCompleted, &status.HallSensorValue, &status.BatteryLevel, &status.Timestamp)
+	err := row.Scan(&status.ID, &status.DeviceID, &status.AlarmActive, &status.MazeCompleted, &status.HallSensorValue, &status.BatteryLevel, &status.Timestamp, &status.Version)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -130,51 +249,54 @@ func (r *MazeDeviceStatusRepository) ReadOne(id int, ctx context.Context) (*mode
 	return &status, nil
 }
 
-func (r *MazeDeviceStatusRepository) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.MazeDeviceStatus, error) {
+// ReadMany returns a page of statuses, all of them when page is below 1. They are read as the sequence is ranged over.
+func (r *MazeDeviceStatusRepository) ReadMany(page int, rowsPerPage int, ctx context.Context) models.Rows[models.MazeDeviceStatus] {
 	if page < 1 {
 		return r.ReadAll(ctx)
 	}
 
 	offset := rowsPerPage * (page - 1)
-	rows, err := r.readManyStmt.QueryContext(ctx, rowsPerPage, offset)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+	return queryRows(func() (cursor, error) {
+		return r.readManyStmt.QueryContext(ctx, rowsPerPage, offset)
+	}, scanStatus)
+}
 
-	var statuses []*models.MazeDeviceStatus
-	for rows.Next() {
-		var s models.MazeDeviceStatus
-		err := rows.Scan(&s.ID, &s.DeviceID, &s.AlarmActive, &s.MazeCompleted, &s.HallSensorValue, &s.BatteryLevel, &s.Timestamp)
-		if err != nil {
-			return nil, err
-		}
-		statuses = append(statuses, &s)
-	}
-	return statuses, nil
+// scanStatus scans a row holding every column of maze_device_status
+func scanStatus(rows scanner, s *models.MazeDeviceStatus) error {
+	return rows.Scan(&s.ID, &s.DeviceID, &s.AlarmActive, &s.MazeCompleted, &s.HallSensorValue, &s.BatteryLevel, &s.Timestamp, &s.Version)
 }
 
-func (r *MazeDeviceStatusRepository) ReadByDeviceID(deviceID string, ctx context.Context) ([]*models.MazeDeviceStatus, error) {
-	rows, err := r.readByDeviceIDStmt.QueryContext(ctx, deviceID)
+// Export calls each for every status matching filter in the order they were stored, rows are read one at a time
+// so large tables are never held in memory. An error returned by each stops the export.
+func (r *MazeDeviceStatusRepository) Export(filter *models.ExportFilter, each func(status *models.MazeDeviceStatus) error, ctx context.Context) error {
+	rows, err := r.exportStmt.QueryContext(ctx, filter.DeviceID, filter.Since, filter.Until)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	var statuses []*models.MazeDeviceStatus
 	for rows.Next() {
 		var s models.MazeDeviceStatus
-		err := rows.Scan(&s.ID, &s.DeviceID, &s.AlarmActive, &s.MazeCompleted, &s.HallSensorValue, &s.BatteryLevel, &s.Timestamp)
+		err := rows.Scan(&s.ID, &s.DeviceID, &s.AlarmActive, &s.MazeCompleted, &s.HallSensorValue, &s.BatteryLevel, &s.Timestamp, &s.Version)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := each(&s); err != nil {
+			return err
 		}
-		statuses = append(statuses, &s)
 	}
-	return statuses, nil
+	return rows.Err()
+}
+
+func (r *MazeDeviceStatusRepository) ReadByDeviceID(deviceID string, ctx context.Context) models.Rows[models.MazeDeviceStatus] {
+	return queryRows(func() (cursor, error) {
+		return r.readByDeviceIDStmt.QueryContext(ctx, deviceID)
+	}, scanStatus)
 }
 
-func (r *MazeDeviceStatusRepository) ReadAll(ctx context.Context) ([]*models.MazeDeviceStatus, error) {
-	rows, err := r.sqlDB.QueryContext(ctx, "SELECT id, device_id, alarm_active, maze_completed, hall_sensor_value, battery_level, timestamp FROM maze_device_status ORDER BY timestamp DESC")
+// ReadLatest returns the most recent status of every device
+func (r *MazeDeviceStatusRepository) ReadLatest(ctx context.Context) ([]*models.MazeDeviceStatus, error) {
+	rows, err := r.readLatestStmt.QueryContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -183,7 +305,7 @@ func (r *MazeDeviceStatusRepository) ReadAll(ctx context.Context) ([]*models.Maz
 	var statuses []*models.MazeDeviceStatus
 	for rows.Next() {
 		var s models.MazeDeviceStatus
-		err := rows.Scan(&s.ID, &s.DeviceID, &s.AlarmActive, &s.MazeCompleted, &s.HallSensorValue, &s.BatteryLevel, &s.Timestamp)
+		err := rows.Scan(&s.ID, &s.DeviceID, &s.AlarmActive, &s.MazeCompleted, &s.HallSensorValue, &s.BatteryLevel, &s.Timestamp, &s.Version)
 		if err != nil {
 			return nil, err
 		}
@@ -192,20 +314,25 @@ func (r *MazeDeviceStatusRepository) ReadAll(ctx context.Context) ([]*models.Maz
 	return statuses, nil
 }
 
+func (r *MazeDeviceStatusRepository) ReadAll(ctx context.Context) models.Rows[models.MazeDeviceStatus] {
+	return queryRows(func() (cursor, error) {
+		return r.sqlDB.QueryContext(ctx, "SELECT id, device_id, alarm_active, maze_completed, hall_sensor_value, battery_level, timestamp, version FROM maze_device_status ORDER BY timestamp DESC")
+	}, scanStatus)
+}
+
 func (r *MazeDeviceStatusRepository) Update(status *models.MazeDeviceStatus, ctx context.Context) (int64, error) {
-	res, err := r.updateStmt.ExecContext(ctx, status.DeviceID, status.AlarmActive, status.MazeCompleted, status.HallSensorValue, status.BatteryLevel, status.Timestamp, status.ID)
-	if err != nil {
-		return 0, err
-	}
-	rowsAffected, err := res.RowsAffected()
-	if err != nil {
-		return 0, err
+	row := r.updateStmt.QueryRowContext(ctx, status.DeviceID, status.AlarmActive, status.MazeCompleted, status.HallSensorValue, status.BatteryLevel, status.Timestamp, status.ID, status.Version, status.Version)
+	if err := row.Scan(&status.Version); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, uniqueViolation(err)
 	}
-	return rowsAffected, nil
+	return 1, nil
 }
 
 func (r *MazeDeviceStatusRepository) Delete(status *models.MazeDeviceStatus, ctx context.Context) (int64, error) {
-	res, err := r.deleteStmt.ExecContext(ctx, status.ID)
+	res, err := r.deleteStmt.ExecContext(ctx, status.ID, status.Version, status.Version)
 	if err != nil {
 		return 0, err
 	}
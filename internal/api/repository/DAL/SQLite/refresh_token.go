@@ -0,0 +1,160 @@
+package SQLite
+
+import (
+	"context"
+	"database/sql"
+	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/models"
+	"log/slog"
+)
+
+type RefreshTokenRepository struct {
+	sqlDB *sql.DB
+	createStmt,
+	readByHashStmt,
+	revokeStmt,
+	revokeByUsernameStmt,
+	deleteExpiredStmt *instrumentedStmt
+	ctx context.Context
+}
+
+func NewRefreshTokenRepository(sqlDB DAL.SQLDatabase, logger *slog.Logger, ctx context.Context) (models.RefreshTokenRepository, error) {
+
+	repo := &RefreshTokenRepository{
+		sqlDB: sqlDB.Connection(),
+		ctx:   ctx,
+	}
+
+	// Create the refresh_token table if it doesn't exist
+	if _, err := repo.sqlDB.Exec(`CREATE TABLE IF NOT EXISTS refresh_token (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		token_hash VARCHAR(64) NOT NULL UNIQUE,
+		username VARCHAR(50) NOT NULL,
+		device_id VARCHAR(50) NOT NULL DEFAULT '',
+		credential VARCHAR(64) NOT NULL DEFAULT '',
+		expires_at TIMESTAMP NOT NULL,
+		revoked_at TEXT NOT NULL DEFAULT '', -- TEXT, the driver would scan an empty TIMESTAMP as the zero time
+		replaced_by INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP NOT NULL
+	);`); err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+
+	// Tables created before refreshing checked the credentials lack the credential column, their tokens cannot be refreshed
+	if err := addColumn(repo.sqlDB, "refresh_token", "credential", "VARCHAR(64) NOT NULL DEFAULT ''"); err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+
+	// Create index on username for revoking all tokens of an account
+	if _, err := repo.sqlDB.Exec(`CREATE INDEX IF NOT EXISTS idx_refresh_token_username ON refresh_token(username);`); err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+
+	// Prepare SQL statements
+	createStmt, err := prepare(sqlDB, logger, `INSERT INTO refresh_token (token_hash, username, device_id, credential, expires_at, created_at) VALUES (?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.createStmt = createStmt
+
+	readByHashStmt, err := prepare(sqlDB, logger, "SELECT id, token_hash, username, device_id, credential, expires_at, revoked_at, replaced_by, created_at FROM refresh_token WHERE token_hash = ?")
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.readByHashStmt = readByHashStmt
+
+	// The WHERE clause makes rotation atomic: only one caller can revoke a valid token
+	revokeStmt, err := prepare(sqlDB, logger, "UPDATE refresh_token SET revoked_at = ?, replaced_by = ? WHERE id = ? AND revoked_at = ''")
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.revokeStmt = revokeStmt
+
+	revokeByUsernameStmt, err := prepare(sqlDB, logger, "UPDATE refresh_token SET revoked_at = ? WHERE username = ? AND revoked_at = ''")
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.revokeByUsernameStmt = revokeByUsernameStmt
+
+	deleteExpiredStmt, err := prepare(sqlDB, logger, "DELETE FROM refresh_token WHERE expires_at <= ?")
+	if err != nil {
+		repo.sqlDB.Close()
+		return nil, err
+	}
+	repo.deleteExpiredStmt = deleteExpiredStmt
+
+	go CloseRefreshToken(ctx, repo)
+
+	return repo, nil
+}
+
+func CloseRefreshToken(ctx context.Context, r *RefreshTokenRepository) {
+	<-ctx.Done()
+	r.createStmt.Close()
+	r.readByHashStmt.Close()
+	r.revokeStmt.Close()
+	r.revokeByUsernameStmt.Close()
+	r.deleteExpiredStmt.Close()
+	r.sqlDB.Close()
+}
+
+func (r *RefreshTokenRepository) Create(token *models.RefreshToken, ctx context.Context) error {
+	res, err := r.createStmt.ExecContext(ctx, token.TokenHash, token.Username, token.DeviceID, token.Credential, token.ExpiresAt, token.CreatedAt)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	token.ID = int(id)
+	return nil
+}
+
+func (r *RefreshTokenRepository) ReadByHash(tokenHash string, ctx context.Context) (*models.RefreshToken, error) {
+	row := r.readByHashStmt.QueryRowContext(ctx, tokenHash)
+	var token models.RefreshToken
+	err := row.Scan(&token.ID, &token.TokenHash, &token.Username, &token.DeviceID, &token.Credential, &token.ExpiresAt, &token.RevokedAt, &token.ReplacedBy, &token.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Revoke marks the token as revoked at token.RevokedAt and replaced by token.ReplacedBy.
+// Zero rows affected means the token does not exist or was already revoked.
+func (r *RefreshTokenRepository) Revoke(token *models.RefreshToken, ctx context.Context) (int64, error) {
+	res, err := r.revokeStmt.ExecContext(ctx, token.RevokedAt, token.ReplacedBy, token.ID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// RevokeByUsername revokes every valid token issued to username
+func (r *RefreshTokenRepository) RevokeByUsername(username string, revokedAt string, ctx context.Context) (int64, error) {
+	res, err := r.revokeByUsernameStmt.ExecContext(ctx, revokedAt, username)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DeleteExpired removes tokens that expired at or before the given RFC3339 timestamp, revoked or not
+func (r *RefreshTokenRepository) DeleteExpired(before string, ctx context.Context) (int64, error) {
+	res, err := r.deleteExpiredStmt.ExecContext(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
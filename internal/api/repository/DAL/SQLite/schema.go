@@ -0,0 +1,114 @@
+package SQLite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"goapi/internal/api/repository/DAL"
+	"strings"
+)
+
+// Tables are created by the repository constructors, see the CREATE TABLE IF NOT EXISTS statements
+var expectedTables = []string{
+	"data",
+	"maze_device_status",
+	"device_config",
+	"claim_code",
+	"device_credential",
+	"firmware_release",
+	"firmware_rollout",
+	"auth_lockout",
+	"refresh_token",
+	"audit_log",
+	"idempotency_key",
+}
+
+// CheckSchema reports an error naming the tables that have not been created, e.g. because a repository failed to set up
+func CheckSchema(ctx context.Context, sqlDB DAL.SQLDatabase) error {
+	rows, err := sqlDB.Connection().QueryContext(ctx, `SELECT name FROM sqlite_master WHERE type = 'table'`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		existing[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, table := range expectedTables {
+		if !existing[table] {
+			missing = append(missing, table)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing tables: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// addColumn adds a column to a table created before the column existed,
+// CREATE TABLE IF NOT EXISTS leaves existing tables as they are
+func addColumn(sqlDB *sql.DB, table string, column string, definition string) error {
+	var count int
+	err := sqlDB.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&count)
+	if err != nil || count > 0 {
+		return err
+	}
+	_, err = sqlDB.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// addUniqueIndex creates a unique index on a table that may have been written before the index existed.
+// Duplicates of its key are never removed here, they fail the setup naming the rows to resolve by hand.
+// Rows with a NULL key column are ignored, they never conflict.
+func addUniqueIndex(sqlDB *sql.DB, table string, index string, columns ...string) error {
+	var count int
+	err := sqlDB.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = ?`, index).Scan(&count)
+	if err != nil || count > 0 {
+		return err
+	}
+
+	key := strings.Join(columns, ", ")
+	rows, err := sqlDB.Query(fmt.Sprintf("SELECT %s, group_concat(id, ', ') FROM %s WHERE %s IS NOT NULL GROUP BY %s HAVING COUNT(*) > 1 LIMIT 10",
+		key, table, strings.Join(columns, " IS NOT NULL AND "), key))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var duplicates []string
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns)+1)
+		dest := make([]any, len(values))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		fields := make([]string, len(columns))
+		for i, column := range columns {
+			fields[i] = column + " " + values[i].String
+		}
+		duplicates = append(duplicates, strings.Join(fields, ", ")+" (ids "+values[len(columns)].String+")")
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(duplicates) > 0 {
+		return fmt.Errorf("%s holds rows with the same %s, remove or change all but one of each before starting: %s",
+			table, key, strings.Join(duplicates, "; "))
+	}
+
+	_, err = sqlDB.Exec(fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s(%s)", index, table, key))
+	return err
+}
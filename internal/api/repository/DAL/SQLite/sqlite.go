@@ -11,9 +11,17 @@ import (
 type SQLite struct {
 	sqlDB          *sql.DB
 	dataSourceName string
+	observer       DAL.QueryObserver
 }
 
-func NewSqlite(dataSourceName string) (DAL.SQLDatabase, error) {
+// PoolConfig sizes the connection pool of the database
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+func NewSqlite(dataSourceName string, pool PoolConfig) (DAL.SQLDatabase, error) {
 
 	sqlDB, err := sql.Open("sqlite3", dataSourceName)
 	if err != nil {
@@ -21,9 +29,9 @@ func NewSqlite(dataSourceName string) (DAL.SQLDatabase, error) {
 	}
 
 	// Set connection pool settings
-	sqlDB.SetMaxOpenConns(10)
-	sqlDB.SetMaxIdleConns(5)
-	sqlDB.SetConnMaxLifetime(5 * time.Minute)
+	sqlDB.SetMaxOpenConns(pool.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(pool.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(pool.ConnMaxLifetime)
 
 	Sqlite := &SQLite{
 		sqlDB:          sqlDB,
@@ -40,3 +48,12 @@ func (s *SQLite) Connection() *sql.DB {
 func (s *SQLite) Close() error {
 	return s.sqlDB.Close()
 }
+
+// SetQueryObserver registers an observer for the statements of repositories created afterwards
+func (s *SQLite) SetQueryObserver(observer DAL.QueryObserver) {
+	s.observer = observer
+}
+
+func (s *SQLite) QueryObserver() DAL.QueryObserver {
+	return s.observer
+}
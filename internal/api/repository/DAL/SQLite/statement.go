@@ -0,0 +1,203 @@
+package SQLite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"goapi/internal/api/repository/DAL"
+	"goapi/internal/api/repository/models"
+	"log/slog"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("goapi/internal/api/repository/DAL/SQLite")
+
+// instrumentedStmt is a prepared statement that traces, logs and observes its executions.
+// Failures are logged at error level, successful executions at debug level, both with the request ID of the calling context.
+type instrumentedStmt struct {
+	*sql.Stmt
+	query    string
+	logger   *slog.Logger
+	observer DAL.QueryObserver
+}
+
+func prepare(sqlDB DAL.SQLDatabase, logger *slog.Logger, query string) (*instrumentedStmt, error) {
+	stmt, err := sqlDB.Connection().Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedStmt{Stmt: stmt, query: query, logger: logger, observer: sqlDB.QueryObserver()}, nil
+}
+
+// inTx returns the statement bound to a transaction, still traced and observed like s
+func (s *instrumentedStmt) inTx(ctx context.Context, tx *sql.Tx) *instrumentedStmt {
+	return &instrumentedStmt{Stmt: tx.StmtContext(ctx, s.Stmt), query: s.query, logger: s.logger, observer: s.observer}
+}
+
+func (s *instrumentedStmt) ExecContext(ctx context.Context, args ...any) (sql.Result, error) {
+	exec := s.start(ctx)
+	res, err := s.Stmt.ExecContext(exec.ctx, args...)
+	s.finish(exec, err)
+	return res, uniqueViolation(err)
+}
+
+// uniqueViolation turns a unique constraint failure into a *models.DuplicateError, other errors are returned as is.
+// Statements that write through QueryRowContext only fail on Scan, they pass that error here themselves.
+func uniqueViolation(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return &models.DuplicateError{Err: err}
+	}
+	return err
+}
+
+// QueryContext runs the query, its span and latency cover reading the rows and end when they are closed
+func (s *instrumentedStmt) QueryContext(ctx context.Context, args ...any) (*instrumentedRows, error) {
+	exec := s.start(ctx)
+	rows, err := s.Stmt.QueryContext(exec.ctx, args...)
+	if err != nil {
+		s.finish(exec, err)
+		return nil, err
+	}
+	return &instrumentedRows{Rows: rows, stmt: s, exec: exec}, nil
+}
+
+// instrumentedRows are the rows of an instrumented query, closing them finishes its execution with the error that
+// stopped reading them, if any
+type instrumentedRows struct {
+	*sql.Rows
+	stmt *instrumentedStmt
+	exec *execution
+}
+
+func (r *instrumentedRows) Close() error {
+	if r.exec == nil {
+		return r.Rows.Close()
+	}
+	err := r.Rows.Err()
+	closeErr := r.Rows.Close()
+	if err == nil {
+		err = closeErr
+	}
+	r.stmt.finish(r.exec, err)
+	r.exec = nil
+	return closeErr
+}
+
+// cursor is implemented by both *sql.Rows and *instrumentedRows
+type cursor interface {
+	scanner
+	Next() bool
+	Err() error
+	Close() error
+}
+
+func (s *instrumentedStmt) QueryRowContext(ctx context.Context, args ...any) *sql.Row {
+	exec := s.start(ctx)
+	row := s.Stmt.QueryRowContext(exec.ctx, args...)
+	s.finish(exec, row.Err())
+	return row
+}
+
+// execution is a statement execution in progress
+type execution struct {
+	ctx        context.Context
+	span       trace.Span
+	pc         uintptr // Repository method that executed the statement
+	repository string
+	method     string
+	start      time.Time
+}
+
+func (s *instrumentedStmt) start(ctx context.Context) *execution {
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:]) // Skip runtime.Callers, start and the Exec/Query wrapper
+	frame, _ := runtime.CallersFrames(pcs[:]).Next()
+	repository, method := splitMethodName(frame.Function)
+
+	ctx, span := tracer.Start(ctx, repository+"."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "sqlite"),
+			attribute.String("db.statement", s.query),
+		),
+	)
+	return &execution{ctx: ctx, span: span, pc: pcs[0], repository: repository, method: method, start: time.Now()}
+}
+
+// finish reports the statement against the repository method that executed it rather than against this file
+func (s *instrumentedStmt) finish(exec *execution, err error) {
+	duration := time.Since(exec.start)
+	if err != nil {
+		exec.span.RecordError(err)
+		exec.span.SetStatus(codes.Error, err.Error())
+	}
+	exec.span.End()
+
+	if s.observer != nil {
+		s.observer(exec.repository, exec.method, duration, err)
+	}
+
+	level, msg := slog.LevelDebug, "Query executed"
+	if err != nil {
+		level, msg = slog.LevelError, "Query failed"
+	}
+	if !s.logger.Enabled(exec.ctx, level) {
+		return
+	}
+
+	record := slog.NewRecord(time.Now(), level, msg, exec.pc)
+	record.AddAttrs(slog.String("query", s.query), slog.Float64("latency_ms", float64(duration.Microseconds())/1000))
+	if err != nil {
+		record.AddAttrs(slog.String("error", err.Error()))
+	}
+	s.logger.Handler().Handle(exec.ctx, record)
+}
+
+// splitMethodName turns "goapi/.../SQLite.(*DataRepository).ReadOne" into "DataRepository" and "ReadOne".
+// Closures like the sequences returned by ReadMany are reported against the method that returned them.
+func splitMethodName(function string) (string, string) {
+	function = function[strings.LastIndex(function, "/")+1:]
+	_, function, _ = strings.Cut(function, ".")
+	repository, method, found := strings.Cut(function, ".")
+	if !found {
+		return "", repository
+	}
+	method, _, _ = strings.Cut(method, ".")
+	return strings.Trim(repository, "(*)"), method
+}
+
+// queryRows returns the rows of a query as a sequence. The query runs when the sequence is ranged over and rows are
+// scanned one at a time, breaking out of the loop closes the cursor.
+func queryRows[T any](query func() (cursor, error), scan func(rows scanner, row *T) error) models.Rows[T] {
+	return func(yield func(*T, error) bool) {
+		rows, err := query()
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			row := new(T)
+			if err := scan(rows, row); err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(row, nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
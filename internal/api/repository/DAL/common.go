@@ -2,9 +2,16 @@ package DAL
 
 import (
 	"database/sql"
+	"time"
 )
 
 type SQLDatabase interface {
 	Connection() *sql.DB
 	Close() error
+	QueryObserver() QueryObserver
+	SetQueryObserver(observer QueryObserver)
 }
+
+// QueryObserver is notified after every statement a repository executes, e.g. to record query metrics.
+// repository and method name the repository method that ran the statement, e.g. "DataRepository" and "ReadOne".
+type QueryObserver func(repository string, method string, duration time.Duration, err error)
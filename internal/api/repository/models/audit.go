@@ -0,0 +1,43 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Audited actions
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// AuditEntry records who changed a resource and how. Entries are append-only.
+type AuditEntry struct {
+	ID           int             `json:"id"`
+	Actor        string          `json:"actor"`         // Username of the caller, the device ID for devices
+	Action       string          `json:"action"`        // create, update or delete
+	ResourceType string          `json:"resource_type"` // e.g. device_config
+	ResourceID   string          `json:"resource_id"`
+	Before       json.RawMessage `json:"before"`  // Resource before the change, null when created
+	After        json.RawMessage `json:"after"`   // Resource after the change, null when deleted
+	Changes      json.RawMessage `json:"changes"` // Changed fields, each with its before and after value
+	RequestID    string          `json:"request_id,omitempty"`
+	CreatedAt    string          `json:"created_at"` // Timestamp in RFC3339 format
+}
+
+// AuditFilter narrows down audit entries, empty fields match everything
+type AuditFilter struct {
+	Actor        string
+	Action       string
+	ResourceType string
+	ResourceID   string
+	Since        string // Inclusive RFC3339 timestamp
+	Until        string // Exclusive RFC3339 timestamp
+}
+
+// AuditRepository defines the interface for audit log database operations, entries can only be appended
+type AuditRepository interface {
+	Create(entry *AuditEntry, ctx context.Context) error
+	ReadMany(filter *AuditFilter, page int, rowsPerPage int, ctx context.Context) ([]*AuditEntry, error)
+}
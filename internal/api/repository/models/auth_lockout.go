@@ -0,0 +1,26 @@
+package models
+
+import "context"
+
+// Subjects of a lockout
+const (
+	LockoutUsername = "username"
+	LockoutIP       = "ip"
+)
+
+// AuthLockout records that a username or source IP was locked out after repeated failed logins
+type AuthLockout struct {
+	ID          int    `json:"id"`
+	Subject     string `json:"subject"`      // Either username or ip
+	Value       string `json:"value"`        // The locked username or IP address
+	Failures    int    `json:"failures"`     // Failed logins that led to the lockout
+	RemoteAddr  string `json:"remote_addr"`  // Source IP of the last failed login
+	LockedAt    string `json:"locked_at"`    // Lockout timestamp in RFC3339 format
+	LockedUntil string `json:"locked_until"` // End of the lockout in RFC3339 format
+}
+
+// AuthLockoutRepository defines the interface for lockout database operations, lockouts are only ever appended
+type AuthLockoutRepository interface {
+	Create(lockout *AuthLockout, ctx context.Context) error
+	ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*AuthLockout, error)
+}
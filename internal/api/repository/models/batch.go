@@ -0,0 +1,22 @@
+package models
+
+// MaxBatchSize is the largest number of items accepted in one batch upload
+const MaxBatchSize = 1000
+
+// Outcomes of an item in a batch upload
+const (
+	BatchAccepted  = "accepted"
+	BatchDuplicate = "duplicate"
+	BatchRejected  = "rejected"
+)
+
+// BatchResult reports what happened to one item of a batch upload.
+// Index is the position of the item in the batch, ID the stored item, also for duplicates.
+// Rejected items name their invalid fields in Errors.
+type BatchResult struct {
+	Index  int         `json:"index"`
+	Status string      `json:"status"`
+	ID     int         `json:"id,omitempty"`
+	Error  string      `json:"error,omitempty"`
+	Errors FieldErrors `json:"errors,omitempty"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "context"
+
+// ClaimCode is a short-lived, single-use code an admin generates so a new device can provision itself
+type ClaimCode struct {
+	ID         int    `json:"id"`
+	Code       string `json:"code"`
+	IssuedBy   string `json:"issued_by"`             // Username of the account the claimed device is bound to
+	DeviceID   string `json:"device_id,omitempty"`   // Device ID to assign, derived from the hardware ID when empty
+	HardwareID string `json:"hardware_id,omitempty"` // Hardware ID of the device that redeemed the code
+	ExpiresAt  string `json:"expires_at"`            // Expiry timestamp in RFC3339 format
+	UsedAt     string `json:"used_at,omitempty"`     // Redemption timestamp in RFC3339 format, empty while unused
+	CreatedAt  string `json:"created_at"`            // Creation timestamp in RFC3339 format
+}
+
+// ClaimCodeRepository defines the interface for claim code database operations
+type ClaimCodeRepository interface {
+	Create(claim *ClaimCode, ctx context.Context) error
+	ReadByCode(code string, ctx context.Context) (*ClaimCode, error)
+	ReadByIssuer(issuedBy string, ctx context.Context) ([]*ClaimCode, error)
+	Redeem(claim *ClaimCode, credential *DeviceCredential, ctx context.Context) (int64, error)
+	Delete(claim *ClaimCode, ctx context.Context) (int64, error)
+	DeleteExpired(before string, ctx context.Context) (int64, error)
+}
@@ -10,12 +10,16 @@ type Data struct {
 	Type        string  `json:"type"`
 	DateTime    string  `json:"date_time"`
 	Description string  `json:"description"`
+	Version     int     `json:"version,omitempty"` // Incremented on every update, sent as the ETag
 }
 
 type DataRepository interface {
 	Create(Data *Data, ctx context.Context) error
+	CreateMany(data []*Data, ctx context.Context) ([]bool, error)
+	CheckMany(data []*Data, ctx context.Context) ([]bool, error)
 	ReadOne(id int, ctx context.Context) (*Data, error)
-	ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*Data, error)
+	ReadMany(page int, rowsPerPage int, ctx context.Context) Rows[Data]
+	Export(filter *ExportFilter, each func(data *Data) error, ctx context.Context) error
 	Update(data *Data, ctx context.Context) (int64, error)
 	Delete(data *Data, ctx context.Context) (int64, error)
 }
@@ -9,14 +9,17 @@ type DeviceConfig struct {
 	AlarmTimeout     int    `json:"alarm_timeout"`     // Alarm timeout in seconds
 	SensitivityLevel int    `json:"sensitivity_level"` // Hall sensor sensitivity level (1-10)
 	UpdatedAt        string `json:"updated_at"`        // Last update timestamp in RFC3339 format
+	Version          int    `json:"version,omitempty"` // Incremented on every update, sent as the ETag
 }
 
 // DeviceConfigRepository defines the interface for device config database operations
 type DeviceConfigRepository interface {
 	Create(config *DeviceConfig, ctx context.Context) error
+	CreateMany(configs []*DeviceConfig, ctx context.Context) ([]bool, error)
+	CheckMany(configs []*DeviceConfig, ctx context.Context) ([]bool, error)
 	ReadOne(id int, ctx context.Context) (*DeviceConfig, error)
 	ReadByDeviceID(deviceID string, ctx context.Context) (*DeviceConfig, error)
-	ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*DeviceConfig, error)
+	ReadMany(page int, rowsPerPage int, ctx context.Context) Rows[DeviceConfig]
 	Update(config *DeviceConfig, ctx context.Context) (int64, error)
 	Delete(config *DeviceConfig, ctx context.Context) (int64, error)
 }
@@ -0,0 +1,21 @@
+package models
+
+import "context"
+
+// DeviceCredential holds the Basic authentication credentials issued to a provisioned device
+type DeviceCredential struct {
+	ID         int    `json:"id"`
+	DeviceID   string `json:"device_id"`   // Device ID, also used as the Basic auth username
+	HardwareID string `json:"hardware_id"` // Hardware identifier reported by the device
+	Owner      string `json:"owner"`       // Username of the account the device is bound to
+	SecretHash string `json:"-"`           // SHA-256 hash of the issued password, hex encoded
+	CreatedAt  string `json:"created_at"`  // Provisioning timestamp in RFC3339 format
+}
+
+// DeviceCredentialRepository defines the interface for device credential database operations
+type DeviceCredentialRepository interface {
+	Create(credential *DeviceCredential, ctx context.Context) error
+	ReadByDeviceID(deviceID string, ctx context.Context) (*DeviceCredential, error)
+	ReadByHardwareID(hardwareID string, ctx context.Context) (*DeviceCredential, error)
+	Update(credential *DeviceCredential, ctx context.Context) (int64, error)
+}
@@ -0,0 +1,15 @@
+package models
+
+// DuplicateError is returned by repositories when a write would store a second row with the same unique key,
+// e.g. a status with the device_id and timestamp of a stored one. Err is the error of the database.
+type DuplicateError struct {
+	Err error
+}
+
+func (e *DuplicateError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DuplicateError) Unwrap() error {
+	return e.Err
+}
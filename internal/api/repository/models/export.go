@@ -0,0 +1,14 @@
+package models
+
+// Exportable tables, served at GET /export/{table}
+const (
+	ExportMazeDeviceStatus = "maze_device_status"
+	ExportData             = "data"
+)
+
+// ExportFilter narrows down exported rows, empty fields match everything
+type ExportFilter struct {
+	DeviceID string
+	Since    string // Inclusive RFC3339 timestamp
+	Until    string // Exclusive RFC3339 timestamp
+}
@@ -0,0 +1,26 @@
+package models
+
+import "strings"
+
+// FieldError describes why the value of a single field is invalid, Field is its JSON name
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// FieldErrors collects the invalid fields of a request
+type FieldErrors []FieldError
+
+// Add records that field is invalid
+func (f *FieldErrors) Add(field string, message string) {
+	*f = append(*f, FieldError{Field: field, Message: message})
+}
+
+// String lists the errors as sentences, e.g. "battery_level must be between 0 and 100."
+func (f FieldErrors) String() string {
+	sentences := make([]string, len(f))
+	for i, e := range f {
+		sentences[i] = e.Field + " " + e.Message + "."
+	}
+	return strings.Join(sentences, " ")
+}
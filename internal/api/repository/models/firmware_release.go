@@ -0,0 +1,26 @@
+package models
+
+import "context"
+
+// FirmwareRelease is a firmware binary that can be distributed to devices over the air
+type FirmwareRelease struct {
+	ID                int      `json:"id"`
+	Version           string   `json:"version"`            // Semantic version, e.g. 1.4.2
+	Hardware          string   `json:"hardware"`           // Target hardware, e.g. esp32
+	Checksum          string   `json:"checksum"`           // SHA-256 of the binary, hex encoded
+	Size              int64    `json:"size"`               // Size of the binary in bytes
+	RolloutPercentage int      `json:"rollout_percentage"` // Share of devices (0-100) that are offered the release
+	Allowlist         []string `json:"allowlist"`          // Devices that are always offered the release
+	FilePath          string   `json:"-"`                  // Location of the binary on local disk
+	CreatedAt         string   `json:"created_at"`         // Upload timestamp in RFC3339 format
+}
+
+// FirmwareReleaseRepository defines the interface for firmware release database operations
+type FirmwareReleaseRepository interface {
+	Create(release *FirmwareRelease, ctx context.Context) error
+	ReadOne(id int, ctx context.Context) (*FirmwareRelease, error)
+	ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*FirmwareRelease, error)
+	ReadByHardware(hardware string, ctx context.Context) ([]*FirmwareRelease, error)
+	Update(release *FirmwareRelease, ctx context.Context) (int64, error)
+	Delete(release *FirmwareRelease, ctx context.Context) (int64, error)
+}
@@ -0,0 +1,28 @@
+package models
+
+import "context"
+
+// Rollout states of a firmware release on a single device
+const (
+	RolloutOffered     = "offered"
+	RolloutDownloading = "downloading"
+	RolloutInstalled   = "installed"
+	RolloutFailed      = "failed"
+)
+
+// FirmwareRollout records how far a firmware release got on a single device
+type FirmwareRollout struct {
+	ID        int    `json:"id"`
+	ReleaseID int    `json:"release_id"`
+	DeviceID  string `json:"device_id"`
+	Status    string `json:"status"`     // One of offered, downloading, installed or failed
+	Detail    string `json:"detail"`     // Optional detail reported by the device, e.g. an error message
+	UpdatedAt string `json:"updated_at"` // Last change timestamp in RFC3339 format
+}
+
+// FirmwareRolloutRepository defines the interface for firmware rollout database operations
+type FirmwareRolloutRepository interface {
+	Upsert(rollout *FirmwareRollout, ctx context.Context) error
+	ReadByRelease(releaseID int, ctx context.Context) ([]*FirmwareRollout, error)
+	ReadOne(releaseID int, deviceID string, ctx context.Context) (*FirmwareRollout, error)
+}
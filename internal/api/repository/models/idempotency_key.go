@@ -0,0 +1,25 @@
+package models
+
+import "context"
+
+// IdempotencyKey stores the response of a POST request sent with an Idempotency-Key header,
+// so a retry of the request gets the same response instead of repeating its effect
+type IdempotencyKey struct {
+	Scope       string // Client the key belongs to, keys of different clients never collide
+	Key         string // Value of the Idempotency-Key header
+	RequestHash string // SHA-256 of the method, path and body, hex encoded
+	StatusCode  int    // Status of the stored response, 0 while the first request is in progress
+	ContentType string // Content-Type of the stored response
+	Body        []byte // Body of the stored response
+	ExpiresAt   string // Expiry timestamp in RFC3339 format, the key can be reused afterwards
+	CreatedAt   string // Creation timestamp in RFC3339 format
+}
+
+// IdempotencyKeyRepository defines the interface for idempotency key database operations
+type IdempotencyKeyRepository interface {
+	Create(key *IdempotencyKey, ctx context.Context) (bool, error)
+	Read(scope string, key string, ctx context.Context) (*IdempotencyKey, error)
+	Complete(key *IdempotencyKey, ctx context.Context) (int64, error)
+	Delete(scope string, key string, ctx context.Context) (int64, error)
+	DeleteExpired(before string, ctx context.Context) (int64, error)
+}
@@ -0,0 +1,49 @@
+package models
+
+// Importable tables, served at POST /import/{table}
+const (
+	ImportMazeDeviceStatus = "maze_device_status"
+	ImportDeviceConfig     = "device_config"
+	ImportData             = "data"
+)
+
+// Chunk sizes of an import, every chunk is stored in its own transaction
+const (
+	DefaultImportChunkSize = 500
+	MaxImportChunkSize     = MaxBatchSize
+)
+
+// MaxImportErrors is the largest number of rejected rows listed in an import report, the count covers all of them
+const MaxImportErrors = 1000
+
+// ImportOptions control an import. A dry run reads, validates and checks every row for duplicates without storing any.
+type ImportOptions struct {
+	DryRun    bool
+	ChunkSize int // Rows per transaction, 0 uses DefaultImportChunkSize
+}
+
+// ImportReport tells what an import did, or would do on a dry run
+type ImportReport struct {
+	DryRun     bool             `json:"dry_run"`
+	Rows       int              `json:"rows"`       // Rows read
+	Accepted   int              `json:"accepted"`   // Rows stored
+	Duplicates int              `json:"duplicates"` // Rows already stored, skipped
+	Rejected   int              `json:"rejected"`   // Rows that could not be read or failed validation
+	Chunks     int              `json:"chunks"`     // Transactions committed
+	Errors     []ImportRowError `json:"errors"`     // The first MaxImportErrors rejected rows
+}
+
+// ImportRowError reports why a row was rejected, Line is where the row starts in the file
+type ImportRowError struct {
+	Line   int         `json:"line"`
+	Error  string      `json:"error"`
+	Errors FieldErrors `json:"errors,omitempty"`
+}
+
+// Reject counts a rejected row and lists it while the list is not full
+func (r *ImportReport) Reject(line int, message string, fields FieldErrors) {
+	r.Rejected++
+	if len(r.Errors) < MaxImportErrors {
+		r.Errors = append(r.Errors, ImportRowError{Line: line, Error: message, Errors: fields})
+	}
+}
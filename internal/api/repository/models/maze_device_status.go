@@ -12,14 +12,19 @@ type MazeDeviceStatus struct {
 	HallSensorValue bool   `json:"hall_sensor_value"` // Hall sensor detection (true = ball detected at end)
 	BatteryLevel    int    `json:"battery_level"`    // Battery level 0-100
 	Timestamp       string `json:"timestamp"`        // Server timestamp in RFC3339 format
+	Version         int    `json:"version,omitempty"` // Incremented on every update, sent as the ETag
 }
 
 // MazeDeviceStatusRepository defines the interface for maze device status database operations
 type MazeDeviceStatusRepository interface {
 	Create(status *MazeDeviceStatus, ctx context.Context) error
+	CreateMany(statuses []*MazeDeviceStatus, ctx context.Context) ([]bool, error)
+	CheckMany(statuses []*MazeDeviceStatus, ctx context.Context) ([]bool, error)
 	ReadOne(id int, ctx context.Context) (*MazeDeviceStatus, error)
-	ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*MazeDeviceStatus, error)
-	ReadByDeviceID(deviceID string, ctx context.Context) ([]*MazeDeviceStatus, error)
+	ReadMany(page int, rowsPerPage int, ctx context.Context) Rows[MazeDeviceStatus]
+	ReadByDeviceID(deviceID string, ctx context.Context) Rows[MazeDeviceStatus]
+	Export(filter *ExportFilter, each func(status *MazeDeviceStatus) error, ctx context.Context) error
+	ReadLatest(ctx context.Context) ([]*MazeDeviceStatus, error)
 	Update(status *MazeDeviceStatus, ctx context.Context) (int64, error)
 	Delete(status *MazeDeviceStatus, ctx context.Context) (int64, error)
 }
@@ -0,0 +1,26 @@
+package models
+
+import "context"
+
+// RefreshToken is a long-lived token a client exchanges for a new access token. Only its hash is stored,
+// every refresh revokes it in favour of a new one.
+type RefreshToken struct {
+	ID         int    `json:"id"`
+	TokenHash  string `json:"-"`                     // SHA-256 of the token, hex encoded
+	Username   string `json:"username"`              // Account or device the token was issued to
+	DeviceID   string `json:"device_id,omitempty"`   // Set when the token was issued to a provisioned device
+	Credential string `json:"-"`                     // MAC of the credentials it was issued for, refreshing fails once they changed
+	ExpiresAt  string `json:"expires_at"`            // Expiry timestamp in RFC3339 format
+	RevokedAt  string `json:"revoked_at,omitempty"`  // Revocation timestamp in RFC3339 format, empty while valid
+	ReplacedBy int    `json:"replaced_by,omitempty"` // ID of the token issued when this one was refreshed
+	CreatedAt  string `json:"created_at"`            // Creation timestamp in RFC3339 format
+}
+
+// RefreshTokenRepository defines the interface for refresh token database operations
+type RefreshTokenRepository interface {
+	Create(token *RefreshToken, ctx context.Context) error
+	ReadByHash(tokenHash string, ctx context.Context) (*RefreshToken, error)
+	Revoke(token *RefreshToken, ctx context.Context) (int64, error)
+	RevokeByUsername(username string, revokedAt string, ctx context.Context) (int64, error)
+	DeleteExpired(before string, ctx context.Context) (int64, error)
+}
@@ -0,0 +1,34 @@
+package models
+
+import "iter"
+
+// Rows is a sequence of rows read one at a time, so a list of any size is never held in memory. An error ends the
+// sequence and is yielded with a nil row. Rows read from the database hold a connection while they are ranged over.
+type Rows[T any] = iter.Seq2[*T, error]
+
+// RowsOf returns the rows of a slice, or only err when it is not nil
+func RowsOf[T any](rows []*T, err error) Rows[T] {
+	return func(yield func(*T, error) bool) {
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for _, row := range rows {
+			if !yield(row, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Collect reads all rows into a slice, stopping at the first error. No rows return nil.
+func Collect[T any](rows Rows[T]) ([]*T, error) {
+	var collected []*T
+	for row, err := range rows {
+		if err != nil {
+			return nil, err
+		}
+		collected = append(collected, row)
+	}
+	return collected, nil
+}
@@ -2,86 +2,583 @@ package server
 
 import (
 	"context"
+	"fmt"
+	"goapi/docs"
+	"goapi/internal/api/apiversion"
+	"goapi/internal/api/config"
+	"goapi/internal/api/handlers/audit"
+	"goapi/internal/api/handlers/auth"
 	"goapi/internal/api/handlers/data"
 	"goapi/internal/api/handlers/device_config"
+	"goapi/internal/api/handlers/export"
+	"goapi/internal/api/handlers/firmware"
+	"goapi/internal/api/handlers/importer"
+	"goapi/internal/api/handlers/lockout"
 	"goapi/internal/api/handlers/maze_device"
+	"goapi/internal/api/handlers/provisioning"
+	"goapi/internal/api/handlers/socket"
+	"goapi/internal/api/health"
+	"goapi/internal/api/metrics"
 	"goapi/internal/api/middleware"
+	"goapi/internal/api/openapi"
+	"goapi/internal/api/ratelimit"
+	"goapi/internal/api/realtime"
+	"goapi/internal/api/repository/models"
 	"goapi/internal/api/service"
-	"log"
+	auditService "goapi/internal/api/service/audit"
+	authService "goapi/internal/api/service/auth"
+	idempotencyService "goapi/internal/api/service/idempotency"
+	lockoutService "goapi/internal/api/service/lockout"
+	provisioningService "goapi/internal/api/service/provisioning"
+	"goapi/internal/api/tabular"
+	"goapi/internal/api/tlsreload"
+	"log/slog"
 	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+const (
+	// claimPurgeInterval is how often expired, unredeemed claim codes are deleted
+	claimPurgeInterval = time.Hour
+	// tokenPurgeInterval is how often expired refresh tokens are deleted
+	tokenPurgeInterval = time.Hour
+	// idempotencyPurgeInterval is how often expired idempotency keys are deleted
+	idempotencyPurgeInterval = time.Hour
+	// tlsReloadInterval is how often the certificate files are checked for changes
+	tlsReloadInterval = 30 * time.Second
+	// limiterCleanupInterval is how often rate limiters forget idle clients
+	limiterCleanupInterval = time.Minute
+	// lockoutCleanupInterval is how often expired failed logins and lockouts are forgotten
+	lockoutCleanupInterval = time.Minute
 )
 
+// legacyDeprecatedAt is when the unversioned routes were deprecated in favour of /v1, sent in their Deprecation header
+var legacyDeprecatedAt = time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+
+// routeMethods are the methods routes are registered with, OPTIONS is answered by the CORS middleware
+var routeMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
 type Server struct {
 	ctx        context.Context
 	HTTPServer *http.Server
-	logger     *log.Logger
+	logger     *slog.Logger
+	checker    *health.Checker
+	cfg        config.ServerConfig
+	routes     []string
+}
+
+// routeTable is the server's ServeMux together with the patterns that are served without credentials,
+// the upload and import patterns that get larger limits, the export patterns that get more time, the patterns that
+// answer with tables or files instead of JSON, and the rate limiter of every pattern. Patterns are registered
+// unversioned, e.g. "GET /data/{id}", and served under every API version, e.g. /v1/data/{id}. The unversioned
+// paths stay registered as deprecated aliases of the legacy version.
+type routeTable struct {
+	*http.ServeMux
+	cfg      *config.Config
+	patterns map[string]bool
+	public   map[string]bool
+	uploads  map[string]bool
+	exports  map[string]bool
+	imports  map[string]bool
+	tables   map[string]bool
+	files    map[string]bool
+	limiters map[string]*ratelimit.Limiter // Keyed by unversioned pattern, "" is the default limiter and nil means unlimited
+}
+
+func newRouteTable(ctx context.Context, cfg *config.Config) *routeTable {
+	rt := &routeTable{
+		ServeMux: http.NewServeMux(),
+		cfg:      cfg,
+		patterns: make(map[string]bool),
+		public:   make(map[string]bool),
+		uploads:  make(map[string]bool),
+		exports:  make(map[string]bool),
+		imports:  make(map[string]bool),
+		tables:   make(map[string]bool),
+		files:    make(map[string]bool),
+		limiters: make(map[string]*ratelimit.Limiter),
+	}
+	rt.limiters[""] = newLimiter(ctx, cfg.Limits.RateLimit)
+	for pattern, limit := range cfg.Limits.Routes {
+		rt.limiters[pattern] = newLimiter(ctx, limit)
+	}
+	return rt
+}
+
+func newLimiter(ctx context.Context, limit config.RateLimit) *ratelimit.Limiter {
+	if limit.RequestsPerSecond == 0 {
+		return nil
+	}
+	limiter := ratelimit.New(limit.RequestsPerSecond, limit.Burst)
+	go limiter.Run(ctx, limiterCleanupInterval)
+	return limiter
+}
+
+// HandleFunc registers a handler under every API version and the unversioned alias. Every route runs in its own
+// span, named after the pattern that matched, and finds its API version in the request context.
+func (rt *routeTable) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	rt.patterns[pattern] = true
+	for _, version := range apiversion.Versions {
+		rt.handle(versioned(pattern, version), version, handler)
+	}
+	rt.handle(pattern, apiversion.Legacy, handler)
+}
+
+func (rt *routeTable) handle(pattern string, version string, handler func(http.ResponseWriter, *http.Request)) {
+	name := "handler " + pattern
+	rt.ServeMux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := otel.Tracer("goapi/internal/api/server").Start(apiversion.WithVersion(r.Context(), version), name)
+		defer span.End()
+		handler(w, r.WithContext(ctx))
+	})
+}
+
+// HandlePublicFunc registers a handler that does not require authentication
+func (rt *routeTable) HandlePublicFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	rt.public[pattern] = true
+	rt.HandleFunc(pattern, handler)
+}
+
+// HandleUploadFunc registers a handler that accepts large bodies and may take longer than other requests
+func (rt *routeTable) HandleUploadFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	rt.uploads[pattern] = true
+	rt.HandleFunc(pattern, handler)
+}
+
+// HandleExportFunc registers a handler that streams large tables and may take longer than other requests
+func (rt *routeTable) HandleExportFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	rt.exports[pattern] = true
+	rt.HandleTableFunc(pattern, handler)
+}
+
+// HandleTableFunc registers a handler that answers with a table in the format the Accept header asks for
+func (rt *routeTable) HandleTableFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	rt.tables[pattern] = true
+	rt.HandleFunc(pattern, handler)
 }
 
-func NewServer(ctx context.Context, sf *service.ServiceFactory, logger *log.Logger) *Server {
+// HandleFileFunc registers a handler that answers with a binary file
+func (rt *routeTable) HandleFileFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	rt.files[pattern] = true
+	rt.HandleFunc(pattern, handler)
+}
 
-	mux := http.NewServeMux()
-	err := setupDataHandlers(mux, sf, logger)
+// HandleImportFunc registers a handler that reads large bodies and may take longer than other requests
+func (rt *routeTable) HandleImportFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	rt.imports[pattern] = true
+	rt.HandleFunc(pattern, handler)
+}
+
+// isPublic reports whether the route matching r was registered with HandlePublicFunc
+func (rt *routeTable) isPublic(r *http.Request) bool {
+	return rt.public[rt.endpoint(r)]
+}
+
+// timeout returns the request timeout of the route matching r
+func (rt *routeTable) timeout(r *http.Request) time.Duration {
+	switch endpoint := rt.endpoint(r); {
+	case rt.uploads[endpoint]:
+		return rt.cfg.Server.UploadTimeout
+	case rt.exports[endpoint]:
+		return rt.cfg.Server.ExportTimeout
+	case rt.imports[endpoint]:
+		return rt.cfg.Server.ImportTimeout
+	}
+	return rt.cfg.Server.RequestTimeout
+}
+
+// bodyLimit returns the maximum request body size of the route matching r
+func (rt *routeTable) bodyLimit(r *http.Request) int64 {
+	switch endpoint := rt.endpoint(r); {
+	case rt.uploads[endpoint]:
+		return rt.cfg.Limits.UploadMaxBodyBytes
+	case rt.imports[endpoint]:
+		return rt.cfg.Limits.ImportMaxBodyBytes
+	}
+	return rt.cfg.Limits.MaxBodyBytes
+}
+
+// representations returns the media types the route matching r answers with, errors aside
+func (rt *routeTable) representations(r *http.Request) []string {
+	switch endpoint := rt.endpoint(r); {
+	case rt.tables[endpoint]:
+		return []string{tabular.JSON, tabular.CSV, tabular.NDJSON}
+	case rt.files[endpoint]:
+		return []string{"application/octet-stream"}
+	}
+	return []string{"application/json"}
+}
+
+// limiter returns the rate limiter of the route matching r, nil when it is unlimited. All versions of a route
+// share its limiter.
+func (rt *routeTable) limiter(r *http.Request) *ratelimit.Limiter {
+	if limiter, ok := rt.limiters[rt.endpoint(r)]; ok {
+		return limiter
+	}
+	return rt.limiters[""]
+}
+
+// checkLimits reports rate limits configured for patterns that are not registered, e.g. because of a typo
+func (rt *routeTable) checkLimits() error {
+	for pattern := range rt.cfg.Limits.Routes {
+		if !rt.patterns[pattern] {
+			return fmt.Errorf("rate limit configured for unknown route %q, use the unversioned pattern", pattern)
+		}
+	}
+	return nil
+}
+
+// routes returns the versioned patterns in sorted order, the deprecated aliases are left out
+func (rt *routeTable) routes() []string {
+	patterns := make([]string, 0, len(rt.patterns)*len(apiversion.Versions))
+	for pattern := range rt.patterns {
+		for _, version := range apiversion.Versions {
+			patterns = append(patterns, versioned(pattern, version))
+		}
+	}
+	sort.Strings(patterns)
+	return patterns
+}
+
+// route returns the pattern of the route matching r, e.g. "GET /v1/data/{id}", or "" when nothing matches
+func (rt *routeTable) route(r *http.Request) string {
+	_, pattern := rt.Handler(r)
+	return pattern
+}
+
+// methods returns the methods registered for the path of r, e.g. [GET PATCH DELETE] for /v1/data/1
+func (rt *routeTable) methods(r *http.Request) []string {
+	var methods []string
+	for _, method := range routeMethods {
+		probe := *r
+		probe.Method = method
+		if rt.route(&probe) != "" {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
+
+// versionedRoute returns the versioned pattern of the route matching r, deprecated aliases are mapped to the
+// legacy version they are answered with
+func (rt *routeTable) versionedRoute(r *http.Request) string {
+	pattern := rt.route(r)
+	if rt.patterns[pattern] {
+		return versioned(pattern, apiversion.Legacy)
+	}
+	return pattern
+}
+
+// endpoint returns the unversioned pattern of the route matching r, e.g. "GET /data/{id}" for /v1/data/1
+func (rt *routeTable) endpoint(r *http.Request) string {
+	pattern := rt.route(r)
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		return pattern
+	}
+	for _, version := range apiversion.Versions {
+		if rest, ok := strings.CutPrefix(path, "/"+version+"/"); ok {
+			return method + " /" + rest
+		}
+	}
+	return pattern
+}
+
+// successor returns the versioned path of a request to a deprecated alias, "" for other requests
+func (rt *routeTable) successor(r *http.Request) string {
+	if rt.patterns[rt.route(r)] {
+		return "/" + apiversion.Legacy + r.URL.Path
+	}
+	return ""
+}
+
+// versioned returns pattern served under version, e.g. "GET /v1/data/{id}" for "GET /data/{id}"
+func versioned(pattern string, version string) string {
+	method, path, _ := strings.Cut(pattern, " ")
+	return method + " /" + version + path
+}
+
+// NewServer creates the API server. /metrics, /healthz, /readyz and the API documentation are served outside
+// the API middleware chain, so scrapers, probes and browsers need no credentials.
+// Background workers run until ctx is done and report their status to the checker.
+func NewServer(ctx context.Context, cfg *config.Config, sf *service.ServiceFactory, logger *slog.Logger, m *metrics.Metrics, checker *health.Checker) *Server {
+
+	mux := newRouteTable(ctx, cfg)
+	hub := realtime.NewHub(logger, cfg.Server.RequestTimeout, realtime.AllowOrigins(cfg.CORS.AllowedOrigins))
+	m.RegisterSockets(hub.Connections)
+	as, err := setupAuditHandlers(mux, sf, logger)
+	if err != nil {
+		logger.Error("Error setting up audit handlers", "error", err)
+		os.Exit(1)
+	}
+
+	err = setupDataHandlers(mux, sf, logger, as)
 	if err != nil {
-		logger.Fatalf("Error setting up data handlers: %v", err)
+		logger.Error("Error setting up data handlers", "error", err)
+		os.Exit(1)
 	}
 
-	err = setupMazeDeviceHandlers(mux, sf, logger)
+	err = setupMazeDeviceHandlers(mux, sf, logger, m, as, hub)
 	if err != nil {
-		logger.Fatalf("Error setting up maze device handlers: %v", err)
+		logger.Error("Error setting up maze device handlers", "error", err)
+		os.Exit(1)
 	}
 
-	err = setupDeviceConfigHandlers(mux, sf, logger)
+	err = setupExportHandlers(mux, sf, logger)
 	if err != nil {
-		logger.Fatalf("Error setting up device config handlers: %v", err)
+		logger.Error("Error setting up export handlers", "error", err)
+		os.Exit(1)
 	}
 
+	err = setupImportHandlers(mux, sf, logger, as, hub)
+	if err != nil {
+		logger.Error("Error setting up import handlers", "error", err)
+		os.Exit(1)
+	}
+
+	err = setupDeviceConfigHandlers(mux, sf, logger, as, hub)
+	if err != nil {
+		logger.Error("Error setting up device config handlers", "error", err)
+		os.Exit(1)
+	}
+
+	setupSocketHandlers(mux, logger, hub)
+
+	// Device IDs double as usernames, a device named like the admin would share its lockouts, tokens and audit trail
+	provisioningService, err := setupProvisioningHandlers(mux, sf, logger, cfg.Auth.Username, middleware.DefaultUsername)
+	if err != nil {
+		logger.Error("Error setting up provisioning handlers", "error", err)
+		os.Exit(1)
+	}
+	go checker.RunWorker(ctx, "claim_code_purge", claimPurgeInterval, func(ctx context.Context) error {
+		purged, err := provisioningService.PurgeExpiredClaims(ctx)
+		if err != nil {
+			logger.ErrorContext(ctx, "Error purging expired claim codes", "error", err)
+			return err
+		}
+		if purged > 0 {
+			logger.InfoContext(ctx, "Purged expired claim codes", "count", purged)
+		}
+		return nil
+	})
+
+	err = setupFirmwareHandlers(mux, sf, logger)
+	if err != nil {
+		logger.Error("Error setting up firmware handlers", "error", err)
+		os.Exit(1)
+	}
+
+	ls, err := setupLockoutHandlers(mux, sf, logger)
+	if err != nil {
+		logger.Error("Error setting up lockout handlers", "error", err)
+		os.Exit(1)
+	}
+	go ls.Run(ctx, lockoutCleanupInterval)
+
+	// * Basic credentials are accepted on every route and exchanged for tokens on POST /auth/login
+	validators := []middleware.CredentialValidator{
+		middleware.UserAccount(cfg.Auth.Username, cfg.Auth.Password),
+		provisioningService.Authenticate,
+	}
+	lookups := []authService.CredentialLookup{
+		authService.UserCredential(cfg.Auth.Username, cfg.Auth.Password),
+		provisioningService.Credential,
+	}
+	tokens, err := setupAuthHandlers(mux, sf, logger, ls, validators, lookups)
+	if err != nil {
+		logger.Error("Error setting up auth handlers", "error", err)
+		os.Exit(1)
+	}
+	go checker.RunWorker(ctx, "refresh_token_purge", tokenPurgeInterval, func(ctx context.Context) error {
+		purged, err := tokens.PurgeExpiredTokens(ctx)
+		if err != nil {
+			logger.ErrorContext(ctx, "Error purging expired refresh tokens", "error", err)
+			return err
+		}
+		if purged > 0 {
+			logger.InfoContext(ctx, "Purged expired refresh tokens", "count", purged)
+		}
+		return nil
+	})
+
+	is, err := sf.CreateIdempotencyService(service.SQLiteDataService)
+	if err != nil {
+		logger.Error("Error setting up idempotency keys", "error", err)
+		os.Exit(1)
+	}
+	go checker.RunWorker(ctx, "idempotency_key_purge", idempotencyPurgeInterval, func(ctx context.Context) error {
+		purged, err := is.PurgeExpiredKeys(ctx)
+		if err != nil {
+			logger.ErrorContext(ctx, "Error purging expired idempotency keys", "error", err)
+			return err
+		}
+		if purged > 0 {
+			logger.InfoContext(ctx, "Purged expired idempotency keys", "count", purged)
+		}
+		return nil
+	})
+
+	if err := mux.checkLimits(); err != nil {
+		logger.Error("Error setting up rate limits", "error", err)
+		os.Exit(1)
+	}
+
+	verifyToken := func(token string, ctx context.Context) (middleware.Identity, bool) {
+		claims, err := tokens.Verify(token, ctx)
+		if err != nil {
+			return middleware.Identity{}, false
+		}
+		return middleware.Identity{Username: claims.Username, DeviceID: claims.DeviceID}, true
+	}
+	authentication := middleware.NewBasicAuthenticationMiddleware(mux.isPublic, ls, verifyToken, validators...)
+	if cfg.Auth.Mode == config.AuthNone {
+		logger.Warn("Authentication is disabled, every request runs as the admin account", "username", cfg.Auth.Username)
+		authentication = middleware.NewNoAuthenticationMiddleware(middleware.Identity{Username: cfg.Auth.Username})
+	}
+
+	spec, err := openapi.Load(docs.OpenAPI)
+	if err != nil {
+		logger.Error("Error loading the OpenAPI document", "error", err)
+		os.Exit(1)
+	}
+
+	// * Validated by config, the unversioned routes carry no Sunset header until a date is set *
+	var sunset time.Time
+	if cfg.Server.LegacySunset != "" {
+		sunset, _ = time.Parse(config.SunsetLayout, cfg.Server.LegacySunset)
+	}
+
+	cors := middleware.CORSPolicy{
+		AllowedOrigins:   cfg.CORS.AllowedOrigins,
+		AllowedMethods:   cfg.CORS.AllowedMethods,
+		AllowedHeaders:   cfg.CORS.AllowedHeaders,
+		ExposedHeaders:   cfg.CORS.ExposedHeaders,
+		AllowCredentials: cfg.CORS.AllowCredentials,
+		MaxAge:           cfg.CORS.MaxAge,
+	}
 	middlewares := []middleware.Middleware{
-		middleware.BasicAuthenticationMiddleware,
-		middleware.CommonMiddleware,
+		middleware.NewIdempotencyMiddleware(idempotencyStore{is}, logger),
+		middleware.NewRateLimitMiddleware(mux.limiter),
+		authentication,
+		middleware.NewClientIPRateLimitMiddleware(mux.limiter),
+		middleware.NewTimeoutMiddleware(mux.timeout),
+		middleware.NewBodyLimitMiddleware(mux.bodyLimit),
+		middleware.NewNegotiationMiddleware(mux.representations),
+		middleware.NewDeprecationMiddleware(mux.successor, legacyDeprecatedAt, sunset),
+		middleware.NewCompressionMiddleware(),
+		middleware.NewCORSMiddleware(cors, mux.methods),
+		middleware.NewMetricsMiddleware(mux.route, m.ObserveRequest),
+		middleware.NewAccessLogMiddleware(logger, mux.route),
+		middleware.RequestIDMiddleware,
+		middleware.NewTracingMiddleware(mux.route),
+	}
+	// * The contract is checked closest to the handlers, so rejected credentials or rate limits are not reported *
+	if cfg.Server.ContractValidation != config.ContractOff {
+		contract := middleware.NewContractMiddleware(spec, mux.versionedRoute, cfg.Server.ContractValidation == config.ContractEnforce, logger)
+		middlewares = append([]middleware.Middleware{contract}, middlewares...)
+	}
+
+	root := http.NewServeMux()
+	routes := mux.routes()
+	handle := func(pattern string, handler http.Handler) {
+		routes = append(routes, pattern)
+		root.Handle(pattern, handler)
+	}
+	handle("GET /metrics", m.Handler(logger))
+	handle("GET /healthz", checker.LiveHandler())
+	handle("GET /readyz", checker.ReadyHandler(logger))
+	handle("GET /openapi.yaml", spec.YAMLHandler())
+	handle("GET /openapi.json", spec.JSONHandler())
+	handle("GET /docs", spec.DocsHandler())
+	root.Handle("/", middleware.ChainMiddleware(mux, middlewares...))
+	sort.Strings(routes)
+
+	httpServer := &http.Server{
+		Addr:     cfg.Server.Addr,
+		Handler:  root,
+		ErrorLog: slog.NewLogLogger(logger.Handler(), slog.LevelError),
+	}
+	// * Shutdown does not close hijacked connections, WebSocket clients are told the server is going away *
+	httpServer.RegisterOnShutdown(hub.Close)
+	if cfg.Server.TLS.Enabled() {
+		certificates, err := tlsreload.New(cfg.Server.TLS, logger)
+		if err != nil {
+			logger.Error("Error setting up TLS", "error", err)
+			os.Exit(1)
+		}
+		go certificates.Watch(ctx, tlsReloadInterval)
+		httpServer.TLSConfig = certificates.TLSConfig()
 	}
 
 	return &Server{
-		ctx:    ctx,
-		logger: logger,
-		HTTPServer: &http.Server{
-			Handler: middleware.ChainMiddleware(mux, middlewares...),
-		},
+		ctx:        ctx,
+		logger:     logger,
+		checker:    checker,
+		cfg:        cfg.Server,
+		HTTPServer: httpServer,
+		routes:     routes,
 	}
 }
 
+// Routes returns the pattern of every registered route, e.g. "GET /v1/data/{id}", in sorted order. The deprecated
+// unversioned aliases are left out.
+func (api *Server) Routes() []string {
+	return append([]string(nil), api.routes...)
+}
+
+// Shutdown first fails readiness and waits the drain delay so load balancers stop sending new requests,
+// then stops accepting connections and waits for in-flight requests to finish
 func (api *Server) Shutdown() error {
-	api.logger.Println("Gracefully shutting down server...")
-	return api.HTTPServer.Shutdown(api.ctx)
+	api.logger.Info("Gracefully shutting down server...", "drain_delay", api.cfg.ShutdownDrainDelay.String())
+	api.checker.SetShuttingDown()
+	time.Sleep(api.cfg.ShutdownDrainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), api.cfg.ShutdownTimeout)
+	defer cancel()
+	return api.HTTPServer.Shutdown(ctx)
 }
 
-func (api *Server) ListenAndServe(addr string) error {
-	api.HTTPServer.Addr = addr
+// ListenAndServe serves HTTPS when a certificate is configured, plain HTTP otherwise
+func (api *Server) ListenAndServe() error {
+	if api.HTTPServer.TLSConfig != nil {
+		// The certificate comes from the TLS config, so it can be reloaded
+		return api.HTTPServer.ListenAndServeTLS("", "")
+	}
 	return api.HTTPServer.ListenAndServe()
 }
 
 // * REST API handlers for original data endpoint
-func setupDataHandlers(mux *http.ServeMux, sf *service.ServiceFactory, logger *log.Logger) error {
+func setupDataHandlers(mux *routeTable, sf *service.ServiceFactory, logger *slog.Logger, as *auditService.AuditServiceSQLite) error {
 
-	ds, err := sf.CreateDataService(service.SQLiteDataService)
+	dataService, err := sf.CreateDataService(service.SQLiteDataService)
 	if err != nil {
 		return err
 	}
+	ds := as.AuditDataService(dataService)
 
-	mux.HandleFunc("OPTIONS /*", func(w http.ResponseWriter, r *http.Request) {
-		data.OptionsHandler(w, r)
-	})
 	mux.HandleFunc("POST /data", func(w http.ResponseWriter, r *http.Request) {
 		data.PostHandler(w, r, logger, ds)
 	})
+	mux.HandleFunc("POST /data/batch", func(w http.ResponseWriter, r *http.Request) {
+		data.PostBatchHandler(w, r, logger, ds)
+	})
 	mux.HandleFunc("PUT /data", func(w http.ResponseWriter, r *http.Request) {
 		data.PutHandler(w, r, logger, ds)
 	})
-	mux.HandleFunc("GET /data", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleTableFunc("GET /data", func(w http.ResponseWriter, r *http.Request) {
 		data.GetHandler(w, r, logger, ds)
 	})
 	mux.HandleFunc("GET /data/{id}", func(w http.ResponseWriter, r *http.Request) {
 		data.GetByIDHandler(w, r, logger, ds)
 	})
+	mux.HandleFunc("PATCH /data/{id}", func(w http.ResponseWriter, r *http.Request) {
+		data.PatchHandler(w, r, logger, ds)
+	})
 	mux.HandleFunc("DELETE /data/{id}", func(w http.ResponseWriter, r *http.Request) {
 		data.DeleteHandler(w, r, logger, ds)
 	})
@@ -89,38 +586,54 @@ func setupDataHandlers(mux *http.ServeMux, sf *service.ServiceFactory, logger *l
 }
 
 // * REST API handlers for maze device status
-func setupMazeDeviceHandlers(mux *http.ServeMux, sf *service.ServiceFactory, logger *log.Logger) error {
+func setupMazeDeviceHandlers(mux *routeTable, sf *service.ServiceFactory, logger *slog.Logger, m *metrics.Metrics, as *auditService.AuditServiceSQLite, hub *realtime.Hub) error {
 
-	mazeService, err := sf.CreateMazeDeviceStatusService(service.SQLiteDataService)
+	ms, err := sf.CreateMazeDeviceStatusService(service.SQLiteDataService)
 	if err != nil {
 		return err
 	}
+	m.RegisterDevices(ms, logger)
+	mazeService := hub.NotifyMazeDeviceStatusService(as.AuditMazeDeviceStatusService(m.InstrumentMazeDeviceStatusService(ms)))
 
 	mux.HandleFunc("POST /device/status", func(w http.ResponseWriter, r *http.Request) {
 		maze_device.PostHandler(w, r, logger, mazeService)
 	})
+	mux.HandleFunc("POST /device/status/batch", func(w http.ResponseWriter, r *http.Request) {
+		maze_device.PostBatchHandler(w, r, logger, mazeService)
+	})
 	mux.HandleFunc("PUT /device/status", func(w http.ResponseWriter, r *http.Request) {
 		maze_device.PutHandler(w, r, logger, mazeService)
 	})
-	mux.HandleFunc("GET /device/status", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleTableFunc("GET /device/status", func(w http.ResponseWriter, r *http.Request) {
 		maze_device.GetHandler(w, r, logger, mazeService)
 	})
 	mux.HandleFunc("GET /device/status/{id}", func(w http.ResponseWriter, r *http.Request) {
 		maze_device.GetByIDHandler(w, r, logger, mazeService)
 	})
+	mux.HandleFunc("PATCH /device/status/{id}", func(w http.ResponseWriter, r *http.Request) {
+		maze_device.PatchHandler(w, r, logger, mazeService)
+	})
 	mux.HandleFunc("DELETE /device/status/{id}", func(w http.ResponseWriter, r *http.Request) {
 		maze_device.DeleteHandler(w, r, logger, mazeService)
 	})
 	return nil
 }
 
+// * WebSocket for live device events and commands, used by the mobile app
+func setupSocketHandlers(mux *routeTable, logger *slog.Logger, hub *realtime.Hub) {
+	mux.HandleFunc("GET /ws", func(w http.ResponseWriter, r *http.Request) {
+		socket.GetHandler(w, r, logger, hub)
+	})
+}
+
 // * REST API handlers for device config
-func setupDeviceConfigHandlers(mux *http.ServeMux, sf *service.ServiceFactory, logger *log.Logger) error {
+func setupDeviceConfigHandlers(mux *routeTable, sf *service.ServiceFactory, logger *slog.Logger, as *auditService.AuditServiceSQLite, hub *realtime.Hub) error {
 
-	configService, err := sf.CreateDeviceConfigService(service.SQLiteDataService)
+	cs, err := sf.CreateDeviceConfigService(service.SQLiteDataService)
 	if err != nil {
 		return err
 	}
+	configService := hub.NotifyDeviceConfigService(as.AuditDeviceConfigService(cs))
 
 	mux.HandleFunc("POST /device/config", func(w http.ResponseWriter, r *http.Request) {
 		device_config.PostHandler(w, r, logger, configService)
@@ -134,8 +647,194 @@ func setupDeviceConfigHandlers(mux *http.ServeMux, sf *service.ServiceFactory, l
 	mux.HandleFunc("GET /device/config/{id}", func(w http.ResponseWriter, r *http.Request) {
 		device_config.GetByIDHandler(w, r, logger, configService)
 	})
+	mux.HandleFunc("PATCH /device/config/{id}", func(w http.ResponseWriter, r *http.Request) {
+		device_config.PatchHandler(w, r, logger, configService)
+	})
 	mux.HandleFunc("DELETE /device/config/{id}", func(w http.ResponseWriter, r *http.Request) {
 		device_config.DeleteHandler(w, r, logger, configService)
 	})
 	return nil
 }
+
+// * REST API handlers for device provisioning
+func setupProvisioningHandlers(mux *routeTable, sf *service.ServiceFactory, logger *slog.Logger, reserved ...string) (*provisioningService.ProvisioningServiceSQLite, error) {
+
+	ps, err := sf.CreateProvisioningService(service.SQLiteDataService, reserved...)
+	if err != nil {
+		return nil, err
+	}
+
+	mux.HandleFunc("POST /provisioning/claims", middleware.AdminOnly(func(w http.ResponseWriter, r *http.Request) {
+		provisioning.PostHandler(w, r, logger, ps)
+	}))
+	mux.HandleFunc("GET /provisioning/claims", middleware.AdminOnly(func(w http.ResponseWriter, r *http.Request) {
+		provisioning.GetHandler(w, r, logger, ps)
+	}))
+	mux.HandleFunc("DELETE /provisioning/claims/{id}", middleware.AdminOnly(func(w http.ResponseWriter, r *http.Request) {
+		provisioning.DeleteHandler(w, r, logger, ps)
+	}))
+	mux.HandlePublicFunc("POST /provisioning/claim", func(w http.ResponseWriter, r *http.Request) {
+		provisioning.ClaimHandler(w, r, logger, ps)
+	})
+	return ps, nil
+}
+
+// * REST API handlers for firmware releases and OTA updates
+func setupFirmwareHandlers(mux *routeTable, sf *service.ServiceFactory, logger *slog.Logger) error {
+
+	fs, err := sf.CreateFirmwareService(service.SQLiteDataService)
+	if err != nil {
+		return err
+	}
+
+	// Release management is limited to admins
+	mux.HandleUploadFunc("POST /firmware", middleware.AdminOnly(func(w http.ResponseWriter, r *http.Request) {
+		firmware.PostHandler(w, r, logger, fs)
+	}))
+	mux.HandleFunc("GET /firmware", middleware.AdminOnly(func(w http.ResponseWriter, r *http.Request) {
+		firmware.GetHandler(w, r, logger, fs)
+	}))
+	mux.HandleFunc("GET /firmware/{id}", middleware.AdminOnly(func(w http.ResponseWriter, r *http.Request) {
+		firmware.GetByIDHandler(w, r, logger, fs)
+	}))
+	mux.HandleFunc("PUT /firmware/{id}/rollout", middleware.AdminOnly(func(w http.ResponseWriter, r *http.Request) {
+		firmware.PutHandler(w, r, logger, fs)
+	}))
+	mux.HandleFunc("DELETE /firmware/{id}", middleware.AdminOnly(func(w http.ResponseWriter, r *http.Request) {
+		firmware.DeleteHandler(w, r, logger, fs)
+	}))
+	mux.HandleFunc("GET /firmware/{id}/rollouts", middleware.AdminOnly(func(w http.ResponseWriter, r *http.Request) {
+		firmware.GetRolloutsHandler(w, r, logger, fs)
+	}))
+
+	// Devices check for, download and report updates
+	mux.HandleFunc("GET /firmware/update", func(w http.ResponseWriter, r *http.Request) {
+		firmware.UpdateHandler(w, r, logger, fs)
+	})
+	mux.HandleFileFunc("GET /firmware/{id}/download", func(w http.ResponseWriter, r *http.Request) {
+		firmware.DownloadHandler(w, r, logger, fs)
+	})
+	mux.HandleFunc("POST /firmware/rollouts", func(w http.ResponseWriter, r *http.Request) {
+		firmware.RolloutHandler(w, r, logger, fs)
+	})
+	return nil
+}
+
+// * REST API handlers for authentication lockouts
+func setupLockoutHandlers(mux *routeTable, sf *service.ServiceFactory, logger *slog.Logger) (*lockoutService.LockoutServiceSQLite, error) {
+
+	ls, err := sf.CreateLockoutService(service.SQLiteDataService)
+	if err != nil {
+		return nil, err
+	}
+
+	mux.HandleFunc("GET /auth/lockouts", middleware.AdminOnly(func(w http.ResponseWriter, r *http.Request) {
+		lockout.GetHandler(w, r, logger, ls)
+	}))
+	return ls, nil
+}
+
+// * REST API handlers for token-based authentication, the credentials or refresh token in the body authenticate them
+func setupAuthHandlers(mux *routeTable, sf *service.ServiceFactory, logger *slog.Logger, ls *lockoutService.LockoutServiceSQLite, validators []middleware.CredentialValidator, lookups []authService.CredentialLookup) (*authService.AuthServiceSQLite, error) {
+
+	credentials := make([]authService.CredentialValidator, len(validators))
+	for i, v := range validators {
+		credentials[i] = authService.CredentialValidator(v)
+	}
+	as, err := sf.CreateAuthService(service.SQLiteDataService, ls, lookups, credentials...)
+	if err != nil {
+		return nil, err
+	}
+
+	mux.HandlePublicFunc("POST /auth/login", func(w http.ResponseWriter, r *http.Request) {
+		auth.LoginHandler(w, r, logger, as)
+	})
+	mux.HandlePublicFunc("POST /auth/refresh", func(w http.ResponseWriter, r *http.Request) {
+		auth.RefreshHandler(w, r, logger, as)
+	})
+	mux.HandlePublicFunc("POST /auth/logout", func(w http.ResponseWriter, r *http.Request) {
+		auth.LogoutHandler(w, r, logger, as)
+	})
+	return as, nil
+}
+
+// * REST API handlers for the audit log, the returned service records changes made through the other handlers
+func setupAuditHandlers(mux *routeTable, sf *service.ServiceFactory, logger *slog.Logger) (*auditService.AuditServiceSQLite, error) {
+
+	actor := func(ctx context.Context) string {
+		identity, _ := middleware.IdentityFromContext(ctx)
+		return identity.Username
+	}
+	as, err := sf.CreateAuditService(service.SQLiteDataService, actor)
+	if err != nil {
+		return nil, err
+	}
+
+	mux.HandleFunc("GET /audit", middleware.AdminOnly(func(w http.ResponseWriter, r *http.Request) {
+		audit.GetHandler(w, r, logger, as)
+	}))
+	return as, nil
+}
+
+// * Table exports for analysts, e.g. to pull telemetry into spreadsheets
+func setupExportHandlers(mux *routeTable, sf *service.ServiceFactory, logger *slog.Logger) error {
+
+	es, err := sf.CreateExportService(service.SQLiteDataService)
+	if err != nil {
+		return err
+	}
+
+	mux.HandleExportFunc("GET /export/{table}", middleware.AdminOnly(func(w http.ResponseWriter, r *http.Request) {
+		export.GetHandler(w, r, logger, es)
+	}))
+	return nil
+}
+
+// * Table imports, e.g. history exported from the backend devices are migrated from
+func setupImportHandlers(mux *routeTable, sf *service.ServiceFactory, logger *slog.Logger, as *auditService.AuditServiceSQLite, hub *realtime.Hub) error {
+
+	is, err := sf.CreateImportService(service.SQLiteDataService)
+	if err != nil {
+		return err
+	}
+	// * Imported rows are audited and published like rows created through the API
+	is.Observe(as, hub)
+
+	mux.HandleImportFunc("POST /import/{table}", middleware.AdminOnly(func(w http.ResponseWriter, r *http.Request) {
+		importer.PostHandler(w, r, logger, is)
+	}))
+	return nil
+}
+
+// idempotencyStore adapts the idempotency service to the middleware
+type idempotencyStore struct {
+	service idempotencyService.IdempotencyService
+}
+
+func (s idempotencyStore) Begin(scope string, key string, requestHash string, ctx context.Context) (*middleware.IdempotentResponse, error) {
+	stored, err := s.service.Begin(scope, key, requestHash, ctx)
+	if err != nil || stored == nil {
+		return nil, err
+	}
+	return &middleware.IdempotentResponse{
+		RequestHash: stored.RequestHash,
+		StatusCode:  stored.StatusCode,
+		ContentType: stored.ContentType,
+		Body:        stored.Body,
+	}, nil
+}
+
+func (s idempotencyStore) Complete(scope string, key string, response *middleware.IdempotentResponse, ctx context.Context) error {
+	return s.service.Complete(&models.IdempotencyKey{
+		Scope:       scope,
+		Key:         key,
+		RequestHash: response.RequestHash,
+		StatusCode:  response.StatusCode,
+		ContentType: response.ContentType,
+		Body:        response.Body,
+	}, ctx)
+}
+
+func (s idempotencyStore) Release(scope string, key string, ctx context.Context) error {
+	return s.service.Release(scope, key, ctx)
+}
@@ -0,0 +1,272 @@
+package server_test
+
+import (
+	"context"
+	"encoding/base64"
+	"goapi/docs"
+	"goapi/internal/api/config"
+	"goapi/internal/api/health"
+	"goapi/internal/api/metrics"
+	"goapi/internal/api/openapi"
+	"goapi/internal/api/realtime"
+	"goapi/internal/api/repository/DAL/SQLite"
+	"goapi/internal/api/server"
+	"goapi/internal/api/service"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newTestServer creates a server with a temporary database and firmware directory, configure may change the defaults
+func newTestServer(t *testing.T, configure func(cfg *config.Config)) *server.Server {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	cfg := config.Default()
+	cfg.Database.DSN = filepath.Join(t.TempDir(), "test.db")
+	cfg.Firmware.Dir = t.TempDir()
+	if configure != nil {
+		configure(cfg)
+	}
+
+	db, err := SQLite.NewSqlite(cfg.Database.DSN, SQLite.PoolConfig{MaxOpenConns: 1, MaxIdleConns: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	sf := service.NewServiceFactory(db, logger, ctx, cfg)
+	return server.NewServer(ctx, cfg, sf, logger, metrics.New(""), health.New())
+}
+
+// TestRoutesMatchOpenAPI fails when a route is registered without being documented in docs/openapi.yaml, or the other way round
+func TestRoutesMatchOpenAPI(t *testing.T) {
+	api := newTestServer(t, nil)
+
+	spec, err := openapi.Load(docs.OpenAPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	documented := make(map[string]bool)
+	for _, operation := range spec.Operations() {
+		documented[operation] = true
+	}
+	registered := make(map[string]bool)
+	for _, route := range api.Routes() {
+		registered[route] = true
+		if !documented[route] {
+			t.Errorf("route %q is registered but missing from docs/openapi.yaml", route)
+		}
+	}
+	for operation := range documented {
+		if !registered[operation] {
+			t.Errorf("operation %q is documented in docs/openapi.yaml but not registered", operation)
+		}
+	}
+}
+
+func TestLegacyRoutes(t *testing.T) {
+	api := newTestServer(t, func(cfg *config.Config) { cfg.Server.LegacySunset = "2027-06-30" })
+
+	tests := []struct {
+		name        string
+		target      string
+		credentials bool
+		status      int
+		deprecated  bool
+	}{
+		{name: "Versioned route", target: "/v1/auth/lockouts", credentials: true, status: http.StatusOK},
+		{name: "Legacy route", target: "/auth/lockouts", credentials: true, status: http.StatusOK, deprecated: true},
+		{name: "Rejected legacy request", target: "/auth/lockouts", status: http.StatusUnauthorized, deprecated: true},
+		{name: "Unversioned root route", target: "/healthz", status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.Header.Set("Content-Type", "application/json")
+			if tt.credentials {
+				req.SetBasicAuth("admin", "password")
+			}
+			rr := httptest.NewRecorder()
+			api.HTTPServer.Handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.status {
+				t.Fatalf("got status %v, want %v", rr.Code, tt.status)
+			}
+			deprecation, sunset, link := rr.Header().Get("Deprecation"), rr.Header().Get("Sunset"), rr.Header().Get("Link")
+			if !tt.deprecated {
+				if deprecation != "" || sunset != "" || link != "" {
+					t.Errorf("unexpected deprecation headers: %q, %q, %q", deprecation, sunset, link)
+				}
+				return
+			}
+			if deprecation != "@1792281600" || sunset != "Wed, 30 Jun 2027 00:00:00 GMT" || link != `</v1/auth/lockouts>; rel="successor-version"` {
+				t.Errorf("unexpected deprecation headers: %q, %q, %q", deprecation, sunset, link)
+			}
+		})
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	api := newTestServer(t, func(cfg *config.Config) {
+		cfg.CORS.AllowedOrigins = []string{"https://dashboard.example.com"}
+		cfg.CORS.AllowedMethods = []string{http.MethodGet, http.MethodPost, http.MethodPatch}
+		cfg.CORS.AllowCredentials = true
+	})
+
+	tests := []struct {
+		name    string
+		target  string
+		origin  string
+		status  int
+		methods string
+	}{
+		{name: "Collection", target: "/v1/data", origin: "https://dashboard.example.com", status: http.StatusNoContent, methods: "GET, POST"},
+		{name: "Single resource", target: "/v1/data/1", origin: "https://dashboard.example.com", status: http.StatusNoContent, methods: "GET, PATCH"},
+		{name: "Legacy route", target: "/device/config", origin: "https://dashboard.example.com", status: http.StatusNoContent, methods: "GET, POST"},
+		{name: "Other origin", target: "/v1/data", origin: "https://evil.example.com", status: http.StatusForbidden},
+		{name: "Unknown path", target: "/v1/nothing", origin: "https://dashboard.example.com", status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// * Preflights carry neither credentials nor a Content-Type *
+			req := httptest.NewRequest(http.MethodOptions, tt.target, nil)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+			rr := httptest.NewRecorder()
+			api.HTTPServer.Handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.status {
+				t.Fatalf("got status %v, want %v", rr.Code, tt.status)
+			}
+			if got := rr.Header().Get("Access-Control-Allow-Methods"); got != tt.methods {
+				t.Errorf("got Access-Control-Allow-Methods %q, want %q", got, tt.methods)
+			}
+			if tt.status != http.StatusNoContent {
+				return
+			}
+			if rr.Header().Get("Access-Control-Allow-Origin") != tt.origin || rr.Header().Get("Access-Control-Allow-Credentials") != "true" {
+				t.Errorf("unexpected CORS headers: %v", rr.Header())
+			}
+		})
+	}
+}
+
+func TestCORSCredentialedRequest(t *testing.T) {
+	api := newTestServer(t, func(cfg *config.Config) {
+		cfg.CORS.AllowedOrigins = []string{"https://dashboard.example.com"}
+		cfg.CORS.AllowCredentials = true
+	})
+
+	for _, credentials := range []bool{true, false} {
+		req := httptest.NewRequest(http.MethodGet, "/v1/auth/lockouts", nil)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Origin", "https://dashboard.example.com")
+		if credentials {
+			req.SetBasicAuth("admin", "password")
+		}
+		rr := httptest.NewRecorder()
+		api.HTTPServer.Handler.ServeHTTP(rr, req)
+
+		// * Rejected requests carry the headers too, so the dashboard can read the 401 *
+		if rr.Header().Get("Access-Control-Allow-Origin") != "https://dashboard.example.com" || rr.Header().Get("Access-Control-Allow-Credentials") != "true" {
+			t.Errorf("credentials %v: unexpected CORS headers: %v", credentials, rr.Header())
+		}
+	}
+}
+
+func TestContentNegotiation(t *testing.T) {
+	api := newTestServer(t, nil)
+
+	tests := []struct {
+		name   string
+		target string
+		accept string
+		status int
+	}{
+		{name: "No headers", target: "/v1/auth/lockouts", status: http.StatusOK},
+		{name: "CSV table", target: "/v1/device/status?device_id=ESP32_MAZE_001", accept: "text/csv", status: http.StatusOK},
+		{name: "CSV of a JSON route", target: "/v1/auth/lockouts", accept: "text/csv", status: http.StatusNotAcceptable},
+		{name: "HTML", target: "/v1/device/status", accept: "text/html", status: http.StatusNotAcceptable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// * Like curl, without a Content-Type *
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.SetBasicAuth("admin", "password")
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rr := httptest.NewRecorder()
+			api.HTTPServer.Handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.status {
+				t.Fatalf("got status %v, want %v: %s", rr.Code, tt.status, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	api := newTestServer(t, nil)
+	ts := httptest.NewServer(api.HTTPServer.Handler)
+	t.Cleanup(ts.Close)
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/v1/ws"
+
+	// * Without credentials or an upgrade the request never reaches the hub *
+	if _, resp, err := websocket.DefaultDialer.Dial(url, nil); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without credentials, got %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/v1/ws", nil)
+	req.SetBasicAuth("admin", "password")
+	rr := httptest.NewRecorder()
+	api.HTTPServer.Handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUpgradeRequired || rr.Header().Get("Upgrade") != "websocket" {
+		t.Errorf("got status %v, want %v", rr.Code, http.StatusUpgradeRequired)
+	}
+
+	// * A status stored over REST is pushed to the subscribed connection through the whole middleware chain *
+	header := http.Header{}
+	header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("admin:password")))
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var reply realtime.Message
+	if err := conn.WriteJSON(realtime.Message{Type: realtime.MessageSubscribe, ID: "1", Topic: realtime.TopicStatus, DeviceID: "ESP32_MAZE_001"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.ReadJSON(&reply); err != nil || reply.Type != realtime.MessageAck {
+		t.Fatalf("Expected an ack, got %+v, %v", reply, err)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/device/status", strings.NewReader(`{"device_id": "ESP32_MAZE_001", "battery_level": 80, "timestamp": "2024-01-15T10:30:00Z"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth("admin", "password")
+	rr = httptest.NewRecorder()
+	api.HTTPServer.Handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("got status %v, want %v: %s", rr.Code, http.StatusCreated, rr.Body.String())
+	}
+
+	var event realtime.Message
+	if err := conn.ReadJSON(&event); err != nil || event.Type != realtime.MessageEvent || event.DeviceID != "ESP32_MAZE_001" {
+		t.Errorf("Expected a status event, got %+v, %v", event, err)
+	}
+}
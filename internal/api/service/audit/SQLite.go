@@ -0,0 +1,137 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/logging"
+	"goapi/internal/api/repository/models"
+	"log/slog"
+	"reflect"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+var tracer = otel.Tracer("goapi/internal/api/service/audit")
+
+// AuditServiceSQLite implements AuditService for SQLite
+type AuditServiceSQLite struct {
+	repo   models.AuditRepository
+	actor  func(ctx context.Context) string
+	logger *slog.Logger
+	now    func() time.Time
+}
+
+// NewAuditServiceSQLite creates the service, actor returns the caller of the request in ctx
+func NewAuditServiceSQLite(repo models.AuditRepository, actor func(ctx context.Context) string, logger *slog.Logger) *AuditServiceSQLite {
+	return &AuditServiceSQLite{
+		repo:   repo,
+		actor:  actor,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+// Record appends an entry for the change of a resource from before to after, either may be nil
+func (s *AuditServiceSQLite) Record(action string, resourceType string, resourceID string, before any, after any, ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "AuditService.Record")
+	defer span.End()
+
+	beforeJSON, afterJSON, changes, err := diff(before, after)
+	if err != nil {
+		return err
+	}
+	return s.repo.Create(&models.AuditEntry{
+		Actor:        s.actor(ctx),
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Before:       beforeJSON,
+		After:        afterJSON,
+		Changes:      changes,
+		RequestID:    logging.RequestID(ctx),
+		CreatedAt:    s.now().UTC().Format(time.RFC3339),
+	}, ctx)
+}
+
+// ReadMany returns the entries matching filter, newest first
+func (s *AuditServiceSQLite) ReadMany(filter *models.AuditFilter, page int, rowsPerPage int, ctx context.Context) ([]*models.AuditEntry, error) {
+	ctx, span := tracer.Start(ctx, "AuditService.ReadMany")
+	defer span.End()
+
+	switch filter.Action {
+	case "", models.AuditCreate, models.AuditUpdate, models.AuditDelete:
+	default:
+		return nil, AuditError{Message: "action must be create, update or delete."}
+	}
+	for _, value := range []*string{&filter.Since, &filter.Until} {
+		if *value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, *value)
+		if err != nil {
+			return nil, AuditError{Message: "since and until must be RFC3339 timestamps."}
+		}
+		// Entries are stored in UTC, compare in the same format
+		*value = t.UTC().Format(time.RFC3339)
+	}
+	return s.repo.ReadMany(filter, page, rowsPerPage, ctx)
+}
+
+// record is called by the service wrappers once a change succeeded. The change is already stored,
+// so failing to record it is logged instead of failing the request.
+func (s *AuditServiceSQLite) record(action string, resourceType string, resourceID string, before any, after any, ctx context.Context) {
+	if err := s.Record(action, resourceType, resourceID, before, after, ctx); err != nil {
+		s.logger.ErrorContext(ctx, "Error recording audit entry", "error", err,
+			"action", action, "resource_type", resourceType, "resource_id", resourceID, "actor", s.actor(ctx))
+	}
+}
+
+// diff returns before and after as JSON, and the top-level fields that differ as {"field": {"before": x, "after": y}}
+func diff(before any, after any) (json.RawMessage, json.RawMessage, json.RawMessage, error) {
+	beforeJSON, beforeFields, err := fields(before)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	afterJSON, afterFields, err := fields(after)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	type change struct {
+		Before any `json:"before"`
+		After  any `json:"after"`
+	}
+	changes := make(map[string]change)
+	for name, value := range beforeFields {
+		if !reflect.DeepEqual(value, afterFields[name]) {
+			changes[name] = change{Before: value, After: afterFields[name]}
+		}
+	}
+	for name, value := range afterFields {
+		if _, ok := beforeFields[name]; !ok {
+			changes[name] = change{After: value}
+		}
+	}
+	changesJSON, err := json.Marshal(changes)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return beforeJSON, afterJSON, changesJSON, nil
+}
+
+// fields marshals v and decodes it into its top-level fields, nil becomes null without fields
+func fields(v any) (json.RawMessage, map[string]any, error) {
+	if v == nil || reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil() {
+		return json.RawMessage("null"), nil, nil
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, nil, err
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return nil, nil, err
+	}
+	return encoded, decoded, nil
+}
@@ -0,0 +1,154 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"goapi/internal/api/repository/models"
+	"log/slog"
+	"testing"
+)
+
+// In-memory repository, enough to inspect the recorded entries
+type memoryAudit struct {
+	entries []*models.AuditEntry
+	filter  *models.AuditFilter
+}
+
+func (m *memoryAudit) Create(entry *models.AuditEntry, ctx context.Context) error {
+	entry.ID = len(m.entries) + 1
+	m.entries = append(m.entries, entry)
+	return nil
+}
+
+func (m *memoryAudit) ReadMany(filter *models.AuditFilter, page int, rowsPerPage int, ctx context.Context) ([]*models.AuditEntry, error) {
+	m.filter = filter
+	return m.entries, nil
+}
+
+// In-memory device config service, configs are keyed by ID
+type memoryConfigs struct {
+	configs map[int]models.DeviceConfig
+}
+
+func (m *memoryConfigs) Create(config *models.DeviceConfig, ctx context.Context) error {
+	config.ID = len(m.configs) + 1
+	m.configs[config.ID] = *config
+	return nil
+}
+
+func (m *memoryConfigs) ReadOne(id int, ctx context.Context) (*models.DeviceConfig, error) {
+	config, ok := m.configs[id]
+	if !ok {
+		return nil, nil
+	}
+	return &config, nil
+}
+
+func (m *memoryConfigs) ReadByDeviceID(deviceID string, ctx context.Context) (*models.DeviceConfig, error) {
+	return nil, nil
+}
+
+func (m *memoryConfigs) ReadMany(page int, rowsPerPage int, ctx context.Context) models.Rows[models.DeviceConfig] {
+	return models.RowsOf[models.DeviceConfig](nil, nil)
+}
+
+func (m *memoryConfigs) Update(config *models.DeviceConfig, ctx context.Context) (int64, error) {
+	if _, ok := m.configs[config.ID]; !ok {
+		return 0, nil
+	}
+	m.configs[config.ID] = *config
+	return 1, nil
+}
+
+func (m *memoryConfigs) Delete(config *models.DeviceConfig, ctx context.Context) (int64, error) {
+	if _, ok := m.configs[config.ID]; !ok {
+		return 0, nil
+	}
+	delete(m.configs, config.ID)
+	return 1, nil
+}
+
+func (m *memoryConfigs) ValidateConfig(config *models.DeviceConfig) error {
+	return nil
+}
+
+func newTestService() (*AuditServiceSQLite, *memoryAudit) {
+	repo := &memoryAudit{}
+	actor := func(ctx context.Context) string { return "admin" }
+	return NewAuditServiceSQLite(repo, actor, slog.Default()), repo
+}
+
+func TestAuditDeviceConfigService(t *testing.T) {
+	service, repo := newTestService()
+	configs := service.AuditDeviceConfigService(&memoryConfigs{configs: map[int]models.DeviceConfig{}})
+	ctx := context.Background()
+
+	config := &models.DeviceConfig{DeviceID: "ESP32_001", AlarmTimeout: 300, SensitivityLevel: 5}
+	if err := configs.Create(config, ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	updated := *config
+	updated.SensitivityLevel = 9
+	if _, err := configs.Update(&updated, ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := configs.Delete(&models.DeviceConfig{ID: config.ID}, ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Nothing was deleted, so nothing is recorded
+	if _, err := configs.Delete(&models.DeviceConfig{ID: 42}, ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(repo.entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(repo.entries))
+	}
+	for i, action := range []string{models.AuditCreate, models.AuditUpdate, models.AuditDelete} {
+		entry := repo.entries[i]
+		if entry.Action != action || entry.Actor != "admin" || entry.ResourceType != ResourceDeviceConfig || entry.ResourceID != "1" {
+			t.Errorf("Unexpected entry %d: %+v", i, entry)
+		}
+	}
+
+	var changes map[string]struct{ Before, After any }
+	if err := json.Unmarshal(repo.entries[1].Changes, &changes); err != nil {
+		t.Fatalf("Invalid changes JSON: %v", err)
+	}
+	if len(changes) != 1 || changes["sensitivity_level"].Before != 5.0 || changes["sensitivity_level"].After != 9.0 {
+		t.Errorf("Expected only sensitivity_level to change from 5 to 9, got %s", repo.entries[1].Changes)
+	}
+	if string(repo.entries[0].Before) != "null" || string(repo.entries[2].After) != "null" {
+		t.Errorf("Expected null before a create and after a delete, got %s and %s", repo.entries[0].Before, repo.entries[2].After)
+	}
+}
+
+func TestReadManyFilters(t *testing.T) {
+	service, repo := newTestService()
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		filter      models.AuditFilter
+		expectError bool
+	}{
+		{name: "No filter", filter: models.AuditFilter{}},
+		{name: "Known action", filter: models.AuditFilter{Action: models.AuditDelete}},
+		{name: "Unknown action", filter: models.AuditFilter{Action: "read"}, expectError: true},
+		{name: "Invalid since", filter: models.AuditFilter{Since: "yesterday"}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.ReadMany(&tt.filter, 1, 10, ctx)
+			if _, ok := err.(AuditError); ok != tt.expectError {
+				t.Errorf("Expected AuditError: %v, got %v", tt.expectError, err)
+			}
+		})
+	}
+
+	// Timestamps are compared in UTC
+	service.ReadMany(&models.AuditFilter{Since: "2024-01-15T12:00:00+02:00"}, 1, 10, ctx)
+	if repo.filter.Since != "2024-01-15T10:00:00Z" {
+		t.Errorf("Expected since in UTC, got %s", repo.filter.Since)
+	}
+}
@@ -0,0 +1,21 @@
+package audit
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+)
+
+// AuditService records changes to resources and lists them
+type AuditService interface {
+	Record(action string, resourceType string, resourceID string, before any, after any, ctx context.Context) error
+	ReadMany(filter *models.AuditFilter, page int, rowsPerPage int, ctx context.Context) ([]*models.AuditEntry, error)
+}
+
+// AuditError represents a business logic error
+type AuditError struct {
+	Message string
+}
+
+func (e AuditError) Error() string {
+	return e.Message
+}
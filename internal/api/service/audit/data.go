@@ -0,0 +1,69 @@
+package audit
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"goapi/internal/api/service/data"
+	"strconv"
+)
+
+// ResourceData is the resource type of entries recorded for /data
+const ResourceData = "data"
+
+type dataService struct {
+	data.DataService
+	audit *AuditServiceSQLite
+}
+
+// AuditDataService wraps service so that every create, update and delete is recorded
+func (s *AuditServiceSQLite) AuditDataService(service data.DataService) data.DataService {
+	return &dataService{DataService: service, audit: s}
+}
+
+func (s *dataService) Create(d *models.Data, ctx context.Context) error {
+	if err := s.DataService.Create(d, ctx); err != nil {
+		return err
+	}
+	s.audit.record(models.AuditCreate, ResourceData, strconv.Itoa(d.ID), nil, d, ctx)
+	return nil
+}
+
+// CreateBatch records the data the batch stored, duplicates and rejected data change nothing
+func (s *dataService) CreateBatch(data []*models.Data, ctx context.Context) ([]models.BatchResult, error) {
+	results, err := s.DataService.CreateBatch(data, ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, result := range results {
+		if result.Status == models.BatchAccepted {
+			s.audit.record(models.AuditCreate, ResourceData, strconv.Itoa(result.ID), nil, data[result.Index], ctx)
+		}
+	}
+	return results, nil
+}
+
+func (s *dataService) Update(d *models.Data, ctx context.Context) (int64, error) {
+	before, err := s.DataService.ReadOne(d.ID, ctx)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := s.DataService.Update(d, ctx)
+	if err != nil || rowsAffected == 0 {
+		return rowsAffected, err
+	}
+	s.audit.record(models.AuditUpdate, ResourceData, strconv.Itoa(d.ID), before, d, ctx)
+	return rowsAffected, nil
+}
+
+func (s *dataService) Delete(d *models.Data, ctx context.Context) (int64, error) {
+	before, err := s.DataService.ReadOne(d.ID, ctx)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := s.DataService.Delete(d, ctx)
+	if err != nil || rowsAffected == 0 {
+		return rowsAffected, err
+	}
+	s.audit.record(models.AuditDelete, ResourceData, strconv.Itoa(d.ID), before, nil, ctx)
+	return rowsAffected, nil
+}
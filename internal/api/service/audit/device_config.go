@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"goapi/internal/api/service/device_config"
+	"strconv"
+)
+
+// ResourceDeviceConfig is the resource type of entries recorded for /device/config
+const ResourceDeviceConfig = "device_config"
+
+type deviceConfigService struct {
+	device_config.DeviceConfigService
+	audit *AuditServiceSQLite
+}
+
+// AuditDeviceConfigService wraps service so that every create, update and delete is recorded
+func (s *AuditServiceSQLite) AuditDeviceConfigService(service device_config.DeviceConfigService) device_config.DeviceConfigService {
+	return &deviceConfigService{DeviceConfigService: service, audit: s}
+}
+
+func (s *deviceConfigService) Create(config *models.DeviceConfig, ctx context.Context) error {
+	if err := s.DeviceConfigService.Create(config, ctx); err != nil {
+		return err
+	}
+	s.audit.record(models.AuditCreate, ResourceDeviceConfig, strconv.Itoa(config.ID), nil, config, ctx)
+	return nil
+}
+
+func (s *deviceConfigService) Update(config *models.DeviceConfig, ctx context.Context) (int64, error) {
+	before, err := s.DeviceConfigService.ReadOne(config.ID, ctx)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := s.DeviceConfigService.Update(config, ctx)
+	if err != nil || rowsAffected == 0 {
+		return rowsAffected, err
+	}
+	s.audit.record(models.AuditUpdate, ResourceDeviceConfig, strconv.Itoa(config.ID), before, config, ctx)
+	return rowsAffected, nil
+}
+
+func (s *deviceConfigService) Delete(config *models.DeviceConfig, ctx context.Context) (int64, error) {
+	before, err := s.DeviceConfigService.ReadOne(config.ID, ctx)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := s.DeviceConfigService.Delete(config, ctx)
+	if err != nil || rowsAffected == 0 {
+		return rowsAffected, err
+	}
+	s.audit.record(models.AuditDelete, ResourceDeviceConfig, strconv.Itoa(config.ID), before, nil, ctx)
+	return rowsAffected, nil
+}
@@ -0,0 +1,28 @@
+package audit
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"strconv"
+)
+
+// StatusesImported records the statuses an import stored like statuses created through the API
+func (s *AuditServiceSQLite) StatusesImported(statuses []*models.MazeDeviceStatus, ctx context.Context) {
+	for _, status := range statuses {
+		s.record(models.AuditCreate, ResourceMazeDeviceStatus, strconv.Itoa(status.ID), nil, status, ctx)
+	}
+}
+
+// ConfigsImported records the configs an import stored like configs created through the API
+func (s *AuditServiceSQLite) ConfigsImported(configs []*models.DeviceConfig, ctx context.Context) {
+	for _, config := range configs {
+		s.record(models.AuditCreate, ResourceDeviceConfig, strconv.Itoa(config.ID), nil, config, ctx)
+	}
+}
+
+// DataImported records the data an import stored like data created through the API
+func (s *AuditServiceSQLite) DataImported(data []*models.Data, ctx context.Context) {
+	for _, d := range data {
+		s.record(models.AuditCreate, ResourceData, strconv.Itoa(d.ID), nil, d, ctx)
+	}
+}
@@ -0,0 +1,69 @@
+package audit
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"goapi/internal/api/service/maze_device"
+	"strconv"
+)
+
+// ResourceMazeDeviceStatus is the resource type of entries recorded for /device/status
+const ResourceMazeDeviceStatus = "maze_device_status"
+
+type mazeDeviceStatusService struct {
+	maze_device.MazeDeviceStatusService
+	audit *AuditServiceSQLite
+}
+
+// AuditMazeDeviceStatusService wraps service so that every create, update and delete is recorded
+func (s *AuditServiceSQLite) AuditMazeDeviceStatusService(service maze_device.MazeDeviceStatusService) maze_device.MazeDeviceStatusService {
+	return &mazeDeviceStatusService{MazeDeviceStatusService: service, audit: s}
+}
+
+func (s *mazeDeviceStatusService) Create(status *models.MazeDeviceStatus, ctx context.Context) error {
+	if err := s.MazeDeviceStatusService.Create(status, ctx); err != nil {
+		return err
+	}
+	s.audit.record(models.AuditCreate, ResourceMazeDeviceStatus, strconv.Itoa(status.ID), nil, status, ctx)
+	return nil
+}
+
+// CreateBatch records the statuses the batch stored, duplicates and rejected statuses change nothing
+func (s *mazeDeviceStatusService) CreateBatch(statuses []*models.MazeDeviceStatus, ctx context.Context) ([]models.BatchResult, error) {
+	results, err := s.MazeDeviceStatusService.CreateBatch(statuses, ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, result := range results {
+		if result.Status == models.BatchAccepted {
+			s.audit.record(models.AuditCreate, ResourceMazeDeviceStatus, strconv.Itoa(result.ID), nil, statuses[result.Index], ctx)
+		}
+	}
+	return results, nil
+}
+
+func (s *mazeDeviceStatusService) Update(status *models.MazeDeviceStatus, ctx context.Context) (int64, error) {
+	before, err := s.MazeDeviceStatusService.ReadOne(status.ID, ctx)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := s.MazeDeviceStatusService.Update(status, ctx)
+	if err != nil || rowsAffected == 0 {
+		return rowsAffected, err
+	}
+	s.audit.record(models.AuditUpdate, ResourceMazeDeviceStatus, strconv.Itoa(status.ID), before, status, ctx)
+	return rowsAffected, nil
+}
+
+func (s *mazeDeviceStatusService) Delete(status *models.MazeDeviceStatus, ctx context.Context) (int64, error) {
+	before, err := s.MazeDeviceStatusService.ReadOne(status.ID, ctx)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := s.MazeDeviceStatusService.Delete(status, ctx)
+	if err != nil || rowsAffected == 0 {
+		return rowsAffected, err
+	}
+	s.audit.record(models.AuditDelete, ResourceMazeDeviceStatus, strconv.Itoa(status.ID), before, nil, ctx)
+	return rowsAffected, nil
+}
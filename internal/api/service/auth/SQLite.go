@@ -0,0 +1,267 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"goapi/internal/api/repository/models"
+	"goapi/internal/api/service/lockout"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.opentelemetry.io/otel"
+)
+
+var tracer = otel.Tracer("goapi/internal/api/service/auth")
+
+// issuer is the iss claim of every access token, tokens of other issuers are rejected
+const issuer = "goapi"
+
+// Policy configures the issued tokens
+type Policy struct {
+	Secret     []byte        // HMAC key signing access tokens
+	AccessTTL  time.Duration // Lifetime of an access token
+	RefreshTTL time.Duration // Lifetime of a refresh token
+}
+
+// AuthServiceSQLite implements AuthService, access tokens are stateless JWTs and refresh tokens are stored in SQLite
+type AuthServiceSQLite struct {
+	tokens     models.RefreshTokenRepository
+	lockouts   lockout.LockoutService
+	validators []CredentialValidator
+	lookups    []CredentialLookup
+	policy     Policy
+	now        func() time.Time
+}
+
+// accessClaims are the claims of an access token, sub is the username
+type accessClaims struct {
+	DeviceID string `json:"device_id,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// NewAuthServiceSQLite creates the service. Logins are checked against validators in order,
+// and count towards the lockouts of lockouts unless it is nil. Refreshing looks the account up again with lookups,
+// so tokens stop working once its credentials changed or were removed.
+func NewAuthServiceSQLite(tokens models.RefreshTokenRepository, lockouts lockout.LockoutService, policy Policy, lookups []CredentialLookup, validators ...CredentialValidator) *AuthServiceSQLite {
+	return &AuthServiceSQLite{
+		tokens:     tokens,
+		lockouts:   lockouts,
+		validators: validators,
+		lookups:    lookups,
+		policy:     policy,
+		now:        time.Now,
+	}
+}
+
+// Login checks the credentials and issues a new pair of tokens
+func (s *AuthServiceSQLite) Login(username string, password string, ip string, ctx context.Context) (*TokenPair, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.Login")
+	defer span.End()
+
+	if s.lockouts != nil {
+		if retryAfter, locked := s.lockouts.Locked(username, ip); locked {
+			return nil, AuthError{Message: "Too many failed logins.", RetryAfter: retryAfter}
+		}
+	}
+
+	deviceID, ok := s.authenticate(username, password, ctx)
+	if !ok {
+		if s.lockouts != nil {
+			lockout.Wait(ctx, s.lockouts.Failed(username, ip, ctx))
+		}
+		return nil, AuthError{Message: "Invalid credentials."}
+	}
+	if s.lockouts != nil {
+		s.lockouts.Succeeded(username, ip)
+	}
+
+	_, credential, _ := s.credential(username, ctx)
+	pair, _, err := s.newPair(username, deviceID, credential, ctx)
+	return pair, err
+}
+
+func (s *AuthServiceSQLite) authenticate(username string, password string, ctx context.Context) (string, bool) {
+	for _, validate := range s.validators {
+		if deviceID, ok := validate(username, password, ctx); ok {
+			return deviceID, true
+		}
+	}
+	return "", false
+}
+
+// credential looks up the current credentials of username and returns their MAC, which is stored with refresh tokens
+// instead of the credentials themselves
+func (s *AuthServiceSQLite) credential(username string, ctx context.Context) (string, string, bool) {
+	for _, lookup := range s.lookups {
+		if deviceID, credential, ok := lookup(username, ctx); ok {
+			mac := hmac.New(sha256.New, s.policy.Secret)
+			mac.Write([]byte(username + "\x00" + deviceID + "\x00" + credential))
+			return deviceID, hex.EncodeToString(mac.Sum(nil)), true
+		}
+	}
+	return "", "", false
+}
+
+// Refresh rotates the refresh token: it is revoked and a new pair of tokens is issued.
+// Presenting a revoked token means it was stolen or replayed, so every token of its account is revoked.
+// A token whose account was removed or changed its password since, e.g. a device provisioned again, is rejected.
+func (s *AuthServiceSQLite) Refresh(refreshToken string, ctx context.Context) (*TokenPair, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.Refresh")
+	defer span.End()
+
+	token, err := s.tokens.ReadByHash(hashToken(refreshToken), ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now().UTC()
+	if token == nil || !parseTime(token.ExpiresAt).After(now) {
+		return nil, AuthError{Message: "Invalid or expired refresh token."}
+	}
+	if token.RevokedAt != "" {
+		if _, err := s.tokens.RevokeByUsername(token.Username, now.Format(time.RFC3339), ctx); err != nil {
+			return nil, err
+		}
+		return nil, AuthError{Message: "Invalid or expired refresh token."}
+	}
+	deviceID, credential, ok := s.credential(token.Username, ctx)
+	if !ok || deviceID != token.DeviceID || !hmac.Equal([]byte(credential), []byte(token.Credential)) {
+		return nil, AuthError{Message: "Invalid or expired refresh token."}
+	}
+
+	pair, replacement, err := s.newPair(token.Username, token.DeviceID, token.Credential, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token.RevokedAt = now.Format(time.RFC3339)
+	token.ReplacedBy = replacement.ID
+	revoked, err := s.tokens.Revoke(token, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if revoked == 0 {
+		// A concurrent refresh won the race, drop the pair issued here
+		replacement.RevokedAt = token.RevokedAt
+		if _, err := s.tokens.Revoke(replacement, ctx); err != nil {
+			return nil, err
+		}
+		return nil, AuthError{Message: "Invalid or expired refresh token."}
+	}
+	return pair, nil
+}
+
+// Logout revokes the refresh token, access tokens issued with it stay valid until they expire
+func (s *AuthServiceSQLite) Logout(refreshToken string, ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "AuthService.Logout")
+	defer span.End()
+
+	token, err := s.tokens.ReadByHash(hashToken(refreshToken), ctx)
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return AuthError{Message: "Invalid refresh token."}
+	}
+	// Logging out twice is not an error
+	token.RevokedAt = s.now().UTC().Format(time.RFC3339)
+	_, err = s.tokens.Revoke(token, ctx)
+	return err
+}
+
+// Verify checks the signature, issuer and expiry of an access token and returns its claims
+func (s *AuthServiceSQLite) Verify(accessToken string, ctx context.Context) (*Claims, error) {
+	var claims accessClaims
+	_, err := jwt.ParseWithClaims(accessToken, &claims, func(t *jwt.Token) (any, error) {
+		return s.policy.Secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(s.now),
+	)
+	if err != nil || claims.Subject == "" {
+		return nil, AuthError{Message: "Invalid or expired token."}
+	}
+	return &Claims{Username: claims.Subject, DeviceID: claims.DeviceID}, nil
+}
+
+// PurgeExpiredTokens deletes refresh tokens that have expired
+func (s *AuthServiceSQLite) PurgeExpiredTokens(ctx context.Context) (int64, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.PurgeExpiredTokens")
+	defer span.End()
+
+	return s.tokens.DeleteExpired(s.now().UTC().Format(time.RFC3339), ctx)
+}
+
+// newPair signs an access token and stores the hash of a new refresh token, bound to the MAC of the credentials
+func (s *AuthServiceSQLite) newPair(username string, deviceID string, credential string, ctx context.Context) (*TokenPair, *models.RefreshToken, error) {
+	now := s.now().UTC()
+
+	jti, err := randomToken(16)
+	if err != nil {
+		return nil, nil, err
+	}
+	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims{
+		DeviceID: deviceID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Subject:   username,
+			ID:        jti,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.policy.AccessTTL)),
+		},
+	}).SignedString(s.policy.Secret)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return nil, nil, err
+	}
+	stored := &models.RefreshToken{
+		TokenHash:  hashToken(refreshToken),
+		Username:   username,
+		DeviceID:   deviceID,
+		Credential: credential,
+		ExpiresAt:  now.Add(s.policy.RefreshTTL).Format(time.RFC3339),
+		CreatedAt:  now.Format(time.RFC3339),
+	}
+	if err := s.tokens.Create(stored, ctx); err != nil {
+		return nil, nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.policy.AccessTTL.Seconds()),
+		RefreshToken: refreshToken,
+	}, stored, nil
+}
+
+func randomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashToken is stored instead of the refresh token, so a leaked database cannot be used to log in
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseTime parses an RFC3339 timestamp, unparsable timestamps count as long expired
+func parseTime(value string) time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,294 @@
+package auth
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// In-memory repository, enough to exercise rotation and revocation without a database
+type memoryTokens struct {
+	tokens []*models.RefreshToken
+}
+
+func (m *memoryTokens) Create(token *models.RefreshToken, ctx context.Context) error {
+	token.ID = len(m.tokens) + 1
+	stored := *token
+	m.tokens = append(m.tokens, &stored)
+	return nil
+}
+
+func (m *memoryTokens) ReadByHash(tokenHash string, ctx context.Context) (*models.RefreshToken, error) {
+	for _, t := range m.tokens {
+		if t.TokenHash == tokenHash {
+			found := *t
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryTokens) Revoke(token *models.RefreshToken, ctx context.Context) (int64, error) {
+	for _, t := range m.tokens {
+		if t.ID == token.ID && t.RevokedAt == "" {
+			t.RevokedAt, t.ReplacedBy = token.RevokedAt, token.ReplacedBy
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (m *memoryTokens) RevokeByUsername(username string, revokedAt string, ctx context.Context) (int64, error) {
+	var revoked int64
+	for _, t := range m.tokens {
+		if t.Username == username && t.RevokedAt == "" {
+			t.RevokedAt = revokedAt
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+func (m *memoryTokens) DeleteExpired(before string, ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+// Fake lockout service counting failed logins
+type countingLockouts struct {
+	failed int
+	locked bool
+}
+
+func (l *countingLockouts) Locked(username string, ip string) (time.Duration, bool) {
+	return time.Minute, l.locked
+}
+
+func (l *countingLockouts) Failed(username string, ip string, ctx context.Context) time.Duration {
+	l.failed++
+	return 0
+}
+
+func (l *countingLockouts) Succeeded(username string, ip string) {}
+
+func (l *countingLockouts) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.AuthLockout, error) {
+	return nil, nil
+}
+
+var testPolicy = Policy{Secret: []byte(strings.Repeat("k", 32)), AccessTTL: 15 * time.Minute, RefreshTTL: time.Hour}
+
+func newTestService() (*AuthServiceSQLite, *memoryTokens, *countingLockouts) {
+	repo := &memoryTokens{}
+	lockouts := &countingLockouts{}
+	return newServiceWith(repo, lockouts, "password", map[string]string{"ESP32_MAZE_002": "secret"}), repo, lockouts
+}
+
+// newServiceWith creates a service for the admin with adminPassword and the devices mapped to their passwords,
+// tests change them to model a restart with a new admin password or a device provisioned again
+func newServiceWith(repo *memoryTokens, lockouts *countingLockouts, adminPassword string, devices map[string]string) *AuthServiceSQLite {
+	admin := func(username, password string, ctx context.Context) (string, bool) {
+		return "", username == "admin" && password == adminPassword
+	}
+	device := func(username, password string, ctx context.Context) (string, bool) {
+		secret, ok := devices[username]
+		return username, ok && password == secret
+	}
+	deviceCredential := func(username string, ctx context.Context) (string, string, bool) {
+		secret, ok := devices[username]
+		return username, secret, ok
+	}
+	return NewAuthServiceSQLite(repo, lockouts, testPolicy, []CredentialLookup{UserCredential("admin", adminPassword), deviceCredential}, admin, device)
+}
+
+func TestLogin(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name               string
+		username, password string
+		expectedDeviceID   string
+		expectError        bool
+	}{
+		{name: "Admin", username: "admin", password: "password"},
+		{name: "Device", username: "ESP32_MAZE_002", password: "secret", expectedDeviceID: "ESP32_MAZE_002"},
+		{name: "Wrong password", username: "admin", password: "wrong", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, repo, lockouts := newTestService()
+
+			pair, err := service.Login(tt.username, tt.password, "192.0.2.1", ctx)
+			if tt.expectError {
+				if _, ok := err.(AuthError); !ok {
+					t.Fatalf("Expected AuthError, got %v", err)
+				}
+				if lockouts.failed != 1 {
+					t.Errorf("Expected the failure to be counted, got %d", lockouts.failed)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			claims, err := service.Verify(pair.AccessToken, ctx)
+			if err != nil {
+				t.Fatalf("Issued access token does not verify: %v", err)
+			}
+			if claims.Username != tt.username || claims.DeviceID != tt.expectedDeviceID {
+				t.Errorf("Unexpected claims %+v", claims)
+			}
+			if pair.TokenType != "Bearer" || pair.ExpiresIn != 900 {
+				t.Errorf("Unexpected token pair %+v", pair)
+			}
+			if len(repo.tokens) != 1 || repo.tokens[0].TokenHash == pair.RefreshToken {
+				t.Error("Expected the refresh token to be stored hashed")
+			}
+		})
+	}
+}
+
+func TestLoginLockedOut(t *testing.T) {
+	service, repo, lockouts := newTestService()
+	lockouts.locked = true
+
+	_, err := service.Login("admin", "password", "192.0.2.1", context.Background())
+	if authErr, ok := err.(AuthError); !ok || authErr.RetryAfter != time.Minute {
+		t.Errorf("Expected AuthError with RetryAfter, got %v", err)
+	}
+	if len(repo.tokens) != 0 {
+		t.Error("No tokens should be issued while locked out")
+	}
+}
+
+func TestRefreshRotates(t *testing.T) {
+	service, repo, _ := newTestService()
+	ctx := context.Background()
+
+	first, _ := service.Login("admin", "password", "192.0.2.1", ctx)
+	second, err := service.Refresh(first.RefreshToken, ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Error("Expected a new refresh token")
+	}
+	if repo.tokens[0].RevokedAt == "" || repo.tokens[0].ReplacedBy != repo.tokens[1].ID {
+		t.Errorf("Expected the first token to be replaced by the second, got %+v", repo.tokens[0])
+	}
+
+	// Replaying the rotated token revokes the whole account
+	if _, err := service.Refresh(first.RefreshToken, ctx); err == nil {
+		t.Fatal("Expected a replayed refresh token to be rejected")
+	}
+	if _, err := service.Refresh(second.RefreshToken, ctx); err == nil {
+		t.Error("Expected the replay to revoke the current refresh token too")
+	}
+}
+
+func TestRefreshRejectsChangedCredentials(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Admin password changed", func(t *testing.T) {
+		repo, lockouts := &memoryTokens{}, &countingLockouts{}
+		service := newServiceWith(repo, lockouts, "password", nil)
+		pair, _ := service.Login("admin", "password", "192.0.2.1", ctx)
+
+		// Restarted with a new password
+		service = newServiceWith(repo, lockouts, "changed", nil)
+		if _, err := service.Refresh(pair.RefreshToken, ctx); err == nil {
+			t.Fatal("Expected the refresh token of the old password to be rejected")
+		}
+		pair, err := service.Login("admin", "changed", "192.0.2.1", ctx)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := service.Refresh(pair.RefreshToken, ctx); err != nil {
+			t.Errorf("Expected the refresh token of the new password to work, got %v", err)
+		}
+	})
+
+	t.Run("Device provisioned again", func(t *testing.T) {
+		devices := map[string]string{"ESP32_MAZE_002": "secret"}
+		service := newServiceWith(&memoryTokens{}, &countingLockouts{}, "password", devices)
+		pair, _ := service.Login("ESP32_MAZE_002", "secret", "192.0.2.1", ctx)
+		pair, err := service.Refresh(pair.RefreshToken, ctx)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		devices["ESP32_MAZE_002"] = "new secret"
+		if _, err := service.Refresh(pair.RefreshToken, ctx); err == nil {
+			t.Error("Expected the refresh token of the old credentials to be rejected")
+		}
+	})
+
+	t.Run("Device credential removed", func(t *testing.T) {
+		devices := map[string]string{"ESP32_MAZE_002": "secret"}
+		service := newServiceWith(&memoryTokens{}, &countingLockouts{}, "password", devices)
+		pair, _ := service.Login("ESP32_MAZE_002", "secret", "192.0.2.1", ctx)
+
+		delete(devices, "ESP32_MAZE_002")
+		if _, err := service.Refresh(pair.RefreshToken, ctx); err == nil {
+			t.Error("Expected the refresh token of a removed device to be rejected")
+		}
+	})
+}
+
+func TestRefreshExpired(t *testing.T) {
+	service, _, _ := newTestService()
+	ctx := context.Background()
+
+	pair, _ := service.Login("admin", "password", "192.0.2.1", ctx)
+	service.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+
+	if _, err := service.Refresh(pair.RefreshToken, ctx); err == nil {
+		t.Error("Expected an expired refresh token to be rejected")
+	}
+	if _, err := service.Verify(pair.AccessToken, ctx); err == nil {
+		t.Error("Expected an expired access token to be rejected")
+	}
+}
+
+func TestLogoutRevokes(t *testing.T) {
+	service, _, _ := newTestService()
+	ctx := context.Background()
+
+	pair, _ := service.Login("admin", "password", "192.0.2.1", ctx)
+	if err := service.Logout(pair.RefreshToken, ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := service.Logout(pair.RefreshToken, ctx); err != nil {
+		t.Errorf("Logging out twice should not fail, got %v", err)
+	}
+	if err := service.Logout("unknown", ctx); err == nil {
+		t.Error("Expected an unknown refresh token to be rejected")
+	}
+}
+
+func TestVerifyRejectsForgedTokens(t *testing.T) {
+	service, _, _ := newTestService()
+	ctx := context.Background()
+
+	now := time.Now()
+	claims := accessClaims{RegisteredClaims: jwt.RegisteredClaims{
+		Issuer:    issuer,
+		Subject:   "admin",
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+	}}
+
+	otherKey, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(strings.Repeat("x", 32)))
+	unsigned, _ := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	claims.Issuer = "someone-else"
+	otherIssuer, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(testPolicy.Secret)
+
+	for name, token := range map[string]string{"other key": otherKey, "alg none": unsigned, "other issuer": otherIssuer, "garbage": "a.b.c"} {
+		if _, err := service.Verify(token, ctx); err == nil {
+			t.Errorf("Expected token signed with %s to be rejected", name)
+		}
+	}
+}
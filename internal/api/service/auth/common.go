@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"context"
+	"time"
+)
+
+// AuthService defines the interface for token-based authentication
+type AuthService interface {
+	Login(username string, password string, ip string, ctx context.Context) (*TokenPair, error)
+	Refresh(refreshToken string, ctx context.Context) (*TokenPair, error)
+	Logout(refreshToken string, ctx context.Context) error
+	Verify(accessToken string, ctx context.Context) (*Claims, error)
+	PurgeExpiredTokens(ctx context.Context) (int64, error)
+}
+
+// CredentialValidator checks a username and password.
+// On success it returns the ID of the device the credentials were issued to, or "" for a user account.
+type CredentialValidator func(username string, password string, ctx context.Context) (deviceID string, ok bool)
+
+// CredentialLookup returns the current credentials of username, ok is false when it has none.
+// credential is any value that changes whenever the password does, e.g. its hash, it is only compared.
+type CredentialLookup func(username string, ctx context.Context) (deviceID string, credential string, ok bool)
+
+// UserCredential looks up a single user account, e.g. the configured admin, its credential is the password
+func UserCredential(username string, password string) CredentialLookup {
+	return func(u string, ctx context.Context) (string, string, bool) {
+		if u != username {
+			return "", "", false
+		}
+		return "", password, true
+	}
+}
+
+// LoginRequest is sent to exchange credentials for tokens
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// RefreshRequest is sent to exchange a refresh token for new tokens, or to revoke it on logout
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// TokenPair is returned on login and refresh, the refresh token replaces the one sent
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"` // Always "Bearer"
+	ExpiresIn    int    `json:"expires_in"` // Lifetime of the access token in seconds
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Claims identify the caller of an access token
+type Claims struct {
+	Username string
+	DeviceID string // Set when the token was issued to a provisioned device
+}
+
+// AuthError represents rejected credentials or tokens
+type AuthError struct {
+	Message    string
+	RetryAfter time.Duration // Set when the login is locked out after repeated failures
+}
+
+func (e AuthError) Error() string {
+	return e.Message
+}
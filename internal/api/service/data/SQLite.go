@@ -3,9 +3,14 @@ package data
 import (
 	"context"
 	"goapi/internal/api/repository/models"
+	"strconv"
 	"time"
+
+	"go.opentelemetry.io/otel"
 )
 
+var tracer = otel.Tracer("goapi/internal/api/service/data")
+
 // * Implementation of DataService for SQLite database *
 type DataServiceSQLite struct {
 	repo models.DataRepository
@@ -18,14 +23,59 @@ func NewDataServiceSQLite(repo models.DataRepository) *DataServiceSQLite {
 }
 
 func (ds *DataServiceSQLite) Create(data *models.Data, ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "DataService.Create")
+	defer span.End()
 
 	if err := ds.ValidateData(data); err != nil {
-		return DataError{Message: "InvalMockDataServiceSuccessfulid data."}
+		return err
 	}
 	return ds.repo.Create(data, ctx)
 }
 
+// * Validates every item and stores the valid ones in one transaction, data already stored for the same device and date_time are reported as duplicates *
+func (ds *DataServiceSQLite) CreateBatch(data []*models.Data, ctx context.Context) ([]models.BatchResult, error) {
+	ctx, span := tracer.Start(ctx, "DataService.CreateBatch")
+	defer span.End()
+
+	if len(data) > models.MaxBatchSize {
+		return nil, DataError{Message: "A batch may hold at most " + strconv.Itoa(models.MaxBatchSize) + " items."}
+	}
+
+	results := make([]models.BatchResult, len(data))
+	var valid []*models.Data
+	var positions []int
+	for i, d := range data {
+		results[i].Index = i
+		if err := ds.ValidateData(d); err != nil {
+			invalid := err.(DataError)
+			results[i].Status = models.BatchRejected
+			results[i].Error, results[i].Errors = invalid.Message, invalid.Fields
+			continue
+		}
+		valid = append(valid, d)
+		positions = append(positions, i)
+	}
+	if len(valid) == 0 {
+		return results, nil
+	}
+
+	created, err := ds.repo.CreateMany(valid, ctx)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range positions {
+		results[i].ID = valid[j].ID
+		results[i].Status = models.BatchDuplicate
+		if created[j] {
+			results[i].Status = models.BatchAccepted
+		}
+	}
+	return results, nil
+}
+
 func (ds *DataServiceSQLite) ReadOne(id int, ctx context.Context) (*models.Data, error) {
+	ctx, span := tracer.Start(ctx, "DataService.ReadOne")
+	defer span.End()
 
 	data, err := ds.repo.ReadOne(id, ctx)
 	if err != nil {
@@ -40,42 +90,57 @@ func (ds *DataServiceSQLite) ReadOne(id int, ctx context.Context) (*models.Data,
 	return data, nil
 }
 
-func (ds *DataServiceSQLite) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.Data, error) {
-	return ds.repo.ReadMany(page, rowsPerPage, ctx)
+// ReadMany returns a page of data, the span covers ranging over it since that is when rows are read
+func (ds *DataServiceSQLite) ReadMany(page int, rowsPerPage int, ctx context.Context) models.Rows[models.Data] {
+	return func(yield func(*models.Data, error) bool) {
+		ctx, span := tracer.Start(ctx, "DataService.ReadMany")
+		defer span.End()
+
+		for data, err := range ds.repo.ReadMany(page, rowsPerPage, ctx) {
+			if !yield(data, err) {
+				return
+			}
+		}
+	}
 }
 
 func (ds *DataServiceSQLite) Update(data *models.Data, ctx context.Context) (int64, error) {
+	ctx, span := tracer.Start(ctx, "DataService.Update")
+	defer span.End()
 
 	if err := ds.ValidateData(data); err != nil {
-		return 0, DataError{Message: "Invalid data: " + err.Error()}
+		return 0, err
 	}
 	return ds.repo.Update(data, ctx)
 }
 
 func (ds *DataServiceSQLite) Delete(data *models.Data, ctx context.Context) (int64, error) {
+	ctx, span := tracer.Start(ctx, "DataService.Delete")
+	defer span.End()
+
 	return ds.repo.Delete(data, ctx)
 }
 
 func (ds *DataServiceSQLite) ValidateData(data *models.Data) error {
-	var errMsg string
+	var fields models.FieldErrors
 	if data.DeviceID == "" || len(data.DeviceID) > 50 {
-		errMsg += "DeviceID is required and must be less than 50 characters. "
+		fields.Add("device_id", "is required and must be less than 50 characters")
 	}
 	if len(data.DeviceName) > 50 {
-		errMsg += "DeviceName must be less than 50 characters. "
+		fields.Add("device_name", "must be less than 50 characters")
 	}
 	if len(data.Type) > 20 {
-		errMsg += "Type must be less than 20 characters. "
+		fields.Add("type", "must be less than 20 characters")
 	}
 	if len(data.Description) > 100 {
-		errMsg += "Description must be less than 100 characters. "
+		fields.Add("description", "must be less than 100 characters")
 	}
 	_, err := time.Parse("2006-01-02T15:04:05Z", data.DateTime)
 	if err != nil {
-		errMsg += "DateTime must be in the format: 2021-01-01T12:00:00Z. "
+		fields.Add("date_time", "must be in the format: 2021-01-01T12:00:00Z")
 	}
-	if errMsg != "" {
-		return DataError{Message: errMsg}
+	if len(fields) > 0 {
+		return DataError{Message: "Invalid data.", Fields: fields}
 	}
 	return nil
 }
@@ -7,8 +7,9 @@ import (
 
 type DataService interface {
 	Create(data *models.Data, ctx context.Context) error
+	CreateBatch(data []*models.Data, ctx context.Context) ([]models.BatchResult, error)
 	ReadOne(id int, ctx context.Context) (*models.Data, error)
-	ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.Data, error)
+	ReadMany(page int, rowsPerPage int, ctx context.Context) models.Rows[models.Data]
 	Update(data *models.Data, ctx context.Context) (int64, error)
 	Delete(data *models.Data, ctx context.Context) (int64, error)
 	ValidateData(data *models.Data) error
@@ -16,8 +17,12 @@ type DataService interface {
 
 type DataError struct {
 	Message string
+	Fields  models.FieldErrors // Invalid fields, set when validation failed
 }
 
 func (de DataError) Error() string {
-	return de.Message
+	if len(de.Fields) == 0 {
+		return de.Message
+	}
+	return de.Message + " " + de.Fields.String()
 }
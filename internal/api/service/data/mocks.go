@@ -8,8 +8,8 @@ import (
 // * Mock implementation of DataService for testing purposes, always returns a successful response and Data object(s) *
 type MockDataServiceSuccessful struct{}
 
-func (m *MockDataServiceSuccessful) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.Data, error) {
-	return []*models.Data{
+func (m *MockDataServiceSuccessful) ReadMany(page int, rowsPerPage int, ctx context.Context) models.Rows[models.Data] {
+	return models.RowsOf([]*models.Data{
 		{
 			ID:          1,
 			DeviceID:    "device1",
@@ -28,7 +28,7 @@ func (m *MockDataServiceSuccessful) ReadMany(page int, rowsPerPage int, ctx cont
 			DateTime:    "2021-01-01 00:00:00",
 			Description: "description2",
 		},
-	}, nil
+	}, nil)
 }
 
 func (m *MockDataServiceSuccessful) ReadOne(id int, ctx context.Context) (*models.Data, error) {
@@ -40,6 +40,7 @@ func (m *MockDataServiceSuccessful) ReadOne(id int, ctx context.Context) (*model
 		Type:        "type1",
 		DateTime:    "2021-01-01 00:00:00",
 		Description: "description1",
+		Version:     1,
 	}, nil
 }
 
@@ -47,6 +48,14 @@ func (m *MockDataServiceSuccessful) Create(data *models.Data, ctx context.Contex
 	return nil
 }
 
+func (m *MockDataServiceSuccessful) CreateBatch(data []*models.Data, ctx context.Context) ([]models.BatchResult, error) {
+	results := make([]models.BatchResult, len(data))
+	for i := range data {
+		results[i] = models.BatchResult{Index: i, Status: models.BatchAccepted, ID: i + 1}
+	}
+	return results, nil
+}
+
 func (m *MockDataServiceSuccessful) Update(data *models.Data, ctx context.Context) (int64, error) {
 	return 1, nil
 }
@@ -63,8 +72,8 @@ func (m *MockDataServiceSuccessful) ValidateData(data *models.Data) error {
 
 type MockDataServiceNotFound struct{}
 
-func (m *MockDataServiceNotFound) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.Data, error) {
-	return []*models.Data{}, nil
+func (m *MockDataServiceNotFound) ReadMany(page int, rowsPerPage int, ctx context.Context) models.Rows[models.Data] {
+	return models.RowsOf([]*models.Data{}, nil)
 }
 
 func (m *MockDataServiceNotFound) ReadOne(id int, ctx context.Context) (*models.Data, error) {
@@ -75,6 +84,10 @@ func (m *MockDataServiceNotFound) Create(data *models.Data, ctx context.Context)
 	return nil
 }
 
+func (m *MockDataServiceNotFound) CreateBatch(data []*models.Data, ctx context.Context) ([]models.BatchResult, error) {
+	return []models.BatchResult{}, nil
+}
+
 func (m *MockDataServiceNotFound) Update(data *models.Data, ctx context.Context) (int64, error) {
 	return 0, nil
 }
@@ -87,11 +100,33 @@ func (m *MockDataServiceNotFound) ValidateData(data *models.Data) error {
 	return nil
 }
 
+// * Mock implementation of DataService for testing purposes, the data exists but was modified since the client read it *
+type MockDataServiceConflict struct {
+	MockDataServiceSuccessful
+}
+
+func (m *MockDataServiceConflict) Update(data *models.Data, ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (m *MockDataServiceConflict) Delete(data *models.Data, ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+// * Mock implementation of DataService for testing purposes, the data exists but updates are rejected *
+type MockDataServiceUpdateError struct {
+	MockDataServiceSuccessful
+}
+
+func (m *MockDataServiceUpdateError) Update(data *models.Data, ctx context.Context) (int64, error) {
+	return 0, DataError{Message: "Error updating data."}
+}
+
 // * Mock implementation of DataService for testing purposes, always returns an error *
 type MockDataServiceError struct{}
 
-func (m *MockDataServiceError) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.Data, error) {
-	return nil, DataError{Message: "Error reading data."}
+func (m *MockDataServiceError) ReadMany(page int, rowsPerPage int, ctx context.Context) models.Rows[models.Data] {
+	return models.RowsOf[models.Data](nil, DataError{Message: "Error reading data."})
 }
 
 func (m *MockDataServiceError) ReadOne(id int, ctx context.Context) (*models.Data, error) {
@@ -102,6 +137,10 @@ func (m *MockDataServiceError) Create(data *models.Data, ctx context.Context) er
 	return DataError{Message: "Error creating data."}
 }
 
+func (m *MockDataServiceError) CreateBatch(data []*models.Data, ctx context.Context) ([]models.BatchResult, error) {
+	return nil, DataError{Message: "Error creating data."}
+}
+
 func (m *MockDataServiceError) Update(data *models.Data, ctx context.Context) (int64, error) {
 	return 0, DataError{Message: "Error updating data."}
 }
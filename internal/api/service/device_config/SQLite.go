@@ -4,8 +4,12 @@ import (
 	"context"
 	"goapi/internal/api/repository/models"
 	"time"
+
+	"go.opentelemetry.io/otel"
 )
 
+var tracer = otel.Tracer("goapi/internal/api/service/device_config")
+
 // DeviceConfigServiceSQLite implements DeviceConfigService for SQLite
 type DeviceConfigServiceSQLite struct {
 	repo models.DeviceConfigRepository
@@ -18,13 +22,19 @@ func NewDeviceConfigServiceSQLite(repo models.DeviceConfigRepository) *DeviceCon
 }
 
 func (s *DeviceConfigServiceSQLite) Create(config *models.DeviceConfig, ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "DeviceConfigService.Create")
+	defer span.End()
+
 	if err := s.ValidateConfig(config); err != nil {
-		return DeviceConfigError{Message: "Invalid device config: " + err.Error()}
+		return err
 	}
 	return s.repo.Create(config, ctx)
 }
 
 func (s *DeviceConfigServiceSQLite) ReadOne(id int, ctx context.Context) (*models.DeviceConfig, error) {
+	ctx, span := tracer.Start(ctx, "DeviceConfigService.ReadOne")
+	defer span.End()
+
 	config, err := s.repo.ReadOne(id, ctx)
 	if err != nil {
 		return nil, err
@@ -33,6 +43,9 @@ func (s *DeviceConfigServiceSQLite) ReadOne(id int, ctx context.Context) (*model
 }
 
 func (s *DeviceConfigServiceSQLite) ReadByDeviceID(deviceID string, ctx context.Context) (*models.DeviceConfig, error) {
+	ctx, span := tracer.Start(ctx, "DeviceConfigService.ReadByDeviceID")
+	defer span.End()
+
 	if deviceID == "" {
 		return nil, DeviceConfigError{Message: "device_id is required"}
 	}
@@ -43,53 +56,69 @@ func (s *DeviceConfigServiceSQLite) ReadByDeviceID(deviceID string, ctx context.
 	return config, nil
 }
 
-func (s *DeviceConfigServiceSQLite) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.DeviceConfig, error) {
-	return s.repo.ReadMany(page, rowsPerPage, ctx)
+// ReadMany returns a page of configs, the span covers ranging over them since that is when they are read
+func (s *DeviceConfigServiceSQLite) ReadMany(page int, rowsPerPage int, ctx context.Context) models.Rows[models.DeviceConfig] {
+	return func(yield func(*models.DeviceConfig, error) bool) {
+		ctx, span := tracer.Start(ctx, "DeviceConfigService.ReadMany")
+		defer span.End()
+
+		for config, err := range s.repo.ReadMany(page, rowsPerPage, ctx) {
+			if !yield(config, err) {
+				return
+			}
+		}
+	}
 }
 
 func (s *DeviceConfigServiceSQLite) Update(config *models.DeviceConfig, ctx context.Context) (int64, error) {
+	ctx, span := tracer.Start(ctx, "DeviceConfigService.Update")
+	defer span.End()
+
 	if err := s.ValidateConfig(config); err != nil {
-		return 0, DeviceConfigError{Message: "Invalid device config: " + err.Error()}
+		return 0, err
 	}
 	return s.repo.Update(config, ctx)
 }
 
 func (s *DeviceConfigServiceSQLite) Delete(config *models.DeviceConfig, ctx context.Context) (int64, error) {
+	ctx, span := tracer.Start(ctx, "DeviceConfigService.Delete")
+	defer span.End()
+
 	return s.repo.Delete(config, ctx)
 }
 
 // ValidateConfig validates the device config according to the requirements
 func (s *DeviceConfigServiceSQLite) ValidateConfig(config *models.DeviceConfig) error {
-	var errMsg string
+	var fields models.FieldErrors
 
 	// Validate device_id (required, max 50 chars)
 	if config.DeviceID == "" || len(config.DeviceID) > 50 {
-		errMsg += "device_id is required and must be less than 50 characters. "
+		fields.Add("device_id", "is required and must be less than 50 characters")
 	}
 
 	// Validate alarm_timeout (must be positive, reasonable range 1-3600 seconds = 1 hour)
 	if config.AlarmTimeout < 1 || config.AlarmTimeout > 3600 {
-		errMsg += "alarm_timeout must be between 1 and 3600 seconds. "
+		fields.Add("alarm_timeout", "must be between 1 and 3600 seconds")
 	}
 
 	// Validate sensitivity_level (must be 1-10)
 	if config.SensitivityLevel < 1 || config.SensitivityLevel > 10 {
-		errMsg += "sensitivity_level must be between 1 and 10. "
+		fields.Add("sensitivity_level", "must be between 1 and 10")
 	}
 
 	// Validate updated_at format (RFC3339) and not in the future
 	updatedAt, err := time.Parse(time.RFC3339, config.UpdatedAt)
 	if err != nil {
-		errMsg += "updated_at must be in RFC3339 format (e.g., 2006-01-02T15:04:05Z07:00). "
+		fields.Add("updated_at", "must be in RFC3339 format (e.g., 2006-01-02T15:04:05Z07:00)")
 	} else {
 		// Check if updated_at is not in the future (allow 1 minute tolerance for clock skew)
 		if updatedAt.After(time.Now().Add(1 * time.Minute)) {
-			errMsg += "updated_at must not be in the future. "
+			fields.Add("updated_at", "must not be in the future")
 		}
 	}
 
-	if errMsg != "" {
-		return DeviceConfigError{Message: errMsg}
+	if len(fields) > 0 {
+		return DeviceConfigError{Message: "Invalid device config.", Fields: fields}
 	}
 	return nil
 }
@@ -10,7 +10,7 @@ type DeviceConfigService interface {
 	Create(config *models.DeviceConfig, ctx context.Context) error
 	ReadOne(id int, ctx context.Context) (*models.DeviceConfig, error)
 	ReadByDeviceID(deviceID string, ctx context.Context) (*models.DeviceConfig, error)
-	ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.DeviceConfig, error)
+	ReadMany(page int, rowsPerPage int, ctx context.Context) models.Rows[models.DeviceConfig]
 	Update(config *models.DeviceConfig, ctx context.Context) (int64, error)
 	Delete(config *models.DeviceConfig, ctx context.Context) (int64, error)
 	ValidateConfig(config *models.DeviceConfig) error
@@ -19,8 +19,12 @@ type DeviceConfigService interface {
 // DeviceConfigError represents a business logic error
 type DeviceConfigError struct {
 	Message string
+	Fields  models.FieldErrors // Invalid fields, set when validation failed
 }
 
 func (e DeviceConfigError) Error() string {
-	return e.Message
+	if len(e.Fields) == 0 {
+		return e.Message
+	}
+	return e.Message + " " + e.Fields.String()
 }
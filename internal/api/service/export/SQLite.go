@@ -0,0 +1,75 @@
+package export
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+var tracer = otel.Tracer("goapi/internal/api/service/export")
+
+// ExportServiceSQLite implements ExportService for SQLite
+type ExportServiceSQLite struct {
+	statuses models.MazeDeviceStatusRepository
+	data     models.DataRepository
+}
+
+func NewExportServiceSQLite(statuses models.MazeDeviceStatusRepository, data models.DataRepository) *ExportServiceSQLite {
+	return &ExportServiceSQLite{
+		statuses: statuses,
+		data:     data,
+	}
+}
+
+// ExportStatuses calls each for every maze device status matching filter, oldest first
+func (s *ExportServiceSQLite) ExportStatuses(filter *models.ExportFilter, each func(status *models.MazeDeviceStatus) error, ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "ExportService.ExportStatuses")
+	defer span.End()
+
+	if err := validateFilter(filter); err != nil {
+		return err
+	}
+	return s.statuses.Export(filter, each, ctx)
+}
+
+// ExportData calls each for every data row matching filter, oldest first
+func (s *ExportServiceSQLite) ExportData(filter *models.ExportFilter, each func(data *models.Data) error, ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "ExportService.ExportData")
+	defer span.End()
+
+	if err := validateFilter(filter); err != nil {
+		return err
+	}
+	return s.data.Export(filter, each, ctx)
+}
+
+// validateFilter checks the time range and converts it to UTC
+func validateFilter(filter *models.ExportFilter) error {
+	since, err := toUTC(&filter.Since)
+	if err != nil {
+		return err
+	}
+	until, err := toUTC(&filter.Until)
+	if err != nil {
+		return err
+	}
+	if !since.IsZero() && !until.IsZero() && !until.After(since) {
+		return ExportError{Message: "until must be after since."}
+	}
+	return nil
+}
+
+// toUTC rewrites an RFC3339 timestamp in UTC and returns it, empty values stay empty and return the zero time
+func toUTC(value *string) (time.Time, error) {
+	if *value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, *value)
+	if err != nil {
+		return time.Time{}, ExportError{Message: "since and until must be RFC3339 timestamps."}
+	}
+	*value = t.UTC().Format(time.RFC3339)
+	return t, nil
+}
@@ -0,0 +1,94 @@
+package export
+
+import (
+	"context"
+	"goapi/internal/api/repository/DAL/SQLite"
+	"goapi/internal/api/repository/models"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newTestService(t *testing.T) (*ExportServiceSQLite, models.MazeDeviceStatusRepository) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	db, err := SQLite.NewSqlite(filepath.Join(t.TempDir(), "test.db"), SQLite.PoolConfig{MaxOpenConns: 1, MaxIdleConns: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	statuses, err := SQLite.NewMazeDeviceStatusRepository(db, logger, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := SQLite.NewDataRepository(db, logger, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewExportServiceSQLite(statuses, data), statuses
+}
+
+func TestExportStatuses(t *testing.T) {
+	service, repo := newTestService(t)
+	ctx := context.Background()
+
+	for _, status := range []*models.MazeDeviceStatus{
+		{DeviceID: "ARD001", BatteryLevel: 90, Timestamp: "2024-01-15T09:00:00Z"},
+		{DeviceID: "ARD002", BatteryLevel: 80, Timestamp: "2024-01-15T10:00:00Z"},
+		// 10:30 UTC written with an offset
+		{DeviceID: "ARD001", BatteryLevel: 70, Timestamp: "2024-01-15T11:30:00+01:00"},
+		{DeviceID: "ARD001", BatteryLevel: 60, Timestamp: "2024-01-15T12:00:00Z"},
+	} {
+		if err := repo.Create(status, ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter models.ExportFilter
+		want   []int
+	}{
+		{name: "Everything", want: []int{90, 80, 70, 60}},
+		{name: "One device", filter: models.ExportFilter{DeviceID: "ARD001"}, want: []int{90, 70, 60}},
+		{name: "Time range", filter: models.ExportFilter{Since: "2024-01-15T10:00:00Z", Until: "2024-01-15T12:00:00Z"}, want: []int{80, 70}},
+		{name: "Time range with offset", filter: models.ExportFilter{DeviceID: "ARD001", Since: "2024-01-15T11:30:00+01:00"}, want: []int{70, 60}},
+		{name: "Nothing", filter: models.ExportFilter{DeviceID: "ARD003"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			err := service.ExportStatuses(&tt.filter, func(status *models.MazeDeviceStatus) error {
+				got = append(got, status.BatteryLevel)
+				return nil
+			}, ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("exported battery levels %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportInvalidFilter(t *testing.T) {
+	service, _ := newTestService(t)
+
+	for name, filter := range map[string]models.ExportFilter{
+		"Malformed since": {Since: "yesterday"},
+		"Empty range":     {Since: "2024-01-15T10:00:00Z", Until: "2024-01-15T10:00:00Z"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := service.ExportData(&filter, func(data *models.Data) error { return nil }, context.Background())
+			if _, ok := err.(ExportError); !ok {
+				t.Errorf("Expected an ExportError, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package export
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+)
+
+// ExportService streams the rows of the telemetry tables, e.g. for analysts pulling them into spreadsheets
+type ExportService interface {
+	ExportStatuses(filter *models.ExportFilter, each func(status *models.MazeDeviceStatus) error, ctx context.Context) error
+	ExportData(filter *models.ExportFilter, each func(data *models.Data) error, ctx context.Context) error
+}
+
+// ExportError represents a business logic error
+type ExportError struct {
+	Message string
+}
+
+func (e ExportError) Error() string {
+	return e.Message
+}
@@ -2,12 +2,23 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"goapi/internal/api/config"
 	"goapi/internal/api/repository/DAL"
 	"goapi/internal/api/repository/DAL/SQLite"
+	"goapi/internal/api/service/audit"
+	"goapi/internal/api/service/auth"
 	service "goapi/internal/api/service/data"
 	"goapi/internal/api/service/device_config"
+	"goapi/internal/api/service/export"
+	"goapi/internal/api/service/firmware"
+	"goapi/internal/api/service/idempotency"
+	"goapi/internal/api/service/importer"
+	"goapi/internal/api/service/lockout"
 	"goapi/internal/api/service/maze_device"
-	"log"
+	"goapi/internal/api/service/provisioning"
+	"log/slog"
+	"time"
 )
 
 type DataServiceType int
@@ -17,17 +28,25 @@ const (
 )
 
 type ServiceFactory struct {
-	db     DAL.SQLDatabase
-	logger *log.Logger
-	ctx    context.Context
+	db             DAL.SQLDatabase
+	logger         *slog.Logger
+	ctx            context.Context
+	firmwareDir    string
+	lockout        config.LockoutConfig
+	tokens         config.TokenConfig
+	idempotencyTTL time.Duration
 }
 
 // * Factory for creating data service *
-func NewServiceFactory(db DAL.SQLDatabase, logger *log.Logger, ctx context.Context) *ServiceFactory {
+func NewServiceFactory(db DAL.SQLDatabase, logger *slog.Logger, ctx context.Context, cfg *config.Config) *ServiceFactory {
 	return &ServiceFactory{
-		db:     db,
-		logger: logger,
-		ctx:    ctx,
+		db:             db,
+		logger:         logger,
+		ctx:            ctx,
+		firmwareDir:    cfg.Firmware.Dir,
+		lockout:        cfg.Auth.Lockout,
+		tokens:         cfg.Auth.Tokens,
+		idempotencyTTL: cfg.Server.IdempotencyTTL,
 	}
 }
 
@@ -36,7 +55,7 @@ func (sf *ServiceFactory) CreateDataService(serviceType DataServiceType) (*servi
 	switch serviceType {
 
 	case SQLiteDataService:
-		repo, err := SQLite.NewDataRepository(sf.db, sf.ctx)
+		repo, err := SQLite.NewDataRepository(sf.db, sf.logger, sf.ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -52,7 +71,7 @@ func (sf *ServiceFactory) CreateMazeDeviceStatusService(serviceType DataServiceT
 	switch serviceType {
 
 	case SQLiteDataService:
-		repo, err := SQLite.NewMazeDeviceStatusRepository(sf.db, sf.ctx)
+		repo, err := SQLite.NewMazeDeviceStatusRepository(sf.db, sf.logger, sf.ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -68,7 +87,7 @@ func (sf *ServiceFactory) CreateDeviceConfigService(serviceType DataServiceType)
 	switch serviceType {
 
 	case SQLiteDataService:
-		repo, err := SQLite.NewDeviceConfigRepository(sf.db, sf.ctx)
+		repo, err := SQLite.NewDeviceConfigRepository(sf.db, sf.logger, sf.ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -78,3 +97,181 @@ func (sf *ServiceFactory) CreateDeviceConfigService(serviceType DataServiceType)
 		return nil, device_config.DeviceConfigError{Message: "Invalid service type."}
 	}
 }
+
+// CreateProvisioningService creates the onboarding service, devices never get one of the reserved usernames as ID
+func (sf *ServiceFactory) CreateProvisioningService(serviceType DataServiceType, reserved ...string) (*provisioning.ProvisioningServiceSQLite, error) {
+
+	switch serviceType {
+
+	case SQLiteDataService:
+		// Claims store credentials when they are redeemed, so the credential table is created first
+		credentials, err := SQLite.NewDeviceCredentialRepository(sf.db, sf.logger, sf.ctx)
+		if err != nil {
+			return nil, err
+		}
+		claims, err := SQLite.NewClaimCodeRepository(sf.db, sf.logger, sf.ctx)
+		if err != nil {
+			return nil, err
+		}
+		configs, err := SQLite.NewDeviceConfigRepository(sf.db, sf.logger, sf.ctx)
+		if err != nil {
+			return nil, err
+		}
+		service := provisioning.NewProvisioningServiceSQLite(claims, credentials, configs, reserved...)
+		return service, nil
+	default:
+		return nil, provisioning.ProvisioningError{Message: "Invalid service type."}
+	}
+}
+
+func (sf *ServiceFactory) CreateFirmwareService(serviceType DataServiceType) (*firmware.FirmwareServiceSQLite, error) {
+
+	switch serviceType {
+
+	case SQLiteDataService:
+		releases, err := SQLite.NewFirmwareReleaseRepository(sf.db, sf.logger, sf.ctx)
+		if err != nil {
+			return nil, err
+		}
+		rollouts, err := SQLite.NewFirmwareRolloutRepository(sf.db, sf.logger, sf.ctx)
+		if err != nil {
+			return nil, err
+		}
+		service := firmware.NewFirmwareServiceSQLite(releases, rollouts, sf.firmwareDir)
+		return service, nil
+	default:
+		return nil, firmware.FirmwareError{Message: "Invalid service type."}
+	}
+}
+
+func (sf *ServiceFactory) CreateLockoutService(serviceType DataServiceType) (*lockout.LockoutServiceSQLite, error) {
+
+	switch serviceType {
+
+	case SQLiteDataService:
+		repo, err := SQLite.NewAuthLockoutRepository(sf.db, sf.logger, sf.ctx)
+		if err != nil {
+			return nil, err
+		}
+		service := lockout.NewLockoutServiceSQLite(repo, lockout.Policy{
+			MaxFailures:      sf.lockout.MaxFailures,
+			MaxFailuresPerIP: sf.lockout.MaxFailuresPerIP,
+			Window:           sf.lockout.Window,
+			Duration:         sf.lockout.Duration,
+			MaxDelay:         sf.lockout.MaxDelay,
+		}, sf.logger)
+		return service, nil
+	default:
+		return nil, lockout.LockoutError{Message: "Invalid service type."}
+	}
+}
+
+// CreateAuthService creates the token service, logins are checked against validators and count towards lockouts,
+// refreshing checks the account is still current with lookups
+func (sf *ServiceFactory) CreateAuthService(serviceType DataServiceType, lockouts lockout.LockoutService, lookups []auth.CredentialLookup, validators ...auth.CredentialValidator) (*auth.AuthServiceSQLite, error) {
+
+	switch serviceType {
+
+	case SQLiteDataService:
+		repo, err := SQLite.NewRefreshTokenRepository(sf.db, sf.logger, sf.ctx)
+		if err != nil {
+			return nil, err
+		}
+		secret := []byte(sf.tokens.Secret)
+		if len(secret) == 0 {
+			sf.logger.Warn("No token secret configured, tokens are signed with a random key and become invalid on restart")
+			secret = make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				return nil, err
+			}
+		}
+		service := auth.NewAuthServiceSQLite(repo, lockouts, auth.Policy{
+			Secret:     secret,
+			AccessTTL:  sf.tokens.AccessTTL,
+			RefreshTTL: sf.tokens.RefreshTTL,
+		}, lookups, validators...)
+		return service, nil
+	default:
+		return nil, auth.AuthError{Message: "Invalid service type."}
+	}
+}
+
+// CreateAuditService creates the audit log service, actor returns the caller of the request in ctx
+func (sf *ServiceFactory) CreateAuditService(serviceType DataServiceType, actor func(ctx context.Context) string) (*audit.AuditServiceSQLite, error) {
+
+	switch serviceType {
+
+	case SQLiteDataService:
+		repo, err := SQLite.NewAuditRepository(sf.db, sf.logger, sf.ctx)
+		if err != nil {
+			return nil, err
+		}
+		service := audit.NewAuditServiceSQLite(repo, actor, sf.logger)
+		return service, nil
+	default:
+		return nil, audit.AuditError{Message: "Invalid service type."}
+	}
+}
+
+// CreateExportService creates the service streaming maze device statuses and data to analysts
+func (sf *ServiceFactory) CreateExportService(serviceType DataServiceType) (*export.ExportServiceSQLite, error) {
+
+	switch serviceType {
+
+	case SQLiteDataService:
+		statuses, err := SQLite.NewMazeDeviceStatusRepository(sf.db, sf.logger, sf.ctx)
+		if err != nil {
+			return nil, err
+		}
+		data, err := SQLite.NewDataRepository(sf.db, sf.logger, sf.ctx)
+		if err != nil {
+			return nil, err
+		}
+		service := export.NewExportServiceSQLite(statuses, data)
+		return service, nil
+	default:
+		return nil, export.ExportError{Message: "Invalid service type."}
+	}
+}
+
+// CreateImportService creates the service loading maze device statuses, device configs and data from other backends
+func (sf *ServiceFactory) CreateImportService(serviceType DataServiceType) (*importer.ImportServiceSQLite, error) {
+
+	switch serviceType {
+
+	case SQLiteDataService:
+		statuses, err := SQLite.NewMazeDeviceStatusRepository(sf.db, sf.logger, sf.ctx)
+		if err != nil {
+			return nil, err
+		}
+		configs, err := SQLite.NewDeviceConfigRepository(sf.db, sf.logger, sf.ctx)
+		if err != nil {
+			return nil, err
+		}
+		data, err := SQLite.NewDataRepository(sf.db, sf.logger, sf.ctx)
+		if err != nil {
+			return nil, err
+		}
+		service := importer.NewImportServiceSQLite(statuses, configs, data)
+		return service, nil
+	default:
+		return nil, importer.ImportError{Message: "Invalid service type."}
+	}
+}
+
+// CreateIdempotencyService creates the service storing responses of requests sent with an Idempotency-Key
+func (sf *ServiceFactory) CreateIdempotencyService(serviceType DataServiceType) (*idempotency.IdempotencyServiceSQLite, error) {
+
+	switch serviceType {
+
+	case SQLiteDataService:
+		repo, err := SQLite.NewIdempotencyKeyRepository(sf.db, sf.logger, sf.ctx)
+		if err != nil {
+			return nil, err
+		}
+		service := idempotency.NewIdempotencyServiceSQLite(repo, idempotency.Policy{TTL: sf.idempotencyTTL})
+		return service, nil
+	default:
+		return nil, idempotency.IdempotencyError{Message: "Invalid service type."}
+	}
+}
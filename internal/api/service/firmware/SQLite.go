@@ -0,0 +1,299 @@
+package firmware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"goapi/internal/api/repository/models"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+var tracer = otel.Tracer("goapi/internal/api/service/firmware")
+
+var hardwarePattern = regexp.MustCompile(`^[a-z0-9_\-]{1,50}$`)
+
+// FirmwareServiceSQLite implements FirmwareService, metadata is stored in SQLite and binaries on local disk
+type FirmwareServiceSQLite struct {
+	releases   models.FirmwareReleaseRepository
+	rollouts   models.FirmwareRolloutRepository
+	storageDir string
+}
+
+func NewFirmwareServiceSQLite(releases models.FirmwareReleaseRepository, rollouts models.FirmwareRolloutRepository, storageDir string) *FirmwareServiceSQLite {
+	return &FirmwareServiceSQLite{
+		releases:   releases,
+		rollouts:   rollouts,
+		storageDir: storageDir,
+	}
+}
+
+// Create validates the upload, stores the binary under <storageDir>/<hardware>/<version>.bin and records the release.
+// New releases start with a rollout percentage of 0, so nothing is offered until a policy is set.
+func (s *FirmwareServiceSQLite) Create(upload *FirmwareUpload, ctx context.Context) (*models.FirmwareRelease, error) {
+	ctx, span := tracer.Start(ctx, "FirmwareService.Create")
+	defer span.End()
+
+	var errMsg string
+	if _, ok := parseVersion(upload.Version); !ok {
+		errMsg += "version must be in MAJOR.MINOR.PATCH format. "
+	}
+	if !hardwarePattern.MatchString(upload.Hardware) {
+		errMsg += "hardware is required and must be 1-50 lower case letters, digits, '_' or '-'. "
+	}
+	if len(upload.Data) == 0 {
+		errMsg += "data is required. "
+	}
+	sum := sha256.Sum256(upload.Data)
+	checksum := hex.EncodeToString(sum[:])
+	if upload.Checksum != "" && !strings.EqualFold(upload.Checksum, checksum) {
+		errMsg += "checksum does not match the uploaded data. "
+	}
+	if errMsg != "" {
+		return nil, FirmwareError{Message: "Invalid firmware release: " + errMsg}
+	}
+
+	path, err := s.store(upload)
+	if err != nil {
+		return nil, err
+	}
+
+	release := &models.FirmwareRelease{
+		Version:   strings.TrimPrefix(upload.Version, "v"),
+		Hardware:  upload.Hardware,
+		Checksum:  checksum,
+		Size:      int64(len(upload.Data)),
+		Allowlist: []string{},
+		FilePath:  path,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	if err := s.releases.Create(release, ctx); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return release, nil
+}
+
+// store writes the binary to a temporary file first, so a partially written file is never served
+func (s *FirmwareServiceSQLite) store(upload *FirmwareUpload) (string, error) {
+	dir := filepath.Join(s.storageDir, upload.Hardware)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, strings.TrimPrefix(upload.Version, "v")+".bin")
+	if _, err := os.Stat(path); err == nil {
+		return "", FirmwareError{Message: "Firmware release already exists for this hardware and version."}
+	}
+
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(upload.Data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	return path, os.Rename(tmp.Name(), path)
+}
+
+func (s *FirmwareServiceSQLite) ReadOne(id int, ctx context.Context) (*models.FirmwareRelease, error) {
+	ctx, span := tracer.Start(ctx, "FirmwareService.ReadOne")
+	defer span.End()
+
+	return s.releases.ReadOne(id, ctx)
+}
+
+func (s *FirmwareServiceSQLite) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.FirmwareRelease, error) {
+	ctx, span := tracer.Start(ctx, "FirmwareService.ReadMany")
+	defer span.End()
+
+	return s.releases.ReadMany(page, rowsPerPage, ctx)
+}
+
+// UpdatePolicy changes which devices are offered the release
+func (s *FirmwareServiceSQLite) UpdatePolicy(release *models.FirmwareRelease, ctx context.Context) (int64, error) {
+	ctx, span := tracer.Start(ctx, "FirmwareService.UpdatePolicy")
+	defer span.End()
+
+	var errMsg string
+	if release.RolloutPercentage < 0 || release.RolloutPercentage > 100 {
+		errMsg += "rollout_percentage must be between 0 and 100. "
+	}
+	for _, deviceID := range release.Allowlist {
+		if deviceID == "" || len(deviceID) > 50 {
+			errMsg += "allowlist entries must be device IDs of 1-50 characters. "
+			break
+		}
+	}
+	if errMsg != "" {
+		return 0, FirmwareError{Message: "Invalid rollout policy: " + errMsg}
+	}
+	return s.releases.Update(release, ctx)
+}
+
+// Delete removes the release and its binary
+func (s *FirmwareServiceSQLite) Delete(release *models.FirmwareRelease, ctx context.Context) (int64, error) {
+	ctx, span := tracer.Start(ctx, "FirmwareService.Delete")
+	defer span.End()
+
+	existing, err := s.releases.ReadOne(release.ID, ctx)
+	if err != nil || existing == nil {
+		return 0, err
+	}
+	rowsAffected, err := s.releases.Delete(existing, ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Remove(existing.FilePath); err != nil && !os.IsNotExist(err) {
+		return rowsAffected, err
+	}
+	return rowsAffected, nil
+}
+
+// CheckUpdate returns the newest release for the hardware that is newer than currentVersion and
+// whose rollout policy includes the device, or nil when the device is up to date.
+// Offering a release is recorded as the first step of its rollout on the device.
+func (s *FirmwareServiceSQLite) CheckUpdate(deviceID string, hardware string, currentVersion string, ctx context.Context) (*models.FirmwareRelease, error) {
+	ctx, span := tracer.Start(ctx, "FirmwareService.CheckUpdate")
+	defer span.End()
+
+	newest, err := s.PreviewUpdate(deviceID, hardware, currentVersion, ctx)
+	if err != nil || newest == nil {
+		return nil, err
+	}
+
+	err = s.rollouts.Upsert(&models.FirmwareRollout{
+		ReleaseID: newest.ID,
+		DeviceID:  deviceID,
+		Status:    models.RolloutOffered,
+		UpdatedAt: time.Now().UTC().Format(time.RFC3339),
+	}, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newest, nil
+}
+
+// PreviewUpdate returns the release CheckUpdate would offer the device without recording anything,
+// e.g. for an admin checking what a device will be offered
+func (s *FirmwareServiceSQLite) PreviewUpdate(deviceID string, hardware string, currentVersion string, ctx context.Context) (*models.FirmwareRelease, error) {
+	ctx, span := tracer.Start(ctx, "FirmwareService.PreviewUpdate")
+	defer span.End()
+
+	if deviceID == "" || len(deviceID) > 50 {
+		return nil, FirmwareError{Message: "device_id is required and must be less than 50 characters."}
+	}
+	current, ok := parseVersion(currentVersion)
+	if !ok {
+		return nil, FirmwareError{Message: "version must be in MAJOR.MINOR.PATCH format."}
+	}
+
+	releases, err := s.releases.ReadByHardware(hardware, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var newest *models.FirmwareRelease
+	var newestVersion [3]int
+	for _, release := range releases {
+		version, ok := parseVersion(release.Version)
+		if !ok || compareVersions(version, current) <= 0 || !inRollout(release, deviceID) {
+			continue
+		}
+		if newest == nil || compareVersions(version, newestVersion) > 0 {
+			newest, newestVersion = release, version
+		}
+	}
+	return newest, nil
+}
+
+// inRollout decides whether a device is offered a release. Allowlisted devices always are, other devices
+// are hashed into a stable bucket 0-99 per release, so raising the percentage only ever adds devices.
+func inRollout(release *models.FirmwareRelease, deviceID string) bool {
+	if slices.Contains(release.Allowlist, deviceID) {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(release.Hardware + "/" + release.Version + "/" + deviceID))
+	return int(h.Sum32()%100) < release.RolloutPercentage
+}
+
+// Targets reports whether the release's rollout policy includes the device, devices may only download releases they
+// would be offered
+func (s *FirmwareServiceSQLite) Targets(release *models.FirmwareRelease, deviceID string) bool {
+	return inRollout(release, deviceID)
+}
+
+// StartDownload reports whether the device may download the release: only releases it was offered by CheckUpdate,
+// which matched them to the hardware it reported. A download from the first byte moves the rollout to downloading,
+// resumed downloads and devices that already installed or failed the release leave it as it is.
+func (s *FirmwareServiceSQLite) StartDownload(release *models.FirmwareRelease, deviceID string, fromStart bool, ctx context.Context) (bool, error) {
+	ctx, span := tracer.Start(ctx, "FirmwareService.StartDownload")
+	defer span.End()
+
+	rollout, err := s.rollouts.ReadOne(release.ID, deviceID, ctx)
+	if err != nil || rollout == nil {
+		return false, err
+	}
+	if !fromStart || rollout.Status == models.RolloutInstalled || rollout.Status == models.RolloutFailed || rollout.Status == models.RolloutDownloading {
+		return true, nil
+	}
+
+	rollout.Status = models.RolloutDownloading
+	rollout.Detail = ""
+	rollout.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	return true, s.rollouts.Upsert(rollout, ctx)
+}
+
+func (s *FirmwareServiceSQLite) Open(release *models.FirmwareRelease) (*os.File, error) {
+	return os.Open(release.FilePath)
+}
+
+// ReportRollout records the rollout state reported by (or observed for) a device
+func (s *FirmwareServiceSQLite) ReportRollout(rollout *models.FirmwareRollout, ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "FirmwareService.ReportRollout")
+	defer span.End()
+
+	switch rollout.Status {
+	case models.RolloutOffered, models.RolloutDownloading, models.RolloutInstalled, models.RolloutFailed:
+	default:
+		return FirmwareError{Message: "status must be one of offered, downloading, installed or failed."}
+	}
+	if rollout.DeviceID == "" || len(rollout.DeviceID) > 50 {
+		return FirmwareError{Message: "device_id is required and must be less than 50 characters."}
+	}
+	if len(rollout.Detail) > 500 {
+		return FirmwareError{Message: "detail must be less than 500 characters."}
+	}
+
+	release, err := s.releases.ReadOne(rollout.ReleaseID, ctx)
+	if err != nil {
+		return err
+	}
+	if release == nil {
+		return FirmwareError{Message: "Firmware release not found."}
+	}
+
+	rollout.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	return s.rollouts.Upsert(rollout, ctx)
+}
+
+func (s *FirmwareServiceSQLite) ReadRollouts(releaseID int, ctx context.Context) ([]*models.FirmwareRollout, error) {
+	ctx, span := tracer.Start(ctx, "FirmwareService.ReadRollouts")
+	defer span.End()
+
+	return s.rollouts.ReadByRelease(releaseID, ctx)
+}
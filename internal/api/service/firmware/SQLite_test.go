@@ -0,0 +1,266 @@
+package firmware
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// In-memory repositories, enough to exercise release selection without a database
+type memoryReleases struct {
+	releases []*models.FirmwareRelease
+}
+
+func (m *memoryReleases) Create(release *models.FirmwareRelease, ctx context.Context) error {
+	release.ID = len(m.releases) + 1
+	m.releases = append(m.releases, release)
+	return nil
+}
+
+func (m *memoryReleases) ReadOne(id int, ctx context.Context) (*models.FirmwareRelease, error) {
+	for _, r := range m.releases {
+		if r.ID == id {
+			return r, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryReleases) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.FirmwareRelease, error) {
+	return m.releases, nil
+}
+
+func (m *memoryReleases) ReadByHardware(hardware string, ctx context.Context) ([]*models.FirmwareRelease, error) {
+	var releases []*models.FirmwareRelease
+	for _, r := range m.releases {
+		if r.Hardware == hardware {
+			releases = append(releases, r)
+		}
+	}
+	return releases, nil
+}
+
+func (m *memoryReleases) Update(release *models.FirmwareRelease, ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (m *memoryReleases) Delete(release *models.FirmwareRelease, ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+type memoryRollouts struct {
+	rollouts []*models.FirmwareRollout
+}
+
+func (m *memoryRollouts) Upsert(rollout *models.FirmwareRollout, ctx context.Context) error {
+	m.rollouts = append(m.rollouts, rollout)
+	return nil
+}
+
+func (m *memoryRollouts) ReadByRelease(releaseID int, ctx context.Context) ([]*models.FirmwareRollout, error) {
+	return m.rollouts, nil
+}
+
+func (m *memoryRollouts) ReadOne(releaseID int, deviceID string, ctx context.Context) (*models.FirmwareRollout, error) {
+	var found *models.FirmwareRollout
+	for _, r := range m.rollouts {
+		if r.ReleaseID == releaseID && r.DeviceID == deviceID {
+			copied := *r
+			found = &copied
+		}
+	}
+	return found, nil
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{a: "1.0.0", b: "1.0.0", expected: 0},
+		{a: "v1.2.0", b: "1.1.9", expected: 1},
+		{a: "1.10.0", b: "1.9.0", expected: 1},
+		{a: "0.9.9", b: "1.0.0", expected: -1},
+	}
+
+	for _, tt := range tests {
+		a, okA := parseVersion(tt.a)
+		b, okB := parseVersion(tt.b)
+		if !okA || !okB {
+			t.Fatalf("Expected %s and %s to parse", tt.a, tt.b)
+		}
+		if got := compareVersions(a, b); got != tt.expected {
+			t.Errorf("compareVersions(%s, %s) = %d, expected %d", tt.a, tt.b, got, tt.expected)
+		}
+	}
+
+	for _, invalid := range []string{"", "1.0", "1.0.0.0", "1.x.0", "1.-1.0"} {
+		if _, ok := parseVersion(invalid); ok {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestCreateValidation(t *testing.T) {
+	service := NewFirmwareServiceSQLite(&memoryReleases{}, &memoryRollouts{}, t.TempDir())
+
+	tests := []struct {
+		name        string
+		upload      FirmwareUpload
+		expectError bool
+	}{
+		{name: "Valid upload", upload: FirmwareUpload{Version: "1.0.0", Hardware: "esp32", Data: []byte("binary")}},
+		{name: "Duplicate version", upload: FirmwareUpload{Version: "v1.0.0", Hardware: "esp32", Data: []byte("binary")}, expectError: true},
+		{name: "Invalid version", upload: FirmwareUpload{Version: "latest", Hardware: "esp32", Data: []byte("binary")}, expectError: true},
+		{name: "Invalid hardware", upload: FirmwareUpload{Version: "1.0.1", Hardware: "../esp32", Data: []byte("binary")}, expectError: true},
+		{name: "Missing data", upload: FirmwareUpload{Version: "1.0.1", Hardware: "esp32"}, expectError: true},
+		{name: "Checksum mismatch", upload: FirmwareUpload{Version: "1.0.1", Hardware: "esp32", Data: []byte("binary"), Checksum: "00"}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release, err := service.Create(&tt.upload, context.Background())
+			if tt.expectError {
+				if _, ok := err.(FirmwareError); !ok {
+					t.Errorf("Expected FirmwareError, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if release.RolloutPercentage != 0 || release.Size != 6 {
+				t.Errorf("Unexpected release: %+v", release)
+			}
+			if _, err := os.Stat(filepath.Join(service.storageDir, "esp32", "1.0.0.bin")); err != nil {
+				t.Errorf("Expected binary to be stored: %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckUpdateRespectsRollout(t *testing.T) {
+	releases := &memoryReleases{}
+	rollouts := &memoryRollouts{}
+	service := NewFirmwareServiceSQLite(releases, rollouts, t.TempDir())
+	ctx := context.Background()
+
+	releases.Create(&models.FirmwareRelease{Version: "1.1.0", Hardware: "esp32", RolloutPercentage: 100}, ctx)
+	releases.Create(&models.FirmwareRelease{Version: "1.2.0", Hardware: "esp32", Allowlist: []string{"ESP32_MAZE_001"}}, ctx)
+
+	// Allowlisted devices get the newest release, other devices only what is rolled out to everyone
+	release, err := service.CheckUpdate("ESP32_MAZE_001", "esp32", "1.0.0", ctx)
+	if err != nil || release == nil || release.Version != "1.2.0" {
+		t.Errorf("Expected 1.2.0 for allowlisted device, got %+v, %v", release, err)
+	}
+	release, err = service.CheckUpdate("ESP32_MAZE_002", "esp32", "1.0.0", ctx)
+	if err != nil || release == nil || release.Version != "1.1.0" {
+		t.Errorf("Expected 1.1.0 for other devices, got %+v, %v", release, err)
+	}
+	release, err = service.CheckUpdate("ESP32_MAZE_002", "esp32", "1.1.0", ctx)
+	if err != nil || release != nil {
+		t.Errorf("Expected no update for an up to date device, got %+v, %v", release, err)
+	}
+
+	if len(rollouts.rollouts) != 2 || rollouts.rollouts[0].Status != models.RolloutOffered {
+		t.Errorf("Expected offered rollouts to be recorded, got %+v", rollouts.rollouts)
+	}
+}
+
+func TestPreviewUpdateRecordsNothing(t *testing.T) {
+	releases := &memoryReleases{}
+	rollouts := &memoryRollouts{}
+	service := NewFirmwareServiceSQLite(releases, rollouts, t.TempDir())
+	ctx := context.Background()
+
+	releases.Create(&models.FirmwareRelease{Version: "1.1.0", Hardware: "esp32", RolloutPercentage: 100}, ctx)
+
+	release, err := service.PreviewUpdate("ESP32_MAZE_001", "esp32", "1.0.0", ctx)
+	if err != nil || release == nil || release.Version != "1.1.0" {
+		t.Errorf("Expected 1.1.0, got %+v, %v", release, err)
+	}
+	if len(rollouts.rollouts) != 0 {
+		t.Errorf("Expected no rollout to be recorded, got %+v", rollouts.rollouts)
+	}
+}
+
+func TestStartDownloadTransitions(t *testing.T) {
+	releases := &memoryReleases{}
+	rollouts := &memoryRollouts{}
+	service := NewFirmwareServiceSQLite(releases, rollouts, t.TempDir())
+	ctx := context.Background()
+
+	releases.Create(&models.FirmwareRelease{Version: "1.1.0", Hardware: "esp32", RolloutPercentage: 100}, ctx)
+	releases.Create(&models.FirmwareRelease{Version: "1.1.0", Hardware: "esp8266", RolloutPercentage: 100}, ctx)
+	esp32, esp8266 := releases.releases[0], releases.releases[1]
+
+	status := func() string {
+		rollout, _ := rollouts.ReadOne(esp32.ID, "ESP32_MAZE_001", ctx)
+		if rollout == nil {
+			return ""
+		}
+		return rollout.Status
+	}
+
+	// Releases are only served once offered, which matched them to the device's hardware
+	if offered, err := service.StartDownload(esp32, "ESP32_MAZE_001", true, ctx); err != nil || offered {
+		t.Fatalf("Expected a release never offered to be refused, got %v, %v", offered, err)
+	}
+	if _, err := service.CheckUpdate("ESP32_MAZE_001", "esp32", "1.0.0", ctx); err != nil {
+		t.Fatal(err)
+	}
+	if offered, _ := service.StartDownload(esp8266, "ESP32_MAZE_001", true, ctx); offered {
+		t.Error("Expected the release of other hardware to be refused")
+	}
+
+	steps := []struct {
+		name      string
+		fromStart bool
+		before    string
+		expected  string
+	}{
+		{name: "Resume before starting", fromStart: false, expected: models.RolloutOffered},
+		{name: "Start", fromStart: true, expected: models.RolloutDownloading},
+		{name: "Installed", before: models.RolloutInstalled, fromStart: true, expected: models.RolloutInstalled},
+		{name: "Failed", before: models.RolloutFailed, fromStart: true, expected: models.RolloutFailed},
+	}
+	for _, step := range steps {
+		if step.before != "" {
+			service.ReportRollout(&models.FirmwareRollout{ReleaseID: esp32.ID, DeviceID: "ESP32_MAZE_001", Status: step.before}, ctx)
+		}
+		offered, err := service.StartDownload(esp32, "ESP32_MAZE_001", step.fromStart, ctx)
+		if err != nil || !offered {
+			t.Fatalf("%s: expected the offered release to be served, got %v, %v", step.name, offered, err)
+		}
+		if got := status(); got != step.expected {
+			t.Errorf("%s: expected status %s, got %s", step.name, step.expected, got)
+		}
+	}
+}
+
+func TestInRolloutIsStable(t *testing.T) {
+	release := &models.FirmwareRelease{Version: "1.1.0", Hardware: "esp32"}
+
+	// Raising the percentage must never remove a device from the rollout
+	included := map[string]bool{}
+	for percentage := 0; percentage <= 100; percentage += 10 {
+		release.RolloutPercentage = percentage
+		count := 0
+		for i := 0; i < 200; i++ {
+			deviceID := "DEVICE_" + string(rune('A'+i%26)) + string(rune('A'+i/26))
+			in := inRollout(release, deviceID)
+			if included[deviceID] && !in {
+				t.Fatalf("Device %s dropped out of the rollout at %d%%", deviceID, percentage)
+			}
+			if in {
+				included[deviceID] = true
+				count++
+			}
+		}
+		if percentage == 0 && count != 0 || percentage == 100 && count != 200 {
+			t.Errorf("Unexpected number of devices at %d%%: %d", percentage, count)
+		}
+	}
+}
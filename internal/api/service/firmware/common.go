@@ -0,0 +1,40 @@
+package firmware
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"os"
+)
+
+// FirmwareService defines the interface for firmware release and OTA update business logic
+type FirmwareService interface {
+	Create(upload *FirmwareUpload, ctx context.Context) (*models.FirmwareRelease, error)
+	ReadOne(id int, ctx context.Context) (*models.FirmwareRelease, error)
+	ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.FirmwareRelease, error)
+	UpdatePolicy(release *models.FirmwareRelease, ctx context.Context) (int64, error)
+	Delete(release *models.FirmwareRelease, ctx context.Context) (int64, error)
+	CheckUpdate(deviceID string, hardware string, currentVersion string, ctx context.Context) (*models.FirmwareRelease, error)
+	PreviewUpdate(deviceID string, hardware string, currentVersion string, ctx context.Context) (*models.FirmwareRelease, error)
+	Targets(release *models.FirmwareRelease, deviceID string) bool
+	StartDownload(release *models.FirmwareRelease, deviceID string, fromStart bool, ctx context.Context) (bool, error)
+	Open(release *models.FirmwareRelease) (*os.File, error)
+	ReportRollout(rollout *models.FirmwareRollout, ctx context.Context) error
+	ReadRollouts(releaseID int, ctx context.Context) ([]*models.FirmwareRollout, error)
+}
+
+// FirmwareUpload is the payload an admin sends to publish a new firmware binary
+type FirmwareUpload struct {
+	Version  string `json:"version"`
+	Hardware string `json:"hardware"`
+	Checksum string `json:"checksum"` // Optional SHA-256 of the binary, verified when given
+	Data     []byte `json:"data"`     // The binary, base64 encoded in JSON
+}
+
+// FirmwareError represents a business logic error
+type FirmwareError struct {
+	Message string
+}
+
+func (e FirmwareError) Error() string {
+	return e.Message
+}
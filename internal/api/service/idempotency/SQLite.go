@@ -0,0 +1,95 @@
+package idempotency
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+var tracer = otel.Tracer("goapi/internal/api/service/idempotency")
+
+// Policy configures how long keys are kept
+type Policy struct {
+	TTL time.Duration // How long a response is replayed, the key can be reused with another request afterwards
+}
+
+// IdempotencyServiceSQLite implements IdempotencyService with keys stored in SQLite
+type IdempotencyServiceSQLite struct {
+	keys   models.IdempotencyKeyRepository
+	policy Policy
+	now    func() time.Time
+}
+
+func NewIdempotencyServiceSQLite(keys models.IdempotencyKeyRepository, policy Policy) *IdempotencyServiceSQLite {
+	return &IdempotencyServiceSQLite{
+		keys:   keys,
+		policy: policy,
+		now:    time.Now,
+	}
+}
+
+// Begin claims key in scope for a request with requestHash and returns nil, the request should then be processed
+// and completed or released. When the key is already in use it returns the stored key instead: its StatusCode is 0
+// while the first request is still in progress, and its RequestHash tells whether the request is the same.
+func (s *IdempotencyServiceSQLite) Begin(scope string, key string, requestHash string, ctx context.Context) (*models.IdempotencyKey, error) {
+	ctx, span := tracer.Start(ctx, "IdempotencyService.Begin")
+	defer span.End()
+
+	now := s.now().UTC()
+	claim := &models.IdempotencyKey{
+		Scope:       scope,
+		Key:         key,
+		RequestHash: requestHash,
+		ExpiresAt:   now.Add(s.policy.TTL).Format(time.RFC3339),
+		CreatedAt:   now.Format(time.RFC3339),
+	}
+	// A key released or purged between the insert and the read is claimed again
+	for range 2 {
+		created, err := s.keys.Create(claim, ctx)
+		if err != nil {
+			return nil, err
+		}
+		if created {
+			return nil, nil
+		}
+		existing, err := s.keys.Read(scope, key, ctx)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return existing, nil
+		}
+	}
+	return nil, IdempotencyError{Message: "Idempotency-Key is in use by a concurrent request."}
+}
+
+// Complete stores the response of a key claimed by Begin. A key that expired and was claimed again keeps the newer request.
+func (s *IdempotencyServiceSQLite) Complete(key *models.IdempotencyKey, ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "IdempotencyService.Complete")
+	defer span.End()
+
+	if key.StatusCode == 0 {
+		return IdempotencyError{Message: "A response needs a status code."}
+	}
+	_, err := s.keys.Complete(key, ctx)
+	return err
+}
+
+// Release frees a key claimed by Begin without storing a response, so the request can be retried with it
+func (s *IdempotencyServiceSQLite) Release(scope string, key string, ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "IdempotencyService.Release")
+	defer span.End()
+
+	_, err := s.keys.Delete(scope, key, ctx)
+	return err
+}
+
+// PurgeExpiredKeys deletes keys whose responses are no longer replayed
+func (s *IdempotencyServiceSQLite) PurgeExpiredKeys(ctx context.Context) (int64, error) {
+	ctx, span := tracer.Start(ctx, "IdempotencyService.PurgeExpiredKeys")
+	defer span.End()
+
+	return s.keys.DeleteExpired(s.now().UTC().Format(time.RFC3339), ctx)
+}
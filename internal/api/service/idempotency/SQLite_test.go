@@ -0,0 +1,135 @@
+package idempotency
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"testing"
+	"time"
+)
+
+// In-memory repository mirroring the SQLite statements
+type memoryKeys struct {
+	keys map[string]*models.IdempotencyKey
+}
+
+func (m *memoryKeys) Create(key *models.IdempotencyKey, ctx context.Context) (bool, error) {
+	if existing, ok := m.keys[key.Scope+"/"+key.Key]; ok && existing.ExpiresAt > key.CreatedAt {
+		return false, nil
+	}
+	stored := *key
+	m.keys[key.Scope+"/"+key.Key] = &stored
+	return true, nil
+}
+
+func (m *memoryKeys) Read(scope string, key string, ctx context.Context) (*models.IdempotencyKey, error) {
+	if existing, ok := m.keys[scope+"/"+key]; ok {
+		found := *existing
+		return &found, nil
+	}
+	return nil, nil
+}
+
+func (m *memoryKeys) Complete(key *models.IdempotencyKey, ctx context.Context) (int64, error) {
+	existing, ok := m.keys[key.Scope+"/"+key.Key]
+	if !ok || existing.RequestHash != key.RequestHash || existing.StatusCode != 0 {
+		return 0, nil
+	}
+	existing.StatusCode, existing.ContentType, existing.Body = key.StatusCode, key.ContentType, key.Body
+	return 1, nil
+}
+
+func (m *memoryKeys) Delete(scope string, key string, ctx context.Context) (int64, error) {
+	if existing, ok := m.keys[scope+"/"+key]; ok && existing.StatusCode == 0 {
+		delete(m.keys, scope+"/"+key)
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (m *memoryKeys) DeleteExpired(before string, ctx context.Context) (int64, error) {
+	var deleted int64
+	for id, key := range m.keys {
+		if key.ExpiresAt <= before {
+			delete(m.keys, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func newTestService() *IdempotencyServiceSQLite {
+	return NewIdempotencyServiceSQLite(&memoryKeys{keys: map[string]*models.IdempotencyKey{}}, Policy{TTL: time.Hour})
+}
+
+func TestBeginReplaysCompletedResponse(t *testing.T) {
+	service := newTestService()
+	ctx := context.Background()
+
+	existing, err := service.Begin("user:admin", "key-1", "hash", ctx)
+	if err != nil || existing != nil {
+		t.Fatalf("Expected the first request to claim the key, got %+v, %v", existing, err)
+	}
+
+	existing, _ = service.Begin("user:admin", "key-1", "hash", ctx)
+	if existing == nil || existing.StatusCode != 0 {
+		t.Fatalf("Expected the key to be in progress, got %+v", existing)
+	}
+
+	err = service.Complete(&models.IdempotencyKey{Scope: "user:admin", Key: "key-1", RequestHash: "hash", StatusCode: 201, ContentType: "application/json", Body: []byte(`{"id":1}`)}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	existing, _ = service.Begin("user:admin", "key-1", "hash", ctx)
+	if existing == nil || existing.StatusCode != 201 || string(existing.Body) != `{"id":1}` {
+		t.Errorf("Expected the stored response, got %+v", existing)
+	}
+
+	// Keys of other clients never collide
+	existing, _ = service.Begin("user:ESP32_MAZE_002", "key-1", "other", ctx)
+	if existing != nil {
+		t.Errorf("Expected another scope to claim the key, got %+v", existing)
+	}
+}
+
+func TestBeginReturnsHashOfOtherRequest(t *testing.T) {
+	service := newTestService()
+	ctx := context.Background()
+
+	service.Begin("user:admin", "key-1", "hash", ctx)
+	existing, _ := service.Begin("user:admin", "key-1", "other", ctx)
+	if existing == nil || existing.RequestHash != "hash" {
+		t.Errorf("Expected the key of the first request, got %+v", existing)
+	}
+}
+
+func TestReleaseAllowsRetry(t *testing.T) {
+	service := newTestService()
+	ctx := context.Background()
+
+	service.Begin("user:admin", "key-1", "hash", ctx)
+	if err := service.Release("user:admin", "key-1", ctx); err != nil {
+		t.Fatal(err)
+	}
+	if existing, _ := service.Begin("user:admin", "key-1", "other", ctx); existing != nil {
+		t.Errorf("Expected a released key to be claimed again, got %+v", existing)
+	}
+}
+
+func TestExpiredKeyIsReused(t *testing.T) {
+	service := newTestService()
+	ctx := context.Background()
+
+	service.Begin("user:admin", "key-1", "hash", ctx)
+	service.Complete(&models.IdempotencyKey{Scope: "user:admin", Key: "key-1", RequestHash: "hash", StatusCode: 201}, ctx)
+	service.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+
+	if existing, _ := service.Begin("user:admin", "key-1", "other", ctx); existing != nil {
+		t.Errorf("Expected an expired key to be claimed again, got %+v", existing)
+	}
+
+	service.now = func() time.Time { return time.Now().Add(4 * time.Hour) }
+	if purged, _ := service.PurgeExpiredKeys(ctx); purged != 1 {
+		t.Errorf("Expected 1 purged key, got %d", purged)
+	}
+}
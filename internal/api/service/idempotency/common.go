@@ -0,0 +1,23 @@
+package idempotency
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+)
+
+// IdempotencyService stores the responses of requests sent with an Idempotency-Key, so retries can be replayed
+type IdempotencyService interface {
+	Begin(scope string, key string, requestHash string, ctx context.Context) (*models.IdempotencyKey, error)
+	Complete(key *models.IdempotencyKey, ctx context.Context) error
+	Release(scope string, key string, ctx context.Context) error
+	PurgeExpiredKeys(ctx context.Context) (int64, error)
+}
+
+// IdempotencyError represents a key that cannot be used
+type IdempotencyError struct {
+	Message string
+}
+
+func (e IdempotencyError) Error() string {
+	return e.Message
+}
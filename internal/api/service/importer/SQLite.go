@@ -0,0 +1,205 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"goapi/internal/api/repository/models"
+	"goapi/internal/api/service/data"
+	"goapi/internal/api/service/device_config"
+	"goapi/internal/api/service/maze_device"
+	"goapi/internal/api/tabular"
+	"io"
+	"strconv"
+
+	"go.opentelemetry.io/otel"
+)
+
+var tracer = otel.Tracer("goapi/internal/api/service/importer")
+
+// ImportServiceSQLite implements ImportService for SQLite, rows are validated by the services of their tables
+type ImportServiceSQLite struct {
+	statuses      models.MazeDeviceStatusRepository
+	configs       models.DeviceConfigRepository
+	data          models.DataRepository
+	statusService maze_device.MazeDeviceStatusService
+	configService device_config.DeviceConfigService
+	dataService   data.DataService
+	observers     []Observer
+}
+
+func NewImportServiceSQLite(statuses models.MazeDeviceStatusRepository, configs models.DeviceConfigRepository, dataRepo models.DataRepository) *ImportServiceSQLite {
+	return &ImportServiceSQLite{
+		statuses:      statuses,
+		configs:       configs,
+		data:          dataRepo,
+		statusService: maze_device.NewMazeDeviceStatusServiceSQLite(statuses),
+		configService: device_config.NewDeviceConfigServiceSQLite(configs),
+		dataService:   data.NewDataServiceSQLite(dataRepo),
+	}
+}
+
+// Observe adds observers told about the rows every import stores
+func (s *ImportServiceSQLite) Observe(observers ...Observer) {
+	s.observers = append(s.observers, observers...)
+}
+
+// ImportStatuses imports maze device statuses, statuses stored for the same device and timestamp are duplicates
+func (s *ImportServiceSQLite) ImportStatuses(body io.Reader, format string, options *models.ImportOptions, ctx context.Context) (*models.ImportReport, error) {
+	ctx, span := tracer.Start(ctx, "ImportService.ImportStatuses")
+	defer span.End()
+
+	store := s.statuses.CreateMany
+	if options.DryRun {
+		store = s.statuses.CheckMany
+	}
+	imported := func(statuses []*models.MazeDeviceStatus, ctx context.Context) {
+		for _, observer := range s.observers {
+			observer.StatusesImported(statuses, ctx)
+		}
+	}
+	return importRows(body, format, options, s.statusService.ValidateStatus, store, imported, ctx)
+}
+
+// ImportConfigs imports device configs, configs of devices that already have one are duplicates and left unchanged
+func (s *ImportServiceSQLite) ImportConfigs(body io.Reader, format string, options *models.ImportOptions, ctx context.Context) (*models.ImportReport, error) {
+	ctx, span := tracer.Start(ctx, "ImportService.ImportConfigs")
+	defer span.End()
+
+	store := s.configs.CreateMany
+	if options.DryRun {
+		store = s.configs.CheckMany
+	}
+	imported := func(configs []*models.DeviceConfig, ctx context.Context) {
+		for _, observer := range s.observers {
+			observer.ConfigsImported(configs, ctx)
+		}
+	}
+	return importRows(body, format, options, s.configService.ValidateConfig, store, imported, ctx)
+}
+
+// ImportData imports data, data stored for the same device and date_time are duplicates
+func (s *ImportServiceSQLite) ImportData(body io.Reader, format string, options *models.ImportOptions, ctx context.Context) (*models.ImportReport, error) {
+	ctx, span := tracer.Start(ctx, "ImportService.ImportData")
+	defer span.End()
+
+	store := s.data.CreateMany
+	if options.DryRun {
+		store = s.data.CheckMany
+	}
+	imported := func(data []*models.Data, ctx context.Context) {
+		for _, observer := range s.observers {
+			observer.DataImported(data, ctx)
+		}
+	}
+	return importRows(body, format, options, s.dataService.ValidateData, store, imported, ctx)
+}
+
+// importRows reads the rows of body one at a time and passes every full chunk of valid rows to store, the rows
+// store created are passed to imported. Duplicates are only found among stored rows and rows of the same chunk on a
+// dry run, its chunks are rolled back.
+func importRows[T any](body io.Reader, format string, options *models.ImportOptions, validate func(row *T) error, store func(rows []*T, ctx context.Context) ([]bool, error), imported func(rows []*T, ctx context.Context), ctx context.Context) (*models.ImportReport, error) {
+	report := &models.ImportReport{DryRun: options.DryRun, Errors: []models.ImportRowError{}}
+
+	chunkSize := options.ChunkSize
+	if chunkSize == 0 {
+		chunkSize = models.DefaultImportChunkSize
+	}
+	if chunkSize < 1 || chunkSize > models.MaxImportChunkSize {
+		return report, ImportError{Message: "chunk_size must be between 1 and " + strconv.Itoa(models.MaxImportChunkSize) + "."}
+	}
+	if format != tabular.CSV && format != tabular.NDJSON {
+		return report, ImportError{Message: "Rows must be sent as " + tabular.CSV + " or " + tabular.NDJSON + "."}
+	}
+
+	rows, err := tabular.NewReader(body, format, new(T))
+	if err != nil {
+		var malformed *tabular.RowError
+		if errors.As(err, &malformed) {
+			return report, ImportError{Message: "Invalid CSV header, " + malformed.Error() + "."}
+		}
+		return report, err
+	}
+
+	var chunk []*T
+	flush := func() error {
+		created, err := store(chunk, ctx)
+		if err != nil {
+			return err
+		}
+		var stored []*T
+		for i, c := range created {
+			if c {
+				report.Accepted++
+				stored = append(stored, chunk[i])
+			} else {
+				report.Duplicates++
+			}
+		}
+		if !options.DryRun {
+			report.Chunks++
+			if len(stored) > 0 {
+				imported(stored, ctx)
+			}
+		}
+		chunk = nil
+		return nil
+	}
+
+	for {
+		row := new(T)
+		err := rows.Read(row)
+		if err == io.EOF {
+			break
+		}
+		var malformed *tabular.RowError
+		if errors.As(err, &malformed) {
+			report.Rows++
+			if malformed.Column != "" {
+				report.Reject(malformed.Line, "Unreadable row.", models.FieldErrors{{Field: malformed.Column, Message: malformed.Err.Error()}})
+			} else {
+				report.Reject(malformed.Line, "Unreadable row, "+malformed.Err.Error()+".", nil)
+			}
+			continue
+		}
+		if err != nil {
+			return report, err
+		}
+
+		report.Rows++
+		if err := validate(row); err != nil {
+			message, fields := invalid(err)
+			report.Reject(rows.Line(), message, fields)
+			continue
+		}
+		chunk = append(chunk, row)
+		if len(chunk) == chunkSize {
+			if err := flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+	if len(chunk) > 0 {
+		if err := flush(); err != nil {
+			return report, err
+		}
+	}
+
+	if report.Rows == 0 {
+		return report, ImportError{Message: "The import holds no rows."}
+	}
+	return report, nil
+}
+
+// invalid returns the message and invalid fields of a validation error
+func invalid(err error) (string, models.FieldErrors) {
+	switch err := err.(type) {
+	case maze_device.MazeDeviceStatusError:
+		return err.Message, err.Fields
+	case device_config.DeviceConfigError:
+		return err.Message, err.Fields
+	case data.DataError:
+		return err.Message, err.Fields
+	default:
+		return err.Error(), nil
+	}
+}
@@ -0,0 +1,34 @@
+package importer
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"io"
+)
+
+// ImportService loads rows exported from another backend, e.g. months of readings when migrating devices.
+// Rows are read from CSV or newline delimited JSON and validated like rows created through the API. Valid rows are
+// stored in chunks, one transaction per chunk, rejected rows are listed in the report. The report is also returned
+// with an error, chunks committed before it stay stored.
+type ImportService interface {
+	ImportStatuses(body io.Reader, format string, options *models.ImportOptions, ctx context.Context) (*models.ImportReport, error)
+	ImportConfigs(body io.Reader, format string, options *models.ImportOptions, ctx context.Context) (*models.ImportReport, error)
+	ImportData(body io.Reader, format string, options *models.ImportOptions, ctx context.Context) (*models.ImportReport, error)
+}
+
+// Observer is told about the rows of every chunk an import stored, duplicates left out, e.g. to record them in
+// the audit log or publish them live like rows created through the API. Dry runs store nothing and tell nothing.
+type Observer interface {
+	StatusesImported(statuses []*models.MazeDeviceStatus, ctx context.Context)
+	ConfigsImported(configs []*models.DeviceConfig, ctx context.Context)
+	DataImported(data []*models.Data, ctx context.Context)
+}
+
+// ImportError represents a business logic error
+type ImportError struct {
+	Message string
+}
+
+func (e ImportError) Error() string {
+	return e.Message
+}
@@ -0,0 +1,182 @@
+package lockout
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+var tracer = otel.Tracer("goapi/internal/api/service/lockout")
+
+// firstDelay is added to the second failed login in a row, every further failure doubles it up to Policy.MaxDelay
+const firstDelay = 100 * time.Millisecond
+
+// LockoutServiceSQLite implements LockoutService, failures are counted in memory and lockouts are recorded in SQLite
+type LockoutServiceSQLite struct {
+	repo   models.AuthLockoutRepository
+	policy Policy
+	logger *slog.Logger
+	now    func() time.Time
+
+	mu    sync.Mutex
+	users map[string]*failures
+	ips   map[string]*failures
+}
+
+// failures counts the failed logins of a username or IP address within the window
+type failures struct {
+	count       int
+	since       time.Time
+	lockedUntil time.Time
+}
+
+func NewLockoutServiceSQLite(repo models.AuthLockoutRepository, policy Policy, logger *slog.Logger) *LockoutServiceSQLite {
+	return &LockoutServiceSQLite{
+		repo:   repo,
+		policy: policy,
+		logger: logger,
+		now:    time.Now,
+		users:  make(map[string]*failures),
+		ips:    make(map[string]*failures),
+	}
+}
+
+// Locked reports whether the username or IP address is locked out and for how much longer
+func (s *LockoutServiceSQLite) Locked(username string, ip string) (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	var retryAfter time.Duration
+	for _, f := range []*failures{s.users[username], s.ips[ip]} {
+		if f != nil && f.lockedUntil.After(now) {
+			retryAfter = max(retryAfter, f.lockedUntil.Sub(now))
+		}
+	}
+	return retryAfter, retryAfter > 0
+}
+
+// Failed counts a failed login, locks the username or IP address once it reaches its limit
+// and returns how long the caller should delay the response
+func (s *LockoutServiceSQLite) Failed(username string, ip string, ctx context.Context) time.Duration {
+	ctx, span := tracer.Start(ctx, "LockoutService.Failed")
+	defer span.End()
+
+	s.mu.Lock()
+	now := s.now()
+	var lockouts []*models.AuthLockout
+	userFailures, lockout := s.count(s.users, username, s.policy.MaxFailures, now)
+	if lockout != nil {
+		lockout.Subject = models.LockoutUsername
+		lockouts = append(lockouts, lockout)
+	}
+	ipFailures, lockout := s.count(s.ips, ip, s.policy.MaxFailuresPerIP, now)
+	if lockout != nil {
+		lockout.Subject = models.LockoutIP
+		lockouts = append(lockouts, lockout)
+	}
+	s.mu.Unlock()
+
+	for _, lockout := range lockouts {
+		lockout.RemoteAddr = ip
+		s.logger.WarnContext(ctx, "Login locked out after repeated failures",
+			"subject", lockout.Subject, "value", lockout.Value, "failures", lockout.Failures, "remote_addr", ip, "locked_until", lockout.LockedUntil)
+		if err := s.repo.Create(lockout, ctx); err != nil {
+			s.logger.ErrorContext(ctx, "Error recording lockout", "error", err, "subject", lockout.Subject, "value", lockout.Value)
+		}
+	}
+
+	return s.delay(max(userFailures, ipFailures))
+}
+
+// count adds a failure for key and returns the failures so far, and the lockout when it reached limit
+func (s *LockoutServiceSQLite) count(counts map[string]*failures, key string, limit int, now time.Time) (int, *models.AuthLockout) {
+	f, ok := counts[key]
+	if !ok || now.Sub(f.since) > s.policy.Window {
+		f = &failures{since: now, lockedUntil: timeOrZero(f)}
+		counts[key] = f
+	}
+	f.count++
+	if limit == 0 || f.count < limit {
+		return f.count, nil
+	}
+
+	// Start counting again, so the next lockout needs another full set of failures
+	failed := f.count
+	f.count, f.since = 0, now
+	f.lockedUntil = now.Add(s.policy.Duration)
+	return failed, &models.AuthLockout{
+		Value:       key,
+		Failures:    failed,
+		LockedAt:    now.UTC().Format(time.RFC3339),
+		LockedUntil: f.lockedUntil.UTC().Format(time.RFC3339),
+	}
+}
+
+func timeOrZero(f *failures) time.Time {
+	if f == nil {
+		return time.Time{}
+	}
+	return f.lockedUntil
+}
+
+// delay grows exponentially with the failures in a row, the first failure is answered right away
+func (s *LockoutServiceSQLite) delay(failed int) time.Duration {
+	if failed < 2 {
+		return 0
+	}
+	delay := firstDelay
+	for i := 2; i < failed && delay < s.policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, s.policy.MaxDelay)
+}
+
+// Succeeded forgets the failed logins of the username, failures from the IP address still count
+func (s *LockoutServiceSQLite) Succeeded(username string, ip string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, ok := s.users[username]; ok && !f.lockedUntil.After(s.now()) {
+		delete(s.users, username)
+	}
+}
+
+// ReadMany returns the recorded lockouts, newest first
+func (s *LockoutServiceSQLite) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.AuthLockout, error) {
+	ctx, span := tracer.Start(ctx, "LockoutService.ReadMany")
+	defer span.End()
+
+	return s.repo.ReadMany(page, rowsPerPage, ctx)
+}
+
+// Run forgets expired failures and lockouts every interval until ctx is done
+func (s *LockoutServiceSQLite) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.cleanup()
+		}
+	}
+}
+
+func (s *LockoutServiceSQLite) cleanup() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for _, counts := range []map[string]*failures{s.users, s.ips} {
+		for key, f := range counts {
+			if now.Sub(f.since) > s.policy.Window && !f.lockedUntil.After(now) {
+				delete(counts, key)
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package lockout
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// In-memory repository, enough to check which lockouts are recorded
+type memoryLockouts struct {
+	lockouts []*models.AuthLockout
+}
+
+func (m *memoryLockouts) Create(lockout *models.AuthLockout, ctx context.Context) error {
+	lockout.ID = len(m.lockouts) + 1
+	m.lockouts = append(m.lockouts, lockout)
+	return nil
+}
+
+func (m *memoryLockouts) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.AuthLockout, error) {
+	return m.lockouts, nil
+}
+
+func newTestService(policy Policy) (*LockoutServiceSQLite, *memoryLockouts, *time.Time) {
+	repo := &memoryLockouts{}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	service := NewLockoutServiceSQLite(repo, policy, slog.Default())
+	service.now = func() time.Time { return now }
+	return service, repo, &now
+}
+
+var testPolicy = Policy{MaxFailures: 3, MaxFailuresPerIP: 5, Window: time.Minute, Duration: 10 * time.Minute, MaxDelay: 300 * time.Millisecond}
+
+func TestFailedDelays(t *testing.T) {
+
+	service, _, _ := newTestService(Policy{Window: time.Minute, Duration: time.Minute, MaxDelay: 300 * time.Millisecond})
+
+	expected := []time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond}
+	for i, delay := range expected {
+		if got := service.Failed("admin", "192.0.2.1", context.Background()); got != delay {
+			t.Errorf("Failure %d: expected delay %v, got %v", i+1, delay, got)
+		}
+	}
+}
+
+func TestUsernameLockout(t *testing.T) {
+
+	service, repo, now := newTestService(testPolicy)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, locked := service.Locked("admin", "192.0.2.1"); locked {
+			t.Fatalf("Locked after %d failures", i)
+		}
+		service.Failed("admin", "192.0.2.1", ctx)
+	}
+
+	retryAfter, locked := service.Locked("admin", "192.0.2.2")
+	if !locked || retryAfter != 10*time.Minute {
+		t.Errorf("Expected admin to be locked for 10m from any IP, got %v %v", locked, retryAfter)
+	}
+	if _, locked := service.Locked("operator", "192.0.2.2"); locked {
+		t.Error("Other usernames should not be locked")
+	}
+	if len(repo.lockouts) != 1 || repo.lockouts[0].Subject != models.LockoutUsername || repo.lockouts[0].Value != "admin" || repo.lockouts[0].Failures != 3 {
+		t.Errorf("Expected one recorded username lockout, got %+v", repo.lockouts)
+	}
+
+	// A correct password does not lift the lockout
+	service.Succeeded("admin", "192.0.2.1")
+	if _, locked := service.Locked("admin", "192.0.2.1"); !locked {
+		t.Error("Success should not lift a lockout")
+	}
+
+	*now = now.Add(10*time.Minute + time.Second)
+	if _, locked := service.Locked("admin", "192.0.2.1"); locked {
+		t.Error("Lockout should have expired")
+	}
+}
+
+func TestIPLockout(t *testing.T) {
+
+	service, repo, _ := newTestService(testPolicy)
+	ctx := context.Background()
+
+	// Spread over usernames so only the IP reaches its limit
+	for _, username := range []string{"a", "b", "c", "d", "e"} {
+		service.Failed(username, "192.0.2.1", ctx)
+	}
+
+	if _, locked := service.Locked("admin", "192.0.2.1"); !locked {
+		t.Error("Expected IP address to be locked")
+	}
+	if _, locked := service.Locked("admin", "192.0.2.2"); locked {
+		t.Error("Other IP addresses should not be locked")
+	}
+	if len(repo.lockouts) != 1 || repo.lockouts[0].Subject != models.LockoutIP || repo.lockouts[0].Value != "192.0.2.1" {
+		t.Errorf("Expected one recorded IP lockout, got %+v", repo.lockouts)
+	}
+}
+
+func TestSucceededResetsUsername(t *testing.T) {
+
+	service, repo, now := newTestService(testPolicy)
+	ctx := context.Background()
+
+	service.Failed("admin", "192.0.2.1", ctx)
+	service.Failed("admin", "192.0.2.1", ctx)
+	service.Succeeded("admin", "192.0.2.1")
+	service.Failed("admin", "192.0.2.1", ctx)
+	service.Failed("admin", "192.0.2.1", ctx)
+	if _, locked := service.Locked("admin", "192.0.2.1"); locked {
+		t.Error("Success should reset the failures of the username")
+	}
+
+	// Failures outside the window are forgotten
+	*now = now.Add(2 * time.Minute)
+	service.Failed("admin", "192.0.2.1", ctx)
+	if _, locked := service.Locked("admin", "192.0.2.1"); locked || len(repo.lockouts) != 0 {
+		t.Errorf("Failures outside the window should not count, got %d lockouts", len(repo.lockouts))
+	}
+}
+
+func TestDisabledLimits(t *testing.T) {
+
+	service, repo, _ := newTestService(Policy{Window: time.Minute, Duration: time.Minute})
+
+	for i := 0; i < 100; i++ {
+		service.Failed("admin", "192.0.2.1", context.Background())
+	}
+	if _, locked := service.Locked("admin", "192.0.2.1"); locked || len(repo.lockouts) != 0 {
+		t.Error("Zero limits should never lock out")
+	}
+}
@@ -0,0 +1,46 @@
+package lockout
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"time"
+)
+
+// LockoutService slows down and locks out repeated failed logins per username and per source IP
+type LockoutService interface {
+	Locked(username string, ip string) (retryAfter time.Duration, locked bool)
+	Failed(username string, ip string, ctx context.Context) (delay time.Duration)
+	Succeeded(username string, ip string)
+	ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.AuthLockout, error)
+}
+
+// Policy configures when logins are locked out
+type Policy struct {
+	MaxFailures      int           // Failed logins per username within Window before it is locked, 0 disables
+	MaxFailuresPerIP int           // Failed logins per source IP within Window before it is locked, 0 disables
+	Window           time.Duration // Failures older than this are forgotten
+	Duration         time.Duration // How long a lockout lasts
+	MaxDelay         time.Duration // Upper bound of the delay added to a failed login, it doubles with every failure
+}
+
+// Wait sleeps for the delay Failed returned, or until ctx is done
+func Wait(ctx context.Context, delay time.Duration) {
+	if delay <= 0 {
+		return
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// LockoutError represents a business logic error
+type LockoutError struct {
+	Message string
+}
+
+func (e LockoutError) Error() string {
+	return e.Message
+}
@@ -3,9 +3,14 @@ package maze_device
 import (
 	"context"
 	"goapi/internal/api/repository/models"
+	"strconv"
 	"time"
+
+	"go.opentelemetry.io/otel"
 )
 
+var tracer = otel.Tracer("goapi/internal/api/service/maze_device")
+
 // MazeDeviceStatusServiceSQLite implements MazeDeviceStatusService for SQLite
 type MazeDeviceStatusServiceSQLite struct {
 	repo models.MazeDeviceStatusRepository
@@ -18,13 +23,61 @@ func NewMazeDeviceStatusServiceSQLite(repo models.MazeDeviceStatusRepository) *M
 }
 
 func (s *MazeDeviceStatusServiceSQLite) Create(status *models.MazeDeviceStatus, ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "MazeDeviceStatusService.Create")
+	defer span.End()
+
 	if err := s.ValidateStatus(status); err != nil {
-		return MazeDeviceStatusError{Message: "Invalid maze device status: " + err.Error()}
+		return err
 	}
 	return s.repo.Create(status, ctx)
 }
 
+// CreateBatch validates every status and stores the valid ones in one transaction,
+// statuses already stored for the same device and timestamp are reported as duplicates
+func (s *MazeDeviceStatusServiceSQLite) CreateBatch(statuses []*models.MazeDeviceStatus, ctx context.Context) ([]models.BatchResult, error) {
+	ctx, span := tracer.Start(ctx, "MazeDeviceStatusService.CreateBatch")
+	defer span.End()
+
+	if len(statuses) > models.MaxBatchSize {
+		return nil, MazeDeviceStatusError{Message: "A batch may hold at most " + strconv.Itoa(models.MaxBatchSize) + " statuses."}
+	}
+
+	results := make([]models.BatchResult, len(statuses))
+	var valid []*models.MazeDeviceStatus
+	var positions []int
+	for i, status := range statuses {
+		results[i].Index = i
+		if err := s.ValidateStatus(status); err != nil {
+			invalid := err.(MazeDeviceStatusError)
+			results[i].Status = models.BatchRejected
+			results[i].Error, results[i].Errors = invalid.Message, invalid.Fields
+			continue
+		}
+		valid = append(valid, status)
+		positions = append(positions, i)
+	}
+	if len(valid) == 0 {
+		return results, nil
+	}
+
+	created, err := s.repo.CreateMany(valid, ctx)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range positions {
+		results[i].ID = valid[j].ID
+		results[i].Status = models.BatchDuplicate
+		if created[j] {
+			results[i].Status = models.BatchAccepted
+		}
+	}
+	return results, nil
+}
+
 func (s *MazeDeviceStatusServiceSQLite) ReadOne(id int, ctx context.Context) (*models.MazeDeviceStatus, error) {
+	ctx, span := tracer.Start(ctx, "MazeDeviceStatusService.ReadOne")
+	defer span.End()
+
 	status, err := s.repo.ReadOne(id, ctx)
 	if err != nil {
 		return nil, err
@@ -32,60 +85,95 @@ func (s *MazeDeviceStatusServiceSQLite) ReadOne(id int, ctx context.Context) (*m
 	return status, nil
 }
 
-func (s *MazeDeviceStatusServiceSQLite) ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.MazeDeviceStatus, error) {
-	return s.repo.ReadMany(page, rowsPerPage, ctx)
+// ReadMany returns a page of statuses, the span covers ranging over them since that is when they are read
+func (s *MazeDeviceStatusServiceSQLite) ReadMany(page int, rowsPerPage int, ctx context.Context) models.Rows[models.MazeDeviceStatus] {
+	return func(yield func(*models.MazeDeviceStatus, error) bool) {
+		ctx, span := tracer.Start(ctx, "MazeDeviceStatusService.ReadMany")
+		defer span.End()
+
+		for status, err := range s.repo.ReadMany(page, rowsPerPage, ctx) {
+			if !yield(status, err) {
+				return
+			}
+		}
+	}
 }
 
-func (s *MazeDeviceStatusServiceSQLite) ReadByDeviceID(deviceID string, ctx context.Context) ([]*models.MazeDeviceStatus, error) {
-	if deviceID == "" {
-		return nil, MazeDeviceStatusError{Message: "device_id is required"}
+// ReadByDeviceID returns every status of a device, an invalid device ID is yielded as a MazeDeviceStatusError
+func (s *MazeDeviceStatusServiceSQLite) ReadByDeviceID(deviceID string, ctx context.Context) models.Rows[models.MazeDeviceStatus] {
+	return func(yield func(*models.MazeDeviceStatus, error) bool) {
+		ctx, span := tracer.Start(ctx, "MazeDeviceStatusService.ReadByDeviceID")
+		defer span.End()
+
+		if deviceID == "" {
+			yield(nil, MazeDeviceStatusError{Message: "device_id is required"})
+			return
+		}
+		for status, err := range s.repo.ReadByDeviceID(deviceID, ctx) {
+			if !yield(status, err) {
+				return
+			}
+		}
 	}
-	return s.repo.ReadByDeviceID(deviceID, ctx)
+}
+
+// ReadLatest returns the most recent status reported by each device
+func (s *MazeDeviceStatusServiceSQLite) ReadLatest(ctx context.Context) ([]*models.MazeDeviceStatus, error) {
+	ctx, span := tracer.Start(ctx, "MazeDeviceStatusService.ReadLatest")
+	defer span.End()
+
+	return s.repo.ReadLatest(ctx)
 }
 
 func (s *MazeDeviceStatusServiceSQLite) Update(status *models.MazeDeviceStatus, ctx context.Context) (int64, error) {
+	ctx, span := tracer.Start(ctx, "MazeDeviceStatusService.Update")
+	defer span.End()
+
 	if err := s.ValidateStatus(status); err != nil {
-		return 0, MazeDeviceStatusError{Message: "Invalid maze device status: " + err.Error()}
+		return 0, err
 	}
 	return s.repo.Update(status, ctx)
 }
 
 func (s *MazeDeviceStatusServiceSQLite) Delete(status *models.MazeDeviceStatus, ctx context.Context) (int64, error) {
+	ctx, span := tracer.Start(ctx, "MazeDeviceStatusService.Delete")
+	defer span.End()
+
 	return s.repo.Delete(status, ctx)
 }
 
 // ValidateStatus validates the maze device status according to the requirements
 func (s *MazeDeviceStatusServiceSQLite) ValidateStatus(status *models.MazeDeviceStatus) error {
-	var errMsg string
+	var fields models.FieldErrors
 
 	// Validate device_id (required, max 50 chars)
 	if status.DeviceID == "" || len(status.DeviceID) > 50 {
-		errMsg += "device_id is required and must be less than 50 characters. "
+		fields.Add("device_id", "is required and must be less than 50 characters")
 	}
 
 	// Validate battery_level (must be 0-100)
 	if status.BatteryLevel < 0 || status.BatteryLevel > 100 {
-		errMsg += "battery_level must be between 0 and 100. "
+		fields.Add("battery_level", "must be between 0 and 100")
 	}
 
 	// Validate timestamp format (RFC3339) and not in the future
 	timestamp, err := time.Parse(time.RFC3339, status.Timestamp)
 	if err != nil {
-		errMsg += "timestamp must be in RFC3339 format (e.g., 2006-01-02T15:04:05Z07:00). "
+		fields.Add("timestamp", "must be in RFC3339 format (e.g., 2006-01-02T15:04:05Z07:00)")
 	} else {
 		// Check if timestamp is not in the future (allow 1 minute tolerance for clock skew)
 		if timestamp.After(time.Now().Add(1 * time.Minute)) {
-			errMsg += "timestamp must not be in the future. "
+			fields.Add("timestamp", "must not be in the future")
 		}
 	}
 
 	// Business logic validation: if maze_completed is true, hall_sensor_value should be true
 	if status.MazeCompleted && !status.HallSensorValue {
-		errMsg += "maze_completed cannot be true when hall_sensor_value is false. "
+		fields.Add("maze_completed", "cannot be true when hall_sensor_value is false")
 	}
 
-	if errMsg != "" {
-		return MazeDeviceStatusError{Message: errMsg}
+	if len(fields) > 0 {
+		return MazeDeviceStatusError{Message: "Invalid maze device status.", Fields: fields}
 	}
 	return nil
 }
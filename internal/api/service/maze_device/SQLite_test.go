@@ -1,7 +1,16 @@
 package maze_device
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"goapi/internal/api/repository/DAL/SQLite"
 	"goapi/internal/api/repository/models"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -199,3 +208,208 @@ func TestValidateStatus(t *testing.T) {
 		})
 	}
 }
+
+// memoryStatuses is a repository keeping statuses in memory, only CreateMany is used by CreateBatch
+type memoryStatuses struct {
+	models.MazeDeviceStatusRepository
+	stored []*models.MazeDeviceStatus
+}
+
+func (m *memoryStatuses) CreateMany(statuses []*models.MazeDeviceStatus, ctx context.Context) ([]bool, error) {
+	created := make([]bool, len(statuses))
+	for i, status := range statuses {
+		duplicate := false
+		for _, stored := range m.stored {
+			if stored.DeviceID == status.DeviceID && stored.Timestamp == status.Timestamp {
+				status.ID = stored.ID
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+		status.ID = len(m.stored) + 1
+		m.stored = append(m.stored, status)
+		created[i] = true
+	}
+	return created, nil
+}
+
+func TestCreateBatch(t *testing.T) {
+	repo := &memoryStatuses{}
+	service := NewMazeDeviceStatusServiceSQLite(repo)
+	timestamp := time.Now().Add(-1 * time.Hour).UTC().Format(time.RFC3339)
+
+	// A status stored before the batch, e.g. by a request the device timed out on
+	repo.stored = append(repo.stored, &models.MazeDeviceStatus{ID: 1, DeviceID: "ARD001", BatteryLevel: 80, Timestamp: timestamp})
+
+	statuses := []*models.MazeDeviceStatus{
+		{DeviceID: "ARD001", BatteryLevel: 80, Timestamp: timestamp},
+		{DeviceID: "ARD001", BatteryLevel: 120, Timestamp: timestamp},
+		{DeviceID: "ARD002", BatteryLevel: 60, Timestamp: timestamp},
+		{DeviceID: "ARD002", BatteryLevel: 60, Timestamp: timestamp},
+	}
+	results, err := service.CreateBatch(statuses, context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := []models.BatchResult{
+		{Index: 0, Status: models.BatchDuplicate, ID: 1},
+		{Index: 1, Status: models.BatchRejected, Error: "Invalid maze device status.", Errors: models.FieldErrors{{Field: "battery_level", Message: "must be between 0 and 100"}}},
+		{Index: 2, Status: models.BatchAccepted, ID: 2},
+		{Index: 3, Status: models.BatchDuplicate, ID: 2},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results, got %d", len(expected), len(results))
+	}
+	for i := range expected {
+		if !reflect.DeepEqual(results[i], expected[i]) {
+			t.Errorf("Expected result %d to be %+v, got %+v", i, expected[i], results[i])
+		}
+	}
+	if len(repo.stored) != 2 {
+		t.Errorf("Expected 2 stored statuses, got %d", len(repo.stored))
+	}
+}
+
+func TestCreateBatchTooLarge(t *testing.T) {
+	service := NewMazeDeviceStatusServiceSQLite(&memoryStatuses{})
+
+	statuses := make([]*models.MazeDeviceStatus, models.MaxBatchSize+1)
+	if _, err := service.CreateBatch(statuses, context.Background()); err == nil {
+		t.Error("Expected an error for a batch above the maximum size")
+	} else if _, ok := err.(MazeDeviceStatusError); !ok {
+		t.Errorf("Expected a MazeDeviceStatusError, got %T", err)
+	}
+}
+
+func TestCreateBatchUniqueIndex(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	// A database written before the unique index existed, holding the same status twice
+	legacy, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := legacy.Exec(`CREATE TABLE maze_device_status (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		device_id VARCHAR(50) NOT NULL,
+		alarm_active BOOLEAN NOT NULL,
+		maze_completed BOOLEAN NOT NULL,
+		hall_sensor_value BOOLEAN NOT NULL,
+		battery_level INTEGER NOT NULL,
+		timestamp TIMESTAMP NOT NULL
+	);
+	INSERT INTO maze_device_status (device_id, alarm_active, maze_completed, hall_sensor_value, battery_level, timestamp) VALUES
+		('ARD001', 0, 0, 0, 80, '2024-01-15T10:00:00Z'),
+		('ARD001', 0, 0, 0, 70, '2024-01-15T10:00:00Z'),
+		('ARD002', 0, 0, 0, 60, '2024-01-15T10:00:00Z');`); err != nil {
+		t.Fatal(err)
+	}
+	legacy.Close()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db, err := SQLite.NewSqlite(path, SQLite.PoolConfig{MaxOpenConns: 1, MaxIdleConns: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The duplicates fail the setup, naming the rows, and are left in place
+	_, err = SQLite.NewMazeDeviceStatusRepository(db, logger, ctx)
+	if err == nil || !strings.Contains(err.Error(), "device_id ARD001, timestamp 2024-01-15T10:00:00Z (ids 1, 2)") {
+		t.Fatalf("Expected the setup to report the duplicate statuses, got %v", err)
+	}
+	legacy, err = sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := legacy.QueryRow("SELECT COUNT(*) FROM maze_device_status").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Fatalf("Expected the duplicates to be kept, got %d statuses", count)
+	}
+
+	// Once resolved by hand the index is created
+	if _, err := legacy.Exec("DELETE FROM maze_device_status WHERE id = 2"); err != nil {
+		t.Fatal(err)
+	}
+	legacy.Close()
+	db, err = SQLite.NewSqlite(path, SQLite.PoolConfig{MaxOpenConns: 1, MaxIdleConns: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := SQLite.NewMazeDeviceStatusRepository(db, logger, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The index rejects a duplicate written outside of a batch
+	err = repo.Create(&models.MazeDeviceStatus{DeviceID: "ARD002", BatteryLevel: 50, Timestamp: "2024-01-15T10:00:00Z"}, ctx)
+	if !errors.As(err, new(*models.DuplicateError)) {
+		t.Errorf("Expected the unique index to reject the status, got %v", err)
+	}
+
+	service := NewMazeDeviceStatusServiceSQLite(repo)
+	results, err := service.CreateBatch([]*models.MazeDeviceStatus{
+		{DeviceID: "ARD001", BatteryLevel: 80, Timestamp: "2024-01-15T10:00:00Z"},
+		{DeviceID: "ARD001", BatteryLevel: 80, Timestamp: "2024-01-15T11:00:00Z"},
+		{DeviceID: "ARD001", BatteryLevel: 80, Timestamp: "2024-01-15T11:00:00Z"},
+	}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, status := range []string{models.BatchDuplicate, models.BatchAccepted, models.BatchDuplicate} {
+		if results[i].Status != status {
+			t.Errorf("Expected result %d to be %s, got %+v", i, status, results[i])
+		}
+	}
+	if results[0].ID != 1 || results[2].ID != results[1].ID {
+		t.Errorf("Expected duplicates to get the id of the stored status, got %+v", results)
+	}
+}
+
+func TestReadManyReportsQueryOnClose(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	db, err := SQLite.NewSqlite(filepath.Join(t.TempDir(), "test.db"), SQLite.PoolConfig{MaxOpenConns: 1, MaxIdleConns: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reported := 0
+	db.SetQueryObserver(func(repository string, method string, duration time.Duration, err error) {
+		if method == "ReadMany" {
+			reported++
+		}
+	})
+	repo, err := SQLite.NewMazeDeviceStatusRepository(db, slog.New(slog.NewTextHandler(io.Discard, nil)), ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, timestamp := range []string{"2024-01-15T10:00:00Z", "2024-01-15T11:00:00Z"} {
+		if err := repo.Create(&models.MazeDeviceStatus{DeviceID: "ARD001", BatteryLevel: 80, Timestamp: timestamp}, ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The query is reported once its rows are read and closed, not when it starts executing
+	read := 0
+	for status, err := range repo.ReadMany(1, 10, ctx) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reported != 0 {
+			t.Fatalf("Expected the query to be reported after reading %s, got %d reports while reading", status.Timestamp, reported)
+		}
+		read++
+	}
+	if read != 2 || reported != 1 {
+		t.Errorf("Expected 2 statuses and the query reported once, got %d and %d", read, reported)
+	}
+}
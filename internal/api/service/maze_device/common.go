@@ -8,9 +8,11 @@ import (
 // MazeDeviceStatusService defines the interface for maze device status business logic
 type MazeDeviceStatusService interface {
 	Create(status *models.MazeDeviceStatus, ctx context.Context) error
+	CreateBatch(statuses []*models.MazeDeviceStatus, ctx context.Context) ([]models.BatchResult, error)
 	ReadOne(id int, ctx context.Context) (*models.MazeDeviceStatus, error)
-	ReadMany(page int, rowsPerPage int, ctx context.Context) ([]*models.MazeDeviceStatus, error)
-	ReadByDeviceID(deviceID string, ctx context.Context) ([]*models.MazeDeviceStatus, error)
+	ReadMany(page int, rowsPerPage int, ctx context.Context) models.Rows[models.MazeDeviceStatus]
+	ReadByDeviceID(deviceID string, ctx context.Context) models.Rows[models.MazeDeviceStatus]
+	ReadLatest(ctx context.Context) ([]*models.MazeDeviceStatus, error)
 	Update(status *models.MazeDeviceStatus, ctx context.Context) (int64, error)
 	Delete(status *models.MazeDeviceStatus, ctx context.Context) (int64, error)
 	ValidateStatus(status *models.MazeDeviceStatus) error
@@ -19,8 +21,12 @@ type MazeDeviceStatusService interface {
 // MazeDeviceStatusError represents a business logic error
 type MazeDeviceStatusError struct {
 	Message string
+	Fields  models.FieldErrors // Invalid fields, set when validation failed
 }
 
 func (e MazeDeviceStatusError) Error() string {
-	return e.Message
+	if len(e.Fields) == 0 {
+		return e.Message
+	}
+	return e.Message + " " + e.Fields.String()
 }
@@ -0,0 +1,284 @@
+package provisioning
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"goapi/internal/api/repository/models"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+)
+
+var tracer = otel.Tracer("goapi/internal/api/service/provisioning")
+
+const (
+	// DefaultClaimTTL is used when the admin does not ask for a specific claim code lifetime
+	DefaultClaimTTL = 15 * time.Minute
+	// MaxClaimTTL bounds how long a claim code may stay redeemable
+	MaxClaimTTL = 24 * time.Hour
+
+	// Default configuration created for a freshly provisioned device
+	defaultAlarmTimeout     = 300
+	defaultSensitivityLevel = 5
+
+	// Claim codes avoid characters that are easy to confuse when typed by hand (0/O, 1/I)
+	claimCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	claimCodeLength   = 8
+)
+
+var (
+	hardwareIDPattern = regexp.MustCompile(`^[A-Za-z0-9_:\-]{1,50}$`)
+	// Device IDs double as Basic auth usernames, which cannot contain ':'
+	deviceIDPattern = regexp.MustCompile(`^[A-Za-z0-9_\-]{1,50}$`)
+)
+
+// ProvisioningServiceSQLite implements ProvisioningService for SQLite
+type ProvisioningServiceSQLite struct {
+	claims      models.ClaimCodeRepository
+	credentials models.DeviceCredentialRepository
+	configs     models.DeviceConfigRepository
+	reserved    []string
+}
+
+// NewProvisioningServiceSQLite creates the service. Device IDs double as usernames, so the reserved usernames,
+// e.g. the configured admin, are never given to a device.
+func NewProvisioningServiceSQLite(claims models.ClaimCodeRepository, credentials models.DeviceCredentialRepository, configs models.DeviceConfigRepository, reserved ...string) *ProvisioningServiceSQLite {
+	return &ProvisioningServiceSQLite{
+		claims:      claims,
+		credentials: credentials,
+		configs:     configs,
+		reserved:    reserved,
+	}
+}
+
+// validDeviceID reports whether deviceID can be used as a Basic auth username without taking another account's
+func (s *ProvisioningServiceSQLite) validDeviceID(deviceID string) bool {
+	if !deviceIDPattern.MatchString(deviceID) {
+		return false
+	}
+	for _, username := range s.reserved {
+		if strings.EqualFold(deviceID, username) {
+			return false
+		}
+	}
+	return true
+}
+
+// CreateClaim generates a new claim code bound to claim.IssuedBy that expires after ttl
+func (s *ProvisioningServiceSQLite) CreateClaim(claim *models.ClaimCode, ttl time.Duration, ctx context.Context) error {
+	ctx, span := tracer.Start(ctx, "ProvisioningService.CreateClaim")
+	defer span.End()
+
+	if ttl == 0 {
+		ttl = DefaultClaimTTL
+	}
+	if ttl < time.Minute || ttl > MaxClaimTTL {
+		return ProvisioningError{Message: "ttl_seconds must be between 60 and 86400."}
+	}
+	if claim.IssuedBy == "" {
+		return ProvisioningError{Message: "Claim codes must be issued by an authenticated user."}
+	}
+	if claim.DeviceID != "" && !s.validDeviceID(claim.DeviceID) {
+		return ProvisioningError{Message: "device_id must be 1-50 characters of letters, digits, '_' or '-', and not a reserved username."}
+	}
+
+	code, err := newClaimCode()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	claim.Code = code
+	claim.CreatedAt = now.Format(time.RFC3339)
+	claim.ExpiresAt = now.Add(ttl).Format(time.RFC3339)
+	claim.UsedAt = ""
+	claim.HardwareID = ""
+	return s.claims.Create(claim, ctx)
+}
+
+func (s *ProvisioningServiceSQLite) ReadClaims(issuedBy string, ctx context.Context) ([]*models.ClaimCode, error) {
+	ctx, span := tracer.Start(ctx, "ProvisioningService.ReadClaims")
+	defer span.End()
+
+	return s.claims.ReadByIssuer(issuedBy, ctx)
+}
+
+// DeleteClaim revokes a claim code, only the issuing user may revoke it
+func (s *ProvisioningServiceSQLite) DeleteClaim(claim *models.ClaimCode, ctx context.Context) (int64, error) {
+	ctx, span := tracer.Start(ctx, "ProvisioningService.DeleteClaim")
+	defer span.End()
+
+	return s.claims.Delete(claim, ctx)
+}
+
+// Provision redeems a claim code for the requesting hardware and returns its new credentials.
+// A device that is provisioned again (e.g. after a flash wipe) keeps its device ID but gets a new password.
+func (s *ProvisioningServiceSQLite) Provision(request *ProvisioningRequest, ctx context.Context) (*ProvisionedDevice, error) {
+	ctx, span := tracer.Start(ctx, "ProvisioningService.Provision")
+	defer span.End()
+
+	if !hardwareIDPattern.MatchString(request.HardwareID) {
+		return nil, ProvisioningError{Message: "hardware_id must be 1-50 characters of letters, digits, '_', ':' or '-'."}
+	}
+	code := normalizeClaimCode(request.ClaimCode)
+
+	claim, err := s.claims.ReadByCode(code, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if claim == nil {
+		return nil, ProvisioningError{Message: "Invalid, expired or already used claim code."}
+	}
+
+	// Without an explicit device ID the hardware ID is used, e.g. MAC 24:6F:28:AA:BB:CC becomes 246F28AABBCC
+	deviceID := claim.DeviceID
+	if deviceID == "" {
+		deviceID = strings.ReplaceAll(request.HardwareID, ":", "")
+	}
+	if !s.validDeviceID(deviceID) {
+		return nil, ProvisioningError{Message: "The device ID derived from hardware_id is not a valid username, claim a code with an explicit device_id."}
+	}
+
+	// A device ID can only belong to one piece of hardware
+	existing, err := s.credentials.ReadByDeviceID(deviceID, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.HardwareID != request.HardwareID {
+		return nil, ProvisioningError{Message: "device_id is already provisioned for different hardware."}
+	}
+	if existing == nil {
+		if existing, err = s.credentials.ReadByHardwareID(request.HardwareID, ctx); err != nil {
+			return nil, err
+		}
+		// Renaming the device of this hardware would hand its credential, and its data, to the new device ID
+		if existing != nil && existing.DeviceID != deviceID {
+			return nil, ProvisioningError{Message: "hardware_id is already provisioned as device " + existing.DeviceID + ".", Conflict: true}
+		}
+	}
+
+	password, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	claim.HardwareID = request.HardwareID
+	claim.DeviceID = deviceID
+	claim.UsedAt = time.Now().UTC().Format(time.RFC3339)
+	credential := &models.DeviceCredential{
+		DeviceID:   deviceID,
+		HardwareID: request.HardwareID,
+		Owner:      claim.IssuedBy,
+		SecretHash: hashSecret(password),
+		CreatedAt:  claim.UsedAt,
+	}
+	if existing != nil {
+		credential.ID = existing.ID
+	}
+
+	// Redeem the claim and store the credential together, this fails if another request used the code first or it
+	// expired meanwhile. A failed credential write leaves the code redeemable.
+	redeemed, err := s.claims.Redeem(claim, credential, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if redeemed == 0 {
+		return nil, ProvisioningError{Message: "Invalid, expired or already used claim code."}
+	}
+
+	config, err := s.configs.ReadByDeviceID(deviceID, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		config = &models.DeviceConfig{
+			DeviceID:         deviceID,
+			AlarmTimeout:     defaultAlarmTimeout,
+			SensitivityLevel: defaultSensitivityLevel,
+			UpdatedAt:        claim.UsedAt,
+		}
+		if err := s.configs.Create(config, ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return &ProvisionedDevice{
+		DeviceID: deviceID,
+		Username: deviceID,
+		Password: password,
+		Config:   config,
+	}, nil
+}
+
+// Authenticate checks Basic credentials issued to a provisioned device and returns its device ID
+func (s *ProvisioningServiceSQLite) Authenticate(username string, password string, ctx context.Context) (string, bool) {
+	ctx, span := tracer.Start(ctx, "ProvisioningService.Authenticate")
+	defer span.End()
+
+	credential, err := s.credentials.ReadByDeviceID(username, ctx)
+	if err != nil || credential == nil {
+		return "", false
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSecret(password)), []byte(credential.SecretHash)) != 1 {
+		return "", false
+	}
+	return credential.DeviceID, true
+}
+
+// Credential returns the device ID and secret hash of the credentials issued to username, the hash changes when the
+// device is provisioned again so refresh tokens issued before stop working
+func (s *ProvisioningServiceSQLite) Credential(username string, ctx context.Context) (string, string, bool) {
+	ctx, span := tracer.Start(ctx, "ProvisioningService.Credential")
+	defer span.End()
+
+	credential, err := s.credentials.ReadByDeviceID(username, ctx)
+	if err != nil || credential == nil {
+		return "", "", false
+	}
+	return credential.DeviceID, credential.SecretHash, true
+}
+
+// PurgeExpiredClaims deletes claim codes that expired without being redeemed
+func (s *ProvisioningServiceSQLite) PurgeExpiredClaims(ctx context.Context) (int64, error) {
+	ctx, span := tracer.Start(ctx, "ProvisioningService.PurgeExpiredClaims")
+	defer span.End()
+
+	return s.claims.DeleteExpired(time.Now().UTC().Format(time.RFC3339), ctx)
+}
+
+func newClaimCode() (string, error) {
+	buf := make([]byte, claimCodeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	for i, b := range buf {
+		// The alphabet has 32 characters, so masking keeps the distribution uniform
+		buf[i] = claimCodeAlphabet[b&31]
+	}
+	return string(buf), nil
+}
+
+// normalizeClaimCode accepts codes typed in lower case or grouped with dashes and spaces
+func normalizeClaimCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+func newSecret() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,420 @@
+package provisioning
+
+import (
+	"context"
+	"errors"
+	"goapi/internal/api/repository/DAL/SQLite"
+	"goapi/internal/api/repository/models"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// In-memory repositories, enough to exercise the provisioning flow without a database
+type memoryClaims struct {
+	claims      []*models.ClaimCode
+	credentials *memoryCredentials // Written when a claim is redeemed
+}
+
+func (m *memoryClaims) Create(claim *models.ClaimCode, ctx context.Context) error {
+	claim.ID = len(m.claims) + 1
+	copied := *claim
+	m.claims = append(m.claims, &copied)
+	return nil
+}
+
+func (m *memoryClaims) ReadByCode(code string, ctx context.Context) (*models.ClaimCode, error) {
+	for _, c := range m.claims {
+		if c.Code == code {
+			copied := *c
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryClaims) ReadByIssuer(issuedBy string, ctx context.Context) ([]*models.ClaimCode, error) {
+	return m.claims, nil
+}
+
+func (m *memoryClaims) Redeem(claim *models.ClaimCode, credential *models.DeviceCredential, ctx context.Context) (int64, error) {
+	for _, c := range m.claims {
+		if c.Code == claim.Code && c.UsedAt == "" && c.ExpiresAt > claim.UsedAt {
+			var err error
+			if credential.ID == 0 {
+				err = m.credentials.Create(credential, ctx)
+			} else {
+				_, err = m.credentials.Update(credential, ctx)
+			}
+			if err != nil {
+				return 0, err
+			}
+			c.UsedAt, c.HardwareID, c.DeviceID = claim.UsedAt, claim.HardwareID, claim.DeviceID
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (m *memoryClaims) Delete(claim *models.ClaimCode, ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (m *memoryClaims) DeleteExpired(before string, ctx context.Context) (int64, error) {
+	var kept []*models.ClaimCode
+	for _, c := range m.claims {
+		if c.UsedAt != "" || c.ExpiresAt > before {
+			kept = append(kept, c)
+		}
+	}
+	purged := int64(len(m.claims) - len(kept))
+	m.claims = kept
+	return purged, nil
+}
+
+type memoryCredentials struct {
+	credentials []*models.DeviceCredential
+	err         error // Returned by writes when set
+}
+
+func (m *memoryCredentials) Create(credential *models.DeviceCredential, ctx context.Context) error {
+	if m.err != nil {
+		return m.err
+	}
+	credential.ID = len(m.credentials) + 1
+	copied := *credential
+	m.credentials = append(m.credentials, &copied)
+	return nil
+}
+
+func (m *memoryCredentials) ReadByDeviceID(deviceID string, ctx context.Context) (*models.DeviceCredential, error) {
+	for _, c := range m.credentials {
+		if c.DeviceID == deviceID {
+			return c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryCredentials) ReadByHardwareID(hardwareID string, ctx context.Context) (*models.DeviceCredential, error) {
+	for _, c := range m.credentials {
+		if c.HardwareID == hardwareID {
+			return c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryCredentials) Update(credential *models.DeviceCredential, ctx context.Context) (int64, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	for i, c := range m.credentials {
+		if c.ID == credential.ID {
+			copied := *credential
+			m.credentials[i] = &copied
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+type memoryConfigs struct {
+	configs []*models.DeviceConfig
+}
+
+func (m *memoryConfigs) Create(config *models.DeviceConfig, ctx context.Context) error {
+	m.configs = append(m.configs, config)
+	return nil
+}
+
+func (m *memoryConfigs) CreateMany(configs []*models.DeviceConfig, ctx context.Context) ([]bool, error) {
+	return nil, nil
+}
+
+func (m *memoryConfigs) CheckMany(configs []*models.DeviceConfig, ctx context.Context) ([]bool, error) {
+	return nil, nil
+}
+
+func (m *memoryConfigs) ReadOne(id int, ctx context.Context) (*models.DeviceConfig, error) {
+	return nil, nil
+}
+
+func (m *memoryConfigs) ReadByDeviceID(deviceID string, ctx context.Context) (*models.DeviceConfig, error) {
+	for _, c := range m.configs {
+		if c.DeviceID == deviceID {
+			return c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryConfigs) ReadMany(page int, rowsPerPage int, ctx context.Context) models.Rows[models.DeviceConfig] {
+	return models.RowsOf(m.configs, nil)
+}
+
+func (m *memoryConfigs) Update(config *models.DeviceConfig, ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (m *memoryConfigs) Delete(config *models.DeviceConfig, ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+func newTestService() (*ProvisioningServiceSQLite, *memoryClaims, *memoryConfigs) {
+	credentials := &memoryCredentials{}
+	claims := &memoryClaims{credentials: credentials}
+	configs := &memoryConfigs{}
+	return NewProvisioningServiceSQLite(claims, credentials, configs, "admin"), claims, configs
+}
+
+func TestCreateClaimValidation(t *testing.T) {
+	service, _, _ := newTestService()
+
+	tests := []struct {
+		name        string
+		claim       models.ClaimCode
+		ttl         time.Duration
+		expectError bool
+	}{
+		{name: "Default TTL", claim: models.ClaimCode{IssuedBy: "admin"}, ttl: 0},
+		{name: "Explicit device ID", claim: models.ClaimCode{IssuedBy: "admin", DeviceID: "ESP32_MAZE_002"}, ttl: time.Hour},
+		{name: "TTL too short", claim: models.ClaimCode{IssuedBy: "admin"}, ttl: time.Second, expectError: true},
+		{name: "TTL too long", claim: models.ClaimCode{IssuedBy: "admin"}, ttl: 48 * time.Hour, expectError: true},
+		{name: "Missing issuer", claim: models.ClaimCode{}, ttl: 0, expectError: true},
+		{name: "Invalid device ID", claim: models.ClaimCode{IssuedBy: "admin", DeviceID: "bad id!"}, ttl: 0, expectError: true},
+		{name: "Reserved device ID", claim: models.ClaimCode{IssuedBy: "admin", DeviceID: "Admin"}, ttl: 0, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := service.CreateClaim(&tt.claim, tt.ttl, context.Background())
+			if tt.expectError {
+				if _, ok := err.(ProvisioningError); !ok {
+					t.Errorf("Expected ProvisioningError, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(tt.claim.Code) != claimCodeLength {
+				t.Errorf("Expected a %d character code, got %q", claimCodeLength, tt.claim.Code)
+			}
+		})
+	}
+}
+
+func TestProvisionIsOneTimeUse(t *testing.T) {
+	service, _, configs := newTestService()
+	ctx := context.Background()
+
+	claim := models.ClaimCode{IssuedBy: "admin"}
+	if err := service.CreateClaim(&claim, 0, ctx); err != nil {
+		t.Fatalf("Error creating claim: %v", err)
+	}
+
+	device, err := service.Provision(&ProvisioningRequest{HardwareID: "24:6F:28:AA:BB:CC", ClaimCode: claim.Code}, ctx)
+	if err != nil {
+		t.Fatalf("Expected provisioning to succeed, got %v", err)
+	}
+	if device.DeviceID != "246F28AABBCC" || device.Password == "" {
+		t.Errorf("Unexpected provisioned device: %+v", device)
+	}
+	if len(configs.configs) != 1 || configs.configs[0].DeviceID != device.DeviceID {
+		t.Errorf("Expected a default config for %s, got %+v", device.DeviceID, configs.configs)
+	}
+
+	if deviceID, ok := service.Authenticate(device.Username, device.Password, ctx); !ok || deviceID != device.DeviceID {
+		t.Errorf("Expected issued credentials to authenticate")
+	}
+	if _, ok := service.Authenticate(device.Username, "wrong", ctx); ok {
+		t.Errorf("Expected wrong password to be rejected")
+	}
+
+	_, err = service.Provision(&ProvisioningRequest{HardwareID: "24:6F:28:AA:BB:DD", ClaimCode: claim.Code}, ctx)
+	if _, ok := err.(ProvisioningError); !ok {
+		t.Errorf("Expected reused claim code to be rejected, got %v", err)
+	}
+}
+
+func TestProvisionExpiredClaim(t *testing.T) {
+	service, claims, _ := newTestService()
+	ctx := context.Background()
+
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	claims.Create(&models.ClaimCode{Code: "ABCDEFGH", IssuedBy: "admin", ExpiresAt: past, CreatedAt: past}, ctx)
+
+	_, err := service.Provision(&ProvisioningRequest{HardwareID: "HW1", ClaimCode: "abcd-efgh"}, ctx)
+	if _, ok := err.(ProvisioningError); !ok {
+		t.Errorf("Expected expired claim code to be rejected, got %v", err)
+	}
+}
+
+func TestFailedProvisionKeepsClaim(t *testing.T) {
+	service, claims, _ := newTestService()
+	ctx := context.Background()
+
+	claim := models.ClaimCode{IssuedBy: "admin"}
+	if err := service.CreateClaim(&claim, 0, ctx); err != nil {
+		t.Fatalf("Error creating claim: %v", err)
+	}
+
+	claims.credentials.err = errors.New("disk I/O error")
+	if _, err := service.Provision(&ProvisioningRequest{HardwareID: "HW1", ClaimCode: claim.Code}, ctx); err == nil {
+		t.Fatal("Expected the failed credential write to fail provisioning")
+	}
+
+	claims.credentials.err = nil
+	if _, err := service.Provision(&ProvisioningRequest{HardwareID: "HW1", ClaimCode: claim.Code}, ctx); err != nil {
+		t.Errorf("Expected the claim code to stay redeemable, got %v", err)
+	}
+}
+
+// TestRedeemIsAtomic redeems a claim whose credential cannot be stored against SQLite, the claim must stay redeemable
+func TestRedeemIsAtomic(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	db, err := SQLite.NewSqlite(filepath.Join(t.TempDir(), "test.db"), SQLite.PoolConfig{MaxOpenConns: 1, MaxIdleConns: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	credentials, err := SQLite.NewDeviceCredentialRepository(db, logger, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := SQLite.NewClaimCodeRepository(db, logger, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	if err := credentials.Create(&models.DeviceCredential{DeviceID: "DEV1", HardwareID: "HW1", Owner: "admin", SecretHash: "x", CreatedAt: now.Format(time.RFC3339)}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	claim := &models.ClaimCode{Code: "ABCDEFGH", IssuedBy: "admin", ExpiresAt: now.Add(time.Hour).Format(time.RFC3339), CreatedAt: now.Format(time.RFC3339)}
+	if err := claims.Create(claim, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// * The hardware ID is already taken, so inserting the credential violates its unique constraint *
+	claim.HardwareID, claim.DeviceID, claim.UsedAt = "HW1", "DEV2", now.Format(time.RFC3339)
+	credential := &models.DeviceCredential{DeviceID: "DEV2", HardwareID: "HW1", Owner: "admin", SecretHash: "y", CreatedAt: claim.UsedAt}
+	if _, err := claims.Redeem(claim, credential, ctx); err == nil {
+		t.Fatal("Expected the credential write to fail")
+	}
+
+	credential = &models.DeviceCredential{DeviceID: "DEV2", HardwareID: "HW2", Owner: "admin", SecretHash: "y", CreatedAt: claim.UsedAt}
+	claim.HardwareID = "HW2"
+	if redeemed, err := claims.Redeem(claim, credential, ctx); err != nil || redeemed != 1 {
+		t.Errorf("Expected the claim to stay redeemable, got %d, %v", redeemed, err)
+	}
+	if stored, err := credentials.ReadByDeviceID("DEV2", ctx); err != nil || stored == nil || stored.ID != credential.ID {
+		t.Errorf("Expected the credential to be stored with ID %d, got %+v, %v", credential.ID, stored, err)
+	}
+}
+
+func TestReprovisionRotatesPassword(t *testing.T) {
+	service, _, _ := newTestService()
+	ctx := context.Background()
+
+	provision := func() *ProvisionedDevice {
+		claim := models.ClaimCode{IssuedBy: "admin", DeviceID: "ESP32_MAZE_002"}
+		if err := service.CreateClaim(&claim, 0, ctx); err != nil {
+			t.Fatalf("Error creating claim: %v", err)
+		}
+		device, err := service.Provision(&ProvisioningRequest{HardwareID: "HW1", ClaimCode: claim.Code}, ctx)
+		if err != nil {
+			t.Fatalf("Expected provisioning to succeed, got %v", err)
+		}
+		return device
+	}
+
+	first := provision()
+	_, firstCredential, _ := service.Credential(first.Username, ctx)
+	second := provision()
+
+	// Refresh tokens are bound to the credential, a new one stops those issued before
+	if deviceID, credential, ok := service.Credential(second.Username, ctx); !ok || deviceID != second.DeviceID || credential == firstCredential {
+		t.Errorf("Expected a new credential for %s, got %q", second.DeviceID, credential)
+	}
+	if _, ok := service.Authenticate(first.Username, first.Password, ctx); ok {
+		t.Errorf("Expected the previous password to be revoked")
+	}
+	if _, ok := service.Authenticate(second.Username, second.Password, ctx); !ok {
+		t.Errorf("Expected the new password to authenticate")
+	}
+}
+
+func TestProvisionRejectsReservedHardwareID(t *testing.T) {
+	service, _, configs := newTestService()
+	ctx := context.Background()
+
+	claim := models.ClaimCode{IssuedBy: "admin"}
+	if err := service.CreateClaim(&claim, 0, ctx); err != nil {
+		t.Fatalf("Error creating claim: %v", err)
+	}
+
+	// Without an explicit device ID the hardware ID would become the admin's username
+	_, err := service.Provision(&ProvisioningRequest{HardwareID: "admin", ClaimCode: claim.Code}, ctx)
+	if e, ok := err.(ProvisioningError); !ok || e.Conflict {
+		t.Fatalf("Expected ProvisioningError, got %v", err)
+	}
+	if _, ok := service.Authenticate("admin", "", ctx); ok || len(configs.configs) != 0 {
+		t.Errorf("Expected no device to be provisioned")
+	}
+}
+
+func TestProvisionRejectsHardwareOfAnotherDevice(t *testing.T) {
+	service, _, _ := newTestService()
+	ctx := context.Background()
+
+	provision := func(deviceID string) (*ProvisionedDevice, error) {
+		claim := models.ClaimCode{IssuedBy: "admin", DeviceID: deviceID}
+		if err := service.CreateClaim(&claim, 0, ctx); err != nil {
+			t.Fatalf("Error creating claim: %v", err)
+		}
+		return service.Provision(&ProvisioningRequest{HardwareID: "HW1", ClaimCode: claim.Code}, ctx)
+	}
+
+	first, err := provision("ESP32_MAZE_001")
+	if err != nil {
+		t.Fatalf("Expected provisioning to succeed, got %v", err)
+	}
+
+	// Claiming the same hardware under another device ID would take over the first device's credential
+	_, err = provision("ESP32_MAZE_002")
+	if e, ok := err.(ProvisioningError); !ok || !e.Conflict {
+		t.Fatalf("Expected a conflicting ProvisioningError, got %v", err)
+	}
+	if _, ok := service.Authenticate(first.Username, first.Password, ctx); !ok {
+		t.Errorf("Expected the first device to keep its credential")
+	}
+	if _, _, ok := service.Credential("ESP32_MAZE_002", ctx); ok {
+		t.Errorf("Expected no credential for the second device ID")
+	}
+}
+
+func TestPurgeExpiredClaims(t *testing.T) {
+	service, claims, _ := newTestService()
+	ctx := context.Background()
+
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	claims.Create(&models.ClaimCode{Code: "EXPIRED1", IssuedBy: "admin", ExpiresAt: past, CreatedAt: past}, ctx)
+	claims.Create(&models.ClaimCode{Code: "REDEEMED", IssuedBy: "admin", ExpiresAt: past, UsedAt: past, CreatedAt: past}, ctx)
+	if err := service.CreateClaim(&models.ClaimCode{IssuedBy: "admin"}, 0, ctx); err != nil {
+		t.Fatalf("Error creating claim: %v", err)
+	}
+
+	purged, err := service.PurgeExpiredClaims(ctx)
+	if err != nil || purged != 1 {
+		t.Errorf("Expected 1 purged claim, got %d, %v", purged, err)
+	}
+	if len(claims.claims) != 2 {
+		t.Errorf("Expected redeemed and unexpired claims to be kept, got %d", len(claims.claims))
+	}
+}
@@ -0,0 +1,42 @@
+package provisioning
+
+import (
+	"context"
+	"goapi/internal/api/repository/models"
+	"time"
+)
+
+// ProvisioningService defines the interface for device onboarding business logic
+type ProvisioningService interface {
+	CreateClaim(claim *models.ClaimCode, ttl time.Duration, ctx context.Context) error
+	ReadClaims(issuedBy string, ctx context.Context) ([]*models.ClaimCode, error)
+	DeleteClaim(claim *models.ClaimCode, ctx context.Context) (int64, error)
+	Provision(request *ProvisioningRequest, ctx context.Context) (*ProvisionedDevice, error)
+	Authenticate(username string, password string, ctx context.Context) (string, bool)
+	Credential(username string, ctx context.Context) (deviceID string, secretHash string, ok bool)
+	PurgeExpiredClaims(ctx context.Context) (int64, error)
+}
+
+// ProvisioningRequest is sent by a device to exchange a claim code for its own credentials
+type ProvisioningRequest struct {
+	HardwareID string `json:"hardware_id"` // Hardware identifier of the device, e.g. the ESP32 MAC address
+	ClaimCode  string `json:"claim_code"`  // Claim code generated by an admin
+}
+
+// ProvisionedDevice is returned to the device once its claim code has been redeemed
+type ProvisionedDevice struct {
+	DeviceID string               `json:"device_id"`
+	Username string               `json:"username"`
+	Password string               `json:"password"` // Only returned once, the server keeps a hash
+	Config   *models.DeviceConfig `json:"config"`
+}
+
+// ProvisioningError represents a business logic error
+type ProvisioningError struct {
+	Message  string
+	Conflict bool // Set when the request collides with the credentials of another device
+}
+
+func (e ProvisioningError) Error() string {
+	return e.Message
+}
@@ -0,0 +1,232 @@
+package tabular
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// maxLineBytes bounds a single NDJSON line
+const maxLineBytes = 1 << 20
+
+// RowError is a row that could not be read, reading may continue with the next row.
+// Column is the JSON name of the offending column when the error is about a single cell.
+type RowError struct {
+	Line   int
+	Column string
+	Err    error
+}
+
+func (e *RowError) Error() string {
+	if e.Column == "" {
+		return "line " + strconv.Itoa(e.Line) + ": " + e.Err.Error()
+	}
+	return "line " + strconv.Itoa(e.Line) + ": " + e.Column + " " + e.Err.Error()
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// Reader reads rows written as CSV or newline delimited JSON, the formats a Writer writes
+type Reader struct {
+	format  string
+	csv     *csv.Reader
+	lines   *bufio.Scanner
+	columns []column
+	line    int
+}
+
+// NewReader returns a reader of rows shaped like sample, a struct or a pointer to one. A CSV header is read right away:
+// it names the columns by the JSON names of sample's fields, in any order, and may leave columns out. A malformed
+// header is returned as a RowError of line 1.
+func NewReader(r io.Reader, format string, sample any) (*Reader, error) {
+	tr := &Reader{format: format}
+	switch format {
+	case CSV:
+		tr.csv = csv.NewReader(r)
+		tr.csv.ReuseRecord = true
+		header, err := tr.csv.Read()
+		if err == io.EOF {
+			return tr, nil
+		}
+		if err != nil {
+			return nil, rowError(err, 1)
+		}
+
+		fields := make(map[string]int)
+		for _, c := range columnsOf(sample) {
+			fields[c.name] = c.index
+		}
+		seen := make(map[string]bool)
+		for i, name := range header {
+			if i == 0 {
+				// Spreadsheets like to start UTF-8 files with a byte order mark
+				name = strings.TrimPrefix(name, "\ufeff")
+			}
+			index, ok := fields[name]
+			if !ok {
+				return nil, &RowError{Line: 1, Column: name, Err: errors.New("is not a known column")}
+			}
+			if seen[name] {
+				return nil, &RowError{Line: 1, Column: name, Err: errors.New("appears twice")}
+			}
+			seen[name] = true
+			tr.columns = append(tr.columns, column{name: name, index: index})
+		}
+	case NDJSON:
+		tr.lines = bufio.NewScanner(r)
+		tr.lines.Buffer(make([]byte, 0, 4096), maxLineBytes)
+	default:
+		return nil, errors.New("tabular: cannot read " + format)
+	}
+	return tr, nil
+}
+
+// Read reads the next row into row, a pointer to a zero value of the sample's type. It returns io.EOF after the last
+// row and a RowError for a row it could not read, other errors end the input.
+func (tr *Reader) Read(row any) error {
+	if tr.format == NDJSON {
+		return tr.readJSON(row)
+	}
+	if tr.columns == nil {
+		return io.EOF
+	}
+
+	record, err := tr.csv.Read()
+	if err != nil {
+		return rowError(err, tr.line+1)
+	}
+	tr.line, _ = tr.csv.FieldPos(0)
+
+	value := reflect.Indirect(reflect.ValueOf(row))
+	for i, c := range tr.columns {
+		if err := parseCell(value.Field(c.index), record[i]); err != nil {
+			return &RowError{Line: tr.line, Column: c.name, Err: err}
+		}
+	}
+	return nil
+}
+
+// Line returns the line the last row read started on, counting from 1
+func (tr *Reader) Line() int {
+	return tr.line
+}
+
+func (tr *Reader) readJSON(row any) error {
+	for tr.lines.Scan() {
+		tr.line++
+		text := bytes.TrimSpace(tr.lines.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		if err := json.Unmarshal(text, row); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) && typeErr.Field != "" {
+				return &RowError{Line: tr.line, Column: typeErr.Field, Err: errors.New("must be " + expected(typeErr.Type.Kind()))}
+			}
+			return &RowError{Line: tr.line, Err: errors.New("invalid JSON")}
+		}
+		return nil
+	}
+	if err := tr.lines.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+// rowError turns a CSV parse error into a RowError, other errors like a failing body are returned as they are
+func rowError(err error, line int) error {
+	var parseErr *csv.ParseError
+	if !errors.As(err, &parseErr) {
+		return err
+	}
+	if parseErr.StartLine > 0 {
+		line = parseErr.StartLine
+	}
+	return &RowError{Line: line, Err: parseErr.Err}
+}
+
+// columnsOf returns the fields of sample with a JSON name, in declaration order
+func columnsOf(sample any) []column {
+	t := reflect.TypeOf(sample)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var columns []column
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" || name == "" {
+			continue
+		}
+		columns = append(columns, column{name: name, index: i})
+	}
+	return columns
+}
+
+// parseCell parses a CSV cell into a field, reversing formatCell. Empty cells leave the zero value.
+func parseCell(field reflect.Value, text string) error {
+	if text == "" {
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		if len(text) > 1 && text[0] == '\'' && strings.ContainsRune("=+-@\t\r", rune(text[1])) {
+			text = text[1:]
+		}
+		field.SetString(text)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return errors.New("must be true or false")
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(text, 10, field.Type().Bits())
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		field.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(text, 10, field.Type().Bits())
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		field.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, field.Type().Bits())
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		field.SetFloat(f)
+	default:
+		if err := json.Unmarshal([]byte(text), field.Addr().Interface()); err != nil {
+			return errors.New("must be valid JSON")
+		}
+	}
+	return nil
+}
+
+// expected names the JSON value expected for a Go kind, for messages like "must be an integer"
+func expected(kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	default:
+		return "valid JSON"
+	}
+}
@@ -0,0 +1,64 @@
+package tabular
+
+import (
+	"iter"
+	"net/http"
+)
+
+// Response is a 200 response whose body is a table written one row at a time. The status line is sent with the first
+// row, so until Started a failure can still be answered with a problem instead.
+type Response struct {
+	w      http.ResponseWriter
+	format string
+	sample any
+	rows   *Writer
+}
+
+// NewResponse returns a response writing rows shaped like sample in format
+func NewResponse(w http.ResponseWriter, format string, sample any) *Response {
+	return &Response{w: w, format: format, sample: sample}
+}
+
+// Started reports whether the status line was sent
+func (res *Response) Started() bool {
+	return res.rows != nil
+}
+
+// Write writes one row, sending the status line before the first
+func (res *Response) Write(row any) error {
+	res.start()
+	return res.rows.Write(row)
+}
+
+// Close ends the body, a response without rows is sent as an empty table
+func (res *Response) Close() error {
+	res.start()
+	return res.rows.Close()
+}
+
+func (res *Response) start() {
+	if res.rows != nil {
+		return
+	}
+	res.w.Header().Set("Content-Type", ContentType(res.format))
+	res.w.WriteHeader(http.StatusOK)
+	res.rows = NewWriter(res.w, res.format, res.sample)
+}
+
+// Copy writes rows to res as they are read, encode returns what is written for a row. It stops at the first error,
+// of the sequence or of writing. A nil encode writes rows as they are.
+func Copy[T any](res *Response, rows iter.Seq2[*T, error], encode func(row *T) any) error {
+	for row, err := range rows {
+		if err != nil {
+			return err
+		}
+		var encoded any = row
+		if encode != nil {
+			encoded = encode(row)
+		}
+		if err := res.Write(encoded); err != nil {
+			return err
+		}
+	}
+	return nil
+}
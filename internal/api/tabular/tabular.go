@@ -0,0 +1,196 @@
+// Package tabular writes rows of a table as a JSON array, CSV or newline delimited JSON, and reads them back from CSV
+// or newline delimited JSON, one row at a time so tables of any size can be streamed. Response streams them as the
+// body of an HTTP response.
+package tabular
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"mime"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Formats, named by their media type
+const (
+	JSON   = "application/json"
+	CSV    = "text/csv"
+	NDJSON = "application/x-ndjson"
+)
+
+// ContentType returns the Content-Type header of a format
+func ContentType(format string) string {
+	if format == CSV {
+		return "text/csv; charset=utf-8"
+	}
+	return format
+}
+
+// Negotiate returns the format the Accept header prefers. fallback is returned when the header is empty, accepts
+// anything, or names no format this package writes.
+func Negotiate(accept string, fallback string) string {
+	type mediaRange struct {
+		mediaType string
+		q         float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, mediaRange{mediaType: mediaType, q: q})
+		}
+	}
+	// Equally preferred ranges keep the order of the header
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	for _, r := range ranges {
+		switch r.mediaType {
+		case JSON, CSV, NDJSON:
+			return r.mediaType
+		case "*/*":
+			return fallback
+		case "text/*":
+			return CSV
+		case "application/*":
+			if fallback == CSV {
+				return JSON
+			}
+			return fallback
+		}
+	}
+	return fallback
+}
+
+// column is a field written as a CSV column, named after its JSON name
+type column struct {
+	name  string
+	index int
+}
+
+// Writer writes rows in one format. Close must be called after the last row, it ends the JSON array and writes
+// what CSV buffered.
+type Writer struct {
+	w       io.Writer
+	format  string
+	csv     *csv.Writer
+	columns []column
+	rows    int
+}
+
+// NewWriter returns a writer of rows shaped like sample, a struct or a pointer to one. The fields of sample with a
+// JSON name are the CSV columns, in declaration order.
+func NewWriter(w io.Writer, format string, sample any) *Writer {
+	tw := &Writer{w: w, format: format}
+	if format == CSV {
+		tw.csv = csv.NewWriter(w)
+		tw.columns = columnsOf(sample)
+	}
+	return tw
+}
+
+// Write writes one row, a value of the sample's type
+func (tw *Writer) Write(row any) error {
+	tw.rows++
+	switch tw.format {
+	case CSV:
+		if tw.rows == 1 {
+			if err := tw.writeHeader(); err != nil {
+				return err
+			}
+		}
+		value := reflect.Indirect(reflect.ValueOf(row))
+		record := make([]string, len(tw.columns))
+		for i, c := range tw.columns {
+			cell, err := formatCell(value.Field(c.index))
+			if err != nil {
+				return err
+			}
+			record[i] = cell
+		}
+		return tw.csv.Write(record)
+	case NDJSON:
+		return tw.writeJSON(row, "", "\n")
+	default:
+		separator := ","
+		if tw.rows == 1 {
+			separator = "["
+		}
+		return tw.writeJSON(row, separator, "")
+	}
+}
+
+// Close ends the output, an empty CSV still gets its header and an empty JSON array is []
+func (tw *Writer) Close() error {
+	switch tw.format {
+	case CSV:
+		if tw.rows == 0 {
+			if err := tw.writeHeader(); err != nil {
+				return err
+			}
+		}
+		tw.csv.Flush()
+		return tw.csv.Error()
+	case NDJSON:
+		return nil
+	default:
+		end := "]\n"
+		if tw.rows == 0 {
+			end = "[]\n"
+		}
+		_, err := io.WriteString(tw.w, end)
+		return err
+	}
+}
+
+func (tw *Writer) writeHeader() error {
+	header := make([]string, len(tw.columns))
+	for i, c := range tw.columns {
+		header[i] = c.name
+	}
+	return tw.csv.Write(header)
+}
+
+func (tw *Writer) writeJSON(row any, before string, after string) error {
+	encoded, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(tw.w, before+string(encoded)+after)
+	return err
+}
+
+// formatCell formats a field for CSV. Text starting like a formula is prefixed with a quote, so a spreadsheet opening
+// the file shows it instead of evaluating it.
+func formatCell(value reflect.Value) (string, error) {
+	switch value.Kind() {
+	case reflect.String:
+		text := value.String()
+		if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+			text = "'" + text
+		}
+		return text, nil
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(value.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(value.Float(), 'f', -1, 64), nil
+	default:
+		encoded, err := json.Marshal(value.Interface())
+		return string(encoded), err
+	}
+}
@@ -0,0 +1,258 @@
+package tabular
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type reading struct {
+	ID          int     `json:"id"`
+	DeviceID    string  `json:"device_id"`
+	Active      bool    `json:"active"`
+	Value       float64 `json:"value"`
+	Description string  `json:"description,omitempty"`
+	internal    string
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept   string
+		fallback string
+		want     string
+	}{
+		{accept: "", fallback: JSON, want: JSON},
+		{accept: "*/*", fallback: CSV, want: CSV},
+		{accept: "text/csv", fallback: JSON, want: CSV},
+		{accept: "application/x-ndjson", fallback: JSON, want: NDJSON},
+		{accept: "text/html, text/csv;q=0.5, application/x-ndjson;q=0.8", fallback: JSON, want: NDJSON},
+		{accept: "text/csv;q=0, */*", fallback: JSON, want: JSON},
+		{accept: "text/*", fallback: JSON, want: CSV},
+		{accept: "image/png", fallback: JSON, want: JSON},
+	}
+
+	for _, tt := range tests {
+		if got := Negotiate(tt.accept, tt.fallback); got != tt.want {
+			t.Errorf("Negotiate(%q, %q) = %q, want %q", tt.accept, tt.fallback, got, tt.want)
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	rows := []*reading{
+		{ID: 1, DeviceID: "ARD001", Active: true, Value: 21.5, Description: "kitchen, \"north\""},
+		{ID: 2, DeviceID: "ARD002", Value: -3, Description: "=HYPERLINK(\"http://example.com\")", internal: "hidden"},
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		rows   []*reading
+		want   string
+	}{
+		{
+			name: "CSV", format: CSV, rows: rows,
+			want: "id,device_id,active,value,description\n" +
+				"1,ARD001,true,21.5,\"kitchen, \"\"north\"\"\"\n" +
+				"2,ARD002,false,-3,\"'=HYPERLINK(\"\"http://example.com\"\")\"\n",
+		},
+		{name: "Empty CSV", format: CSV, want: "id,device_id,active,value,description\n"},
+		{
+			name: "NDJSON", format: NDJSON, rows: rows,
+			want: `{"id":1,"device_id":"ARD001","active":true,"value":21.5,"description":"kitchen, \"north\""}` + "\n" +
+				`{"id":2,"device_id":"ARD002","active":false,"value":-3,"description":"=HYPERLINK(\"http://example.com\")"}` + "\n",
+		},
+		{name: "Empty NDJSON", format: NDJSON, want: ""},
+		{
+			name: "JSON", format: JSON, rows: rows[:1],
+			want: `[{"id":1,"device_id":"ARD001","active":true,"value":21.5,"description":"kitchen, \"north\""}]` + "\n",
+		},
+		{name: "Empty JSON", format: JSON, want: "[]\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			writer := NewWriter(&out, tt.format, reading{})
+			for _, row := range tt.rows {
+				if err := writer.Write(row); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestReaderReadsWhatWriterWrites(t *testing.T) {
+	rows := []*reading{
+		{ID: 1, DeviceID: "ARD001", Active: true, Value: 21.5, Description: "kitchen, \"north\""},
+		{ID: 2, DeviceID: "ARD002", Value: -3, Description: "=HYPERLINK(\"http://example.com\")"},
+	}
+
+	for _, format := range []string{CSV, NDJSON} {
+		t.Run(format, func(t *testing.T) {
+			var out bytes.Buffer
+			writer := NewWriter(&out, format, reading{})
+			for _, row := range rows {
+				if err := writer.Write(row); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			reader, err := NewReader(&out, format, reading{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []*reading
+			for {
+				row := new(reading)
+				if err := reader.Read(row); err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, row)
+			}
+			if !reflect.DeepEqual(got, rows) {
+				t.Errorf("got %+v, want %+v", got, rows)
+			}
+		})
+	}
+}
+
+func TestReaderCSV(t *testing.T) {
+	// Columns in any order, some left out, after a byte order mark
+	body := "\ufeffvalue,device_id\n" +
+		"1.5,ARD001\n" +
+		"warm,ARD002\n" +
+		"2.5\n" +
+		",ARD004\n"
+
+	reader, err := NewReader(strings.NewReader(body), CSV, reading{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		want   *reading
+		line   int
+		column string
+	}{
+		{want: &reading{DeviceID: "ARD001", Value: 1.5}, line: 2},
+		{line: 3, column: "value"},
+		{line: 4},
+		{want: &reading{DeviceID: "ARD004"}, line: 5},
+	}
+	for _, tt := range tests {
+		row := new(reading)
+		err := reader.Read(row)
+		if tt.want != nil {
+			if err != nil || !reflect.DeepEqual(row, tt.want) || reader.Line() != tt.line {
+				t.Errorf("Expected %+v on line %d, got %+v on line %d (%v)", tt.want, tt.line, row, reader.Line(), err)
+			}
+			continue
+		}
+		var rowErr *RowError
+		if !errors.As(err, &rowErr) || rowErr.Line != tt.line || rowErr.Column != tt.column {
+			t.Errorf("Expected a row error on line %d in column %q, got %v", tt.line, tt.column, err)
+		}
+	}
+	if err := reader.Read(new(reading)); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+}
+
+func TestReaderInvalidHeader(t *testing.T) {
+	for _, header := range []string{"device_id,colour\n", "device_id,device_id\n", "internal\n"} {
+		_, err := NewReader(strings.NewReader(header), CSV, reading{})
+		var rowErr *RowError
+		if !errors.As(err, &rowErr) || rowErr.Line != 1 {
+			t.Errorf("Expected a row error on line 1 for %q, got %v", header, err)
+		}
+	}
+}
+
+func TestResponse(t *testing.T) {
+	rows := []*reading{{ID: 1, DeviceID: "ARD001"}, {ID: 2, DeviceID: "ARD002"}}
+	failure := errors.New("database is locked")
+
+	tests := []struct {
+		name    string
+		rows    iter.Seq2[*reading, error]
+		started bool
+		want    string
+	}{
+		{name: "Rows", rows: seq(rows, nil), started: true, want: "id,device_id,active,value,description\n1,ARD001,false,0,\n2,ARD002,false,0,\n"},
+		{name: "Failure before the first row", rows: seq[reading](nil, failure)},
+		{name: "Failure after the first row", rows: seq(rows[:1], failure), started: true, want: "id,device_id,active,value,description\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			res := NewResponse(rr, CSV, reading{})
+			err := Copy(res, tt.rows, nil)
+			if res.Started() != tt.started {
+				t.Fatalf("Started() = %v, want %v", res.Started(), tt.started)
+			}
+			if !tt.started {
+				if err != failure || rr.Body.Len() != 0 || rr.Header().Get("Content-Type") != "" {
+					t.Errorf("Expected nothing sent and %v, got %v with %q", failure, err, rr.Body.String())
+				}
+				return
+			}
+			if err == nil {
+				if err := res.Close(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// CSV is buffered until Close, a cut short body holds at most what was flushed
+			if rr.Header().Get("Content-Type") != "text/csv; charset=utf-8" || !strings.HasPrefix(tt.want, rr.Body.String()) {
+				t.Errorf("got %q with %s, want %q", rr.Body.String(), rr.Header().Get("Content-Type"), tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseWithoutRows(t *testing.T) {
+	rr := httptest.NewRecorder()
+	res := NewResponse(rr, JSON, reading{})
+	if err := Copy(res, seq[reading](nil, nil), nil); err != nil || res.Started() {
+		t.Fatalf("Expected nothing sent, got %v", err)
+	}
+	if err := res.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if rr.Code != http.StatusOK || rr.Body.String() != "[]\n" {
+		t.Errorf("Expected an empty array, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+// seq yields rows, then err when it is not nil
+func seq[T any](rows []*T, err error) iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		for _, row := range rows {
+			if !yield(row, nil) {
+				return
+			}
+		}
+		if err != nil {
+			yield(nil, err)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package tlsreload
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"goapi/internal/api/config"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// nextProtos are offered over ALPN, the config returned for a handshake replaces the server's own,
+// so without them every connection falls back to HTTP/1.1
+var nextProtos = []string{"h2", "http/1.1"}
+
+// Reloader serves the certificate and client CA from files and picks up changes without a restart,
+// e.g. when a certificate is renewed
+type Reloader struct {
+	cfg    config.TLSConfig
+	logger *slog.Logger
+
+	mu       sync.RWMutex
+	current  *tls.Config
+	modTimes map[string]time.Time
+}
+
+// New loads the configured files, an invalid certificate fails here rather than on the first handshake
+func New(cfg config.TLSConfig, logger *slog.Logger) (*Reloader, error) {
+	rl := &Reloader{cfg: cfg, logger: logger}
+	if err := rl.reload(); err != nil {
+		return nil, err
+	}
+	return rl, nil
+}
+
+// TLSConfig returns the config for the HTTP server, every handshake uses the latest loaded files
+func (rl *Reloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: nextProtos,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			rl.mu.RLock()
+			defer rl.mu.RUnlock()
+			return rl.current, nil
+		},
+	}
+}
+
+// Watch checks the files every interval until ctx is done and reloads them when one changed.
+// A failed reload is logged and the previous certificate stays in use.
+func (rl *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !rl.changed() {
+			continue
+		}
+		if err := rl.reload(); err != nil {
+			rl.logger.Error("Error reloading TLS certificate, keeping the previous one", "error", err)
+			continue
+		}
+		rl.logger.Info("Reloaded TLS certificate", "cert_file", rl.cfg.CertFile)
+	}
+}
+
+func (rl *Reloader) files() []string {
+	files := []string{rl.cfg.CertFile, rl.cfg.KeyFile}
+	if rl.cfg.ClientCAFile != "" {
+		files = append(files, rl.cfg.ClientCAFile)
+	}
+	return files
+}
+
+func (rl *Reloader) changed() bool {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+	for _, file := range rl.files() {
+		info, err := os.Stat(file)
+		if err != nil || !info.ModTime().Equal(rl.modTimes[file]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (rl *Reloader) reload() error {
+	// Record the modification times first, so a write during loading is picked up by the next check
+	modTimes := make(map[string]time.Time)
+	for _, file := range rl.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTimes[file] = info.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(rl.cfg.CertFile, rl.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("loading TLS certificate: %w", err)
+	}
+
+	current := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   clientAuthType(rl.cfg.ClientAuth),
+		NextProtos:   nextProtos,
+	}
+	if rl.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(rl.cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("loading client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("loading client CA: no certificates found in " + rl.cfg.ClientCAFile)
+		}
+		current.ClientCAs = pool
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.current, rl.modTimes = current, modTimes
+	return nil
+}
+
+func clientAuthType(mode string) tls.ClientAuthType {
+	switch mode {
+	case config.ClientAuthOptional:
+		return tls.VerifyClientCertIfGiven
+	case config.ClientAuthRequire:
+		return tls.RequireAndVerifyClientCert
+	}
+	return tls.NoClientCert
+}
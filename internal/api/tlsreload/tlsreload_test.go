@@ -0,0 +1,184 @@
+package tlsreload
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"goapi/internal/api/config"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// issue creates a certificate for cn signed by parent, or self-signed CA when parent is nil
+func issue(t *testing.T, cn string, serial int64, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, []byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		template.IsCA, template.BasicConstraintsValid = true, true
+		template.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return cert, key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func write(t *testing.T, path string, data []byte, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadAndClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey, caPEM, _ := issue(t, "Maze CA", 1, nil, nil)
+	_, _, serverPEM, serverKeyPEM := issue(t, "localhost", 2, ca, caKey)
+	_, _, devicePEM, deviceKeyPEM := issue(t, "ESP32_MAZE_002", 3, ca, caKey)
+
+	cfg := config.TLSConfig{
+		CertFile:     filepath.Join(dir, "server.crt"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+		ClientAuth:   config.ClientAuthOptional,
+	}
+	initial := time.Now().Add(-time.Minute)
+	write(t, cfg.CertFile, serverPEM, initial)
+	write(t, cfg.KeyFile, serverKeyPEM, initial)
+	write(t, cfg.ClientCAFile, caPEM, initial)
+
+	rl, err := New(cfg, slog.Default())
+	if err != nil {
+		t.Fatalf("Error loading certificates: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.VerifiedChains) > 0 {
+			w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+		}
+	}))
+	server.TLS = rl.TLSConfig()
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	deviceCert, _ := tls.X509KeyPair(devicePEM, deviceKeyPEM)
+	get := func(certificates ...tls.Certificate) (string, *big.Int) {
+		t.Helper()
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certificates}}}
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Error requesting %s: %v", server.URL, err)
+		}
+		defer resp.Body.Close()
+		body := make([]byte, 64)
+		n, _ := resp.Body.Read(body)
+		return string(body[:n]), resp.TLS.PeerCertificates[0].SerialNumber
+	}
+
+	if cn, serial := get(deviceCert); cn != "ESP32_MAZE_002" || serial.Int64() != 2 {
+		t.Errorf("Expected device CN over the initial certificate, got %q, serial %d", cn, serial)
+	}
+	if cn, _ := get(); cn != "" {
+		t.Errorf("Expected no verified client certificate, got %q", cn)
+	}
+
+	// Renew the server certificate, the watcher serves it without a restart
+	_, _, renewedPEM, renewedKeyPEM := issue(t, "localhost", 4, ca, caKey)
+	write(t, cfg.CertFile, renewedPEM, time.Now())
+	write(t, cfg.KeyFile, renewedKeyPEM, time.Now())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go rl.Watch(ctx, 10*time.Millisecond)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, serial := get(); serial.Int64() == 4 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the renewed certificate to be served")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// A broken file keeps the previous certificate in use
+	write(t, cfg.CertFile, []byte("not a certificate"), time.Now().Add(time.Second))
+	time.Sleep(50 * time.Millisecond)
+	if _, serial := get(); serial.Int64() != 4 {
+		t.Errorf("Expected the renewed certificate to stay in use, got serial %d", serial)
+	}
+}
+
+func TestNewRejectsInvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	_, err := New(config.TLSConfig{CertFile: filepath.Join(dir, "missing.crt"), KeyFile: filepath.Join(dir, "missing.key")}, slog.Default())
+	if err == nil {
+		t.Error("Expected missing certificate files to fail")
+	}
+}
+
+func TestHTTP2(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey, _, _ := issue(t, "Maze CA", 1, nil, nil)
+	_, _, serverPEM, serverKeyPEM := issue(t, "localhost", 2, ca, caKey)
+	cfg := config.TLSConfig{CertFile: filepath.Join(dir, "server.crt"), KeyFile: filepath.Join(dir, "server.key")}
+	write(t, cfg.CertFile, serverPEM, time.Now())
+	write(t, cfg.KeyFile, serverKeyPEM, time.Now())
+
+	rl, err := New(cfg, slog.Default())
+	if err != nil {
+		t.Fatalf("Error loading certificates: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.EnableHTTP2 = true
+	server.TLS = rl.TLSConfig()
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}, ForceAttemptHTTP2: true}}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Error requesting %s: %v", server.URL, err)
+	}
+	resp.Body.Close()
+
+	if resp.TLS.NegotiatedProtocol != "h2" || resp.ProtoMajor != 2 {
+		t.Errorf("Expected h2 to be negotiated over ALPN, got %q and %s", resp.TLS.NegotiatedProtocol, resp.Proto)
+	}
+}
@@ -0,0 +1,25 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+func TestNewExporter(t *testing.T) {
+	ctx := context.Background()
+
+	for _, name := range []string{"", "none"} {
+		if exporter, err := newExporter(ctx, name, io.Discard); exporter != nil || err != nil {
+			t.Errorf("Expected no exporter for %q, got %v, %v", name, exporter, err)
+		}
+	}
+	for _, name := range []string{"console", "stdout", "otlp"} {
+		if exporter, err := newExporter(ctx, name, io.Discard); exporter == nil || err != nil {
+			t.Errorf("Expected an exporter for %q, got %v", name, err)
+		}
+	}
+	if _, err := newExporter(ctx, "zipkin", io.Discard); err == nil {
+		t.Errorf("Expected unsupported exporter to be rejected")
+	}
+}
@@ -21,6 +21,7 @@ interface DeviceConfig {
   alarm_timeout: number;
   sensitivity_level: number;
   updated_at: string;
+  version?: number;
 }
 
 interface GameSession {
@@ -96,9 +97,12 @@ class APIService {
     }
   }
 
-  async updateDeviceConfig(config: DeviceConfig): Promise<DeviceConfig> {
+  async updateDeviceConfig(config: DeviceConfig & { version: number }): Promise<DeviceConfig> {
     try {
-      const response = await this.client.put('/device/config', config);
+      // If-Match is required, the version of the loaded config the change is based on
+      const response = await this.client.put('/device/config', config, {
+        headers: { 'If-Match': `"${config.version}"` },
+      });
       return response.data;
     } catch (error) {
       console.error('Update device config error:', error);
@@ -12,6 +12,7 @@ interface DeviceStatus {
   hall_sensor_value: boolean;
   battery_level: number;
   timestamp: string;
+  version?: number;
 }
 
 interface DeviceConfig {
@@ -20,8 +21,13 @@ interface DeviceConfig {
   alarm_timeout: number;
   sensitivity_level: number;
   updated_at: string;
+  version?: number;
 }
 
+// Updates and deletes require If-Match with the version of the loaded resource the change is based on,
+// the server answers 412 when it changed since
+const ifMatch = (version: number) => ({ 'If-Match': `"${version}"` });
+
 class APIService {
   private client: AxiosInstance;
 
@@ -55,13 +61,13 @@ class APIService {
     return response.data;
   }
 
-  async updateDeviceStatus(status: DeviceStatus): Promise<DeviceStatus> {
-    const response = await this.client.put('/device/status', status);
+  async updateDeviceStatus(status: DeviceStatus & { version: number }): Promise<DeviceStatus> {
+    const response = await this.client.put('/device/status', status, { headers: ifMatch(status.version) });
     return response.data;
   }
 
-  async deleteDeviceStatus(id: number): Promise<void> {
-    await this.client.delete(`/device/status/${id}`);
+  async deleteDeviceStatus(id: number, version: number): Promise<void> {
+    await this.client.delete(`/device/status/${id}`, { headers: ifMatch(version) });
   }
 
   async getDeviceConfigs(deviceId?: string): Promise<DeviceConfig[]> {
@@ -80,13 +86,13 @@ class APIService {
     return response.data;
   }
 
-  async updateDeviceConfig(config: DeviceConfig): Promise<DeviceConfig> {
-    const response = await this.client.put('/device/config', config);
+  async updateDeviceConfig(config: DeviceConfig & { version: number }): Promise<DeviceConfig> {
+    const response = await this.client.put('/device/config', config, { headers: ifMatch(config.version) });
     return response.data;
   }
 
-  async deleteDeviceConfig(id: number): Promise<void> {
-    await this.client.delete(`/device/config/${id}`);
+  async deleteDeviceConfig(id: number, version: number): Promise<void> {
+    await this.client.delete(`/device/config/${id}`, { headers: ifMatch(version) });
   }
 }
 
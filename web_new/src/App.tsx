@@ -37,6 +37,7 @@ interface DeviceStatus {
   hall_sensor_value: boolean;
   battery_level: number;
   timestamp: string;
+  version: number;
 }
 
 function App() {
@@ -142,10 +143,11 @@ function App() {
       const response = await axios.get(`${API_URL}/device/status`, {
         headers: { Authorization: AUTH },
       });
-      const data = response.data || [];
+      const data: DeviceStatus[] = response.data || [];
       for (const session of data) {
+        // Deletes require If-Match, the version fetched above
         await axios.delete(`${API_URL}/device/status/${session.id}`, {
-          headers: { Authorization: AUTH },
+          headers: { Authorization: AUTH, 'If-Match': `"${session.version}"` },
         });
       }
       fetchData();